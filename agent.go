@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/go-events"
@@ -13,13 +14,26 @@ import (
 	"github.com/docker/libnetwork/discoverapi"
 	"github.com/docker/libnetwork/driverapi"
 	"github.com/docker/libnetwork/networkdb"
+	"github.com/docker/libnetwork/types"
 	"github.com/gogo/protobuf/proto"
 )
 
+// loadTable is the networkdb table gossiping the load of the service
+// backends, keyed by "<endpoint id>/<node address>".
+const loadTable = "service_load_table"
+
+// trafficTable is the networkdb table gossiping the traffic split of
+// the services between their deployment groups, keyed by service ID.
+const trafficTable = "service_traffic_table"
+
 type agent struct {
 	networkDB         *networkdb.NetworkDB
 	bindAddr          string
+	bindNet           *net.IPNet
 	epTblCancel       func()
+	loadTblCancel     func()
+	trafficTblCancel  func()
+	stopCh            chan struct{}
 	driverCancelFuncs map[string][]func()
 }
 
@@ -51,6 +65,27 @@ func getBindAddr(ifaceName string) (string, error) {
 	return "", fmt.Errorf("failed to get bind address")
 }
 
+// getBindNet returns the subnet of the local interface owning the bind
+// address.
+func getBindNet(bindAddr string) *net.IPNet {
+	ip := net.ParseIP(bindAddr)
+	if ip == nil {
+		return nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+
+	for _, a := range addrs {
+		if addr, ok := a.(*net.IPNet); ok && addr.IP.Equal(ip) {
+			return &net.IPNet{IP: ip.Mask(addr.Mask), Mask: addr.Mask}
+		}
+	}
+	return nil
+}
+
 func resolveAddr(addrOrInterface string) (string, error) {
 	// Try and see if this is a valid IP address
 	if net.ParseIP(addrOrInterface) != nil {
@@ -82,18 +117,45 @@ func (c *controller) agentInit(bindAddrOrInterface string) error {
 	}
 
 	ch, cancel := nDB.Watch("endpoint_table", "", "")
+	loadCh, loadCancel := nDB.Watch(loadTable, "", "")
+	trafficCh, trafficCancel := nDB.Watch(trafficTable, "", "")
 
 	c.agent = &agent{
 		networkDB:         nDB,
 		bindAddr:          bindAddr,
+		bindNet:           getBindNet(bindAddr),
 		epTblCancel:       cancel,
+		loadTblCancel:     loadCancel,
+		trafficTblCancel:  trafficCancel,
+		stopCh:            make(chan struct{}),
 		driverCancelFuncs: make(map[string][]func()),
 	}
 
 	go c.handleTableEvents(ch, c.handleEpTableEvent)
+	go c.handleTableEvents(loadCh, c.handleLoadTableEvent)
+	go c.handleTableEvents(trafficCh, c.handleTrafficTableEvent)
+	if interval := c.cfg.Daemon.LBLoadInterval; interval > 0 {
+		go c.publishLoadHints(interval, c.agent.stopCh)
+	}
 	return nil
 }
 
+// isPreferredBackend returns whether a backend running on the node with
+// the passed address matches the locality preference of its service.
+func (c *controller) isPreferredBackend(pref string, nodeAddr net.IP) bool {
+	if c.agent == nil || nodeAddr == nil {
+		return false
+	}
+
+	switch pref {
+	case LBPreferNode:
+		return nodeAddr.Equal(net.ParseIP(c.agent.bindAddr))
+	case LBPreferSubnet:
+		return c.agent.bindNet != nil && c.agent.bindNet.Contains(nodeAddr)
+	}
+	return false
+}
+
 func (c *controller) agentJoin(remote string) error {
 	if c.agent == nil {
 		return nil
@@ -124,6 +186,9 @@ func (c *controller) agentClose() {
 		}
 	}
 	c.agent.epTblCancel()
+	c.agent.loadTblCancel()
+	c.agent.trafficTblCancel()
+	close(c.agent.stopCh)
 
 	c.agent.networkDB.Close()
 	c.agent = nil
@@ -168,27 +233,13 @@ func (ep *endpoint) addToCluster() error {
 
 	c := n.getController()
 	if !ep.isAnonymous() && ep.Iface().Address() != nil {
-		var ingressPorts []*PortConfig
-		if ep.svcID != "" {
-			// Gossip ingress ports only in ingress network.
-			if n.ingress {
-				ingressPorts = ep.ingressPorts
-			}
-
-			if err := c.addServiceBinding(ep.svcName, ep.svcID, n.ID(), ep.ID(), ep.virtualIP, ingressPorts, ep.Iface().Address().IP); err != nil {
+		if ep.svcID != "" && !ep.isUnhealthy() {
+			if err := c.addServiceBinding(ep.svcName, ep.svcID, n.ID(), ep.ID(), ep.virtualIP, ep.virtualIPv6, ep.clusterIngressPorts(), ep.svcSchedName, ep.svcPersistTimeout, ep.svcPersistMaskLen, ep.svcDSR, ep.svcLBPreference, ep.svcUDPTimeout, ep.svcMaxConns, ep.svcConnRate, ep.svcMetadataRecords(), ep.Iface().Address().IP, ep.ipv6Addr(), net.ParseIP(c.agent.bindAddr), ep.svcWeight, ep.svcGroup); err != nil {
 				return err
 			}
 		}
 
-		buf, err := proto.Marshal(&EndpointRecord{
-			Name:         ep.Name(),
-			ServiceName:  ep.svcName,
-			ServiceID:    ep.svcID,
-			VirtualIP:    ep.virtualIP.String(),
-			IngressPorts: ingressPorts,
-			EndpointIP:   ep.Iface().Address().IP.String(),
-		})
-
+		buf, err := proto.Marshal(ep.endpointRecord())
 		if err != nil {
 			return err
 		}
@@ -207,6 +258,85 @@ func (ep *endpoint) addToCluster() error {
 	return nil
 }
 
+// clusterIngressPorts returns the ingress ports of the endpoint service
+// to be gossiped. Ingress ports are gossiped only in the ingress network.
+func (ep *endpoint) clusterIngressPorts() []*PortConfig {
+	if !ep.getNetwork().ingress {
+		return nil
+	}
+	return ep.ingressPorts
+}
+
+// ipv6Addr returns the IPv6 address of the endpoint, if it has one.
+func (ep *endpoint) ipv6Addr() net.IP {
+	if addr := ep.Iface().AddressIPv6(); addr != nil {
+		return addr.IP
+	}
+	return nil
+}
+
+func (ep *endpoint) endpointRecord() *EndpointRecord {
+	epRec := &EndpointRecord{
+		Name:               ep.Name(),
+		ServiceName:        ep.svcName,
+		ServiceID:          ep.svcID,
+		VirtualIP:          ep.virtualIP.String(),
+		IngressPorts:       ep.clusterIngressPorts(),
+		EndpointIP:         ep.Iface().Address().IP.String(),
+		ServiceMetadata:    ep.svcMetadataRecords(),
+		SchedName:          ep.svcSchedName,
+		PersistenceTimeout: ep.svcPersistTimeout,
+		PersistenceMaskLen: ep.svcPersistMaskLen,
+		Weight:             ep.svcWeight,
+		Unhealthy:          ep.isUnhealthy(),
+		DirectServerReturn: ep.svcDSR,
+		LbPreference:       ep.svcLBPreference,
+		NodeAddr:           ep.getNetwork().getController().agent.bindAddr,
+		UdpTimeout:         ep.svcUDPTimeout,
+		MaxConnections:     ep.svcMaxConns,
+		ConnectionRate:     ep.svcConnRate,
+		ServiceGroup:       ep.svcGroup,
+	}
+	if len(ep.virtualIPv6) != 0 {
+		epRec.VirtualIPv6 = ep.virtualIPv6.String()
+	}
+	if ip6 := ep.ipv6Addr(); ip6 != nil {
+		epRec.EndpointIPv6 = ip6.String()
+	}
+	return epRec
+}
+
+// updateHealthInCluster adds or removes the endpoint from its service
+// according to its health and gossips the change to the other nodes.
+func (ep *endpoint) updateHealthInCluster() error {
+	n := ep.getNetwork()
+	if !n.isClusterEligible() {
+		return nil
+	}
+
+	if ep.svcID == "" || ep.isAnonymous() || ep.Iface().Address() == nil {
+		return nil
+	}
+
+	c := n.getController()
+	if ep.isUnhealthy() {
+		if err := c.rmServiceBinding(ep.svcName, ep.svcID, n.ID(), ep.ID(), ep.virtualIP, ep.virtualIPv6, ep.clusterIngressPorts(), ep.Iface().Address().IP, ep.ipv6Addr()); err != nil {
+			return err
+		}
+	} else {
+		if err := c.addServiceBinding(ep.svcName, ep.svcID, n.ID(), ep.ID(), ep.virtualIP, ep.virtualIPv6, ep.clusterIngressPorts(), ep.svcSchedName, ep.svcPersistTimeout, ep.svcPersistMaskLen, ep.svcDSR, ep.svcLBPreference, ep.svcUDPTimeout, ep.svcMaxConns, ep.svcConnRate, ep.svcMetadataRecords(), ep.Iface().Address().IP, ep.ipv6Addr(), net.ParseIP(c.agent.bindAddr), ep.svcWeight, ep.svcGroup); err != nil {
+			return err
+		}
+	}
+
+	buf, err := proto.Marshal(ep.endpointRecord())
+	if err != nil {
+		return err
+	}
+
+	return c.agent.networkDB.UpdateEntry("endpoint_table", n.ID(), ep.ID(), buf)
+}
+
 func (ep *endpoint) deleteFromCluster() error {
 	n := ep.getNetwork()
 	if !n.isClusterEligible() {
@@ -216,12 +346,7 @@ func (ep *endpoint) deleteFromCluster() error {
 	c := n.getController()
 	if !ep.isAnonymous() {
 		if ep.svcID != "" && ep.Iface().Address() != nil {
-			var ingressPorts []*PortConfig
-			if n.ingress {
-				ingressPorts = ep.ingressPorts
-			}
-
-			if err := c.rmServiceBinding(ep.svcName, ep.svcID, n.ID(), ep.ID(), ep.virtualIP, ingressPorts, ep.Iface().Address().IP); err != nil {
+			if err := c.rmServiceBinding(ep.svcName, ep.svcID, n.ID(), ep.ID(), ep.virtualIP, ep.virtualIPv6, ep.clusterIngressPorts(), ep.Iface().Address().IP, ep.ipv6Addr()); err != nil {
 				return err
 			}
 		}
@@ -328,19 +453,161 @@ func (n *network) handleDriverTableEvent(ev events.Event) {
 		tname = event.Table
 		key = event.Key
 		value = event.Value
-		etype = driverapi.Delete
+		etype = driverapi.Update
 	}
 
 	d.EventNotify(etype, n.ID(), tname, key, value)
 }
 
+// handleLoadTableEvent applies the load of a service backend gossiped
+// by a node to the local load balancers.
+func (c *controller) handleLoadTableEvent(ev events.Event) {
+	var (
+		nid      string
+		key      string
+		value    []byte
+		isDelete bool
+		hint     LoadHint
+	)
+
+	switch event := ev.(type) {
+	case networkdb.CreateEvent:
+		nid = event.NetworkID
+		key = event.Key
+		value = event.Value
+	case networkdb.UpdateEvent:
+		nid = event.NetworkID
+		key = event.Key
+		value = event.Value
+	case networkdb.DeleteEvent:
+		nid = event.NetworkID
+		key = event.Key
+		value = event.Value
+		isDelete = true
+	}
+
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		logrus.Errorf("Invalid key %q received while handling load table event", key)
+		return
+	}
+
+	if err := proto.Unmarshal(value, &hint); err != nil {
+		logrus.Errorf("Failed to unmarshal load table value: %v", err)
+		return
+	}
+
+	c.setBackendLoad(nid, hint.ServiceID, parts[0], parts[1], hint.ActiveConnections, isDelete)
+}
+
+func (c *controller) SwitchServiceTraffic(sid, active, standby string, standbyPercent uint32) error {
+	if active != "" && active == standby {
+		return types.BadRequestErrorf("active and standby groups must differ")
+	}
+	if standbyPercent > 100 {
+		return types.BadRequestErrorf("invalid standby percentage %d", standbyPercent)
+	}
+
+	c.Lock()
+	s, ok := c.serviceBindings[sid]
+	agent := c.agent
+	c.Unlock()
+	if !ok {
+		return types.NotFoundErrorf("service %s not found", sid)
+	}
+
+	var t *trafficSplit
+	if active != "" {
+		t = &trafficSplit{
+			active:         active,
+			standby:        standby,
+			standbyPercent: standbyPercent,
+		}
+	}
+	c.setServiceTraffic(sid, t)
+
+	if agent == nil {
+		return nil
+	}
+
+	buf, err := proto.Marshal(&TrafficSplit{
+		ServiceID:      sid,
+		ActiveGroup:    active,
+		StandbyGroup:   standby,
+		StandbyPercent: standbyPercent,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	nids := make([]string, 0, len(s.loadBalancers))
+	for nid := range s.loadBalancers {
+		nids = append(nids, nid)
+	}
+	s.Unlock()
+
+	for _, nid := range nids {
+		if t == nil {
+			err = agent.networkDB.DeleteEntry(trafficTable, nid, sid)
+		} else if err = agent.networkDB.CreateEntry(trafficTable, nid, sid, buf); err != nil {
+			err = agent.networkDB.UpdateEntry(trafficTable, nid, sid, buf)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to gossip the traffic split of service %s on network %s: %v", sid, nid, err)
+		}
+	}
+
+	return nil
+}
+
+// handleTrafficTableEvent applies the traffic split of a service
+// gossiped by a node to the local load balancers.
+func (c *controller) handleTrafficTableEvent(ev events.Event) {
+	var (
+		key      string
+		value    []byte
+		isDelete bool
+		split    TrafficSplit
+	)
+
+	switch event := ev.(type) {
+	case networkdb.CreateEvent:
+		key = event.Key
+		value = event.Value
+	case networkdb.UpdateEvent:
+		key = event.Key
+		value = event.Value
+	case networkdb.DeleteEvent:
+		key = event.Key
+		isDelete = true
+	}
+
+	if isDelete {
+		c.setServiceTraffic(key, nil)
+		return
+	}
+
+	if err := proto.Unmarshal(value, &split); err != nil {
+		logrus.Errorf("Failed to unmarshal traffic table value: %v", err)
+		return
+	}
+
+	c.setServiceTraffic(split.ServiceID, &trafficSplit{
+		active:         split.ActiveGroup,
+		standby:        split.StandbyGroup,
+		standbyPercent: split.StandbyPercent,
+	})
+}
+
 func (c *controller) handleEpTableEvent(ev events.Event) {
 	var (
-		nid   string
-		eid   string
-		value []byte
-		isAdd bool
-		epRec EndpointRecord
+		nid      string
+		eid      string
+		value    []byte
+		isAdd    bool
+		isUpdate bool
+		epRec    EndpointRecord
 	)
 
 	switch event := ev.(type) {
@@ -354,7 +621,10 @@ func (c *controller) handleEpTableEvent(ev events.Event) {
 		eid = event.Key
 		value = event.Value
 	case networkdb.UpdateEvent:
-		logrus.Errorf("Unexpected update service table event = %#v", event)
+		nid = event.NetworkID
+		eid = event.Key
+		value = event.Value
+		isUpdate = true
 	}
 
 	nw, err := c.NetworkByID(nid)
@@ -374,17 +644,50 @@ func (c *controller) handleEpTableEvent(ev events.Event) {
 	svcName := epRec.ServiceName
 	svcID := epRec.ServiceID
 	vip := net.ParseIP(epRec.VirtualIP)
+	vip6 := net.ParseIP(epRec.VirtualIPv6)
 	ip := net.ParseIP(epRec.EndpointIP)
+	ip6 := net.ParseIP(epRec.EndpointIPv6)
 	ingressPorts := epRec.IngressPorts
+	metadata := epRec.ServiceMetadata
+	schedName := epRec.SchedName
+	persistTimeout := epRec.PersistenceTimeout
+	persistMaskLen := epRec.PersistenceMaskLen
+	weight := epRec.Weight
+	group := epRec.ServiceGroup
+	unhealthy := epRec.Unhealthy
+	dsr := epRec.DirectServerReturn
+	lbPref := epRec.LbPreference
+	nodeAddr := net.ParseIP(epRec.NodeAddr)
+	udpTimeout := epRec.UdpTimeout
+	maxConns := epRec.MaxConnections
+	connRate := epRec.ConnectionRate
 
 	if name == "" || ip == nil {
 		logrus.Errorf("Invalid endpoint name/ip received while handling service table event %s", value)
 		return
 	}
 
+	if isUpdate {
+		// Updates only carry a change of the endpoint health. The
+		// endpoint keeps its name record either way.
+		if svcID == "" {
+			return
+		}
+
+		if unhealthy {
+			err = c.rmServiceBinding(svcName, svcID, nid, eid, vip, vip6, ingressPorts, ip, ip6)
+		} else {
+			err = c.addServiceBinding(svcName, svcID, nid, eid, vip, vip6, ingressPorts, schedName, persistTimeout, persistMaskLen, dsr, lbPref, udpTimeout, maxConns, connRate, metadata, ip, ip6, nodeAddr, weight, group)
+		}
+		if err != nil {
+			logrus.Errorf("Failed updating service binding for value %s: %v", value, err)
+		}
+		return
+	}
+
 	if isAdd {
-		if svcID != "" {
-			if err := c.addServiceBinding(svcName, svcID, nid, eid, vip, ingressPorts, ip); err != nil {
+		if svcID != "" && !unhealthy {
+			if err := c.addServiceBinding(svcName, svcID, nid, eid, vip, vip6, ingressPorts, schedName, persistTimeout, persistMaskLen, dsr, lbPref, udpTimeout, maxConns, connRate, metadata, ip, ip6, nodeAddr, weight, group); err != nil {
 				logrus.Errorf("Failed adding service binding for value %s: %v", value, err)
 				return
 			}
@@ -393,7 +696,7 @@ func (c *controller) handleEpTableEvent(ev events.Event) {
 		n.addSvcRecords(name, ip, nil, true)
 	} else {
 		if svcID != "" {
-			if err := c.rmServiceBinding(svcName, svcID, nid, eid, vip, ingressPorts, ip); err != nil {
+			if err := c.rmServiceBinding(svcName, svcID, nid, eid, vip, vip6, ingressPorts, ip, ip6); err != nil {
 				logrus.Errorf("Failed adding service binding for value %s: %v", value, err)
 				return
 			}
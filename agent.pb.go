@@ -11,6 +11,8 @@
 	It has these top-level messages:
 		EndpointRecord
 		PortConfig
+		LoadHint
+		TrafficSplit
 */
 package libnetwork
 
@@ -39,17 +41,20 @@ const _ = proto.GoGoProtoPackageIsVersion1
 type PortConfig_Protocol int32
 
 const (
-	ProtocolTCP PortConfig_Protocol = 0
-	ProtocolUDP PortConfig_Protocol = 1
+	ProtocolTCP  PortConfig_Protocol = 0
+	ProtocolUDP  PortConfig_Protocol = 1
+	ProtocolSCTP PortConfig_Protocol = 2
 )
 
 var PortConfig_Protocol_name = map[int32]string{
 	0: "TCP",
 	1: "UDP",
+	2: "SCTP",
 }
 var PortConfig_Protocol_value = map[string]int32{
-	"TCP": 0,
-	"UDP": 1,
+	"TCP":  0,
+	"UDP":  1,
+	"SCTP": 2,
 }
 
 func (x PortConfig_Protocol) String() string {
@@ -57,6 +62,27 @@ func (x PortConfig_Protocol) String() string {
 }
 func (PortConfig_Protocol) EnumDescriptor() ([]byte, []int) { return fileDescriptorAgent, []int{1, 0} }
 
+type PortConfig_PublishMode int32
+
+const (
+	PublishModeIngress PortConfig_PublishMode = 0
+	PublishModeHost    PortConfig_PublishMode = 1
+)
+
+var PortConfig_PublishMode_name = map[int32]string{
+	0: "INGRESS",
+	1: "HOST",
+}
+var PortConfig_PublishMode_value = map[string]int32{
+	"INGRESS": 0,
+	"HOST":    1,
+}
+
+func (x PortConfig_PublishMode) String() string {
+	return proto.EnumName(PortConfig_PublishMode_name, int32(x))
+}
+func (PortConfig_PublishMode) EnumDescriptor() ([]byte, []int) { return fileDescriptorAgent, []int{1, 1} }
+
 // EndpointRecord specifies all the endpoint specific information that
 // needs to gossiped to nodes participating in the network.
 type EndpointRecord struct {
@@ -72,6 +98,49 @@ type EndpointRecord struct {
 	EndpointIP string `protobuf:"bytes,5,opt,name=endpoint_ip,json=endpointIp,proto3" json:"endpoint_ip,omitempty"`
 	// IngressPorts exposed by the service to which this endpoint belongs.
 	IngressPorts []*PortConfig `protobuf:"bytes,6,rep,name=ingress_ports,json=ingressPorts" json:"ingress_ports,omitempty"`
+	// Metadata of the service to which this endpoint belongs in
+	// key=value form. It is served as DNS TXT records.
+	ServiceMetadata []string `protobuf:"bytes,7,rep,name=service_metadata,json=serviceMetadata" json:"service_metadata,omitempty"`
+	// Name of the IPVS scheduler used to balance the load across
+	// the backends of the service to which this endpoint belongs.
+	SchedName string `protobuf:"bytes,8,opt,name=sched_name,json=schedName,proto3" json:"sched_name,omitempty"`
+	// Timeout in seconds during which the connections from a client
+	// stick to the same backend. Persistence is disabled if zero.
+	PersistenceTimeout uint32 `protobuf:"varint,9,opt,name=persistence_timeout,json=persistenceTimeout,proto3" json:"persistence_timeout,omitempty"`
+	// Prefix length of the client addresses sharing the same backend
+	// when persistence is enabled.
+	PersistenceMaskLen uint32 `protobuf:"varint,10,opt,name=persistence_mask_len,json=persistenceMaskLen,proto3" json:"persistence_mask_len,omitempty"`
+	// Relative weight of this endpoint among the backends of the
+	// service. Zero stands for the default weight of one.
+	Weight uint32 `protobuf:"varint,11,opt,name=weight,proto3" json:"weight,omitempty"`
+	// Unhealthy endpoints are kept out of the service load
+	// balancers and of the DNS RR answers of the service.
+	Unhealthy bool `protobuf:"varint,12,opt,name=unhealthy,proto3" json:"unhealthy,omitempty"`
+	// Ingress traffic of the service is forwarded to the backends
+	// with IPVS direct routing so that the replies bypass the
+	// ingress node.
+	DirectServerReturn bool `protobuf:"varint,13,opt,name=direct_server_return,json=directServerReturn,proto3" json:"direct_server_return,omitempty"`
+	// Locality of the backends preferred by the load balancers of
+	// the service to which this endpoint belongs.
+	LbPreference string `protobuf:"bytes,14,opt,name=lb_preference,json=lbPreference,proto3" json:"lb_preference,omitempty"`
+	// Address of the node running this endpoint.
+	NodeAddr string `protobuf:"bytes,15,opt,name=node_addr,json=nodeAddr,proto3" json:"node_addr,omitempty"`
+	// Timeout in seconds of the idle UDP flows to the service to
+	// which this endpoint belongs. Zero keeps the default timeout.
+	UdpTimeout uint32 `protobuf:"varint,16,opt,name=udp_timeout,json=udpTimeout,proto3" json:"udp_timeout,omitempty"`
+	// IPv6 virtual IP of the service to which this endpoint belongs.
+	VirtualIPv6 string `protobuf:"bytes,17,opt,name=virtual_ipv6,json=virtualIpv6,proto3" json:"virtual_ipv6,omitempty"`
+	// IPv6 address assigned to this endpoint.
+	EndpointIPv6 string `protobuf:"bytes,18,opt,name=endpoint_ipv6,json=endpointIpv6,proto3" json:"endpoint_ipv6,omitempty"`
+	// Maximum number of concurrent connections each backend of the
+	// service accepts from a load balancer. Zero disables the limit.
+	MaxConnections uint32 `protobuf:"varint,19,opt,name=max_connections,json=maxConnections,proto3" json:"max_connections,omitempty"`
+	// Maximum rate, in new connections per second, of the ingress
+	// connections to the service on a node. Zero disables the limit.
+	ConnectionRate uint32 `protobuf:"varint,20,opt,name=connection_rate,json=connectionRate,proto3" json:"connection_rate,omitempty"`
+	// Deployment group, e.g. blue or green, of this endpoint among
+	// the backends of its service.
+	ServiceGroup string `protobuf:"bytes,21,opt,name=service_group,json=serviceGroup,proto3" json:"service_group,omitempty"`
 }
 
 func (m *EndpointRecord) Reset()                    { *m = EndpointRecord{} }
@@ -105,22 +174,73 @@ type PortConfig struct {
 	// system. If specified it should be within the node port
 	// range and it should be available.
 	NodePort uint32 `protobuf:"varint,4,opt,name=node_port,json=nodePort,proto3" json:"node_port,omitempty"`
+	// Version of the PROXY protocol header prepended to the
+	// connections forwarded from the node port to the service
+	// backends. Zero disables the header.
+	ProxyProtocol uint32 `protobuf:"varint,5,opt,name=proxy_protocol,json=proxyProtocol,proto3" json:"proxy_protocol,omitempty"`
+	// PublishMode specifies how the node port is published. Ingress
+	// ports are reachable on every node of the cluster, host ports only
+	// on the nodes running a backend of the service.
+	PublishMode PortConfig_PublishMode `protobuf:"varint,6,opt,name=publish_mode,json=publishMode,proto3,enum=libnetwork.PortConfig_PublishMode" json:"publish_mode,omitempty"`
+	// Number of contiguous ports, starting at port and node_port,
+	// published as a unit. Zero stands for a single port.
+	PortCount uint32 `protobuf:"varint,7,opt,name=port_count,json=portCount,proto3" json:"port_count,omitempty"`
+	// Source networks, in CIDR notation, of the clients allowed to
+	// connect to the node port. All the clients are allowed if empty.
+	AllowedSources []string `protobuf:"bytes,8,rep,name=allowed_sources,json=allowedSources" json:"allowed_sources,omitempty"`
 }
 
 func (m *PortConfig) Reset()                    { *m = PortConfig{} }
 func (*PortConfig) ProtoMessage()               {}
 func (*PortConfig) Descriptor() ([]byte, []int) { return fileDescriptorAgent, []int{1} }
 
+// LoadHint carries the number of connections a node has active to a
+// service backend. It is gossiped so that every node can weight the
+// backends by their load across the cluster.
+type LoadHint struct {
+	// Service ID of the service to which the backend belongs.
+	ServiceID string `protobuf:"bytes,1,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
+	// IP of the backend endpoint.
+	EndpointIP string `protobuf:"bytes,2,opt,name=endpoint_ip,json=endpointIp,proto3" json:"endpoint_ip,omitempty"`
+	// Number of active connections from the node to the backend.
+	ActiveConnections uint32 `protobuf:"varint,3,opt,name=active_connections,json=activeConnections,proto3" json:"active_connections,omitempty"`
+}
+
+func (m *LoadHint) Reset()                    { *m = LoadHint{} }
+func (*LoadHint) ProtoMessage()               {}
+func (*LoadHint) Descriptor() ([]byte, []int) { return fileDescriptorAgent, []int{2} }
+
+// TrafficSplit carries the share of the traffic of a service sent to
+// each of its two deployment groups. It is gossiped so that all the
+// nodes switch the traffic of the service consistently.
+type TrafficSplit struct {
+	// Service ID of the service whose traffic is split.
+	ServiceID string `protobuf:"bytes,1,opt,name=service_id,json=serviceId,proto3" json:"service_id,omitempty"`
+	// Group receiving the traffic not sent to the standby group.
+	ActiveGroup string `protobuf:"bytes,2,opt,name=active_group,json=activeGroup,proto3" json:"active_group,omitempty"`
+	// Group receiving standby_percent percent of the traffic.
+	StandbyGroup string `protobuf:"bytes,3,opt,name=standby_group,json=standbyGroup,proto3" json:"standby_group,omitempty"`
+	// Percentage of the traffic sent to the standby group.
+	StandbyPercent uint32 `protobuf:"varint,4,opt,name=standby_percent,json=standbyPercent,proto3" json:"standby_percent,omitempty"`
+}
+
+func (m *TrafficSplit) Reset()                    { *m = TrafficSplit{} }
+func (*TrafficSplit) ProtoMessage()               {}
+func (*TrafficSplit) Descriptor() ([]byte, []int) { return fileDescriptorAgent, []int{3} }
+
 func init() {
 	proto.RegisterType((*EndpointRecord)(nil), "libnetwork.EndpointRecord")
 	proto.RegisterType((*PortConfig)(nil), "libnetwork.PortConfig")
+	proto.RegisterType((*LoadHint)(nil), "libnetwork.LoadHint")
+	proto.RegisterType((*TrafficSplit)(nil), "libnetwork.TrafficSplit")
 	proto.RegisterEnum("libnetwork.PortConfig_Protocol", PortConfig_Protocol_name, PortConfig_Protocol_value)
+	proto.RegisterEnum("libnetwork.PortConfig_PublishMode", PortConfig_PublishMode_name, PortConfig_PublishMode_value)
 }
 func (this *EndpointRecord) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 10)
+	s := make([]string, 0, 25)
 	s = append(s, "&libnetwork.EndpointRecord{")
 	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
 	s = append(s, "ServiceName: "+fmt.Sprintf("%#v", this.ServiceName)+",\n")
@@ -130,6 +250,21 @@ func (this *EndpointRecord) GoString() string {
 	if this.IngressPorts != nil {
 		s = append(s, "IngressPorts: "+fmt.Sprintf("%#v", this.IngressPorts)+",\n")
 	}
+	s = append(s, "ServiceMetadata: "+fmt.Sprintf("%#v", this.ServiceMetadata)+",\n")
+	s = append(s, "SchedName: "+fmt.Sprintf("%#v", this.SchedName)+",\n")
+	s = append(s, "PersistenceTimeout: "+fmt.Sprintf("%#v", this.PersistenceTimeout)+",\n")
+	s = append(s, "PersistenceMaskLen: "+fmt.Sprintf("%#v", this.PersistenceMaskLen)+",\n")
+	s = append(s, "Weight: "+fmt.Sprintf("%#v", this.Weight)+",\n")
+	s = append(s, "Unhealthy: "+fmt.Sprintf("%#v", this.Unhealthy)+",\n")
+	s = append(s, "DirectServerReturn: "+fmt.Sprintf("%#v", this.DirectServerReturn)+",\n")
+	s = append(s, "LbPreference: "+fmt.Sprintf("%#v", this.LbPreference)+",\n")
+	s = append(s, "NodeAddr: "+fmt.Sprintf("%#v", this.NodeAddr)+",\n")
+	s = append(s, "UdpTimeout: "+fmt.Sprintf("%#v", this.UdpTimeout)+",\n")
+	s = append(s, "VirtualIPv6: "+fmt.Sprintf("%#v", this.VirtualIPv6)+",\n")
+	s = append(s, "EndpointIPv6: "+fmt.Sprintf("%#v", this.EndpointIPv6)+",\n")
+	s = append(s, "MaxConnections: "+fmt.Sprintf("%#v", this.MaxConnections)+",\n")
+	s = append(s, "ConnectionRate: "+fmt.Sprintf("%#v", this.ConnectionRate)+",\n")
+	s = append(s, "ServiceGroup: "+fmt.Sprintf("%#v", this.ServiceGroup)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -137,12 +272,41 @@ func (this *PortConfig) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 11)
 	s = append(s, "&libnetwork.PortConfig{")
 	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
 	s = append(s, "Protocol: "+fmt.Sprintf("%#v", this.Protocol)+",\n")
 	s = append(s, "Port: "+fmt.Sprintf("%#v", this.Port)+",\n")
 	s = append(s, "NodePort: "+fmt.Sprintf("%#v", this.NodePort)+",\n")
+	s = append(s, "ProxyProtocol: "+fmt.Sprintf("%#v", this.ProxyProtocol)+",\n")
+	s = append(s, "PublishMode: "+fmt.Sprintf("%#v", this.PublishMode)+",\n")
+	s = append(s, "PortCount: "+fmt.Sprintf("%#v", this.PortCount)+",\n")
+	s = append(s, "AllowedSources: "+fmt.Sprintf("%#v", this.AllowedSources)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LoadHint) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&libnetwork.LoadHint{")
+	s = append(s, "ServiceID: "+fmt.Sprintf("%#v", this.ServiceID)+",\n")
+	s = append(s, "EndpointIP: "+fmt.Sprintf("%#v", this.EndpointIP)+",\n")
+	s = append(s, "ActiveConnections: "+fmt.Sprintf("%#v", this.ActiveConnections)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *TrafficSplit) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&libnetwork.TrafficSplit{")
+	s = append(s, "ServiceID: "+fmt.Sprintf("%#v", this.ServiceID)+",\n")
+	s = append(s, "ActiveGroup: "+fmt.Sprintf("%#v", this.ActiveGroup)+",\n")
+	s = append(s, "StandbyGroup: "+fmt.Sprintf("%#v", this.StandbyGroup)+",\n")
+	s = append(s, "StandbyPercent: "+fmt.Sprintf("%#v", this.StandbyPercent)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -228,6 +392,119 @@ func (m *EndpointRecord) MarshalTo(data []byte) (int, error) {
 			i += n
 		}
 	}
+	if len(m.ServiceMetadata) > 0 {
+		for _, s := range m.ServiceMetadata {
+			data[i] = 0x3a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				data[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			data[i] = uint8(l)
+			i++
+			i += copy(data[i:], s)
+		}
+	}
+	if len(m.SchedName) > 0 {
+		data[i] = 0x42
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.SchedName)))
+		i += copy(data[i:], m.SchedName)
+	}
+	if m.PersistenceTimeout != 0 {
+		data[i] = 0x48
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.PersistenceTimeout))
+	}
+	if m.PersistenceMaskLen != 0 {
+		data[i] = 0x50
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.PersistenceMaskLen))
+	}
+	if m.Weight != 0 {
+		data[i] = 0x58
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.Weight))
+	}
+	if m.Unhealthy {
+		data[i] = 0x60
+		i++
+		if m.Unhealthy {
+			data[i] = 1
+		} else {
+			data[i] = 0
+		}
+		i++
+	}
+	if m.DirectServerReturn {
+		data[i] = 0x68
+		i++
+		if m.DirectServerReturn {
+			data[i] = 1
+		} else {
+			data[i] = 0
+		}
+		i++
+	}
+	if len(m.LbPreference) > 0 {
+		data[i] = 0x72
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.LbPreference)))
+		i += copy(data[i:], m.LbPreference)
+	}
+	if len(m.NodeAddr) > 0 {
+		data[i] = 0x7a
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.NodeAddr)))
+		i += copy(data[i:], m.NodeAddr)
+	}
+	if m.UdpTimeout != 0 {
+		data[i] = 0x80
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.UdpTimeout))
+	}
+	if len(m.VirtualIPv6) > 0 {
+		data[i] = 0x8a
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.VirtualIPv6)))
+		i += copy(data[i:], m.VirtualIPv6)
+	}
+	if len(m.EndpointIPv6) > 0 {
+		data[i] = 0x92
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.EndpointIPv6)))
+		i += copy(data[i:], m.EndpointIPv6)
+	}
+	if m.MaxConnections != 0 {
+		data[i] = 0x98
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.MaxConnections))
+	}
+	if m.ConnectionRate != 0 {
+		data[i] = 0xa0
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.ConnectionRate))
+	}
+	if len(m.ServiceGroup) > 0 {
+		data[i] = 0xaa
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.ServiceGroup)))
+		i += copy(data[i:], m.ServiceGroup)
+	}
 	return i, nil
 }
 
@@ -267,6 +544,112 @@ func (m *PortConfig) MarshalTo(data []byte) (int, error) {
 		i++
 		i = encodeVarintAgent(data, i, uint64(m.NodePort))
 	}
+	if m.ProxyProtocol != 0 {
+		data[i] = 0x28
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.ProxyProtocol))
+	}
+	if m.PublishMode != 0 {
+		data[i] = 0x30
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.PublishMode))
+	}
+	if m.PortCount != 0 {
+		data[i] = 0x38
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.PortCount))
+	}
+	if len(m.AllowedSources) > 0 {
+		for _, s := range m.AllowedSources {
+			data[i] = 0x42
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				data[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			data[i] = uint8(l)
+			i++
+			i += copy(data[i:], s)
+		}
+	}
+	return i, nil
+}
+
+func (m *LoadHint) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *LoadHint) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.ServiceID) > 0 {
+		data[i] = 0xa
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.ServiceID)))
+		i += copy(data[i:], m.ServiceID)
+	}
+	if len(m.EndpointIP) > 0 {
+		data[i] = 0x12
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.EndpointIP)))
+		i += copy(data[i:], m.EndpointIP)
+	}
+	if m.ActiveConnections != 0 {
+		data[i] = 0x18
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.ActiveConnections))
+	}
+	return i, nil
+}
+
+func (m *TrafficSplit) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *TrafficSplit) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.ServiceID) > 0 {
+		data[i] = 0xa
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.ServiceID)))
+		i += copy(data[i:], m.ServiceID)
+	}
+	if len(m.ActiveGroup) > 0 {
+		data[i] = 0x12
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.ActiveGroup)))
+		i += copy(data[i:], m.ActiveGroup)
+	}
+	if len(m.StandbyGroup) > 0 {
+		data[i] = 0x1a
+		i++
+		i = encodeVarintAgent(data, i, uint64(len(m.StandbyGroup)))
+		i += copy(data[i:], m.StandbyGroup)
+	}
+	if m.StandbyPercent != 0 {
+		data[i] = 0x20
+		i++
+		i = encodeVarintAgent(data, i, uint64(m.StandbyPercent))
+	}
 	return i, nil
 }
 
@@ -326,6 +709,60 @@ func (m *EndpointRecord) Size() (n int) {
 			n += 1 + l + sovAgent(uint64(l))
 		}
 	}
+	if len(m.ServiceMetadata) > 0 {
+		for _, s := range m.ServiceMetadata {
+			l = len(s)
+			n += 1 + l + sovAgent(uint64(l))
+		}
+	}
+	l = len(m.SchedName)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	if m.PersistenceTimeout != 0 {
+		n += 1 + sovAgent(uint64(m.PersistenceTimeout))
+	}
+	if m.PersistenceMaskLen != 0 {
+		n += 1 + sovAgent(uint64(m.PersistenceMaskLen))
+	}
+	if m.Weight != 0 {
+		n += 1 + sovAgent(uint64(m.Weight))
+	}
+	if m.Unhealthy {
+		n += 2
+	}
+	if m.DirectServerReturn {
+		n += 2
+	}
+	l = len(m.LbPreference)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	l = len(m.NodeAddr)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	if m.UdpTimeout != 0 {
+		n += 2 + sovAgent(uint64(m.UdpTimeout))
+	}
+	l = len(m.VirtualIPv6)
+	if l > 0 {
+		n += 2 + l + sovAgent(uint64(l))
+	}
+	l = len(m.EndpointIPv6)
+	if l > 0 {
+		n += 2 + l + sovAgent(uint64(l))
+	}
+	if m.MaxConnections != 0 {
+		n += 2 + sovAgent(uint64(m.MaxConnections))
+	}
+	if m.ConnectionRate != 0 {
+		n += 2 + sovAgent(uint64(m.ConnectionRate))
+	}
+	l = len(m.ServiceGroup)
+	if l > 0 {
+		n += 2 + l + sovAgent(uint64(l))
+	}
 	return n
 }
 
@@ -345,6 +782,59 @@ func (m *PortConfig) Size() (n int) {
 	if m.NodePort != 0 {
 		n += 1 + sovAgent(uint64(m.NodePort))
 	}
+	if m.ProxyProtocol != 0 {
+		n += 1 + sovAgent(uint64(m.ProxyProtocol))
+	}
+	if m.PublishMode != 0 {
+		n += 1 + sovAgent(uint64(m.PublishMode))
+	}
+	if m.PortCount != 0 {
+		n += 1 + sovAgent(uint64(m.PortCount))
+	}
+	if len(m.AllowedSources) > 0 {
+		for _, s := range m.AllowedSources {
+			l = len(s)
+			n += 1 + l + sovAgent(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *LoadHint) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.ServiceID)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	l = len(m.EndpointIP)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	if m.ActiveConnections != 0 {
+		n += 1 + sovAgent(uint64(m.ActiveConnections))
+	}
+	return n
+}
+
+func (m *TrafficSplit) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.ServiceID)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	l = len(m.ActiveGroup)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	l = len(m.StandbyGroup)
+	if l > 0 {
+		n += 1 + l + sovAgent(uint64(l))
+	}
+	if m.StandbyPercent != 0 {
+		n += 1 + sovAgent(uint64(m.StandbyPercent))
+	}
 	return n
 }
 
@@ -372,6 +862,21 @@ func (this *EndpointRecord) String() string {
 		`VirtualIP:` + fmt.Sprintf("%v", this.VirtualIP) + `,`,
 		`EndpointIP:` + fmt.Sprintf("%v", this.EndpointIP) + `,`,
 		`IngressPorts:` + strings.Replace(fmt.Sprintf("%v", this.IngressPorts), "PortConfig", "PortConfig", 1) + `,`,
+		`ServiceMetadata:` + fmt.Sprintf("%v", this.ServiceMetadata) + `,`,
+		`SchedName:` + fmt.Sprintf("%v", this.SchedName) + `,`,
+		`PersistenceTimeout:` + fmt.Sprintf("%v", this.PersistenceTimeout) + `,`,
+		`PersistenceMaskLen:` + fmt.Sprintf("%v", this.PersistenceMaskLen) + `,`,
+		`Weight:` + fmt.Sprintf("%v", this.Weight) + `,`,
+		`Unhealthy:` + fmt.Sprintf("%v", this.Unhealthy) + `,`,
+		`DirectServerReturn:` + fmt.Sprintf("%v", this.DirectServerReturn) + `,`,
+		`LbPreference:` + fmt.Sprintf("%v", this.LbPreference) + `,`,
+		`NodeAddr:` + fmt.Sprintf("%v", this.NodeAddr) + `,`,
+		`UdpTimeout:` + fmt.Sprintf("%v", this.UdpTimeout) + `,`,
+		`VirtualIPv6:` + fmt.Sprintf("%v", this.VirtualIPv6) + `,`,
+		`EndpointIPv6:` + fmt.Sprintf("%v", this.EndpointIPv6) + `,`,
+		`MaxConnections:` + fmt.Sprintf("%v", this.MaxConnections) + `,`,
+		`ConnectionRate:` + fmt.Sprintf("%v", this.ConnectionRate) + `,`,
+		`ServiceGroup:` + fmt.Sprintf("%v", this.ServiceGroup) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -385,6 +890,35 @@ func (this *PortConfig) String() string {
 		`Protocol:` + fmt.Sprintf("%v", this.Protocol) + `,`,
 		`Port:` + fmt.Sprintf("%v", this.Port) + `,`,
 		`NodePort:` + fmt.Sprintf("%v", this.NodePort) + `,`,
+		`ProxyProtocol:` + fmt.Sprintf("%v", this.ProxyProtocol) + `,`,
+		`PublishMode:` + fmt.Sprintf("%v", this.PublishMode) + `,`,
+		`PortCount:` + fmt.Sprintf("%v", this.PortCount) + `,`,
+		`AllowedSources:` + fmt.Sprintf("%v", this.AllowedSources) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LoadHint) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&LoadHint{`,
+		`ServiceID:` + fmt.Sprintf("%v", this.ServiceID) + `,`,
+		`EndpointIP:` + fmt.Sprintf("%v", this.EndpointIP) + `,`,
+		`ActiveConnections:` + fmt.Sprintf("%v", this.ActiveConnections) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *TrafficSplit) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&TrafficSplit{`,
+		`ServiceID:` + fmt.Sprintf("%v", this.ServiceID) + `,`,
+		`ActiveGroup:` + fmt.Sprintf("%v", this.ActiveGroup) + `,`,
+		`StandbyGroup:` + fmt.Sprintf("%v", this.StandbyGroup) + `,`,
+		`StandbyPercent:` + fmt.Sprintf("%v", this.StandbyPercent) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -602,20 +1136,599 @@ func (m *EndpointRecord) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipAgent(data[iNdEx:])
-			if err != nil {
-				return err
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceMetadata", wireType)
 			}
-			if skippy < 0 {
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthAgent
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
+			m.ServiceMetadata = append(m.ServiceMetadata, string(data[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SchedName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SchedName = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PersistenceTimeout", wireType)
+			}
+			m.PersistenceTimeout = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.PersistenceTimeout |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PersistenceMaskLen", wireType)
+			}
+			m.PersistenceMaskLen = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.PersistenceMaskLen |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Weight", wireType)
+			}
+			m.Weight = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Weight |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Unhealthy", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Unhealthy = bool(v != 0)
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DirectServerReturn", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.DirectServerReturn = bool(v != 0)
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LbPreference", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LbPreference = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NodeAddr", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NodeAddr = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UdpTimeout", wireType)
+			}
+			m.UdpTimeout = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.UdpTimeout |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VirtualIPv6", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.VirtualIPv6 = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 18:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndpointIPv6", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EndpointIPv6 = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 19:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxConnections", wireType)
+			}
+			m.MaxConnections = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.MaxConnections |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 20:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ConnectionRate", wireType)
+			}
+			m.ConnectionRate = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.ConnectionRate |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 21:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceGroup", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ServiceGroup = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAgent(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthAgent
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PortConfig) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAgent
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PortConfig: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PortConfig: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Protocol", wireType)
+			}
+			m.Protocol = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Protocol |= (PortConfig_Protocol(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Port", wireType)
+			}
+			m.Port = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Port |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NodePort", wireType)
+			}
+			m.NodePort = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.NodePort |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProxyProtocol", wireType)
+			}
+			m.ProxyProtocol = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.ProxyProtocol |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublishMode", wireType)
+			}
+			m.PublishMode = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.PublishMode |= (PortConfig_PublishMode(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PortCount", wireType)
+			}
+			m.PortCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.PortCount |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AllowedSources", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AllowedSources = append(m.AllowedSources, string(data[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAgent(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthAgent
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
 	}
 
 	if iNdEx > l {
@@ -623,7 +1736,7 @@ func (m *EndpointRecord) Unmarshal(data []byte) error {
 	}
 	return nil
 }
-func (m *PortConfig) Unmarshal(data []byte) error {
+func (m *LoadHint) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -646,15 +1759,15 @@ func (m *PortConfig) Unmarshal(data []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: PortConfig: wiretype end group for non-group")
+			return fmt.Errorf("proto: LoadHint: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PortConfig: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: LoadHint: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceID", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -679,13 +1792,13 @@ func (m *PortConfig) Unmarshal(data []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Name = string(data[iNdEx:postIndex])
+			m.ServiceID = string(data[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Protocol", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndpointIP", wireType)
 			}
-			m.Protocol = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowAgent
@@ -695,16 +1808,26 @@ func (m *PortConfig) Unmarshal(data []byte) error {
 				}
 				b := data[iNdEx]
 				iNdEx++
-				m.Protocol |= (PortConfig_Protocol(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EndpointIP = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 3:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Port", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ActiveConnections", wireType)
 			}
-			m.Port = 0
+			m.ActiveConnections = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowAgent
@@ -714,16 +1837,153 @@ func (m *PortConfig) Unmarshal(data []byte) error {
 				}
 				b := data[iNdEx]
 				iNdEx++
-				m.Port |= (uint32(b) & 0x7F) << shift
+				m.ActiveConnections |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAgent(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthAgent
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TrafficSplit) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAgent
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TrafficSplit: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TrafficSplit: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ServiceID = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActiveGroup", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ActiveGroup = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StandbyGroup", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAgent
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAgent
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.StandbyGroup = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 4:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field NodePort", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field StandbyPercent", wireType)
 			}
-			m.NodePort = 0
+			m.StandbyPercent = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowAgent
@@ -733,7 +1993,7 @@ func (m *PortConfig) Unmarshal(data []byte) error {
 				}
 				b := data[iNdEx]
 				iNdEx++
-				m.NodePort |= (uint32(b) & 0x7F) << shift
+				m.StandbyPercent |= (uint32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -865,29 +2125,62 @@ var (
 )
 
 var fileDescriptorAgent = []byte{
-	// 370 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x6c, 0x90, 0xbf, 0x4e, 0x32, 0x41,
-	0x14, 0xc5, 0x59, 0xe0, 0x23, 0xec, 0x5d, 0x96, 0x8f, 0x4c, 0x8c, 0xd9, 0x60, 0xb2, 0x20, 0x15,
-	0x85, 0x59, 0x12, 0x2c, 0xe9, 0x00, 0x8b, 0x6d, 0xcc, 0x66, 0xfc, 0xd3, 0x12, 0x60, 0xc7, 0xcd,
-	0x44, 0x9c, 0xd9, 0xcc, 0xae, 0xd8, 0x5a, 0x1a, 0x3b, 0x1f, 0xc0, 0xca, 0x97, 0xb1, 0xb4, 0xb0,
-	0xb0, 0x32, 0xc2, 0x13, 0xf8, 0x08, 0xce, 0x0c, 0xbb, 0x12, 0x13, 0x8a, 0x9b, 0xdc, 0x9c, 0xf3,
-	0xbb, 0x37, 0x27, 0x07, 0xac, 0x69, 0x44, 0x58, 0xea, 0xc5, 0x82, 0xa7, 0x1c, 0xc1, 0x82, 0xce,
-	0x18, 0x49, 0xef, 0xb8, 0xb8, 0x6e, 0xee, 0x45, 0x3c, 0xe2, 0x5a, 0xee, 0xa9, 0x6d, 0x43, 0x74,
-	0x9e, 0x8a, 0x50, 0x3f, 0x61, 0x61, 0xcc, 0x29, 0x4b, 0x31, 0x99, 0x73, 0x11, 0x22, 0x04, 0x65,
-	0x36, 0xbd, 0x21, 0x8e, 0xd1, 0x36, 0xba, 0x26, 0xd6, 0x3b, 0x3a, 0x84, 0x5a, 0x42, 0xc4, 0x92,
-	0xce, 0xc9, 0x44, 0x7b, 0x45, 0xed, 0x59, 0x99, 0x76, 0xaa, 0x90, 0x23, 0x80, 0x1c, 0xa1, 0xa1,
-	0x53, 0x52, 0xc0, 0xd0, 0x5e, 0x7f, 0xb6, 0xcc, 0xb3, 0x8d, 0xea, 0x8f, 0xb1, 0x99, 0x01, 0x7e,
-	0xa8, 0xe8, 0x25, 0x15, 0xe9, 0xed, 0x74, 0x31, 0xa1, 0xb1, 0x53, 0xde, 0xd2, 0x97, 0x1b, 0xd5,
-	0x0f, 0xb0, 0x99, 0x01, 0x7e, 0x8c, 0x7a, 0x60, 0x91, 0x2c, 0xa4, 0xc2, 0xff, 0x69, 0xbc, 0x2e,
-	0x71, 0xc8, 0xb3, 0x4b, 0x1e, 0x72, 0x44, 0x1e, 0x0c, 0xc0, 0xa6, 0x2c, 0x12, 0x24, 0x49, 0x26,
-	0x31, 0x17, 0x69, 0xe2, 0x54, 0xda, 0xa5, 0xae, 0xd5, 0xdf, 0xf7, 0xb6, 0x85, 0x78, 0x81, 0x34,
-	0x46, 0x9c, 0x5d, 0xd1, 0x08, 0xd7, 0x32, 0x58, 0x49, 0x49, 0xe7, 0xdd, 0x00, 0xd8, 0x9a, 0x3b,
-	0xfb, 0x18, 0x40, 0x55, 0xf7, 0x37, 0xe7, 0x0b, 0xdd, 0x45, 0xbd, 0xdf, 0xda, 0xfd, 0xda, 0x0b,
-	0x32, 0x0c, 0xff, 0x1e, 0xa8, 0x87, 0x2a, 0x94, 0xee, 0xc8, 0xc6, 0x7a, 0x47, 0x07, 0x60, 0x32,
-	0x1e, 0x12, 0x9d, 0x56, 0xd7, 0x61, 0xe3, 0xaa, 0x12, 0xd4, 0xa7, 0xce, 0x18, 0xaa, 0xf9, 0x1b,
-	0xe4, 0x40, 0xe9, 0x7c, 0x14, 0x34, 0x0a, 0xcd, 0xff, 0x8f, 0xcf, 0x6d, 0x2b, 0x97, 0xa5, 0xa4,
-	0x9c, 0x8b, 0x71, 0xd0, 0x30, 0xfe, 0x3a, 0x52, 0x6a, 0x96, 0x1f, 0x5e, 0xdc, 0xc2, 0xd0, 0xf9,
-	0x58, 0xb9, 0x85, 0xef, 0x95, 0x6b, 0xdc, 0xaf, 0x5d, 0xe3, 0x55, 0xce, 0x9b, 0x9c, 0x2f, 0x39,
-	0xb3, 0x8a, 0x8e, 0x76, 0xfc, 0x13, 0x00, 0x00, 0xff, 0xff, 0xca, 0xbb, 0xca, 0xdf, 0x3c, 0x02,
-	0x00, 0x00,
+	// 902 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x94, 0x4f, 0x6f, 0xdb, 0x36,
+	0x18, 0xc6, 0xa3, 0x38, 0x4d, 0xac, 0x57, 0xf2, 0x9f, 0xb2, 0x59, 0x41, 0x64, 0x9b, 0xed, 0x7a,
+	0x1b, 0x9a, 0x01, 0x5b, 0x32, 0x64, 0x98, 0x2f, 0x3d, 0xad, 0x4e, 0xd0, 0x1a, 0x68, 0x32, 0x41,
+	0x76, 0x77, 0x15, 0x68, 0x91, 0xb1, 0x85, 0xc8, 0xa4, 0x40, 0x51, 0x4e, 0x72, 0xdb, 0x71, 0xd8,
+	0x27, 0xd8, 0x65, 0xa7, 0x9d, 0xf7, 0x05, 0xf6, 0x09, 0x76, 0xdc, 0x71, 0xa7, 0x60, 0xf5, 0x75,
+	0x97, 0x7d, 0x84, 0x81, 0x94, 0x64, 0xb9, 0x5d, 0x87, 0x62, 0x37, 0xea, 0x79, 0x7e, 0x14, 0x5f,
+	0xbe, 0x7c, 0x48, 0x70, 0xc8, 0x8c, 0x71, 0x75, 0x94, 0x48, 0xa1, 0x04, 0x82, 0x38, 0x9a, 0x72,
+	0xa6, 0xae, 0x85, 0xbc, 0x3a, 0xd8, 0x9f, 0x89, 0x99, 0x30, 0xf2, 0xb1, 0x1e, 0xe5, 0x44, 0xff,
+	0xaf, 0x5d, 0x68, 0x9e, 0x71, 0x9a, 0x88, 0x88, 0x2b, 0x9f, 0x85, 0x42, 0x52, 0x84, 0x60, 0x87,
+	0x93, 0x05, 0xc3, 0x56, 0xcf, 0x3a, 0xb4, 0x7d, 0x33, 0x46, 0x8f, 0xc0, 0x4d, 0x99, 0x5c, 0x46,
+	0x21, 0x0b, 0x8c, 0xb7, 0x6d, 0x3c, 0xa7, 0xd0, 0x2e, 0x34, 0xf2, 0x19, 0x40, 0x89, 0x44, 0x14,
+	0xd7, 0x34, 0xf0, 0xb4, 0xb1, 0xba, 0xeb, 0xda, 0xe3, 0x5c, 0x1d, 0x9d, 0xfa, 0x76, 0x01, 0x8c,
+	0xa8, 0xa6, 0x97, 0x91, 0x54, 0x19, 0x89, 0x83, 0x28, 0xc1, 0x3b, 0x15, 0xfd, 0x6d, 0xae, 0x8e,
+	0x3c, 0xdf, 0x2e, 0x80, 0x51, 0x82, 0x8e, 0xc1, 0x61, 0x45, 0x91, 0x1a, 0xbf, 0x67, 0xf0, 0xe6,
+	0xea, 0xae, 0x0b, 0x65, 0xed, 0x23, 0xcf, 0x87, 0x12, 0x19, 0x25, 0xe8, 0x09, 0x34, 0x22, 0x3e,
+	0x93, 0x2c, 0x4d, 0x83, 0x44, 0x48, 0x95, 0xe2, 0xdd, 0x5e, 0xed, 0xd0, 0x39, 0x79, 0x78, 0x54,
+	0x35, 0xe4, 0xc8, 0x13, 0x52, 0x0d, 0x05, 0xbf, 0x8c, 0x66, 0xbe, 0x5b, 0xc0, 0x5a, 0x4a, 0xd1,
+	0xa7, 0xd0, 0x2e, 0x77, 0xb2, 0x60, 0x8a, 0x50, 0xa2, 0x08, 0xde, 0xeb, 0xd5, 0x0e, 0x6d, 0xbf,
+	0x55, 0xe8, 0xe7, 0x85, 0x8c, 0x3e, 0x04, 0x48, 0xc3, 0x39, 0xa3, 0x79, 0x57, 0xea, 0xa6, 0x2b,
+	0xb6, 0x51, 0x4c, 0x4f, 0x8e, 0xe1, 0x41, 0xc2, 0x64, 0x1a, 0xa5, 0x8a, 0xf1, 0x90, 0x05, 0x2a,
+	0x5a, 0x30, 0x91, 0x29, 0x6c, 0xf7, 0xac, 0xc3, 0x86, 0x8f, 0x36, 0xac, 0x49, 0xee, 0xa0, 0x2f,
+	0x60, 0x7f, 0x73, 0xc2, 0x82, 0xa4, 0x57, 0x41, 0xcc, 0x38, 0x86, 0x7f, 0xcd, 0x38, 0x27, 0xe9,
+	0xd5, 0x0b, 0xc6, 0xd1, 0x43, 0xd8, 0xbd, 0x66, 0xd1, 0x6c, 0xae, 0xb0, 0x63, 0x98, 0xe2, 0x0b,
+	0x7d, 0x00, 0x76, 0xc6, 0xe7, 0x8c, 0xc4, 0x6a, 0x7e, 0x8b, 0xdd, 0x9e, 0x75, 0x58, 0xf7, 0x2b,
+	0x41, 0xaf, 0x43, 0x23, 0xc9, 0x42, 0x15, 0xe8, 0x1d, 0x31, 0x19, 0x48, 0xa6, 0x32, 0xc9, 0x71,
+	0xc3, 0x80, 0x28, 0xf7, 0xc6, 0xc6, 0xf2, 0x8d, 0x83, 0x3e, 0x82, 0x46, 0x3c, 0x0d, 0x12, 0xc9,
+	0x2e, 0x99, 0xd4, 0xeb, 0xe3, 0xa6, 0xd9, 0xac, 0x1b, 0x4f, 0xbd, 0xb5, 0x86, 0xde, 0x07, 0x9b,
+	0x0b, 0xca, 0x02, 0x42, 0xa9, 0xc4, 0x2d, 0x03, 0xd4, 0xb5, 0xf0, 0x35, 0xa5, 0x12, 0x75, 0xc1,
+	0xc9, 0x68, 0xb2, 0x6e, 0x42, 0xdb, 0x94, 0x0b, 0x19, 0x4d, 0xca, 0xcd, 0x9f, 0x80, 0x5b, 0x65,
+	0x62, 0x39, 0xc0, 0xf7, 0xcd, 0x31, 0xb7, 0x56, 0x77, 0x5d, 0x67, 0x9d, 0x8a, 0xe5, 0xc0, 0x77,
+	0xd6, 0xb9, 0x58, 0x0e, 0xd0, 0x57, 0xd0, 0xd8, 0x48, 0xc6, 0x72, 0x80, 0x91, 0x99, 0xd4, 0x5e,
+	0xdd, 0x75, 0xdd, 0x2a, 0x1b, 0xcb, 0x81, 0xef, 0x56, 0xe9, 0x58, 0x0e, 0xd0, 0x63, 0x68, 0x2d,
+	0xc8, 0x4d, 0x10, 0x0a, 0xce, 0x59, 0xa8, 0x22, 0xc1, 0x53, 0xfc, 0xc0, 0xd4, 0xd3, 0x5c, 0x90,
+	0x9b, 0x61, 0xa5, 0x6a, 0xb0, 0x82, 0x02, 0x49, 0x14, 0xc3, 0xfb, 0x39, 0x58, 0xc9, 0x3e, 0x51,
+	0x4c, 0xf7, 0xa7, 0x0c, 0xcd, 0x4c, 0x8a, 0x2c, 0xc1, 0xef, 0xe5, 0xfd, 0x29, 0xc4, 0x67, 0x5a,
+	0xeb, 0xff, 0x5a, 0x03, 0xa8, 0x62, 0xf7, 0xd6, 0x9b, 0xf6, 0x04, 0xea, 0xe6, 0x66, 0x86, 0x22,
+	0x36, 0xb7, 0xac, 0x79, 0xd2, 0x7d, 0x7b, 0x68, 0x8f, 0xbc, 0x02, 0xf3, 0xd7, 0x13, 0xf4, 0x0f,
+	0x75, 0xdc, 0xcd, 0xed, 0x6b, 0xf8, 0x66, 0xbc, 0x3e, 0x13, 0x63, 0xec, 0x18, 0xc3, 0x9c, 0x89,
+	0xfe, 0x13, 0xfa, 0x04, 0x9a, 0x89, 0x14, 0x37, 0xb7, 0xc1, 0x7a, 0xcd, 0x7b, 0x86, 0x68, 0x18,
+	0xb5, 0x5c, 0x01, 0x9d, 0x81, 0x9b, 0x64, 0xd3, 0x38, 0x4a, 0xe7, 0xc1, 0x42, 0x50, 0x86, 0x77,
+	0x4d, 0x61, 0xfd, 0xff, 0x2a, 0x2c, 0x47, 0xcf, 0x05, 0x65, 0xbe, 0x93, 0x54, 0x1f, 0xfa, 0xb6,
+	0xe8, 0x2a, 0x82, 0x50, 0x64, 0x5c, 0xe1, 0x3d, 0xb3, 0x92, 0x9d, 0x98, 0x89, 0x19, 0x57, 0xba,
+	0xd7, 0x24, 0x8e, 0xc5, 0x35, 0xa3, 0x41, 0x2a, 0x32, 0x19, 0xb2, 0x14, 0xd7, 0xcd, 0xb5, 0x6b,
+	0x16, 0xf2, 0x38, 0x57, 0xfb, 0x17, 0x50, 0x5f, 0x97, 0x86, 0xa1, 0x36, 0x19, 0x7a, 0xed, 0xad,
+	0x83, 0xd6, 0x0f, 0x3f, 0xf5, 0x9c, 0x52, 0x9e, 0x0c, 0x3d, 0xed, 0xbc, 0x3c, 0xf5, 0xda, 0xd6,
+	0xeb, 0xce, 0xcb, 0x53, 0x0f, 0xd5, 0x61, 0x67, 0x3c, 0x9c, 0x78, 0xed, 0xed, 0x83, 0x9d, 0xef,
+	0x7f, 0xee, 0x6c, 0xf5, 0x3f, 0x06, 0x67, 0xa3, 0x66, 0xe4, 0xc0, 0xde, 0xe8, 0xe2, 0x99, 0x7f,
+	0x36, 0x1e, 0xb7, 0xb7, 0x34, 0xfb, 0xfc, 0x9b, 0xf1, 0xa4, 0x6d, 0xf5, 0x7f, 0xb4, 0xa0, 0xfe,
+	0x42, 0x10, 0xfa, 0x3c, 0xe2, 0xea, 0x8d, 0xd7, 0xce, 0x7a, 0xc7, 0x6b, 0xf7, 0xc6, 0xfb, 0xb5,
+	0xfd, 0xce, 0xf7, 0xeb, 0x73, 0x40, 0x24, 0x54, 0xd1, 0x92, 0xbd, 0x16, 0xd1, 0xfc, 0x58, 0xef,
+	0xe7, 0xce, 0x46, 0x4a, 0xfb, 0xbf, 0x58, 0xe0, 0x4e, 0x24, 0xb9, 0xbc, 0x8c, 0xc2, 0x71, 0x12,
+	0x47, 0xff, 0xb7, 0xbc, 0x47, 0xe0, 0x16, 0xab, 0xe5, 0xd1, 0x2d, 0x5e, 0xf7, 0x5c, 0x33, 0xc9,
+	0x35, 0xf1, 0x56, 0x84, 0xd3, 0xe9, 0x6d, 0xc1, 0xd4, 0x8a, 0x78, 0xe7, 0x62, 0x0e, 0x3d, 0x86,
+	0x56, 0x09, 0x25, 0x4c, 0x86, 0x8c, 0x97, 0x81, 0x6b, 0x16, 0xb2, 0x97, 0xab, 0x4f, 0xf1, 0x1f,
+	0xaf, 0x3a, 0x5b, 0x7f, 0xbf, 0xea, 0x58, 0xdf, 0xad, 0x3a, 0xd6, 0x6f, 0xab, 0x8e, 0xf5, 0xfb,
+	0xaa, 0x63, 0xfd, 0xb9, 0xea, 0x58, 0xd3, 0x5d, 0x13, 0xc4, 0x2f, 0xff, 0x19, 0x00, 0xeb, 0xf9,
+	0xa2, 0x5e, 0xc7, 0x06, 0x00, 0x00,
 }
@@ -304,6 +304,9 @@ func procCreateNetwork(c libnetwork.NetworkController, vars map[string]string, b
 		}
 		options = append(options, libnetwork.NetworkOptionEnableIPv6(enableIPv6))
 	}
+	if val, ok := create.NetworkOpts[netlabel.DNSSearch]; ok {
+		options = append(options, libnetwork.NetworkOptionDNSSearch(strings.Split(val, ",")))
+	}
 	if len(create.DriverOpts) > 0 {
 		options = append(options, libnetwork.NetworkOptionDriverOpts(create.DriverOpts))
 	}
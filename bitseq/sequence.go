@@ -214,6 +214,90 @@ func (h *Handle) SetAny() (uint64, error) {
 	return h.set(0, 0, h.bits-1, true, false)
 }
 
+// SetAnyN atomically sets n unset bits in the specified range in the
+// sequence, with a single write to the store, and returns the corresponding
+// ordinals in ascending order. The bits are the first n unset ones, or the
+// first n consecutive unset ones if consecutive is true. None is set on
+// failure.
+func (h *Handle) SetAnyN(start, end, n uint64, consecutive bool) ([]uint64, error) {
+	if n == 0 || start > end || end >= h.Bits() {
+		return nil, fmt.Errorf("invalid request of %d bits in range [%d, %d]", n, start, end)
+	}
+	if h.Unselected() < n {
+		return nil, ErrNoBitAvailable
+	}
+
+	for {
+		h.Lock()
+		store := h.store
+		h.Unlock()
+		if store != nil {
+			if err := store.GetObject(datastore.Key(h.Key()...), h); err != nil && err != datastore.ErrKeyNotFound {
+				return nil, err
+			}
+		}
+
+		// Create a private copy of h and reserve the bits on it
+		h.Lock()
+		nh := h.getCopy()
+		h.Unlock()
+
+		ordinals, err := nh.reserveN(start, end, n, consecutive)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := nh.writeToStore(); err != nil {
+			if _, ok := err.(types.RetryError); !ok {
+				return nil, fmt.Errorf("internal failure while setting the bits: %v", err)
+			}
+			// Retry
+			continue
+		}
+
+		h.Lock()
+		defer h.Unlock()
+		h.unselected = nh.unselected
+		h.head = nh.head
+		h.dbExists = nh.dbExists
+		h.dbIndex = nh.dbIndex
+		return ordinals, nil
+	}
+}
+
+// reserveN sets n unset bits of the range in the private copy of a handle
+func (h *Handle) reserveN(start, end, n uint64, consecutive bool) ([]uint64, error) {
+	if h.unselected < n {
+		return nil, ErrNoBitAvailable
+	}
+	ordinals := make([]uint64, 0, n)
+	for from := start; uint64(len(ordinals)) < n; {
+		if from > end {
+			return nil, ErrNoBitAvailable
+		}
+		bytePos, bitPos, err := getFirstAvailable(h.head, from)
+		if err != nil {
+			return nil, err
+		}
+		o := posToOrdinal(bytePos, bitPos)
+		if o > end {
+			return nil, ErrNoBitAvailable
+		}
+		// a set bit breaks the run, which restarts from this one
+		if consecutive && len(ordinals) > 0 && o != ordinals[len(ordinals)-1]+1 {
+			ordinals = ordinals[:0]
+		}
+		ordinals = append(ordinals, o)
+		from = o + 1
+	}
+	for _, o := range ordinals {
+		bytePos, bitPos := ordinalToPos(o)
+		h.head = pushReservation(bytePos, bitPos, h.head, false)
+		h.unselected--
+	}
+	return ordinals, nil
+}
+
 // Set atomically sets the corresponding bit in the sequence
 func (h *Handle) Set(ordinal uint64) error {
 	if err := h.validateOrdinal(ordinal); err != nil {
@@ -257,6 +341,32 @@ func (h *Handle) runConsistencyCheck() bool {
 	return corrupted
 }
 
+// WalkSet invokes the passed function on the set bits in ascending order,
+// until it returns true. The runs of unset blocks are skipped at once. The
+// handle is locked during the walk, the function must not call it.
+func (h *Handle) WalkSet(fn func(ordinal uint64) bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	var base uint64
+	for s := h.head; s != nil; s = s.next {
+		if s.block != 0 {
+			for i := uint64(0); i < s.count; i++ {
+				for b := uint64(0); b < uint64(blockLen); b++ {
+					o := base + i*uint64(blockLen) + b
+					if o >= h.bits {
+						return
+					}
+					if s.block&(blockFirstBit>>b) != 0 && fn(o) {
+						return
+					}
+				}
+			}
+		}
+		base += s.count * uint64(blockLen)
+	}
+}
+
 // CheckConsistency checks if the bit sequence is in an inconsistent state and attempts to fix it.
 // It looks for a corruption signature that may happen in docker 1.9.0 and 1.9.1.
 func (h *Handle) CheckConsistency() error {
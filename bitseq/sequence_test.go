@@ -1133,3 +1133,89 @@ func TestIsCorrupted(t *testing.T) {
 		}
 	}
 }
+
+func TestWalkSet(t *testing.T) {
+	numBits := uint64(1000)
+	hnd, err := NewHandle("", nil, "", numBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := []uint64{0, 31, 32, 100, 640, 641, 999}
+	for _, o := range set {
+		if err := hnd.Set(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var walked []uint64
+	hnd.WalkSet(func(o uint64) bool {
+		walked = append(walked, o)
+		return false
+	})
+	if len(walked) != len(set) {
+		t.Fatalf("Expected %v, got %v", set, walked)
+	}
+	for i := range set {
+		if walked[i] != set[i] {
+			t.Fatalf("Expected %v, got %v", set, walked)
+		}
+	}
+
+	walked = nil
+	hnd.WalkSet(func(o uint64) bool {
+		walked = append(walked, o)
+		return o == 100
+	})
+	if len(walked) != 4 {
+		t.Fatalf("Expected the walk to stop at 100, got %v", walked)
+	}
+}
+
+func TestSetAnyN(t *testing.T) {
+	numBits := uint64(100)
+	hnd, err := NewHandle("", nil, "", numBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range []uint64{0, 3, 5, 6} {
+		if err := hnd.Set(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := hnd.SetAnyN(0, numBits-1, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0] != 1 || list[1] != 2 || list[2] != 4 {
+		t.Fatalf("Unexpected ordinals: %v", list)
+	}
+
+	list, err = hnd.SetAnyN(0, numBits-1, 4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 4 || list[0] != 7 || list[3] != 10 {
+		t.Fatalf("Unexpected ordinals: %v", list)
+	}
+	if hnd.Unselected() != numBits-11 {
+		t.Fatalf("Unexpected number of unselected bits: %d", hnd.Unselected())
+	}
+
+	// none is set on failure
+	if _, err := hnd.SetAnyN(90, numBits-1, 11, true); err != ErrNoBitAvailable {
+		t.Fatalf("Expected ErrNoBitAvailable, got %v", err)
+	}
+	if _, err := hnd.SetAnyN(0, numBits-1, numBits, false); err != ErrNoBitAvailable {
+		t.Fatalf("Expected ErrNoBitAvailable, got %v", err)
+	}
+	if hnd.Unselected() != numBits-11 {
+		t.Fatalf("Unexpected number of unselected bits: %d", hnd.Unselected())
+	}
+
+	if _, err := hnd.SetAnyN(0, numBits, 1, false); err == nil {
+		t.Fatal("Expected failure for a range beyond the sequence")
+	}
+}
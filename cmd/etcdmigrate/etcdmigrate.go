@@ -0,0 +1,48 @@
+// Command etcdmigrate copies the libnetwork keys of an etcd v2 store to an
+// etcd v3 one, for the daemons moving to the etcdv3 datastore provider.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/libkv"
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/etcd"
+	"github.com/docker/libnetwork/datastore/etcdv3"
+)
+
+func main() {
+	from := flag.String("from", "", "comma separated etcd v2 endpoints")
+	to := flag.String("to", "", "comma separated etcd v3 endpoints")
+	root := flag.String("root", "docker/network/v1.0", "root directory of the keys to migrate")
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	etcd.Register()
+	etcdv3.Register()
+
+	src, err := libkv.NewStore(store.ETCD, strings.Split(*from, ","), &store.Config{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to the etcd v2 store: %v\n", err)
+		os.Exit(1)
+	}
+	dst, err := libkv.NewStore(etcdv3.Backend, strings.Split(*to, ","), &store.Config{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to the etcd v3 store: %v\n", err)
+		os.Exit(1)
+	}
+
+	n, err := etcdv3.Migrate(src, dst, *root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration failed after %d keys: %v\n", n, err)
+		os.Exit(1)
+	}
+	fmt.Printf("migrated %d keys\n", n)
+}
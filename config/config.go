@@ -2,6 +2,7 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	log "github.com/Sirupsen/logrus"
@@ -10,6 +11,7 @@ import (
 	"github.com/docker/libkv/store"
 	"github.com/docker/libnetwork/cluster"
 	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/ipamutils"
 	"github.com/docker/libnetwork/netlabel"
 )
 
@@ -29,6 +31,27 @@ type DaemonCfg struct {
 	Labels          []string
 	DriverCfg       map[string]interface{}
 	ClusterProvider cluster.Provider
+	DNSCacheSize    int
+	DNSCacheMaxTTL  uint32
+	LBDrainPeriod   time.Duration
+	IngressSubnet   string
+	IngressGateway  string
+	VIPPool         string
+	LBBackend       string
+	LBLoadInterval  time.Duration
+	SandboxPoolSize int
+	// utilization percentages of the ipam pools crossing which is notified
+	PoolUsageThresholds []int
+	// interval of the passes releasing the orphaned ipam allocations
+	IPAMReconcileInterval time.Duration
+	// interface the IPv6 prefix delegation is requested through
+	PrefixDelegationIface string
+	// length of the subnets carved from the delegated prefix
+	PrefixDelegationLen int
+	// networks the local and global scope networks without explicit
+	// subnets are allocated from
+	DefaultAddressPools       []*ipamutils.NetworkToSplit
+	DefaultGlobalAddressPools []*ipamutils.NetworkToSplit
 }
 
 // ClusterCfg represents cluster configuration
@@ -170,6 +193,24 @@ func OptionKVOpts(opts map[string]string) Option {
 		} else {
 			log.Info("Option Initializing KV without TLS")
 		}
+
+		if opts["kv.username"] != "" || opts["kv.password"] != "" || opts["kv.token"] != "" {
+			log.Info("Option Initializing KV with authentication")
+			if _, ok := c.Scopes[datastore.GlobalScope]; !ok {
+				c.Scopes[datastore.GlobalScope] = &datastore.ScopeCfg{}
+			}
+			c.Scopes[datastore.GlobalScope].Client.Username = opts["kv.username"]
+			c.Scopes[datastore.GlobalScope].Client.Password = opts["kv.password"]
+			c.Scopes[datastore.GlobalScope].Client.Token = opts["kv.token"]
+		}
+
+		if opts["kv.prefix"] != "" {
+			log.Infof("Option Initializing KV with prefix %s", opts["kv.prefix"])
+			if _, ok := c.Scopes[datastore.GlobalScope]; !ok {
+				c.Scopes[datastore.GlobalScope] = &datastore.ScopeCfg{}
+			}
+			c.Scopes[datastore.GlobalScope].Client.Prefix = opts["kv.prefix"]
+		}
 	}
 }
 
@@ -194,6 +235,142 @@ func OptionDataDir(dataDir string) Option {
 	}
 }
 
+// OptionDNSCache function returns an option setter for the cache of the
+// queries forwarded by the embedded DNS server. A zero size disables the
+// cache, a zero maxTTL honors the TTL of the upstream answers.
+func OptionDNSCache(size int, maxTTL uint32) Option {
+	return func(c *Config) {
+		log.Debugf("Option DNSCache: size %d, max ttl %d", size, maxTTL)
+		c.Daemon.DNSCacheSize = size
+		c.Daemon.DNSCacheMaxTTL = maxTTL
+	}
+}
+
+// OptionLBDrainPeriod function returns an option setter for the period
+// during which a removed service backend keeps serving its established
+// connections before it is deleted from the load balancers.
+func OptionLBDrainPeriod(period time.Duration) Option {
+	return func(c *Config) {
+		log.Debugf("Option LBDrainPeriod: %v", period)
+		c.Daemon.LBDrainPeriod = period
+	}
+}
+
+// OptionIngressSubnet function returns an option setter for the subnet
+// and the gateway of the ingress network, used when the network is created
+// without an explicit IPAM configuration.
+func OptionIngressSubnet(subnet, gateway string) Option {
+	return func(c *Config) {
+		log.Debugf("Option IngressSubnet: %s, IngressGateway: %s", subnet, gateway)
+		c.Daemon.IngressSubnet = subnet
+		c.Daemon.IngressGateway = gateway
+	}
+}
+
+// OptionVIPPool function returns an option setter for the range the
+// service VIPs are allocated from, within the pool of their network.
+func OptionVIPPool(pool string) Option {
+	return func(c *Config) {
+		log.Debugf("Option VIPPool: %s", pool)
+		c.Daemon.VIPPool = pool
+	}
+}
+
+// OptionLBLoadInterval function returns an option setter for the interval
+// at which the node gossips the number of connections it has active to
+// each service backend. The nodes weight the backends by the gossiped
+// load. Zero disables the gossip.
+func OptionLBLoadInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		log.Debugf("Option LBLoadInterval: %v", interval)
+		c.Daemon.LBLoadInterval = interval
+	}
+}
+
+// OptionSandboxPoolSize function returns an option setter for the number
+// of sandboxes created ahead of time, with their load balancer ready, so
+// that the first container started on a network does not wait for its
+// sandbox to be set up. Zero disables the pool.
+func OptionSandboxPoolSize(size int) Option {
+	return func(c *Config) {
+		log.Debugf("Option SandboxPoolSize: %d", size)
+		c.Daemon.SandboxPoolSize = size
+	}
+}
+
+// OptionPoolUsageThresholds function returns an option setter for the
+// utilization percentages of the ipam pools, the crossing of which is
+// notified to the pool event subscribers. No threshold disables the
+// notifications.
+func OptionPoolUsageThresholds(thresholds ...int) Option {
+	return func(c *Config) {
+		log.Debugf("Option PoolUsageThresholds: %v", thresholds)
+		c.Daemon.PoolUsageThresholds = append([]int{}, thresholds...)
+	}
+}
+
+// OptionIPAMReconcileInterval function returns an option setter for the
+// interval at which the addresses allocated from the ipam pools and used
+// by no endpoint are released. Zero disables the periodic passes.
+func OptionIPAMReconcileInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		log.Debugf("Option IPAMReconcileInterval: %v", interval)
+		c.Daemon.IPAMReconcileInterval = interval
+	}
+}
+
+// OptionPrefixDelegation function returns an option setter for the
+// interface through which the delegation of an IPv6 prefix is requested
+// from the upstream router with DHCPv6, the IPv6 subnets of the local
+// networks being carved from the prefix with the passed length, /64 when
+// zero.
+func OptionPrefixDelegation(ifName string, subnetLen int) Option {
+	return func(c *Config) {
+		log.Debugf("Option PrefixDelegation: %s /%d", ifName, subnetLen)
+		c.Daemon.PrefixDelegationIface = ifName
+		c.Daemon.PrefixDelegationLen = subnetLen
+	}
+}
+
+// OptionDefaultAddressPools function returns an option setter for the
+// networks the local scope networks without explicit subnets are allocated
+// from
+func OptionDefaultAddressPools(pools []*ipamutils.NetworkToSplit) Option {
+	return func(c *Config) {
+		log.Debugf("Option DefaultAddressPools: %d pools", len(pools))
+		c.Daemon.DefaultAddressPools = pools
+	}
+}
+
+// OptionDefaultGlobalAddressPools function returns an option setter for
+// the networks the global scope networks without explicit subnets are
+// allocated from
+func OptionDefaultGlobalAddressPools(pools []*ipamutils.NetworkToSplit) Option {
+	return func(c *Config) {
+		log.Debugf("Option DefaultGlobalAddressPools: %d pools", len(pools))
+		c.Daemon.DefaultGlobalAddressPools = pools
+	}
+}
+
+// Backends programming the service load balancers
+const (
+	// LBBackendIPVS programs the load balancers with IPVS and
+	// iptables firewall marks. This is the default.
+	LBBackendIPVS = "ipvs"
+	// LBBackendEBPF programs the load balancers with eBPF programs
+	// attached to cgroup hooks.
+	LBBackendEBPF = "ebpf"
+)
+
+// OptionLBBackend function returns an option setter for the backend
+// programming the service load balancers.
+func OptionLBBackend(backend string) Option {
+	return func(c *Config) {
+		log.Debugf("Option LBBackend: %s", backend)
+		c.Daemon.LBBackend = backend
+	}
+}
+
 // ProcessOptions processes options and stores it in config
 func (c *Config) ProcessOptions(options ...Option) {
 	for _, opt := range options {
@@ -143,3 +143,12 @@ BFrwkQE4HQtQBV60hYQUzzlSk44VFDz+jxIEtacRHaomDRh2FtOTz+I=
 		t.Fatal("TLS.Certificates is not length 1")
 	}
 }
+
+func TestOptionKVAuth(t *testing.T) {
+	c := &Config{Scopes: map[string]*datastore.ScopeCfg{}}
+	OptionKVOpts(map[string]string{"kv.username": "user", "kv.password": "secret"})(c)
+	client := c.Scopes[datastore.GlobalScope].Client
+	if client.Username != "user" || client.Password != "secret" || client.Token != "" {
+		t.Fatalf("Unexpected client configuration %+v", client)
+	}
+}
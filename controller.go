@@ -54,6 +54,7 @@ import (
 	"github.com/docker/docker/pkg/discovery"
 	"github.com/docker/docker/pkg/plugins"
 	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/go-events"
 	"github.com/docker/libnetwork/cluster"
 	"github.com/docker/libnetwork/config"
 	"github.com/docker/libnetwork/datastore"
@@ -62,6 +63,7 @@ import (
 	"github.com/docker/libnetwork/drvregistry"
 	"github.com/docker/libnetwork/hostdiscovery"
 	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/ipamutils"
 	"github.com/docker/libnetwork/netlabel"
 	"github.com/docker/libnetwork/osl"
 	"github.com/docker/libnetwork/types"
@@ -115,6 +117,93 @@ type NetworkController interface {
 	// SetClusterProvider sets cluster provider
 	SetClusterProvider(provider cluster.Provider)
 
+	// SetDNSPolicy sets the policy consulted by the embedded DNS server
+	// before answering or forwarding a query. A nil policy passes all queries.
+	SetDNSPolicy(policy DNSPolicy)
+
+	// FlushDNSCache drops the answers cached by the embedded DNS server
+	// of every sandbox
+	FlushDNSCache()
+
+	// WatchServiceRecords returns a channel streaming the service discovery
+	// changes of the network with the passed id, or of all the networks if
+	// the id is empty, along with a function to cancel the watch.
+	WatchServiceRecords(nid string) (chan events.Event, func())
+
+	// SubscribeServiceEvents returns a channel streaming a
+	// ServiceBindingEvent for every backend added to, removed from or
+	// updated in a service load balancer, along with a function to
+	// cancel the subscription.
+	SubscribeServiceEvents() (chan events.Event, func())
+
+	// SubscribePoolEvents returns a channel streaming a PoolUsageEvent
+	// every time the usage of an ipam pool of a network crosses one of
+	// the configured thresholds, and a DelegatedPrefixEvent every time
+	// the IPv6 prefix delegated to the node changes, along with a
+	// function to cancel the subscription.
+	SubscribePoolEvents() (chan events.Event, func())
+
+	// ReconcileAddresses returns the addresses allocated from the ipam
+	// pools of the networks which no endpoint uses anymore, releasing the
+	// ones already found orphaned by the previous pass unless dryRun is
+	// set.
+	ReconcileAddresses(dryRun bool) ([]*OrphanedAddress, error)
+
+	// ExportIPAMState returns the pools of the ipam driver with the passed
+	// name and the addresses allocated from them, as a portable document.
+	ExportIPAMState(ipamName string) (*ipamapi.State, error)
+
+	// ImportIPAMState imports the pools and the addresses of the document
+	// in the ipam driver with the passed name, none of them on failure.
+	ImportIPAMState(ipamName string, state *ipamapi.State) error
+
+	// SetAddressAuditHook sets the function invoked for every address
+	// allocated from or released to the ipam pools of the networks, nil
+	// removing it.
+	SetAddressAuditHook(hook AddressAuditHook)
+
+	// SetIPAMQuotaPolicy sets the policy limiting the number of addresses
+	// the ipam driver with the passed name allocates to each tenant, nil
+	// removing it.
+	SetIPAMQuotaPolicy(ipamName string, policy *ipamapi.QuotaPolicy) error
+
+	// CreateService creates a load balanced service with the passed name
+	// on the network with the passed id, for the embedders not relying on
+	// the cluster agent. A VIP is allocated from the network unless one
+	// is passed. It returns the id and the VIP of the service.
+	CreateService(name, nid string, vip net.IP) (string, net.IP, error)
+
+	// AttachServiceBackend adds the endpoint with the passed id, on the
+	// network of the service, to the backends of the service.
+	AttachServiceBackend(sid, eid string) error
+
+	// DetachServiceBackend removes the endpoint with the passed id from
+	// the backends of the service. Backends are not detached when their
+	// endpoint is deleted, the caller is in charge of it.
+	DetachServiceBackend(sid, eid string) error
+
+	// DeleteService detaches the remaining backends of the service
+	// created by CreateService and releases its VIP.
+	DeleteService(sid string) error
+
+	// SwitchServiceTraffic sends standbyPercent percent of the traffic of
+	// the service with the passed id to the backends of the standby group
+	// and the rest to the backends of the active group, on all the nodes.
+	// The backends of the other groups get no new connections. An empty
+	// active group sends the traffic to all the backends again.
+	SwitchServiceTraffic(sid, active, standby string, standbyPercent uint32) error
+
+	// RequestVIP allocates a service VIP on the network with the passed
+	// id, from the configured VIP pool if it belongs to the network.
+	RequestVIP(nid string) (net.IP, error)
+
+	// ReleaseVIP releases a service VIP allocated by RequestVIP.
+	ReleaseVIP(nid string, vip net.IP) error
+
+	// ServiceStats returns the load balancing statistics of the service
+	// with the passed id, summed over the sandboxes of this node.
+	ServiceStats(sid string) (*ServiceStats, error)
+
 	// Wait for agent initialization complete in libnetwork controller
 	AgentInitWait()
 }
@@ -142,11 +231,26 @@ type controller struct {
 	svcRecords      map[string]svcInfo
 	nmap            map[string]*netWatch
 	serviceBindings map[string]*service
+	localServices   map[string]*localService
+	trafficSplits   map[string]*trafficSplit
+	sbPool          *sandboxPool
 	defOsSbox       osl.Sandbox
 	ingressSandbox  *sandbox
 	sboxOnce        sync.Once
 	agent           *agent
 	agentInitDone   chan struct{}
+	dnsPolicy       DNSPolicy
+	svcBroadcaster  *events.Broadcaster
+	poolLevels      map[string]int
+	poolLevelsMu    sync.Mutex
+	orphans         map[string]bool
+	orphansMu       sync.Mutex
+	reconcileStop   chan struct{}
+	expandMu        sync.Mutex
+	delegatedPrefix *net.IPNet
+	pdStop          chan struct{}
+	leaseStop       chan struct{}
+	auditHook       AddressAuditHook
 	sync.Mutex
 }
 
@@ -163,13 +267,35 @@ func New(cfgOptions ...config.Option) (NetworkController, error) {
 		sandboxes:       sandboxTable{},
 		svcRecords:      make(map[string]svcInfo),
 		serviceBindings: make(map[string]*service),
+		localServices:   make(map[string]*localService),
+		trafficSplits:   make(map[string]*trafficSplit),
 		agentInitDone:   make(chan struct{}),
+		svcBroadcaster:  events.NewBroadcaster(),
+		poolLevels:      make(map[string]int),
+	}
+
+	// Only IPVS is supported so far, an eBPF backend needs a program
+	// loader which is not available yet.
+	if lb := c.cfg.Daemon.LBBackend; lb != "" && lb != config.LBBackendIPVS {
+		return nil, types.NotImplementedErrorf("load balancer backend %q is not supported", lb)
 	}
 
 	if err := c.initStores(); err != nil {
 		return nil, err
 	}
 
+	// the built-in ipam reads the default address pools on initialization
+	if err := ipamutils.ConfigLocalScopeDefaultNetworks(c.cfg.Daemon.DefaultAddressPools); err != nil {
+		return nil, err
+	}
+	if err := ipamutils.ConfigGlobalScopeDefaultNetworks(c.cfg.Daemon.DefaultGlobalAddressPools); err != nil {
+		return nil, err
+	}
+
+	if size := c.cfg.Daemon.SandboxPoolSize; size > 0 {
+		c.sbPool = newSandboxPool(size)
+	}
+
 	drvRegistry, err := drvregistry.New(c.getStore(datastore.LocalScope), c.getStore(datastore.GlobalScope), c.RegisterDriver, nil)
 	if err != nil {
 		return nil, err
@@ -208,6 +334,19 @@ func New(cfgOptions ...config.Option) (NetworkController, error) {
 		return nil, err
 	}
 
+	if ifName := c.cfg.Daemon.PrefixDelegationIface; ifName != "" {
+		c.pdStop = make(chan struct{})
+		go c.startPrefixDelegation(ifName, c.pdStop)
+	}
+
+	if interval := c.cfg.Daemon.IPAMReconcileInterval; interval > 0 {
+		c.reconcileStop = make(chan struct{})
+		go c.reconcileAddressesLoop(interval, c.reconcileStop)
+	}
+
+	c.leaseStop = make(chan struct{})
+	go c.renewLeasesLoop(c.leaseStop)
+
 	return c, nil
 }
 
@@ -216,6 +355,33 @@ func (c *controller) SetClusterProvider(provider cluster.Provider) {
 	go c.clusterAgentInit()
 }
 
+func (c *controller) SetDNSPolicy(policy DNSPolicy) {
+	c.Lock()
+	c.dnsPolicy = policy
+	c.Unlock()
+}
+
+func (c *controller) getDNSPolicy() DNSPolicy {
+	c.Lock()
+	defer c.Unlock()
+	return c.dnsPolicy
+}
+
+func (c *controller) FlushDNSCache() {
+	c.Lock()
+	sandboxes := make([]*sandbox, 0, len(c.sandboxes))
+	for _, sb := range c.sandboxes {
+		sandboxes = append(sandboxes, sb)
+	}
+	c.Unlock()
+
+	for _, sb := range sandboxes {
+		if sb.resolver != nil {
+			sb.resolver.FlushCache()
+		}
+	}
+}
+
 func isValidClusteringIP(addr string) bool {
 	return addr != "" && !net.ParseIP(addr).IsLoopback() && !net.ParseIP(addr).IsUnspecified()
 }
@@ -303,11 +469,19 @@ func (c *controller) makeDriverConfig(ntype string) map[string]interface{} {
 		if !v.IsValid() {
 			continue
 		}
+		// the TLS settings and credentials of the client are passed in
+		// the store configuration
+		storeCfg, err := v.Client.StoreConfig()
+		if err != nil {
+			log.Warnf("Invalid configuration of the store client of the %s scope: %v", k, err)
+			continue
+		}
 		config[netlabel.MakeKVClient(k)] = discoverapi.DatastoreConfigData{
 			Scope:    k,
 			Provider: v.Client.Provider,
 			Address:  v.Client.Address,
-			Config:   v.Client.Config,
+			Config:   storeCfg,
+			Prefix:   v.Client.Prefix,
 		}
 	}
 
@@ -333,7 +507,8 @@ func (c *controller) ReloadConfiguration(cfgOptions ...config.Option) error {
 	for s, nSCfg := range cfg.Scopes {
 		if eSCfg, ok := c.cfg.Scopes[s]; ok {
 			if eSCfg.Client.Provider != nSCfg.Client.Provider ||
-				eSCfg.Client.Address != nSCfg.Client.Address {
+				eSCfg.Client.Address != nSCfg.Client.Address ||
+				eSCfg.Client.Prefix != nSCfg.Client.Prefix {
 				return types.ForbiddenErrorf("cannot accept new configuration because it modifies an existing datastore client")
 			}
 		} else {
@@ -352,11 +527,16 @@ func (c *controller) ReloadConfiguration(cfgOptions ...config.Option) error {
 		if scope == datastore.LocalScope || !sCfg.IsValid() {
 			continue
 		}
+		storeCfg, err := sCfg.Client.StoreConfig()
+		if err != nil {
+			return err
+		}
 		dsConfig = &discoverapi.DatastoreConfigData{
 			Scope:    scope,
 			Provider: sCfg.Client.Provider,
 			Address:  sCfg.Client.Address,
-			Config:   sCfg.Client.Config,
+			Config:   storeCfg,
+			Prefix:   sCfg.Client.Prefix,
 		}
 		break
 	}
@@ -546,6 +726,18 @@ func (c *controller) NewNetwork(networkType, name string, id string, options ...
 
 	network.processOptions(options...)
 
+	if _, err := network.autoExpandLimit(); err != nil {
+		return nil, err
+	}
+
+	// The ingress network defaults to the configured subnet.
+	if network.ingress && len(network.ipamV4Config) == 0 && c.cfg != nil && c.cfg.Daemon.IngressSubnet != "" {
+		network.ipamV4Config = []*IpamConf{{
+			PreferredPool: c.cfg.Daemon.IngressSubnet,
+			Gateway:       c.cfg.Daemon.IngressGateway,
+		}}
+	}
+
 	_, cap, err := network.resolveDriver(networkType, true)
 	if err != nil {
 		return nil, err
@@ -588,23 +780,13 @@ func (c *controller) NewNetwork(networkType, name string, id string, options ...
 		}
 	}()
 
-	// First store the endpoint count, then the network. To avoid to
-	// end up with a datastore containing a network and not an epCnt,
-	// in case of an ungraceful shutdown during this function call.
+	// Store the endpoint count along with the network, in a single
+	// transaction. To avoid to end up with a datastore containing a
+	// network and not an epCnt, in case of an ungraceful shutdown during
+	// this function call.
 	epCnt := &endpointCnt{n: network}
-	if err = c.updateToStore(epCnt); err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err != nil {
-			if e := c.deleteFromStore(epCnt); e != nil {
-				log.Warnf("couldnt rollback from store, epCnt %v on failure (%v): %v", epCnt, err, e)
-			}
-		}
-	}()
-
 	network.epCnt = epCnt
-	if err = c.updateToStore(network); err != nil {
+	if err = c.updateTxnToStore(datastore.TxnOp{Object: epCnt}, datastore.TxnOp{Object: network}); err != nil {
 		return nil, err
 	}
 
@@ -745,7 +927,10 @@ func (c *controller) NewSandbox(containerID string, options ...SandboxOption) (s
 		return nil, types.BadRequestErrorf("invalid container ID")
 	}
 
-	var sb *sandbox
+	var (
+		sb    *sandbox
+		fresh bool
+	)
 	c.Lock()
 	for _, s := range c.sandboxes {
 		if s.containerID == containerID {
@@ -776,6 +961,7 @@ func (c *controller) NewSandbox(containerID string, options ...SandboxOption) (s
 			config:      containerConfig{},
 			controller:  c,
 		}
+		fresh = true
 	}
 	sBox = sb
 
@@ -783,6 +969,15 @@ func (c *controller) NewSandbox(containerID string, options ...SandboxOption) (s
 
 	sb.processOptions(options...)
 
+	// A new sandbox takes over a pooled OS sandbox along with the id
+	// its key is derived from.
+	if fresh && !sb.config.useDefaultSandBox && !sb.config.useExternalKey {
+		if ps, ok := c.sbPool.get(); ok {
+			sb.id = ps.id
+			sb.osSbox = ps.osSbox
+		}
+	}
+
 	c.Lock()
 	if sb.ingress && c.ingressSandbox != nil {
 		c.Unlock()
@@ -969,5 +1164,14 @@ func (c *controller) getIPAMDriver(name string) (ipamapi.Ipam, *ipamapi.Capabili
 func (c *controller) Stop() {
 	c.closeStores()
 	c.stopExternalKeyListener()
+	c.svcBroadcaster.Close()
+	c.sbPool.close()
+	if c.reconcileStop != nil {
+		close(c.reconcileStop)
+	}
+	if c.pdStop != nil {
+		close(c.pdStop)
+	}
+	close(c.leaseStop)
 	osl.GC()
 }
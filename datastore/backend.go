@@ -0,0 +1,88 @@
+package datastore
+
+import (
+	"sync"
+
+	"github.com/docker/libkv"
+	"github.com/docker/libkv/store"
+	"github.com/docker/libnetwork/types"
+)
+
+// BackendCapability lists the features of a store backend the datastore
+// adapts to
+type BackendCapability struct {
+	// Watch tells whether the backend supports Watch and WatchTree. The
+	// stores of a backend without watches are not watchable.
+	Watch bool
+	// AtomicOps tells whether AtomicPut and AtomicDelete compare the
+	// index of the keys. The global scope requires them, the local one
+	// falls back to plain puts and deletes serialized by the datastore.
+	AtomicOps bool
+	// TTL tells whether the backend expires the keys put with a TTL
+	TTL bool
+	// Scopes are the scopes the backend can serve, any if empty
+	Scopes []string
+}
+
+var (
+	backendsMu sync.Mutex
+	// capabilities of the registered backends, by name. The backends
+	// registered to libkv directly are assumed to support every feature.
+	backends = make(map[string]*BackendCapability)
+)
+
+// RegisterBackend registers a custom store backend, under the name the
+// providers of the scope configurations refer to, along with its
+// capabilities
+func RegisterBackend(name string, init libkv.Initialize, capability BackendCapability) error {
+	if name == "" || init == nil {
+		return types.BadRequestErrorf("invalid store backend registration: missing name or initializer")
+	}
+	for _, s := range capability.Scopes {
+		if s != LocalScope && s != GlobalScope {
+			return types.BadRequestErrorf("invalid scope %q of store backend %s", s, name)
+		}
+	}
+	if capability.servesScope(GlobalScope) && !capability.AtomicOps {
+		return types.BadRequestErrorf("store backend %s serving the %s scope must support atomic operations", name, GlobalScope)
+	}
+
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[name]; ok {
+		return types.ForbiddenErrorf("store backend %s already registered", name)
+	}
+	c := capability
+	c.Scopes = append([]string(nil), capability.Scopes...)
+	backends[name] = &c
+	libkv.AddStore(store.Backend(name), init)
+	return nil
+}
+
+// GetBackendCapability returns the capabilities of the custom store
+// backend, false if none was registered with the name
+func GetBackendCapability(name string) (BackendCapability, bool) {
+	c := backendCapability(name)
+	if c == nil {
+		return BackendCapability{}, false
+	}
+	return *c, true
+}
+
+func backendCapability(name string) *BackendCapability {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	return backends[name]
+}
+
+func (c *BackendCapability) servesScope(scope string) bool {
+	if len(c.Scopes) == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -1,27 +1,56 @@
 package datastore
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/docker/libkv/store"
 )
 
+// errCacheMiss is returned by the watched caches for the objects they do
+// not hold, which are read from the store
+var errCacheMiss = errors.New("object not in cache")
+
 type kvMap map[string]KVObject
 
 type cache struct {
 	sync.Mutex
 	kmm map[string]kvMap
 	ds  *datastore
+	// The watched caches front the global scope stores, each map being
+	// kept up to date by a watch of its prefix. They are disabled if the
+	// store turns out not to support watches.
+	watched  bool
+	disabled bool
+	fillMu   sync.Mutex
+	stopCh   chan struct{}
+	// the stop channels of the watches of the prefixes
+	watches map[string]chan struct{}
 }
 
 func newCache(ds *datastore) *cache {
 	return &cache{kmm: make(map[string]kvMap), ds: ds}
 }
 
+func newWatchedCache(ds *datastore) *cache {
+	return &cache{
+		kmm:     make(map[string]kvMap),
+		ds:      ds,
+		watched: true,
+		stopCh:  make(chan struct{}),
+		watches: make(map[string]chan struct{}),
+	}
+}
+
 func (c *cache) kmap(kvObject KVObject) (kvMap, error) {
 	var err error
 
+	if c.watched {
+		return c.watchedKmap(kvObject)
+	}
+
 	c.Lock()
 	keyPrefix := Key(kvObject.KeyPrefix()...)
 	kmap, ok := c.kmm[keyPrefix]
@@ -86,12 +115,223 @@ out:
 	return kmap, nil
 }
 
+// watchedKmap returns the map of the prefix of the object, populating it
+// from the first list the watch of the prefix sends. On failure the
+// caller reads the store.
+func (c *cache) watchedKmap(kvObject KVObject) (kvMap, error) {
+	keyPrefix := Key(kvObject.KeyPrefix()...)
+
+	c.Lock()
+	kmap, ok := c.kmm[keyPrefix]
+	c.Unlock()
+	if ok {
+		return kmap, nil
+	}
+
+	// a single watch per prefix
+	c.fillMu.Lock()
+	defer c.fillMu.Unlock()
+
+	c.Lock()
+	kmap, ok = c.kmm[keyPrefix]
+	c.Unlock()
+	if ok {
+		return kmap, nil
+	}
+
+	// no watch until the store is back
+	if c.ds.unreachable {
+		return nil, errCacheMiss
+	}
+
+	// the watches of some stores fail on a missing prefix
+	if err := c.ds.ensureParent(keyPrefix); err != nil {
+		if isUnreachable(err) {
+			c.ds.markUnreachable()
+		}
+		return nil, errCacheMiss
+	}
+	stopCh := make(chan struct{})
+	listCh, err := c.ds.store.WatchTree(keyPrefix, stopCh)
+	if err != nil {
+		if err == store.ErrCallNotSupported {
+			c.Lock()
+			c.disabled = true
+			c.Unlock()
+		}
+		if isUnreachable(err) {
+			c.ds.markUnreachable()
+		}
+		return nil, errCacheMiss
+	}
+	list, ok := <-listCh
+	if !ok {
+		close(stopCh)
+		return nil, errCacheMiss
+	}
+
+	ctor := kvObject.(KVConstructor)
+	kmap = newKvMap(ctor, list)
+	c.Lock()
+	c.kmm[keyPrefix] = kmap
+	c.watches[keyPrefix] = stopCh
+	c.Unlock()
+
+	go c.watchLoop(keyPrefix, ctor, listCh, stopCh)
+
+	return kmap, nil
+}
+
+// watchLoop refreshes the map of the prefix with the lists the watch sends
+// until the watch is stopped, or the cache closed
+func (c *cache) watchLoop(keyPrefix string, ctor KVConstructor, listCh <-chan []*store.KVPair, stopCh chan struct{}) {
+	for {
+		select {
+		case list, ok := <-listCh:
+			if !ok {
+				c.watchFailed(keyPrefix, stopCh)
+				return
+			}
+			c.refresh(keyPrefix, newKvMap(ctor, list))
+		case <-stopCh:
+			return
+		case <-c.stopCh:
+			c.stopWatch(keyPrefix, stopCh)
+			return
+		}
+	}
+}
+
+// watchFailed handles the end of the watch of the prefix. The map is kept,
+// stale, while the store is unreachable, to be dropped once it is back.
+func (c *cache) watchFailed(keyPrefix string, stopCh chan struct{}) {
+	if _, err := c.ds.store.Exists(keyPrefix); isUnreachable(err) {
+		c.ds.Lock()
+		c.ds.markUnreachable()
+		c.ds.Unlock()
+		return
+	}
+	c.stopWatch(keyPrefix, stopCh)
+}
+
+// stopWatch drops the map of the prefix, stopping its watch if it is still
+// the given one
+func (c *cache) stopWatch(keyPrefix string, stopCh chan struct{}) {
+	c.Lock()
+	defer c.Unlock()
+	if c.watches[keyPrefix] != stopCh {
+		return
+	}
+	delete(c.watches, keyPrefix)
+	delete(c.kmm, keyPrefix)
+	close(stopCh)
+}
+
+// resync drops all the maps, stopping their watches, for them to be read
+// from the store again
+func (c *cache) resync() {
+	c.fillMu.Lock()
+	defer c.fillMu.Unlock()
+	c.Lock()
+	defer c.Unlock()
+	for keyPrefix, stopCh := range c.watches {
+		delete(c.kmm, keyPrefix)
+		close(stopCh)
+	}
+	c.watches = make(map[string]chan struct{})
+}
+
+// refresh replaces the content of the map of the prefix with the objects
+// read from the store, but for the ones the node updated since
+func (c *cache) refresh(keyPrefix string, fresh kvMap) {
+	c.Lock()
+	defer c.Unlock()
+
+	kmap, ok := c.kmm[keyPrefix]
+	if !ok {
+		return
+	}
+	for k, o := range kmap {
+		if n, ok := fresh[k]; ok && o.Index() > n.Index() {
+			fresh[k] = o
+		}
+	}
+	for k := range kmap {
+		delete(kmap, k)
+	}
+	for k, o := range fresh {
+		kmap[k] = o
+	}
+}
+
+// newKvMap returns the map of the objects of the pairs, the ones failing
+// to decode aside
+func newKvMap(ctor KVConstructor, list []*store.KVPair) kvMap {
+	kmap := kvMap{}
+	for _, kvPair := range list {
+		if len(kvPair.Value) == 0 {
+			continue
+		}
+		dstO := ctor.New()
+		if err := dstO.SetValue(kvPair.Value); err != nil {
+			log.Warnf("Failed to decode the value of key %s to cache: %v", kvPair.Key, err)
+			continue
+		}
+		dstO.SetIndex(kvPair.LastIndex)
+		kmap[Key(dstO.Key()...)] = dstO
+	}
+	return kmap
+}
+
+// serves returns whether the cache serves the object
+func (c *cache) serves(kvObject KVObject) bool {
+	if !c.watched {
+		return true
+	}
+	if _, ok := kvObject.(KVConstructor); !ok || kvObject.Skip() {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+	return !c.disabled
+}
+
+// evict drops the object from the watched cache, so that it is read from
+// the store until the watch refreshes it
+func (c *cache) evict(kvObject KVObject) {
+	keyPrefix := Key(kvObject.KeyPrefix()...)
+	c.Lock()
+	if kmap, ok := c.kmm[keyPrefix]; ok {
+		delete(kmap, Key(kvObject.Key()...))
+	}
+	c.Unlock()
+}
+
+func (c *cache) close() {
+	if c.watched {
+		close(c.stopCh)
+	}
+}
+
 func (c *cache) add(kvObject KVObject) error {
 	kmap, err := c.kmap(kvObject)
+	if err == errCacheMiss {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	// the watched caches keep a copy not to share the object with the
+	// caller and the watch
+	if c.watched {
+		dstO := kvObject.(KVConstructor).New()
+		if err := kvObject.(KVConstructor).CopyTo(dstO); err != nil {
+			return err
+		}
+		kvObject = dstO
+	}
+
 	c.Lock()
 	kmap[Key(kvObject.Key()...)] = kvObject
 	c.Unlock()
@@ -100,6 +340,9 @@ func (c *cache) add(kvObject KVObject) error {
 
 func (c *cache) del(kvObject KVObject) error {
 	kmap, err := c.kmap(kvObject)
+	if err == errCacheMiss {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -121,6 +364,9 @@ func (c *cache) get(key string, kvObject KVObject) error {
 
 	o, ok := kmap[Key(kvObject.Key()...)]
 	if !ok {
+		if c.watched {
+			return errCacheMiss
+		}
 		return ErrKeyNotFound
 	}
 
@@ -143,6 +389,14 @@ func (c *cache) list(kvObject KVObject) ([]KVObject, error) {
 
 	var kvol []KVObject
 	for _, v := range kmap {
+		// the objects of the watched caches are shared with the watch
+		if c.watched {
+			dstO := v.(KVConstructor).New()
+			if err := v.(KVConstructor).CopyTo(dstO); err != nil {
+				return nil, err
+			}
+			v = dstO
+		}
 		kvol = append(kvol, v)
 	}
 
@@ -25,6 +25,9 @@ type DataStore interface {
 	DeleteObject(kvObject KVObject) error
 	// DeleteObjectAtomic performs an atomic delete operation
 	DeleteObjectAtomic(kvObject KVObject) error
+	// Transaction performs the atomic puts and deletes of the operations
+	// all at once
+	Transaction(ops ...TxnOp) error
 	// DeleteTree deletes a record
 	DeleteTree(kvObject KVObject) error
 	// Watchable returns whether the store is watchable or not
@@ -59,6 +62,12 @@ type datastore struct {
 	cache   *cache
 	watchCh chan struct{}
 	active  bool
+	// capabilities of the custom backend of the store, nil for the libkv
+	// ones
+	caps *BackendCapability
+	// whether the store is unreachable, and the writes queued meanwhile
+	unreachable bool
+	pending     []*pendingWrite
 	sync.Mutex
 }
 
@@ -105,6 +114,18 @@ type ScopeClientCfg struct {
 	Provider string
 	Address  string
 	Config   *store.Config
+	// TLS client certificate and key, and CA bundle verifying the store
+	CertFile   string
+	KeyFile    string
+	CACertFile string
+	// Credentials of the etcd stores
+	Username string
+	Password string
+	// ACL token of the consul stores
+	Token string
+	// Prefix namespaces the keys of the scope in the store, for several
+	// deployments to share a KV cluster
+	Prefix string
 }
 
 const (
@@ -185,12 +206,17 @@ func ParseKey(key string) ([]string, error) {
 }
 
 // newClient used to connect to KV Store
-func newClient(scope string, kv string, addr string, config *store.Config, cached bool) (DataStore, error) {
+func newClient(scope string, kv string, addr string, config *store.Config, token string, prefix string, cached bool) (DataStore, error) {
 
 	if cached && scope != LocalScope {
 		return nil, fmt.Errorf("caching supported only for scope %s", LocalScope)
 	}
 
+	caps := backendCapability(kv)
+	if caps != nil && !caps.servesScope(scope) {
+		return nil, fmt.Errorf("store backend %s does not serve the %s scope", kv, scope)
+	}
+
 	if config == nil {
 		config = &store.Config{}
 	}
@@ -211,14 +237,22 @@ func newClient(scope string, kv string, addr string, config *store.Config, cache
 		}
 	}
 
+	consul := kv == string(store.CONSUL)
 	store, err := libkv.NewStore(store.Backend(kv), addrs, config)
 	if err != nil {
 		return nil, err
 	}
+	if consul {
+		setConsulToken(addrs, token)
+	}
 
-	ds := &datastore{scope: scope, store: store, active: true, watchCh: make(chan struct{})}
+	ds := &datastore{scope: scope, store: newPrefixedStore(store, prefix), active: true, watchCh: make(chan struct{}), caps: caps}
 	if cached {
 		ds.cache = newCache(ds)
+	} else if scope != LocalScope && (caps == nil || caps.Watch) {
+		// the objects of the global scope are read through a cache the
+		// watches of the store keep up to date
+		ds.cache = newWatchedCache(ds)
 	}
 
 	return ds, nil
@@ -240,7 +274,12 @@ func NewDataStore(scope string, cfg *ScopeCfg) (DataStore, error) {
 		cached = true
 	}
 
-	return newClient(scope, cfg.Client.Provider, cfg.Client.Address, cfg.Client.Config, cached)
+	config, err := cfg.Client.StoreConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(scope, cfg.Client.Provider, cfg.Client.Address, config, cfg.Client.Token, cfg.Client.Prefix, cached)
 }
 
 // NewDataStoreFromConfig creates a new instance of LibKV data store starting from the datastore config data
@@ -260,6 +299,7 @@ func NewDataStoreFromConfig(dsc discoverapi.DatastoreConfigData) (DataStore, err
 			Address:  dsc.Address,
 			Provider: dsc.Provider,
 			Config:   sCfgP,
+			Prefix:   dsc.Prefix,
 		},
 	}
 
@@ -272,9 +312,20 @@ func NewDataStoreFromConfig(dsc discoverapi.DatastoreConfigData) (DataStore, err
 }
 
 func (ds *datastore) Close() {
+	if ds.cache != nil {
+		ds.cache.close()
+	}
 	ds.store.Close()
 }
 
+// cached returns the cache serving the object, if any
+func (ds *datastore) cached(kvObject KVObject) *cache {
+	if ds.cache == nil || !ds.cache.serves(kvObject) {
+		return nil
+	}
+	return ds.cache
+}
+
 func (ds *datastore) Scope() string {
 	return ds.scope
 }
@@ -284,10 +335,14 @@ func (ds *datastore) Active() bool {
 }
 
 func (ds *datastore) Watchable() bool {
-	return ds.scope != LocalScope
+	return ds.scope != LocalScope && (ds.caps == nil || ds.caps.Watch)
 }
 
 func (ds *datastore) Watch(kvObject KVObject, stopCh <-chan struct{}) (<-chan KVObject, error) {
+	if ds.caps != nil && !ds.caps.Watch {
+		return nil, types.NotImplementedErrorf("store of the %s scope does not support watches", ds.scope)
+	}
+
 	sCh := make(chan struct{})
 
 	ctor, ok := kvObject.(KVConstructor)
@@ -358,6 +413,10 @@ func (ds *datastore) RestartWatch() {
 	ds.Lock()
 	defer ds.Unlock()
 
+	ds.restartWatch()
+}
+
+func (ds *datastore) restartWatch() {
 	ds.active = true
 	watchCh := ds.watchCh
 	ds.watchCh = make(chan struct{})
@@ -370,13 +429,19 @@ func (ds *datastore) KVStore() store.Store {
 
 // PutObjectAtomic adds a new Record based on an object into the datastore
 func (ds *datastore) PutObjectAtomic(kvObject KVObject) error {
+	ds.Lock()
+	defer ds.Unlock()
+
+	return ds.putObjectAtomic(kvObject)
+}
+
+func (ds *datastore) putObjectAtomic(kvObject KVObject) error {
 	var (
 		previous *store.KVPair
 		pair     *store.KVPair
+		w        *pendingWrite
 		err      error
 	)
-	ds.Lock()
-	defer ds.Unlock()
 
 	if kvObject == nil {
 		return types.BadRequestErrorf("invalid KV Object : nil")
@@ -398,8 +463,27 @@ func (ds *datastore) PutObjectAtomic(kvObject KVObject) error {
 		previous = nil
 	}
 
-	_, pair, err = ds.store.AtomicPut(Key(kvObject.Key()...), kvObjValue, previous, nil)
-	if err != nil {
+	w = &pendingWrite{op: opPutAtomic, key: Key(kvObject.Key()...), value: kvObjValue, previous: previous}
+	if ds.queued(w, nil) {
+		goto add_cache
+	}
+
+	if ds.caps != nil && !ds.caps.AtomicOps {
+		// the datastore lock serializes the updates of the local scope
+		if err = ds.store.Put(Key(kvObject.Key()...), kvObjValue, nil); err != nil {
+			return err
+		}
+		if pair, err = ds.store.Get(Key(kvObject.Key()...)); err != nil {
+			return err
+		}
+	} else if _, pair, err = ds.store.AtomicPut(Key(kvObject.Key()...), kvObjValue, previous, nil); err != nil {
+		if ds.queued(w, err) {
+			goto add_cache
+		}
+		// the cached object is stale
+		if c := ds.cached(kvObject); c != nil && c.watched {
+			c.evict(kvObject)
+		}
 		if err == store.ErrKeyExists {
 			return ErrKeyModified
 		}
@@ -409,8 +493,8 @@ func (ds *datastore) PutObjectAtomic(kvObject KVObject) error {
 	kvObject.SetIndex(pair.LastIndex)
 
 add_cache:
-	if ds.cache != nil {
-		return ds.cache.add(kvObject)
+	if c := ds.cached(kvObject); c != nil {
+		return c.add(kvObject)
 	}
 
 	return nil
@@ -425,17 +509,18 @@ func (ds *datastore) PutObject(kvObject KVObject) error {
 		return types.BadRequestErrorf("invalid KV Object : nil")
 	}
 
-	if kvObject.Skip() {
+	w := &pendingWrite{op: opPut, key: Key(kvObject.Key()...), value: kvObject.Value()}
+	if kvObject.Skip() || w.value != nil && ds.queued(w, nil) {
 		goto add_cache
 	}
 
-	if err := ds.putObjectWithKey(kvObject, kvObject.Key()...); err != nil {
+	if err := ds.putObjectWithKey(kvObject, kvObject.Key()...); err != nil && !ds.queued(w, err) {
 		return err
 	}
 
 add_cache:
-	if ds.cache != nil {
-		return ds.cache.add(kvObject)
+	if c := ds.cached(kvObject); c != nil {
+		return c.add(kvObject)
 	}
 
 	return nil
@@ -455,8 +540,10 @@ func (ds *datastore) GetObject(key string, o KVObject) error {
 	ds.Lock()
 	defer ds.Unlock()
 
-	if ds.cache != nil {
-		return ds.cache.get(key, o)
+	if c := ds.cached(o); c != nil {
+		if err := c.get(key, o); err != errCacheMiss {
+			return err
+		}
 	}
 
 	kvPair, err := ds.store.Get(key)
@@ -489,8 +576,10 @@ func (ds *datastore) List(key string, kvObject KVObject) ([]KVObject, error) {
 	ds.Lock()
 	defer ds.Unlock()
 
-	if ds.cache != nil {
-		return ds.cache.list(kvObject)
+	if c := ds.cached(kvObject); c != nil {
+		if kvol, err := c.list(kvObject); err != errCacheMiss {
+			return kvol, err
+		}
 	}
 
 	// Bail out right away if the kvObject does not implement KVConstructor
@@ -536,15 +625,22 @@ func (ds *datastore) DeleteObject(kvObject KVObject) error {
 	defer ds.Unlock()
 
 	// cleaup the cache first
-	if ds.cache != nil {
-		ds.cache.del(kvObject)
+	if c := ds.cached(kvObject); c != nil {
+		c.del(kvObject)
 	}
 
 	if kvObject.Skip() {
 		return nil
 	}
 
-	return ds.store.Delete(Key(kvObject.Key()...))
+	w := &pendingWrite{op: opDelete, key: Key(kvObject.Key()...)}
+	if ds.queued(w, nil) {
+		return nil
+	}
+	if err := ds.store.Delete(Key(kvObject.Key()...)); !ds.queued(w, err) {
+		return err
+	}
+	return nil
 }
 
 // DeleteObjectAtomic performs atomic delete on a record
@@ -552,17 +648,32 @@ func (ds *datastore) DeleteObjectAtomic(kvObject KVObject) error {
 	ds.Lock()
 	defer ds.Unlock()
 
+	return ds.deleteObjectAtomic(kvObject)
+}
+
+func (ds *datastore) deleteObjectAtomic(kvObject KVObject) error {
 	if kvObject == nil {
 		return types.BadRequestErrorf("invalid KV Object : nil")
 	}
 
 	previous := &store.KVPair{Key: Key(kvObject.Key()...), LastIndex: kvObject.Index()}
+	w := &pendingWrite{op: opDeleteAtomic, key: previous.Key, previous: previous}
 
-	if kvObject.Skip() {
+	if kvObject.Skip() || ds.queued(w, nil) {
 		goto del_cache
 	}
 
-	if _, err := ds.store.AtomicDelete(Key(kvObject.Key()...), previous); err != nil {
+	if ds.caps != nil && !ds.caps.AtomicOps {
+		if err := ds.store.Delete(Key(kvObject.Key()...)); err != nil {
+			return err
+		}
+	} else if _, err := ds.store.AtomicDelete(Key(kvObject.Key()...), previous); err != nil {
+		if ds.queued(w, err) {
+			goto del_cache
+		}
+		if c := ds.cached(kvObject); c != nil && c.watched {
+			c.evict(kvObject)
+		}
 		if err == store.ErrKeyExists {
 			return ErrKeyModified
 		}
@@ -571,8 +682,8 @@ func (ds *datastore) DeleteObjectAtomic(kvObject KVObject) error {
 
 del_cache:
 	// cleanup the cache only if AtomicDelete went through successfully
-	if ds.cache != nil {
-		return ds.cache.del(kvObject)
+	if c := ds.cached(kvObject); c != nil {
+		return c.del(kvObject)
 	}
 
 	return nil
@@ -584,13 +695,20 @@ func (ds *datastore) DeleteTree(kvObject KVObject) error {
 	defer ds.Unlock()
 
 	// cleaup the cache first
-	if ds.cache != nil {
-		ds.cache.del(kvObject)
+	if c := ds.cached(kvObject); c != nil {
+		c.del(kvObject)
 	}
 
 	if kvObject.Skip() {
 		return nil
 	}
 
-	return ds.store.DeleteTree(Key(kvObject.KeyPrefix()...))
+	w := &pendingWrite{op: opDeleteTree, key: Key(kvObject.KeyPrefix()...)}
+	if ds.queued(w, nil) {
+		return nil
+	}
+	if err := ds.store.DeleteTree(Key(kvObject.KeyPrefix()...)); !ds.queued(w, err) {
+		return err
+	}
+	return nil
 }
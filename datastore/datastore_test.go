@@ -2,9 +2,15 @@ package datastore
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/docker/libkv/store"
 	"github.com/docker/libnetwork/options"
 	_ "github.com/docker/libnetwork/testutils"
 	"github.com/stretchr/testify/assert"
@@ -165,6 +171,15 @@ func (n *dummyObject) Skip() bool {
 	return n.SkipSave
 }
 
+func (n *dummyObject) New() KVObject {
+	return &dummyObject{}
+}
+
+func (n *dummyObject) CopyTo(o KVObject) error {
+	*o.(*dummyObject) = *n
+	return nil
+}
+
 func (n *dummyObject) DataScope() string {
 	return LocalScope
 }
@@ -252,3 +267,495 @@ func dummyKVObject(id string, retValue bool) *dummyObject {
 	n.Generic = generic
 	return &n
 }
+
+// listableStore is a mock store listing no key, for the datastore caches
+type listableStore struct {
+	*MockStore
+}
+
+func (s *listableStore) List(prefix string) ([]*store.KVPair, error) {
+	return nil, store.ErrKeyNotFound
+}
+
+func TestRegisterBackend(t *testing.T) {
+	init := func(addrs []string, options *store.Config) (store.Store, error) {
+		return &listableStore{NewMockStore()}, nil
+	}
+
+	if err := RegisterBackend("test-global", init, BackendCapability{}); err == nil {
+		t.Fatal("Expected failure registering a global backend without atomic operations")
+	}
+	if err := RegisterBackend("test-local", init, BackendCapability{Scopes: []string{"cluster"}}); err == nil {
+		t.Fatal("Expected failure registering a backend with an invalid scope")
+	}
+	if err := RegisterBackend("test-local", init, BackendCapability{TTL: true, Scopes: []string{LocalScope}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterBackend("test-local", init, BackendCapability{Scopes: []string{LocalScope}}); err == nil {
+		t.Fatal("Expected failure registering a backend twice")
+	}
+	if c, ok := GetBackendCapability("test-local"); !ok || !c.TTL || c.AtomicOps {
+		t.Fatalf("Unexpected capability: %+v", c)
+	}
+
+	cfg := &ScopeCfg{Client: ScopeClientCfg{Provider: "test-local", Address: "local"}}
+	if _, err := NewDataStore(GlobalScope, cfg); err == nil {
+		t.Fatal("Expected failure serving the global scope with a local backend")
+	}
+	ds, err := NewDataStore(LocalScope, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Watchable() {
+		t.Fatal("Expected the store not to be watchable")
+	}
+	if _, err := ds.Watch(dummyKVObject("1", true), nil); err == nil {
+		t.Fatal("Expected failure watching a store without watches")
+	}
+
+	// the atomic operations fall back to the plain ones
+	o := dummyKVObject("2000", true)
+	if err := ds.PutObjectAtomic(o); err != nil {
+		t.Fatal(err)
+	}
+	if !o.Exists() {
+		t.Fatal("Expected the object to exist")
+	}
+	if err := ds.DeleteObjectAtomic(o); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := ds.KVStore().Exists(Key(o.Key()...)); ok {
+		t.Fatal("Expected the object to be deleted")
+	}
+}
+
+// watchedObject is an object whose value carries its key, as the objects
+// of the global scope do
+type watchedObject struct {
+	ID      string
+	Data    string
+	dbIndex uint64
+	exists  bool
+}
+
+func (o *watchedObject) Key() []string       { return []string{"watched", o.ID} }
+func (o *watchedObject) KeyPrefix() []string { return []string{"watched"} }
+func (o *watchedObject) Value() []byte {
+	b, _ := json.Marshal(o)
+	return b
+}
+func (o *watchedObject) SetValue(value []byte) error { return json.Unmarshal(value, o) }
+func (o *watchedObject) Index() uint64               { return o.dbIndex }
+func (o *watchedObject) SetIndex(index uint64) {
+	o.dbIndex = index
+	o.exists = true
+}
+func (o *watchedObject) Exists() bool      { return o.exists }
+func (o *watchedObject) Skip() bool        { return false }
+func (o *watchedObject) DataScope() string { return GlobalScope }
+func (o *watchedObject) New() KVObject     { return &watchedObject{} }
+func (o *watchedObject) CopyTo(dst KVObject) error {
+	*dst.(*watchedObject) = *o
+	return nil
+}
+
+// watchStore is a mock store whose tree watches the test triggers, counting
+// the reads of the keys
+type watchStore struct {
+	*MockStore
+	sync.Mutex
+	gets    int
+	watchCh chan []*store.KVPair
+}
+
+func (s *watchStore) Get(key string) (*store.KVPair, error) {
+	s.Lock()
+	s.gets++
+	s.Unlock()
+	if _, ok := s.db[key]; !ok {
+		return nil, store.ErrKeyNotFound
+	}
+	return s.MockStore.Get(key)
+}
+
+func (s *watchStore) List(prefix string) ([]*store.KVPair, error) {
+	var list []*store.KVPair
+	for k, d := range s.db {
+		if strings.HasPrefix(k, prefix) && k != prefix {
+			list = append(list, &store.KVPair{Key: k, Value: d.Data, LastIndex: d.Index})
+		}
+	}
+	return list, nil
+}
+
+func (s *watchStore) WatchTree(prefix string, stopCh <-chan struct{}) (<-chan []*store.KVPair, error) {
+	list, _ := s.List(prefix)
+	s.watchCh = make(chan []*store.KVPair, 1)
+	s.watchCh <- list
+	return s.watchCh, nil
+}
+
+func TestWatchedCache(t *testing.T) {
+	ws := &watchStore{MockStore: NewMockStore()}
+	init := func(addrs []string, options *store.Config) (store.Store, error) {
+		return ws, nil
+	}
+	if err := RegisterBackend("test-watch", init, BackendCapability{Watch: true, AtomicOps: true}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ScopeCfg{Client: ScopeClientCfg{Provider: "test-watch", Address: "global"}}
+	ds, err := NewDataStore(GlobalScope, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+
+	o := &watchedObject{ID: "1", Data: "v1"}
+	if err := ds.PutObjectAtomic(o); err != nil {
+		t.Fatal(err)
+	}
+	key := Key(o.Key()...)
+
+	get := func() (*watchedObject, error) {
+		n := &watchedObject{ID: "1"}
+		return n, ds.GetObject(key, n)
+	}
+	if n, err := get(); err != nil || n.Data != "v1" || n.Index() != o.Index() {
+		t.Fatalf("Unexpected object %+v: %v", n, err)
+	}
+	if ws.gets != 0 {
+		t.Fatalf("Expected the object to be read from the cache, got %d reads", ws.gets)
+	}
+
+	// another node updates the object
+	ws.db[key] = &MockData{Data: (&watchedObject{ID: "1", Data: "v2"}).Value(), Index: 10}
+	list, _ := ws.List(Key("watched"))
+	ws.watchCh <- list
+	for i := 0; ; i++ {
+		n, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Data == "v2" && n.Index() == 10 && ws.gets == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("Expected the cache to be refreshed, got %+v", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	kvol, err := ds.List(Key("watched"), &watchedObject{})
+	if err != nil || len(kvol) != 1 {
+		t.Fatalf("Unexpected list %v: %v", kvol, err)
+	}
+	kvol[0].(*watchedObject).Data = "changed"
+	if n, _ := get(); n.Data != "v2" {
+		t.Fatal("Expected the listed objects not to be shared with the cache")
+	}
+
+	// and deletes it
+	delete(ws.db, key)
+	ws.watchCh <- nil
+	for i := 0; ; i++ {
+		if _, err := get(); err == store.ErrKeyNotFound {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Expected the deleted object to be evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// flakyStore is a mock store the test takes down, failing the calls and the
+// watches until it is back
+type flakyStore struct {
+	*MockStore
+	sync.Mutex
+	down    bool
+	watches []chan []*store.KVPair
+}
+
+func (s *flakyStore) setDown(down bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.down = down
+	if down {
+		for _, ch := range s.watches {
+			close(ch)
+		}
+		s.watches = nil
+	}
+}
+
+func (s *flakyStore) set(key string, value []byte, index uint64) {
+	s.Lock()
+	s.db[key] = &MockData{Data: value, Index: index}
+	s.Unlock()
+}
+
+func (s *flakyStore) has(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.db[key]
+	return ok
+}
+
+func (s *flakyStore) Get(key string) (*store.KVPair, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return nil, store.ErrNotReachable
+	}
+	if _, ok := s.db[key]; !ok {
+		return nil, store.ErrKeyNotFound
+	}
+	return s.MockStore.Get(key)
+}
+
+func (s *flakyStore) Put(key string, value []byte, options *store.WriteOptions) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return store.ErrNotReachable
+	}
+	return s.MockStore.Put(key, value, options)
+}
+
+func (s *flakyStore) Exists(key string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return false, store.ErrNotReachable
+	}
+	return s.MockStore.Exists(key)
+}
+
+func (s *flakyStore) list(prefix string) []*store.KVPair {
+	var list []*store.KVPair
+	for k, d := range s.db {
+		if strings.HasPrefix(k, prefix) && k != prefix {
+			list = append(list, &store.KVPair{Key: k, Value: d.Data, LastIndex: d.Index})
+		}
+	}
+	return list
+}
+
+func (s *flakyStore) List(prefix string) ([]*store.KVPair, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return nil, store.ErrNotReachable
+	}
+	return s.list(prefix), nil
+}
+
+func (s *flakyStore) WatchTree(prefix string, stopCh <-chan struct{}) (<-chan []*store.KVPair, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return nil, store.ErrNotReachable
+	}
+	ch := make(chan []*store.KVPair, 1)
+	ch <- s.list(prefix)
+	s.watches = append(s.watches, ch)
+	return ch, nil
+}
+
+func (s *flakyStore) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return false, nil, store.ErrNotReachable
+	}
+	return s.MockStore.AtomicPut(key, value, previous, options)
+}
+
+func TestStoreOutage(t *testing.T) {
+	fs := &flakyStore{MockStore: NewMockStore()}
+	init := func(addrs []string, options *store.Config) (store.Store, error) {
+		return fs, nil
+	}
+	if err := RegisterBackend("test-flaky", init, BackendCapability{Watch: true, AtomicOps: true}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ScopeCfg{Client: ScopeClientCfg{Provider: "test-flaky", Address: "global"}}
+	ds, err := NewDataStore(GlobalScope, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+
+	o1 := &watchedObject{ID: "1", Data: "v1"}
+	if err := ds.PutObjectAtomic(o1); err != nil {
+		t.Fatal(err)
+	}
+
+	fs.setDown(true)
+
+	// the writes are queued, and served from the cache
+	o2 := &watchedObject{ID: "2", Data: "v1"}
+	if err := ds.PutObjectAtomic(o2); err != nil {
+		t.Fatalf("Expected the write to be queued: %v", err)
+	}
+	n := &watchedObject{ID: "2"}
+	if err := ds.GetObject(Key(o2.Key()...), n); err != nil || n.Data != "v1" {
+		t.Fatalf("Unexpected object %+v: %v", n, err)
+	}
+	if ds.Active() {
+		t.Fatal("Expected the store to be inactive")
+	}
+
+	// another node updates the first object meanwhile
+	fs.set(Key(o1.Key()...), (&watchedObject{ID: "1", Data: "v2"}).Value(), 10)
+	fs.setDown(false)
+
+	for i := 0; !fs.has(Key(o2.Key()...)); i++ {
+		if i == 300 {
+			t.Fatal("Expected the queued write to be replayed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n = &watchedObject{ID: "1"}
+	if err := ds.GetObject(Key(o1.Key()...), n); err != nil || n.Data != "v2" || n.Index() != 10 {
+		t.Fatalf("Expected the cache to be resynced, got %+v: %v", n, err)
+	}
+	if !ds.Active() {
+		t.Fatal("Expected the store to be active again")
+	}
+}
+
+// noTxnStore hides the transactions of the mock store
+type noTxnStore struct {
+	store.Store
+}
+
+func TestTransaction(t *testing.T) {
+	for _, s := range []store.Store{NewMockStore(), noTxnStore{NewMockStore()}} {
+		ds := &datastore{scope: LocalScope, store: s}
+
+		o1 := &watchedObject{ID: "1", Data: "v1"}
+		o2 := &watchedObject{ID: "2", Data: "v1"}
+		if err := ds.Transaction(TxnOp{Object: o1}, TxnOp{Object: o2}); err != nil {
+			t.Fatal(err)
+		}
+		if !o1.Exists() || !o2.Exists() {
+			t.Fatalf("%T: Expected the objects to exist", s)
+		}
+
+		// the creation of the first object is rolled back as the second
+		// one is stale
+		o3 := &watchedObject{ID: "3", Data: "v1"}
+		stale := &watchedObject{ID: "2", Data: "v2"}
+		if err := ds.Transaction(TxnOp{Object: o3}, TxnOp{Object: stale}); err == nil {
+			t.Fatalf("%T: Expected failure writing a stale object", s)
+		}
+		if ok, _ := s.Exists(Key(o3.Key()...)); ok {
+			t.Fatalf("%T: Expected the first write to be rolled back", s)
+		}
+
+		if err := ds.Transaction(TxnOp{Object: o1, Delete: true}, TxnOp{Object: o2, Delete: true}); err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range []*watchedObject{o1, o2} {
+			if ok, _ := s.Exists(Key(o.Key()...)); ok {
+				t.Fatalf("%T: Expected the object %s to be deleted", s, o.ID)
+			}
+		}
+	}
+}
+
+func TestClientSecurity(t *testing.T) {
+	for _, cfg := range []ScopeClientCfg{
+		{Provider: "zk", CACertFile: "/ca.pem"},
+		{Provider: "consul", Username: "user"},
+		{Provider: "etcd", Token: "token"},
+		{Provider: "etcd", CertFile: "/cert.pem"},
+		{Provider: "etcd", Password: "secret"},
+	} {
+		if _, err := cfg.StoreConfig(); err == nil {
+			t.Fatalf("Expected failure validating %+v", cfg)
+		}
+	}
+
+	cfg := ScopeClientCfg{Provider: "etcd", Config: &store.Config{Bucket: "libnetwork"}, Username: "user", Password: "secret"}
+	config, err := cfg.StoreConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Bucket != "libnetwork" || config.Username != "user" || config.Password != "secret" {
+		t.Fatalf("Unexpected store configuration %+v", config)
+	}
+	if cfg.Config.Username != "" {
+		t.Fatal("Expected the configuration of the client to be left")
+	}
+
+	// the consul requests carry the token of the server
+	var token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.URL.Query().Get("token")
+	}))
+	defer srv.Close()
+	transport := http.DefaultClient.Transport
+	defer func() {
+		http.DefaultClient.Transport = transport
+		consulTokensMu.Lock()
+		consulTokens = make(map[string]string)
+		consulTokensMu.Unlock()
+	}()
+
+	setConsulToken([]string{strings.TrimPrefix(srv.URL, "http://")}, "secret")
+	resp, err := http.DefaultClient.Get(srv.URL + "/v1/kv/docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if token != "secret" {
+		t.Fatalf("Unexpected token %q", token)
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	ws := &watchStore{MockStore: NewMockStore()}
+	ds := &datastore{scope: GlobalScope, store: newPrefixedStore(ws, "/prod/docker/network/")}
+
+	o1 := &watchedObject{ID: "1", Data: "v1"}
+	o2 := &watchedObject{ID: "2", Data: "v1"}
+	if err := ds.Transaction(TxnOp{Object: o1}, TxnOp{Object: o2}); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []*watchedObject{o1, o2} {
+		if ok, _ := ws.Exists("prod/docker/network/" + Key(o.Key()...)); !ok {
+			t.Fatalf("Expected the object %s to be stored under the prefix", o.ID)
+		}
+		if ok, _ := ws.Exists(Key(o.Key()...)); ok {
+			t.Fatalf("Expected the object %s not to be stored out of the prefix", o.ID)
+		}
+	}
+
+	n := &watchedObject{ID: "2"}
+	if err := ds.GetObject(Key(o2.Key()...), n); err != nil || n.Data != "v1" {
+		t.Fatalf("Unexpected object read: %v, %v", n, err)
+	}
+	kvol, err := ds.List(Key("watched"), &watchedObject{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvol) != 2 {
+		t.Fatalf("Expected 2 objects listed, got %d", len(kvol))
+	}
+
+	// another deployment sharing the store does not see the objects
+	other := &datastore{scope: GlobalScope, store: newPrefixedStore(noTxnStore{ws.MockStore}, "staging")}
+	if ok, _ := other.store.Exists(Key(o1.Key()...)); ok {
+		t.Fatal("Expected the object not to exist under another prefix")
+	}
+	o3 := &watchedObject{ID: "1", Data: "v1"}
+	if err := other.Transaction(TxnOp{Object: o3}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := ws.Exists("staging/" + Key(o3.Key()...)); !ok {
+		t.Fatal("Expected the object to be stored under the other prefix")
+	}
+}
@@ -0,0 +1,189 @@
+package etcdv3
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// The messages of the etcd v3 API, in the JSON mapping of its gateway
+
+// revision is an int64 of the API, which the gateway encodes as a string
+type revision int64
+
+func (r *revision) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	if s == "" || s == "null" {
+		*r = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*r = revision(v)
+	return nil
+}
+
+type responseHeader struct {
+	Revision revision `json:"revision"`
+}
+
+type keyValue struct {
+	Key            []byte   `json:"key"`
+	Value          []byte   `json:"value"`
+	CreateRevision revision `json:"create_revision"`
+	ModRevision    revision `json:"mod_revision"`
+	Lease          revision `json:"lease"`
+}
+
+type rangeRequest struct {
+	Key        []byte `json:"key"`
+	RangeEnd   []byte `json:"range_end,omitempty"`
+	SortOrder  string `json:"sort_order,omitempty"`
+	SortTarget string `json:"sort_target,omitempty"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []*keyValue    `json:"kvs"`
+}
+
+type putRequest struct {
+	Key   []byte   `json:"key"`
+	Value []byte   `json:"value"`
+	Lease revision `json:"lease,omitempty"`
+}
+
+type putResponse struct {
+	Header responseHeader `json:"header"`
+}
+
+type deleteRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type deleteRangeResponse struct {
+	Header  responseHeader `json:"header"`
+	Deleted revision       `json:"deleted"`
+}
+
+// compare is a condition of a transaction. Only the revision of the target
+// is set, the API rejecting a comparison with both.
+type compare struct {
+	Result         string   `json:"result"`
+	Target         string   `json:"target"`
+	Key            []byte   `json:"key"`
+	CreateRevision revision `json:"create_revision,omitempty"`
+	ModRevision    revision `json:"mod_revision,omitempty"`
+}
+
+type requestOp struct {
+	RequestRange       *rangeRequest       `json:"request_range,omitempty"`
+	RequestPut         *putRequest         `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type responseOp struct {
+	ResponseRange       *rangeResponse       `json:"response_range,omitempty"`
+	ResponsePut         *putResponse         `json:"response_put,omitempty"`
+	ResponseDeleteRange *deleteRangeResponse `json:"response_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []*compare   `json:"compare"`
+	Success []*requestOp `json:"success,omitempty"`
+	Failure []*requestOp `json:"failure,omitempty"`
+}
+
+type txnResponse struct {
+	Header    responseHeader `json:"header"`
+	Succeeded bool           `json:"succeeded"`
+	Responses []*responseOp  `json:"responses"`
+}
+
+type leaseGrantRequest struct {
+	TTL int64 `json:"TTL"`
+}
+
+type leaseGrantResponse struct {
+	ID    revision `json:"ID"`
+	TTL   revision `json:"TTL"`
+	Error string   `json:"error"`
+}
+
+type leaseRequest struct {
+	ID revision `json:"ID"`
+}
+
+type leaseKeepAliveResponse struct {
+	Result struct {
+		ID  revision `json:"ID"`
+		TTL revision `json:"TTL"`
+	} `json:"result"`
+}
+
+type watchCreateRequest struct {
+	Key           []byte   `json:"key"`
+	RangeEnd      []byte   `json:"range_end,omitempty"`
+	StartRevision revision `json:"start_revision,omitempty"`
+}
+
+type watchRequest struct {
+	CreateRequest *watchCreateRequest `json:"create_request"`
+}
+
+// event is a change of a key. The gateway omits the PUT type, which is
+// the default one.
+type event struct {
+	Type string    `json:"type"`
+	Kv   *keyValue `json:"kv"`
+}
+
+type watchResponse struct {
+	Result struct {
+		Header   responseHeader `json:"header"`
+		Created  bool           `json:"created"`
+		Canceled bool           `json:"canceled"`
+		Events   []*event       `json:"events"`
+	} `json:"result"`
+	Error *apiError `json:"error"`
+}
+
+type authenticateRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type authenticateResponse struct {
+	Token string `json:"token"`
+}
+
+// apiError is the error the gateway returns along with a non 200 status
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+func (e *apiError) String() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Error
+}
+
+// codeUnauthenticated is the gRPC code of the requests with an invalid
+// or expired auth token
+const codeUnauthenticated = 16
+
+func decodeAPIError(data []byte) *apiError {
+	e := &apiError{}
+	if err := json.Unmarshal(data, e); err != nil || e.String() == "" {
+		e.Message = string(data)
+	}
+	return e
+}
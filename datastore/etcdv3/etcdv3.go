@@ -0,0 +1,614 @@
+// Package etcdv3 provides a libkv store backend speaking the etcd v3 API,
+// through the JSON gateway of the etcd servers, as the etcd v2 API the
+// libkv etcd backend relies on is deprecated. The keys keep the paths the
+// etcd v2 backend stores them at, so the data of a v2 store can be
+// migrated as it is, see Migrate.
+package etcdv3
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/libkv"
+	"github.com/docker/libkv/store"
+	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/types"
+)
+
+// Backend is the name of the backend the datastore providers refer to
+const Backend store.Backend = "etcdv3"
+
+const (
+	apiPrefix      = "/v3"
+	defaultTimeout = 10 * time.Second
+	defaultLockTTL = 20 * time.Second
+)
+
+var (
+	// ErrAbortTryLock is thrown when a user stops trying to seek the lock
+	// by sending a signal to the stop chan
+	ErrAbortTryLock = errors.New("lock operation aborted")
+)
+
+// EtcdV3 is the receiver type for the Store interface
+type EtcdV3 struct {
+	endpoints []string
+	// client serves the unary calls, stream the watches and keepalives
+	client   *http.Client
+	stream   *http.Client
+	username string
+	password string
+	token    string
+	current  int
+	sync.Mutex
+}
+
+// Register registers the etcd v3 backend to libkv
+func Register() {
+	libkv.AddStore(Backend, New)
+}
+
+// New creates a new etcd v3 client given a list of endpoints and an
+// optional tls config
+func New(addrs []string, options *store.Config) (store.Store, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no etcd endpoint")
+	}
+
+	scheme := "http"
+	timeout := defaultTimeout
+	var tlsConfig *tls.Config
+	s := &EtcdV3{}
+	if options != nil {
+		if options.TLS != nil {
+			scheme = "https"
+			tlsConfig = options.TLS
+		}
+		if options.ConnectionTimeout != 0 {
+			timeout = options.ConnectionTimeout
+		}
+		s.username = options.Username
+		s.password = options.Password
+	}
+
+	transport := &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
+	s.endpoints = store.CreateEndpoints(addrs, scheme)
+	s.client = &http.Client{Transport: transport, Timeout: timeout}
+	s.stream = &http.Client{Transport: transport}
+
+	return s, nil
+}
+
+// normalize returns the key at the path the etcd v2 servers store it at,
+// whether it comes with a leading slash or not
+func normalize(key string) string {
+	return path.Clean("/" + key)
+}
+
+// prefixEnd returns the end of the range of the keys with the prefix
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// every key is greater than the prefix
+	return []byte{0}
+}
+
+// post sends the request to the endpoints in turn, starting from the last
+// one reachable, until one of them answers
+func (s *EtcdV3) post(client *http.Client, path string, req interface{}) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authenticate(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	start, token := s.current, s.token
+	s.Unlock()
+
+	var lastErr error
+	for i := 0; i < len(s.endpoints); i++ {
+		n := (start + i) % len(s.endpoints)
+		r, err := http.NewRequest("POST", s.endpoints[n]+apiPrefix+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			r.Header.Set("Authorization", token)
+		}
+		resp, err := client.Do(r)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		s.Lock()
+		s.current = n
+		s.Unlock()
+		return resp, nil
+	}
+
+	return nil, types.NoServiceErrorf("%v: %v", store.ErrNotReachable, lastErr)
+}
+
+// call invokes the unary API call, decoding its response into res
+func (s *EtcdV3) call(path string, req, res interface{}) error {
+	for retried := false; ; retried = true {
+		resp, err := s.post(s.client, path, req)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return json.Unmarshal(data, res)
+		}
+		e := decodeAPIError(data)
+		// the auth token expired
+		if e.Code == codeUnauthenticated && s.username != "" && !retried {
+			s.Lock()
+			s.token = ""
+			s.Unlock()
+			continue
+		}
+		return fmt.Errorf("etcd %s failed: %s", path, e)
+	}
+}
+
+// authenticate gets an auth token if the store was configured with
+// credentials and none is held
+func (s *EtcdV3) authenticate() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.username == "" || s.token != "" {
+		return nil
+	}
+
+	body, err := json.Marshal(&authenticateRequest{Name: s.username, Password: s.password})
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for i := 0; i < len(s.endpoints); i++ {
+		n := (s.current + i) % len(s.endpoints)
+		resp, err := s.client.Post(s.endpoints[n]+apiPrefix+"/auth/authenticate", "application/json", bytes.NewReader(body))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("etcd authentication failed: %s", decodeAPIError(data))
+		}
+		res := &authenticateResponse{}
+		if err := json.Unmarshal(data, res); err != nil {
+			return err
+		}
+		s.current = n
+		s.token = res.Token
+		return nil
+	}
+	return types.NoServiceErrorf("%v: %v", store.ErrNotReachable, lastErr)
+}
+
+// get returns the key value pair of the key along with the revision of the
+// store it was read at
+func (s *EtcdV3) get(key string) (*keyValue, revision, error) {
+	res := &rangeResponse{}
+	if err := s.call("/kv/range", &rangeRequest{Key: []byte(normalize(key))}, res); err != nil {
+		return nil, 0, err
+	}
+	if len(res.Kvs) == 0 {
+		return nil, res.Header.Revision, store.ErrKeyNotFound
+	}
+	return res.Kvs[0], res.Header.Revision, nil
+}
+
+// Get the value at "key", returns the last modified index to use in
+// conjunction to Atomic calls
+func (s *EtcdV3) Get(key string) (*store.KVPair, error) {
+	kv, _, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return &store.KVPair{Key: key, Value: kv.Value, LastIndex: uint64(kv.ModRevision)}, nil
+}
+
+// grant returns a lease of the ttl, rounded up to the second
+func (s *EtcdV3) grant(ttl time.Duration) (revision, error) {
+	secs := int64((ttl + time.Second - 1) / time.Second)
+	res := &leaseGrantResponse{}
+	if err := s.call("/lease/grant", &leaseGrantRequest{TTL: secs}, res); err != nil {
+		return 0, err
+	}
+	if res.Error != "" {
+		return 0, fmt.Errorf("etcd lease grant failed: %s", res.Error)
+	}
+	return res.ID, nil
+}
+
+// newPut returns the request putting the value at the key, attached
+// to a lease of the ttl of the options, if any
+func (s *EtcdV3) newPut(key string, value []byte, opts *store.WriteOptions) (*putRequest, error) {
+	req := &putRequest{Key: []byte(normalize(key)), Value: value}
+	if opts != nil && opts.TTL > 0 {
+		lease, err := s.grant(opts.TTL)
+		if err != nil {
+			return nil, err
+		}
+		req.Lease = lease
+	}
+	return req, nil
+}
+
+// Put a value at "key". There is no directory in etcd v3, IsDir is
+// ignored.
+func (s *EtcdV3) Put(key string, value []byte, opts *store.WriteOptions) error {
+	req, err := s.newPut(key, value, opts)
+	if err != nil {
+		return err
+	}
+	return s.call("/kv/put", req, &putResponse{})
+}
+
+// Delete a value at "key"
+func (s *EtcdV3) Delete(key string) error {
+	res := &deleteRangeResponse{}
+	if err := s.call("/kv/deleterange", &deleteRangeRequest{Key: []byte(normalize(key))}, res); err != nil {
+		return err
+	}
+	if res.Deleted == 0 {
+		return store.ErrKeyNotFound
+	}
+	return nil
+}
+
+// Exists checks if the key exists inside the store
+func (s *EtcdV3) Exists(key string) (bool, error) {
+	_, err := s.Get(key)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// list returns the children of the directory, along with the revision of
+// the store they were read at. Like the etcd v2 backend, it returns the
+// direct children only, the keys of the nested directories aside.
+func (s *EtcdV3) list(directory string) ([]*store.KVPair, revision, error) {
+	prefix := normalize(directory) + "/"
+	req := &rangeRequest{
+		Key:        []byte(prefix),
+		RangeEnd:   prefixEnd(prefix),
+		SortOrder:  "ASCEND",
+		SortTarget: "KEY",
+	}
+	res := &rangeResponse{}
+	if err := s.call("/kv/range", req, res); err != nil {
+		return nil, 0, err
+	}
+	if len(res.Kvs) == 0 {
+		return nil, res.Header.Revision, store.ErrKeyNotFound
+	}
+
+	kv := []*store.KVPair{}
+	for _, n := range res.Kvs {
+		if strings.Contains(strings.TrimPrefix(string(n.Key), prefix), "/") {
+			continue
+		}
+		kv = append(kv, &store.KVPair{
+			Key:       string(n.Key),
+			Value:     n.Value,
+			LastIndex: uint64(n.ModRevision),
+		})
+	}
+	return kv, res.Header.Revision, nil
+}
+
+// List child nodes of a given directory
+func (s *EtcdV3) List(directory string) ([]*store.KVPair, error) {
+	kv, _, err := s.list(directory)
+	return kv, err
+}
+
+// DeleteTree deletes a range of keys under a given directory
+func (s *EtcdV3) DeleteTree(directory string) error {
+	prefix := normalize(directory) + "/"
+	res := &deleteRangeResponse{}
+	if err := s.call("/kv/deleterange", &deleteRangeRequest{Key: []byte(prefix), RangeEnd: prefixEnd(prefix)}, res); err != nil {
+		return err
+	}
+	if res.Deleted == 0 {
+		return store.ErrKeyNotFound
+	}
+	return nil
+}
+
+// AtomicPut puts a value at "key" if the key has not been modified since
+// the previous pair was read, or does not exist if the previous pair is
+// nil, in a single transaction
+func (s *EtcdV3) AtomicPut(key string, value []byte, previous *store.KVPair, opts *store.WriteOptions) (bool, *store.KVPair, error) {
+	put, err := s.newPut(key, value, opts)
+	if err != nil {
+		return false, nil, err
+	}
+
+	cmp := &compare{Result: "EQUAL", Key: put.Key}
+	if previous != nil {
+		cmp.Target = "MOD"
+		cmp.ModRevision = revision(previous.LastIndex)
+	} else {
+		cmp.Target = "CREATE"
+	}
+
+	res := &txnResponse{}
+	req := &txnRequest{
+		Compare: []*compare{cmp},
+		Success: []*requestOp{{RequestPut: put}},
+	}
+	if err := s.call("/kv/txn", req, res); err != nil {
+		return false, nil, err
+	}
+	if !res.Succeeded {
+		s.revokeAttached(put)
+		if previous == nil {
+			return false, nil, store.ErrKeyExists
+		}
+		return false, nil, store.ErrKeyModified
+	}
+
+	// the revision of the transaction is the one of the put key
+	updated := &store.KVPair{
+		Key:       key,
+		Value:     value,
+		LastIndex: uint64(res.Header.Revision),
+	}
+	return true, updated, nil
+}
+
+// revokeAttached revokes the lease granted for the put which did not
+// happen, if any
+func (s *EtcdV3) revokeAttached(put *putRequest) {
+	if put.Lease != 0 {
+		s.call("/kv/lease/revoke", &leaseRequest{ID: put.Lease}, &struct{}{})
+	}
+}
+
+// AtomicDelete deletes a value at "key" if the key has not been modified
+// since the previous pair was read
+func (s *EtcdV3) AtomicDelete(key string, previous *store.KVPair) (bool, error) {
+	if previous == nil {
+		return false, store.ErrPreviousNotSpecified
+	}
+
+	k := []byte(normalize(key))
+	res := &txnResponse{}
+	req := &txnRequest{
+		Compare: []*compare{{Result: "EQUAL", Target: "MOD", Key: k, ModRevision: revision(previous.LastIndex)}},
+		Success: []*requestOp{{RequestDeleteRange: &deleteRangeRequest{Key: k}}},
+		Failure: []*requestOp{{RequestRange: &rangeRequest{Key: k}}},
+	}
+	if err := s.call("/kv/txn", req, res); err != nil {
+		return false, err
+	}
+	if !res.Succeeded {
+		if len(res.Responses) > 0 && res.Responses[0].ResponseRange != nil && len(res.Responses[0].ResponseRange.Kvs) == 0 {
+			return false, store.ErrKeyNotFound
+		}
+		return false, store.ErrKeyModified
+	}
+	return true, nil
+}
+
+// AtomicTxn writes the keys in a single transaction, if none of them was
+// modified since its previous pair was read. It implements the TxnStore
+// interface of the datastore.
+func (s *EtcdV3) AtomicTxn(ops []*datastore.KVTxnOp) ([]uint64, error) {
+	req := &txnRequest{}
+	for _, op := range ops {
+		k := []byte(normalize(op.Key))
+		cmp := &compare{Result: "EQUAL", Key: k}
+		if op.Previous != nil {
+			cmp.Target = "MOD"
+			cmp.ModRevision = revision(op.Previous.LastIndex)
+		} else {
+			cmp.Target = "CREATE"
+		}
+		req.Compare = append(req.Compare, cmp)
+
+		if op.Delete {
+			req.Success = append(req.Success, &requestOp{RequestDeleteRange: &deleteRangeRequest{Key: k}})
+		} else {
+			req.Success = append(req.Success, &requestOp{RequestPut: &putRequest{Key: k, Value: op.Value}})
+		}
+	}
+
+	res := &txnResponse{}
+	if err := s.call("/kv/txn", req, res); err != nil {
+		return nil, err
+	}
+	if !res.Succeeded {
+		return nil, store.ErrKeyModified
+	}
+
+	// the revision of the transaction is the one of the put keys
+	indexes := make([]uint64, len(ops))
+	for i, op := range ops {
+		if !op.Delete {
+			indexes[i] = uint64(res.Header.Revision)
+		}
+	}
+	return indexes, nil
+}
+
+// watch streams the events of the keys of the range from the revision
+// until the stop channel is closed or the watch fails, closing the
+// returned channel then
+func (s *EtcdV3) watch(key, rangeEnd []byte, rev revision, stopCh <-chan struct{}) (<-chan []*event, error) {
+	req := &watchRequest{CreateRequest: &watchCreateRequest{Key: key, RangeEnd: rangeEnd, StartRevision: rev}}
+	resp, err := s.post(s.stream, "/watch", req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd watch failed: %s", decodeAPIError(data))
+	}
+
+	eventsCh := make(chan []*event)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stopCh:
+		case <-done:
+		}
+		resp.Body.Close()
+	}()
+	go func() {
+		defer close(eventsCh)
+		defer close(done)
+		dec := json.NewDecoder(resp.Body)
+		for {
+			wr := &watchResponse{}
+			if err := dec.Decode(wr); err != nil || wr.Error != nil || wr.Result.Canceled {
+				return
+			}
+			if len(wr.Result.Events) == 0 {
+				continue
+			}
+			select {
+			case eventsCh <- wr.Result.Events:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	return eventsCh, nil
+}
+
+// Watch for changes on a "key"
+// It returns a channel that will receive changes or pass
+// on errors. Upon creation, the current value will first
+// be sent to the channel. Providing a non-nil stopCh can
+// be used to stop watching.
+func (s *EtcdV3) Watch(key string, stopCh <-chan struct{}) (<-chan *store.KVPair, error) {
+	kv, rev, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.watch([]byte(normalize(key)), nil, rev+1, stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	// watchCh is sending back events to the caller
+	watchCh := make(chan *store.KVPair)
+	go func() {
+		defer close(watchCh)
+
+		// Push the current value through the channel.
+		watchCh <- &store.KVPair{Key: key, Value: kv.Value, LastIndex: uint64(kv.ModRevision)}
+
+		for list := range events {
+			for _, ev := range list {
+				pair := &store.KVPair{Key: key, LastIndex: uint64(ev.Kv.ModRevision)}
+				// the deleted keys come with an empty value, like with
+				// the etcd v2 backend
+				if ev.Type != "DELETE" {
+					pair.Value = ev.Kv.Value
+				}
+				select {
+				case watchCh <- pair:
+				case <-stopCh:
+					return
+				}
+			}
+		}
+	}()
+
+	return watchCh, nil
+}
+
+// WatchTree watches for changes on a "directory"
+// It returns a channel that will receive changes or pass
+// on errors. Upon creating a watch, the current childs values
+// will be sent to the channel. Providing a non-nil stopCh can
+// be used to stop watching.
+func (s *EtcdV3) WatchTree(directory string, stopCh <-chan struct{}) (<-chan []*store.KVPair, error) {
+	list, rev, err := s.list(directory)
+	if err != nil {
+		return nil, err
+	}
+	prefix := normalize(directory) + "/"
+	events, err := s.watch([]byte(prefix), prefixEnd(prefix), rev+1, stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	// watchCh is sending back events to the caller
+	watchCh := make(chan []*store.KVPair)
+	go func() {
+		defer close(watchCh)
+
+		// Push the current value through the channel.
+		watchCh <- list
+
+		for range events {
+			list, err := s.List(directory)
+			if err != nil && err != store.ErrKeyNotFound {
+				return
+			}
+			select {
+			case watchCh <- list:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	return watchCh, nil
+}
+
+// Close closes the client connection
+func (s *EtcdV3) Close() {
+	if t, ok := s.client.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,375 @@
+package etcdv3
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libnetwork/datastore"
+)
+
+// fakeEtcd serves the unary kv calls of the gateway from memory, encoding
+// the revisions as strings the way the gateway does
+type fakeEtcd struct {
+	sync.Mutex
+	rev int64
+	kvs map[string]*fakeKV
+	// the writes of a transaction share its revision
+	inTxn bool
+}
+
+func (f *fakeEtcd) nextRev() {
+	if !f.inTxn {
+		f.rev++
+	}
+}
+
+type fakeKV struct {
+	value  []byte
+	create int64
+	mod    int64
+}
+
+func (f *fakeEtcd) keys(key, end []byte) []string {
+	var list []string
+	for k := range f.kvs {
+		if end == nil && k == string(key) || end != nil && k >= string(key) && k < string(end) {
+			list = append(list, k)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (f *fakeEtcd) header() map[string]interface{} {
+	return map[string]interface{}{"revision": strconv.FormatInt(f.rev, 10)}
+}
+
+func (f *fakeEtcd) doRange(req *rangeRequest) map[string]interface{} {
+	var kvs []map[string]interface{}
+	for _, k := range f.keys(req.Key, req.RangeEnd) {
+		kv := f.kvs[k]
+		kvs = append(kvs, map[string]interface{}{
+			"key":             []byte(k),
+			"value":           kv.value,
+			"create_revision": strconv.FormatInt(kv.create, 10),
+			"mod_revision":    strconv.FormatInt(kv.mod, 10),
+		})
+	}
+	return map[string]interface{}{"header": f.header(), "kvs": kvs}
+}
+
+func (f *fakeEtcd) doPut(req *putRequest) map[string]interface{} {
+	f.nextRev()
+	kv, ok := f.kvs[string(req.Key)]
+	if !ok {
+		kv = &fakeKV{create: f.rev}
+		f.kvs[string(req.Key)] = kv
+	}
+	kv.value, kv.mod = req.Value, f.rev
+	return map[string]interface{}{"header": f.header()}
+}
+
+func (f *fakeEtcd) doDelete(req *deleteRangeRequest) map[string]interface{} {
+	keys := f.keys(req.Key, req.RangeEnd)
+	if len(keys) > 0 {
+		f.nextRev()
+	}
+	for _, k := range keys {
+		delete(f.kvs, k)
+	}
+	return map[string]interface{}{"header": f.header(), "deleted": strconv.Itoa(len(keys))}
+}
+
+func (f *fakeEtcd) doTxn(req *txnRequest) map[string]interface{} {
+	succeeded := true
+	for _, c := range req.Compare {
+		var v int64
+		if kv, ok := f.kvs[string(c.Key)]; ok {
+			v = kv.mod
+			if c.Target == "CREATE" {
+				v = kv.create
+			}
+		}
+		expected := int64(c.ModRevision)
+		if c.Target == "CREATE" {
+			expected = int64(c.CreateRevision)
+		}
+		succeeded = succeeded && v == expected
+	}
+	ops := req.Success
+	if !succeeded {
+		ops = req.Failure
+	}
+	if succeeded && len(ops) > 0 {
+		f.rev++
+		f.inTxn = true
+		defer func() { f.inTxn = false }()
+	}
+	var responses []map[string]interface{}
+	for _, op := range ops {
+		switch {
+		case op.RequestRange != nil:
+			responses = append(responses, map[string]interface{}{"response_range": f.doRange(op.RequestRange)})
+		case op.RequestPut != nil:
+			responses = append(responses, map[string]interface{}{"response_put": f.doPut(op.RequestPut)})
+		case op.RequestDeleteRange != nil:
+			responses = append(responses, map[string]interface{}{"response_delete_range": f.doDelete(op.RequestDeleteRange)})
+		}
+	}
+	return map[string]interface{}{"header": f.header(), "succeeded": succeeded, "responses": responses}
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	var res interface{}
+	dec := json.NewDecoder(r.Body)
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		req := &rangeRequest{}
+		dec.Decode(req)
+		res = f.doRange(req)
+	case "/v3/kv/put":
+		req := &putRequest{}
+		dec.Decode(req)
+		res = f.doPut(req)
+	case "/v3/kv/deleterange":
+		req := &deleteRangeRequest{}
+		dec.Decode(req)
+		res = f.doDelete(req)
+	case "/v3/kv/txn":
+		req := &txnRequest{}
+		dec.Decode(req)
+		res = f.doTxn(req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 5, "error": "Not Found"})
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func newTestStore(t *testing.T) (store.Store, func()) {
+	srv := httptest.NewServer(&fakeEtcd{kvs: make(map[string]*fakeKV)})
+	s, err := New([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, srv.Close
+}
+
+func TestPutGetDelete(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	if _, err := s.Get("docker/network/v1.0/network/n1"); err != store.ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if err := s.Put("docker/network/v1.0/network/n1", []byte("v1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := s.Get("docker/network/v1.0/network/n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pair.Value) != "v1" || pair.LastIndex != 1 {
+		t.Fatalf("Unexpected pair: %+v", pair)
+	}
+	if ok, err := s.Exists("docker/network/v1.0/network/n1"); err != nil || !ok {
+		t.Fatalf("Expected the key to exist: %v", err)
+	}
+	if err := s.Delete("docker/network/v1.0/network/n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("docker/network/v1.0/network/n1"); err != store.ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestAtomicPutDelete(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	ok, pair, err := s.AtomicPut("docker/network/v1.0/key", []byte("v1"), nil, nil)
+	if err != nil || !ok {
+		t.Fatalf("Failed to create the key: %v", err)
+	}
+	if _, _, err := s.AtomicPut("docker/network/v1.0/key", []byte("v1"), nil, nil); err != store.ErrKeyExists {
+		t.Fatalf("Expected ErrKeyExists, got %v", err)
+	}
+
+	ok, updated, err := s.AtomicPut("docker/network/v1.0/key", []byte("v2"), pair, nil)
+	if err != nil || !ok {
+		t.Fatalf("Failed to update the key: %v", err)
+	}
+	if updated.LastIndex <= pair.LastIndex {
+		t.Fatalf("Expected the index to grow: %d, %d", pair.LastIndex, updated.LastIndex)
+	}
+	if _, _, err := s.AtomicPut("docker/network/v1.0/key", []byte("v3"), pair, nil); err != store.ErrKeyModified {
+		t.Fatalf("Expected ErrKeyModified, got %v", err)
+	}
+
+	if _, err := s.AtomicDelete("docker/network/v1.0/key", pair); err != store.ErrKeyModified {
+		t.Fatalf("Expected ErrKeyModified, got %v", err)
+	}
+	if ok, err := s.AtomicDelete("docker/network/v1.0/key", updated); err != nil || !ok {
+		t.Fatalf("Failed to delete the key: %v", err)
+	}
+	if _, err := s.AtomicDelete("docker/network/v1.0/key", updated); err != store.ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestAtomicTxn(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	ts := s.(datastore.TxnStore)
+	indexes, err := ts.AtomicTxn([]*datastore.KVTxnOp{
+		{Key: "docker/a", Value: []byte("a")},
+		{Key: "docker/b", Value: []byte("b")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range []string{"docker/a", "docker/b"} {
+		pair, err := s.Get(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pair.LastIndex != indexes[i] {
+			t.Fatalf("Unexpected index of %s: %d, expected %d", k, pair.LastIndex, indexes[i])
+		}
+	}
+
+	// a stale key fails the whole transaction
+	_, err = ts.AtomicTxn([]*datastore.KVTxnOp{
+		{Key: "docker/a", Previous: &store.KVPair{LastIndex: indexes[0]}, Delete: true},
+		{Key: "docker/b", Value: []byte("b2"), Previous: &store.KVPair{LastIndex: indexes[1] - 1}},
+	})
+	if err != store.ErrKeyModified {
+		t.Fatalf("Expected ErrKeyModified, got %v", err)
+	}
+	if _, err := s.Get("docker/a"); err != nil {
+		t.Fatalf("Expected the key to be left: %v", err)
+	}
+
+	if _, err = ts.AtomicTxn([]*datastore.KVTxnOp{
+		{Key: "docker/a", Previous: &store.KVPair{LastIndex: indexes[0]}, Delete: true},
+		{Key: "docker/b", Value: []byte("b2"), Previous: &store.KVPair{LastIndex: indexes[1]}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("docker/a"); err != store.ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if pair, err := s.Get("docker/b"); err != nil || string(pair.Value) != "b2" {
+		t.Fatalf("Unexpected pair %v: %v", pair, err)
+	}
+}
+
+func TestListDeleteTree(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	for _, k := range []string{"net/a", "net/b", "net/sub/c", "network/d"} {
+		if err := s.Put("docker/"+k, []byte(k), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := s.List("docker/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Key != "/docker/net/a" || list[1].Key != "/docker/net/b" {
+		t.Fatalf("Unexpected list: %v", list)
+	}
+
+	if err := s.DeleteTree("docker/net"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.List("docker/net"); err != store.ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err := s.Get("docker/network/d"); err != nil {
+		t.Fatalf("Expected the sibling directory to be left: %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	src := &treeStore{
+		"/docker":           nil,
+		"/docker/net":       nil,
+		"/docker/net/a":     []byte("a"),
+		"/docker/net/empty": nil,
+		"/docker/b":         []byte("b"),
+	}
+	dst, cleanup := newTestStore(t)
+	defer cleanup()
+
+	n, err := Migrate(src, dst, "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected 2 keys migrated, got %d", n)
+	}
+	for _, k := range []string{"docker/net/a", "docker/b"} {
+		pair, err := dst.Get(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pair.Value, (*src)["/"+k]) {
+			t.Fatalf("Unexpected value of %s: %s", k, pair.Value)
+		}
+	}
+}
+
+// treeStore lists its keys the way an etcd v2 store does, the directories
+// having a nil value
+type treeStore map[string][]byte
+
+func (t treeStore) List(directory string) ([]*store.KVPair, error) {
+	prefix := normalize(directory) + "/"
+	list := []*store.KVPair{}
+	for k, v := range t {
+		if strings.HasPrefix(k, prefix) && !strings.Contains(strings.TrimPrefix(k, prefix), "/") {
+			list = append(list, &store.KVPair{Key: k, Value: v})
+		}
+	}
+	return list, nil
+}
+
+func (t treeStore) Put(key string, value []byte, options *store.WriteOptions) error {
+	return store.ErrCallNotSupported
+}
+func (t treeStore) Get(key string) (*store.KVPair, error) { return nil, store.ErrCallNotSupported }
+func (t treeStore) Delete(key string) error               { return store.ErrCallNotSupported }
+func (t treeStore) Exists(key string) (bool, error)       { return false, store.ErrCallNotSupported }
+func (t treeStore) Watch(key string, stopCh <-chan struct{}) (<-chan *store.KVPair, error) {
+	return nil, store.ErrCallNotSupported
+}
+func (t treeStore) WatchTree(directory string, stopCh <-chan struct{}) (<-chan []*store.KVPair, error) {
+	return nil, store.ErrCallNotSupported
+}
+func (t treeStore) NewLock(key string, options *store.LockOptions) (store.Locker, error) {
+	return nil, store.ErrCallNotSupported
+}
+func (t treeStore) DeleteTree(directory string) error { return store.ErrCallNotSupported }
+func (t treeStore) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	return false, nil, store.ErrCallNotSupported
+}
+func (t treeStore) AtomicDelete(key string, previous *store.KVPair) (bool, error) {
+	return false, store.ErrCallNotSupported
+}
+func (t treeStore) Close() {}
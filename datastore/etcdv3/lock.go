@@ -0,0 +1,162 @@
+package etcdv3
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/docker/libkv/store"
+)
+
+type etcdLock struct {
+	s         *EtcdV3
+	key       []byte
+	value     []byte
+	ttl       time.Duration
+	stopRenew <-chan struct{}
+	stopLock  chan struct{}
+	lease     revision
+}
+
+// NewLock returns a handle to a lock struct which can
+// be used to provide mutual exclusion on a key
+func (s *EtcdV3) NewLock(key string, options *store.LockOptions) (store.Locker, error) {
+	l := &etcdLock{
+		s:   s,
+		key: []byte(normalize(key)),
+		ttl: defaultLockTTL,
+	}
+
+	// Apply options on Lock
+	if options != nil {
+		l.value = options.Value
+		if options.TTL != 0 {
+			l.ttl = options.TTL
+		}
+		l.stopRenew = options.RenewLock
+	}
+
+	return l, nil
+}
+
+// Lock attempts to acquire the lock and blocks while doing so. The key is
+// created in a transaction, attached to a lease renewed while the lock is
+// held. It returns a channel that is closed if our lock is lost or if an
+// error occurs.
+func (l *etcdLock) Lock(stopChan chan struct{}) (<-chan struct{}, error) {
+	lease, err := l.s.grant(l.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		res := &txnResponse{}
+		req := &txnRequest{
+			Compare: []*compare{{Result: "EQUAL", Target: "CREATE", Key: l.key}},
+			Success: []*requestOp{{RequestPut: &putRequest{Key: l.key, Value: l.value, Lease: lease}}},
+		}
+		if err := l.s.call("/kv/txn", req, res); err != nil {
+			l.revoke(lease)
+			return nil, err
+		}
+
+		if res.Succeeded {
+			// Leader section
+			l.lease = lease
+			l.stopLock = make(chan struct{})
+			lockHeld := make(chan struct{})
+			go l.holdLock(lockHeld, l.stopLock)
+			return lockHeld, nil
+		}
+
+		// Seeker section: wait for the key to be deleted or to expire, or
+		// for a signal to stop trying to lock it
+		if err := l.waitLock(res.Header.Revision+1, stopChan); err != nil {
+			l.revoke(lease)
+			return nil, err
+		}
+	}
+}
+
+// waitLock waits for the key to be deleted from the revision on
+func (l *etcdLock) waitLock(rev revision, stopChan chan struct{}) error {
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	events, err := l.s.watch(l.key, nil, rev, stopWatch)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case list, ok := <-events:
+			if !ok {
+				// the watch was interrupted, the caller tries again
+				return nil
+			}
+			for _, ev := range list {
+				if ev.Type == "DELETE" {
+					return nil
+				}
+			}
+		case <-stopChan:
+			return ErrAbortTryLock
+		}
+	}
+}
+
+// holdLock keeps the lease of the key alive as long as we can, until we
+// receive an explicit stop signal from the Unlock method or from the
+// renewal channel of the lock options
+func (l *etcdLock) holdLock(lockHeld chan struct{}, stopLocking <-chan struct{}) {
+	defer close(lockHeld)
+
+	update := time.NewTicker(l.ttl / 3)
+	defer update.Stop()
+
+	for {
+		select {
+		case <-update.C:
+			if !l.keepAlive() {
+				return
+			}
+		case <-l.stopRenew:
+			return
+		case <-stopLocking:
+			return
+		}
+	}
+}
+
+// keepAlive renews the lease of the key once, returning whether it is
+// still alive
+func (l *etcdLock) keepAlive() bool {
+	resp, err := l.s.post(l.s.client, "/lease/keepalive", &leaseRequest{ID: l.lease})
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	// the keepalive is a stream, of which the first response is read
+	res := &leaseKeepAliveResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return false
+	}
+	return res.Result.TTL > 0
+}
+
+func (l *etcdLock) revoke(lease revision) error {
+	return l.s.call("/kv/lease/revoke", &leaseRequest{ID: lease}, &struct{}{})
+}
+
+// Unlock the "key". Revoking the lease of the key deletes it.
+func (l *etcdLock) Unlock() error {
+	if l.stopLock == nil {
+		return nil
+	}
+	close(l.stopLock)
+	l.stopLock = nil
+	return l.revoke(l.lease)
+}
@@ -0,0 +1,38 @@
+package etcdv3
+
+import (
+	"github.com/docker/libkv/store"
+)
+
+// Migrate copies the keys of the tree under the root directory of the
+// source store, an etcd v2 one typically, to the destination store at the
+// same paths, and returns the number of keys copied. The keys present in
+// the destination are overwritten. The keys with an empty value are
+// skipped, a v2 store listing them the same as its empty directories.
+func Migrate(src, dst store.Store, root string) (int, error) {
+	list, err := src.List(root)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	count := 0
+	for _, kv := range list {
+		// the directories have children, the keys have none
+		n, err := Migrate(src, dst, kv.Key)
+		if err != nil {
+			return count, err
+		}
+		count += n
+		if n > 0 || len(kv.Value) == 0 {
+			continue
+		}
+		if err := dst.Put(kv.Key, kv.Value, nil); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -127,3 +127,25 @@ func (s *MockStore) AtomicDelete(key string, previous *store.KVPair) (bool, erro
 func (s *MockStore) Close() {
 	return
 }
+
+// AtomicTxn applies the writes if none of the keys has been modified in
+// the meantime, throws an error if this is the case
+func (s *MockStore) AtomicTxn(ops []*KVTxnOp) ([]uint64, error) {
+	for _, op := range ops {
+		mData := s.db[op.Key]
+		if op.Previous == nil && mData != nil || op.Previous != nil && (mData == nil || mData.Index != op.Previous.LastIndex) {
+			return nil, ErrKeyModified
+		}
+	}
+
+	indexes := make([]uint64, len(ops))
+	for i, op := range ops {
+		if op.Delete {
+			s.Delete(op.Key)
+			continue
+		}
+		s.Put(op.Key, op.Value, nil)
+		indexes[i] = s.db[op.Key].Index
+	}
+	return indexes, nil
+}
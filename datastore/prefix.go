@@ -0,0 +1,147 @@
+package datastore
+
+import (
+	"strings"
+
+	"github.com/docker/libkv/store"
+)
+
+// prefixedStore namespaces the keys of a store under a prefix, for the
+// deployments sharing a KV cluster not to see each other's keys. The keys
+// the store returns are stripped of the prefix.
+type prefixedStore struct {
+	store.Store
+	prefix string
+}
+
+func newPrefixedStore(s store.Store, prefix string) store.Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return s
+	}
+	return &prefixedStore{Store: s, prefix: prefix}
+}
+
+func (s *prefixedStore) key(key string) string {
+	return s.prefix + "/" + strings.TrimPrefix(key, "/")
+}
+
+func (s *prefixedStore) strip(pair *store.KVPair) *store.KVPair {
+	if pair == nil {
+		return nil
+	}
+	key := strings.TrimPrefix(pair.Key, "/")
+	if !strings.HasPrefix(key, s.prefix+"/") {
+		return pair
+	}
+	stripped := *pair
+	stripped.Key = strings.TrimPrefix(key, s.prefix)
+	if !strings.HasPrefix(pair.Key, "/") {
+		stripped.Key = stripped.Key[1:]
+	}
+	return &stripped
+}
+
+func (s *prefixedStore) stripList(list []*store.KVPair) []*store.KVPair {
+	if list == nil {
+		return nil
+	}
+	stripped := make([]*store.KVPair, 0, len(list))
+	for _, pair := range list {
+		stripped = append(stripped, s.strip(pair))
+	}
+	return stripped
+}
+
+func (s *prefixedStore) Put(key string, value []byte, options *store.WriteOptions) error {
+	return s.Store.Put(s.key(key), value, options)
+}
+
+func (s *prefixedStore) Get(key string) (*store.KVPair, error) {
+	pair, err := s.Store.Get(s.key(key))
+	return s.strip(pair), err
+}
+
+func (s *prefixedStore) Delete(key string) error {
+	return s.Store.Delete(s.key(key))
+}
+
+func (s *prefixedStore) Exists(key string) (bool, error) {
+	return s.Store.Exists(s.key(key))
+}
+
+func (s *prefixedStore) Watch(key string, stopCh <-chan struct{}) (<-chan *store.KVPair, error) {
+	pairCh, err := s.Store.Watch(s.key(key), stopCh)
+	if err != nil {
+		return nil, err
+	}
+	watchCh := make(chan *store.KVPair)
+	go func() {
+		defer close(watchCh)
+		for pair := range pairCh {
+			select {
+			case watchCh <- s.strip(pair):
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return watchCh, nil
+}
+
+func (s *prefixedStore) WatchTree(directory string, stopCh <-chan struct{}) (<-chan []*store.KVPair, error) {
+	listCh, err := s.Store.WatchTree(s.key(directory), stopCh)
+	if err != nil {
+		return nil, err
+	}
+	watchCh := make(chan []*store.KVPair)
+	go func() {
+		defer close(watchCh)
+		for list := range listCh {
+			select {
+			case watchCh <- s.stripList(list):
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return watchCh, nil
+}
+
+func (s *prefixedStore) NewLock(key string, options *store.LockOptions) (store.Locker, error) {
+	return s.Store.NewLock(s.key(key), options)
+}
+
+func (s *prefixedStore) List(directory string) ([]*store.KVPair, error) {
+	list, err := s.Store.List(s.key(directory))
+	return s.stripList(list), err
+}
+
+func (s *prefixedStore) DeleteTree(directory string) error {
+	return s.Store.DeleteTree(s.key(directory))
+}
+
+func (s *prefixedStore) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	ok, pair, err := s.Store.AtomicPut(s.key(key), value, previous, options)
+	return ok, s.strip(pair), err
+}
+
+func (s *prefixedStore) AtomicDelete(key string, previous *store.KVPair) (bool, error) {
+	return s.Store.AtomicDelete(s.key(key), previous)
+}
+
+// AtomicTxn runs the transaction of the prefixed keys if the store supports
+// transactions
+func (s *prefixedStore) AtomicTxn(ops []*KVTxnOp) ([]uint64, error) {
+	ts, ok := s.Store.(TxnStore)
+	if !ok {
+		return nil, store.ErrCallNotSupported
+	}
+	prefixed := make([]*KVTxnOp, 0, len(ops))
+	for _, op := range ops {
+		p := *op
+		p.Key = s.key(op.Key)
+		prefixed = append(prefixed, &p)
+	}
+	return ts.AtomicTxn(prefixed)
+}
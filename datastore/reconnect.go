@@ -0,0 +1,175 @@
+package datastore
+
+import (
+	"net"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libkv/store"
+	"github.com/docker/libnetwork/types"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+// The datastores fronted by a watched cache outlive the outages of their
+// store: the writes failing to reach it are queued, to be replayed once it
+// is reachable again, the cache being resynced and the watches restarted
+// then.
+
+const (
+	reconnectBackoffMin = 500 * time.Millisecond
+	reconnectBackoffMax = 30 * time.Second
+)
+
+type writeOp int
+
+const (
+	opPut writeOp = iota
+	opPutAtomic
+	opDelete
+	opDeleteAtomic
+	opDeleteTree
+)
+
+func (op writeOp) atomic() bool {
+	return op == opPutAtomic || op == opDeleteAtomic
+}
+
+// pendingWrite is a write queued during an outage of the store
+type pendingWrite struct {
+	op       writeOp
+	key      string
+	value    []byte
+	previous *store.KVPair
+}
+
+// clusterError is the error of the etcd client failing to reach any of
+// the members of the cluster, matched by its method not to import it
+type clusterError interface {
+	Detail() string
+}
+
+// isUnreachable returns whether the error is the store being unreachable
+func isUnreachable(err error) bool {
+	switch err.(type) {
+	case clusterError, net.Error, types.NoServiceError:
+		return true
+	}
+	return err == store.ErrNotReachable || err == zk.ErrNoServer || err == zk.ErrConnectionClosed
+}
+
+// reconnects returns whether the datastore queues its writes during the
+// outages of its store
+func (ds *datastore) reconnects() bool {
+	return ds.cache != nil && ds.cache.watched
+}
+
+// queued returns whether the write is to be replayed later, the store being
+// unreachable, queueing it then. Called with the datastore locked.
+func (ds *datastore) queued(w *pendingWrite, err error) bool {
+	if !ds.reconnects() || !ds.unreachable && !isUnreachable(err) {
+		return false
+	}
+	ds.markUnreachable()
+
+	for i, p := range ds.pending {
+		if p.key != w.key {
+			continue
+		}
+		// an object created during the outage is deleted
+		if p.op == opPutAtomic && p.previous == nil && (w.op == opDelete || w.op == opDeleteAtomic) {
+			ds.pending = append(ds.pending[:i], ds.pending[i+1:]...)
+			return true
+		}
+		// the index of the key in the store is the one of the first write
+		if p.op.atomic() && w.op.atomic() {
+			w.previous = p.previous
+		}
+		ds.pending[i] = w
+		return true
+	}
+	ds.pending = append(ds.pending, w)
+	return true
+}
+
+// markUnreachable enters the outage mode, probing the store until it is
+// reachable again. Called with the datastore locked.
+func (ds *datastore) markUnreachable() {
+	if ds.unreachable || !ds.reconnects() {
+		return
+	}
+	log.Warnf("Store of the %s scope unreachable, queueing its writes", ds.scope)
+	ds.unreachable = true
+	ds.active = false
+	go ds.reconnectLoop()
+}
+
+// reconnectLoop probes the store with an exponential backoff until the
+// outage is over, or the datastore is closed
+func (ds *datastore) reconnectLoop() {
+	backoff := reconnectBackoffMin
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-ds.cache.stopCh:
+			return
+		}
+		if ds.reconnect() {
+			return
+		}
+		if backoff *= 2; backoff > reconnectBackoffMax {
+			backoff = reconnectBackoffMax
+		}
+	}
+}
+
+// reconnect replays the queued writes if the store is reachable, then
+// resyncs the cache and restarts the watches. It returns whether the
+// outage is over.
+func (ds *datastore) reconnect() bool {
+	if _, err := ds.store.Exists(Key()); isUnreachable(err) {
+		return false
+	}
+
+	ds.Lock()
+	defer ds.Unlock()
+
+	for len(ds.pending) > 0 {
+		w := ds.pending[0]
+		if err := ds.replay(w); isUnreachable(err) {
+			return false
+		} else if err != nil {
+			log.Warnf("Failed to replay the write of key %s queued during the store outage: %v", w.key, err)
+		}
+		ds.pending = ds.pending[1:]
+	}
+
+	ds.unreachable = false
+	ds.cache.resync()
+	ds.restartWatch()
+	log.Infof("Store of the %s scope reachable again", ds.scope)
+
+	return true
+}
+
+func (ds *datastore) replay(w *pendingWrite) error {
+	switch w.op {
+	case opPut:
+		return ds.store.Put(w.key, w.value, nil)
+	case opPutAtomic:
+		_, _, err := ds.store.AtomicPut(w.key, w.value, w.previous, nil)
+		return err
+	case opDelete:
+		if err := ds.store.Delete(w.key); err != store.ErrKeyNotFound {
+			return err
+		}
+	case opDeleteAtomic:
+		if _, err := ds.store.AtomicDelete(w.key, w.previous); err != store.ErrKeyNotFound {
+			return err
+		}
+	case opDeleteTree:
+		if err := ds.store.DeleteTree(w.key); err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package datastore
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/docker/docker/pkg/tlsconfig"
+	"github.com/docker/libkv/store"
+)
+
+// clientSecurity tells the TLS and authentication settings of the client
+// configuration the store providers support. The custom backends get the
+// TLS settings and the credentials in their libkv configuration.
+var clientSecurity = map[string]struct {
+	tls         bool
+	credentials bool
+	token       bool
+}{
+	string(store.CONSUL): {tls: true, token: true},
+	string(store.ETCD):   {tls: true, credentials: true},
+	"etcdv3":             {tls: true, credentials: true},
+	string(store.ZK):     {},
+	string(store.BOLTDB): {},
+}
+
+// validateSecurity checks the provider supports the TLS and authentication
+// settings of the client configuration
+func (cfg *ScopeClientCfg) validateSecurity() error {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return fmt.Errorf("both the certificate and the key of the %s store client must be set", cfg.Provider)
+	}
+	if cfg.Password != "" && cfg.Username == "" {
+		return fmt.Errorf("password of the %s store client set without a username", cfg.Provider)
+	}
+
+	sec, ok := clientSecurity[cfg.Provider]
+	if !ok {
+		sec.tls, sec.credentials = true, true
+	}
+	if !sec.tls && (cfg.CertFile != "" || cfg.CACertFile != "") {
+		return fmt.Errorf("store provider %s does not support TLS", cfg.Provider)
+	}
+	if !sec.credentials && cfg.Username != "" {
+		return fmt.Errorf("store provider %s does not support authentication by credentials", cfg.Provider)
+	}
+	if !sec.token && cfg.Token != "" {
+		return fmt.Errorf("store provider %s does not support authentication by token", cfg.Provider)
+	}
+	return nil
+}
+
+// StoreConfig returns the libkv configuration of the client, carrying its
+// TLS settings and credentials
+func (cfg *ScopeClientCfg) StoreConfig() (*store.Config, error) {
+	if err := cfg.validateSecurity(); err != nil {
+		return nil, err
+	}
+	if cfg.CertFile == "" && cfg.CACertFile == "" && cfg.Username == "" {
+		return cfg.Config, nil
+	}
+
+	config := &store.Config{}
+	if cfg.Config != nil {
+		*config = *cfg.Config
+	}
+	if cfg.CertFile != "" || cfg.CACertFile != "" {
+		tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:   cfg.CACertFile,
+			CertFile: cfg.CertFile,
+			KeyFile:  cfg.KeyFile,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to set up TLS for the %s store client: %v", cfg.Provider, err)
+		}
+		config.TLS = tlsConfig
+		// Workaround libkv/etcd bug for https
+		config.ClientTLS = &store.ClientTLSConfig{
+			CACertFile: cfg.CACertFile,
+			CertFile:   cfg.CertFile,
+			KeyFile:    cfg.KeyFile,
+		}
+	}
+	if cfg.Username != "" {
+		config.Username = cfg.Username
+		config.Password = cfg.Password
+	}
+	return config, nil
+}
+
+// The libkv consul stores share the default http client, and set their TLS
+// configuration in its transport. The ACL tokens of the consul servers are
+// set by a wrapper of that transport, adding them to the requests to the
+// servers.
+var (
+	consulTokensMu sync.Mutex
+	consulTokens   = make(map[string]string)
+)
+
+type consulTokenTransport struct {
+	base http.RoundTripper
+}
+
+func (t *consulTokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	consulTokensMu.Lock()
+	token, ok := consulTokens[r.URL.Host]
+	consulTokensMu.Unlock()
+
+	if ok {
+		// the request must not be modified
+		req := new(http.Request)
+		*req = *r
+		u := *r.URL
+		q := u.Query()
+		if q.Get("token") == "" {
+			q.Set("token", token)
+		}
+		u.RawQuery = q.Encode()
+		req.URL = &u
+		r = req
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
+
+// setConsulToken sets the ACL token of the consul servers, wrapping the
+// transport of the default http client if the creation of a consul store
+// replaced it
+func setConsulToken(addrs []string, token string) {
+	consulTokensMu.Lock()
+	defer consulTokensMu.Unlock()
+
+	for _, addr := range addrs {
+		if token != "" {
+			consulTokens[addr] = token
+		}
+	}
+	if len(consulTokens) == 0 {
+		return
+	}
+	if _, ok := http.DefaultClient.Transport.(*consulTokenTransport); !ok {
+		http.DefaultClient.Transport = &consulTokenTransport{base: http.DefaultClient.Transport}
+	}
+}
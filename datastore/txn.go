@@ -0,0 +1,142 @@
+package datastore
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libkv/store"
+	"github.com/docker/libnetwork/types"
+)
+
+// TxnOp is an operation of a transaction: the atomic put of the object or,
+// if Delete is set, its atomic deletion
+type TxnOp struct {
+	Object KVObject
+	Delete bool
+}
+
+// KVTxnOp is a write of a key in a store transaction, conditioned by the
+// previous pair of the key, nil if the key must not exist
+type KVTxnOp struct {
+	Key      string
+	Value    []byte
+	Previous *store.KVPair
+	Delete   bool
+}
+
+// TxnStore is implemented by the stores able to write several keys in a
+// single transaction
+type TxnStore interface {
+	// AtomicTxn applies all the writes if none of the keys changed since
+	// their previous pair, failing with ErrKeyModified otherwise. It
+	// returns the indexes of the keys put.
+	AtomicTxn(ops []*KVTxnOp) ([]uint64, error)
+}
+
+// Transaction performs the atomic writes of the operations in a single
+// transaction if the store supports it. Otherwise they are performed in
+// order, the objects created being deleted if one of them fails.
+func (ds *datastore) Transaction(ops ...TxnOp) error {
+	ds.Lock()
+	defer ds.Unlock()
+
+	var (
+		kvOps   []*KVTxnOp
+		written []KVObject
+	)
+	for _, op := range ops {
+		if op.Object == nil {
+			return types.BadRequestErrorf("invalid KV Object : nil")
+		}
+		if op.Object.Skip() {
+			continue
+		}
+
+		kvOp := &KVTxnOp{Key: Key(op.Object.Key()...), Delete: op.Delete}
+		if op.Delete || op.Object.Exists() {
+			kvOp.Previous = &store.KVPair{Key: kvOp.Key, LastIndex: op.Object.Index()}
+		}
+		if !op.Delete {
+			if kvOp.Value = op.Object.Value(); kvOp.Value == nil {
+				return types.BadRequestErrorf("invalid KV Object with a nil Value for key %s", kvOp.Key)
+			}
+		}
+		kvOps = append(kvOps, kvOp)
+		written = append(written, op.Object)
+	}
+
+	ts, ok := ds.store.(TxnStore)
+	if !ok || ds.caps != nil && !ds.caps.AtomicOps || ds.unreachable {
+		return ds.applyInOrder(ops)
+	}
+
+	indexes, err := ts.AtomicTxn(kvOps)
+	if err == store.ErrCallNotSupported {
+		return ds.applyInOrder(ops)
+	}
+	if err != nil {
+		if ds.reconnects() && isUnreachable(err) {
+			// the writes are queued
+			ds.markUnreachable()
+			return ds.applyInOrder(ops)
+		}
+		// the cached objects are stale
+		if ds.cache != nil && ds.cache.watched {
+			for _, o := range written {
+				if c := ds.cached(o); c != nil {
+					c.evict(o)
+				}
+			}
+		}
+		return err
+	}
+
+	for i, o := range written {
+		if !kvOps[i].Delete {
+			o.SetIndex(indexes[i])
+		}
+	}
+	for _, op := range ops {
+		c := ds.cached(op.Object)
+		if c == nil {
+			continue
+		}
+		if op.Delete {
+			err = c.del(op.Object)
+		} else {
+			err = c.add(op.Object)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyInOrder performs the operations of a transaction one after the
+// other, deleting the objects created by the first ones if one fails
+func (ds *datastore) applyInOrder(ops []TxnOp) error {
+	var created []KVObject
+	for _, op := range ops {
+		var err error
+		if op.Delete {
+			err = ds.deleteObjectAtomic(op.Object)
+		} else {
+			exists := op.Object.Exists()
+			if err = ds.putObjectAtomic(op.Object); err == nil && !exists && !op.Object.Skip() {
+				created = append(created, op.Object)
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		for _, o := range created {
+			if e := ds.deleteObjectAtomic(o); e != nil {
+				log.Warnf("Failed to roll back the creation of key %s on transaction failure: %v", Key(o.Key()...), e)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package dhcp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	clientPort6 = 546
+	serverPort6 = 547
+)
+
+// all DHCP relay agents and servers link-local multicast address
+var allServers = net.ParseIP("ff02::1:2")
+
+// listen6 opens the DHCPv6 client socket bound to the interface
+func listen6(ifName string) (net.PacketConn, error) {
+	fd, err := syscall.Socket(syscall.AF_INET6, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	if err := syscall.BindToDevice(fd, ifName); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind to interface %s: %v", ifName, err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrInet6{Port: clientPort6}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), "dhcp6-"+ifName)
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+// exchange6 multicasts the request to the servers of the link until a
+// reply of the type comes back
+func exchange6(conn net.PacketConn, ifName string, req *packet6, msgType byte) (*packet6, error) {
+	dst := &net.UDPAddr{IP: allServers, Port: serverPort6, Zone: ifName}
+	b := make([]byte, 1500)
+	for i := 0; i < exchangeAttempts; i++ {
+		if _, err := conn.WriteTo(req.marshal(), dst); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(exchangeTimeout)
+		conn.SetReadDeadline(deadline)
+		for time.Now().Before(deadline) {
+			n, _, err := conn.ReadFrom(b)
+			if err != nil {
+				break
+			}
+			reply, err := parsePacket6(b[:n])
+			if err != nil || reply.xid != req.xid || reply.msgType != msgType {
+				continue
+			}
+			return reply, nil
+		}
+	}
+	return nil, fmt.Errorf("no reply from the dhcpv6 server")
+}
+
+// AcquirePrefix obtains the delegation of a prefix of the length, or of
+// the length the router chooses when zero, through the interface on the
+// network of the upstream router
+func AcquirePrefix(ifName string, mac net.HardwareAddr, length int) (*PrefixLease, error) {
+	conn, err := listen6(ifName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var hint *net.IPNet
+	if length > 0 {
+		hint = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(length, 128)}
+	}
+	solicit := newPacket6(msg6Solicit, mac)
+	solicit.options[opt6IAPD] = iaPD(iaid(mac), hint)
+	adv, err := exchange6(conn, ifName, solicit, msg6Advertise)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := adv.prefixLease(time.Now()); err != nil {
+		return nil, err
+	}
+
+	req := newPacket6(msg6Request, mac)
+	req.options[opt6ServerID] = adv.options[opt6ServerID]
+	req.options[opt6IAPD] = adv.options[opt6IAPD]
+	reply, err := exchange6(conn, ifName, req, msg6Reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.prefixLease(time.Now())
+}
+
+// RenewPrefix renews the delegation of the prefix with the router which
+// delegated it
+func RenewPrefix(ifName string, mac net.HardwareAddr, lease *PrefixLease) (*PrefixLease, error) {
+	conn, err := listen6(ifName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := newPacket6(msg6Renew, mac)
+	req.options[opt6ServerID] = lease.Server
+	req.options[opt6IAPD] = iaPD(lease.IAID, lease.Prefix)
+	reply, err := exchange6(conn, ifName, req, msg6Reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.prefixLease(time.Now())
+}
+
+// ReleasePrefix gives the delegated prefix back to the router
+func ReleasePrefix(ifName string, mac net.HardwareAddr, lease *PrefixLease) error {
+	conn, err := listen6(ifName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	rel := newPacket6(msg6Release, mac)
+	rel.options[opt6ServerID] = lease.Server
+	rel.options[opt6IAPD] = iaPD(lease.IAID, lease.Prefix)
+	_, err = conn.WriteTo(rel.marshal(), &net.UDPAddr{IP: allServers, Port: serverPort6, Zone: ifName})
+	return err
+}
@@ -0,0 +1,162 @@
+package dhcp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	clientPort = 68
+	serverPort = 67
+
+	exchangeTimeout  = 3 * time.Second
+	exchangeAttempts = 3
+)
+
+// listen opens the client socket bound to the interface
+func listen(ifName string) (net.PacketConn, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range []int{syscall.SO_REUSEADDR, syscall.SO_BROADCAST} {
+		if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, opt, 1); err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+	}
+	if err := syscall.BindToDevice(fd, ifName); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind to interface %s: %v", ifName, err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Port: clientPort}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), "dhcp-"+ifName)
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+// exchange broadcasts the request until a reply of one of the types comes
+// back
+func exchange(conn net.PacketConn, req *packet, msgTypes ...byte) (*packet, error) {
+	dst := &net.UDPAddr{IP: net.IPv4bcast, Port: serverPort}
+	b := make([]byte, 1500)
+	for i := 0; i < exchangeAttempts; i++ {
+		if _, err := conn.WriteTo(req.marshal(), dst); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(exchangeTimeout)
+		conn.SetReadDeadline(deadline)
+		for time.Now().Before(deadline) {
+			n, _, err := conn.ReadFrom(b)
+			if err != nil {
+				break
+			}
+			reply, err := parsePacket(b[:n])
+			if err != nil || reply.op != opReply || reply.xid != req.xid {
+				continue
+			}
+			for _, t := range msgTypes {
+				if reply.msgType() == t {
+					return reply, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("no reply from the dhcp server")
+}
+
+// request sends the request of the address and returns the lease the
+// server acknowledges
+func request(conn net.PacketConn, req *packet) (*Lease, error) {
+	req.options[optParamList] = []byte{optSubnetMask, optRouter, optDNS, optLeaseTime, optRenewalTime}
+	reply, err := exchange(conn, req, msgAck, msgNak)
+	if err != nil {
+		return nil, err
+	}
+	if reply.msgType() == msgNak {
+		return nil, fmt.Errorf("dhcp server declined the request")
+	}
+	return reply.lease(time.Now())
+}
+
+// Acquire obtains a lease for the hardware address through the interface,
+// which is on the network of the DHCP server
+func Acquire(ifName string, mac net.HardwareAddr) (*Lease, error) {
+	conn, err := listen(ifName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	discover := newPacket(msgDiscover, mac)
+	offer, err := exchange(conn, discover, msgOffer)
+	if err != nil {
+		return nil, err
+	}
+
+	req := newPacket(msgRequest, mac)
+	req.xid = discover.xid
+	req.options[optRequestedIP] = offer.yiaddr.To4()
+	if server := offer.options[optServerID]; server != nil {
+		req.options[optServerID] = server
+	}
+	return request(conn, req)
+}
+
+// Request asks the server for the address through the interface, as a
+// client rebooting with its lease does
+func Request(ifName string, mac net.HardwareAddr, ip net.IP) (*Lease, error) {
+	conn, err := listen(ifName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := newPacket(msgRequest, mac)
+	req.options[optRequestedIP] = ip.To4()
+	lease, err := request(conn, req)
+	if err != nil {
+		return nil, err
+	}
+	if !lease.IP.IP.Equal(ip) {
+		return nil, fmt.Errorf("dhcp server leased %s in place of %s", lease.IP.IP, ip)
+	}
+	return lease, nil
+}
+
+// Renew renews the lease of the hardware address through the interface
+func Renew(ifName string, mac net.HardwareAddr, lease *Lease) (*Lease, error) {
+	conn, err := listen(ifName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := newPacket(msgRequest, mac)
+	req.ciaddr = lease.IP.IP
+	return request(conn, req)
+}
+
+// Release gives the lease of the hardware address back to the server
+func Release(ifName string, mac net.HardwareAddr, lease *Lease) error {
+	conn, err := listen(ifName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	rel := newPacket(msgRelease, mac)
+	rel.ciaddr = lease.IP.IP
+	if server := lease.Server.To4(); server != nil {
+		rel.options[optServerID] = server
+	}
+	_, err = conn.WriteTo(rel.marshal(), &net.UDPAddr{IP: net.IPv4bcast, Port: serverPort})
+	return err
+}
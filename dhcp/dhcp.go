@@ -0,0 +1,261 @@
+// Package dhcp implements the DHCPv4 client the network drivers obtain the
+// addresses of their endpoints from the DHCP server of the parent network
+// with, in place of the libnetwork IPAM.
+package dhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	opRequest = 1
+	opReply   = 2
+
+	msgDiscover = 1
+	msgOffer    = 2
+	msgRequest  = 3
+	msgAck      = 5
+	msgNak      = 6
+	msgRelease  = 7
+
+	optSubnetMask  = 1
+	optRouter      = 3
+	optDNS         = 6
+	optRequestedIP = 50
+	optLeaseTime   = 51
+	optMsgType     = 53
+	optServerID    = 54
+	optParamList   = 55
+	optRenewalTime = 58
+	optClientID    = 61
+	optEnd         = 255
+
+	// flag asking the server to broadcast its replies, the client
+	// interface having no address yet
+	flagBroadcast = 0x8000
+
+	headerLen = 236
+
+	// retry interval of the failed renewals
+	retryInterval = 30 * time.Second
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// Lease is an address leased by the DHCP server
+type Lease struct {
+	IP       *net.IPNet
+	Gateway  net.IP
+	Server   net.IP
+	DNS      []net.IP
+	Duration time.Duration
+	// time after which the lease is renewed
+	Renew    time.Duration
+	Obtained time.Time
+}
+
+// Expired returns whether the lease expired at the time
+func (l *Lease) Expired(now time.Time) bool {
+	return now.After(l.Obtained.Add(l.Duration))
+}
+
+type packet struct {
+	op      byte
+	xid     uint32
+	flags   uint16
+	ciaddr  net.IP
+	yiaddr  net.IP
+	chaddr  net.HardwareAddr
+	options map[byte][]byte
+}
+
+func newPacket(msgType byte, mac net.HardwareAddr) *packet {
+	return &packet{
+		op:     opRequest,
+		xid:    rand.Uint32(),
+		flags:  flagBroadcast,
+		chaddr: mac,
+		options: map[byte][]byte{
+			optMsgType:  {msgType},
+			optClientID: append([]byte{1}, mac...),
+		},
+	}
+}
+
+func (p *packet) msgType() byte {
+	if t := p.options[optMsgType]; len(t) == 1 {
+		return t[0]
+	}
+	return 0
+}
+
+func (p *packet) marshal() []byte {
+	b := make([]byte, headerLen, headerLen+64)
+	b[0] = p.op
+	b[1] = 1 // ethernet
+	b[2] = 6
+	binary.BigEndian.PutUint32(b[4:8], p.xid)
+	binary.BigEndian.PutUint16(b[10:12], p.flags)
+	if ip := p.ciaddr.To4(); ip != nil {
+		copy(b[12:16], ip)
+	}
+	if ip := p.yiaddr.To4(); ip != nil {
+		copy(b[16:20], ip)
+	}
+	copy(b[28:44], p.chaddr)
+	b = append(b, magicCookie...)
+
+	codes := make([]int, 0, len(p.options))
+	for code := range p.options {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		v := p.options[byte(code)]
+		b = append(b, byte(code), byte(len(v)))
+		b = append(b, v...)
+	}
+	return append(b, optEnd)
+}
+
+func parsePacket(b []byte) (*packet, error) {
+	if len(b) < headerLen+len(magicCookie) || string(b[headerLen:headerLen+4]) != string(magicCookie) {
+		return nil, fmt.Errorf("invalid dhcp packet")
+	}
+	p := &packet{
+		op:      b[0],
+		xid:     binary.BigEndian.Uint32(b[4:8]),
+		flags:   binary.BigEndian.Uint16(b[10:12]),
+		ciaddr:  net.IP(append([]byte(nil), b[12:16]...)),
+		yiaddr:  net.IP(append([]byte(nil), b[16:20]...)),
+		chaddr:  net.HardwareAddr(append([]byte(nil), b[28:28+int(b[2]&0xf)]...)),
+		options: make(map[byte][]byte),
+	}
+	opts := b[headerLen+4:]
+	for len(opts) > 0 {
+		code := opts[0]
+		if code == optEnd {
+			break
+		}
+		if code == 0 {
+			opts = opts[1:]
+			continue
+		}
+		if len(opts) < 2 || len(opts) < 2+int(opts[1]) {
+			return nil, fmt.Errorf("truncated dhcp option %d", code)
+		}
+		p.options[code] = append([]byte(nil), opts[2:2+int(opts[1])]...)
+		opts = opts[2+int(opts[1]):]
+	}
+	return p, nil
+}
+
+func ipOption(b []byte) net.IP {
+	if len(b) < 4 {
+		return nil
+	}
+	return net.IP(b[:4]).To16()
+}
+
+func durationOption(b []byte) time.Duration {
+	if len(b) != 4 {
+		return 0
+	}
+	return time.Duration(binary.BigEndian.Uint32(b)) * time.Second
+}
+
+// lease returns the lease the acknowledgement grants
+func (p *packet) lease(now time.Time) (*Lease, error) {
+	ip := p.yiaddr.To4()
+	if ip == nil || ip.IsUnspecified() {
+		return nil, fmt.Errorf("dhcp acknowledgement without address")
+	}
+	mask := ip.DefaultMask()
+	if m := p.options[optSubnetMask]; len(m) == 4 {
+		mask = net.IPMask(m)
+	}
+	l := &Lease{
+		IP:       &net.IPNet{IP: ip, Mask: mask},
+		Gateway:  ipOption(p.options[optRouter]),
+		Server:   ipOption(p.options[optServerID]),
+		Duration: durationOption(p.options[optLeaseTime]),
+		Renew:    durationOption(p.options[optRenewalTime]),
+		Obtained: now,
+	}
+	for dns := p.options[optDNS]; len(dns) >= 4; dns = dns[4:] {
+		l.DNS = append(l.DNS, ipOption(dns))
+	}
+	if l.Duration == 0 {
+		return nil, fmt.Errorf("dhcp acknowledgement without lease time")
+	}
+	if l.Renew == 0 || l.Renew > l.Duration {
+		l.Renew = l.Duration / 2
+	}
+	return l, nil
+}
+
+// Keeper renews a lease until stopped
+type Keeper struct {
+	lease  *Lease
+	stopCh chan struct{}
+	sync.Mutex
+}
+
+// Keep renews the lease with the function when due, until the keeper
+// is stopped
+func Keep(lease *Lease, renew func(*Lease) (*Lease, error)) *Keeper {
+	k := &Keeper{lease: lease, stopCh: make(chan struct{})}
+	go k.loop(renew)
+	return k
+}
+
+func (k *Keeper) loop(renew func(*Lease) (*Lease, error)) {
+	l := k.Lease()
+	next := l.Obtained.Add(l.Renew)
+	for {
+		select {
+		case <-k.stopCh:
+			return
+		case <-time.After(next.Sub(time.Now())):
+		}
+
+		nl, err := renew(l)
+		if err != nil {
+			if l.Expired(time.Now()) {
+				logrus.Errorf("dhcp lease of %s expired: %v", l.IP, err)
+			} else {
+				logrus.Warnf("dhcp lease renewal of %s failed: %v", l.IP, err)
+			}
+			next = time.Now().Add(retryInterval)
+			continue
+		}
+		if !nl.IP.IP.Equal(l.IP.IP) {
+			logrus.Errorf("dhcp server moved the lease of %s to %s", l.IP, nl.IP)
+		}
+		k.Lock()
+		k.lease = nl
+		k.Unlock()
+		l = nl
+		next = l.Obtained.Add(l.Renew)
+	}
+}
+
+// Lease returns the current lease
+func (k *Keeper) Lease() *Lease {
+	k.Lock()
+	defer k.Unlock()
+	return k.lease
+}
+
+// Stop stops the renewals of the lease
+func (k *Keeper) Stop() {
+	close(k.stopCh)
+}
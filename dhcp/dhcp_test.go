@@ -0,0 +1,115 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPacketLease(t *testing.T) {
+	mac, _ := net.ParseMAC("02:42:c0:a8:01:0a")
+	req := newPacket(msgRequest, mac)
+	req.ciaddr = net.ParseIP("192.168.1.10")
+
+	p, err := parsePacket(req.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.op != opRequest || p.xid != req.xid || p.flags != flagBroadcast || p.msgType() != msgRequest {
+		t.Fatalf("Unexpected request: %+v", p)
+	}
+	if p.chaddr.String() != mac.String() || !p.ciaddr.Equal(req.ciaddr) {
+		t.Fatalf("Unexpected request addresses: %+v", p)
+	}
+
+	ack := &packet{
+		op:     opReply,
+		xid:    req.xid,
+		yiaddr: net.ParseIP("192.168.1.10"),
+		chaddr: mac,
+		options: map[byte][]byte{
+			optMsgType:    {msgAck},
+			optSubnetMask: {255, 255, 255, 0},
+			optRouter:     {192, 168, 1, 1},
+			optServerID:   {192, 168, 1, 2},
+			optDNS:        {192, 168, 1, 3, 192, 168, 1, 4},
+			optLeaseTime:  {0, 0, 0x0e, 0x10},
+		},
+	}
+	p, err = parsePacket(ack.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	l, err := p.lease(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.IP.String() != "192.168.1.10/24" || !l.Gateway.Equal(net.ParseIP("192.168.1.1")) || !l.Server.Equal(net.ParseIP("192.168.1.2")) {
+		t.Fatalf("Unexpected lease: %+v", l)
+	}
+	if len(l.DNS) != 2 || l.Duration != time.Hour || l.Renew != 30*time.Minute {
+		t.Fatalf("Unexpected lease times or servers: %+v", l)
+	}
+	if l.Expired(now.Add(time.Minute)) || !l.Expired(now.Add(2*time.Hour)) {
+		t.Fatalf("Unexpected lease expiry")
+	}
+
+	delete(ack.options, optLeaseTime)
+	p, _ = parsePacket(ack.marshal())
+	if _, err := p.lease(now); err == nil {
+		t.Fatalf("Failed to detect a lease without lease time")
+	}
+}
+
+func TestPacketPrefixLease(t *testing.T) {
+	mac, _ := net.ParseMAC("02:42:c0:a8:01:0a")
+	_, hint, _ := net.ParseCIDR("::/56")
+	solicit := newPacket6(msg6Solicit, mac)
+	solicit.options[opt6IAPD] = iaPD(iaid(mac), hint)
+
+	p, err := parsePacket6(solicit.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.msgType != msg6Solicit || p.xid != solicit.xid || string(p.options[opt6ClientID]) != string(duid(mac)) {
+		t.Fatalf("Unexpected solicit: %+v", p)
+	}
+
+	_, prefix, _ := net.ParseCIDR("2001:db8:0:100::/56")
+	ia := iaPD(iaid(mac), prefix)
+	copy(ia[4:12], []byte{0, 0, 0x07, 0x08, 0, 0, 0x0b, 0x40})
+	copy(ia[16:24], []byte{0, 0, 0x0e, 0x10, 0, 0, 0x1c, 0x20})
+	reply := &packet6{
+		msgType: msg6Reply,
+		xid:     solicit.xid,
+		options: map[uint16][]byte{
+			opt6ServerID: {0, 3, 0, 1, 2, 0, 0, 0, 0, 1},
+			opt6IAPD:     ia,
+		},
+	}
+	p, err = parsePacket6(reply.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	l, err := p.prefixLease(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Prefix.String() != "2001:db8:0:100::/56" || l.IAID != iaid(mac) || len(l.Server) != 10 {
+		t.Fatalf("Unexpected prefix lease: %+v", l)
+	}
+	if l.Renew != 30*time.Minute || l.Preferred != time.Hour || l.Valid != 2*time.Hour {
+		t.Fatalf("Unexpected prefix lease times: %+v", l)
+	}
+	if l.Expired(now.Add(time.Hour)) || !l.Expired(now.Add(3*time.Hour)) {
+		t.Fatalf("Unexpected prefix lease expiry")
+	}
+
+	reply.options[opt6StatusCode] = []byte{0, 6, 'n', 'o'}
+	p, _ = parsePacket6(reply.marshal())
+	if _, err := p.prefixLease(now); err == nil {
+		t.Fatalf("Failed to detect an unsuccessful status")
+	}
+}
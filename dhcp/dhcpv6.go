@@ -0,0 +1,273 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// DHCPv6 prefix delegation, RFC 3633
+const (
+	msg6Solicit   = 1
+	msg6Advertise = 2
+	msg6Request   = 3
+	msg6Renew     = 5
+	msg6Reply     = 7
+	msg6Release   = 8
+
+	opt6ClientID    = 1
+	opt6ServerID    = 2
+	opt6ElapsedTime = 8
+	opt6StatusCode  = 13
+	opt6IAPD        = 25
+	opt6IAPrefix    = 26
+
+	statusSuccess = 0
+
+	// link-layer address DUID of an ethernet interface
+	duidLL         = 3
+	hwTypeEthernet = 1
+
+	iaPDLen     = 12
+	iaPrefixLen = 25
+)
+
+// PrefixLease is an IPv6 prefix delegated by the upstream router
+type PrefixLease struct {
+	Prefix *net.IPNet
+	// DUID of the delegating router
+	Server []byte
+	IAID   uint32
+	// time after which the lease is renewed
+	Renew     time.Duration
+	Preferred time.Duration
+	Valid     time.Duration
+	Obtained  time.Time
+}
+
+// Expired returns whether the delegation expired at the time
+func (l *PrefixLease) Expired(now time.Time) bool {
+	return now.After(l.Obtained.Add(l.Valid))
+}
+
+type packet6 struct {
+	msgType byte
+	xid     uint32
+	options map[uint16][]byte
+}
+
+// duid returns the link-layer address DUID of the hardware address
+func duid(mac net.HardwareAddr) []byte {
+	b := make([]byte, 4, 4+len(mac))
+	binary.BigEndian.PutUint16(b[0:2], duidLL)
+	binary.BigEndian.PutUint16(b[2:4], hwTypeEthernet)
+	return append(b, mac...)
+}
+
+// iaid returns the identity association id of the hardware address
+func iaid(mac net.HardwareAddr) uint32 {
+	if len(mac) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(mac[len(mac)-4:])
+}
+
+func newPacket6(msgType byte, mac net.HardwareAddr) *packet6 {
+	return &packet6{
+		msgType: msgType,
+		xid:     rand.Uint32() & 0xffffff,
+		options: map[uint16][]byte{
+			opt6ClientID:    duid(mac),
+			opt6ElapsedTime: {0, 0},
+		},
+	}
+}
+
+// iaPD returns the identity association for the delegation of the prefix,
+// or of a prefix of the length when the prefix IP is nil
+func iaPD(id uint32, prefix *net.IPNet) []byte {
+	b := make([]byte, iaPDLen, iaPDLen+4+iaPrefixLen)
+	binary.BigEndian.PutUint32(b[0:4], id)
+	if prefix == nil {
+		return b
+	}
+	ones, _ := prefix.Mask.Size()
+	p := make([]byte, iaPrefixLen)
+	p[8] = byte(ones)
+	if ip := prefix.IP.To16(); ip != nil {
+		copy(p[9:], ip)
+	}
+	b = append(b, 0, opt6IAPrefix, 0, iaPrefixLen)
+	return append(b, p...)
+}
+
+func (p *packet6) marshal() []byte {
+	b := []byte{p.msgType, byte(p.xid >> 16), byte(p.xid >> 8), byte(p.xid)}
+	codes := make([]int, 0, len(p.options))
+	for code := range p.options {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		v := p.options[uint16(code)]
+		b = append(b, byte(code>>8), byte(code), byte(len(v)>>8), byte(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
+
+// parseOptions6 returns the options encoded in b, the last occurrence of
+// a repeated option winning
+func parseOptions6(b []byte) (map[uint16][]byte, error) {
+	opts := make(map[uint16][]byte)
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("truncated dhcpv6 option")
+		}
+		code := binary.BigEndian.Uint16(b[0:2])
+		l := int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < 4+l {
+			return nil, fmt.Errorf("truncated dhcpv6 option %d", code)
+		}
+		opts[code] = append([]byte(nil), b[4:4+l]...)
+		b = b[4+l:]
+	}
+	return opts, nil
+}
+
+func parsePacket6(b []byte) (*packet6, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("invalid dhcpv6 packet")
+	}
+	opts, err := parseOptions6(b[4:])
+	if err != nil {
+		return nil, err
+	}
+	return &packet6{
+		msgType: b[0],
+		xid:     uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]),
+		options: opts,
+	}, nil
+}
+
+// statusError returns the error the status code option reports, if any
+func statusError(b []byte) error {
+	if len(b) < 2 || binary.BigEndian.Uint16(b[0:2]) == statusSuccess {
+		return nil
+	}
+	return fmt.Errorf("dhcpv6 server returned status %d: %s", binary.BigEndian.Uint16(b[0:2]), string(b[2:]))
+}
+
+// prefixLease returns the prefix the advertisement or the reply delegates
+func (p *packet6) prefixLease(now time.Time) (*PrefixLease, error) {
+	if err := statusError(p.options[opt6StatusCode]); err != nil {
+		return nil, err
+	}
+	ia := p.options[opt6IAPD]
+	if len(ia) < iaPDLen {
+		return nil, fmt.Errorf("dhcpv6 reply without prefix delegation")
+	}
+	opts, err := parseOptions6(ia[iaPDLen:])
+	if err != nil {
+		return nil, err
+	}
+	if err := statusError(opts[opt6StatusCode]); err != nil {
+		return nil, err
+	}
+	pfx := opts[opt6IAPrefix]
+	if len(pfx) < iaPrefixLen {
+		return nil, fmt.Errorf("dhcpv6 reply without delegated prefix")
+	}
+
+	ones := int(pfx[8])
+	if ones > 128 {
+		return nil, fmt.Errorf("invalid delegated prefix length %d", ones)
+	}
+	ip := net.IP(append([]byte(nil), pfx[9:25]...))
+	mask := net.CIDRMask(ones, 128)
+	l := &PrefixLease{
+		Prefix:    &net.IPNet{IP: ip.Mask(mask), Mask: mask},
+		Server:    p.options[opt6ServerID],
+		IAID:      binary.BigEndian.Uint32(ia[0:4]),
+		Renew:     time.Duration(binary.BigEndian.Uint32(ia[4:8])) * time.Second,
+		Preferred: time.Duration(binary.BigEndian.Uint32(pfx[0:4])) * time.Second,
+		Valid:     time.Duration(binary.BigEndian.Uint32(pfx[4:8])) * time.Second,
+		Obtained:  now,
+	}
+	if l.Valid == 0 {
+		return nil, fmt.Errorf("delegated prefix %s without valid lifetime", l.Prefix)
+	}
+	if l.Renew == 0 || l.Renew > l.Valid {
+		l.Renew = l.Preferred / 2
+		if l.Renew == 0 {
+			l.Renew = l.Valid / 2
+		}
+	}
+	return l, nil
+}
+
+// PrefixKeeper renews a prefix delegation until stopped
+type PrefixKeeper struct {
+	lease  *PrefixLease
+	stopCh chan struct{}
+	sync.Mutex
+}
+
+// KeepPrefix renews the delegation with the function when due, until the
+// keeper is stopped. The changed function is invoked when the renewal
+// delegates another prefix.
+func KeepPrefix(lease *PrefixLease, renew func(*PrefixLease) (*PrefixLease, error), changed func(old, new *PrefixLease)) *PrefixKeeper {
+	k := &PrefixKeeper{lease: lease, stopCh: make(chan struct{})}
+	go k.loop(renew, changed)
+	return k
+}
+
+func (k *PrefixKeeper) loop(renew func(*PrefixLease) (*PrefixLease, error), changed func(old, new *PrefixLease)) {
+	l := k.Lease()
+	next := l.Obtained.Add(l.Renew)
+	for {
+		select {
+		case <-k.stopCh:
+			return
+		case <-time.After(next.Sub(time.Now())):
+		}
+
+		nl, err := renew(l)
+		if err != nil {
+			if l.Expired(time.Now()) {
+				logrus.Errorf("delegation of prefix %s expired: %v", l.Prefix, err)
+			} else {
+				logrus.Warnf("renewal of delegated prefix %s failed: %v", l.Prefix, err)
+			}
+			next = time.Now().Add(retryInterval)
+			continue
+		}
+		k.Lock()
+		k.lease = nl
+		k.Unlock()
+		if nl.Prefix.String() != l.Prefix.String() {
+			logrus.Warnf("upstream router moved the delegated prefix %s to %s", l.Prefix, nl.Prefix)
+			changed(l, nl)
+		}
+		l = nl
+		next = l.Obtained.Add(l.Renew)
+	}
+}
+
+// Lease returns the current prefix delegation
+func (k *PrefixKeeper) Lease() *PrefixLease {
+	k.Lock()
+	defer k.Unlock()
+	return k.lease
+}
+
+// Stop stops the renewals of the delegation
+func (k *PrefixKeeper) Stop() {
+	close(k.stopCh)
+}
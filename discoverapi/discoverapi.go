@@ -32,4 +32,6 @@ type DatastoreConfigData struct {
 	Provider string
 	Address  string
 	Config   interface{}
+	// Prefix namespacing the keys of the scope
+	Prefix string
 }
@@ -2,8 +2,10 @@ package driverapi
 
 import (
 	"net"
+	"time"
 
 	"github.com/docker/libnetwork/discoverapi"
+	"github.com/docker/libnetwork/types"
 )
 
 // NetworkPluginEndpointType represents the Endpoint Type used by Plugin system
@@ -75,12 +77,91 @@ type Driver interface {
 	Type() string
 }
 
+// SubnetExtender is implemented by the drivers which can grow an existing
+// network with additional IPv4 subnets.
+type SubnetExtender interface {
+	// AddSubnet invokes the driver method to add the passed subnet
+	// to the network identified by the network id.
+	AddSubnet(nid string, ipV4Data IPAMData) error
+}
+
+// ExternalPeer is a host of a network which is not a libnetwork node, such
+// as a bare-metal host behind a hardware VTEP, reached through a tunnel
+// endpoint.
+type ExternalPeer struct {
+	// Address is the address of the peer in the network, with the mask
+	// of its subnet
+	Address *net.IPNet
+	// MacAddress is the mac address of the peer
+	MacAddress net.HardwareAddr
+	// Vtep is the tunnel endpoint the traffic to the peer is sent to
+	Vtep net.IP
+	// Prefixes are the external networks routed through the peer
+	Prefixes []*net.IPNet
+}
+
+// ExternalPeerRegistrar is implemented by the drivers which can reach
+// static external peers.
+type ExternalPeerRegistrar interface {
+	// AddExternalPeer registers the passed peer into the network
+	// identified by the network id.
+	AddExternalPeer(nid string, peer *ExternalPeer) error
+	// DeleteExternalPeer unregisters the peer of the passed address
+	// from the network identified by the network id.
+	DeleteExternalPeer(nid string, address net.IP) error
+}
+
+// EndpointStatistics is implemented by the drivers which can account
+// the traffic of their endpoints.
+type EndpointStatistics interface {
+	// EndpointStatistics returns the interface counters of the endpoint
+	// identified by the passed network and endpoint ids, as seen from
+	// the sandbox.
+	EndpointStatistics(nid, eid string) (*types.InterfaceStatistics, error)
+}
+
+// PeerStatus is the reachability of the tunnel endpoint of remote peers
+// as seen by the prober of the driver.
+type PeerStatus struct {
+	// Tunnel endpoint of the peers
+	Vtep net.IP
+	// Whether the tunnel endpoint replied to the recent probes
+	Reachable bool
+	// Round trip time of the last replied probe
+	Latency time.Duration
+	// Time of the last reply, zero when none was received
+	LastSeen time.Time
+}
+
+// PeerDiagnostics is implemented by the drivers which probe the tunnels
+// to the remote peers of their networks.
+type PeerDiagnostics interface {
+	// PeerStatus returns the status of the tunnel endpoints of the
+	// remote peers of the network identified by the network id.
+	PeerStatus(nid string) ([]*PeerStatus, error)
+}
+
 // NetworkInfo provides a go interface for drivers to provide network
 // specific information to libnetwork.
 type NetworkInfo interface {
 	// TableEventRegister registers driver interest in a given
 	// table name.
 	TableEventRegister(tableName string) error
+
+	// UpdateTableEntry updates the value of a table entry the driver
+	// added to the gossip layer on the join of a local endpoint.
+	UpdateTableEntry(tableName string, key string, value []byte) error
+
+	// AddTableEntry adds a table entry owned by the network rather than
+	// by one of its endpoints
+	AddTableEntry(tableName string, key string, value []byte) error
+
+	// DeleteTableEntry deletes a table entry owned by the network
+	DeleteTableEntry(tableName string, key string) error
+
+	// WalkTable walks the live entries of the network in the table,
+	// stopping when the passed function returns true
+	WalkTable(tableName string, fn func(key string, value []byte) bool) error
 }
 
 // InterfaceInfo provides a go interface for drivers to retrive
@@ -105,6 +186,16 @@ type InterfaceInfo interface {
 	AddressIPv6() *net.IPNet
 }
 
+// SecondaryAddressInfo is implemented by the InterfaceInfo of the endpoints
+// which can be assigned additional addresses on their interface.
+type SecondaryAddressInfo interface {
+	// SecondaryAddresses returns the additional IPv4 addresses.
+	SecondaryAddresses() []*net.IPNet
+
+	// SecondaryAddressesIPv6 returns the additional IPv6 addresses.
+	SecondaryAddressesIPv6() []*net.IPNet
+}
+
 // InterfaceNameInfo provides a go interface for the drivers to assign names
 // to interfaces.
 type InterfaceNameInfo interface {
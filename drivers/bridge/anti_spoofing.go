@@ -0,0 +1,105 @@
+package bridge
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+// antiSpoofingRules returns the ebtables rules dropping the frames sent
+// from the host interface with a source MAC or IP address other than the
+// endpoint ones. Both the bridged and the host bound frames are filtered.
+func antiSpoofingRules(hostIfName string, mac net.HardwareAddr, ips, ip6s []net.IP) [][]string {
+	var rules [][]string
+	for _, chain := range []string{"FORWARD", "INPUT"} {
+		rules = append(rules,
+			[]string{chain, "-i", hostIfName, "-s", "!", mac.String(), "-j", "DROP"})
+		rules = append(rules, sourceAddressRules(chain, hostIfName, "IPv4", "--ip-src", ips)...)
+		rules = append(rules,
+			[]string{chain, "-i", hostIfName, "-p", "ARP", "--arp-mac-src", "!", mac.String(), "-j", "DROP"})
+		rules = append(rules, sourceAddressRules(chain, hostIfName, "ARP", "--arp-ip-src", ips)...)
+		if len(ip6s) > 0 {
+			// Link local addresses are needed for the neighbor discovery
+			rules = append(rules,
+				[]string{chain, "-i", hostIfName, "-p", "IPv6", "--ip6-src", "fe80::/10", "-j", "ACCEPT"})
+			rules = append(rules, sourceAddressRules(chain, hostIfName, "IPv6", "--ip6-src", ip6s)...)
+		}
+	}
+	return rules
+}
+
+// sourceAddressRules returns the rules dropping the frames of the protocol
+// with a source address other than the passed ones. The frames of the
+// endpoints with secondary addresses are accepted address by address.
+func sourceAddressRules(chain, hostIfName, proto, opt string, ips []net.IP) [][]string {
+	if len(ips) == 1 {
+		return [][]string{{chain, "-i", hostIfName, "-p", proto, opt, "!", ips[0].String(), "-j", "DROP"}}
+	}
+
+	var rules [][]string
+	for _, ip := range ips {
+		rules = append(rules, []string{chain, "-i", hostIfName, "-p", proto, opt, ip.String(), "-j", "ACCEPT"})
+	}
+	return append(rules, []string{chain, "-i", hostIfName, "-p", proto, "-j", "DROP"})
+}
+
+// protectEndpoint pins the endpoint to its MAC and IP addresses so that
+// it cannot impersonate the other endpoints of the bridge, and programs
+// the static neighbor entries of its addresses on the bridge. Failures
+// in removing the rules and the entries are only logged.
+func (n *bridgeNetwork) protectEndpoint(ep *bridgeEndpoint, enable bool) error {
+	ips := []net.IP{ep.addr.IP}
+	var ip6s []net.IP
+	if ep.addrv6 != nil {
+		ip6s = append(ip6s, ep.addrv6.IP)
+	}
+	for _, addr := range ep.secondaryAddrs {
+		if addr.IP.To4() != nil {
+			ips = append(ips, addr.IP)
+		} else {
+			ip6s = append(ip6s, addr.IP)
+		}
+	}
+
+	action := "-A"
+	if !enable {
+		action = "-D"
+	}
+	for _, rule := range antiSpoofingRules(ep.hostIfName, ep.macAddress, ips, ip6s) {
+		args := append([]string{"-t", "filter", action}, rule...)
+		if out, err := exec.Command("ebtables", args...).CombinedOutput(); err != nil {
+			err = fmt.Errorf("unable to program ebtables rule %q: %v (%s)", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+			if enable {
+				return err
+			}
+			logrus.Warn(err)
+		}
+	}
+
+	for _, ip := range append(ips, ip6s...) {
+		family := netlink.FAMILY_V4
+		if ip.To4() == nil {
+			family = netlink.FAMILY_V6
+		}
+		neigh := &netlink.Neigh{
+			LinkIndex:    n.bridge.Link.Attrs().Index,
+			Family:       family,
+			State:        netlink.NUD_PERMANENT,
+			IP:           ip,
+			HardwareAddr: ep.macAddress,
+		}
+		if enable {
+			if err := netlink.NeighSet(neigh); err != nil {
+				return fmt.Errorf("unable to add the static neighbor entry for %s: %v", ip, err)
+			}
+		} else if err := netlink.NeighDel(neigh); err != nil {
+			logrus.Warnf("Failed to remove the static neighbor entry for %s: %v", ip, err)
+		}
+	}
+
+	return nil
+}
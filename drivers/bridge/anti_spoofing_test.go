@@ -0,0 +1,39 @@
+package bridge
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAntiSpoofingRules(t *testing.T) {
+	mac, _ := net.ParseMAC("02:42:ac:11:00:02")
+	ip := net.ParseIP("172.17.0.2")
+
+	rules := antiSpoofingRules("veth0", mac, []net.IP{ip}, nil)
+	if len(rules) != 8 {
+		t.Fatalf("Expected 8 rules, got %d", len(rules))
+	}
+	if r := strings.Join(rules[1], " "); r != "FORWARD -i veth0 -p IPv4 --ip-src ! 172.17.0.2 -j DROP" {
+		t.Fatalf("Unexpected rule: %s", r)
+	}
+
+	rules = antiSpoofingRules("veth0", mac, []net.IP{ip}, []net.IP{net.ParseIP("2001:db8::2")})
+	if len(rules) != 12 {
+		t.Fatalf("Expected 12 rules, got %d", len(rules))
+	}
+	if r := strings.Join(rules[5], " "); r != "FORWARD -i veth0 -p IPv6 --ip6-src ! 2001:db8::2 -j DROP" {
+		t.Fatalf("Unexpected rule: %s", r)
+	}
+
+	rules = antiSpoofingRules("veth0", mac, []net.IP{ip, net.ParseIP("172.17.0.3")}, nil)
+	if len(rules) != 16 {
+		t.Fatalf("Expected 16 rules, got %d", len(rules))
+	}
+	if r := strings.Join(rules[2], " "); r != "FORWARD -i veth0 -p IPv4 --ip-src 172.17.0.3 -j ACCEPT" {
+		t.Fatalf("Unexpected rule: %s", r)
+	}
+	if r := strings.Join(rules[3], " "); r != "FORWARD -i veth0 -p IPv4 -j DROP" {
+		t.Fatalf("Unexpected rule: %s", r)
+	}
+}
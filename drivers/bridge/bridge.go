@@ -49,6 +49,10 @@ type configuration struct {
 	EnableIPForwarding  bool
 	EnableIPTables      bool
 	EnableUserlandProxy bool
+	// FirewallBackend selects the framework the iptables rules are
+	// programmed into, "legacy" or "nftables". It is auto-detected if
+	// empty.
+	FirewallBackend string
 }
 
 // networkConfiguration for network specific configuration
@@ -60,6 +64,7 @@ type networkConfiguration struct {
 	EnableICC          bool
 	Mtu                int
 	DefaultBindingIP   net.IP
+	HostSNATIP         net.IP
 	DefaultBridge      bool
 	// Internal fields set after ipam data parsing
 	AddressIPv4        *net.IPNet
@@ -69,11 +74,39 @@ type networkConfiguration struct {
 	dbIndex            uint64
 	dbExists           bool
 	Internal           bool
+	// Bridge addresses on the subnets added to the network
+	SecondaryAddressesIPv4 []*net.IPNet
+	VlanFiltering          bool
+	// Per network overrides of the driver userland proxy setting
+	// and of the hairpin mode it implies, nil if not overridden
+	UserlandProxy *bool
+	HairpinMode   *bool
+	// The bridge existed before the network was created, and
+	// already had the network gateway address
+	AdoptedBridge      bool
+	AdoptedAddressIPv4 bool
+	PreserveBridge     bool
+	PortIsolation      bool
+	AntiSpoofing       bool
+	MulticastSnooping  *bool
+	MulticastQuerier   bool
+	MulticastDisabled  bool
+	// Conntrack zone of the connections originated on the bridge
+	ConntrackZone uint16
+	DHCPParent    string
+	// Transparent proxy of the outbound traffic, the host when
+	// the address is nil
+	EgressProxyIP    net.IP
+	EgressProxyPort  uint16
+	EgressProxyPorts []uint16
 }
 
 // endpointConfiguration represents the user specified configuration for the sandbox endpoint
 type endpointConfiguration struct {
 	MacAddress net.HardwareAddr
+	IccGroup   string
+	Vlan       uint16
+	Shaping    trafficShaping
 }
 
 // containerConfiguration represents the user specified configuration for a container
@@ -91,8 +124,10 @@ type connectivityConfiguration struct {
 type bridgeEndpoint struct {
 	id              string
 	srcName         string
+	hostIfName      string
 	addr            *net.IPNet
 	addrv6          *net.IPNet
+	secondaryAddrs  []*net.IPNet
 	macAddress      net.HardwareAddr
 	config          *endpointConfiguration // User specified parameters
 	containerConfig *containerConfiguration
@@ -157,6 +192,17 @@ func (c *networkConfiguration) Validate() error {
 		}
 	}
 
+	if c.HostSNATIP != nil && c.HostSNATIP.To4() == nil {
+		return types.BadRequestErrorf("host snat address %s is not an IPv4 address", c.HostSNATIP)
+	}
+
+	if len(c.EgressProxyPorts) > 0 && c.EgressProxyPort == 0 {
+		return types.BadRequestErrorf("egress proxy ports specified without an egress proxy")
+	}
+	if c.EgressProxyPort != 0 && (c.Internal || c.DHCPParent != "") {
+		return types.BadRequestErrorf("egress proxy is not supported on internal or dhcp passthrough networks")
+	}
+
 	// If default v6 gw is specified, AddressIPv6 must be specified and gw must belong to AddressIPv6 subnet
 	if c.EnableIPv6 && c.DefaultGatewayIPv6 != nil {
 		if c.AddressIPv6 == nil || !c.AddressIPv6.Contains(c.DefaultGatewayIPv6) {
@@ -222,6 +268,64 @@ func (c *networkConfiguration) fromLabels(labels map[string]string) error {
 			if c.DefaultBindingIP = net.ParseIP(value); c.DefaultBindingIP == nil {
 				return parseErr(label, value, "nil ip")
 			}
+		case HostSNATIP:
+			if c.HostSNATIP = net.ParseIP(value); c.HostSNATIP == nil {
+				return parseErr(label, value, "nil ip")
+			}
+		case MulticastSnooping:
+			var enable bool
+			if enable, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+			c.MulticastSnooping = &enable
+		case MulticastQuerier:
+			if c.MulticastQuerier, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+		case EnableMulticast:
+			var enable bool
+			if enable, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+			c.MulticastDisabled = !enable
+		case DHCPParent:
+			c.DHCPParent = value
+		case EgressProxy:
+			if c.EgressProxyIP, c.EgressProxyPort, err = parseEgressProxy(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+		case EgressProxyPorts:
+			if c.EgressProxyPorts, err = parsePortList(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+		case AntiSpoofing:
+			if c.AntiSpoofing, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+		case PortIsolation:
+			if c.PortIsolation, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+		case PreserveBridge:
+			if c.PreserveBridge, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+		case VlanFiltering:
+			if c.VlanFiltering, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+		case UserlandProxy:
+			var enable bool
+			if enable, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+			c.UserlandProxy = &enable
+		case HairpinMode:
+			var enable bool
+			if enable, err = strconv.ParseBool(value); err != nil {
+				return parseErr(label, value, err.Error())
+			}
+			c.HairpinMode = &enable
 		}
 	}
 
@@ -247,6 +351,36 @@ func (n *bridgeNetwork) getDriverChains() (*iptables.ChainInfo, *iptables.ChainI
 	return n.driver.natChain, n.driver.filterChain, n.driver.isolationChain, nil
 }
 
+// userlandProxy returns whether the port mappings of the network
+// use the userland proxy
+func (n *bridgeNetwork) userlandProxy() bool {
+	n.Lock()
+	config := n.config
+	d := n.driver
+	n.Unlock()
+
+	if config.UserlandProxy != nil {
+		return *config.UserlandProxy
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	return d.config.EnableUserlandProxy
+}
+
+// hairpinMode returns whether the containers of the network can reach
+// their own published ports via the host addresses
+func (n *bridgeNetwork) hairpinMode() bool {
+	n.Lock()
+	config := n.config
+	n.Unlock()
+
+	if config.HairpinMode != nil {
+		return *config.HairpinMode
+	}
+	return !n.userlandProxy()
+}
+
 func (n *bridgeNetwork) getNetworkBridgeName() string {
 	n.Lock()
 	config := n.config
@@ -325,7 +459,8 @@ func (c *networkConfiguration) conflictsWithNetworks(id string, others []*bridge
 		// bridges. This could not be completely caught by the config conflict
 		// check, because networks which config does not specify the AddressIPv4
 		// get their address and subnet selected by the driver (see electBridgeIPv4())
-		if c.AddressIPv4 != nil {
+		// Bridges in dhcp passthrough mode have no address.
+		if c.AddressIPv4 != nil && nwBridge.bridgeIPv4 != nil {
 			if nwBridge.bridgeIPv4.Contains(c.AddressIPv4.IP) ||
 				c.AddressIPv4.Contains(nwBridge.bridgeIPv4.IP) {
 				return types.ForbiddenErrorf("conflicts with network %s (%s) by ip network", nwID, nwConfig.BridgeName)
@@ -371,6 +506,9 @@ func (d *driver) configure(option map[string]interface{}) error {
 	}
 
 	if config.EnableIPTables {
+		if err := iptables.SetBackend(iptables.Backend(config.FirewallBackend)); err != nil {
+			return err
+		}
 		if _, err := os.Stat("/proc/sys/net/bridge"); err != nil {
 			if out, err := exec.Command("modprobe", "-va", "bridge", "br_netfilter").CombinedOutput(); err != nil {
 				logrus.Warnf("Running modprobe bridge br_netfilter failed with message: %s, error: %v", out, err)
@@ -443,18 +581,29 @@ func parseNetworkGenericOptions(data interface{}) (*networkConfiguration, error)
 }
 
 func (c *networkConfiguration) processIPAM(id string, ipamV4Data, ipamV6Data []driverapi.IPAMData) error {
-	if len(ipamV4Data) > 1 || len(ipamV6Data) > 1 {
-		return types.ForbiddenErrorf("bridge driver doesn't support multiple subnets")
+	if len(ipamV6Data) > 1 {
+		return types.ForbiddenErrorf("bridge driver doesn't support multiple ipv6 subnets")
 	}
 
 	if len(ipamV4Data) == 0 {
 		return types.BadRequestErrorf("bridge network %s requires ipv4 configuration", id)
 	}
 
+	if len(ipamV4Data) > 1 && c.Internal {
+		return types.ForbiddenErrorf("bridge driver doesn't support multiple subnets on internal networks")
+	}
+
 	if ipamV4Data[0].Gateway != nil {
 		c.AddressIPv4 = types.GetIPNetCopy(ipamV4Data[0].Gateway)
 	}
 
+	for _, d := range ipamV4Data[1:] {
+		if d.Gateway == nil {
+			return types.BadRequestErrorf("bridge network %s requires a gateway on subnet %s", id, d.Pool)
+		}
+		c.SecondaryAddressesIPv4 = append(c.SecondaryAddressesIPv4, types.GetIPNetCopy(d.Gateway))
+	}
+
 	if gw, ok := ipamV4Data[0].AuxAddresses[DefaultGatewayV4AuxKey]; ok {
 		c.DefaultGatewayIPv4 = gw.IP
 	}
@@ -523,6 +672,24 @@ func getV6Network(config *networkConfiguration, i *bridgeInterface) *net.IPNet {
 	return nil
 }
 
+// allocateConntrackZone returns the lowest conntrack zone not in use by
+// the bridge networks. Zone 0 is the default one and is never returned.
+func (d *driver) allocateConntrackZone() (uint16, error) {
+	used := make(map[uint16]bool)
+	for _, nw := range d.getNetworks() {
+		nw.Lock()
+		used[nw.config.ConntrackZone] = true
+		nw.Unlock()
+	}
+
+	for zone := uint16(1); zone != 0; zone++ {
+		if !used[zone] {
+			return zone, nil
+		}
+	}
+	return 0, types.NoServiceErrorf("no conntrack zone available")
+}
+
 // Return a slice of networks over which caller can iterate safely
 func (d *driver) getNetworks() []*bridgeNetwork {
 	d.Lock()
@@ -548,9 +715,6 @@ func (d *driver) EventNotify(etype driverapi.EventType, nid, tableName, key stri
 
 // Create a new network using bridge plugin
 func (d *driver) CreateNetwork(id string, option map[string]interface{}, nInfo driverapi.NetworkInfo, ipV4Data, ipV6Data []driverapi.IPAMData) error {
-	if len(ipV4Data) == 0 || ipV4Data[0].Pool.String() == "0.0.0.0/0" {
-		return types.BadRequestErrorf("ipv4 pool is empty")
-	}
 	// Sanity checks
 	d.Lock()
 	if _, ok := d.networks[id]; ok {
@@ -565,11 +729,39 @@ func (d *driver) CreateNetwork(id string, option map[string]interface{}, nInfo d
 		return err
 	}
 
-	err = config.processIPAM(id, ipV4Data, ipV6Data)
+	// In dhcp passthrough mode the network has no ipam data
+	if config.DHCPParent != "" {
+		err = config.validateDHCPPassthrough(ipV4Data, ipV6Data)
+	} else if len(ipV4Data) == 0 || ipV4Data[0].Pool.String() == "0.0.0.0/0" {
+		err = types.BadRequestErrorf("ipv4 pool is empty")
+	} else {
+		err = config.processIPAM(id, ipV4Data, ipV6Data)
+	}
 	if err != nil {
 		return err
 	}
 
+	if !config.DefaultBridge {
+		if err = config.adoptBridge(); err != nil {
+			return err
+		}
+	}
+
+	if err = config.validateUplinkMtu(); err != nil {
+		return err
+	}
+
+	if config.ConntrackZone, err = d.allocateConntrackZone(); err != nil {
+		return err
+	}
+
+	// Isolated ports only stop the bridged traffic, the traffic routed
+	// through the gateway is dropped by disabling icc.
+	if config.PortIsolation && config.EnableICC {
+		logrus.Infof("Disabling icc on bridge network %s as it isolates its ports", id)
+		config.EnableICC = false
+	}
+
 	if err = d.createNetwork(config); err != nil {
 		return err
 	}
@@ -651,8 +843,16 @@ func (d *driver) createNetwork(config *networkConfiguration) error {
 		bridgeSetup.queueStep(setupDevice)
 	}
 
+	// The bridge has no address nor rules in dhcp passthrough mode
+	passthrough := config.DHCPParent != ""
+
 	// Even if a bridge exists try to setup IPv4.
-	bridgeSetup.queueStep(setupBridgeIPv4)
+	if !passthrough {
+		bridgeSetup.queueStep(setupBridgeIPv4)
+	}
+	if len(config.SecondaryAddressesIPv4) > 0 {
+		bridgeSetup.queueStep(setupBridgeSecondaryIPv4)
+	}
 
 	enableIPv6Forwarding := d.config.EnableIPForwarding && config.AddressIPv6 != nil
 
@@ -669,20 +869,36 @@ func (d *driver) createNetwork(config *networkConfiguration) error {
 
 		// We ensure that the bridge has the expectedIPv4 and IPv6 addresses in
 		// the case of a previously existing device.
-		{bridgeAlreadyExists, setupVerifyAndReconcile},
+		{bridgeAlreadyExists && !passthrough, setupVerifyAndReconcile},
 
 		// Enable IPv6 Forwarding
 		{enableIPv6Forwarding, setupIPv6Forwarding},
 
+		// Propagate the network MTU to the bridge, the MTU of an
+		// adopted bridge is left to its owner
+		{config.Mtu != 0 && !config.AdoptedBridge, setupBridgeMTU},
+
+		// Enable the VLAN filtering on the bridge
+		{config.VlanFiltering, setupVlanFiltering},
+
+		// Configure the multicast snooping and filtering
+		{config.MulticastSnooping != nil || config.MulticastQuerier || config.MulticastDisabled, network.setupMulticast},
+
+		// Attach the parent interface in dhcp passthrough mode
+		{passthrough, setupDHCPParent},
+
 		// Setup Loopback Adresses Routing
-		{!d.config.EnableUserlandProxy, setupLoopbackAdressesRouting},
+		{network.hairpinMode() && !passthrough, setupLoopbackAdressesRouting},
 
 		// Setup IPTables.
-		{d.config.EnableIPTables, network.setupIPTables},
+		{d.config.EnableIPTables && !passthrough, network.setupIPTables},
+
+		// Redirect the outbound traffic to the egress proxy
+		{d.config.EnableIPTables && config.EgressProxyPort != 0, network.setupEgressProxy},
 
 		//We want to track firewalld configuration so that
 		//if it is started/reloaded, the rules can be applied correctly
-		{d.config.EnableIPTables, network.setupFirewalld},
+		{d.config.EnableIPTables && !passthrough, network.setupFirewalld},
 
 		// Setup DefaultGatewayIPv4
 		{config.DefaultGatewayIPv4 != nil, setupGatewayIPv4},
@@ -691,10 +907,10 @@ func (d *driver) createNetwork(config *networkConfiguration) error {
 		{config.DefaultGatewayIPv6 != nil, setupGatewayIPv6},
 
 		// Add inter-network communication rules.
-		{d.config.EnableIPTables, setupNetworkIsolationRules},
+		{d.config.EnableIPTables && !passthrough, setupNetworkIsolationRules},
 
 		//Configure bridge networking filtering if ICC is off and IP tables are enabled
-		{!config.EnableICC && d.config.EnableIPTables, setupBridgeNetFiltering},
+		{!config.EnableICC && d.config.EnableIPTables && !passthrough, setupBridgeNetFiltering},
 	} {
 		if step.Condition {
 			bridgeSetup.queueStep(step.Fn)
@@ -710,6 +926,135 @@ func (d *driver) createNetwork(config *networkConfiguration) error {
 	return nil
 }
 
+// AddSubnet adds a secondary IPv4 subnet to an existing bridge network:
+// the bridge gets the subnet gateway address and, unless masquerading is
+// disabled, the subnet traffic is NATed like the one of the original subnet.
+func (d *driver) AddSubnet(nid string, ipV4Data driverapi.IPAMData) error {
+	if ipV4Data.Gateway == nil {
+		return types.BadRequestErrorf("bridge network %s requires a gateway on subnet %s", nid, ipV4Data.Pool)
+	}
+
+	n, err := d.getNetwork(nid)
+	if err != nil {
+		return err
+	}
+
+	n.Lock()
+	config := n.config
+	n.Unlock()
+
+	if config.Internal {
+		return types.ForbiddenErrorf("bridge driver doesn't support multiple subnets on internal networks")
+	}
+
+	addr := types.GetIPNetCopy(ipV4Data.Gateway)
+	for _, a := range append([]*net.IPNet{config.AddressIPv4}, config.SecondaryAddressesIPv4...) {
+		if a != nil && (a.Contains(addr.IP) || addr.Contains(a.IP)) {
+			return types.ForbiddenErrorf("subnet %s overlaps with bridge network %s address %s", ipV4Data.Pool, nid, a)
+		}
+	}
+
+	defer osl.InitOSContext()()
+
+	if err = n.bridge.programSecondaryIPv4(addr); err != nil {
+		return err
+	}
+
+	d.Lock()
+	enableIPTables := d.config.EnableIPTables
+	d.Unlock()
+
+	if enableIPTables {
+		if err = n.setupSecondaryIPTables(config, addr); err != nil {
+			if err := netlink.AddrDel(n.bridge.Link, &netlink.Addr{IPNet: addr}); err != nil {
+				logrus.Warnf("Failed to remove address %s from bridge %s on cleanup: %v", addr, config.BridgeName, err)
+			}
+			return err
+		}
+	}
+
+	n.Lock()
+	config.SecondaryAddressesIPv4 = append(config.SecondaryAddressesIPv4, addr)
+	n.Unlock()
+
+	return d.storeUpdate(config)
+}
+
+// releaseBridgeAddresses removes from the bridge the addresses the
+// network assigned to it
+func (n *bridgeNetwork) releaseBridgeAddresses() {
+	n.Lock()
+	config := n.config
+	n.Unlock()
+
+	addrs := append([]*net.IPNet{}, config.SecondaryAddressesIPv4...)
+	if !config.AdoptedAddressIPv4 && config.AddressIPv4 != nil {
+		addrs = append(addrs, config.AddressIPv4)
+	}
+	for _, addr := range addrs {
+		if err := netlink.AddrDel(n.bridge.Link, &netlink.Addr{IPNet: addr}); err != nil {
+			logrus.Warnf("Failed to remove address %s from bridge %s on network %s delete: %v", addr, config.BridgeName, config.ID, err)
+		}
+	}
+}
+
+// isolateEndpoint programs the rules preventing the communication between
+// the endpoint and the ones of the network in a different icc group.
+// Failures in removing the rules are only logged.
+func (n *bridgeNetwork) isolateEndpoint(ep *bridgeEndpoint, enable bool) error {
+	n.Lock()
+	config := n.config
+	var peers []*bridgeEndpoint
+	for _, e := range n.endpoints {
+		if e == ep || e.addr == nil || e.config == nil {
+			continue
+		}
+		if e.config.IccGroup != "" && e.config.IccGroup != ep.config.IccGroup {
+			peers = append(peers, e)
+		}
+	}
+	n.Unlock()
+
+	if len(peers) == 0 {
+		return nil
+	}
+
+	// The traffic between the endpoints is bridged, it must traverse
+	// iptables for the rules to apply.
+	if enable {
+		if err := setupBridgeNetFiltering(config, n.bridge); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range peers {
+		if err := setIccGroupRules(config.BridgeName, ep.addr.IP, p.addr.IP, enable); err != nil {
+			if enable {
+				return err
+			}
+			logrus.Warnf("Failed to remove the icc group rules between %s and %s: %v", ep.addr.IP, p.addr.IP, err)
+		}
+	}
+
+	return nil
+}
+
+// gatewayIPv4 returns the gateway for the endpoint with the passed address,
+// which is the bridge address on the endpoint subnet.
+func (n *bridgeNetwork) gatewayIPv4(addr *net.IPNet) net.IP {
+	n.Lock()
+	defer n.Unlock()
+
+	if addr != nil && !n.bridge.bridgeIPv4.Contains(addr.IP) {
+		for _, a := range n.config.SecondaryAddressesIPv4 {
+			if a.Contains(addr.IP) {
+				return a.IP
+			}
+		}
+	}
+	return n.bridge.gatewayIPv4
+}
+
 func (d *driver) DeleteNetwork(nid string) error {
 	var err error
 
@@ -757,7 +1102,14 @@ func (d *driver) DeleteNetwork(nid string) error {
 	}
 
 	// We only delete the bridge when it's not the default bridge. This is keep the backward compatible behavior.
-	if !config.DefaultBridge {
+	// Preserved bridges are left in place, with the addresses they had before the network was created.
+	if config.DHCPParent != "" {
+		releaseDHCPParent(config)
+	}
+	switch {
+	case config.AdoptedBridge || config.PreserveBridge:
+		n.releaseBridgeAddresses()
+	case !config.DefaultBridge:
 		if err := netlink.LinkDel(n.bridge.Link); err != nil {
 			logrus.Warnf("Failed to remove bridge interface %s on network %s delete: %v", config.BridgeName, nid, err)
 		}
@@ -795,6 +1147,25 @@ func addToBridge(ifaceName, bridgeName string) error {
 	return nil
 }
 
+// setPortIsolation sets the isolated flag on the bridge port, isolated
+// ports can only forward traffic to the non isolated ones. The vendored
+// netlink lacks the flag, it is set via sysfs.
+func setPortIsolation(link netlink.Link, enable bool) error {
+	path := filepath.Join("/sys/class/net", link.Attrs().Name, "brport/isolated")
+
+	var val []byte
+	if enable {
+		val = []byte{'1', '\n'}
+	} else {
+		val = []byte{'0', '\n'}
+	}
+
+	if err := ioutil.WriteFile(path, val, 0644); err != nil {
+		return fmt.Errorf("unable to set port isolation on %s via sysfs: %v", link.Attrs().Name, err)
+	}
+	return nil
+}
+
 func setHairpinMode(link netlink.Link, enable bool) error {
 	err := netlink.LinkSetHairpin(link, enable)
 	if err != nil && err != syscall.EINVAL {
@@ -949,27 +1320,57 @@ func (d *driver) CreateEndpoint(nid, eid string, ifInfo driverapi.InterfaceInfo,
 		return fmt.Errorf("adding interface %s to bridge %s failed: %v", hostIfName, config.BridgeName, err)
 	}
 
-	if !dconfig.EnableUserlandProxy {
+	if n.hairpinMode() {
 		err = setHairpinMode(host, true)
 		if err != nil {
 			return err
 		}
 	}
 
+	if config.PortIsolation {
+		if err = setPortIsolation(host, true); err != nil {
+			return err
+		}
+	}
+
+	if epConfig != nil && epConfig.Vlan != 0 {
+		if !config.VlanFiltering {
+			err = types.ForbiddenErrorf("cannot tag endpoint %s into vlan %d: vlan filtering is disabled on network %s", eid, epConfig.Vlan, nid)
+			return err
+		}
+		if err = setPortVlan(host, epConfig.Vlan); err != nil {
+			return err
+		}
+	}
+
 	// Store the sandbox side pipe interface parameters
 	endpoint.srcName = containerIfName
+	endpoint.hostIfName = hostIfName
 	endpoint.macAddress = ifInfo.MacAddress()
 	endpoint.addr = ifInfo.Address()
 	endpoint.addrv6 = ifInfo.AddressIPv6()
+	if sai, ok := ifInfo.(driverapi.SecondaryAddressInfo); ok {
+		endpoint.secondaryAddrs = append(sai.SecondaryAddresses(), sai.SecondaryAddressesIPv6()...)
+	}
 
 	// Set the sbox's MAC if not provided. If specified, use the one configured by user, otherwise generate one based on IP.
 	if endpoint.macAddress == nil {
-		endpoint.macAddress = electMacAddress(epConfig, endpoint.addr.IP)
+		var ip net.IP
+		if endpoint.addr != nil {
+			ip = endpoint.addr.IP
+		}
+		endpoint.macAddress = electMacAddress(epConfig, ip)
 		if err = ifInfo.SetMacAddress(endpoint.macAddress); err != nil {
 			return err
 		}
 	}
 
+	if epConfig != nil {
+		if err = epConfig.Shaping.program(host); err != nil {
+			return fmt.Errorf("could not shape the traffic of host interface %s: %v", hostIfName, err)
+		}
+	}
+
 	// Up the host interface after finishing all netlink configuration
 	if err = netlink.LinkSetUp(host); err != nil {
 		return fmt.Errorf("could not set link up for host interface %s: %v", hostIfName, err)
@@ -1000,6 +1401,26 @@ func (d *driver) CreateEndpoint(nid, eid string, ifInfo driverapi.InterfaceInfo,
 		}
 	}
 
+	if config.AntiSpoofing {
+		if err = n.protectEndpoint(endpoint, true); err != nil {
+			n.protectEndpoint(endpoint, false)
+			return err
+		}
+		defer func() {
+			if err != nil {
+				n.protectEndpoint(endpoint, false)
+			}
+		}()
+	}
+
+	// With icc disabled the endpoints are already isolated from each other
+	if epConfig != nil && epConfig.IccGroup != "" && endpoint.addr != nil && config.EnableICC && dconfig.EnableIPTables {
+		if err = n.isolateEndpoint(endpoint, true); err != nil {
+			n.isolateEndpoint(endpoint, false)
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1011,6 +1432,7 @@ func (d *driver) DeleteEndpoint(nid, eid string) error {
 	// Get the network handler and make sure it exists
 	d.Lock()
 	n, ok := d.networks[nid]
+	dconfig := d.config
 	d.Unlock()
 
 	if !ok {
@@ -1054,6 +1476,17 @@ func (d *driver) DeleteEndpoint(nid, eid string) error {
 		}
 	}()
 
+	if ep.config != nil && ep.config.IccGroup != "" && ep.addr != nil && dconfig.EnableIPTables {
+		n.isolateEndpoint(ep, false)
+	}
+
+	n.Lock()
+	antiSpoofing := n.config.AntiSpoofing
+	n.Unlock()
+	if antiSpoofing {
+		n.protectEndpoint(ep, false)
+	}
+
 	// Try removal of link. Discard error: it is a best effort.
 	// Also make sure defer does not see this error either.
 	if link, err := netlink.LinkByName(ep.srcName); err == nil {
@@ -1148,7 +1581,7 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 		return err
 	}
 
-	err = jinfo.SetGateway(network.bridge.gatewayIPv4)
+	err = jinfo.SetGateway(network.gatewayIPv4(endpoint.addr))
 	if err != nil {
 		return err
 	}
@@ -1158,6 +1591,11 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 		return err
 	}
 
+	// The default gateway comes from the dhcp server in passthrough mode
+	if endpoint.addr == nil {
+		jinfo.DisableGatewayService()
+	}
+
 	return nil
 }
 
@@ -1210,8 +1648,17 @@ func (d *driver) ProgramExternalConnectivity(nid, eid string, options map[string
 		return err
 	}
 
+	// The ports of an endpoint without address, in dhcp passthrough
+	// mode, cannot be mapped
+	if endpoint.addr == nil {
+		if endpoint.extConnConfig != nil && len(endpoint.extConnConfig.PortBindings) > 0 {
+			return types.ForbiddenErrorf("port mappings are not supported on dhcp passthrough network %s", nid)
+		}
+		return nil
+	}
+
 	// Program any required port mapping and store them in the endpoint
-	endpoint.portMapping, err = network.allocatePorts(endpoint, network.config.DefaultBindingIP, d.config.EnableUserlandProxy)
+	endpoint.portMapping, err = network.allocatePorts(endpoint, network.config.DefaultBindingIP, network.userlandProxy())
 	if err != nil {
 		return err
 	}
@@ -1355,6 +1802,26 @@ func parseEndpointOptions(epOptions map[string]interface{}) (*endpointConfigurat
 		}
 	}
 
+	if opt, ok := epOptions[IccGroup]; ok {
+		if group, ok := opt.(string); ok {
+			ec.IccGroup = group
+		} else {
+			return nil, &ErrInvalidEndpointConfig{}
+		}
+	}
+
+	if opt, ok := epOptions[Vlan]; ok {
+		vlan, err := parseVlan(opt)
+		if err != nil {
+			return nil, err
+		}
+		ec.Vlan = vlan
+	}
+
+	if err := ec.Shaping.parse(epOptions); err != nil {
+		return nil, err
+	}
+
 	return ec, nil
 }
 
@@ -1410,5 +1877,8 @@ func electMacAddress(epConfig *endpointConfiguration, ip net.IP) net.HardwareAdd
 	if epConfig != nil && epConfig.MacAddress != nil {
 		return epConfig.MacAddress
 	}
+	if ip == nil {
+		return netutils.GenerateRandomMAC()
+	}
 	return netutils.GenerateMACFromIP(ip)
 }
@@ -50,6 +50,12 @@ func (d *driver) populateNetworks() error {
 		}
 	}
 
+	// The rules of the restored networks are in place, remove the ones
+	// left behind by the previous run
+	if d.config.EnableIPTables {
+		d.reconcileIPTables()
+	}
+
 	return nil
 }
 
@@ -95,8 +101,39 @@ func (ncfg *networkConfiguration) MarshalJSON() ([]byte, error) {
 	nMap["EnableICC"] = ncfg.EnableICC
 	nMap["Mtu"] = ncfg.Mtu
 	nMap["Internal"] = ncfg.Internal
+	nMap["VlanFiltering"] = ncfg.VlanFiltering
+	nMap["AdoptedBridge"] = ncfg.AdoptedBridge
+	nMap["AdoptedAddressIPv4"] = ncfg.AdoptedAddressIPv4
+	nMap["PreserveBridge"] = ncfg.PreserveBridge
+	nMap["PortIsolation"] = ncfg.PortIsolation
+	nMap["AntiSpoofing"] = ncfg.AntiSpoofing
+	if ncfg.MulticastSnooping != nil {
+		nMap["MulticastSnooping"] = *ncfg.MulticastSnooping
+	}
+	nMap["MulticastQuerier"] = ncfg.MulticastQuerier
+	nMap["MulticastDisabled"] = ncfg.MulticastDisabled
+	nMap["ConntrackZone"] = ncfg.ConntrackZone
+	if ncfg.DHCPParent != "" {
+		nMap["DHCPParent"] = ncfg.DHCPParent
+	}
+	if ncfg.EgressProxyPort != 0 {
+		if ncfg.EgressProxyIP != nil {
+			nMap["EgressProxyIP"] = ncfg.EgressProxyIP.String()
+		}
+		nMap["EgressProxyPort"] = ncfg.EgressProxyPort
+		nMap["EgressProxyPorts"] = formatPortList(ncfg.EgressProxyPorts)
+	}
+	if ncfg.UserlandProxy != nil {
+		nMap["UserlandProxy"] = *ncfg.UserlandProxy
+	}
+	if ncfg.HairpinMode != nil {
+		nMap["HairpinMode"] = *ncfg.HairpinMode
+	}
 	nMap["DefaultBridge"] = ncfg.DefaultBridge
 	nMap["DefaultBindingIP"] = ncfg.DefaultBindingIP.String()
+	if ncfg.HostSNATIP != nil {
+		nMap["HostSNATIP"] = ncfg.HostSNATIP.String()
+	}
 	nMap["DefaultGatewayIPv4"] = ncfg.DefaultGatewayIPv4.String()
 	nMap["DefaultGatewayIPv6"] = ncfg.DefaultGatewayIPv6.String()
 
@@ -108,6 +145,14 @@ func (ncfg *networkConfiguration) MarshalJSON() ([]byte, error) {
 		nMap["AddressIPv6"] = ncfg.AddressIPv6.String()
 	}
 
+	if len(ncfg.SecondaryAddressesIPv4) > 0 {
+		addrs := make([]string, 0, len(ncfg.SecondaryAddressesIPv4))
+		for _, a := range ncfg.SecondaryAddressesIPv4 {
+			addrs = append(addrs, a.String())
+		}
+		nMap["SecondaryAddressesIPv4"] = addrs
+	}
+
 	return json.Marshal(nMap)
 }
 
@@ -133,8 +178,21 @@ func (ncfg *networkConfiguration) UnmarshalJSON(b []byte) error {
 		}
 	}
 
+	if v, ok := nMap["SecondaryAddressesIPv4"]; ok {
+		for _, a := range v.([]interface{}) {
+			addr, err := types.ParseCIDR(a.(string))
+			if err != nil {
+				return types.InternalErrorf("failed to decode bridge network secondary address IPv4 after json unmarshal: %s", a.(string))
+			}
+			ncfg.SecondaryAddressesIPv4 = append(ncfg.SecondaryAddressesIPv4, addr)
+		}
+	}
+
 	ncfg.DefaultBridge = nMap["DefaultBridge"].(bool)
 	ncfg.DefaultBindingIP = net.ParseIP(nMap["DefaultBindingIP"].(string))
+	if v, ok := nMap["HostSNATIP"]; ok {
+		ncfg.HostSNATIP = net.ParseIP(v.(string))
+	}
 	ncfg.DefaultGatewayIPv4 = net.ParseIP(nMap["DefaultGatewayIPv4"].(string))
 	ncfg.DefaultGatewayIPv6 = net.ParseIP(nMap["DefaultGatewayIPv6"].(string))
 	ncfg.ID = nMap["ID"].(string)
@@ -146,6 +204,59 @@ func (ncfg *networkConfiguration) UnmarshalJSON(b []byte) error {
 	if v, ok := nMap["Internal"]; ok {
 		ncfg.Internal = v.(bool)
 	}
+	if v, ok := nMap["VlanFiltering"]; ok {
+		ncfg.VlanFiltering = v.(bool)
+	}
+	if v, ok := nMap["AdoptedBridge"]; ok {
+		ncfg.AdoptedBridge = v.(bool)
+	}
+	if v, ok := nMap["AdoptedAddressIPv4"]; ok {
+		ncfg.AdoptedAddressIPv4 = v.(bool)
+	}
+	if v, ok := nMap["PreserveBridge"]; ok {
+		ncfg.PreserveBridge = v.(bool)
+	}
+	if v, ok := nMap["PortIsolation"]; ok {
+		ncfg.PortIsolation = v.(bool)
+	}
+	if v, ok := nMap["AntiSpoofing"]; ok {
+		ncfg.AntiSpoofing = v.(bool)
+	}
+	if v, ok := nMap["MulticastSnooping"]; ok {
+		enable := v.(bool)
+		ncfg.MulticastSnooping = &enable
+	}
+	if v, ok := nMap["MulticastQuerier"]; ok {
+		ncfg.MulticastQuerier = v.(bool)
+	}
+	if v, ok := nMap["MulticastDisabled"]; ok {
+		ncfg.MulticastDisabled = v.(bool)
+	}
+	if v, ok := nMap["DHCPParent"]; ok {
+		ncfg.DHCPParent = v.(string)
+	}
+	if v, ok := nMap["EgressProxyIP"]; ok {
+		ncfg.EgressProxyIP = net.ParseIP(v.(string))
+	}
+	if v, ok := nMap["EgressProxyPort"]; ok {
+		ncfg.EgressProxyPort = uint16(v.(float64))
+	}
+	if v, ok := nMap["EgressProxyPorts"]; ok && v.(string) != "" {
+		if ncfg.EgressProxyPorts, err = parsePortList(v.(string)); err != nil {
+			return types.InternalErrorf("failed to decode bridge network egress proxy ports after json unmarshal: %s", v.(string))
+		}
+	}
+	if v, ok := nMap["ConntrackZone"]; ok {
+		ncfg.ConntrackZone = uint16(v.(float64))
+	}
+	if v, ok := nMap["UserlandProxy"]; ok {
+		enable := v.(bool)
+		ncfg.UserlandProxy = &enable
+	}
+	if v, ok := nMap["HairpinMode"]; ok {
+		enable := v.(bool)
+		ncfg.HairpinMode = &enable
+	}
 
 	return nil
 }
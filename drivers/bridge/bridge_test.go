@@ -767,6 +767,136 @@ func TestValidateConfig(t *testing.T) {
 	}
 }
 
+func TestParseTrafficShaping(t *testing.T) {
+	ec, err := parseEndpointOptions(map[string]interface{}{
+		IngressRate: "10000000",
+		IngressCeil: uint64(20000000),
+		EgressRate:  5000000,
+		EgressBurst: "32768",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.Shaping.IngressRate != 10000000 || ec.Shaping.IngressCeil != 20000000 ||
+		ec.Shaping.EgressRate != 5000000 || ec.Shaping.EgressBurst != 32768 {
+		t.Fatalf("Unexpected traffic shaping configuration: %+v", ec.Shaping)
+	}
+
+	if _, err := parseEndpointOptions(map[string]interface{}{IngressRate: "fast"}); err == nil {
+		t.Fatalf("Failed to detect invalid ingress rate")
+	}
+
+	if _, err := parseEndpointOptions(map[string]interface{}{EgressRate: "200", EgressCeil: "100"}); err == nil {
+		t.Fatalf("Failed to detect egress ceil lower than the egress rate")
+	}
+}
+
+func TestParseIccGroup(t *testing.T) {
+	ec, err := parseEndpointOptions(map[string]interface{}{IccGroup: "frontend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.IccGroup != "frontend" {
+		t.Fatalf("Unexpected icc group: %s", ec.IccGroup)
+	}
+
+	if _, err := parseEndpointOptions(map[string]interface{}{IccGroup: 1}); err == nil {
+		t.Fatalf("Failed to detect invalid icc group")
+	}
+}
+
+func TestParseVlan(t *testing.T) {
+	ec, err := parseEndpointOptions(map[string]interface{}{Vlan: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.Vlan != 100 {
+		t.Fatalf("Unexpected vlan: %d", ec.Vlan)
+	}
+
+	for _, v := range []interface{}{"0", 4095, "vlan", 1.5} {
+		if _, err := parseEndpointOptions(map[string]interface{}{Vlan: v}); err == nil {
+			t.Fatalf("Failed to detect invalid vlan %v", v)
+		}
+	}
+}
+
+func TestNetworkHairpinMode(t *testing.T) {
+	d := newDriver()
+	d.config.EnableUserlandProxy = true
+
+	config := &networkConfiguration{}
+	if err := config.fromLabels(map[string]string{HairpinMode: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	n := &bridgeNetwork{config: config, driver: d}
+	if !n.userlandProxy() || !n.hairpinMode() {
+		t.Fatalf("Expected the userland proxy and the hairpin mode to be enabled")
+	}
+
+	config = &networkConfiguration{}
+	if err := config.fromLabels(map[string]string{UserlandProxy: "false"}); err != nil {
+		t.Fatal(err)
+	}
+	n = &bridgeNetwork{config: config, driver: d}
+	if n.userlandProxy() || !n.hairpinMode() {
+		t.Fatalf("Expected the userland proxy to be disabled and the hairpin mode to be enabled")
+	}
+
+	n = &bridgeNetwork{config: &networkConfiguration{}, driver: d}
+	if !n.userlandProxy() || n.hairpinMode() {
+		t.Fatalf("Expected the network to follow the driver userland proxy setting")
+	}
+
+	config = &networkConfiguration{}
+	if err := config.fromLabels(map[string]string{HairpinMode: "maybe"}); err == nil {
+		t.Fatalf("Failed to detect invalid hairpin mode")
+	}
+}
+
+func TestMulticastLabels(t *testing.T) {
+	config := &networkConfiguration{}
+	if err := config.fromLabels(map[string]string{
+		MulticastSnooping: "false",
+		MulticastQuerier:  "true",
+		EnableMulticast:   "false",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if config.MulticastSnooping == nil || *config.MulticastSnooping || !config.MulticastQuerier || !config.MulticastDisabled {
+		t.Fatalf("Unexpected multicast configuration: %v %v %v", config.MulticastSnooping, config.MulticastQuerier, config.MulticastDisabled)
+	}
+
+	config = &networkConfiguration{}
+	if err := config.fromLabels(map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if config.MulticastSnooping != nil || config.MulticastDisabled {
+		t.Fatalf("Expected the multicast defaults to be kept")
+	}
+}
+
+func TestAllocateConntrackZone(t *testing.T) {
+	d := newDriver()
+
+	zone, err := d.allocateConntrackZone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone != 1 {
+		t.Fatalf("Expected zone 1, got %d", zone)
+	}
+
+	d.networks["n1"] = &bridgeNetwork{config: &networkConfiguration{ConntrackZone: 1}}
+	d.networks["n3"] = &bridgeNetwork{config: &networkConfiguration{ConntrackZone: 3}}
+	if zone, err = d.allocateConntrackZone(); err != nil {
+		t.Fatal(err)
+	}
+	if zone != 2 {
+		t.Fatalf("Expected zone 2, got %d", zone)
+	}
+}
+
 func TestSetDefaultGw(t *testing.T) {
 	defer testutils.SetupTestOSContext(t)()
 	d := newDriver()
@@ -115,6 +115,21 @@ func (eim ErrInvalidMtu) Error() string {
 // BadRequest denotes the type of this error
 func (eim ErrInvalidMtu) BadRequest() {}
 
+// MtuExceedsUplinkError is returned when the network MTU is bigger than
+// the one of the uplink interface.
+type MtuExceedsUplinkError struct {
+	Mtu       int
+	Uplink    string
+	UplinkMtu int
+}
+
+func (e *MtuExceedsUplinkError) Error() string {
+	return fmt.Sprintf("MTU %d exceeds the MTU %d of the uplink interface %s", e.Mtu, e.UplinkMtu, e.Uplink)
+}
+
+// BadRequest denotes the type of this error
+func (e *MtuExceedsUplinkError) BadRequest() {}
+
 // ErrInvalidPort is returned when the container or host port specified in the port binding is not valid.
 type ErrInvalidPort string
 
@@ -339,3 +354,14 @@ func (address InvalidLinkIPAddrError) Error() string {
 
 // BadRequest denotes the type of this error
 func (address InvalidLinkIPAddrError) BadRequest() {}
+
+// NotABridgeError is returned when the device with the configured bridge
+// name exists but is not a bridge.
+type NotABridgeError string
+
+func (name NotABridgeError) Error() string {
+	return fmt.Sprintf("device %s already exists and is not a bridge", string(name))
+}
+
+// Forbidden denotes the type of this error
+func (name NotABridgeError) Forbidden() {}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/docker/libnetwork/types"
 	"github.com/vishvananda/netlink"
 )
 
@@ -63,6 +64,37 @@ func (i *bridgeInterface) addresses() (netlink.Addr, []netlink.Addr, error) {
 	return v4addr[0], v6addr, nil
 }
 
+// hasIPv4Address returns whether the passed address is one of the IPv4
+// addresses of the bridge interface.
+func (i *bridgeInterface) hasIPv4Address(addr *net.IPNet) (bool, error) {
+	addrs, err := netlink.AddrList(i.Link, netlink.FAMILY_V4)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve address list: %v", err)
+	}
+	for _, a := range addrs {
+		if types.CompareIPNet(a.IPNet, addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// programSecondaryIPv4 adds the passed address to the bridge interface,
+// unless it is already there.
+func (i *bridgeInterface) programSecondaryIPv4(addr *net.IPNet) error {
+	found, err := i.hasIPv4Address(addr)
+	if err != nil {
+		return &IPv4AddrAddError{IP: addr, Err: err}
+	}
+	if found {
+		return nil
+	}
+	if err := netlink.AddrAdd(i.Link, &netlink.Addr{IPNet: addr}); err != nil {
+		return &IPv4AddrAddError{IP: addr, Err: err}
+	}
+	return nil
+}
+
 func (i *bridgeInterface) programIPv6Address() error {
 	_, nlAddressList, err := i.addresses()
 	if err != nil {
@@ -13,6 +13,99 @@ const (
 	// DefaultBindingIP label
 	DefaultBindingIP = "com.docker.network.bridge.host_binding_ipv4"
 
+	// HostSNATIP label for the host address the outgoing traffic of the
+	// network is source NATed to, in place of masquerading to the address
+	// of the egress interface
+	HostSNATIP = "com.docker.network.bridge.host_snat_ipv4"
+
 	// DefaultBridge label
 	DefaultBridge = "com.docker.network.bridge.default_bridge"
+
+	// UserlandProxy label to use, or not, the userland proxy for the
+	// port mappings of the network regardless of the daemon setting
+	UserlandProxy = "com.docker.network.bridge.userland_proxy"
+
+	// HairpinMode label to let, or not, the containers of the network
+	// reach their own published ports via the host addresses. It
+	// defaults to enabled when the userland proxy is not in use.
+	HairpinMode = "com.docker.network.bridge.hairpin_mode"
+
+	// PreserveBridge label to leave the bridge, and the interfaces attached
+	// to it, in place on network delete. Pre-existing bridges adopted by
+	// the network are always preserved.
+	PreserveBridge = "com.docker.network.bridge.preserve"
+
+	// PortIsolation label to isolate the endpoints of the network from
+	// each other, while letting them reach the gateway and the host
+	PortIsolation = "com.docker.network.bridge.port_isolation"
+
+	// AntiSpoofing label to prevent the endpoints of the network from
+	// sending traffic with the MAC and IP addresses of other endpoints
+	AntiSpoofing = "com.docker.network.bridge.anti_spoofing"
+
+	// MulticastSnooping label to enable or disable the IGMP/MLD snooping
+	// on the bridge, the kernel default is used if not specified
+	MulticastSnooping = "com.docker.network.bridge.multicast_snooping"
+
+	// MulticastQuerier label to make the bridge act as the multicast
+	// querier when there is no multicast router on the segment
+	MulticastQuerier = "com.docker.network.bridge.multicast_querier"
+
+	// EnableMulticast label to allow, or drop, the IPv4 multicast traffic
+	// between the endpoints of the network. It is allowed by default.
+	EnableMulticast = "com.docker.network.bridge.enable_multicast"
+
+	// DHCPParent label for the host interface attached to the bridge in
+	// DHCP passthrough mode. The containers of the network get their
+	// address from the DHCP server of the parent network instead of
+	// libnetwork, which requires the null ipam driver. The parent should
+	// be dedicated to the network, as it loses its host connectivity.
+	DHCPParent = "com.docker.network.bridge.dhcp_parent"
+
+	// EgressProxy label for the [ip:]port address of the proxy the
+	// outbound TCP traffic of the network is transparently redirected
+	// to. The proxy listens on the host when no address is given.
+	EgressProxy = "com.docker.network.bridge.egress_proxy"
+
+	// EgressProxyPorts label for the comma separated list of the
+	// destination ports redirected to the egress proxy, 80 and 443
+	// by default
+	EgressProxyPorts = "com.docker.network.bridge.egress_proxy_ports"
+
+	// VlanFiltering label to enable the VLAN filtering on the bridge
+	VlanFiltering = "com.docker.network.bridge.vlan_filtering"
+
+	// Vlan label for the VLAN the endpoint is tagged into. On a VLAN
+	// filtering bridge the endpoints of a VLAN only reach each other,
+	// the bridge gateway is only reachable from the default VLAN.
+	Vlan = "com.docker.network.bridge.endpoint.vlan"
+
+	// IccGroup label for the inter container communication group of the
+	// endpoint. Endpoints in different groups cannot communicate with
+	// each other, endpoints without a group can communicate with all.
+	IccGroup = "com.docker.network.bridge.endpoint.icc_group"
+
+	// IngressRate label for the rate, in bits per second, of the
+	// traffic received by the endpoint
+	IngressRate = "com.docker.network.bridge.endpoint.ingress_rate"
+
+	// IngressCeil label for the rate, in bits per second, the traffic
+	// received by the endpoint may burst up to
+	IngressCeil = "com.docker.network.bridge.endpoint.ingress_ceil"
+
+	// IngressBurst label for the size, in bytes, of the bursts of the
+	// traffic received by the endpoint
+	IngressBurst = "com.docker.network.bridge.endpoint.ingress_burst"
+
+	// EgressRate label for the rate, in bits per second, of the
+	// traffic sent by the endpoint
+	EgressRate = "com.docker.network.bridge.endpoint.egress_rate"
+
+	// EgressCeil label for the peak rate, in bits per second, of the
+	// traffic sent by the endpoint
+	EgressCeil = "com.docker.network.bridge.endpoint.egress_ceil"
+
+	// EgressBurst label for the size, in bytes, of the bursts of the
+	// traffic sent by the endpoint
+	EgressBurst = "com.docker.network.bridge.endpoint.egress_burst"
 )
@@ -50,6 +50,77 @@ func setupDevice(config *networkConfiguration, i *bridgeInterface) error {
 	return err
 }
 
+// setupBridgeMTU propagates the network MTU to the bridge, the veth
+// pairs get it on endpoint creation.
+func setupBridgeMTU(config *networkConfiguration, i *bridgeInterface) error {
+	if err := netlink.LinkSetMTU(i.Link, config.Mtu); err != nil {
+		return fmt.Errorf("failed to set MTU %d on bridge %s: %v", config.Mtu, config.BridgeName, err)
+	}
+	return nil
+}
+
+// validateUplinkMtu verifies the network MTU does not exceed the one of
+// the interface of the default route, if any.
+func (c *networkConfiguration) validateUplinkMtu() error {
+	if c.Mtu == 0 {
+		return nil
+	}
+
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the routes: %v", err)
+	}
+	for _, r := range routes {
+		if r.Dst != nil {
+			continue
+		}
+		uplink, err := netlink.LinkByIndex(r.LinkIndex)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve the uplink interface: %v", err)
+		}
+		if uplink.Attrs().MTU < c.Mtu {
+			return &MtuExceedsUplinkError{Mtu: c.Mtu, Uplink: uplink.Attrs().Name, UplinkMtu: uplink.Attrs().MTU}
+		}
+	}
+
+	return nil
+}
+
+// adoptBridge marks the configuration as adopting the bridge with the
+// configured name if it already exists on the host. The bridge must not
+// have IPv4 addresses or have the network gateway one among them.
+func (c *networkConfiguration) adoptBridge() error {
+	link, err := netlink.LinkByName(c.BridgeName)
+	if err != nil {
+		return nil
+	}
+
+	if _, ok := link.(*netlink.Bridge); !ok {
+		return NotABridgeError(c.BridgeName)
+	}
+
+	i := &bridgeInterface{Link: link}
+	addrv4, _, err := i.addresses()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve bridge interface addresses: %v", err)
+	}
+	if addrv4.IPNet != nil && c.AddressIPv4 != nil {
+		found, err := i.hasIPv4Address(c.AddressIPv4)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &IPv4AddrNoMatchError{IP: addrv4.IP, CfgIP: c.AddressIPv4.IP}
+		}
+		c.AdoptedAddressIPv4 = true
+	}
+
+	logrus.Infof("Adopting existing bridge %s for network %s", c.BridgeName, c.ID)
+	c.AdoptedBridge = true
+
+	return nil
+}
+
 // SetupDeviceUp ups the given bridge interface.
 func setupDeviceUp(config *networkConfiguration, i *bridgeInterface) error {
 	err := netlink.LinkSetUp(i.Link)
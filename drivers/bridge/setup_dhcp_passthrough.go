@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/types"
+	"github.com/vishvananda/netlink"
+)
+
+// setupDHCPParent attaches the parent interface to the bridge, so that the
+// DHCP requests of the containers are bridged to the external DHCP server
+// of the parent network.
+func setupDHCPParent(config *networkConfiguration, i *bridgeInterface) error {
+	parent, err := netlink.LinkByName(config.DHCPParent)
+	if err != nil {
+		return fmt.Errorf("could not find dhcp parent interface %s: %v", config.DHCPParent, err)
+	}
+
+	if master := parent.Attrs().MasterIndex; master != 0 && master != i.Link.Attrs().Index {
+		return types.ForbiddenErrorf("dhcp parent interface %s is already attached to another device", config.DHCPParent)
+	}
+
+	if err := addToBridge(config.DHCPParent, config.BridgeName); err != nil {
+		return fmt.Errorf("adding dhcp parent interface %s to bridge %s failed: %v", config.DHCPParent, config.BridgeName, err)
+	}
+
+	if err := netlink.LinkSetUp(parent); err != nil {
+		return fmt.Errorf("could not set link up for dhcp parent interface %s: %v", config.DHCPParent, err)
+	}
+
+	return nil
+}
+
+// releaseDHCPParent detaches the parent interface from the bridge
+func releaseDHCPParent(config *networkConfiguration) {
+	parent, err := netlink.LinkByName(config.DHCPParent)
+	if err != nil {
+		logrus.Warnf("Could not find dhcp parent interface %s of bridge %s: %v", config.DHCPParent, config.BridgeName, err)
+		return
+	}
+	if err := netlink.LinkSetNoMaster(parent); err != nil {
+		logrus.Warnf("Failed to detach dhcp parent interface %s from bridge %s: %v", config.DHCPParent, config.BridgeName, err)
+	}
+}
+
+// validateDHCPPassthrough verifies the network configuration is
+// compatible with the DHCP passthrough mode, where libnetwork does not
+// manage the addresses of the containers.
+func (c *networkConfiguration) validateDHCPPassthrough(ipV4Data, ipV6Data []driverapi.IPAMData) error {
+	if len(ipV4Data) > 1 || (len(ipV4Data) == 1 && types.IsIPNetValid(ipV4Data[0].Pool)) || len(ipV6Data) > 0 {
+		return types.BadRequestErrorf("dhcp passthrough network %s must use the null ipam driver", c.ID)
+	}
+	if c.EnableIPv6 {
+		return types.BadRequestErrorf("dhcp passthrough network %s does not support ipv6", c.ID)
+	}
+	if c.AntiSpoofing {
+		return types.BadRequestErrorf("dhcp passthrough network %s does not support anti-spoofing", c.ID)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/types"
+)
+
+func TestValidateDHCPPassthrough(t *testing.T) {
+	nullPool, _ := types.ParseCIDR("0.0.0.0/0")
+	pool, _ := types.ParseCIDR("172.28.0.0/16")
+
+	c := &networkConfiguration{ID: "dummy", DHCPParent: "eth1"}
+	if err := c.validateDHCPPassthrough([]driverapi.IPAMData{{Pool: nullPool}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.validateDHCPPassthrough([]driverapi.IPAMData{{Pool: pool}}, nil); err == nil {
+		t.Fatalf("Failed to detect ipam managed pool in dhcp passthrough mode")
+	}
+
+	c.EnableIPv6 = true
+	if err := c.validateDHCPPassthrough(nil, nil); err == nil {
+		t.Fatalf("Failed to detect ipv6 in dhcp passthrough mode")
+	}
+}
@@ -0,0 +1,113 @@
+package bridge
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libnetwork/iptables"
+)
+
+// The default ports whose outbound traffic is redirected to the proxy
+var defaultEgressProxyPorts = []uint16{80, 443}
+
+// iptables multiport matches at most 15 ports
+const maxEgressProxyPorts = 15
+
+// parseEgressProxy parses the [ip:]port address of the egress proxy
+func parseEgressProxy(value string) (net.IP, uint16, error) {
+	var (
+		ip   net.IP
+		port = value
+	)
+	if strings.Contains(value, ":") {
+		host, p, err := net.SplitHostPort(value)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ip = net.ParseIP(host); ip == nil || ip.To4() == nil {
+			return nil, 0, fmt.Errorf("invalid IPv4 address %q", host)
+		}
+		port = p
+	}
+
+	p, err := parsePort(port)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ip, p, nil
+}
+
+// parsePortList parses a comma separated list of ports
+func parsePortList(value string) ([]uint16, error) {
+	var ports []uint16
+	for _, s := range strings.Split(value, ",") {
+		p, err := parsePort(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, p)
+	}
+	if len(ports) > maxEgressProxyPorts {
+		return nil, fmt.Errorf("at most %d ports can be redirected", maxEgressProxyPorts)
+	}
+	return ports, nil
+}
+
+func parsePort(value string) (uint16, error) {
+	p, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	if p == 0 {
+		return 0, fmt.Errorf("invalid port 0")
+	}
+	return uint16(p), nil
+}
+
+func formatPortList(ports []uint16) string {
+	s := make([]string, 0, len(ports))
+	for _, p := range ports {
+		s = append(s, strconv.Itoa(int(p)))
+	}
+	return strings.Join(s, ",")
+}
+
+// egressProxyRule returns the rule redirecting the TCP traffic from the
+// bridge to the given ports out of the bridge subnet to the proxy. The
+// traffic is redirected to the host when the proxy has no address.
+func egressProxyRule(bridgeIface string, subnet *net.IPNet, ip net.IP, port uint16, ports []uint16) iptRule {
+	args := []string{"-i", bridgeIface, "!", "-d", subnet.String(), "-p", "tcp", "-m", "multiport", "--dports", formatPortList(ports)}
+	if ip == nil {
+		args = append(args, "-j", "REDIRECT", "--to-ports", strconv.Itoa(int(port)))
+	} else {
+		args = append(args, "-j", "DNAT", "--to-destination", net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+	}
+	return iptRule{table: iptables.Nat, chain: "PREROUTING", preArgs: []string{"-t", "nat"}, args: args}
+}
+
+// egressProxyPorts returns the ports redirected to the egress proxy
+func (c *networkConfiguration) egressProxyPorts() []uint16 {
+	if len(c.EgressProxyPorts) > 0 {
+		return c.EgressProxyPorts
+	}
+	return defaultEgressProxyPorts
+}
+
+func (n *bridgeNetwork) setupEgressProxy(config *networkConfiguration, i *bridgeInterface) error {
+	maskedAddrv4 := &net.IPNet{
+		IP:   i.bridgeIPv4.IP.Mask(i.bridgeIPv4.Mask),
+		Mask: i.bridgeIPv4.Mask,
+	}
+	rule := egressProxyRule(config.BridgeName, maskedAddrv4, config.EgressProxyIP, config.EgressProxyPort, config.egressProxyPorts())
+
+	if err := programChainRule(rule, "EGRESS PROXY", true); err != nil {
+		return err
+	}
+	n.registerIptCleanFunc(func() error {
+		return programChainRule(rule, "EGRESS PROXY", false)
+	})
+
+	return nil
+}
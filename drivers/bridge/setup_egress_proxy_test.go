@@ -0,0 +1,57 @@
+package bridge
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseEgressProxy(t *testing.T) {
+	ip, port, err := parseEgressProxy("3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != nil || port != 3128 {
+		t.Fatalf("Unexpected egress proxy: %v:%d", ip, port)
+	}
+
+	ip, port, err = parseEgressProxy("10.0.0.5:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("10.0.0.5")) || port != 8080 {
+		t.Fatalf("Unexpected egress proxy: %v:%d", ip, port)
+	}
+
+	for _, value := range []string{"", "0", "65536", "[2001:db8::1]:80", "10.0.0.5:"} {
+		if _, _, err := parseEgressProxy(value); err == nil {
+			t.Fatalf("Failed to detect invalid egress proxy %q", value)
+		}
+	}
+
+	if _, err := parsePortList("80, 443,8080"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsePortList("1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16"); err == nil {
+		t.Fatalf("Failed to detect too many egress proxy ports")
+	}
+}
+
+func TestEgressProxyRule(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.18.0.0/16")
+
+	rule := egressProxyRule("br-test", subnet, nil, 3128, defaultEgressProxyPorts)
+	if r := strings.Join(rule.args, " "); r != "-i br-test ! -d 172.18.0.0/16 -p tcp -m multiport --dports 80,443 -j REDIRECT --to-ports 3128" {
+		t.Fatalf("Unexpected rule: %s", r)
+	}
+
+	rule = egressProxyRule("br-test", subnet, net.ParseIP("10.0.0.5"), 8080, []uint16{80})
+	if r := strings.Join(rule.args, " "); r != "-i br-test ! -d 172.18.0.0/16 -p tcp -m multiport --dports 80 -j DNAT --to-destination 10.0.0.5:8080" {
+		t.Fatalf("Unexpected rule: %s", r)
+	}
+
+	c := &networkConfiguration{EgressProxyPorts: []uint16{8080}}
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Failed to detect egress proxy ports without egress proxy")
+	}
+}
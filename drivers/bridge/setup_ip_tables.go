@@ -3,6 +3,7 @@ package bridge
 import (
 	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/iptables"
@@ -71,8 +72,10 @@ func (n *bridgeNetwork) setupIPTables(config *networkConfiguration, i *bridgeInt
 		return fmt.Errorf("Cannot program chains, EnableIPTable is disabled")
 	}
 
-	// Pickup this configuraton option from driver
-	hairpinMode := !driverConfig.EnableUserlandProxy
+	// The NAT chain is shared by the networks, it is programmed after
+	// the driver option. The network rules honour the network one.
+	chainHairpinMode := !driverConfig.EnableUserlandProxy
+	hairpinMode := n.hairpinMode()
 
 	maskedAddrv4 := &net.IPNet{
 		IP:   i.bridgeIPv4.IP.Mask(i.bridgeIPv4.Mask),
@@ -86,41 +89,107 @@ func (n *bridgeNetwork) setupIPTables(config *networkConfiguration, i *bridgeInt
 			return setupInternalNetworkRules(config.BridgeName, maskedAddrv4, false)
 		})
 	} else {
-		if err = setupIPTablesInternal(config.BridgeName, maskedAddrv4, config.EnableICC, config.EnableIPMasquerade, hairpinMode, true); err != nil {
+		if err = setupIPTablesInternal(config.BridgeName, maskedAddrv4, config.EnableICC, config.EnableIPMasquerade, config.HostSNATIP, hairpinMode, true); err != nil {
 			return fmt.Errorf("Failed to Setup IP tables: %s", err.Error())
 		}
 		n.registerIptCleanFunc(func() error {
-			return setupIPTablesInternal(config.BridgeName, maskedAddrv4, config.EnableICC, config.EnableIPMasquerade, hairpinMode, false)
+			return setupIPTablesInternal(config.BridgeName, maskedAddrv4, config.EnableICC, config.EnableIPMasquerade, config.HostSNATIP, hairpinMode, false)
 		})
 		natChain, filterChain, _, err := n.getDriverChains()
 		if err != nil {
 			return fmt.Errorf("Failed to setup IP tables, cannot acquire chain info %s", err.Error())
 		}
 
-		err = iptables.ProgramChain(natChain, config.BridgeName, hairpinMode, true)
+		err = iptables.ProgramChain(natChain, config.BridgeName, chainHairpinMode, true)
 		if err != nil {
 			return fmt.Errorf("Failed to program NAT chain: %s", err.Error())
 		}
 
-		err = iptables.ProgramChain(filterChain, config.BridgeName, hairpinMode, true)
+		err = iptables.ProgramChain(filterChain, config.BridgeName, chainHairpinMode, true)
 		if err != nil {
 			return fmt.Errorf("Failed to program FILTER chain: %s", err.Error())
 		}
 
 		n.registerIptCleanFunc(func() error {
-			return iptables.ProgramChain(filterChain, config.BridgeName, hairpinMode, false)
+			return iptables.ProgramChain(filterChain, config.BridgeName, chainHairpinMode, false)
 		})
 
-		n.portMapper.SetIptablesChain(natChain, n.getNetworkBridgeName())
+		// The port mappings DNAT the traffic from the bridge too in hairpin mode
+		nwNatChain := *natChain
+		nwNatChain.HairpinMode = hairpinMode
+		n.portMapper.SetIptablesChain(&nwNatChain, n.getNetworkBridgeName())
+
+		for _, addr := range config.SecondaryAddressesIPv4 {
+			if err = n.setupSecondaryIPTables(config, addr); err != nil {
+				return err
+			}
+		}
 	}
 
 	if err := ensureJumpRule("FORWARD", IsolationChain); err != nil {
 		return err
 	}
 
+	// Kernels without directional zones support cannot isolate the
+	// connection tracking state of the networks, which is not fatal.
+	if config.ConntrackZone != 0 {
+		if err := setConntrackZone(config.BridgeName, config.ConntrackZone, true); err != nil {
+			logrus.Warnf("Failed to set the conntrack zone of bridge %s: %v", config.BridgeName, err)
+		} else {
+			n.registerIptCleanFunc(func() error {
+				return setConntrackZone(config.BridgeName, config.ConntrackZone, false)
+			})
+		}
+	}
+
 	return nil
 }
 
+// setConntrackZone tracks the connections originated on the bridge in the
+// passed zone, so that the ones of networks with overlapping subnets do
+// not collide. The reply direction stays in the default zone for the
+// replies coming from the uplink to match the NATed connections.
+func setConntrackZone(bridgeIface string, zone uint16, insert bool) error {
+	rule := iptRule{table: iptables.RawTable, chain: "PREROUTING", preArgs: []string{"-t", "raw"},
+		args: []string{"-i", bridgeIface, "-j", "CT", "--zone-orig", strconv.Itoa(int(zone))}}
+	return programChainRule(rule, "CONNTRACK ZONE", insert)
+}
+
+// setupSecondaryIPTables programs the NAT rule for a secondary subnet of the bridge
+func (n *bridgeNetwork) setupSecondaryIPTables(config *networkConfiguration, addr *net.IPNet) error {
+	if !config.EnableIPMasquerade {
+		return nil
+	}
+
+	maskedAddrv4 := &net.IPNet{
+		IP:   addr.IP.Mask(addr.Mask),
+		Mask: addr.Mask,
+	}
+	if err := setupSecondaryNATRule(config.BridgeName, maskedAddrv4, config.HostSNATIP, true); err != nil {
+		return fmt.Errorf("Failed to Setup IP tables for subnet %s: %s", maskedAddrv4, err.Error())
+	}
+	n.registerIptCleanFunc(func() error {
+		return setupSecondaryNATRule(config.BridgeName, maskedAddrv4, config.HostSNATIP, false)
+	})
+
+	return nil
+}
+
+func setupSecondaryNATRule(bridgeIface string, addr net.Addr, snatIP net.IP, enable bool) error {
+	natRule := iptRule{table: iptables.Nat, chain: "POSTROUTING", preArgs: []string{"-t", "nat"}, args: natArgs(bridgeIface, addr, snatIP)}
+	return programChainRule(natRule, "NAT", enable)
+}
+
+// natArgs returns the arguments of the rule NATing the outgoing traffic
+// of the bridge subnet, to the passed address if any.
+func natArgs(bridgeIface string, addr net.Addr, snatIP net.IP) []string {
+	args := []string{"-s", addr.String(), "!", "-o", bridgeIface}
+	if snatIP != nil {
+		return append(args, "-j", "SNAT", "--to-source", snatIP.String())
+	}
+	return append(args, "-j", "MASQUERADE")
+}
+
 type iptRule struct {
 	table   iptables.Table
 	chain   string
@@ -128,11 +197,10 @@ type iptRule struct {
 	args    []string
 }
 
-func setupIPTablesInternal(bridgeIface string, addr net.Addr, icc, ipmasq, hairpin, enable bool) error {
+func setupIPTablesInternal(bridgeIface string, addr net.Addr, icc, ipmasq bool, snatIP net.IP, hairpin, enable bool) error {
 
 	var (
-		address   = addr.String()
-		natRule   = iptRule{table: iptables.Nat, chain: "POSTROUTING", preArgs: []string{"-t", "nat"}, args: []string{"-s", address, "!", "-o", bridgeIface, "-j", "MASQUERADE"}}
+		natRule   = iptRule{table: iptables.Nat, chain: "POSTROUTING", preArgs: []string{"-t", "nat"}, args: natArgs(bridgeIface, addr, snatIP)}
 		hpNatRule = iptRule{table: iptables.Nat, chain: "POSTROUTING", preArgs: []string{"-t", "nat"}, args: []string{"-m", "addrtype", "--src-type", "LOCAL", "-o", bridgeIface, "-j", "MASQUERADE"}}
 		skipDNAT  = iptRule{table: iptables.Nat, chain: DockerChain, preArgs: []string{"-t", "nat"}, args: []string{"-i", bridgeIface, "-j", "RETURN"}}
 		outRule   = iptRule{table: iptables.Filter, chain: "FORWARD", args: []string{"-i", bridgeIface, "!", "-o", bridgeIface, "-j", "ACCEPT"}}
@@ -250,6 +318,20 @@ func setIcc(bridgeIface string, iccEnable, insert bool) error {
 	return nil
 }
 
+// Control the communication between two endpoints of the bridge in
+// different icc groups. Install/remove only if it is not/is present.
+func setIccGroupRules(bridgeIface string, ip1, ip2 net.IP, insert bool) error {
+	for _, rule := range []iptRule{
+		{table: iptables.Filter, chain: IsolationChain, args: []string{"-i", bridgeIface, "-o", bridgeIface, "-s", ip1.String(), "-d", ip2.String(), "-j", "DROP"}},
+		{table: iptables.Filter, chain: IsolationChain, args: []string{"-i", bridgeIface, "-o", bridgeIface, "-s", ip2.String(), "-d", ip1.String(), "-j", "DROP"}},
+	} {
+		if err := programChainRule(rule, "DROP ICC GROUP", insert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Control Inter Network Communication. Install/remove only if it is not/is present.
 func setINC(iface1, iface2 string, enable bool) error {
 	var (
@@ -2,6 +2,7 @@ package bridge
 
 import (
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/docker/libnetwork/iptables"
@@ -122,3 +123,17 @@ func assertBridgeConfig(config *networkConfiguration, br *bridgeInterface, d *dr
 		t.Fatalf("%v", err)
 	}
 }
+
+func TestNatArgs(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.18.0.0/16")
+
+	args := strings.Join(natArgs("br-test", subnet, nil), " ")
+	if args != "-s 172.18.0.0/16 ! -o br-test -j MASQUERADE" {
+		t.Fatalf("Unexpected masquerade rule: %s", args)
+	}
+
+	args = strings.Join(natArgs("br-test", subnet, net.ParseIP("192.168.1.10")), " ")
+	if args != "-s 172.18.0.0/16 ! -o br-test -j SNAT --to-source 192.168.1.10" {
+		t.Fatalf("Unexpected snat rule: %s", args)
+	}
+}
@@ -11,6 +11,16 @@ import (
 )
 
 func setupBridgeIPv4(config *networkConfiguration, i *bridgeInterface) error {
+	// The addresses of an adopted bridge are left in place
+	if config.AdoptedBridge {
+		if err := i.programSecondaryIPv4(config.AddressIPv4); err != nil {
+			return err
+		}
+		i.bridgeIPv4 = config.AddressIPv4
+		i.gatewayIPv4 = config.AddressIPv4.IP
+		return nil
+	}
+
 	addrv4, _, err := i.addresses()
 	if err != nil {
 		return fmt.Errorf("failed to retrieve bridge interface addresses: %v", err)
@@ -35,6 +45,15 @@ func setupBridgeIPv4(config *networkConfiguration, i *bridgeInterface) error {
 	return nil
 }
 
+func setupBridgeSecondaryIPv4(config *networkConfiguration, i *bridgeInterface) error {
+	for _, addr := range config.SecondaryAddressesIPv4 {
+		if err := i.programSecondaryIPv4(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func setupGatewayIPv4(config *networkConfiguration, i *bridgeInterface) error {
 	if !i.bridgeIPv4.Contains(config.DefaultGatewayIPv4) {
 		return &ErrInvalidGateway{}
@@ -0,0 +1,72 @@
+package bridge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// setBridgeOption writes the passed boolean option of the bridge via sysfs
+func setBridgeOption(bridgeName, option string, enable bool) error {
+	path := filepath.Join("/sys/class/net", bridgeName, "bridge", option)
+
+	var val []byte
+	if enable {
+		val = []byte{'1', '\n'}
+	} else {
+		val = []byte{'0', '\n'}
+	}
+
+	if err := ioutil.WriteFile(path, val, 0644); err != nil {
+		return fmt.Errorf("unable to set %s on bridge %s: %v", option, bridgeName, err)
+	}
+	return nil
+}
+
+func (n *bridgeNetwork) setupMulticast(config *networkConfiguration, i *bridgeInterface) error {
+	if config.MulticastSnooping != nil {
+		if err := setBridgeOption(config.BridgeName, "multicast_snooping", *config.MulticastSnooping); err != nil {
+			return err
+		}
+	}
+
+	if config.MulticastQuerier {
+		if err := setBridgeOption(config.BridgeName, "multicast_querier", true); err != nil {
+			return err
+		}
+	}
+
+	if config.MulticastDisabled {
+		if err := setMulticastFiltering(config.BridgeName, true); err != nil {
+			return err
+		}
+		n.registerIptCleanFunc(func() error {
+			return setMulticastFiltering(config.BridgeName, false)
+		})
+	}
+
+	return nil
+}
+
+// setMulticastFiltering drops the IPv4 multicast traffic bridged between
+// the endpoints. Install/remove only if it is not/is present.
+func setMulticastFiltering(bridgeName string, insert bool) error {
+	rule := []string{"FORWARD", "--logical-in", bridgeName, "-p", "IPv4", "--ip-dst", "224.0.0.0/4", "-j", "DROP"}
+
+	// ebtables -L does not support rule matching, -D fails if the rule
+	// is missing and -A duplicates it: delete it first in both cases.
+	out, err := exec.Command("ebtables", append([]string{"-t", "filter", "-D"}, rule...)...).CombinedOutput()
+	if !insert {
+		if err != nil && !strings.Contains(string(out), "not exist") {
+			return fmt.Errorf("unable to remove multicast filtering on bridge %s: %v (%s)", bridgeName, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	if out, err := exec.Command("ebtables", append([]string{"-t", "filter", "-A"}, rule...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to filter multicast on bridge %s: %v (%s)", bridgeName, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package bridge
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/iptables"
+	"github.com/vishvananda/netlink"
+)
+
+// wildcard matches any value of an option in a rule template
+const wildcard = "*"
+
+// ownedChain lists the templates of the rules the driver programs in a
+// built-in chain. The driver chains are flushed on start, but the rules
+// in the built-in chains survive a restart of the daemon.
+type ownedChain struct {
+	table     iptables.Table
+	chain     string
+	templates [][]string
+}
+
+var ownedChains = []ownedChain{
+	{table: iptables.Nat, chain: "POSTROUTING", templates: [][]string{
+		{"-s", wildcard, "!", "-o", wildcard, "-j", "MASQUERADE"},
+		{"-s", wildcard, "!", "-o", wildcard, "-j", "SNAT", "--to-source", wildcard},
+		{"-m", "addrtype", "--src-type", "LOCAL", "-o", wildcard, "-j", "MASQUERADE"},
+	}},
+	{table: iptables.Nat, chain: "PREROUTING", templates: [][]string{
+		{"-i", wildcard, "!", "-d", wildcard, "-p", "tcp", "-m", "multiport", "--dports", wildcard, "-j", "REDIRECT", "--to-ports", wildcard},
+		{"-i", wildcard, "!", "-d", wildcard, "-p", "tcp", "-m", "multiport", "--dports", wildcard, "-j", "DNAT", "--to-destination", wildcard},
+	}},
+	{table: iptables.Filter, chain: "FORWARD", templates: [][]string{
+		{"-i", wildcard, "!", "-o", wildcard, "-j", "ACCEPT"},
+		{"-o", wildcard, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+		{"-i", wildcard, "-o", wildcard, "-j", "ACCEPT"},
+		{"-i", wildcard, "-o", wildcard, "-j", "DROP"},
+		{"-o", wildcard, "-j", DockerChain},
+	}},
+	{table: iptables.RawTable, chain: "PREROUTING", templates: [][]string{
+		{"-i", wildcard, "-j", "CT", "--zone-orig", wildcard},
+	}},
+}
+
+// The masquerade rule installed for the hairpinned traffic of a port
+// mapping. The port mappings do not survive a restart of the daemon.
+var portMappingTemplate = []string{"-p", wildcard, "-s", wildcard, "-d", wildcard, "--dport", wildcard, "-j", "MASQUERADE"}
+
+// ruleOptions splits the rule arguments in options, the negation being
+// part of the option name and the value in iptables canonical form.
+func ruleOptions(args []string) [][2]string {
+	var (
+		opts  [][2]string
+		proto string
+		neg   bool
+	)
+	for i := 0; i < len(args); i++ {
+		if args[i] == "!" {
+			neg = true
+			continue
+		}
+		opt := args[i]
+		if neg {
+			opt = "!" + opt
+			neg = false
+		}
+		var val string
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") && args[i+1] != "!" {
+			i++
+			val = args[i]
+		}
+		switch strings.TrimPrefix(opt, "!") {
+		case "-p":
+			proto = val
+		case "-s", "-d":
+			val = canonicalAddr(val)
+		}
+		opts = append(opts, [2]string{opt, val})
+	}
+
+	// iptables lists the implicit protocol match of the port options
+	filtered := opts[:0]
+	for _, o := range opts {
+		if o[0] == "-m" && o[1] == proto && proto != "" {
+			continue
+		}
+		filtered = append(filtered, o)
+	}
+	return filtered
+}
+
+// canonicalAddr returns the address as iptables lists it
+func canonicalAddr(val string) string {
+	if ip := net.ParseIP(val); ip != nil {
+		if ip.To4() != nil {
+			return val + "/32"
+		}
+		return val + "/128"
+	}
+	if _, nw, err := net.ParseCIDR(val); err == nil {
+		return nw.String()
+	}
+	return val
+}
+
+// ruleKey returns a representation of the rule which does not depend on
+// the order of its options, as iptables lists them in its own order.
+func ruleKey(args []string) string {
+	var s []string
+	for _, o := range ruleOptions(args) {
+		s = append(s, o[0]+" "+o[1])
+	}
+	sort.Strings(s)
+	return strings.Join(s, " ")
+}
+
+// matchTemplate returns whether the rule matches the template and the
+// interfaces it references
+func matchTemplate(template, args []string) ([]string, bool) {
+	topts, opts := ruleOptions(template), ruleOptions(args)
+	if len(topts) != len(opts) {
+		return nil, false
+	}
+
+	wild := make(map[string]bool)
+	for _, o := range topts {
+		if o[1] == wildcard {
+			wild[o[0]] = true
+		}
+	}
+
+	var ifaces []string
+	masked := make([]string, 0, len(opts)*2)
+	for _, o := range opts {
+		if wild[o[0]] {
+			switch strings.TrimPrefix(o[0], "!") {
+			case "-i", "-o":
+				ifaces = append(ifaces, o[1])
+			}
+			o[1] = wildcard
+		}
+		masked = append(masked, o[0], o[1])
+	}
+
+	return ifaces, ruleKey(masked) == ruleKey(template)
+}
+
+// listChainRules returns the arguments of the rules of the chain
+func listChainRules(table iptables.Table, chain string) ([][]string, error) {
+	out, err := iptables.Raw("-t", string(table), "-S", chain)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules [][]string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "-A" || fields[1] != chain {
+			continue
+		}
+		rules = append(rules, fields[2:])
+	}
+	return rules, nil
+}
+
+// iptablesRules returns the rules setupIPTables programs in the built-in
+// chains for the network
+func (n *bridgeNetwork) iptablesRules() []iptRule {
+	n.Lock()
+	config := n.config
+	n.Unlock()
+
+	if config.DHCPParent != "" || config.AddressIPv4 == nil {
+		return nil
+	}
+
+	var (
+		rules   []iptRule
+		bridge  = config.BridgeName
+		subnets = append([]*net.IPNet{config.AddressIPv4}, config.SecondaryAddressesIPv4...)
+	)
+
+	if config.ConntrackZone != 0 {
+		rules = append(rules, iptRule{table: iptables.RawTable, chain: "PREROUTING",
+			args: []string{"-i", bridge, "-j", "CT", "--zone-orig", strconv.Itoa(int(config.ConntrackZone))}})
+	}
+
+	// The rules of the internal networks are in the isolation chain
+	if config.Internal {
+		return rules
+	}
+
+	if config.EnableIPMasquerade {
+		for _, addr := range subnets {
+			masked := &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}
+			rules = append(rules, iptRule{table: iptables.Nat, chain: "POSTROUTING", args: natArgs(bridge, masked, config.HostSNATIP)})
+		}
+	}
+	if n.hairpinMode() {
+		rules = append(rules, iptRule{table: iptables.Nat, chain: "POSTROUTING", args: []string{"-m", "addrtype", "--src-type", "LOCAL", "-o", bridge, "-j", "MASQUERADE"}})
+	}
+
+	if config.EgressProxyPort != 0 {
+		masked := &net.IPNet{IP: config.AddressIPv4.IP.Mask(config.AddressIPv4.Mask), Mask: config.AddressIPv4.Mask}
+		rules = append(rules, egressProxyRule(bridge, masked, config.EgressProxyIP, config.EgressProxyPort, config.egressProxyPorts()))
+	}
+
+	iccAction := "DROP"
+	if config.EnableICC {
+		iccAction = "ACCEPT"
+	}
+	rules = append(rules,
+		iptRule{table: iptables.Filter, chain: "FORWARD", args: []string{"-i", bridge, "-o", bridge, "-j", iccAction}},
+		iptRule{table: iptables.Filter, chain: "FORWARD", args: []string{"-i", bridge, "!", "-o", bridge, "-j", "ACCEPT"}},
+		iptRule{table: iptables.Filter, chain: "FORWARD", args: []string{"-o", bridge, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}},
+		iptRule{table: iptables.Filter, chain: "FORWARD", args: []string{"-o", bridge, "-j", DockerChain}},
+	)
+
+	return rules
+}
+
+// reconcileIPTables removes the rules a previous run of the driver left
+// in the built-in chains which do not match the state of the networks
+// restored from the store: the duplicated ones, the ones of the bridges
+// which no longer exist or whose configuration changed, and the ones of
+// the port mappings. The missing rules are added by the network setup.
+func (d *driver) reconcileIPTables() {
+	d.Lock()
+	networks := make([]*bridgeNetwork, 0, len(d.networks))
+	for _, n := range d.networks {
+		networks = append(networks, n)
+	}
+	d.Unlock()
+
+	var (
+		expected = make(map[string]bool)
+		bridges  = make(map[string]bool)
+		subnets  []*net.IPNet
+	)
+	for _, n := range networks {
+		for _, r := range n.iptablesRules() {
+			expected[string(r.table)+"/"+r.chain+"/"+ruleKey(r.args)] = true
+		}
+		n.Lock()
+		config := n.config
+		n.Unlock()
+		bridges[config.BridgeName] = true
+		if config.AddressIPv4 != nil {
+			subnets = append(subnets, config.AddressIPv4)
+		}
+		subnets = append(subnets, config.SecondaryAddressesIPv4...)
+	}
+
+	// The rules of a bridge this driver does not manage belong to it only
+	// if the bridge is gone, otherwise it may be owned by someone else.
+	owned := func(ifaces []string) bool {
+		for _, iface := range ifaces {
+			if bridges[iface] {
+				continue
+			}
+			if _, err := netlink.LinkByName(iface); err == nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	inSubnets := func(addr string) bool {
+		ip, _, err := net.ParseCIDR(canonicalAddr(addr))
+		if err != nil {
+			return false
+		}
+		for _, nw := range subnets {
+			if nw.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, oc := range ownedChains {
+		rules, err := listChainRules(oc.table, oc.chain)
+		if err != nil {
+			logrus.Warnf("Failed to list the rules of table %s chain %s: %v", oc.table, oc.chain, err)
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, args := range rules {
+			key := string(oc.table) + "/" + oc.chain + "/" + ruleKey(args)
+
+			stale := false
+			if oc.table == iptables.Nat {
+				if _, ok := matchTemplate(portMappingTemplate, args); ok {
+					stale = inSubnets(ruleValue(args, "-s"))
+				}
+			}
+			if !stale {
+				ifaces, ok := matchOwnedChain(oc, args)
+				if !ok || !owned(ifaces) {
+					continue
+				}
+				stale = !expected[key] || seen[key]
+			}
+			seen[key] = true
+
+			if !stale {
+				continue
+			}
+			logrus.Debugf("Removing stale rule from table %s chain %s: %s", oc.table, oc.chain, strings.Join(args, " "))
+			if err := iptables.RawCombinedOutput(append([]string{"-t", string(oc.table), "-D", oc.chain}, args...)...); err != nil {
+				logrus.Warnf("Failed to remove stale rule from table %s chain %s: %v", oc.table, oc.chain, err)
+			}
+		}
+	}
+}
+
+func matchOwnedChain(oc ownedChain, args []string) ([]string, bool) {
+	for _, t := range oc.templates {
+		if ifaces, ok := matchTemplate(t, args); ok {
+			return ifaces, true
+		}
+	}
+	return nil, false
+}
+
+// ruleValue returns the value of the option in the rule arguments
+func ruleValue(args []string, opt string) string {
+	for _, o := range ruleOptions(args) {
+		if o[0] == opt {
+			return o[1]
+		}
+	}
+	return ""
+}
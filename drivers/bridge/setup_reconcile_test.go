@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/docker/libnetwork/iptables"
+)
+
+func TestRuleKey(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.18.0.0/16")
+
+	// As listed by iptables -S
+	listed := strings.Fields("-s 172.18.0.0/16 ! -o br-test -j MASQUERADE")
+	if ruleKey(natArgs("br-test", subnet, nil)) != ruleKey(listed) {
+		t.Fatalf("Failed to match the listed nat rule")
+	}
+
+	listed = strings.Fields("-o br-test -m addrtype --src-type LOCAL -j MASQUERADE")
+	if ruleKey([]string{"-m", "addrtype", "--src-type", "LOCAL", "-o", "br-test", "-j", "MASQUERADE"}) != ruleKey(listed) {
+		t.Fatalf("Failed to match the reordered hairpin rule")
+	}
+
+	listed = strings.Fields("-i br-test ! -o br-test -j ACCEPT")
+	if ruleKey([]string{"-i", "br-test", "-o", "br-test", "-j", "ACCEPT"}) == ruleKey(listed) {
+		t.Fatalf("Unexpected match of a negated option")
+	}
+}
+
+func TestMatchTemplate(t *testing.T) {
+	var nat ownedChain
+	for _, oc := range ownedChains {
+		if oc.table == iptables.Nat && oc.chain == "POSTROUTING" {
+			nat = oc
+		}
+	}
+
+	ifaces, ok := matchOwnedChain(nat, strings.Fields("-s 172.18.0.0/16 ! -o br-test -j SNAT --to-source 10.0.0.1"))
+	if !ok {
+		t.Fatalf("Failed to match the snat rule")
+	}
+	if len(ifaces) != 1 || ifaces[0] != "br-test" {
+		t.Fatalf("Unexpected interfaces of the snat rule: %v", ifaces)
+	}
+
+	if _, ok := matchOwnedChain(nat, strings.Fields("-s 10.0.0.0/8 -o eth0 -j MASQUERADE")); ok {
+		t.Fatalf("Unexpected match of a foreign rule")
+	}
+
+	args := strings.Fields("-s 172.18.0.2/32 -d 172.18.0.2/32 -p tcp -m tcp --dport 80 -j MASQUERADE")
+	if _, ok := matchTemplate(portMappingTemplate, args); !ok {
+		t.Fatalf("Failed to match the port mapping rule")
+	}
+	if v := ruleValue(args, "-s"); v != "172.18.0.2/32" {
+		t.Fatalf("Unexpected source of the port mapping rule: %s", v)
+	}
+}
@@ -19,8 +19,17 @@ func setupVerifyAndReconcile(config *networkConfiguration, i *bridgeInterface) e
 	}
 
 	// Verify that the bridge IPv4 address matches the requested configuration.
+	// An adopted bridge may have other addresses before the network one.
 	if config.AddressIPv4 != nil && !addrv4.IP.Equal(config.AddressIPv4.IP) {
-		return &IPv4AddrNoMatchError{IP: addrv4.IP, CfgIP: config.AddressIPv4.IP}
+		found := false
+		if config.AdoptedBridge {
+			if found, err = i.hasIPv4Address(config.AddressIPv4); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return &IPv4AddrNoMatchError{IP: addrv4.IP, CfgIP: config.AddressIPv4.IP}
+		}
 	}
 
 	// Verify that one of the bridge IPv6 addresses matches the requested
@@ -30,6 +39,9 @@ func setupVerifyAndReconcile(config *networkConfiguration, i *bridgeInterface) e
 	}
 
 	// Release any residual IPv6 address that might be there because of older daemon instances
+	if config.AdoptedBridge {
+		return nil
+	}
 	for _, addrv6 := range addrsv6 {
 		if addrv6.IP.IsGlobalUnicast() && !types.CompareIPNet(addrv6.IPNet, i.bridgeIPv6) {
 			if err := netlink.AddrDel(i.Link, &addrv6); err != nil {
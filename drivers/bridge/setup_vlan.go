@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libnetwork/types"
+	"github.com/vishvananda/netlink"
+)
+
+// Lowest and highest usable VLAN ids
+const (
+	minVlan = 1
+	maxVlan = 4094
+)
+
+func setupVlanFiltering(config *networkConfiguration, i *bridgeInterface) error {
+	path := filepath.Join("/sys/class/net", config.BridgeName, "bridge/vlan_filtering")
+	if err := ioutil.WriteFile(path, []byte{'1', '\n'}, 0644); err != nil {
+		return fmt.Errorf("unable to enable vlan filtering on bridge %s: %v", config.BridgeName, err)
+	}
+	return nil
+}
+
+// setPortVlan makes the passed vlan the only one of the bridge port,
+// untagged, in place of the default one. The vendored netlink lacks the
+// bridge vlan support, the bridge tool is used instead.
+func setPortVlan(link netlink.Link, vlan uint16) error {
+	name := link.Attrs().Name
+	for _, args := range [][]string{
+		{"vlan", "add", "dev", name, "vid", strconv.Itoa(int(vlan)), "pvid", "untagged"},
+		{"vlan", "del", "dev", name, "vid", "1"},
+	} {
+		if vlan == 1 && args[1] == "del" {
+			continue
+		}
+		if out, err := exec.Command("bridge", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("unable to set vlan %d on %s: %v (%s)", vlan, name, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+func parseVlan(opt interface{}) (uint16, error) {
+	var (
+		vlan int
+		err  error
+	)
+	switch v := opt.(type) {
+	case uint16:
+		vlan = int(v)
+	case int:
+		vlan = v
+	case string:
+		if vlan, err = strconv.Atoi(v); err != nil {
+			return 0, types.BadRequestErrorf("invalid vlan %q: %v", v, err)
+		}
+	default:
+		return 0, types.BadRequestErrorf("invalid vlan %v", opt)
+	}
+	if vlan < minVlan || vlan > maxVlan {
+		return 0, types.BadRequestErrorf("vlan %d is out of the %d-%d range", vlan, minVlan, maxVlan)
+	}
+	return uint16(vlan), nil
+}
@@ -0,0 +1,77 @@
+package bridge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// EndpointStatistics returns the counters of the endpoint interface as
+// seen from the container. They are read from the host side of the veth
+// pair, which receives what the endpoint transmits and vice versa.
+func (d *driver) EndpointStatistics(nid, eid string) (*types.InterfaceStatistics, error) {
+	n, err := d.getNetwork(nid)
+	if err != nil {
+		return nil, err
+	}
+
+	ep, err := n.getEndpoint(eid)
+	if err != nil {
+		return nil, err
+	}
+	if ep == nil {
+		return nil, driverapi.ErrNoEndpoint(eid)
+	}
+	if ep.hostIfName == "" {
+		return nil, types.NotFoundErrorf("endpoint %s has no host interface", eid)
+	}
+
+	hs, err := readInterfaceStatistics(filepath.Join("/sys/class/net", ep.hostIfName, "statistics"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.InterfaceStatistics{
+		RxBytes:   hs.TxBytes,
+		RxPackets: hs.TxPackets,
+		RxErrors:  hs.TxErrors,
+		RxDropped: hs.TxDropped,
+		TxBytes:   hs.RxBytes,
+		TxPackets: hs.RxPackets,
+		TxErrors:  hs.RxErrors,
+		TxDropped: hs.RxDropped,
+	}, nil
+}
+
+// readInterfaceStatistics reads the 64 bits counters of an interface
+// from its sysfs statistics directory
+func readInterfaceStatistics(dir string) (*types.InterfaceStatistics, error) {
+	s := &types.InterfaceStatistics{}
+	for _, c := range []struct {
+		name    string
+		counter *uint64
+	}{
+		{"rx_bytes", &s.RxBytes},
+		{"rx_packets", &s.RxPackets},
+		{"rx_errors", &s.RxErrors},
+		{"rx_dropped", &s.RxDropped},
+		{"tx_bytes", &s.TxBytes},
+		{"tx_packets", &s.TxPackets},
+		{"tx_errors", &s.TxErrors},
+		{"tx_dropped", &s.TxDropped},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, c.name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read interface statistics: %v", err)
+		}
+		if *c.counter, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse interface statistics %s: %v", c.name, err)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,38 @@
+package bridge
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadInterfaceStatistics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statistics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, value := range map[string]string{
+		"rx_bytes": "5000000000\n", "rx_packets": "10\n", "rx_errors": "0\n", "rx_dropped": "1\n",
+		"tx_bytes": "300\n", "tx_packets": "3\n", "tx_errors": "0\n", "tx_dropped": "2\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := readInterfaceStatistics(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RxBytes != 5000000000 || s.RxPackets != 10 || s.RxDropped != 1 || s.TxBytes != 300 || s.TxPackets != 3 || s.TxDropped != 2 {
+		t.Fatalf("Unexpected statistics: %s", s)
+	}
+
+	os.Remove(filepath.Join(dir, "tx_dropped"))
+	if _, err := readInterfaceStatistics(dir); err == nil {
+		t.Fatalf("Failed to detect missing counter")
+	}
+}
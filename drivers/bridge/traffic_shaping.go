@@ -0,0 +1,128 @@
+package bridge
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libnetwork/types"
+	"github.com/vishvananda/netlink"
+)
+
+// trafficShaping is the bandwidth limit of an endpoint. Rates are in
+// bits per second and bursts in bytes, zero rates disable the limits.
+type trafficShaping struct {
+	IngressRate  uint64
+	IngressCeil  uint64
+	IngressBurst uint64
+	EgressRate   uint64
+	EgressCeil   uint64
+	EgressBurst  uint64
+}
+
+// parse reads the traffic shaping labels from the endpoint options.
+func (ts *trafficShaping) parse(epOptions map[string]interface{}) error {
+	for label, field := range map[string]*uint64{
+		IngressRate:  &ts.IngressRate,
+		IngressCeil:  &ts.IngressCeil,
+		IngressBurst: &ts.IngressBurst,
+		EgressRate:   &ts.EgressRate,
+		EgressCeil:   &ts.EgressCeil,
+		EgressBurst:  &ts.EgressBurst,
+	} {
+		opt, ok := epOptions[label]
+		if !ok {
+			continue
+		}
+		switch v := opt.(type) {
+		case uint64:
+			*field = v
+		case int:
+			*field = uint64(v)
+		case string:
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return types.BadRequestErrorf("invalid value %q for %s: %v", v, label, err)
+			}
+			*field = n
+		default:
+			return types.BadRequestErrorf("invalid value %v for %s", opt, label)
+		}
+	}
+
+	if ts.IngressCeil != 0 && ts.IngressCeil < ts.IngressRate {
+		return types.BadRequestErrorf("ingress ceil %d is lower than the ingress rate %d", ts.IngressCeil, ts.IngressRate)
+	}
+	if ts.EgressCeil != 0 && ts.EgressCeil < ts.EgressRate {
+		return types.BadRequestErrorf("egress ceil %d is lower than the egress rate %d", ts.EgressCeil, ts.EgressRate)
+	}
+	return nil
+}
+
+// program applies the limits on the host side interface of the veth
+// pair: the traffic received by the endpoint leaves the host interface
+// and is shaped by an HTB class, the traffic sent by the endpoint enters
+// it and is policed.
+func (ts *trafficShaping) program(host netlink.Link) error {
+	if ts.IngressRate != 0 {
+		qdisc := netlink.NewHtb(netlink.QdiscAttrs{
+			LinkIndex: host.Attrs().Index,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		})
+		qdisc.Defcls = 1
+		if err := netlink.QdiscAdd(qdisc); err != nil {
+			return fmt.Errorf("failed to add the htb qdisc: %v", err)
+		}
+
+		class := netlink.NewHtbClass(netlink.ClassAttrs{
+			LinkIndex: host.Attrs().Index,
+			Handle:    netlink.MakeHandle(1, 1),
+			Parent:    netlink.MakeHandle(1, 0),
+		}, netlink.HtbClassAttrs{
+			Rate:    ts.IngressRate,
+			Ceil:    ts.IngressCeil,
+			Buffer:  uint32(ts.IngressBurst),
+			Cbuffer: uint32(ts.IngressBurst),
+		})
+		if err := netlink.ClassAdd(class); err != nil {
+			return fmt.Errorf("failed to add the htb class: %v", err)
+		}
+	}
+
+	if ts.EgressRate != 0 {
+		if err := netlink.QdiscAdd(&netlink.Ingress{
+			QdiscAttrs: netlink.QdiscAttrs{
+				LinkIndex: host.Attrs().Index,
+				Handle:    netlink.MakeHandle(0xffff, 0),
+				Parent:    netlink.HANDLE_INGRESS,
+			},
+		}); err != nil {
+			return fmt.Errorf("failed to add the ingress qdisc: %v", err)
+		}
+
+		// The vendored netlink lacks the police action, the filter
+		// is added with tc.
+		burst := ts.EgressBurst
+		if burst == 0 {
+			// Allow at least 10ms of traffic, and a full size
+			// packet.
+			burst = ts.EgressRate / 8 / 100
+			if burst < 65536 {
+				burst = 65536
+			}
+		}
+		args := fmt.Sprintf("filter add dev %s parent ffff: protocol all u32 match u32 0 0 police rate %dbit burst %d",
+			host.Attrs().Name, ts.EgressRate, burst)
+		if ts.EgressCeil != 0 {
+			args += fmt.Sprintf(" peakrate %dbit mtu 65536", ts.EgressCeil)
+		}
+		args += " drop flowid :1"
+		if out, err := exec.Command("tc", strings.Fields(args)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to add the policing filter: %v (%s)", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
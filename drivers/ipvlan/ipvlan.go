@@ -4,9 +4,12 @@ import (
 	"net"
 	"sync"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/dhcp"
 	"github.com/docker/libnetwork/discoverapi"
 	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/netlabel"
 	"github.com/docker/libnetwork/osl"
 	"github.com/docker/libnetwork/types"
 )
@@ -18,7 +21,9 @@ const (
 	ipvlanType          = "ipvlan" // driver type name
 	modeL2              = "l2"     // ipvlan mode l2 is the default
 	modeL3              = "l3"     // ipvlan L3 mode
+	modeL3S             = "l3s"    // ipvlan L3 mode through the netfilter hooks
 	parentOpt           = "parent" // parent interface -o parent
+	dhcpOpt             = "dhcp"   // addresses from the dhcp server of the parent network -o dhcp
 	modeOpt             = "_mode"  // ipvlan mode ux opt suffix
 )
 
@@ -33,6 +38,8 @@ type driver struct {
 	sync.Once
 	sync.Mutex
 	store datastore.DataStore
+	// global datastore of the node configurations
+	globalStore datastore.DataStore
 }
 
 type endpoint struct {
@@ -41,6 +48,17 @@ type endpoint struct {
 	addr    *net.IPNet
 	addrv6  *net.IPNet
 	srcName string
+	lease   *dhcp.Keeper
+	// dhcp client hardware address of the endpoint, the ipvlan
+	// interfaces sharing the one of the parent
+	leaseMac net.HardwareAddr
+	// sandbox the endpoint joined, its interface being recreated in it
+	// when the parent link comes back
+	sboxKey string
+	// egress priority of the traffic of the endpoint and preference of
+	// its filters on the parent
+	priority int
+	qosPref  int
 }
 
 type network struct {
@@ -49,6 +67,10 @@ type network struct {
 	endpoints endpointTable
 	driver    *driver
 	config    *configuration
+	// index of the host link the parent depends on
+	lower int
+	// preference of the next priority filter on the parent
+	qosPref int
 	sync.Mutex
 }
 
@@ -61,18 +83,18 @@ func Init(dc driverapi.DriverCallback, config map[string]interface{}) error {
 		networks: networkTable{},
 	}
 	d.initStore(config)
+	if data, ok := config[netlabel.GlobalKVClient]; ok {
+		if dsc, ok := data.(discoverapi.DatastoreConfigData); ok {
+			if err := d.setGlobalStore(dsc); err != nil {
+				logrus.Warn(err)
+			}
+		}
+	}
+	go d.watchParents()
 
 	return dc.RegisterDriver(ipvlanType, d, c)
 }
 
-func (d *driver) NetworkAllocate(id string, option map[string]string, ipV4Data, ipV6Data []driverapi.IPAMData) (map[string]string, error) {
-	return nil, types.NotImplementedErrorf("not implemented")
-}
-
-func (d *driver) NetworkFree(id string) error {
-	return types.NotImplementedErrorf("not implemented")
-}
-
 func (d *driver) EndpointOperInfo(nid, eid string) (map[string]interface{}, error) {
 	return make(map[string]interface{}, 0), nil
 }
@@ -91,6 +113,13 @@ func (d *driver) RevokeExternalConnectivity(nid, eid string) error {
 
 // DiscoverNew is a notification for a new discovery event.
 func (d *driver) DiscoverNew(dType discoverapi.DiscoveryType, data interface{}) error {
+	if dType == discoverapi.DatastoreConfig {
+		dsc, ok := data.(discoverapi.DatastoreConfigData)
+		if !ok {
+			return types.InternalErrorf("incorrect data in datastore configuration: %v", data)
+		}
+		return d.setGlobalStore(dsc)
+	}
 	return nil
 }
 
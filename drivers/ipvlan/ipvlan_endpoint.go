@@ -6,6 +6,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/driverapi"
 	"github.com/docker/libnetwork/netlabel"
+	"github.com/docker/libnetwork/netutils"
 	"github.com/docker/libnetwork/osl"
 	"github.com/docker/libnetwork/types"
 	"github.com/vishvananda/netlink"
@@ -23,6 +24,9 @@ func (d *driver) CreateEndpoint(nid, eid string, ifInfo driverapi.InterfaceInfo,
 	if err != nil {
 		return fmt.Errorf("network id %q not found", nid)
 	}
+	if n.config.ConfigOnly != "" {
+		return types.ForbiddenErrorf("%s network %s only holds the configuration %s of the node", ipvlanType, nid, n.config.ConfigOnly)
+	}
 	if ifInfo.MacAddress() != nil {
 		return fmt.Errorf("%s interfaces do not support custom mac address assigment", ipvlanType)
 	}
@@ -32,9 +36,32 @@ func (d *driver) CreateEndpoint(nid, eid string, ifInfo driverapi.InterfaceInfo,
 		addrv6: ifInfo.AddressIPv6(),
 		mac:    ifInfo.MacAddress(),
 	}
+	if n.config.DHCP {
+		if ep.addr != nil {
+			return fmt.Errorf("%s network %s leases the addresses from the dhcp server of its parent network", ipvlanType, nid)
+		}
+		ep.leaseMac = netutils.GenerateRandomMAC()
+		if err := n.acquireLease(ep); err != nil {
+			return err
+		}
+		if err := ifInfo.SetIPAddress(ep.addr); err != nil {
+			ep.lease.Stop()
+			n.releaseLease(ep)
+			return err
+		}
+	}
 	if ep.addr == nil {
 		return fmt.Errorf("create endpoint was not passed an IP address")
 	}
+	if opt, ok := epOptions[priorityOpt]; ok {
+		if ep.priority, err = parsePriority(opt); err != nil {
+			if ep.lease != nil {
+				ep.lease.Stop()
+				n.releaseLease(ep)
+			}
+			return err
+		}
+	}
 	// disallow port mapping -p
 	if opt, ok := epOptions[netlabel.PortMap]; ok {
 		if _, ok := opt.([]types.PortBinding); ok {
@@ -73,6 +100,10 @@ func (d *driver) DeleteEndpoint(nid, eid string) error {
 	if link, err := netlink.LinkByName(ep.srcName); err == nil {
 		netlink.LinkDel(link)
 	}
+	if ep.lease != nil {
+		ep.lease.Stop()
+		n.releaseLease(ep)
+	}
 
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/docker/libnetwork/netutils"
 	"github.com/docker/libnetwork/osl"
 	"github.com/docker/libnetwork/types"
+	"github.com/vishvananda/netlink"
 )
 
 type staticRoute struct {
@@ -49,7 +50,7 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 	if ep == nil {
 		return fmt.Errorf("could not find endpoint with id %s", eid)
 	}
-	if n.config.IpvlanMode == modeL3 {
+	if n.config.IpvlanMode == modeL3 || n.config.IpvlanMode == modeL3S {
 		// disable gateway services to add a default gw using dev eth0 only
 		jinfo.DisableGatewayService()
 		defaultRoute, err := ifaceGateway(defaultV4RouteCidr)
@@ -74,6 +75,16 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 				ep.addrv6.IP.String(), n.config.IpvlanMode, n.config.Parent)
 		}
 	}
+	// the default gateway of a leased address comes from the dhcp server
+	if ep.lease != nil {
+		if gw := ep.lease.Lease().Gateway; gw != nil {
+			if err := jinfo.SetGateway(gw); err != nil {
+				return err
+			}
+		}
+		logrus.Debugf("Ipvlan Endpoint Joined with leased IPv4_Addr: %s, Ipvlan_Mode: %s, Parent: %s",
+			ep.addr.IP.String(), n.config.IpvlanMode, n.config.Parent)
+	}
 	if n.config.IpvlanMode == modeL2 {
 		// parse and correlate the endpoint v4 address with the available v4 subnets
 		if len(n.config.Ipv4Subnets) > 0 {
@@ -110,6 +121,21 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 				ep.addrv6.IP.String(), v6gw.String(), n.config.IpvlanMode, n.config.Parent)
 		}
 	}
+	// set the egress priority of the endpoint traffic on the parent
+	if ep.priority != 0 {
+		if err := n.addQoS(ep); err != nil {
+			return err
+		}
+	}
+	// mark the iface to find it in the sandbox when healing the endpoint
+	if link, err := netlink.LinkByName(vethName); err == nil {
+		if err := netlink.LinkSetAlias(link, eid); err != nil {
+			logrus.Debugf("Failed to set the alias of %s: %v", vethName, err)
+		}
+	}
+	n.Lock()
+	ep.sboxKey = sboxKey
+	n.Unlock()
 	iNames := jinfo.InterfaceName()
 	err = iNames.SetNames(vethName, containerVethPrefix)
 	if err != nil {
@@ -133,6 +159,12 @@ func (d *driver) Leave(nid, eid string) error {
 	if endpoint == nil {
 		return fmt.Errorf("could not find endpoint with id %s", eid)
 	}
+	if endpoint.priority != 0 {
+		network.delQoS(endpoint)
+	}
+	network.Lock()
+	endpoint.sboxKey = ""
+	network.Unlock()
 
 	return nil
 }
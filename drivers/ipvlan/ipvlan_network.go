@@ -2,6 +2,7 @@ package ipvlan
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/pkg/parsers/kernel"
@@ -25,19 +26,35 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 		return fmt.Errorf("kernel version failed to meet the minimum ipvlan kernel requirement of %d.%d, found %d.%d.%d",
 			ipvlanKernelVer, ipvlanMajorVer, kv.Kernel, kv.Major, kv.Minor)
 	}
-	// reject a null v4 network
-	if len(ipV4Data) == 0 || ipV4Data[0].Pool.String() == "0.0.0.0/0" {
-		return fmt.Errorf("ipv4 pool is empty")
-	}
 	// parse and validate the config and bind to networkConfiguration
 	config, err := parseNetworkOptions(nid, option)
 	if err != nil {
 		return err
 	}
 	config.ID = nid
-	err = config.processIPAM(nid, ipV4Data, ipV6Data)
-	if err != nil {
-		return err
+	if config.ConfigOnly != "" && config.ConfigFrom != "" {
+		return types.BadRequestErrorf("%s network %s cannot both hold and use a node configuration", ipvlanType, nid)
+	}
+	// take the parent and mode of the node from the configuration
+	if config.ConfigFrom != "" {
+		if err := d.applyNodeConfig(config); err != nil {
+			return err
+		}
+	}
+	if config.DHCP {
+		// the dhcp server of the parent network leases the addresses
+		if err := config.validateDHCP(ipV4Data, ipV6Data); err != nil {
+			return err
+		}
+	} else {
+		// reject a null v4 network
+		if len(ipV4Data) == 0 || ipV4Data[0].Pool.String() == "0.0.0.0/0" {
+			return fmt.Errorf("ipv4 pool is empty")
+		}
+		err = config.processIPAM(nid, ipV4Data, ipV6Data)
+		if err != nil {
+			return err
+		}
 	}
 	// verify the ipvlan mode from -o ipvlan_mode option
 	switch config.IpvlanMode {
@@ -46,6 +63,13 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 		config.IpvlanMode = modeL2
 	case modeL3:
 		config.IpvlanMode = modeL3
+	case modeL3S:
+		// the l3s mode runs the traffic through conntrack and iptables
+		if kv.Kernel < l3sKernelVer || (kv.Kernel == l3sKernelVer && kv.Major < l3sMajorVer) {
+			return fmt.Errorf("kernel version failed to meet the minimum ipvlan l3s kernel requirement of %d.%d, found %d.%d.%d",
+				l3sKernelVer, l3sMajorVer, kv.Kernel, kv.Major, kv.Minor)
+		}
+		config.IpvlanMode = modeL3S
 	default:
 		return fmt.Errorf("requested ipvlan mode '%s' is not valid, 'l2' mode is the ipvlan driver default", config.IpvlanMode)
 	}
@@ -53,6 +77,15 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 	if config.Parent == "lo" {
 		return fmt.Errorf("loopback interface is not a valid %s parent link", ipvlanType)
 	}
+	// save the parent and mode of the node for the networks using them
+	if config.ConfigOnly != "" {
+		if config.Parent == "" || config.Internal {
+			return types.BadRequestErrorf("%s configuration only network %s requires a parent interface", ipvlanType, nid)
+		}
+		if err := d.saveNodeConfig(config); err != nil {
+			return err
+		}
+	}
 	// if parent interface not specified, create a dummy type link to use named dummy+net_id
 	if config.Parent == "" {
 		config.Parent = getDummyName(stringid.TruncateID(config.ID))
@@ -67,6 +100,9 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 	err = d.storeUpdate(config)
 	if err != nil {
 		d.deleteNetwork(config.ID)
+		if config.ConfigOnly != "" {
+			d.deleteNodeConfig(config)
+		}
 		logrus.Debugf("encoutered an error rolling back a network create for %s : %v", config.ID, err)
 		return err
 	}
@@ -76,8 +112,22 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 
 // createNetwork is used by new network callbacks and persistent network cache
 func (d *driver) createNetwork(config *configuration) error {
+	n := &network{
+		id:        config.ID,
+		driver:    d,
+		endpoints: endpointTable{},
+		config:    config,
+	}
+	// a configuration only network creates no link
+	if config.ConfigOnly != "" {
+		d.addNetwork(n)
+		return nil
+	}
 	networkList := d.getNetworks()
 	for _, nw := range networkList {
+		if nw.config.ConfigOnly != "" {
+			continue
+		}
 		if config.Parent == nw.config.Parent {
 			return fmt.Errorf("network %s is already using parent interface %s",
 				getDummyName(stringid.TruncateID(nw.config.ID)), config.Parent)
@@ -107,12 +157,7 @@ func (d *driver) createNetwork(config *configuration) error {
 			config.CreatedSlaveLink = true
 		}
 	}
-	n := &network{
-		id:        config.ID,
-		driver:    d,
-		endpoints: endpointTable{},
-		config:    config,
-	}
+	n.lower = config.lowerIndex()
 	// add the *network
 	d.addNetwork(n)
 
@@ -147,6 +192,9 @@ func (d *driver) DeleteNetwork(nid string) error {
 			}
 		}
 	}
+	if n.config.ConfigOnly != "" {
+		d.deleteNodeConfig(n.config)
+	}
 	// delete the *network
 	d.deleteNetwork(nid)
 	// delete the network record from persistent cache
@@ -211,6 +259,19 @@ func (config *configuration) fromOptions(labels map[string]string) error {
 		case driverModeOpt:
 			// parse driver option '-o ipvlan_mode'
 			config.IpvlanMode = value
+		case dhcpOpt:
+			// parse driver option '-o dhcp'
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s value %q: %v", dhcpOpt, value, err)
+			}
+			config.DHCP = enabled
+		case configOnlyOpt:
+			// parse driver option '-o config_only'
+			config.ConfigOnly = value
+		case configFromOpt:
+			// parse driver option '-o config_from'
+			config.ConfigFrom = value
 		}
 	}
 	return nil
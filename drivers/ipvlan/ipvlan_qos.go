@@ -0,0 +1,145 @@
+package ipvlan
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/types"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// egress priority of the traffic of the endpoint, 0-7
+	priorityOpt = "com.docker.network.ipvlan.endpoint.priority"
+	maxPriority = 7
+	// preference of the first priority filter on the parent
+	firstQoSPref = 100
+)
+
+// The traffic the endpoints send leaves through the parent link, where a
+// filter of the clsact egress hook sets the skb priority of the packets
+// sourced from the addresses of the endpoints with a priority. On a vlan
+// parent the priority is mapped to the same 802.1p PCP of the vlan tag for
+// the upstream switches to apply their QoS policies.
+
+// parsePriority parses the egress priority of the endpoint options
+func parsePriority(opt interface{}) (int, error) {
+	var (
+		prio int
+		err  error
+	)
+	switch v := opt.(type) {
+	case int:
+		prio = v
+	case string:
+		if prio, err = strconv.Atoi(v); err != nil {
+			return 0, types.BadRequestErrorf("invalid value %q for %s: %v", v, priorityOpt, err)
+		}
+	default:
+		return 0, types.BadRequestErrorf("invalid value %v for %s", opt, priorityOpt)
+	}
+	if prio < 0 || prio > maxPriority {
+		return 0, types.BadRequestErrorf("%s must be between 0-%d, received: %d", priorityOpt, maxPriority, prio)
+	}
+	return prio, nil
+}
+
+// tcCmd runs tc, the vendored netlink lacking the clsact qdisc and the
+// skbedit action
+func tcCmd(args ...string) error {
+	if out, err := exec.Command("tc", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("tc %s failed: %v (%s)", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// setupQoS adds the clsact qdisc to the parent, and on a vlan parent maps
+// the priorities to the PCP of the vlan tag
+func setupQoS(parent string) error {
+	link, err := netlink.LinkByName(parent)
+	if err != nil {
+		return fmt.Errorf("failed to find the parent %s: %v", parent, err)
+	}
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return fmt.Errorf("failed to list the qdiscs of %s: %v", parent, err)
+	}
+	clsact := false
+	for _, q := range qdiscs {
+		if q.Type() == "clsact" {
+			clsact = true
+		}
+	}
+	if !clsact {
+		if err := tcCmd("qdisc", "add", "dev", parent, "clsact"); err != nil {
+			return err
+		}
+	}
+	if _, ok := link.(*netlink.Vlan); ok {
+		args := []string{"link", "set", "dev", parent, "type", "vlan", "egress-qos-map"}
+		for p := 0; p <= maxPriority; p++ {
+			args = append(args, fmt.Sprintf("%d:%d", p, p))
+		}
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to map the priorities of %s to the vlan PCP: %v (%s)", parent, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// qosFilterArgs returns the tc arguments of the filters setting the
+// priority of the traffic of the endpoint
+func qosFilterArgs(parent string, pref int, ep *endpoint) [][]string {
+	prio := strconv.Itoa(ep.priority)
+	list := [][]string{{"filter", "add", "dev", parent, "egress", "pref", strconv.Itoa(pref),
+		"protocol", "ip", "u32", "match", "ip", "src", ep.addr.IP.String() + "/32",
+		"action", "skbedit", "priority", prio}}
+	if ep.addrv6 != nil {
+		list = append(list, []string{"filter", "add", "dev", parent, "egress", "pref", strconv.Itoa(pref + 1),
+			"protocol", "ipv6", "u32", "match", "ip6", "src", ep.addrv6.IP.String() + "/128",
+			"action", "skbedit", "priority", prio})
+	}
+	return list
+}
+
+// addQoS sets the egress priority of the traffic of the endpoint
+func (n *network) addQoS(ep *endpoint) error {
+	if err := setupQoS(n.config.Parent); err != nil {
+		return err
+	}
+	n.Lock()
+	if ep.qosPref == 0 {
+		if n.qosPref == 0 {
+			n.qosPref = firstQoSPref
+		}
+		ep.qosPref = n.qosPref
+		n.qosPref += 2
+	}
+	pref := ep.qosPref
+	n.Unlock()
+	for _, args := range qosFilterArgs(n.config.Parent, pref, ep) {
+		if err := tcCmd(args...); err != nil {
+			n.delQoS(ep)
+			return err
+		}
+	}
+	logrus.Debugf("Set the egress priority %d of ipvlan endpoint %s on parent %s", ep.priority, ep.id, n.config.Parent)
+
+	return nil
+}
+
+// delQoS removes the priority filters of the endpoint
+func (n *network) delQoS(ep *endpoint) {
+	n.Lock()
+	pref := ep.qosPref
+	n.Unlock()
+	if pref == 0 {
+		return
+	}
+	for _, p := range []int{pref, pref + 1} {
+		tcCmd("filter", "del", "dev", n.config.Parent, "egress", "pref", strconv.Itoa(p))
+	}
+}
@@ -13,8 +13,13 @@ const (
 	dummyPrefix     = "di-" // ipvlan prefix for dummy parent interface
 	ipvlanKernelVer = 4     // minimum ipvlan kernel support
 	ipvlanMajorVer  = 2     // minimum ipvlan major kernel support
+	l3sKernelVer    = 4     // minimum ipvlan l3s kernel support
+	l3sMajorVer     = 9     // minimum ipvlan l3s major kernel support
 )
 
+// ipvlanModeL3S is the kernel l3s mode, which the netlink package predates
+const ipvlanModeL3S = netlink.IPVLAN_MODE_L3 + 1
+
 // createIPVlan Create the ipvlan slave specifying the source name
 func createIPVlan(containerIfName, parent, ipvlanMode string) (string, error) {
 	// Set the ipvlan mode. Default is bridge mode
@@ -47,13 +52,15 @@ func createIPVlan(containerIfName, parent, ipvlanMode string) (string, error) {
 	return ipvlan.Attrs().Name, nil
 }
 
-// setIPVlanMode setter for one of the two ipvlan port types
+// setIPVlanMode setter for one of the three ipvlan port types
 func setIPVlanMode(mode string) (netlink.IPVlanMode, error) {
 	switch mode {
 	case modeL2:
 		return netlink.IPVLAN_MODE_L2, nil
 	case modeL3:
 		return netlink.IPVLAN_MODE_L3, nil
+	case modeL3S:
+		return ipvlanModeL3S, nil
 	default:
 		return 0, fmt.Errorf("Unknown ipvlan mode: %s", mode)
 	}
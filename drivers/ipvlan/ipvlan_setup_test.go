@@ -1,8 +1,16 @@
 package ipvlan
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/docker/libnetwork/datastore"
 	"github.com/vishvananda/netlink"
 )
 
@@ -68,6 +76,14 @@ func TestSetIPVlanMode(t *testing.T) {
 	if mode != netlink.IPVLAN_MODE_L3 {
 		t.Fatalf("expected %d got %d", netlink.IPVLAN_MODE_L3, mode)
 	}
+	// test ipvlan l3s mode
+	mode, err = setIPVlanMode(modeL3S)
+	if err != nil {
+		t.Fatalf("error parsing %v vlan mode: %v", mode, err)
+	}
+	if mode != ipvlanModeL3S {
+		t.Fatalf("expected %d got %d", ipvlanModeL3S, mode)
+	}
 	// test invalid mode
 	mode, err = setIPVlanMode("foo")
 	if err == nil {
@@ -85,3 +101,108 @@ func TestSetIPVlanMode(t *testing.T) {
 		t.Fatalf("expected 0 got %d", mode)
 	}
 }
+
+// TestLowerName tests the lower link of the parents
+func TestLowerName(t *testing.T) {
+	for _, c := range []struct {
+		config *configuration
+		lower  string
+	}{
+		{&configuration{Parent: "eth0"}, "eth0"},
+		{&configuration{Parent: "eth0.10"}, "eth0.10"},
+		{&configuration{Parent: "eth0.10", CreatedSlaveLink: true}, "eth0"},
+	} {
+		if lower := c.config.lowerName(); lower != c.lower {
+			t.Fatalf("expected lower link %s of parent %s got %s", c.lower, c.config.Parent, lower)
+		}
+	}
+}
+
+// TestParsePriority tests the endpoint egress priority option
+func TestParsePriority(t *testing.T) {
+	for _, opt := range []interface{}{5, "5"} {
+		prio, err := parsePriority(opt)
+		if err != nil {
+			t.Fatalf("failed priority validation of %v: %v", opt, err)
+		}
+		if prio != 5 {
+			t.Fatalf("expected priority 5 got %d", prio)
+		}
+	}
+	for _, opt := range []interface{}{-1, 8, "foo", 5.0} {
+		if _, err := parsePriority(opt); err == nil {
+			t.Fatalf("failed to invalidate priority %v", opt)
+		}
+	}
+
+	ep := &endpoint{priority: 5, addr: &net.IPNet{IP: net.ParseIP("192.168.1.2"), Mask: net.CIDRMask(24, 32)}}
+	list := qosFilterArgs("eth0.10", 100, ep)
+	if len(list) != 1 {
+		t.Fatalf("expected 1 filter got %d", len(list))
+	}
+	expected := "filter add dev eth0.10 egress pref 100 protocol ip u32 match ip src 192.168.1.2/32 action skbedit priority 5"
+	if args := strings.Join(list[0], " "); args != expected {
+		t.Fatalf("expected %q got %q", expected, args)
+	}
+	ep.addrv6 = &net.IPNet{IP: net.ParseIP("2001:db8::2"), Mask: net.CIDRMask(64, 128)}
+	if list = qosFilterArgs("eth0.10", 100, ep); len(list) != 2 || list[1][6] != "101" {
+		t.Fatalf("expected an ipv6 filter of preference 101 got %v", list)
+	}
+}
+
+// TestNodeConfig tests the node configurations of the cluster networks
+func TestNodeConfig(t *testing.T) {
+	boltdb.Register()
+	tmp, err := ioutil.TempFile("", "libnetwork-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	ds, err := datastore.NewDataStore(datastore.LocalScope, &datastore.ScopeCfg{
+		Client: datastore.ScopeClientCfg{
+			Provider: "boltdb",
+			Address:  tmp.Name(),
+			Config:   &store.Config{Bucket: "libnetwork", ConnectionTimeout: 3 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &driver{networks: networkTable{}, store: ds}
+
+	cfgOnly := &configuration{ID: "net1", ConfigOnly: "vlan10", Parent: "eth0.10", IpvlanMode: modeL3}
+	if err := d.saveNodeConfig(cfgOnly); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.saveNodeConfig(&configuration{ID: "net2", ConfigOnly: "vlan10", Parent: "eth1.10"}); err == nil {
+		t.Fatal("saved the node configuration vlan10 twice")
+	}
+
+	config := &configuration{ID: "net3", ConfigFrom: "vlan10"}
+	if err := d.applyNodeConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Parent != "eth0.10" || config.IpvlanMode != modeL3 {
+		t.Fatalf("expected parent eth0.10 in l3 mode got %s in %s mode", config.Parent, config.IpvlanMode)
+	}
+	if err := d.applyNodeConfig(&configuration{ID: "net4", ConfigFrom: "vlan10", Parent: "eth1"}); err == nil {
+		t.Fatal("applied the node configuration over a parent")
+	}
+
+	d.deleteNodeConfig(cfgOnly)
+	if err := d.applyNodeConfig(&configuration{ID: "net5", ConfigFrom: "vlan10"}); err == nil {
+		t.Fatal("applied a deleted node configuration")
+	}
+
+	if _, err := d.NetworkAllocate("net6", map[string]string{configOnlyOpt: "vlan10"}, nil, nil); err == nil {
+		t.Fatal("allocated a configuration only network for the cluster")
+	}
+	opts, err := d.NetworkAllocate("net6", map[string]string{configFromOpt: "vlan10"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts[configFromOpt] != "vlan10" {
+		t.Fatalf("expected the %s option in the allocated options got %v", configFromOpt, opts)
+	}
+}
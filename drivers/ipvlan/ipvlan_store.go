@@ -23,8 +23,14 @@ type configuration struct {
 	Parent           string
 	IpvlanMode       string
 	CreatedSlaveLink bool
-	Ipv4Subnets      []*ipv4Subnet
-	Ipv6Subnets      []*ipv6Subnet
+	// whether the addresses of the endpoints are leased by the dhcp
+	// server of the parent network
+	DHCP bool
+	// name of the node configuration the network holds or uses
+	ConfigOnly  string
+	ConfigFrom  string
+	Ipv4Subnets []*ipv4Subnet
+	Ipv6Subnets []*ipv6Subnet
 }
 
 type ipv4Subnet struct {
@@ -117,6 +123,13 @@ func (config *configuration) MarshalJSON() ([]byte, error) {
 	nMap["IpvlanMode"] = config.IpvlanMode
 	nMap["Internal"] = config.Internal
 	nMap["CreatedSubIface"] = config.CreatedSlaveLink
+	nMap["DHCP"] = config.DHCP
+	if config.ConfigOnly != "" {
+		nMap["ConfigOnly"] = config.ConfigOnly
+	}
+	if config.ConfigFrom != "" {
+		nMap["ConfigFrom"] = config.ConfigFrom
+	}
 	if len(config.Ipv4Subnets) > 0 {
 		iis, err := json.Marshal(config.Ipv4Subnets)
 		if err != nil {
@@ -150,6 +163,15 @@ func (config *configuration) UnmarshalJSON(b []byte) error {
 	config.IpvlanMode = nMap["IpvlanMode"].(string)
 	config.Internal = nMap["Internal"].(bool)
 	config.CreatedSlaveLink = nMap["CreatedSubIface"].(bool)
+	if v, ok := nMap["DHCP"]; ok {
+		config.DHCP = v.(bool)
+	}
+	if v, ok := nMap["ConfigOnly"]; ok {
+		config.ConfigOnly = v.(string)
+	}
+	if v, ok := nMap["ConfigFrom"]; ok {
+		config.ConfigFrom = v.(string)
+	}
 	if v, ok := nMap["Ipv4Subnets"]; ok {
 		if err := json.Unmarshal([]byte(v.(string)), &config.Ipv4Subnets); err != nil {
 			return err
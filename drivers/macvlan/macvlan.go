@@ -4,9 +4,12 @@ import (
 	"net"
 	"sync"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/dhcp"
 	"github.com/docker/libnetwork/discoverapi"
 	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/netlabel"
 	"github.com/docker/libnetwork/osl"
 	"github.com/docker/libnetwork/types"
 )
@@ -21,6 +24,7 @@ const (
 	modeBridge          = "bridge"   // macvlan mode bridge
 	modePassthru        = "passthru" // macvlan mode passthrough
 	parentOpt           = "parent"   // parent interface -o parent
+	dhcpOpt             = "dhcp"     // addresses from the dhcp server of the parent network -o dhcp
 	modeOpt             = "_mode"    // macvlan mode ux opt suffix
 )
 
@@ -32,9 +36,13 @@ type networkTable map[string]*network
 
 type driver struct {
 	networks networkTable
+	// mac address reservations of the endpoints by address
+	macs map[string]*macReservation
 	sync.Once
 	sync.Mutex
 	store datastore.DataStore
+	// global datastore of the node configurations
+	globalStore datastore.DataStore
 }
 
 type endpoint struct {
@@ -43,6 +51,10 @@ type endpoint struct {
 	addr    *net.IPNet
 	addrv6  *net.IPNet
 	srcName string
+	lease   *dhcp.Keeper
+	// sandbox the endpoint joined, its interface being recreated in it
+	// when the parent link comes back
+	sboxKey string
 }
 
 type network struct {
@@ -51,6 +63,8 @@ type network struct {
 	endpoints endpointTable
 	driver    *driver
 	config    *configuration
+	// index of the host link the parent depends on
+	lower int
 	sync.Mutex
 }
 
@@ -61,20 +75,21 @@ func Init(dc driverapi.DriverCallback, config map[string]interface{}) error {
 	}
 	d := &driver{
 		networks: networkTable{},
+		macs:     make(map[string]*macReservation),
 	}
 	d.initStore(config)
+	if data, ok := config[netlabel.GlobalKVClient]; ok {
+		if dsc, ok := data.(discoverapi.DatastoreConfigData); ok {
+			if err := d.setGlobalStore(dsc); err != nil {
+				logrus.Warn(err)
+			}
+		}
+	}
+	go d.watchParents()
 
 	return dc.RegisterDriver(macvlanType, d, c)
 }
 
-func (d *driver) NetworkAllocate(id string, option map[string]string, ipV4Data, ipV6Data []driverapi.IPAMData) (map[string]string, error) {
-	return nil, types.NotImplementedErrorf("not implemented")
-}
-
-func (d *driver) NetworkFree(id string) error {
-	return types.NotImplementedErrorf("not implemented")
-}
-
 func (d *driver) EndpointOperInfo(nid, eid string) (map[string]interface{}, error) {
 	return make(map[string]interface{}, 0), nil
 }
@@ -93,6 +108,13 @@ func (d *driver) RevokeExternalConnectivity(nid, eid string) error {
 
 // DiscoverNew is a notification for a new discovery event
 func (d *driver) DiscoverNew(dType discoverapi.DiscoveryType, data interface{}) error {
+	if dType == discoverapi.DatastoreConfig {
+		dsc, ok := data.(discoverapi.DatastoreConfigData)
+		if !ok {
+			return types.InternalErrorf("incorrect data in datastore configuration: %v", data)
+		}
+		return d.setGlobalStore(dsc)
+	}
 	return nil
 }
 
@@ -0,0 +1,255 @@
+package macvlan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/discoverapi"
+	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/types"
+)
+
+const (
+	configOnlyOpt       = "config_only"    // name of the node configuration the network holds -o config_only
+	configFromOpt       = "config_from"    // name of the node configuration the network uses -o config_from
+	macvlanConfigPrefix = "macvlan-config" // prefix used for the node configurations
+)
+
+// The networks spanning the nodes of a cluster cannot share a parent, the
+// interfaces and vlans differing between the nodes. A configuration only
+// network, created on each node with -o config_only=<name>, holds the
+// parent and mode of the node and creates no link. It is saved as the
+// <name> configuration of the node in the global datastore, the local one
+// without a cluster. The cluster network, created with -o config_from=<name>,
+// takes the parent and mode of the configuration of the node it is created
+// on.
+
+// nodeConfig is the configuration of a node saved by a configuration only
+// network
+type nodeConfig struct {
+	Name        string
+	Node        string
+	Parent      string
+	MacvlanMode string
+	dbIndex     uint64
+	dbExists    bool
+	scope       string
+}
+
+// nodeName returns the name of the node the node configurations are
+// saved under
+func nodeName() (string, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the node name: %v", err)
+	}
+	return name, nil
+}
+
+// configStore returns the datastore of the node configurations
+func (d *driver) configStore() datastore.DataStore {
+	d.Lock()
+	defer d.Unlock()
+	if d.globalStore != nil {
+		return d.globalStore
+	}
+	return d.store
+}
+
+// setGlobalStore sets the global datastore the node configurations are
+// saved in
+func (d *driver) setGlobalStore(dsc discoverapi.DatastoreConfigData) error {
+	if dsc.Scope != datastore.GlobalScope {
+		return nil
+	}
+	store, err := datastore.NewDataStoreFromConfig(dsc)
+	if err != nil {
+		return types.InternalErrorf("macvlan driver failed to initialize the global data store: %v", err)
+	}
+	d.Lock()
+	d.globalStore = store
+	d.Unlock()
+
+	return nil
+}
+
+// saveNodeConfig saves the configuration of the node held by the
+// configuration only network
+func (d *driver) saveNodeConfig(config *configuration) error {
+	store := d.configStore()
+	if store == nil {
+		return fmt.Errorf("%s configuration only network %s requires a datastore", macvlanType, config.ConfigOnly)
+	}
+	node, err := nodeName()
+	if err != nil {
+		return err
+	}
+	nc := &nodeConfig{
+		Name:        config.ConfigOnly,
+		Node:        node,
+		Parent:      config.Parent,
+		MacvlanMode: config.MacvlanMode,
+		scope:       store.Scope(),
+	}
+	if err := store.GetObject(datastore.Key(nc.Key()...), &nodeConfig{}); err == nil {
+		return types.ForbiddenErrorf("%s configuration %s of node %s exists already", macvlanType, nc.Name, node)
+	}
+	if err := store.PutObjectAtomic(nc); err != nil {
+		return fmt.Errorf("failed to save the %s configuration %s of node %s: %v", macvlanType, nc.Name, node, err)
+	}
+
+	return nil
+}
+
+// deleteNodeConfig deletes the configuration of the node held by the
+// configuration only network
+func (d *driver) deleteNodeConfig(config *configuration) {
+	store := d.configStore()
+	node, err := nodeName()
+	if store == nil || err != nil {
+		return
+	}
+	nc := &nodeConfig{Name: config.ConfigOnly, Node: node}
+	if err := store.GetObject(datastore.Key(nc.Key()...), nc); err != nil {
+		return
+	}
+	if err := store.DeleteObjectAtomic(nc); err != nil {
+		logrus.Warnf("Failed to delete the %s configuration %s of node %s: %v", macvlanType, nc.Name, node, err)
+	}
+}
+
+// applyNodeConfig sets the parent and mode of the network from the
+// configuration of the node it uses
+func (d *driver) applyNodeConfig(config *configuration) error {
+	if config.Parent != "" || config.Internal {
+		return types.BadRequestErrorf("%s network %s takes its parent from the configuration %s, no %s can be given",
+			macvlanType, config.ID, config.ConfigFrom, parentOpt)
+	}
+	store := d.configStore()
+	if store == nil {
+		return fmt.Errorf("%s network %s requires a datastore to use the configuration %s", macvlanType, config.ID, config.ConfigFrom)
+	}
+	node, err := nodeName()
+	if err != nil {
+		return err
+	}
+	nc := &nodeConfig{Name: config.ConfigFrom, Node: node}
+	if err := store.GetObject(datastore.Key(nc.Key()...), nc); err != nil {
+		return types.NotFoundErrorf("no %s configuration %s for node %s: %v", macvlanType, nc.Name, node, err)
+	}
+	config.Parent = nc.Parent
+	if config.MacvlanMode == "" {
+		config.MacvlanMode = nc.MacvlanMode
+	}
+
+	return nil
+}
+
+// NetworkAllocate validates the options of the network spanning the nodes
+// of the cluster. Nothing is allocated, the parent of each node coming from
+// its configuration.
+func (d *driver) NetworkAllocate(id string, option map[string]string, ipV4Data, ipV6Data []driverapi.IPAMData) (map[string]string, error) {
+	config := &configuration{ID: id}
+	if err := config.fromOptions(option); err != nil {
+		return nil, err
+	}
+	if config.ConfigOnly != "" {
+		return nil, types.BadRequestErrorf("%s configuration only networks are local to the nodes", macvlanType)
+	}
+	if config.ConfigFrom != "" && config.Parent != "" {
+		return nil, types.BadRequestErrorf("%s network %s takes its parent from the configuration %s, no %s can be given",
+			macvlanType, id, config.ConfigFrom, parentOpt)
+	}
+	opts := make(map[string]string, len(option))
+	for k, v := range option {
+		opts[k] = v
+	}
+
+	return opts, nil
+}
+
+// NetworkFree frees the network allocated for the cluster
+func (d *driver) NetworkFree(id string) error {
+	return nil
+}
+
+func (nc *nodeConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"Name":        nc.Name,
+		"Node":        nc.Node,
+		"Parent":      nc.Parent,
+		"MacvlanMode": nc.MacvlanMode,
+	})
+}
+
+func (nc *nodeConfig) UnmarshalJSON(b []byte) error {
+	var cMap map[string]string
+	if err := json.Unmarshal(b, &cMap); err != nil {
+		return err
+	}
+	nc.Name = cMap["Name"]
+	nc.Node = cMap["Node"]
+	nc.Parent = cMap["Parent"]
+	nc.MacvlanMode = cMap["MacvlanMode"]
+
+	return nil
+}
+
+func (nc *nodeConfig) Key() []string {
+	return []string{macvlanConfigPrefix, nc.Name, nc.Node}
+}
+
+func (nc *nodeConfig) KeyPrefix() []string {
+	return []string{macvlanConfigPrefix, nc.Name}
+}
+
+func (nc *nodeConfig) Value() []byte {
+	b, err := json.Marshal(nc)
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+func (nc *nodeConfig) SetValue(value []byte) error {
+	return json.Unmarshal(value, nc)
+}
+
+func (nc *nodeConfig) Index() uint64 {
+	return nc.dbIndex
+}
+
+func (nc *nodeConfig) SetIndex(index uint64) {
+	nc.dbIndex = index
+	nc.dbExists = true
+}
+
+func (nc *nodeConfig) Exists() bool {
+	return nc.dbExists
+}
+
+func (nc *nodeConfig) Skip() bool {
+	return false
+}
+
+func (nc *nodeConfig) New() datastore.KVObject {
+	return &nodeConfig{scope: nc.scope}
+}
+
+func (nc *nodeConfig) CopyTo(o datastore.KVObject) error {
+	dst := o.(*nodeConfig)
+	*dst = *nc
+
+	return nil
+}
+
+func (nc *nodeConfig) DataScope() string {
+	if nc.scope != "" {
+		return nc.scope
+	}
+	return datastore.LocalScope
+}
@@ -0,0 +1,93 @@
+package macvlan
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/dhcp"
+	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/netutils"
+	"github.com/docker/libnetwork/osl"
+	"github.com/docker/libnetwork/types"
+	"github.com/vishvananda/netlink"
+)
+
+const dhcpPrefix = "dh" // macvlan prefix for the dhcp client link
+
+// validateDHCP verifies the network configuration is compatible with the
+// addresses leased by the dhcp server of the parent network
+func (config *configuration) validateDHCP(ipV4Data, ipV6Data []driverapi.IPAMData) error {
+	if len(ipV4Data) > 1 || (len(ipV4Data) == 1 && types.IsIPNetValid(ipV4Data[0].Pool)) || len(ipV6Data) > 0 {
+		return types.BadRequestErrorf("dhcp %s network %s must use the null ipam driver", macvlanType, config.ID)
+	}
+	if config.Parent == "" || config.Internal {
+		return types.BadRequestErrorf("dhcp %s network %s requires a parent interface", macvlanType, config.ID)
+	}
+	if config.MacvlanMode == modePassthru {
+		return types.BadRequestErrorf("dhcp %s network %s does not support the %s mode", macvlanType, config.ID, modePassthru)
+	}
+	return nil
+}
+
+// dhcpLink runs the function on a macvlan link of the parent created for
+// the time of the dhcp exchange, the endpoint interface being in the
+// container sandbox or not created yet
+func (n *network) dhcpLink(f func(ifName string) error) error {
+	defer osl.InitOSContext()()
+
+	name, err := netutils.GenerateIfaceName(dhcpPrefix, vethLen)
+	if err != nil {
+		return fmt.Errorf("error generating an interface name: %v", err)
+	}
+	if _, err := createMacVlan(name, n.config.Parent, n.config.MacvlanMode); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to find the dhcp client link %s: %v", name, err)
+	}
+	defer netlink.LinkDel(link)
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to enable the dhcp client link %s: %v", name, err)
+	}
+
+	return f(name)
+}
+
+// acquireLease obtains the address of the endpoint from the dhcp server
+// and keeps it renewed
+func (n *network) acquireLease(ep *endpoint) error {
+	var lease *dhcp.Lease
+	err := n.dhcpLink(func(ifName string) error {
+		var err error
+		lease, err = dhcp.Acquire(ifName, ep.mac)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to lease an address from the dhcp server on %s: %v", n.config.Parent, err)
+	}
+	ep.addr = lease.IP
+	ep.lease = dhcp.Keep(lease, func(l *dhcp.Lease) (*dhcp.Lease, error) {
+		var renewed *dhcp.Lease
+		err := n.dhcpLink(func(ifName string) error {
+			var err error
+			renewed, err = dhcp.Renew(ifName, ep.mac, l)
+			return err
+		})
+		return renewed, err
+	})
+	logrus.Debugf("Leased %s to macvlan endpoint %s from the dhcp server %s", lease.IP, ep.id, lease.Server)
+
+	return nil
+}
+
+// releaseLease gives the address of the endpoint back to the dhcp server
+func (n *network) releaseLease(ep *endpoint) {
+	lease := ep.lease.Lease()
+	err := n.dhcpLink(func(ifName string) error {
+		return dhcp.Release(ifName, ep.mac, lease)
+	})
+	if err != nil {
+		logrus.Warnf("Failed to release the dhcp lease of %s: %v", lease.IP, err)
+	}
+}
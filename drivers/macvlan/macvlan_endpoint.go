@@ -14,7 +14,7 @@ import (
 
 // CreateEndpoint assigns the mac, ip and endpoint id for the new container
 func (d *driver) CreateEndpoint(nid, eid string, ifInfo driverapi.InterfaceInfo,
-	epOptions map[string]interface{}) error {
+	epOptions map[string]interface{}) (err error) {
 	defer osl.InitOSContext()()
 
 	if err := validateID(nid, eid); err != nil {
@@ -24,12 +24,56 @@ func (d *driver) CreateEndpoint(nid, eid string, ifInfo driverapi.InterfaceInfo,
 	if err != nil {
 		return fmt.Errorf("network id %q not found", nid)
 	}
+	if n.config.ConfigOnly != "" {
+		return types.ForbiddenErrorf("%s network %s only holds the configuration %s of the node", macvlanType, nid, n.config.ConfigOnly)
+	}
 	ep := &endpoint{
 		id:     eid,
 		addr:   ifInfo.Address(),
 		addrv6: ifInfo.AddressIPv6(),
 		mac:    ifInfo.MacAddress(),
 	}
+	// reserve the requested mac or one of the oui pool of the network
+	if ep.mac != nil {
+		if err := validateMac(ep.mac); err != nil {
+			return err
+		}
+		if err := d.reserveMac(nid, eid, ep.mac); err != nil {
+			return err
+		}
+	} else if n.config.MacOUI != "" {
+		if ep.mac, err = d.allocateMac(nid, eid, n.config.MacOUI); err != nil {
+			return err
+		}
+		if err := ifInfo.SetMacAddress(ep.mac); err != nil {
+			d.releaseMacs(eid)
+			return err
+		}
+	}
+	defer func() {
+		if err != nil {
+			d.releaseMacs(eid)
+		}
+	}()
+	if n.config.DHCP {
+		if ep.addr != nil {
+			return fmt.Errorf("%s network %s leases the addresses from the dhcp server of its parent network", macvlanType, nid)
+		}
+		if ep.mac == nil {
+			ep.mac = netutils.GenerateRandomMAC()
+			if err := ifInfo.SetMacAddress(ep.mac); err != nil {
+				return err
+			}
+		}
+		if err := n.acquireLease(ep); err != nil {
+			return err
+		}
+		if err := ifInfo.SetIPAddress(ep.addr); err != nil {
+			ep.lease.Stop()
+			n.releaseLease(ep)
+			return err
+		}
+	}
 	if ep.addr == nil {
 		return fmt.Errorf("create endpoint was not passed an IP address")
 	}
@@ -70,6 +114,7 @@ func (d *driver) DeleteEndpoint(nid, eid string) error {
 	if n == nil {
 		return fmt.Errorf("network id %q not found", nid)
 	}
+	d.releaseMacs(eid)
 	ep := n.endpoint(eid)
 	if ep == nil {
 		return fmt.Errorf("endpoint id %q not found", eid)
@@ -77,6 +122,10 @@ func (d *driver) DeleteEndpoint(nid, eid string) error {
 	if link, err := netlink.LinkByName(ep.srcName); err == nil {
 		netlink.LinkDel(link)
 	}
+	if ep.lease != nil {
+		ep.lease.Stop()
+		n.releaseLease(ep)
+	}
 
 	return nil
 }
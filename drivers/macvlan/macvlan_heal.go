@@ -0,0 +1,285 @@
+package macvlan
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/netutils"
+	"github.com/docker/libnetwork/osl"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// The kernel deletes the macvlan links, the ones in the containers
+// included, with their parent link, as when the driver of the nic is
+// reloaded. The driver watches the lower links of the networks and when
+// one comes back under a new index, recreates the vlan sub-interface and
+// the host shim of the network on it and plugs a new interface in the
+// sandbox of each joined endpoint, with the address, mac and routes of the
+// lost one. A parent renamed in place is followed.
+
+// lowerName returns the name of the host link the network depends on, the
+// one the driver created its vlan sub-interface on or the parent itself
+func (config *configuration) lowerName() string {
+	if config.CreatedSlaveLink {
+		if isQinQ(config.Parent) {
+			if parent, _, _, err := parseQinQ(config.Parent); err == nil {
+				return parent
+			}
+		} else if parent, _, err := parseVlan(config.Parent); err == nil {
+			return parent
+		}
+	}
+	return config.Parent
+}
+
+// lowerIndex returns the index of the lower link of the network, 0 if
+// it does not exist
+func (config *configuration) lowerIndex() int {
+	link, err := netlink.LinkByName(config.lowerName())
+	if err != nil {
+		return 0
+	}
+	return link.Attrs().Index
+}
+
+// watchParents heals the networks on the changes of their lower links
+func (d *driver) watchParents() {
+	ch := make(chan netlink.LinkUpdate)
+	if err := netlink.LinkSubscribe(ch, nil); err != nil {
+		logrus.Warnf("%s driver failed to watch the parent links: %v", macvlanType, err)
+		return
+	}
+	for u := range ch {
+		attrs := u.Link.Attrs()
+		for _, n := range d.getNetworks() {
+			if n.config.Internal || n.config.ConfigOnly != "" {
+				continue
+			}
+			n.Lock()
+			lower := n.lower
+			n.Unlock()
+			switch {
+			case u.Header.Type == syscall.RTM_DELLINK:
+				if attrs.Index == lower {
+					logrus.Warnf("Parent %s of %s network %s was deleted, its endpoints are down until it is back",
+						attrs.Name, macvlanType, n.id)
+				}
+			case attrs.Index == lower && attrs.Name != n.config.lowerName() && !n.config.CreatedSlaveLink:
+				n.followRename(attrs.Name)
+			case attrs.Name == n.config.lowerName() && attrs.Index != lower:
+				n.heal(attrs.Index)
+			}
+		}
+	}
+}
+
+// followRename updates the parent of the network renamed in place, its
+// macvlan links remaining attached to it
+func (n *network) followRename(name string) {
+	logrus.Infof("Parent %s of %s network %s was renamed to %s", n.config.Parent, macvlanType, n.id, name)
+	n.config.Parent = name
+	if err := n.driver.storeUpdate(n.config); err != nil {
+		logrus.Warnf("Failed to update the parent of %s network %s: %v", macvlanType, n.id, err)
+	}
+}
+
+// heal recreates the links of the network on the lower link come back
+// under the index
+func (n *network) heal(index int) {
+	defer osl.InitOSContext()()
+
+	n.Lock()
+	n.lower = index
+	n.Unlock()
+	logrus.Infof("Parent %s of %s network %s is back, recreating its links", n.config.lowerName(), macvlanType, n.id)
+
+	if n.config.CreatedSlaveLink && !parentExists(n.config.Parent) {
+		var err error
+		if isQinQ(n.config.Parent) {
+			_, err = createQinQLink(n.config.Parent)
+		} else {
+			err = createVlanLink(n.config.Parent)
+		}
+		if err != nil {
+			logrus.Errorf("Failed to recreate the parent %s of %s network %s: %v", n.config.Parent, macvlanType, n.id, err)
+			return
+		}
+	}
+	if n.config.HostShim != "" {
+		if err := createHostShim(n.config); err != nil {
+			logrus.Errorf("Failed to recreate the host shim of %s network %s: %v", macvlanType, n.id, err)
+		}
+	}
+
+	n.Lock()
+	sboxes := make(map[*endpoint]string)
+	for _, ep := range n.endpoints {
+		if ep.sboxKey != "" {
+			sboxes[ep] = ep.sboxKey
+		}
+	}
+	n.Unlock()
+	for ep, key := range sboxes {
+		if err := n.replugEndpoint(ep, key); err != nil {
+			logrus.Errorf("Failed to recreate the interface of %s endpoint %s: %v", macvlanType, ep.id, err)
+			continue
+		}
+		if n.config.HostShim != "" {
+			if err := n.addShimRoute(ep); err != nil {
+				logrus.Warnf("Failed to restore the host shim route of %s endpoint %s: %v", macvlanType, ep.id, err)
+			}
+		}
+	}
+}
+
+// replugEndpoint plugs a new macvlan interface in the sandbox the endpoint
+// joined in place of the one lost with the parent. The interface takes the
+// first free name of the sandbox.
+func (n *network) replugEndpoint(ep *endpoint, sboxKey string) error {
+	sbox, err := osl.OpenSandbox(sboxKey, nil)
+	if err != nil {
+		return err
+	}
+	var found bool
+	sbox.InvokeFunc(func() {
+		_, err := netlink.LinkByAlias(ep.id)
+		found = err == nil
+	})
+	if found {
+		return nil
+	}
+
+	name, err := netutils.GenerateIfaceName(vethPrefix, vethLen)
+	if err != nil {
+		return fmt.Errorf("error generating an interface name: %v", err)
+	}
+	if _, err := createMacVlan(name, n.config.Parent, n.config.MacvlanMode); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to find the interface %s: %v", name, err)
+	}
+	if err := netlink.LinkSetAlias(link, ep.id); err != nil {
+		netlink.LinkDel(link)
+		return fmt.Errorf("failed to set the alias of the interface %s: %v", name, err)
+	}
+	if err := moveLink(link, sboxKey); err != nil {
+		netlink.LinkDel(link)
+		return err
+	}
+	routes := n.endpointRoutes(ep)
+	sbox.InvokeFunc(func() {
+		err = plugLink(name, ep, routes)
+	})
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("Recreated the interface of %s endpoint %s on parent %s", macvlanType, ep.id, n.config.Parent)
+
+	return nil
+}
+
+// endpointRoutes returns the default routes of the endpoint
+func (n *network) endpointRoutes(ep *endpoint) []*netlink.Route {
+	var gws []net.IP
+	if ep.lease != nil {
+		if gw := ep.lease.Lease().Gateway; gw != nil {
+			gws = append(gws, gw)
+		}
+	}
+	if len(n.config.Ipv4Subnets) > 0 {
+		if s := n.getSubnetforIPv4(ep.addr); s != nil {
+			if gw, _, err := net.ParseCIDR(s.GwIP); err == nil {
+				gws = append(gws, gw)
+			}
+		}
+	}
+	if len(n.config.Ipv6Subnets) > 0 && ep.addrv6 != nil {
+		if s := n.getSubnetforIPv6(ep.addrv6); s != nil {
+			if gw, _, err := net.ParseCIDR(s.GwIP); err == nil {
+				gws = append(gws, gw)
+			}
+		}
+	}
+	routes := make([]*netlink.Route, 0, len(gws))
+	for _, gw := range gws {
+		routes = append(routes, &netlink.Route{Gw: gw})
+	}
+	return routes
+}
+
+// moveLink moves the link into the network namespace at the path
+func moveLink(link netlink.Link, path string) error {
+	ns, err := netns.GetFromPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to open the network namespace at %s: %v", path, err)
+	}
+	defer ns.Close()
+	if err := netlink.LinkSetNsFd(link, int(ns)); err != nil {
+		return fmt.Errorf("failed to move the interface %s into the sandbox: %v", link.Attrs().Name, err)
+	}
+	return nil
+}
+
+// plugLink names the link moved into the sandbox and configures it with
+// the mac, addresses and routes of the endpoint. To be called in the
+// namespace of the sandbox.
+func plugLink(name string, ep *endpoint, routes []*netlink.Route) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to find the interface %s in the sandbox: %v", name, err)
+	}
+	dstName, err := freeIfName(containerVethPrefix)
+	if err != nil {
+		return err
+	}
+	if err := netlink.LinkSetName(link, dstName); err != nil {
+		return fmt.Errorf("failed to rename the interface %s to %s: %v", name, dstName, err)
+	}
+	if ep.mac != nil {
+		if err := netlink.LinkSetHardwareAddr(link, ep.mac); err != nil {
+			return fmt.Errorf("failed to set the mac address of %s: %v", dstName, err)
+		}
+	}
+	for _, addr := range []*net.IPNet{ep.addr, ep.addrv6} {
+		if addr == nil {
+			continue
+		}
+		if err := netlink.AddrAdd(link, &netlink.Addr{IPNet: addr}); err != nil {
+			return fmt.Errorf("failed to add the address %s to %s: %v", addr, dstName, err)
+		}
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to enable %s: %v", dstName, err)
+	}
+	for _, r := range routes {
+		r.LinkIndex = link.Attrs().Index
+		if err := netlink.RouteAdd(r); err != nil && err != syscall.EEXIST {
+			return fmt.Errorf("failed to add the route via %s to %s: %v", r.Gw, dstName, err)
+		}
+	}
+	return nil
+}
+
+// freeIfName returns the first name with the prefix no link of the
+// current namespace uses
+func freeIfName(prefix string) (string, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(links))
+	for _, l := range links {
+		used[l.Attrs().Name] = true
+	}
+	for i := 0; ; i++ {
+		if name := prefix + strconv.Itoa(i); !used[name] {
+			return name, nil
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/libnetwork/driverapi"
 	"github.com/docker/libnetwork/netutils"
 	"github.com/docker/libnetwork/osl"
+	"github.com/vishvananda/netlink"
 )
 
 // Join method is invoked when a Sandbox is attached to an endpoint.
@@ -37,6 +38,16 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 	if ep == nil {
 		return fmt.Errorf("could not find endpoint with id %s", eid)
 	}
+	// the default gateway of a leased address comes from the dhcp server
+	if ep.lease != nil {
+		if gw := ep.lease.Lease().Gateway; gw != nil {
+			if err := jinfo.SetGateway(gw); err != nil {
+				return err
+			}
+		}
+		logrus.Debugf("Macvlan Endpoint Joined with leased IPv4_Addr: %s, MacVlan_Mode: %s, Parent: %s",
+			ep.addr.IP.String(), n.config.MacvlanMode, n.config.Parent)
+	}
 	// parse and match the endpoint address with the available v4 subnets
 	if len(n.config.Ipv4Subnets) > 0 {
 		s := n.getSubnetforIPv4(ep.addr)
@@ -71,6 +82,21 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 		logrus.Debugf("Macvlan Endpoint Joined with IPv6_Addr: %s Gateway: %s MacVlan_Mode: %s, Parent: %s",
 			ep.addrv6.IP.String(), v6gw.String(), n.config.MacvlanMode, n.config.Parent)
 	}
+	// route the endpoint address of the host through the host shim
+	if n.config.HostShim != "" {
+		if err := n.addShimRoute(ep); err != nil {
+			return err
+		}
+	}
+	// mark the iface to find it in the sandbox when healing the endpoint
+	if link, err := netlink.LinkByName(vethName); err == nil {
+		if err := netlink.LinkSetAlias(link, eid); err != nil {
+			logrus.Debugf("Failed to set the alias of %s: %v", vethName, err)
+		}
+	}
+	n.Lock()
+	ep.sboxKey = sboxKey
+	n.Unlock()
 	iNames := jinfo.InterfaceName()
 	err = iNames.SetNames(vethName, containerVethPrefix)
 	if err != nil {
@@ -94,6 +120,12 @@ func (d *driver) Leave(nid, eid string) error {
 	if endpoint == nil {
 		return fmt.Errorf("could not find endpoint with id %s", eid)
 	}
+	if network.config.HostShim != "" {
+		network.delShimRoute(endpoint)
+	}
+	network.Lock()
+	endpoint.sboxKey = ""
+	network.Unlock()
 
 	return nil
 }
@@ -0,0 +1,227 @@
+package macvlan
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/types"
+)
+
+const (
+	macOUIOpt        = "mac_oui"     // oui of the pool of the endpoint mac addresses -o mac_oui
+	macvlanMacPrefix = "macvlan-mac" // prefix used for the persistent mac reservations
+	// number of random addresses of the pool tried before giving up
+	macPoolAttempts = 256
+)
+
+// The mac addresses requested by the endpoints and the ones drawn from the
+// oui pool of their network are reserved in the datastore, keyed by the
+// address, for the conflicts to be detected across the networks of the
+// driver and the restarts of the daemon. The addresses derived from the
+// endpoint ip are not reserved, the ipam keeping them unique.
+
+// macReservation is the reservation of a mac address by an endpoint
+type macReservation struct {
+	Mac      string
+	Network  string
+	Endpoint string
+	dbIndex  uint64
+	dbExists bool
+}
+
+// parseOUI parses the oui of the mac address pool: -o mac_oui=00:16:3e
+func parseOUI(value string) (net.HardwareAddr, error) {
+	oui, err := net.ParseMAC(value + ":00:00:00")
+	if err != nil || len(oui) != 6 {
+		return nil, fmt.Errorf("invalid mac oui %q, example formatting is 00:16:3e", value)
+	}
+	if oui[0]&1 != 0 {
+		return nil, fmt.Errorf("invalid mac oui %q, the multicast bit is set", value)
+	}
+	return oui[:3], nil
+}
+
+// validateMac verifies the mac address requested for an endpoint is a
+// unicast ethernet address
+func validateMac(mac net.HardwareAddr) error {
+	if len(mac) != 6 {
+		return types.BadRequestErrorf("invalid mac address %s, an ethernet address is required", mac)
+	}
+	if mac[0]&1 != 0 {
+		return types.BadRequestErrorf("invalid mac address %s, the multicast bit is set", mac)
+	}
+	if mac.String() == "00:00:00:00:00:00" {
+		return types.BadRequestErrorf("invalid mac address %s", mac)
+	}
+	return nil
+}
+
+// reserveMac reserves the mac address for the endpoint, failing if
+// another endpoint holds it
+func (d *driver) reserveMac(nid, eid string, mac net.HardwareAddr) error {
+	r := &macReservation{Mac: mac.String(), Network: nid, Endpoint: eid}
+	d.Lock()
+	if o, ok := d.macs[r.Mac]; ok {
+		d.Unlock()
+		return types.ForbiddenErrorf("mac address %s is in use by endpoint %s", mac, stringid.TruncateID(o.Endpoint))
+	}
+	d.macs[r.Mac] = r
+	d.Unlock()
+	if d.store == nil {
+		return nil
+	}
+	// the atomic put of the new record fails if the address is reserved
+	if err := d.store.PutObjectAtomic(r); err != nil {
+		d.Lock()
+		delete(d.macs, r.Mac)
+		d.Unlock()
+		if err == datastore.ErrKeyModified {
+			return types.ForbiddenErrorf("mac address %s is in use", mac)
+		}
+		return fmt.Errorf("failed to reserve the mac address %s: %v", mac, err)
+	}
+
+	return nil
+}
+
+// allocateMac reserves a random mac address of the oui pool for the
+// endpoint
+func (d *driver) allocateMac(nid, eid, oui string) (net.HardwareAddr, error) {
+	prefix, err := parseOUI(oui)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < macPoolAttempts; i++ {
+		mac := make(net.HardwareAddr, 6)
+		copy(mac, prefix)
+		if _, err := rand.Read(mac[3:]); err != nil {
+			return nil, err
+		}
+		err := d.reserveMac(nid, eid, mac)
+		if err == nil {
+			return mac, nil
+		}
+		if _, ok := err.(types.ForbiddenError); !ok {
+			return nil, err
+		}
+	}
+	return nil, types.NoServiceErrorf("no free mac address left in the pool of oui %s", oui)
+}
+
+// releaseMacs releases the mac addresses the endpoint reserved
+func (d *driver) releaseMacs(eid string) {
+	var released []*macReservation
+	d.Lock()
+	for mac, r := range d.macs {
+		if r.Endpoint == eid {
+			delete(d.macs, mac)
+			released = append(released, r)
+		}
+	}
+	d.Unlock()
+	for _, r := range released {
+		if err := d.storeDelete(r); err != nil {
+			logrus.Warnf("Failed to release the mac address %s of endpoint %s: %v", r.Mac, eid, err)
+		}
+	}
+}
+
+// populateMacs loads the mac reservations, dropping the ones of the
+// networks gone
+func (d *driver) populateMacs() error {
+	kvol, err := d.store.List(datastore.Key(macvlanMacPrefix), &macReservation{})
+	if err != nil && err != datastore.ErrKeyNotFound {
+		return fmt.Errorf("failed to get macvlan mac reservations from store: %v", err)
+	}
+	for _, kvo := range kvol {
+		r := kvo.(*macReservation)
+		if _, err := d.getNetwork(r.Network); err != nil {
+			if err := d.storeDelete(r); err != nil {
+				logrus.Warnf("Failed to delete the stale mac reservation %s: %v", r.Mac, err)
+			}
+			continue
+		}
+		d.macs[r.Mac] = r
+	}
+
+	return nil
+}
+
+func (r *macReservation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"Mac":      r.Mac,
+		"Network":  r.Network,
+		"Endpoint": r.Endpoint,
+	})
+}
+
+func (r *macReservation) UnmarshalJSON(b []byte) error {
+	var rMap map[string]string
+	if err := json.Unmarshal(b, &rMap); err != nil {
+		return err
+	}
+	r.Mac = rMap["Mac"]
+	r.Network = rMap["Network"]
+	r.Endpoint = rMap["Endpoint"]
+
+	return nil
+}
+
+func (r *macReservation) Key() []string {
+	return []string{macvlanMacPrefix, strings.Replace(r.Mac, ":", "", -1)}
+}
+
+func (r *macReservation) KeyPrefix() []string {
+	return []string{macvlanMacPrefix}
+}
+
+func (r *macReservation) Value() []byte {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+func (r *macReservation) SetValue(value []byte) error {
+	return json.Unmarshal(value, r)
+}
+
+func (r *macReservation) Index() uint64 {
+	return r.dbIndex
+}
+
+func (r *macReservation) SetIndex(index uint64) {
+	r.dbIndex = index
+	r.dbExists = true
+}
+
+func (r *macReservation) Exists() bool {
+	return r.dbExists
+}
+
+func (r *macReservation) Skip() bool {
+	return false
+}
+
+func (r *macReservation) New() datastore.KVObject {
+	return &macReservation{}
+}
+
+func (r *macReservation) CopyTo(o datastore.KVObject) error {
+	dst := o.(*macReservation)
+	*dst = *r
+
+	return nil
+}
+
+func (r *macReservation) DataScope() string {
+	return datastore.LocalScope
+}
@@ -2,6 +2,7 @@ package macvlan
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/pkg/parsers/kernel"
@@ -25,19 +26,35 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 		return fmt.Errorf("kernel version failed to meet the minimum macvlan kernel requirement of %d.%d, found %d.%d.%d",
 			macvlanKernelVer, macvlanMajorVer, kv.Kernel, kv.Major, kv.Minor)
 	}
-	// reject a null v4 network
-	if len(ipV4Data) == 0 || ipV4Data[0].Pool.String() == "0.0.0.0/0" {
-		return fmt.Errorf("ipv4 pool is empty")
-	}
 	// parse and validate the config and bind to networkConfiguration
 	config, err := parseNetworkOptions(nid, option)
 	if err != nil {
 		return err
 	}
 	config.ID = nid
-	err = config.processIPAM(nid, ipV4Data, ipV6Data)
-	if err != nil {
-		return err
+	if config.ConfigOnly != "" && config.ConfigFrom != "" {
+		return types.BadRequestErrorf("%s network %s cannot both hold and use a node configuration", macvlanType, nid)
+	}
+	// take the parent and mode of the node from the configuration
+	if config.ConfigFrom != "" {
+		if err := d.applyNodeConfig(config); err != nil {
+			return err
+		}
+	}
+	if config.DHCP {
+		// the dhcp server of the parent network leases the addresses
+		if err := config.validateDHCP(ipV4Data, ipV6Data); err != nil {
+			return err
+		}
+	} else {
+		// reject a null v4 network
+		if len(ipV4Data) == 0 || ipV4Data[0].Pool.String() == "0.0.0.0/0" {
+			return fmt.Errorf("ipv4 pool is empty")
+		}
+		err = config.processIPAM(nid, ipV4Data, ipV6Data)
+		if err != nil {
+			return err
+		}
 	}
 	// verify the macvlan mode from -o macvlan_mode option
 	switch config.MacvlanMode {
@@ -53,10 +70,23 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 	default:
 		return fmt.Errorf("requested macvlan mode '%s' is not valid, 'bridge' mode is the macvlan driver default", config.MacvlanMode)
 	}
+	// the host shim reaches the containers through the parent in bridge mode only
+	if config.HostShim != "" && config.MacvlanMode != modeBridge {
+		return fmt.Errorf("the %s option requires the macvlan %s mode", hostShimOpt, modeBridge)
+	}
 	// loopback is not a valid parent link
 	if config.Parent == "lo" {
 		return fmt.Errorf("loopback interface is not a valid %s parent link", macvlanType)
 	}
+	// save the parent and mode of the node for the networks using them
+	if config.ConfigOnly != "" {
+		if config.Parent == "" || config.Internal {
+			return types.BadRequestErrorf("%s configuration only network %s requires a parent interface", macvlanType, nid)
+		}
+		if err := d.saveNodeConfig(config); err != nil {
+			return err
+		}
+	}
 	// if parent interface not specified, create a dummy type link to use named dummy+net_id
 	if config.Parent == "" {
 		config.Parent = getDummyName(stringid.TruncateID(config.ID))
@@ -71,6 +101,9 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 	err = d.storeUpdate(config)
 	if err != nil {
 		d.deleteNetwork(config.ID)
+		if config.ConfigOnly != "" {
+			d.deleteNodeConfig(config)
+		}
 		logrus.Debugf("encoutered an error rolling back a network create for %s : %v", config.ID, err)
 		return err
 	}
@@ -80,8 +113,22 @@ func (d *driver) CreateNetwork(nid string, option map[string]interface{}, nInfo
 
 // createNetwork is used by new network callbacks and persistent network cache
 func (d *driver) createNetwork(config *configuration) error {
+	n := &network{
+		id:        config.ID,
+		driver:    d,
+		endpoints: endpointTable{},
+		config:    config,
+	}
+	// a configuration only network creates no link
+	if config.ConfigOnly != "" {
+		d.addNetwork(n)
+		return nil
+	}
 	networkList := d.getNetworks()
 	for _, nw := range networkList {
+		if nw.config.ConfigOnly != "" {
+			continue
+		}
 		if config.Parent == nw.config.Parent {
 			return fmt.Errorf("network %s is already using parent interface %s",
 				getDummyName(stringid.TruncateID(nw.config.ID)), config.Parent)
@@ -100,6 +147,15 @@ func (d *driver) createNetwork(config *configuration) error {
 				logrus.Debugf("Empty -o parent= and --internal flags limit communications to other containers inside of network: %s",
 					config.Parent)
 			}
+		} else if isQinQ(config.Parent) {
+			// a stacked vlan parent 'eth0.100.10' is the vlan '10' in the
+			// service vlan '100' of the parent iface 'eth0'
+			createdService, err := createQinQLink(config.Parent)
+			if err != nil {
+				return err
+			}
+			config.CreatedSlaveLink = true
+			config.CreatedServiceLink = createdService
 		} else {
 			// if the subinterface parent_iface.vlan_id checks do not pass, return err.
 			//  a valid example is 'eth0.10' for a parent iface 'eth0' with a vlan id '10'
@@ -111,12 +167,12 @@ func (d *driver) createNetwork(config *configuration) error {
 			config.CreatedSlaveLink = true
 		}
 	}
-	n := &network{
-		id:        config.ID,
-		driver:    d,
-		endpoints: endpointTable{},
-		config:    config,
+	if config.HostShim != "" {
+		if err := createHostShim(config); err != nil {
+			return err
+		}
 	}
+	n.lower = config.lowerIndex()
 	// add the *network
 	d.addNetwork(n)
 
@@ -141,6 +197,12 @@ func (d *driver) DeleteNetwork(nid string) error {
 					logrus.Debugf("link %s was not deleted, continuing the delete network operation: %v",
 						n.config.Parent, err)
 				}
+			} else if isQinQ(n.config.Parent) {
+				err := delQinQLink(n.config.Parent, n.config.CreatedServiceLink)
+				if err != nil {
+					logrus.Debugf("link %s was not deleted, continuing the delete network operation: %v",
+						n.config.Parent, err)
+				}
 			} else {
 				// only delete the link if it matches iface.vlan naming
 				err := delVlanLink(n.config.Parent)
@@ -151,6 +213,12 @@ func (d *driver) DeleteNetwork(nid string) error {
 			}
 		}
 	}
+	if n.config.HostShim != "" {
+		delHostShim(n.config)
+	}
+	if n.config.ConfigOnly != "" {
+		d.deleteNodeConfig(n.config)
+	}
 	// delete the *network
 	d.deleteNetwork(nid)
 	// delete the network record from persistent cache
@@ -217,6 +285,31 @@ func (config *configuration) fromOptions(labels map[string]string) error {
 		case driverModeOpt:
 			// parse driver option '-o macvlan_mode'
 			config.MacvlanMode = value
+		case hostShimOpt:
+			// parse driver option '-o host_shim'
+			if _, err := parseHostShim(value); err != nil {
+				return err
+			}
+			config.HostShim = value
+		case macOUIOpt:
+			// parse driver option '-o mac_oui'
+			if _, err := parseOUI(value); err != nil {
+				return err
+			}
+			config.MacOUI = value
+		case configOnlyOpt:
+			// parse driver option '-o config_only'
+			config.ConfigOnly = value
+		case configFromOpt:
+			// parse driver option '-o config_from'
+			config.ConfigFrom = value
+		case dhcpOpt:
+			// parse driver option '-o dhcp'
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s value %q: %v", dhcpOpt, value, err)
+			}
+			config.DHCP = enabled
 		}
 	}
 
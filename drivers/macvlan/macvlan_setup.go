@@ -2,6 +2,7 @@ package macvlan
 
 import (
 	"fmt"
+	"os/exec"
 	"strconv"
 	"strings"
 
@@ -159,6 +160,139 @@ func parseVlan(linkName string) (string, int, error) {
 	return parent, vidInt, nil
 }
 
+// isQinQ returns whether the parent is named after stacked vlans: eth0.100.200
+func isQinQ(linkName string) bool {
+	return strings.Count(linkName, ".") == 2
+}
+
+// createQinQLink creates the 802.1ad service vlan link if missing and the
+// 802.1q customer vlan link stacked on it. It returns whether the service
+// vlan link was created.
+func createQinQLink(parentName string) (bool, error) {
+	parent, svid, cvid, err := parseQinQ(parentName)
+	if err != nil {
+		return false, err
+	}
+	serviceName := fmt.Sprintf("%s.%d", parent, svid)
+	createdService := false
+	if !parentExists(serviceName) {
+		// the netlink package does not set the vlan protocol
+		out, err := exec.Command("ip", "link", "add", "link", parent, "name", serviceName,
+			"type", "vlan", "proto", "802.1ad", "id", strconv.Itoa(svid)).CombinedOutput()
+		if err != nil {
+			return false, fmt.Errorf("failed to create %s service vlan link: %v (%s)", serviceName, err, strings.TrimSpace(string(out)))
+		}
+		createdService = true
+	}
+	serviceLink, err := netlink.LinkByName(serviceName)
+	if err == nil {
+		err = netlink.LinkSetUp(serviceLink)
+	}
+	if err != nil {
+		if createdService {
+			delServiceLink(serviceName)
+		}
+		return false, fmt.Errorf("failed to enable %s the service vlan link: %v", serviceName, err)
+	}
+	vlanLink := &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        parentName,
+			ParentIndex: serviceLink.Attrs().Index,
+		},
+		VlanId: cvid,
+	}
+	if err := netlink.LinkAdd(vlanLink); err != nil {
+		if createdService {
+			delServiceLink(serviceName)
+		}
+		return false, fmt.Errorf("failed to create %s vlan link: %v", vlanLink.Name, err)
+	}
+	if err := netlink.LinkSetUp(vlanLink); err != nil {
+		return createdService, fmt.Errorf("failed to enable %s the macvlan parent link %v", vlanLink.Name, err)
+	}
+	logrus.Debugf("Added a stacked vlan netlink subinterface: %s with a service vlan id: %d and a customer vlan id: %d",
+		parentName, svid, cvid)
+
+	return createdService, nil
+}
+
+// delQinQLink deletes the customer vlan link of the stacked vlans, and the
+// service vlan link the driver created once no other link uses it
+func delQinQLink(linkName string, delService bool) error {
+	parent, svid, _, err := parseQinQ(linkName)
+	if err != nil {
+		return err
+	}
+	vlanLink, err := netlink.LinkByName(linkName)
+	if err != nil {
+		return fmt.Errorf("failed to find interface %s on the Docker host : %v", linkName, err)
+	}
+	if vlanLink.Attrs().ParentIndex == 0 {
+		return fmt.Errorf("interface %s does not appear to be a slave device", linkName)
+	}
+	if err := netlink.LinkDel(vlanLink); err != nil {
+		return fmt.Errorf("failed to delete  %s link: %v", linkName, err)
+	}
+	logrus.Debugf("Deleted a stacked vlan netlink subinterface: %s", linkName)
+	if delService {
+		return delServiceLink(fmt.Sprintf("%s.%d", parent, svid))
+	}
+
+	return nil
+}
+
+// delServiceLink deletes the service vlan link unless other links are
+// stacked on it
+func delServiceLink(serviceName string) error {
+	serviceLink, err := netlink.LinkByName(serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to find interface %s on the Docker host : %v", serviceName, err)
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if l.Attrs().ParentIndex == serviceLink.Attrs().Index {
+			logrus.Debugf("Service vlan link %s is still in use by %s", serviceName, l.Attrs().Name)
+			return nil
+		}
+	}
+	if err := netlink.LinkDel(serviceLink); err != nil {
+		return fmt.Errorf("failed to delete  %s link: %v", serviceName, err)
+	}
+	logrus.Debugf("Deleted a service vlan netlink subinterface: %s", serviceName)
+
+	return nil
+}
+
+// parseQinQ parses and verifies a stacked vlan interface name: -o parent=eth0.100.200
+func parseQinQ(linkName string) (string, int, int, error) {
+	splitName := strings.Split(linkName, ".")
+	if len(splitName) != 3 {
+		return "", 0, 0, fmt.Errorf("required interface name format is: name.service_vlan_id.vlan_id, ex. eth0.100.10 for vlan 10 in service vlan 100, instead received %s", linkName)
+	}
+	parent := splitName[0]
+	var vids [2]int
+	for i, vidStr := range splitName[1:] {
+		vid, err := strconv.Atoi(vidStr)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("unable to parse a valid vlan id from: %s (ex. eth0.100.10 for vlan 10 in service vlan 100)", vidStr)
+		}
+		// VLAN identifier or VID is a 12-bit field specifying the VLAN to which the frame belongs
+		if vid > 4094 || vid < 1 {
+			return "", 0, 0, fmt.Errorf("vlan id must be between 1-4094, received: %d", vid)
+		}
+		vids[i] = vid
+	}
+	// Check if the interface exists
+	if !parentExists(parent) {
+		return "", 0, 0, fmt.Errorf("-o parent interface does was not found on the host: %s", parent)
+	}
+
+	return parent, vids[0], vids[1], nil
+}
+
 // createDummyLink creates a dummy0 parent link
 func createDummyLink(dummyName, truncNetID string) error {
 	// create a parent interface since one was not specified
@@ -1,8 +1,16 @@
 package macvlan
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/docker/libnetwork/datastore"
 	"github.com/vishvananda/netlink"
 )
 
@@ -50,6 +58,30 @@ func TestValidateSubLink(t *testing.T) {
 	}
 }
 
+// TestValidateQinQLink tests valid 802.1ad stacked vlan naming convention
+func TestValidateQinQLink(t *testing.T) {
+	validQinQIface := "lo.100.10"
+	invalidQinQIface1 := "lo.100"
+	invalidQinQIface2 := "lo.0.10"
+	invalidQinQIface3 := "lo.100.5000"
+	invalidQinQIface4 := "foo123.100.10"
+
+	// test a valid parent_iface.service_vlan_id.vlan_id
+	parent, svid, cvid, err := parseQinQ(validQinQIface)
+	if err != nil {
+		t.Fatalf("failed stacked vlan validation: %v", err)
+	}
+	if parent != "lo" || svid != 100 || cvid != 10 {
+		t.Fatalf("unexpected stacked vlan %s %d %d", parent, svid, cvid)
+	}
+	// test invalid stacked vlans
+	for _, iface := range []string{invalidQinQIface1, invalidQinQIface2, invalidQinQIface3, invalidQinQIface4} {
+		if _, _, _, err := parseQinQ(iface); err == nil {
+			t.Fatalf("failed stacked vlan validation test: %s", iface)
+		}
+	}
+}
+
 // TestSetMacVlanMode tests the macvlan mode setter
 func TestSetMacVlanMode(t *testing.T) {
 	// test macvlan bridge mode
@@ -101,3 +133,130 @@ func TestSetMacVlanMode(t *testing.T) {
 		t.Fatalf("expected 0 got %d", mode)
 	}
 }
+
+// TestParseHostShim tests the host shim address parsing
+func TestParseHostShim(t *testing.T) {
+	addr, err := parseHostShim("192.168.1.250")
+	if err != nil {
+		t.Fatalf("failed host shim address validation: %v", err)
+	}
+	if addr.String() != "192.168.1.250/32" {
+		t.Fatalf("expected 192.168.1.250/32 got %s", addr)
+	}
+	for _, value := range []string{"", "0.0.0.0", "192.168.1.250/24", "fe80::1"} {
+		if _, err := parseHostShim(value); err == nil {
+			t.Fatalf("failed to invalidate host shim address %q", value)
+		}
+	}
+	if name := getShimName("0123456789ab"); name != "ms-0123456789ab" {
+		t.Fatalf("expected ms-0123456789ab got %s", name)
+	}
+}
+
+// TestLowerName tests the lower link of the parents
+func TestLowerName(t *testing.T) {
+	for _, c := range []struct {
+		config *configuration
+		lower  string
+	}{
+		{&configuration{Parent: "eth0"}, "eth0"},
+		{&configuration{Parent: "eth0.10"}, "eth0.10"},
+		{&configuration{Parent: "eth0.10", CreatedSlaveLink: true}, "eth0"},
+		{&configuration{Parent: "eth0.100.10", CreatedSlaveLink: true}, "eth0"},
+	} {
+		if lower := c.config.lowerName(); lower != c.lower {
+			t.Fatalf("expected lower link %s of parent %s got %s", c.lower, c.config.Parent, lower)
+		}
+	}
+}
+
+// TestMacPool tests the mac address reservations and the oui pool
+func TestMacPool(t *testing.T) {
+	if _, err := parseOUI("00:16:3e"); err != nil {
+		t.Fatalf("failed oui validation: %v", err)
+	}
+	for _, value := range []string{"", "00:16", "00:16:3e:01", "01:00:5e"} {
+		if _, err := parseOUI(value); err == nil {
+			t.Fatalf("failed to invalidate oui %q", value)
+		}
+	}
+	for _, value := range []string{"01:00:5e:00:00:01", "00:00:00:00:00:00"} {
+		mac, _ := net.ParseMAC(value)
+		if err := validateMac(mac); err == nil {
+			t.Fatalf("failed to invalidate mac address %s", value)
+		}
+	}
+
+	d := &driver{networks: networkTable{}, macs: make(map[string]*macReservation)}
+	mac, err := d.allocateMac("net1", "ep1", "00:16:3e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mac.String(), "00:16:3e:") {
+		t.Fatalf("expected a mac address of oui 00:16:3e got %s", mac)
+	}
+	if err := d.reserveMac("net2", "ep2", mac); err == nil {
+		t.Fatalf("reserved mac address %s twice", mac)
+	}
+	d.releaseMacs("ep1")
+	if err := d.reserveMac("net2", "ep2", mac); err != nil {
+		t.Fatalf("failed to reserve the released mac address %s: %v", mac, err)
+	}
+}
+
+// TestNodeConfig tests the node configurations of the cluster networks
+func TestNodeConfig(t *testing.T) {
+	boltdb.Register()
+	tmp, err := ioutil.TempFile("", "libnetwork-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	ds, err := datastore.NewDataStore(datastore.LocalScope, &datastore.ScopeCfg{
+		Client: datastore.ScopeClientCfg{
+			Provider: "boltdb",
+			Address:  tmp.Name(),
+			Config:   &store.Config{Bucket: "libnetwork", ConnectionTimeout: 3 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &driver{networks: networkTable{}, store: ds}
+
+	cfgOnly := &configuration{ID: "net1", ConfigOnly: "vlan10", Parent: "eth0.10", MacvlanMode: modeVepa}
+	if err := d.saveNodeConfig(cfgOnly); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.saveNodeConfig(&configuration{ID: "net2", ConfigOnly: "vlan10", Parent: "eth1.10"}); err == nil {
+		t.Fatal("saved the node configuration vlan10 twice")
+	}
+
+	config := &configuration{ID: "net3", ConfigFrom: "vlan10"}
+	if err := d.applyNodeConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Parent != "eth0.10" || config.MacvlanMode != modeVepa {
+		t.Fatalf("expected parent eth0.10 in vepa mode got %s in %s mode", config.Parent, config.MacvlanMode)
+	}
+	if err := d.applyNodeConfig(&configuration{ID: "net4", ConfigFrom: "vlan10", Parent: "eth1"}); err == nil {
+		t.Fatal("applied the node configuration over a parent")
+	}
+
+	d.deleteNodeConfig(cfgOnly)
+	if err := d.applyNodeConfig(&configuration{ID: "net5", ConfigFrom: "vlan10"}); err == nil {
+		t.Fatal("applied a deleted node configuration")
+	}
+
+	if _, err := d.NetworkAllocate("net6", map[string]string{configOnlyOpt: "vlan10"}, nil, nil); err == nil {
+		t.Fatal("allocated a configuration only network for the cluster")
+	}
+	opts, err := d.NetworkAllocate("net6", map[string]string{configFromOpt: "vlan10"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts[configFromOpt] != "vlan10" {
+		t.Fatalf("expected the %s option in the allocated options got %v", configFromOpt, opts)
+	}
+}
@@ -0,0 +1,112 @@
+package macvlan
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	shimPrefix  = "ms-"       // macvlan prefix for the host shim interface
+	hostShimOpt = "host_shim" // host address of the shim to the containers -o host_shim
+)
+
+// getShimName returns the name of the host shim of the network with
+// truncated net ID and driver prefix
+func getShimName(netID string) string {
+	return fmt.Sprintf("%s%s", shimPrefix, netID)
+}
+
+// parseHostShim parses the host address of the shim: -o host_shim=192.168.1.250
+func parseHostShim(value string) (*net.IPNet, error) {
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil || ip.IsUnspecified() {
+		return nil, fmt.Errorf("invalid host shim address %q, example formatting is 192.168.1.250", value)
+	}
+	return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, nil
+}
+
+// createHostShim creates the bridge mode macvlan link of the host on the
+// parent, the host reaching the containers of the network through it
+// rather than through the parent, which the macvlan links are isolated from
+func createHostShim(config *configuration) error {
+	addr, err := parseHostShim(config.HostShim)
+	if err != nil {
+		return err
+	}
+	name := getShimName(stringid.TruncateID(config.ID))
+	// remove the shim left by a previous run of the daemon
+	if link, err := netlink.LinkByName(name); err == nil {
+		netlink.LinkDel(link)
+	}
+	if _, err := createMacVlan(name, config.Parent, modeBridge); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(name)
+	if err == nil {
+		err = netlink.AddrAdd(link, &netlink.Addr{IPNet: addr})
+	}
+	if err == nil {
+		err = netlink.LinkSetUp(link)
+	}
+	if err != nil {
+		delHostShim(config)
+		return fmt.Errorf("failed to set up the host shim %s: %v", name, err)
+	}
+	logrus.Debugf("Added the host shim %s with address %s on parent %s", name, addr, config.Parent)
+
+	return nil
+}
+
+// delHostShim deletes the host shim of the network
+func delHostShim(config *configuration) {
+	name := getShimName(stringid.TruncateID(config.ID))
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		logrus.Debugf("host shim %s was not deleted: %v", name, err)
+	}
+}
+
+// shimRoute returns the route of the host to the endpoint through the
+// host shim of the network
+func (n *network) shimRoute(ep *endpoint) (*netlink.Route, error) {
+	name := getShimName(stringid.TruncateID(n.id))
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the host shim %s: %v", name, err)
+	}
+	return &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+		Dst:       &net.IPNet{IP: ep.addr.IP, Mask: net.CIDRMask(32, 32)},
+	}, nil
+}
+
+// addShimRoute routes the address of the endpoint through the host shim
+func (n *network) addShimRoute(ep *endpoint) error {
+	route, err := n.shimRoute(ep)
+	if err != nil {
+		return err
+	}
+	if err := netlink.RouteAdd(route); err != nil {
+		return fmt.Errorf("failed to route %s through the host shim: %v", ep.addr.IP, err)
+	}
+	return nil
+}
+
+// delShimRoute removes the route of the endpoint through the host shim
+func (n *network) delShimRoute(ep *endpoint) {
+	route, err := n.shimRoute(ep)
+	if err == nil {
+		err = netlink.RouteDel(route)
+	}
+	if err != nil {
+		logrus.Debugf("host shim route of %s was not deleted: %v", ep.addr.IP, err)
+	}
+}
@@ -23,8 +23,21 @@ type configuration struct {
 	Parent           string
 	MacvlanMode      string
 	CreatedSlaveLink bool
-	Ipv4Subnets      []*ipv4Subnet
-	Ipv6Subnets      []*ipv6Subnet
+	// whether the 802.1ad service vlan link of a stacked vlan parent
+	// was created by the driver
+	CreatedServiceLink bool
+	// whether the addresses of the endpoints are leased by the dhcp
+	// server of the parent network
+	DHCP bool
+	// host address of the shim the host reaches the containers through
+	HostShim string
+	// oui of the pool of the endpoint mac addresses
+	MacOUI string
+	// name of the node configuration the network holds or uses
+	ConfigOnly  string
+	ConfigFrom  string
+	Ipv4Subnets []*ipv4Subnet
+	Ipv6Subnets []*ipv6Subnet
 }
 
 type ipv4Subnet struct {
@@ -50,7 +63,11 @@ func (d *driver) initStore(option map[string]interface{}) error {
 			return types.InternalErrorf("macvlan driver failed to initialize data store: %v", err)
 		}
 
-		return d.populateNetworks()
+		if err := d.populateNetworks(); err != nil {
+			return err
+		}
+
+		return d.populateMacs()
 	}
 
 	return nil
@@ -117,6 +134,20 @@ func (config *configuration) MarshalJSON() ([]byte, error) {
 	nMap["MacvlanMode"] = config.MacvlanMode
 	nMap["Internal"] = config.Internal
 	nMap["CreatedSubIface"] = config.CreatedSlaveLink
+	nMap["CreatedServiceIface"] = config.CreatedServiceLink
+	nMap["DHCP"] = config.DHCP
+	if config.HostShim != "" {
+		nMap["HostShim"] = config.HostShim
+	}
+	if config.MacOUI != "" {
+		nMap["MacOUI"] = config.MacOUI
+	}
+	if config.ConfigOnly != "" {
+		nMap["ConfigOnly"] = config.ConfigOnly
+	}
+	if config.ConfigFrom != "" {
+		nMap["ConfigFrom"] = config.ConfigFrom
+	}
 	if len(config.Ipv4Subnets) > 0 {
 		iis, err := json.Marshal(config.Ipv4Subnets)
 		if err != nil {
@@ -150,6 +181,24 @@ func (config *configuration) UnmarshalJSON(b []byte) error {
 	config.MacvlanMode = nMap["MacvlanMode"].(string)
 	config.Internal = nMap["Internal"].(bool)
 	config.CreatedSlaveLink = nMap["CreatedSubIface"].(bool)
+	if v, ok := nMap["CreatedServiceIface"]; ok {
+		config.CreatedServiceLink = v.(bool)
+	}
+	if v, ok := nMap["DHCP"]; ok {
+		config.DHCP = v.(bool)
+	}
+	if v, ok := nMap["HostShim"]; ok {
+		config.HostShim = v.(string)
+	}
+	if v, ok := nMap["MacOUI"]; ok {
+		config.MacOUI = v.(string)
+	}
+	if v, ok := nMap["ConfigOnly"]; ok {
+		config.ConfigOnly = v.(string)
+	}
+	if v, ok := nMap["ConfigFrom"]; ok {
+		config.ConfigFrom = v.(string)
+	}
 	if v, ok := nMap["Ipv4Subnets"]; ok {
 		if err := json.Unmarshal([]byte(v.(string)), &config.Ipv4Subnets); err != nil {
 			return err
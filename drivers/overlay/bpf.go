@@ -0,0 +1,278 @@
+package overlay
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/netlabel"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+// The experimental eBPF data path forwards the traffic of the subnets
+// between the host end of their veth pair and an externally controlled
+// vxlan device of the node with the tc classifiers of the eBPF object
+// configured on the driver, which provides:
+//
+//   - the "encap" section, attached to the ingress of the host ends, which
+//     looks up the destination mac of the frames in the ovl_peers map, sets
+//     the tunnel key to the vtep of the peer and redirects them to the vxlan
+//     device, leaving the other frames to the next filters (TC_ACT_UNSPEC)
+//   - the "decap" section, attached to the ingress of the vxlan device,
+//     which looks up the vni of the frames in the ovl_vnis map and redirects
+//     them to the host end of the subnet
+//   - the ovl_peers hash map, of key {u32 ifindex, u8 mac[6], u16 pad} and
+//     value {u32 vni, u32 vtep}, and the ovl_vnis hash map, of key {u32 vni}
+//     and value {u32 ifindex}, pinned by tc in the globals namespace. The
+//     vteps are in network byte order, the other fields in host byte order.
+//
+// The broadcast and multicast traffic is replicated by the flooding filter
+// of the geneve subnets.
+const (
+	dataPathKernel = "kernel"
+	dataPathBPF    = "bpf"
+	bpfMapDir      = "/sys/fs/bpf/tc/globals"
+	bpfPeerMap     = "ovl_peers"
+	bpfVniMap      = "ovl_vnis"
+)
+
+// bpfDevName returns the name of the externally controlled vxlan device of
+// the node receiving and sending the traffic of the eBPF data path on the
+// port
+func bpfDevName(port uint16) string {
+	return fmt.Sprintf("vxb-%d", port)
+}
+
+// bpfObject returns the path of the eBPF object of the data path
+func (d *driver) bpfObject() (string, error) {
+	obj, ok := d.config[netlabel.OverlayBPFObject].(string)
+	if !ok || obj == "" {
+		return "", fmt.Errorf("no eBPF object configured for the overlay data path")
+	}
+	return obj, nil
+}
+
+func bpfMapUpdate(name string, key, value []byte) error {
+	args := append([]string{"map", "update", "pinned", filepath.Join(bpfMapDir, name), "key", "hex"}, hexBytes(key)...)
+	args = append(append(args, "value", "hex"), hexBytes(value)...)
+	if out, err := exec.Command("bpftool", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update the eBPF map %s: %v (%s)", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func bpfMapDelete(name string, key []byte) error {
+	args := append([]string{"map", "delete", "pinned", filepath.Join(bpfMapDir, name), "key", "hex"}, hexBytes(key)...)
+	if out, err := exec.Command("bpftool", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete from the eBPF map %s: %v (%s)", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func hexBytes(b []byte) []string {
+	s := make([]string, len(b))
+	for i := range b {
+		s[i] = hex.EncodeToString(b[i : i+1])
+	}
+	return s
+}
+
+func bpfU32(v uint32) []byte {
+	b := make([]byte, 4)
+	nl.NativeEndian().PutUint32(b, v)
+	return b
+}
+
+// bpfPeerKey returns the ovl_peers key of the peer mac on the host end
+func bpfPeerKey(ifIndex int, peerMac net.HardwareAddr) []byte {
+	key := append(bpfU32(uint32(ifIndex)), peerMac...)
+	return append(key, 0, 0)
+}
+
+// bpfPeerValue returns the ovl_peers value sending the traffic of the vni
+// to the vtep
+func bpfPeerValue(vni uint32, vtep net.IP) []byte {
+	return append(bpfU32(vni), vtep.To4()...)
+}
+
+// bpfDevice returns the vxlan device of the eBPF data path on the port,
+// creating it and attaching the decap program on the first use
+func (d *driver) bpfDevice(port uint16) (string, error) {
+	name := bpfDevName(port)
+
+	obj, err := d.bpfObject()
+	if err != nil {
+		return "", err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.bpfDevs[port] {
+		return name, nil
+	}
+
+	// Remove the device of a previous run of the daemon
+	deleteInterface(name)
+
+	if out, err := exec.Command("ip", "link", "add", name, "type", "vxlan",
+		"dstport", strconv.Itoa(int(port)), "external").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("error creating eBPF data path vxlan interface, port %d may be in use by the kernel data path: %v (%s)",
+			port, err, strings.TrimSpace(string(out)))
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return "", fmt.Errorf("could not find link by name %s: %v", name, err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return "", fmt.Errorf("could not bring up vxlan interface %s: %v", name, err)
+	}
+	if err := tc("qdisc", "add", "dev", name, "clsact"); err != nil {
+		return "", err
+	}
+	if err := tc("filter", "add", "dev", name, "ingress", "prio", "1", "bpf", "da", "obj", obj, "sec", "decap"); err != nil {
+		return "", err
+	}
+
+	if d.bpfDevs == nil {
+		d.bpfDevs = make(map[uint16]bool)
+	}
+	d.bpfDevs[port] = true
+	return name, nil
+}
+
+// initBPFSubnet connects the bridge of the subnet to the vxlan device of
+// the eBPF data path through a veth pair whose host end runs the encap
+// program
+func (n *network) initBPFSubnet(s *subnet, ifName, brName string) (string, error) {
+	if n.driver.underlayIPv6() {
+		return "", fmt.Errorf("the eBPF data path does not support an IPv6 underlay")
+	}
+	if _, err := n.driver.bpfDevice(n.dstPort()); err != nil {
+		return "", err
+	}
+	obj, err := n.driver.bpfObject()
+	if err != nil {
+		return "", err
+	}
+
+	hostIfName := "gh" + strings.TrimPrefix(ifName, "gn")
+	if err := createGeneveVeth(hostIfName, ifName); err != nil {
+		return "", err
+	}
+
+	iface, err := net.InterfaceByName(hostIfName)
+	if err != nil {
+		deleteInterface(hostIfName)
+		return "", fmt.Errorf("could not find interface %s: %v", hostIfName, err)
+	}
+	if err := tc("qdisc", "add", "dev", hostIfName, "clsact"); err != nil {
+		deleteInterface(hostIfName)
+		return "", err
+	}
+	if err := tc("filter", "add", "dev", hostIfName, "ingress", "prio", "1", "bpf", "da", "obj", obj, "sec", "encap"); err != nil {
+		deleteInterface(hostIfName)
+		return "", err
+	}
+	if err := bpfMapUpdate(bpfVniMap, bpfU32(n.vxlanID(s)), bpfU32(uint32(iface.Index))); err != nil {
+		deleteInterface(hostIfName)
+		return "", err
+	}
+
+	sbox := n.sandbox()
+	if err := sbox.AddInterface(ifName, "veth",
+		sbox.InterfaceOptions().Master(brName)); err != nil {
+		removeBPFSubnet(n.vxlanID(s), hostIfName)
+		return "", fmt.Errorf("eBPF data path interface creation failed for subnet %q: %v", s.subnetIP.String(), err)
+	}
+
+	return hostIfName, nil
+}
+
+// removeBPFSubnet removes the veth pair of the subnet and its vni from the
+// eBPF maps, the peers of the host end being removed with it
+func removeBPFSubnet(vni uint32, hostIfName string) {
+	if iface, err := net.InterfaceByName(hostIfName); err == nil {
+		removeBPFPeers(iface.Index)
+	}
+	if err := bpfMapDelete(bpfVniMap, bpfU32(vni)); err != nil {
+		logrus.Warnf("Could not remove the eBPF data path vni %d: %v", vni, err)
+	}
+	if err := deleteInterface(hostIfName); err != nil {
+		logrus.Warnf("could not cleanup eBPF data path interface: %v", err)
+	}
+}
+
+// removeBPFPeers removes the peers of the host end from the eBPF map
+func removeBPFPeers(ifIndex int) {
+	out, err := exec.Command("bpftool", "map", "dump", "pinned", filepath.Join(bpfMapDir, bpfPeerMap)).Output()
+	if err != nil {
+		return
+	}
+	prefix := strings.Join(hexBytes(bpfU32(uint32(ifIndex))), " ")
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "key: "+prefix) {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "key: "))
+		if len(fields) < 12 {
+			continue
+		}
+		key, err := hex.DecodeString(strings.Join(fields[:12], ""))
+		if err == nil {
+			bpfMapDelete(bpfPeerMap, key)
+		}
+	}
+}
+
+// bpfAddPeer sends the traffic of the subnet to the peer mac to the vtep of
+// the peer through the eBPF data path
+func (n *network) bpfAddPeer(s *subnet, peerMac net.HardwareAddr, vtep net.IP) error {
+	n.Lock()
+	if s.genevePeers == nil {
+		s.genevePeers = make(map[string]genevePeer)
+	}
+	s.genevePeers[peerMac.String()] = genevePeer{vtep: vtep}
+	hostIfName := s.hostIfName
+	n.Unlock()
+
+	iface, err := net.InterfaceByName(hostIfName)
+	if err != nil {
+		return fmt.Errorf("could not find interface %s: %v", hostIfName, err)
+	}
+	if err := bpfMapUpdate(bpfPeerMap, bpfPeerKey(iface.Index, peerMac), bpfPeerValue(n.vxlanID(s), vtep)); err != nil {
+		return err
+	}
+
+	return n.geneveUpdateFlood(s)
+}
+
+// bpfDeletePeer stops sending the traffic of the subnet to the peer mac
+func (n *network) bpfDeletePeer(s *subnet, peerMac net.HardwareAddr) error {
+	n.Lock()
+	_, ok := s.genevePeers[peerMac.String()]
+	delete(s.genevePeers, peerMac.String())
+	hostIfName := s.hostIfName
+	n.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	iface, err := net.InterfaceByName(hostIfName)
+	if err != nil {
+		return fmt.Errorf("could not find interface %s: %v", hostIfName, err)
+	}
+	if err := bpfMapDelete(bpfPeerMap, bpfPeerKey(iface.Index, peerMac)); err != nil {
+		return err
+	}
+
+	return n.geneveUpdateFlood(s)
+}
@@ -0,0 +1,688 @@
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/iptables"
+	"github.com/docker/libnetwork/netlabel"
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	encryptionWireGuard = "wireguard"
+	// The WireGuard devices of the node, one per key generation parity
+	// so that the next generation is set up before the current one is
+	// removed during a key rotation
+	wgIfPrefix   = "wg-overlay"
+	wgListenPort = 51820
+	// fwmark of the VXLAN traffic of the encrypted networks, routed
+	// through the WireGuard devices by a policy routing rule
+	wgMark  = 0xD0C4
+	wgTable = 0xD0C4
+	// Time given to a key advertised by a peer to reach all the nodes
+	// before the traffic is sent to the peer with it
+	wgSwitchDelay  = 10 * time.Second
+	wgTickInterval = 2 * time.Second
+)
+
+// wgDevice is the WireGuard device of a key generation
+type wgDevice struct {
+	gen       uint32
+	publicKey string
+}
+
+// wgPeer is the state of the WireGuard peer of a tunnel endpoint
+type wgPeer struct {
+	// generation the peer sends the traffic with
+	gen uint32
+	// public keys of the peer by generation, and when they were first
+	// advertised
+	keys map[uint32]string
+	seen map[uint32]time.Time
+	// keys installed on the devices by generation
+	installed map[uint32]string
+	// generation the traffic is sent to the peer with
+	sendGen uint32
+	routed  bool
+}
+
+// wgState is the WireGuard setup of the node, shared by the encrypted
+// networks. The key is rotated by setting up the device of the next
+// generation, sending the traffic to each peer with it once the peer
+// advertised its key of the generation, and removing the device of the
+// previous generation once all the peers send with the new one.
+type wgState struct {
+	initErr error
+	gen     uint32
+	devs    map[uint32]*wgDevice
+	peers   map[string]*wgPeer
+	// rotation interval, zero when the keys are rotated only when the
+	// peers do
+	interval  time.Duration
+	lastStart time.Time
+	sync.Mutex
+}
+
+func wgDevName(gen uint32) string {
+	return fmt.Sprintf("%s%d", wgIfPrefix, gen%2)
+}
+
+func wgPort(gen uint32) int {
+	return wgListenPort + int(gen%2)
+}
+
+func wgCmd(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed: %v (%s)", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// createWgDevice creates the WireGuard device of the generation with a
+// fresh key pair
+func createWgDevice(gen uint32) (*wgDevice, error) {
+	name := wgDevName(gen)
+
+	// Remove the device of a previous run of the daemon or generation
+	exec.Command("ip", "link", "del", name).Run()
+
+	if _, err := wgCmd("ip", "link", "add", name, "type", "wireguard"); err != nil {
+		return nil, err
+	}
+
+	privKey, err := wgCmd("wg", "genkey")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("wg", "pubkey")
+	cmd.Stdin = strings.NewReader(privKey)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive the wireguard public key: %v", err)
+	}
+
+	// wg reads the private key from a file only
+	f, err := ioutil.TempFile("", name)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(privKey)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, args := range [][]string{
+		{"wg", "set", name, "listen-port", strconv.Itoa(wgPort(gen)), "private-key", f.Name()},
+		{"ip", "link", "set", name, "up"},
+	} {
+		if _, err := wgCmd(args[0], args[1:]...); err != nil {
+			return nil, err
+		}
+	}
+
+	// The decrypted traffic comes in from the device the peer is not
+	// routed through
+	if err := ioutil.WriteFile("/proc/sys/net/ipv4/conf/"+name+"/rp_filter", []byte("2"), 0644); err != nil {
+		logrus.Warnf("Failed to set loose reverse path filtering on %s: %v", name, err)
+	}
+
+	return &wgDevice{gen: gen, publicKey: strings.TrimSpace(string(out))}, nil
+}
+
+// setupWireGuard creates the WireGuard device of the node and routes the
+// marked VXLAN traffic of the underlay address family through the devices.
+// The traffic to the peers whose key is unknown is rejected rather than
+// sent in the clear.
+func setupWireGuard(v6 bool) (*wgState, error) {
+	dev, err := createWgDevice(0)
+	if err != nil {
+		return nil, err
+	}
+	exec.Command("ip", "link", "del", wgDevName(1)).Run()
+
+	table := strconv.Itoa(wgTable)
+	family := "-4"
+	if v6 {
+		family = "-6"
+	}
+	if _, err := wgCmd("ip", family, "route", "replace", "unreachable", "default", "table", table); err != nil {
+		return nil, err
+	}
+	if !ruleListed(family, table) {
+		if _, err := wgCmd("ip", family, "rule", "add", "fwmark", strconv.Itoa(wgMark), "table", table); err != nil {
+			return nil, err
+		}
+	}
+
+	return &wgState{
+		devs:      map[uint32]*wgDevice{0: dev},
+		peers:     make(map[string]*wgPeer),
+		lastStart: time.Now(),
+	}, nil
+}
+
+// ruleListed returns whether the policy routing rule of the marked
+// traffic was left by a previous run of the daemon
+func ruleListed(family, table string) bool {
+	out, err := wgCmd("ip", family, "rule", "show")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "fwmark 0x"+strconv.FormatInt(wgMark, 16)) && strings.Contains(line, "lookup "+table) {
+			return true
+		}
+	}
+	return false
+}
+
+// wireGuard returns the WireGuard setup of the node, setting it up on
+// the first use
+func (d *driver) wireGuard() (*wgState, error) {
+	d.wgOnce.Do(func() {
+		wg, err := setupWireGuard(d.underlayIPv6())
+		if err != nil {
+			logrus.Errorf("Failed to set up the overlay wireguard device: %v", err)
+			d.wg = &wgState{initErr: err}
+			return
+		}
+		if v, ok := d.config[netlabel.OverlayKeyRotationInterval]; ok {
+			if wg.interval, err = parseInterval(v); err != nil {
+				logrus.Warnf("Invalid overlay key rotation interval %v: %v", v, err)
+			}
+		}
+		d.wg = wg
+		go d.wgLoop()
+	})
+	return d.wg, d.wg.initErr
+}
+
+func parseInterval(v interface{}) (time.Duration, error) {
+	switch i := v.(type) {
+	case time.Duration:
+		return i, nil
+	case string:
+		return time.ParseDuration(i)
+	}
+	return 0, fmt.Errorf("unexpected type %T", v)
+}
+
+// wgKeys returns the key the node sends the traffic of the network with,
+// the one of the next generation during a key rotation and the current
+// generation, empty when the network is not encrypted
+func (d *driver) wgKeys(n *network) (string, string, uint32) {
+	if n.encryption != encryptionWireGuard {
+		return "", "", 0
+	}
+	wg, err := d.wireGuard()
+	if err != nil {
+		return "", "", 0
+	}
+
+	wg.Lock()
+	defer wg.Unlock()
+
+	var next string
+	if dev, ok := wg.devs[wg.gen+1]; ok {
+		next = dev.publicKey
+	}
+	return wg.devs[wg.gen].publicKey, next, wg.gen
+}
+
+// peerRecord returns the record advertising the local endpoint
+func (d *driver) peerRecord(n *network, ep *endpoint) *PeerRecord {
+	key, next, gen := d.wgKeys(n)
+	return &PeerRecord{
+		EndpointIP:          ep.addr.String(),
+		EndpointMAC:         ep.mac.String(),
+		TunnelEndpointIP:    d.bindAddress,
+		TunnelPublicKey:     key,
+		TunnelNextPublicKey: next,
+		TunnelKeyGeneration: gen,
+	}
+}
+
+// wgAdvertise updates the records of the local endpoints of the encrypted
+// networks with the keys of the node
+func (d *driver) wgAdvertise() {
+	d.Lock()
+	var networks []*network
+	for _, n := range d.networks {
+		if n.encryption != "" && n.nInfo != nil {
+			networks = append(networks, n)
+		}
+	}
+	d.Unlock()
+
+	for _, n := range networks {
+		n.Lock()
+		var eps []*endpoint
+		for _, ep := range n.endpoints {
+			if ep.ifName != "" {
+				eps = append(eps, ep)
+			}
+		}
+		n.Unlock()
+
+		for _, ep := range eps {
+			buf, err := proto.Marshal(d.peerRecord(n, ep))
+			if err != nil {
+				logrus.Errorf("Failed to marshal the peer record of endpoint %s: %v", ep.id, err)
+				continue
+			}
+			if err := n.nInfo.UpdateTableEntry(ovPeerTable, ep.id, buf); err != nil {
+				logrus.Debugf("Failed to update the peer record of endpoint %s: %v", ep.id, err)
+			}
+		}
+	}
+}
+
+// wgPeerUpdate installs the keys the peer of the tunnel endpoint
+// advertised, starting a key rotation when the peer did
+func (d *driver) wgPeerUpdate(vtep net.IP, rec *PeerRecord) {
+	if rec.TunnelPublicKey == "" || vtep.String() == d.bindAddress {
+		return
+	}
+	wg, err := d.wireGuard()
+	if err != nil {
+		return
+	}
+
+	wg.Lock()
+	p, ok := wg.peers[vtep.String()]
+	if !ok {
+		p = &wgPeer{
+			keys:      make(map[uint32]string),
+			seen:      make(map[uint32]time.Time),
+			installed: make(map[uint32]string),
+			sendGen:   wg.gen,
+		}
+		wg.peers[vtep.String()] = p
+	}
+	p.gen = rec.TunnelKeyGeneration
+	p.setKey(rec.TunnelKeyGeneration, rec.TunnelPublicKey)
+	if rec.TunnelNextPublicKey != "" {
+		p.setKey(rec.TunnelKeyGeneration+1, rec.TunnelNextPublicKey)
+	}
+
+	changed := wg.follow()
+	wg.install(vtep, p)
+	wg.Unlock()
+
+	if changed {
+		d.wgAdvertise()
+	}
+}
+
+func (p *wgPeer) setKey(gen uint32, key string) {
+	if p.keys[gen] != key {
+		p.keys[gen] = key
+		p.seen[gen] = time.Now()
+	}
+	for g := range p.keys {
+		if g+2 < gen {
+			delete(p.keys, g)
+			delete(p.seen, g)
+		}
+	}
+}
+
+// follow catches up with the generation of the peers, rotating the key
+// when a peer is ahead. A node joining the cluster adopts its generation.
+// To be called while holding the wireguard lock.
+func (wg *wgState) follow() bool {
+	if len(wg.devs) > 1 {
+		return false
+	}
+
+	target := wg.gen
+	for _, p := range wg.peers {
+		for g := range p.keys {
+			if g > target {
+				target = g
+			}
+		}
+	}
+	if target == wg.gen {
+		return false
+	}
+
+	if target%2 == wg.gen%2 {
+		// The device of the generation parity is the current one
+		dev := wg.devs[wg.gen]
+		delete(wg.devs, wg.gen)
+		dev.gen = target
+		wg.devs[target] = dev
+		wg.gen = target
+		for vtep, p := range wg.peers {
+			p.sendGen = target
+			p.routed = false
+			p.installed = make(map[uint32]string)
+			wg.install(net.ParseIP(vtep), p)
+		}
+		return true
+	}
+
+	return wg.startRotation(target)
+}
+
+// startRotation sets up the device of the next generation, accepting the
+// traffic of the peers with their key of the generation. To be called
+// while holding the wireguard lock.
+func (wg *wgState) startRotation(gen uint32) bool {
+	dev, err := createWgDevice(gen)
+	if err != nil {
+		logrus.Errorf("Failed to rotate the overlay wireguard key: %v", err)
+		return false
+	}
+	logrus.Debugf("overlay: starting the rotation to wireguard key generation %d", gen)
+	wg.devs[gen] = dev
+	wg.lastStart = time.Now()
+	for vtep, p := range wg.peers {
+		wg.install(net.ParseIP(vtep), p)
+	}
+	return true
+}
+
+// install configures the keys of the peer on the devices of the node and
+// routes the traffic to the peer through the device it is sent with. To
+// be called while holding the wireguard lock.
+func (wg *wgState) install(vtep net.IP, p *wgPeer) {
+	for gen, dev := range wg.devs {
+		key, ok := p.keys[gen]
+		if !ok || p.installed[gen] == key {
+			continue
+		}
+		if old, ok := p.installed[gen]; ok {
+			if _, err := wgCmd("wg", "set", wgDevName(dev.gen), "peer", old, "remove"); err != nil {
+				logrus.Warnf("Failed to remove the wireguard peer %s: %v", vtep, err)
+			}
+		}
+		if _, err := wgCmd("wg", "set", wgDevName(dev.gen), "peer", key,
+			"endpoint", net.JoinHostPort(vtep.String(), strconv.Itoa(wgPort(dev.gen))),
+			"allowed-ips", hostPrefix(vtep)); err != nil {
+			logrus.Errorf("Failed to add the wireguard peer %s: %v", vtep, err)
+			delete(p.installed, gen)
+			continue
+		}
+		p.installed[gen] = key
+	}
+
+	if !p.routed && p.installed[p.sendGen] != "" {
+		wg.route(vtep, p, p.sendGen)
+	}
+}
+
+// route sends the traffic to the peer through the device of the generation
+func (wg *wgState) route(vtep net.IP, p *wgPeer, gen uint32) {
+	if _, err := wgCmd("ip", "route", "replace", hostPrefix(vtep), "dev", wgDevName(gen),
+		"table", strconv.Itoa(wgTable)); err != nil {
+		logrus.Errorf("Failed to route the traffic to %s through wireguard: %v", vtep, err)
+		return
+	}
+	p.sendGen = gen
+	p.routed = true
+}
+
+// wgLoop drives the key rotations of the node
+func (d *driver) wgLoop() {
+	for range time.Tick(wgTickInterval) {
+		d.wgTick()
+	}
+}
+
+func (d *driver) wgTick() {
+	// The peers whose endpoints are all gone no longer take part in
+	// the rotations
+	vteps := make(map[string]bool)
+	d.peerDbWalk(func(nid string, pKey *peerKey, pEntry *peerEntry) bool {
+		if !pEntry.isLocal {
+			vteps[pEntry.vtep.String()] = true
+		}
+		return false
+	})
+
+	wg := d.wg
+	wg.Lock()
+	for vtep := range wg.peers {
+		if !vteps[vtep] {
+			wg.removePeer(vtep)
+		}
+	}
+	changed := wg.step(time.Now())
+	wg.Unlock()
+
+	if changed {
+		d.wgAdvertise()
+	}
+}
+
+// removePeer removes the keys and the route of the peer. To be called
+// while holding the wireguard lock.
+func (wg *wgState) removePeer(vtep string) {
+	p := wg.peers[vtep]
+	for gen, key := range p.installed {
+		if dev, ok := wg.devs[gen]; ok {
+			wgCmd("wg", "set", wgDevName(dev.gen), "peer", key, "remove")
+		}
+	}
+	if p.routed {
+		wgCmd("ip", "route", "del", hostPrefix(net.ParseIP(vtep)), "table", strconv.Itoa(wgTable))
+	}
+	delete(wg.peers, vtep)
+}
+
+// step moves the key rotation forward, returning whether the keys to
+// advertise changed. To be called while holding the wireguard lock.
+func (wg *wgState) step(now time.Time) bool {
+	next := wg.gen + 1
+	if _, ok := wg.devs[next]; ok {
+		// Send with the next generation to the peers which accept it
+		switched := true
+		for vtep, p := range wg.peers {
+			if p.sendGen == next {
+				continue
+			}
+			if p.installed[next] != "" && now.Sub(p.seen[next]) >= wgSwitchDelay {
+				wg.route(net.ParseIP(vtep), p, next)
+			}
+			if p.sendGen != next {
+				switched = false
+			}
+		}
+		if !switched {
+			return false
+		}
+		logrus.Debugf("overlay: sending with wireguard key generation %d", next)
+		wg.gen = next
+		return true
+	}
+
+	if prev, ok := wg.devs[wg.gen-1]; ok && wg.gen > 0 {
+		// Remove the previous generation once no peer sends with it
+		for _, p := range wg.peers {
+			if p.gen < wg.gen {
+				return false
+			}
+		}
+		if _, err := wgCmd("ip", "link", "del", wgDevName(prev.gen)); err != nil {
+			logrus.Warnf("Failed to remove the previous overlay wireguard device: %v", err)
+		}
+		delete(wg.devs, prev.gen)
+		for _, p := range wg.peers {
+			delete(p.installed, prev.gen)
+		}
+		return false
+	}
+
+	if wg.interval != 0 && now.Sub(wg.lastStart) >= wg.interval {
+		return wg.startRotation(next)
+	}
+	return false
+}
+
+// u32At returns the u32 location of the offset from the UDP header of the
+// outer packet, skipping the IPv4 header of variable length or the IPv6
+// header without extension headers
+func u32At(offset int, v6 bool) string {
+	if v6 {
+		return strconv.Itoa(40 + offset)
+	}
+	return fmt.Sprintf("0>>22&0x3C@%d", offset)
+}
+
+// vniMatch returns the u32 match of the VXLAN header carrying the vni
+func vniMatch(vni uint32, v6 bool) string {
+	return fmt.Sprintf("%s&0xFFFFFF00=%d", u32At(12, v6), int(vni)<<8)
+}
+
+// encryptSelector selects the encapsulated IPv4 traffic from the src
+// prefix to the dst prefix, a nil prefix matching any address
+type encryptSelector struct {
+	src *net.IPNet
+	dst *net.IPNet
+}
+
+// parseEncryptionPolicy parses a comma separated list of prefixes, whose
+// traffic from and to any address is encrypted, and of dash separated
+// pairs of prefixes, whose traffic between each other is encrypted. An
+// address stands for the prefix of the single endpoint.
+func parseEncryptionPolicy(value string) ([]encryptSelector, error) {
+	var sels []encryptSelector
+	for _, item := range strings.Split(value, ",") {
+		var prefixes []*net.IPNet
+		for _, s := range strings.Split(strings.TrimSpace(item), "-") {
+			s = strings.TrimSpace(s)
+			if !strings.Contains(s, "/") {
+				s += "/32"
+			}
+			_, prefix, err := net.ParseCIDR(s)
+			if err != nil || prefix.IP.To4() == nil {
+				return nil, fmt.Errorf("invalid encryption policy prefix %q", s)
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		switch len(prefixes) {
+		case 1:
+			sels = append(sels, encryptSelector{src: prefixes[0]}, encryptSelector{dst: prefixes[0]})
+		case 2:
+			sels = append(sels, encryptSelector{src: prefixes[0], dst: prefixes[1]},
+				encryptSelector{src: prefixes[1], dst: prefixes[0]})
+		default:
+			return nil, fmt.Errorf("invalid encryption policy %q", item)
+		}
+	}
+	return sels, nil
+}
+
+// formatEncryptionPolicy returns the list form of the selectors
+func formatEncryptionPolicy(sels []encryptSelector) string {
+	var items []string
+	for i := 0; i+1 < len(sels); i += 2 {
+		if sels[i].dst == nil {
+			items = append(items, sels[i].src.String())
+		} else {
+			items = append(items, sels[i].src.String()+"-"+sels[i].dst.String())
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// prefixMatch returns the u32 match of the inner IPv4 header field at the
+// offset from the VXLAN header being in the prefix
+func prefixMatch(offset int, prefix *net.IPNet, v6 bool) string {
+	ip := prefix.IP.To4()
+	return fmt.Sprintf("%s&0x%x=0x%x", u32At(8+offset, v6), []byte(prefix.Mask), []byte(ip))
+}
+
+// match returns the u32 match of the VXLAN traffic of the vni selected by
+// the selector, the inner frame being an untagged IPv4 one
+func (sel encryptSelector) match(vni uint32, v6 bool) string {
+	m := vniMatch(vni, v6)
+	if sel.src == nil && sel.dst == nil {
+		return m
+	}
+	m += "&&" + u32At(26, v6) + "&0xFFFF=0x800"
+	if sel.src != nil {
+		m += "&&" + prefixMatch(34, sel.src, v6)
+	}
+	if sel.dst != nil {
+		m += "&&" + prefixMatch(38, sel.dst, v6)
+	}
+	return m
+}
+
+// encryptionRules returns the rules marking the outgoing VXLAN traffic
+// of the vni selected by the policy, all of it without a policy, to route
+// it through the WireGuard devices and dropping the incoming one which
+// was not decrypted by them
+func encryptionRules(vni uint32, port uint16, policy []encryptSelector, v6 bool) [][]string {
+	if len(policy) == 0 {
+		policy = []encryptSelector{{}}
+	}
+	var rules [][]string
+	for _, sel := range policy {
+		match := []string{"-p", "udp", "--dport", strconv.Itoa(int(port)), "-m", "u32", "--u32", sel.match(vni, v6)}
+		rules = append(rules,
+			append([]string{"-t", "mangle", "OUTPUT"}, append(match, "-j", "MARK", "--set-mark", strconv.Itoa(wgMark))...),
+			append([]string{"-t", "filter", "INPUT", "!", "-i", wgIfPrefix + "+"}, append(match, "-j", "DROP")...))
+	}
+	return rules
+}
+
+func programEncryption(vni uint32, port uint16, policy []encryptSelector, v6 bool, add bool) error {
+	for _, rule := range encryptionRules(vni, port, policy, v6) {
+		table, chain, args := iptables.Table(rule[1]), rule[2], rule[3:]
+		var exists bool
+		if v6 {
+			exists = ip6tables(append([]string{"-t", string(table), "-C", chain}, args...)...) == nil
+		} else {
+			exists = iptables.Exists(table, chain, args...)
+		}
+		if add == exists {
+			continue
+		}
+		op := "-D"
+		if add {
+			op = "-I"
+		}
+		cmd := append([]string{"-t", string(table), op, chain}, args...)
+		var err error
+		if v6 {
+			err = ip6tables(cmd...)
+		} else {
+			err = iptables.RawCombinedOutput(cmd...)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to program the encryption rule of vni %d: %v", vni, err)
+		}
+	}
+	return nil
+}
+
+// ip6tables runs ip6tables, which the iptables package does not drive
+func ip6tables(args ...string) error {
+	_, err := wgCmd("ip6tables", args...)
+	return err
+}
+
+// setupEncryption encrypts the traffic of the subnet of an encrypted
+// network through the WireGuard devices of the node
+func (n *network) setupEncryption(s *subnet) error {
+	if _, err := n.driver.wireGuard(); err != nil {
+		return fmt.Errorf("wireguard encryption is not available: %v", err)
+	}
+	return programEncryption(n.vxlanID(s), n.dstPort(), n.encryptPolicy, n.driver.underlayIPv6(), true)
+}
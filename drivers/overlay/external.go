@@ -0,0 +1,226 @@
+package overlay
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/types"
+	"github.com/gogo/protobuf/proto"
+)
+
+// externalPeerJSON is the stored form of an external peer
+type externalPeerJSON struct {
+	Address  string
+	Mac      string
+	Vtep     string
+	Prefixes []string `json:",omitempty"`
+}
+
+// externalPeerID returns the peer table key of the external peer
+func externalPeerID(ip net.IP) string {
+	return "external-" + ip.String()
+}
+
+func (ep *externalPeerJSON) peer() (*driverapi.ExternalPeer, error) {
+	addr, err := types.ParseCIDR(ep.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid external peer address %q: %v", ep.Address, err)
+	}
+	mac, err := net.ParseMAC(ep.Mac)
+	if err != nil {
+		return nil, fmt.Errorf("invalid external peer mac %q: %v", ep.Mac, err)
+	}
+	vtep := net.ParseIP(ep.Vtep)
+	if vtep == nil {
+		return nil, fmt.Errorf("invalid external peer vtep %q", ep.Vtep)
+	}
+	prefixes, err := parsePrefixes(ep.Prefixes)
+	if err != nil {
+		return nil, err
+	}
+	return &driverapi.ExternalPeer{Address: addr, MacAddress: mac, Vtep: vtep, Prefixes: prefixes}, nil
+}
+
+func parsePrefixes(list []string) ([]*net.IPNet, error) {
+	var prefixes []*net.IPNet
+	for _, s := range list {
+		_, prefix, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid external prefix %q: %v", s, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+func formatPrefixes(prefixes []*net.IPNet) []string {
+	var list []string
+	for _, p := range prefixes {
+		list = append(list, p.String())
+	}
+	return list
+}
+
+func externalPeerRecord(peer *driverapi.ExternalPeer) *PeerRecord {
+	return &PeerRecord{
+		EndpointIP:       peer.Address.String(),
+		EndpointMAC:      peer.MacAddress.String(),
+		TunnelEndpointIP: peer.Vtep.String(),
+		ExternalPrefixes: formatPrefixes(peer.Prefixes),
+	}
+}
+
+// AddExternalPeer registers into the network a static peer which is not a
+// libnetwork node, such as a bare-metal host or a hardware VTEP. The peer
+// is stored with the network and advertised to the other nodes through the
+// peer table by this node.
+func (d *driver) AddExternalPeer(nid string, peer *driverapi.ExternalPeer) error {
+	n := d.network(nid)
+	if n == nil {
+		return types.NotFoundErrorf("could not find network with id %s", nid)
+	}
+	if peer.Vtep.IsUnspecified() || isIPv6(peer.Vtep) != d.underlayIPv6() || peer.Vtep.String() == d.bindAddress {
+		return types.BadRequestErrorf("invalid external peer vtep %s", peer.Vtep)
+	}
+	if n.encryption != "" {
+		return types.ForbiddenErrorf("encrypted network %s cannot reach external peers", nid)
+	}
+	if n.getSubnetforIP(peer.Address) == nil {
+		return types.BadRequestErrorf("external peer address %s does not belong to network %s", peer.Address, nid)
+	}
+
+	key := peer.Address.IP.String()
+	n.Lock()
+	if n.externalPeers == nil {
+		n.externalPeers = make(map[string]*driverapi.ExternalPeer)
+	}
+	old := n.externalPeers[key]
+	n.externalPeers[key] = peer
+	n.Unlock()
+
+	if err := n.writeToStore(); err != nil {
+		n.Lock()
+		if old != nil {
+			n.externalPeers[key] = old
+		} else {
+			delete(n.externalPeers, key)
+		}
+		n.Unlock()
+		return fmt.Errorf("failed to update data store for network %v: %v", n.id, err)
+	}
+
+	eid := externalPeerID(peer.Address.IP)
+	if old != nil {
+		d.peerDelete(nid, eid, old.Address.IP, old.Address.Mask, old.MacAddress, old.Vtep, true)
+	}
+	n.setExternalRoutes(peer.Address.IP, peer.Prefixes)
+	if err := d.peerAdd(nid, eid, peer.Address.IP, peer.Address.Mask, peer.MacAddress, peer.Vtep, true); err != nil {
+		logrus.Warnf("overlay: failed to program external peer %s of network %s: %v", key, nid, err)
+	}
+
+	if n.nInfo != nil {
+		buf, err := proto.Marshal(externalPeerRecord(peer))
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			n.nInfo.DeleteTableEntry(ovPeerTable, eid)
+		}
+		if err := n.nInfo.AddTableEntry(ovPeerTable, eid, buf); err != nil {
+			logrus.Warnf("overlay: failed to advertise external peer %s of network %s: %v", key, nid, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteExternalPeer unregisters the external peer of the address from
+// the network
+func (d *driver) DeleteExternalPeer(nid string, address net.IP) error {
+	n := d.network(nid)
+	if n == nil {
+		return types.NotFoundErrorf("could not find network with id %s", nid)
+	}
+
+	key := address.String()
+	n.Lock()
+	peer, ok := n.externalPeers[key]
+	delete(n.externalPeers, key)
+	n.Unlock()
+	if !ok {
+		return types.NotFoundErrorf("no external peer %s in network %s", key, nid)
+	}
+
+	if err := n.writeToStore(); err != nil {
+		n.Lock()
+		n.externalPeers[key] = peer
+		n.Unlock()
+		return fmt.Errorf("failed to update data store for network %v: %v", n.id, err)
+	}
+
+	eid := externalPeerID(address)
+	n.setExternalRoutes(address, nil)
+	if err := d.peerDelete(nid, eid, peer.Address.IP, peer.Address.Mask, peer.MacAddress, peer.Vtep, true); err != nil {
+		logrus.Warnf("overlay: failed to remove external peer %s of network %s: %v", key, nid, err)
+	}
+
+	if n.nInfo != nil {
+		if err := n.nInfo.DeleteTableEntry(ovPeerTable, eid); err != nil {
+			logrus.Warnf("overlay: failed to withdraw external peer %s of network %s: %v", key, nid, err)
+		}
+	}
+
+	return nil
+}
+
+// loadExternalPeers adds the external peers of a network loaded from the
+// datastore to the peer db
+func (d *driver) loadExternalPeers(n *network) {
+	for _, peer := range n.externalPeers {
+		n.setExternalRoutes(peer.Address.IP, peer.Prefixes)
+		d.peerDbAdd(n.id, externalPeerID(peer.Address.IP), peer.Address.IP, peer.Address.Mask,
+			peer.MacAddress, peer.Vtep, false)
+	}
+}
+
+// setExternalRoutes sets the external networks routed through the gateway
+// address, none removing them
+func (n *network) setExternalRoutes(gw net.IP, prefixes []*net.IPNet) {
+	n.Lock()
+	defer n.Unlock()
+
+	if len(prefixes) == 0 {
+		delete(n.externalRoutes, gw.String())
+		return
+	}
+	if n.externalRoutes == nil {
+		n.externalRoutes = make(map[string][]*net.IPNet)
+	}
+	n.externalRoutes[gw.String()] = prefixes
+}
+
+// addExternalRoutes routes the external networks of the network through
+// their gateway in the endpoint of the subnet being joined
+func (n *network) addExternalRoutes(s *subnet, jinfo driverapi.JoinInfo) error {
+	n.Lock()
+	routes := make(map[string][]*net.IPNet, len(n.externalRoutes))
+	for gw, prefixes := range n.externalRoutes {
+		routes[gw] = prefixes
+	}
+	n.Unlock()
+
+	for gw, prefixes := range routes {
+		// The gateways of the other subnets are not on link
+		if !s.subnetIP.Contains(net.ParseIP(gw)) {
+			continue
+		}
+		for _, prefix := range prefixes {
+			if err := jinfo.AddStaticRoute(prefix, types.NEXTHOP, net.ParseIP(gw)); err != nil {
+				return fmt.Errorf("could not route external network %s through %s: %v", prefix, gw, err)
+			}
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package overlay
 
 import (
 	"fmt"
+	"io/ioutil"
 	"sync"
 
 	"github.com/Sirupsen/logrus"
@@ -125,3 +126,56 @@ func addFilters(cname, brName string) error {
 func removeFilters(cname, brName string) error {
 	return setFilters(cname, brName, true)
 }
+
+// Policies of the traffic between the subnets of a network
+const (
+	interSubnetRoute = "route"
+	interSubnetDrop  = "drop"
+)
+
+func interSubnetDropRule(from, to string) []string {
+	return []string{"-i", from, "-o", to, "-j", "DROP"}
+}
+
+// setInterSubnetPolicy applies the inter-subnet policy of the network to
+// the traffic between the bridge and the bridges of the other subnets, in
+// the network chain in host mode and in the sandbox otherwise
+func (n *network) setInterSubnetPolicy(brName string) {
+	n.Lock()
+	policy := n.interSubnet
+	var others []string
+	for _, s := range n.subnets {
+		if s.brName != "" && s.brName != brName {
+			others = append(others, s.brName)
+		}
+	}
+	n.Unlock()
+
+	chain := "FORWARD"
+	if hostMode {
+		chain = n.id[:12]
+	}
+
+	n.sandbox().InvokeFunc(func() {
+		if policy != interSubnetDrop {
+			// The host forwarding is left to the daemon
+			if !hostMode {
+				if err := ioutil.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte("1"), 0644); err != nil {
+					logrus.Warnf("overlay: could not enable the routing between the subnets of network %s: %v", n.id, err)
+				}
+			}
+			return
+		}
+
+		for _, other := range others {
+			for _, rule := range [][]string{interSubnetDropRule(brName, other), interSubnetDropRule(other, brName)} {
+				if iptables.Exists(iptables.Filter, chain, rule...) {
+					continue
+				}
+				if err := iptables.RawCombinedOutput(append([]string{"-I", chain}, rule...)...); err != nil {
+					logrus.Warnf("overlay: could not drop the traffic between the subnets of network %s: %v", n.id, err)
+				}
+			}
+		}
+	})
+}
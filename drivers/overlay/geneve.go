@@ -0,0 +1,314 @@
+package overlay
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/osl"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	encapVxlan  = "vxlan"
+	encapGeneve = "geneve"
+	genevePort  = 6081
+	// tc handle of the flooding filter of a geneve subnet, the peer
+	// filters are numbered from the next one
+	floodHandle = 1
+	// Maximum length of the geneve options (the header option length
+	// field counts 4 bytes words on 6 bits)
+	maxGeneveOptsLen = 252
+)
+
+// geneveOption is a TLV carried in the geneve header of the traffic of a
+// network, the data being a multiple of 4 bytes
+type geneveOption struct {
+	class uint16
+	typ   uint8
+	data  []byte
+}
+
+// genevePeer is the vtep of a peer mac of a geneve subnet and the handle
+// of the tc filter sending its traffic to the vtep
+type genevePeer struct {
+	vtep   net.IP
+	handle uint32
+}
+
+// parseGeneveOptions parses a comma separated list of class:type:data
+// options, class, type and data being hexadecimal
+func parseGeneveOptions(value string) ([]geneveOption, error) {
+	var (
+		opts []geneveOption
+		size int
+	)
+	for _, s := range strings.Split(value, ",") {
+		fields := strings.Split(strings.TrimSpace(s), ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid geneve option %q", s)
+		}
+		class, err := strconv.ParseUint(fields[0], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid geneve option class %q: %v", fields[0], err)
+		}
+		typ, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid geneve option type %q: %v", fields[1], err)
+		}
+		data, err := hex.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid geneve option data %q: %v", fields[2], err)
+		}
+		if len(data) == 0 || len(data)%4 != 0 {
+			return nil, fmt.Errorf("geneve option data %q is not a multiple of 4 bytes", fields[2])
+		}
+		size += 4 + len(data)
+		opts = append(opts, geneveOption{class: uint16(class), typ: uint8(typ), data: data})
+	}
+	if size > maxGeneveOptsLen {
+		return nil, fmt.Errorf("geneve options exceed %d bytes", maxGeneveOptsLen)
+	}
+	return opts, nil
+}
+
+func formatGeneveOptions(opts []geneveOption) string {
+	s := make([]string, 0, len(opts))
+	for _, o := range opts {
+		s = append(s, fmt.Sprintf("%04x:%02x:%s", o.class, o.typ, hex.EncodeToString(o.data)))
+	}
+	return strings.Join(s, ",")
+}
+
+// geneveOptionsLen returns the length the options add to the geneve header
+func geneveOptionsLen(opts []geneveOption) int {
+	var l int
+	for _, o := range opts {
+		l += 4 + len(o.data)
+	}
+	return l
+}
+
+// geneveDevName returns the name of the externally controlled geneve
+// device of the node receiving and sending the traffic on the port
+func geneveDevName(port uint16) string {
+	return fmt.Sprintf("gnv-%d", port)
+}
+
+func tc(args ...string) error {
+	if out, err := exec.Command("tc", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("tc %s failed: %v (%s)", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// geneveDevice returns the geneve device of the port, creating it on the
+// first use. The kernel allows a single externally controlled device on
+// a port, which the subnets share through tc tunnel key filters.
+func (d *driver) geneveDevice(port uint16) (string, error) {
+	name := geneveDevName(port)
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.geneveDevs[port] {
+		return name, nil
+	}
+
+	// Remove the device of a previous run of the daemon
+	deleteInterface(name)
+
+	if out, err := exec.Command("ip", "link", "add", name, "type", "geneve",
+		"dstport", strconv.Itoa(int(port)), "external").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("error creating geneve interface: %v (%s)", err, strings.TrimSpace(string(out)))
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return "", fmt.Errorf("could not find link by name %s: %v", name, err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return "", fmt.Errorf("could not bring up geneve interface %s: %v", name, err)
+	}
+	if err := tc("qdisc", "add", "dev", name, "clsact"); err != nil {
+		return "", err
+	}
+
+	if d.geneveDevs == nil {
+		d.geneveDevs = make(map[uint16]bool)
+	}
+	d.geneveDevs[port] = true
+	return name, nil
+}
+
+// initGeneveSubnet connects the bridge of the subnet to the geneve device
+// of the node through a veth pair whose host end redirects the traffic
+// to and from the device, adding and removing the tunnel key of the vni.
+func (n *network) initGeneveSubnet(s *subnet, ifName, brName string) (string, error) {
+	port := n.dstPort()
+	gnvName, err := n.driver.geneveDevice(port)
+	if err != nil {
+		return "", err
+	}
+
+	hostIfName := "gh" + strings.TrimPrefix(ifName, "gn")
+	if err := createGeneveVeth(hostIfName, ifName); err != nil {
+		return "", err
+	}
+
+	vni := strconv.Itoa(int(n.vxlanID(s)))
+	if err := tc("qdisc", "add", "dev", hostIfName, "clsact"); err != nil {
+		deleteInterface(hostIfName)
+		return "", err
+	}
+	if err := tc("filter", "replace", "dev", gnvName, "ingress", "prio", "1", "handle", vni, "protocol", "all",
+		"flower", "enc_key_id", vni, "enc_dst_port", strconv.Itoa(int(port)),
+		"action", "tunnel_key", "unset",
+		"action", "mirred", "egress", "redirect", "dev", hostIfName); err != nil {
+		deleteInterface(hostIfName)
+		return "", err
+	}
+
+	sbox := n.sandbox()
+	if err := sbox.AddInterface(ifName, "veth",
+		sbox.InterfaceOptions().Master(brName)); err != nil {
+		removeGeneveSubnet(n.vxlanID(s), port, hostIfName)
+		return "", fmt.Errorf("geneve interface creation failed for subnet %q: %v", s.subnetIP.String(), err)
+	}
+
+	return hostIfName, nil
+}
+
+func createGeneveVeth(hostIfName, ifName string) error {
+	defer osl.InitOSContext()()
+
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: hostIfName, TxQLen: 0},
+		PeerName:  ifName}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return fmt.Errorf("error creating geneve veth pair: %v", err)
+	}
+	if err := netlink.LinkSetUp(veth); err != nil {
+		deleteInterface(hostIfName)
+		return fmt.Errorf("could not bring up geneve veth %s: %v", hostIfName, err)
+	}
+	return nil
+}
+
+// removeGeneveSubnet removes the veth pair of the subnet and its filter on
+// the geneve device
+func removeGeneveSubnet(vni uint32, port uint16, hostIfName string) {
+	if err := tc("filter", "del", "dev", geneveDevName(port), "ingress", "prio", "1",
+		"handle", strconv.Itoa(int(vni)), "protocol", "all", "flower"); err != nil {
+		logrus.Warnf("Could not remove geneve filter of vni %d: %v", vni, err)
+	}
+	if err := deleteInterface(hostIfName); err != nil {
+		logrus.Warnf("could not cleanup geneve interface: %v", err)
+	}
+}
+
+// externalDevName returns the name of the externally controlled device
+// the traffic of the network is sent through
+func (n *network) externalDevName() string {
+	if n.dataPath == dataPathBPF {
+		return bpfDevName(n.dstPort())
+	}
+	return geneveDevName(n.dstPort())
+}
+
+// tunnelKeyAction returns the tc actions setting the tunnel key sending
+// the traffic of the subnet to the vtep
+func (n *network) tunnelKeyAction(s *subnet, vtep net.IP) []string {
+	args := []string{"action", "tunnel_key", "set",
+		"id", strconv.Itoa(int(s.vni)),
+		"src_ip", n.driver.bindAddress,
+		"dst_ip", vtep.String(),
+		"dst_port", strconv.Itoa(int(n.dstPort()))}
+	if len(n.geneveOpts) > 0 {
+		args = append(args, "geneve_opts", formatGeneveOptions(n.geneveOpts))
+	}
+	if n.tos != 0 {
+		args = append(args, "tos", strconv.Itoa(int(n.tos)))
+	}
+	return args
+}
+
+// geneveAddPeer sends the traffic of the subnet to the peer mac to the
+// vtep of the peer
+func (n *network) geneveAddPeer(s *subnet, peerMac net.HardwareAddr, vtep net.IP) error {
+	n.Lock()
+	if s.genevePeers == nil {
+		s.genevePeers = make(map[string]genevePeer)
+	}
+	p, ok := s.genevePeers[peerMac.String()]
+	if !ok {
+		s.nextHandle++
+		p.handle = floodHandle + s.nextHandle
+	}
+	p.vtep = vtep
+	s.genevePeers[peerMac.String()] = p
+	hostIfName := s.hostIfName
+	n.Unlock()
+
+	args := append([]string{"filter", "replace", "dev", hostIfName, "ingress", "prio", "1",
+		"handle", strconv.Itoa(int(p.handle)), "protocol", "all",
+		"flower", "dst_mac", peerMac.String()}, n.tunnelKeyAction(s, vtep)...)
+	args = append(args, "action", "mirred", "egress", "redirect", "dev", geneveDevName(n.dstPort()))
+	if err := tc(args...); err != nil {
+		return err
+	}
+
+	return n.geneveUpdateFlood(s)
+}
+
+// geneveDeletePeer stops sending the traffic of the subnet to the peer mac
+func (n *network) geneveDeletePeer(s *subnet, peerMac net.HardwareAddr) error {
+	n.Lock()
+	p, ok := s.genevePeers[peerMac.String()]
+	delete(s.genevePeers, peerMac.String())
+	hostIfName := s.hostIfName
+	n.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if err := tc("filter", "del", "dev", hostIfName, "ingress", "prio", "1",
+		"handle", strconv.Itoa(int(p.handle)), "protocol", "all", "flower"); err != nil {
+		return err
+	}
+
+	return n.geneveUpdateFlood(s)
+}
+
+// geneveUpdateFlood sends a copy of the broadcast and multicast traffic of
+// the subnet to every vtep having peers in it
+func (n *network) geneveUpdateFlood(s *subnet) error {
+	n.Lock()
+	vteps := make(map[string]net.IP)
+	for _, p := range s.genevePeers {
+		vteps[p.vtep.String()] = p.vtep
+	}
+	hostIfName := s.hostIfName
+	n.Unlock()
+
+	filter := []string{"dev", hostIfName, "ingress", "prio", "2",
+		"handle", strconv.Itoa(floodHandle), "protocol", "all", "flower"}
+	if len(vteps) == 0 {
+		tc(append([]string{"filter", "del"}, filter...)...)
+		return nil
+	}
+
+	args := append([]string{"filter", "replace"}, filter...)
+	args = append(args, "dst_mac", "01:00:00:00:00:00/01:00:00:00:00:00")
+	for _, vtep := range vteps {
+		args = append(args, n.tunnelKeyAction(s, vtep)...)
+		args = append(args, "action", "mirred", "egress", "mirror", "dev", n.externalDevName())
+	}
+	args = append(args, "action", "drop")
+
+	return tc(args...)
+}
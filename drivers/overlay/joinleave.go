@@ -56,15 +56,20 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 	}
 
 	ep.ifName = containerIfName
-
-	// Set the container interface and its peer MTU to 1450 to allow
-	// for 50 bytes vxlan encap (inner eth header(14) + outer IP(20) +
-	// outer UDP(8) + vxlan header(8))
+	ep.srcName = overlayIfName
+
+	// Set the container interface and its peer MTU to the lowest path
+	// MTU to the peers less the encap overhead, 1450 on an underlay of
+	// 1500 to allow for 50 bytes vxlan encap (inner eth header(14) +
+	// outer IP(20) + outer UDP(8) + vxlan header(8))
+	n.resetPathMTUs()
+	n.updateMTU()
+	mtu := n.vethMTU()
 	veth, err := netlink.LinkByName(overlayIfName)
 	if err != nil {
 		return fmt.Errorf("cound not find link by name %s: %v", overlayIfName, err)
 	}
-	err = netlink.LinkSetMTU(veth, vxlanVethMTU)
+	err = netlink.LinkSetMTU(veth, mtu)
 	if err != nil {
 		return err
 	}
@@ -78,7 +83,7 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 	if err != nil {
 		return fmt.Errorf("could not find link by name %s: %v", containerIfName, err)
 	}
-	err = netlink.LinkSetMTU(veth, vxlanVethMTU)
+	err = netlink.LinkSetMTU(veth, mtu)
 	if err != nil {
 		return err
 	}
@@ -88,7 +93,7 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 	}
 
 	for _, sub := range n.subnets {
-		if sub == s {
+		if sub == s || n.interSubnet == interSubnetDrop {
 			continue
 		}
 		if err := jinfo.AddStaticRoute(sub.subnetIP, types.NEXTHOP, s.gwIP.IP); err != nil {
@@ -96,6 +101,10 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 		}
 	}
 
+	if err := n.addExternalRoutes(s, jinfo); err != nil {
+		log.Errorf("overlay: %v", err)
+	}
+
 	if iNames := jinfo.InterfaceName(); iNames != nil {
 		err = iNames.SetNames(containerIfName, "eth")
 		if err != nil {
@@ -103,14 +112,12 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 		}
 	}
 
+	d.migrateIn(n, ep)
+
 	d.peerDbAdd(nid, eid, ep.addr.IP, ep.addr.Mask, ep.mac,
 		net.ParseIP(d.bindAddress), true)
 
-	buf, err := proto.Marshal(&PeerRecord{
-		EndpointIP:       ep.addr.String(),
-		EndpointMAC:      ep.mac.String(),
-		TunnelEndpointIP: d.bindAddress,
-	})
+	buf, err := proto.Marshal(d.peerRecord(n, ep))
 	if err != nil {
 		return err
 	}
@@ -119,6 +126,8 @@ func (d *driver) Join(nid, eid string, sboxKey string, jinfo driverapi.JoinInfo,
 		log.Errorf("overlay: Failed adding table entry to joininfo: %v", err)
 	}
 
+	n.saveState()
+
 	d.pushLocalEndpointEvent("join", nid, eid)
 
 	return nil
@@ -132,36 +141,53 @@ func (d *driver) EventNotify(etype driverapi.EventType, nid, tableName, key stri
 
 	eid := key
 
+	peer, addr, mac, vtep, err := parsePeerRecord(value)
+	if err != nil {
+		log.Errorf("%v received in event notify", err)
+		return
+	}
+
+	if n := d.network(nid); n != nil && (len(peer.ExternalPrefixes) > 0 || etype == driverapi.Delete) {
+		prefixes, err := parsePrefixes(peer.ExternalPrefixes)
+		if err != nil || etype == driverapi.Delete {
+			prefixes = nil
+		}
+		n.setExternalRoutes(addr.IP, prefixes)
+	}
+
+	if etype == driverapi.Delete {
+		d.peerDelete(nid, eid, addr.IP, addr.Mask, mac, vtep, true)
+		return
+	}
+
+	d.wgPeerUpdate(vtep, peer)
+	d.peerAdd(nid, eid, addr.IP, addr.Mask, mac, vtep, true)
+}
+
+// parsePeerRecord decodes a peer table entry into the peer record and its
+// endpoint address, mac and vtep
+func parsePeerRecord(value []byte) (*PeerRecord, *net.IPNet, net.HardwareAddr, net.IP, error) {
 	var peer PeerRecord
 	if err := proto.Unmarshal(value, &peer); err != nil {
-		log.Errorf("Failed to unmarshal peer record: %v", err)
-		return
+		return nil, nil, nil, nil, fmt.Errorf("Failed to unmarshal peer record: %v", err)
 	}
 
 	addr, err := types.ParseCIDR(peer.EndpointIP)
 	if err != nil {
-		log.Errorf("Invalid peer IP %s received in event notify", peer.EndpointIP)
-		return
+		return nil, nil, nil, nil, fmt.Errorf("Invalid peer IP %s", peer.EndpointIP)
 	}
 
 	mac, err := net.ParseMAC(peer.EndpointMAC)
 	if err != nil {
-		log.Errorf("Invalid mac %s received in event notify", peer.EndpointMAC)
-		return
+		return nil, nil, nil, nil, fmt.Errorf("Invalid mac %s", peer.EndpointMAC)
 	}
 
 	vtep := net.ParseIP(peer.TunnelEndpointIP)
 	if vtep == nil {
-		log.Errorf("Invalid VTEP %s received in event notify", peer.TunnelEndpointIP)
-		return
+		return nil, nil, nil, nil, fmt.Errorf("Invalid VTEP %s", peer.TunnelEndpointIP)
 	}
 
-	if etype == driverapi.Delete {
-		d.peerDelete(nid, eid, addr.IP, addr.Mask, mac, vtep, true)
-		return
-	}
-
-	d.peerAdd(nid, eid, addr.IP, addr.Mask, mac, vtep, true)
+	return &peer, addr, mac, vtep, nil
 }
 
 // Leave method is invoked when a Sandbox detaches from an endpoint.
@@ -0,0 +1,96 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+// An endpoint migrating between nodes keeps its address and mac. The
+// destination node programs the endpoint as local before withdrawing the
+// remote entries pointing to the source node and announces the mac with a
+// gratuitous ARP, the other nodes add the fdb entry to the new vtep before
+// removing the one to the old vtep, and the late withdrawal of the endpoint
+// by the source node is ignored once the peer db points to the new vtep.
+
+var broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// migrateIn removes the remote programming of the endpoint joining the
+// node when it was known as a peer on another node, and announces it from
+// the container interface
+func (d *driver) migrateIn(n *network, ep *endpoint) {
+	e, ok := d.peerDbGet(n.id, ep.addr.IP, ep.mac)
+	if !ok || e.isLocal {
+		return
+	}
+
+	logrus.Debugf("overlay: endpoint %s %s of network %s migrated from %s", ep.addr.IP, ep.mac, n.id, e.vtep)
+	if err := d.peerDelete(n.id, e.eid, ep.addr.IP, ep.addr.Mask, ep.mac, e.vtep, false); err != nil {
+		logrus.Warnf("overlay: failed to remove the old entries of migrated endpoint %s: %v", ep.addr.IP, err)
+	}
+
+	if err := sendGARP(ep.ifName, ep.addr.IP, ep.mac); err != nil {
+		logrus.Warnf("overlay: failed to announce migrated endpoint %s: %v", ep.addr.IP, err)
+	}
+}
+
+// garpFrame returns the gratuitous ARP request announcing the ip at the mac
+func garpFrame(ip net.IP, mac net.HardwareAddr) []byte {
+	b := make([]byte, 42)
+	copy(b[0:6], broadcastMAC)
+	copy(b[6:12], mac)
+	binary.BigEndian.PutUint16(b[12:14], syscall.ETH_P_ARP)
+
+	arp := b[14:]
+	binary.BigEndian.PutUint16(arp[0:2], 1) // ethernet
+	binary.BigEndian.PutUint16(arp[2:4], syscall.ETH_P_IP)
+	arp[4] = 6
+	arp[5] = 4
+	binary.BigEndian.PutUint16(arp[6:8], 1) // request
+	copy(arp[8:14], mac)
+	copy(arp[14:18], ip.To4())
+	copy(arp[24:28], ip.To4())
+	return b
+}
+
+// sendGARP sends the gratuitous ARP of the ip at the mac out of the
+// interface, which is brought up for the time of the send if needed
+func sendGARP(ifName string, ip net.IP, mac net.HardwareAddr) error {
+	if ip.To4() == nil {
+		return nil
+	}
+
+	link, err := netlink.LinkByName(ifName)
+	if err != nil {
+		return fmt.Errorf("could not find link by name %s: %v", ifName, err)
+	}
+	if link.Attrs().Flags&net.FlagUp == 0 {
+		if err := netlink.LinkSetUp(link); err != nil {
+			return fmt.Errorf("could not bring up interface %s: %v", ifName, err)
+		}
+		defer netlink.LinkSetDown(link)
+	}
+
+	proto := htons(syscall.ETH_P_ARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(proto))
+	if err != nil {
+		return fmt.Errorf("could not open packet socket: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	addr := &syscall.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  link.Attrs().Index,
+		Halen:    6,
+	}
+	copy(addr.Addr[:], broadcastMAC)
+	return syscall.Sendto(fd, garpFrame(ip, mac), 0, addr)
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
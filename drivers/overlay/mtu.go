@@ -0,0 +1,249 @@
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/iptables"
+)
+
+const (
+	// Length of the vxlan or geneve encap without options (inner eth
+	// header(14) + outer IP(20) + outer UDP(8) + vxlan header(8))
+	encapOverhead = 50
+	// Length of the WireGuard encap (outer IP(20) + outer UDP(8) +
+	// WireGuard header(16) + authentication tag(16))
+	wgOverhead = 60
+	// Extra length of the outer IPv6 header over the IPv4 one
+	ipv6Overhead = 20
+	// Length of the IPv4 and TCP headers without options
+	tcpIPHeaderLen = 40
+	// Lowest MTU of an IPv4 link
+	minMTU = 576
+)
+
+// parseRouteGet returns the interface and the path MTU cached by the
+// kernel in the output of ip route get, zero when none was learnt
+func parseRouteGet(out string) (string, int) {
+	var (
+		dev string
+		mtu int
+	)
+	fields := strings.Fields(out)
+	for i := 0; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "dev":
+			dev = fields[i+1]
+		case "mtu":
+			if v, err := strconv.Atoi(fields[i+1]); err == nil {
+				mtu = v
+			}
+		}
+	}
+	return dev, mtu
+}
+
+// pathMTU returns the MTU of the path to the address, the one learnt
+// from the ICMP fragmentation needed messages if any or else the one of
+// the outgoing interface
+func pathMTU(dst net.IP) (int, error) {
+	out, err := exec.Command("ip", "route", "get", dst.String()).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the route to %s: %v (%s)", dst, err, strings.TrimSpace(string(out)))
+	}
+
+	dev, mtu := parseRouteGet(string(out))
+	if mtu != 0 {
+		return mtu, nil
+	}
+	iface, err := net.InterfaceByName(dev)
+	if err != nil {
+		return 0, fmt.Errorf("could not find the interface %q of the route to %s: %v", dev, dst, err)
+	}
+	return iface.MTU, nil
+}
+
+// bindMTU returns the MTU of the interface of the bind address
+func bindMTU(bindAddress string) int {
+	ip := net.ParseIP(bindAddress)
+	ifaces, err := net.Interfaces()
+	if ip == nil || err != nil {
+		return 0
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.MTU
+			}
+		}
+	}
+	return 0
+}
+
+// overhead returns the length the encapsulation and encryption of the
+// network add to the container frames
+func (n *network) overhead() int {
+	if n.routed() {
+		return 0
+	}
+	o := encapOverhead + geneveOptionsLen(n.geneveOpts)
+	v6 := n.driver != nil && isIPv6(net.ParseIP(n.driver.bindAddress))
+	if v6 {
+		o += ipv6Overhead
+	}
+	if n.encryption != "" {
+		o += wgOverhead
+		if v6 {
+			o += ipv6Overhead
+		}
+	}
+	return o
+}
+
+// vethMTU returns the MTU of the container interfaces of the network,
+// derived from the lowest path MTU to the peers discovered so far
+func (n *network) vethMTU() int {
+	n.Lock()
+	defer n.Unlock()
+
+	if n.mtu != 0 {
+		return n.mtu
+	}
+	return vxlanVethMTU + encapOverhead - n.overhead()
+}
+
+// updateMTU discovers the path MTU to the vteps of the peers of the
+// network and sets the MTU of the network to the lowest one less the
+// encap overhead. The endpoints already joined keep their MTU, the MSS
+// of their TCP connections is clamped instead.
+func (n *network) updateMTU() {
+	vteps := make(map[string]net.IP)
+	n.driver.peerDbNetworkWalk(n.id, func(pKey *peerKey, pEntry *peerEntry) bool {
+		if !pEntry.isLocal {
+			vteps[pEntry.vtep.String()] = pEntry.vtep
+		}
+		return false
+	})
+
+	lowest := bindMTU(n.driver.bindAddress)
+	for key, vtep := range vteps {
+		n.Lock()
+		mtu, ok := n.pathMTUs[key]
+		n.Unlock()
+		if !ok {
+			var err error
+			if mtu, err = pathMTU(vtep); err != nil {
+				logrus.Warnf("overlay: path MTU discovery to %s failed: %v", vtep, err)
+				continue
+			}
+			n.Lock()
+			if n.pathMTUs == nil {
+				n.pathMTUs = make(map[string]int)
+			}
+			n.pathMTUs[key] = mtu
+			n.Unlock()
+		}
+		if lowest == 0 || mtu < lowest {
+			lowest = mtu
+		}
+	}
+	if lowest == 0 {
+		return
+	}
+
+	mtu := lowest - n.overhead()
+	if mtu < minMTU {
+		mtu = minMTU
+	}
+
+	n.Lock()
+	if mtu != n.mtu {
+		logrus.Debugf("overlay: MTU of network %s set to %d", n.id, mtu)
+	}
+	n.mtu = mtu
+	n.Unlock()
+
+	n.clampMSS()
+}
+
+// mssRule returns the rule clamping the MSS of the TCP connections
+// bridged by the subnet to the MTU of the network
+func mssRule(brName string, mtu int) []string {
+	return []string{"-i", brName, "-p", "tcp", "--tcp-flags", "SYN,RST", "SYN",
+		"-j", "TCPMSS", "--set-mss", strconv.Itoa(mtu - tcpIPHeaderLen)}
+}
+
+// clampMSS replaces the MSS clamping rules of the subnets of the network
+// in its sandbox which do not match the MTU of the network
+func (n *network) clampMSS() {
+	// The routed traffic gets the ICMP fragmentation needed messages
+	sbox := n.sandbox()
+	if sbox == nil || n.routed() {
+		return
+	}
+
+	type clamp struct {
+		brName string
+		old    int
+	}
+	var clamps []clamp
+
+	n.Lock()
+	mtu := n.mtu
+	for _, s := range n.subnets {
+		if s.brName != "" && s.mssMTU != mtu {
+			clamps = append(clamps, clamp{s.brName, s.mssMTU})
+			s.mssMTU = mtu
+		}
+	}
+	n.Unlock()
+
+	if len(clamps) == 0 {
+		return
+	}
+
+	sbox.InvokeFunc(func() {
+		// The rules apply to the bridged traffic only if it goes
+		// through iptables
+		if err := ioutil.WriteFile("/proc/sys/net/bridge/bridge-nf-call-iptables", []byte("1"), 0644); err != nil {
+			logrus.Warnf("overlay: could not enable iptables on the bridged traffic of network %s: %v", n.id, err)
+		}
+
+		for _, c := range clamps {
+			if c.old != 0 {
+				removeMSSRule(c.brName, c.old)
+			}
+			rule := mssRule(c.brName, mtu)
+			if err := iptables.RawCombinedOutput(append([]string{"-t", "mangle", "-I", "FORWARD"}, rule...)...); err != nil {
+				logrus.Warnf("overlay: could not clamp the MSS of network %s: %v", n.id, err)
+			}
+		}
+	})
+}
+
+func removeMSSRule(brName string, mtu int) {
+	rule := mssRule(brName, mtu)
+	if !iptables.Exists(iptables.Mangle, "FORWARD", rule...) {
+		return
+	}
+	if err := iptables.RawCombinedOutput(append([]string{"-t", "mangle", "-D", "FORWARD"}, rule...)...); err != nil {
+		logrus.Warnf("overlay: could not remove the MSS clamping rule of bridge %s: %v", brName, err)
+	}
+}
+
+// resetPathMTUs forgets the path MTUs discovered so far for them to be
+// discovered again, taking the ones learnt by the kernel into account
+func (n *network) resetPathMTUs() {
+	n.Lock()
+	n.pathMTUs = nil
+	n.Unlock()
+}
@@ -0,0 +1,77 @@
+package overlay
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// floodMAC is the address of the all-zeros fdb entries of the vxlan
+// devices, to whose destinations the kernel replicates the broadcast,
+// multicast and unknown unicast traffic
+var floodMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+
+// addFloodPeer replicates the broadcast and multicast traffic of the
+// subnet to the vtep of the peer mac when the vtep hosts no other peer
+// of the subnet
+func (n *network) addFloodPeer(s *subnet, peerMac net.HardwareAddr, vtep net.IP) error {
+	n.Lock()
+	if s.floodPeers == nil {
+		s.floodPeers = make(map[string]string)
+	}
+	old, moved := s.floodPeers[peerMac.String()]
+	s.floodPeers[peerMac.String()] = vtep.String()
+	first := floodVtepPeers(s, vtep.String()) == 1
+	last := moved && old != vtep.String() && floodVtepPeers(s, old) == 0
+	vxlanName := s.vxlanName
+	n.Unlock()
+
+	sbox := n.sandbox()
+	if last {
+		// The peer moved to another node
+		sbox.DeleteNeighbor(net.ParseIP(old), floodMAC)
+	}
+	if !first {
+		return nil
+	}
+
+	if err := sbox.AddNeighbor(vtep, floodMAC, sbox.NeighborOptions().LinkName(vxlanName),
+		sbox.NeighborOptions().Family(syscall.AF_BRIDGE), sbox.NeighborOptions().Append()); err != nil {
+		n.Lock()
+		delete(s.floodPeers, peerMac.String())
+		n.Unlock()
+		return fmt.Errorf("could not add flood entry into the sandbox: %v", err)
+	}
+	return nil
+}
+
+// deleteFloodPeer stops replicating the broadcast and multicast traffic of
+// the subnet to the vtep of the peer mac when it was its last peer
+func (n *network) deleteFloodPeer(s *subnet, peerMac net.HardwareAddr) error {
+	n.Lock()
+	vtep, ok := s.floodPeers[peerMac.String()]
+	delete(s.floodPeers, peerMac.String())
+	last := ok && floodVtepPeers(s, vtep) == 0
+	n.Unlock()
+
+	if !last {
+		return nil
+	}
+
+	if err := n.sandbox().DeleteNeighbor(net.ParseIP(vtep), floodMAC); err != nil {
+		return fmt.Errorf("could not delete flood entry from the sandbox: %v", err)
+	}
+	return nil
+}
+
+// floodVtepPeers returns the number of peers of the subnet on the vtep.
+// To be called while holding the network lock.
+func floodVtepPeers(s *subnet, vtep string) int {
+	var count int
+	for _, v := range s.floodPeers {
+		if v == vtep {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,82 @@
+package overlay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Offload features of the vxlan devices which can be set per network,
+// as named by ethtool
+var offloadFeatures = map[string]bool{
+	"tx":  true,
+	"rx":  true,
+	"gso": true,
+	"gro": true,
+}
+
+// offloadSetting is the state of an offload feature of the vxlan devices
+// of a network
+type offloadSetting struct {
+	feature string
+	on      bool
+}
+
+// parseOffloads parses a csv of feature=on|off offload settings
+func parseOffloads(value string) ([]offloadSetting, error) {
+	var settings []offloadSetting
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 || !offloadFeatures[kv[0]] || seen[kv[0]] {
+			return nil, fmt.Errorf("invalid offload setting %q", item)
+		}
+		var on bool
+		switch kv[1] {
+		case "on":
+			on = true
+		case "off":
+		default:
+			return nil, fmt.Errorf("invalid offload setting %q", item)
+		}
+		seen[kv[0]] = true
+		settings = append(settings, offloadSetting{feature: kv[0], on: on})
+	}
+	return settings, nil
+}
+
+func formatOffloads(settings []offloadSetting) string {
+	var list []string
+	for _, s := range settings {
+		state := "off"
+		if s.on {
+			state = "on"
+		}
+		list = append(list, s.feature+"="+state)
+	}
+	return strings.Join(list, ",")
+}
+
+// offloadArgs returns the ethtool arguments applying the settings to the
+// device
+func offloadArgs(name string, settings []offloadSetting) []string {
+	args := []string{"-K", name}
+	for _, s := range settings {
+		state := "off"
+		if s.on {
+			state = "on"
+		}
+		args = append(args, s.feature, state)
+	}
+	return args
+}
+
+// setOffloads applies the offload settings to the device
+func setOffloads(name string, settings []offloadSetting) error {
+	if len(settings) == 0 {
+		return nil
+	}
+	if _, err := wgCmd("ethtool", offloadArgs(name, settings)...); err != nil {
+		return fmt.Errorf("failed to set the offloads of %s: %v", name, err)
+	}
+	return nil
+}
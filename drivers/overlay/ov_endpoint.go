@@ -17,6 +17,8 @@ type endpoint struct {
 	ifName string
 	mac    net.HardwareAddr
 	addr   *net.IPNet
+	// host name of the end of the veth pair moved to the network sandbox
+	srcName string
 }
 
 func (n *network) endpoint(eid string) *endpoint {
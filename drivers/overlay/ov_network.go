@@ -38,6 +38,16 @@ type subnet struct {
 	initErr   error
 	subnetIP  *net.IPNet
 	gwIP      *net.IPNet
+	// host end of the veth pair connecting the bridge to the geneve
+	// device and the peers reached through it
+	hostIfName  string
+	genevePeers map[string]genevePeer
+	nextHandle  uint32
+	// MTU the MSS clamping rule of the bridge is programmed for
+	mssMTU int
+	// vteps of the peer macs the broadcast and multicast traffic is
+	// replicated to
+	floodPeers map[string]string
 }
 
 type subnetJSON struct {
@@ -46,6 +56,31 @@ type subnetJSON struct {
 	Vni      uint32
 }
 
+// networkJSON is the stored form of the networks with options, the
+// others are stored as the list of their subnets
+type networkJSON struct {
+	Subnets     []*subnetJSON
+	VxlanPort   uint16 `json:",omitempty"`
+	SrcPortLow  uint16 `json:",omitempty"`
+	SrcPortHigh uint16 `json:",omitempty"`
+	Encryption  string `json:",omitempty"`
+	Encap       string `json:",omitempty"`
+	GeneveOpts  string `json:",omitempty"`
+	PinnedVNI   bool   `json:",omitempty"`
+	Multicast   bool   `json:",omitempty"`
+	DataPath    string `json:",omitempty"`
+	Tos         uint8  `json:",omitempty"`
+	// static peers which are not libnetwork nodes
+	ExternalPeers []*externalPeerJSON `json:",omitempty"`
+	// traffic the encryption is restricted to
+	EncryptionPolicy string `json:",omitempty"`
+	// policy of the traffic between the subnets
+	InterSubnet string `json:",omitempty"`
+	// checksums and offloads of the vxlan devices
+	UDPCsum  bool   `json:",omitempty"`
+	Offloads string `json:",omitempty"`
+}
+
 type network struct {
 	id        string
 	dbIndex   uint64
@@ -58,6 +93,51 @@ type network struct {
 	initEpoch int
 	initErr   error
 	subnets   []*subnet
+	// UDP destination port and source port range of the VXLAN
+	// traffic, the kernel defaults when zero
+	vxlanDstPort uint16
+	srcPortLow   uint16
+	srcPortHigh  uint16
+	// data plane encryption of the network, none when empty, and the
+	// traffic it is restricted to, all when empty
+	encryption    string
+	encryptPolicy []encryptSelector
+	// encapsulation of the network, vxlan when empty, and the options
+	// carried in the geneve header
+	encap      string
+	geneveOpts []geneveOption
+	// MTU of the container interfaces, derived from the path MTU to the
+	// vteps of the peers
+	mtu      int
+	pathMTUs map[string]int
+	// whether the VNIs of the subnets were pinned by the user and are
+	// reserved in the datastore
+	pinnedVNI bool
+	// whether the broadcast and multicast traffic is replicated to the
+	// vteps of the peers
+	multicast bool
+	// data path forwarding the traffic of the network, the kernel bridge
+	// and vxlan devices when empty
+	dataPath string
+	// policy of the traffic between the subnets, routed in the sandbox
+	// when empty
+	interSubnet string
+	// TOS of the outer header of the encapsulated traffic, the DSCP
+	// shifted by two or tosInherit to copy the one of the inner header
+	tos uint8
+	// whether the outer UDP header of the encapsulated traffic carries
+	// a checksum, and the offload settings of the vxlan devices
+	udpCsum  bool
+	offloads []offloadSetting
+	// static peers which are not libnetwork nodes by address, and the
+	// external networks routed through the peers by gateway address
+	externalPeers  map[string]*driverapi.ExternalPeer
+	externalRoutes map[string][]*net.IPNet
+	// peers of the static peers file programmed in the network, by address
+	staticPeers map[string]*staticPeer
+	// network info of the network controller, to update the records
+	// of the local endpoints
+	nInfo driverapi.NetworkInfo
 	sync.Mutex
 }
 
@@ -106,6 +186,128 @@ func (d *driver) CreateNetwork(id string, option map[string]interface{}, nInfo d
 				vnis = append(vnis, uint32(vni))
 			}
 		}
+		if val, ok := optMap[netlabel.OverlayVNI]; ok {
+			if len(vnis) != 0 {
+				return types.BadRequestErrorf("vni and vxlan id list options are mutually exclusive")
+			}
+			var err error
+			if vnis, err = parseVNIs(val); err != nil {
+				return types.BadRequestErrorf("%v", err)
+			}
+			if len(vnis) != len(ipV4Data) {
+				return types.BadRequestErrorf("%d vnis passed for %d subnets", len(vnis), len(ipV4Data))
+			}
+			n.pinnedVNI = true
+		}
+		if val, ok := optMap[netlabel.OverlayVxlanPort]; ok {
+			port, err := strconv.ParseUint(val, 10, 16)
+			if err != nil || port == 0 {
+				return types.BadRequestErrorf("invalid vxlan port value %q passed", val)
+			}
+			n.vxlanDstPort = uint16(port)
+		}
+		if val, ok := optMap[netlabel.OverlaySourcePortRange]; ok {
+			var err error
+			if n.srcPortLow, n.srcPortHigh, err = parsePortRange(val); err != nil {
+				return types.BadRequestErrorf("%v", err)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayEncryption]; ok {
+			if val != encryptionWireGuard {
+				return types.BadRequestErrorf("unsupported overlay encryption %q", val)
+			}
+			n.encryption = val
+		}
+		if val, ok := optMap[netlabel.OverlayEncryptionPolicy]; ok {
+			var err error
+			if n.encryptPolicy, err = parseEncryptionPolicy(val); err != nil {
+				return types.BadRequestErrorf("%v", err)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayEncapsulation]; ok {
+			switch val {
+			case encapVxlan:
+			case encapGeneve, encapNone:
+				n.encap = val
+			default:
+				return types.BadRequestErrorf("unsupported overlay encapsulation %q", val)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayGeneveOptions]; ok {
+			if n.encap != encapGeneve {
+				return types.BadRequestErrorf("geneve options require the geneve encapsulation")
+			}
+			var err error
+			if n.geneveOpts, err = parseGeneveOptions(val); err != nil {
+				return types.BadRequestErrorf("%v", err)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayMulticast]; ok {
+			var err error
+			if n.multicast, err = strconv.ParseBool(val); err != nil {
+				return types.BadRequestErrorf("invalid multicast value %q passed", val)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayDataPath]; ok {
+			switch val {
+			case dataPathKernel:
+			case dataPathBPF:
+				if _, err := d.bpfObject(); err != nil {
+					return types.BadRequestErrorf("%v", err)
+				}
+				n.dataPath = val
+			default:
+				return types.BadRequestErrorf("unsupported overlay data path %q", val)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayInterSubnet]; ok {
+			switch val {
+			case interSubnetRoute:
+			case interSubnetDrop:
+				n.interSubnet = val
+			default:
+				return types.BadRequestErrorf("unsupported inter-subnet policy %q", val)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayDSCP]; ok {
+			var err error
+			if n.tos, err = parseDSCP(val); err != nil {
+				return types.BadRequestErrorf("%v", err)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayUDPChecksum]; ok {
+			var err error
+			if n.udpCsum, err = strconv.ParseBool(val); err != nil {
+				return types.BadRequestErrorf("invalid udp checksum value %q passed", val)
+			}
+		}
+		if val, ok := optMap[netlabel.OverlayOffloads]; ok {
+			var err error
+			if n.offloads, err = parseOffloads(val); err != nil {
+				return types.BadRequestErrorf("%v", err)
+			}
+		}
+		if (n.udpCsum || len(n.offloads) != 0) && (n.encap != "" || n.dataPath != "") {
+			return types.BadRequestErrorf("checksum and offload settings require the vxlan devices of the kernel data path")
+		}
+		if n.dataPath == dataPathBPF && n.encap != "" {
+			return types.BadRequestErrorf("the eBPF data path supports the vxlan encapsulation only")
+		}
+		if len(n.encryptPolicy) != 0 && (n.encryption == "" || n.encap != "" || n.dataPath != "") {
+			return types.BadRequestErrorf("encryption policies require the encryption of a vxlan network")
+		}
+		if n.routed() && n.encryption != "" {
+			return types.BadRequestErrorf("routed overlay networks cannot be encrypted")
+		}
+		if n.routed() && n.tos != 0 {
+			return types.BadRequestErrorf("routed overlay networks have no encapsulation to mark")
+		}
+		if n.routed() && n.interSubnet != "" {
+			return types.BadRequestErrorf("routed overlay networks route between their subnets through the host")
+		}
+		if n.routed() && n.multicast {
+			return types.BadRequestErrorf("routed overlay networks cannot replicate multicast traffic")
+		}
 	}
 
 	// If we are getting vnis from libnetwork, either we get for
@@ -128,7 +330,16 @@ func (d *driver) CreateNetwork(id string, option map[string]interface{}, nInfo d
 		n.subnets = append(n.subnets, s)
 	}
 
+	if n.pinnedVNI {
+		if err := d.reserveVNIs(n.id, vnis); err != nil {
+			return err
+		}
+	}
+
 	if err := n.writeToStore(); err != nil {
+		if n.pinnedVNI {
+			d.releaseVNIs(vnis)
+		}
 		return fmt.Errorf("failed to update data store for network %v: %v", n.id, err)
 	}
 
@@ -136,9 +347,21 @@ func (d *driver) CreateNetwork(id string, option map[string]interface{}, nInfo d
 		if err := nInfo.TableEventRegister(ovPeerTable); err != nil {
 			return err
 		}
+		n.nInfo = nInfo
+		d.resyncOnce.Do(func() { go d.peerResyncLoop() })
 	}
 
 	d.addNetwork(n)
+
+	if d.staticPeersFile() != "" {
+		d.staticOnce.Do(func() {
+			if err := d.loadStaticPeers(); err != nil {
+				logrus.Warnf("overlay: failed to load the static peers: %v", err)
+			}
+			go d.staticPeersLoop()
+		})
+		d.applyStaticPeers(n)
+	}
 	return nil
 }
 
@@ -200,6 +423,7 @@ func (n *network) leaveSandbox() {
 	defer n.Unlock()
 	n.joinCnt--
 	if n.joinCnt != 0 {
+		n.writeState()
 		return
 	}
 
@@ -223,13 +447,47 @@ func (n *network) destroySandbox() {
 			}
 		}
 
+		if n.routed() {
+			n.driver.deletePeerRoutes(n.id)
+		}
+
 		for _, s := range n.subnets {
-			if hostMode {
+			if hostMode || n.routed() {
 				if err := removeFilters(n.id[:12], s.brName); err != nil {
 					logrus.Warnf("Could not remove overlay filters: %v", err)
 				}
 			}
 
+			port := n.vxlanDstPort
+			if port == 0 {
+				port = n.defaultPort()
+			}
+			if hostMode && s.mssMTU != 0 {
+				removeMSSRule(s.brName, s.mssMTU)
+			}
+			s.mssMTU = 0
+			s.floodPeers = nil
+
+			if n.encryption != "" && s.vxlanName != "" {
+				if err := programEncryption(s.vni, port, n.encryptPolicy,
+					isIPv6(net.ParseIP(n.driver.bindAddress)), false); err != nil {
+					logrus.Warnf("Could not remove overlay encryption rules: %v", err)
+				}
+			}
+
+			if s.hostIfName != "" && n.dataPath == dataPathBPF {
+				removeBPFSubnet(s.vni, s.hostIfName)
+				s.hostIfName = ""
+				s.genevePeers = nil
+				continue
+			}
+			if s.hostIfName != "" {
+				removeGeneveSubnet(s.vni, port, s.hostIfName)
+				s.hostIfName = ""
+				s.genevePeers = nil
+				continue
+			}
+
 			if s.vxlanName != "" {
 				err := deleteInterface(s.vxlanName)
 				if err != nil {
@@ -238,7 +496,7 @@ func (n *network) destroySandbox() {
 			}
 		}
 
-		if hostMode {
+		if hostMode || n.routed() {
 			if err := removeNetworkChain(n.id[:12]); err != nil {
 				logrus.Warnf("could not remove network chain: %v", err)
 			}
@@ -246,6 +504,7 @@ func (n *network) destroySandbox() {
 
 		n.sbox.Destroy()
 		n.sbox = nil
+		removeNetworkState(n.id)
 	}
 }
 
@@ -255,7 +514,7 @@ func setHostMode() {
 		return
 	}
 
-	err := createVxlan("testvxlan", 1)
+	err := createVxlan("testvxlan", 1, vxlanPort, 0, 0, 0, false, nil)
 	if err != nil {
 		logrus.Errorf("Failed to create testvxlan interface: %v", err)
 		return
@@ -286,6 +545,25 @@ func setHostMode() {
 	}
 }
 
+// dstPort returns the UDP destination port of the VXLAN or geneve traffic
+func (n *network) dstPort() uint16 {
+	n.Lock()
+	defer n.Unlock()
+
+	if n.vxlanDstPort != 0 {
+		return n.vxlanDstPort
+	}
+	return n.defaultPort()
+}
+
+// defaultPort returns the IANA port of the encapsulation of the network
+func (n *network) defaultPort() uint16 {
+	if n.encap == encapGeneve {
+		return genevePort
+	}
+	return vxlanPort
+}
+
 func (n *network) generateVxlanName(s *subnet) string {
 	id := n.id
 	if len(n.id) > 5 {
@@ -326,7 +604,7 @@ func (n *network) initSubnetSandbox(s *subnet) error {
 	brName := n.generateBridgeName(s)
 	vxlanName := n.generateVxlanName(s)
 
-	if hostMode {
+	if hostMode || n.routed() {
 		// Try to delete stale bridge interface if it exists
 		deleteInterface(brName)
 		// Try to delete the vxlan interface by vni if already present
@@ -346,27 +624,69 @@ func (n *network) initSubnetSandbox(s *subnet) error {
 		return fmt.Errorf("bridge creation in sandbox failed for subnet %q: %v", s.subnetIP.String(), err)
 	}
 
-	err := createVxlan(vxlanName, n.vxlanID(s))
-	if err != nil {
-		return err
-	}
+	var hostIfName string
+	if n.routed() {
+		vxlanName = ""
+	} else if n.encap == encapGeneve {
+		vxlanName = "gn" + strings.TrimPrefix(vxlanName, "vx")
+		var err error
+		if hostIfName, err = n.initGeneveSubnet(s, vxlanName, brName); err != nil {
+			return err
+		}
+	} else if n.dataPath == dataPathBPF {
+		vxlanName = "gn" + strings.TrimPrefix(vxlanName, "vx")
+		var err error
+		if hostIfName, err = n.initBPFSubnet(s, vxlanName, brName); err != nil {
+			return err
+		}
+	} else {
+		err := createVxlan(vxlanName, n.vxlanID(s), n.dstPort(), n.srcPortLow, n.srcPortHigh, n.tos,
+			n.udpCsum, net.ParseIP(n.driver.bindAddress))
+		if err != nil {
+			return err
+		}
+
+		if err := setOffloads(vxlanName, n.offloads); err != nil {
+			deleteInterface(vxlanName)
+			return err
+		}
 
-	if err := sbox.AddInterface(vxlanName, "vxlan",
-		sbox.InterfaceOptions().Master(brName)); err != nil {
-		return fmt.Errorf("vxlan interface creation failed for subnet %q: %v", s.subnetIP.String(), err)
+		if err := sbox.AddInterface(vxlanName, "vxlan",
+			sbox.InterfaceOptions().Master(brName)); err != nil {
+			return fmt.Errorf("vxlan interface creation failed for subnet %q: %v", s.subnetIP.String(), err)
+		}
 	}
 
-	if hostMode {
+	if hostMode || n.routed() {
 		if err := addFilters(n.id[:12], brName); err != nil {
 			return err
 		}
 	}
 
+	if n.routed() {
+		if err := setupRoutedSubnet(n.id[:12], brName, s.subnetIP); err != nil {
+			return err
+		}
+	}
+
+	if n.encryption != "" {
+		if err := n.setupEncryption(s); err != nil {
+			return err
+		}
+	}
+
 	n.Lock()
 	s.vxlanName = vxlanName
 	s.brName = brName
+	s.hostIfName = hostIfName
 	n.Unlock()
 
+	if !n.routed() && len(n.subnets) > 1 {
+		n.setInterSubnetPolicy(brName)
+	}
+
+	n.saveState()
+
 	return nil
 }
 
@@ -397,25 +717,38 @@ func (n *network) initSandbox() error {
 
 	hostModeOnce.Do(setHostMode)
 
-	if hostMode {
+	// The bridges of the routed networks live in the host namespace
+	if hostMode || n.routed() {
 		if err := addNetworkChain(n.id[:12]); err != nil {
 			return err
 		}
 	}
 
-	// If there are any stale sandboxes related to this network
-	// from previous daemon life clean it up here
-	n.cleanupStaleSandboxes()
+	// The sandbox kept by the previous daemon life is adopted as is,
+	// others related to this network are stale and cleaned up here
+	var err error
+	sbox, restored := n.restoreSandbox()
+	if !restored {
+		n.cleanupStaleSandboxes()
 
-	sbox, err := osl.NewSandbox(
-		osl.GenerateKey(fmt.Sprintf("%d-", n.initEpoch)+n.id), !hostMode)
-	if err != nil {
-		return fmt.Errorf("could not create network sandbox: %v", err)
+		sbox, err = osl.NewSandbox(
+			osl.GenerateKey(fmt.Sprintf("%d-", n.initEpoch)+n.id), !hostMode && !n.routed())
+		if err != nil {
+			return fmt.Errorf("could not create network sandbox: %v", err)
+		}
 	}
 
 	n.setSandbox(sbox)
 
 	n.driver.peerDbUpdateSandbox(n.id)
+	if restored {
+		n.saveState()
+	}
+
+	// The peers of the routed networks are resolved by proxy arp
+	if n.routed() {
+		return nil
+	}
 
 	var nlSock *nl.NetlinkSocket
 	sbox.InvokeFunc(func() {
@@ -495,6 +828,7 @@ func (d *driver) network(nid string) *network {
 			n.endpoints = endpointTable{}
 			n.once = &sync.Once{}
 			networks[nid] = n
+			d.loadExternalPeers(n)
 		}
 	}
 
@@ -560,7 +894,32 @@ func (n *network) Value() []byte {
 		netJSON = append(netJSON, sj)
 	}
 
-	b, err := json.Marshal(netJSON)
+	var (
+		b   []byte
+		err error
+	)
+	if n.vxlanDstPort == 0 && n.srcPortLow == 0 && n.encryption == "" && n.encap == "" && !n.pinnedVNI && !n.multicast && n.dataPath == "" && len(n.externalPeers) == 0 && n.tos == 0 && n.interSubnet == "" && !n.udpCsum && len(n.offloads) == 0 {
+		b, err = json.Marshal(netJSON)
+	} else {
+		b, err = json.Marshal(&networkJSON{
+			Subnets:          netJSON,
+			VxlanPort:        n.vxlanDstPort,
+			SrcPortLow:       n.srcPortLow,
+			SrcPortHigh:      n.srcPortHigh,
+			Encryption:       n.encryption,
+			EncryptionPolicy: formatEncryptionPolicy(n.encryptPolicy),
+			InterSubnet:      n.interSubnet,
+			Encap:            n.encap,
+			GeneveOpts:       formatGeneveOptions(n.geneveOpts),
+			PinnedVNI:        n.pinnedVNI,
+			Multicast:        n.multicast,
+			DataPath:         n.dataPath,
+			Tos:              n.tos,
+			ExternalPeers:    n.externalPeersJSON(),
+			UDPCsum:          n.udpCsum,
+			Offloads:         formatOffloads(n.offloads),
+		})
+	}
 
 	if err != nil {
 		return []byte{}
@@ -568,6 +927,19 @@ func (n *network) Value() []byte {
 	return b
 }
 
+func (n *network) externalPeersJSON() []*externalPeerJSON {
+	var list []*externalPeerJSON
+	for _, peer := range n.externalPeers {
+		list = append(list, &externalPeerJSON{
+			Address:  peer.Address.String(),
+			Mac:      peer.MacAddress.String(),
+			Vtep:     peer.Vtep.String(),
+			Prefixes: formatPrefixes(peer.Prefixes),
+		})
+	}
+	return list
+}
+
 func (n *network) Index() uint64 {
 	return n.dbIndex
 }
@@ -589,8 +961,58 @@ func (n *network) SetValue(value []byte) error {
 	var newNet bool
 	netJSON := []*subnetJSON{}
 
-	err := json.Unmarshal(value, &netJSON)
-	if err != nil {
+	if len(value) > 0 && value[0] == '{' {
+		nj := &networkJSON{}
+		if err := json.Unmarshal(value, nj); err != nil {
+			return err
+		}
+		netJSON = nj.Subnets
+		n.vxlanDstPort = nj.VxlanPort
+		n.srcPortLow = nj.SrcPortLow
+		n.srcPortHigh = nj.SrcPortHigh
+		n.encryption = nj.Encryption
+		n.encryptPolicy = nil
+		if nj.EncryptionPolicy != "" {
+			policy, err := parseEncryptionPolicy(nj.EncryptionPolicy)
+			if err != nil {
+				return err
+			}
+			n.encryptPolicy = policy
+		}
+		n.encap = nj.Encap
+		n.pinnedVNI = nj.PinnedVNI
+		n.multicast = nj.Multicast
+		n.dataPath = nj.DataPath
+		n.tos = nj.Tos
+		n.interSubnet = nj.InterSubnet
+		n.udpCsum = nj.UDPCsum
+		n.offloads = nil
+		if nj.Offloads != "" {
+			offloads, err := parseOffloads(nj.Offloads)
+			if err != nil {
+				return err
+			}
+			n.offloads = offloads
+		}
+		n.externalPeers = nil
+		for _, pj := range nj.ExternalPeers {
+			peer, err := pj.peer()
+			if err != nil {
+				return err
+			}
+			if n.externalPeers == nil {
+				n.externalPeers = make(map[string]*driverapi.ExternalPeer)
+			}
+			n.externalPeers[peer.Address.IP.String()] = peer
+		}
+		if nj.GeneveOpts != "" {
+			opts, err := parseGeneveOptions(nj.GeneveOpts)
+			if err != nil {
+				return err
+			}
+			n.geneveOpts = opts
+		}
+	} else if err := json.Unmarshal(value, &netJSON); err != nil {
 		return err
 	}
 
@@ -653,13 +1075,17 @@ func (n *network) releaseVxlanID() error {
 		}
 	}
 
+	var pinned []uint32
 	for _, s := range n.subnets {
-		if n.driver.vxlanIdm != nil {
+		if n.pinnedVNI {
+			pinned = append(pinned, n.vxlanID(s))
+		} else if n.driver.vxlanIdm != nil {
 			n.driver.vxlanIdm.Release(uint64(n.vxlanID(s)))
 		}
 
 		n.setVxlanID(s, 0)
 	}
+	n.driver.releaseVNIs(pinned)
 
 	return nil
 }
@@ -2,6 +2,9 @@ package overlay
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/docker/libnetwork/netutils"
 	"github.com/docker/libnetwork/osl"
@@ -46,17 +49,26 @@ func createVethPair() (string, string, error) {
 	return name1, name2, nil
 }
 
-func createVxlan(name string, vni uint32) error {
+func createVxlan(name string, vni uint32, port, srcPortLow, srcPortHigh uint16, tos uint8, udpCsum bool, local net.IP) error {
 	defer osl.InitOSContext()()
 
 	vxlan := &netlink.Vxlan{
 		LinkAttrs: netlink.LinkAttrs{Name: name},
 		VxlanId:   int(vni),
 		Learning:  true,
-		Port:      vxlanPort,
+		Port:      int(port),
+		PortLow:   int(srcPortLow),
+		PortHigh:  int(srcPortHigh),
 		Proxy:     true,
 		L3miss:    true,
 		L2miss:    true,
+		TOS:       int(tos),
+		UDPCSum:   udpCsum,
+	}
+	// The IPv6 local address makes the device send and receive over
+	// IPv6, the IPv4 devices are left unbound
+	if isIPv6(local) {
+		vxlan.SrcAddr = local
 	}
 
 	if err := netlink.LinkAdd(vxlan); err != nil {
@@ -66,6 +78,45 @@ func createVxlan(name string, vni uint32) error {
 	return nil
 }
 
+// parsePortRange parses a low-high range of UDP ports
+func parsePortRange(value string) (uint16, uint16, error) {
+	bounds := strings.Split(value, "-")
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid port range %q", value)
+	}
+
+	low, err := strconv.ParseUint(bounds[0], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q: %v", value, err)
+	}
+	high, err := strconv.ParseUint(bounds[1], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q: %v", value, err)
+	}
+	if low == 0 || low > high {
+		return 0, 0, fmt.Errorf("invalid port range %q", value)
+	}
+
+	return uint16(low), uint16(high), nil
+}
+
+// TOS of the tunnels copying the one of the inner header
+const tosInherit = 1
+
+// parseDSCP parses the DSCP of the outer header of the encapsulated
+// traffic, either a value from 0 to 63 or "inherit" to copy the one of the
+// inner header, into the TOS of the tunnel
+func parseDSCP(value string) (uint8, error) {
+	if value == "inherit" {
+		return tosInherit, nil
+	}
+	dscp, err := strconv.ParseUint(value, 0, 8)
+	if err != nil || dscp > 63 {
+		return 0, fmt.Errorf("invalid dscp %q", value)
+	}
+	return uint8(dscp) << 2, nil
+}
+
 func deleteInterface(name string) error {
 	defer osl.InitOSContext()()
 
@@ -0,0 +1,139 @@
+package overlay
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/types"
+)
+
+// Highest VNI of the 24 bits VXLAN network identifier
+const maxVNI = 1<<24 - 1
+
+// vniReservation records in the datastore the network a VNI chosen by the
+// user is pinned to, for the pinned VNIs not to collide across networks
+type vniReservation struct {
+	vni      uint32
+	nid      string
+	dbIndex  uint64
+	dbExists bool
+}
+
+func (r *vniReservation) Key() []string {
+	return []string{"overlay", "vni", strconv.FormatUint(uint64(r.vni), 10)}
+}
+
+func (r *vniReservation) KeyPrefix() []string {
+	return []string{"overlay", "vni"}
+}
+
+func (r *vniReservation) Value() []byte {
+	return []byte(r.nid)
+}
+
+func (r *vniReservation) SetValue(value []byte) error {
+	r.nid = string(value)
+	return nil
+}
+
+func (r *vniReservation) Index() uint64 {
+	return r.dbIndex
+}
+
+func (r *vniReservation) SetIndex(index uint64) {
+	r.dbIndex = index
+	r.dbExists = true
+}
+
+func (r *vniReservation) Exists() bool {
+	return r.dbExists
+}
+
+func (r *vniReservation) Skip() bool {
+	return false
+}
+
+func (r *vniReservation) New() datastore.KVObject {
+	return &vniReservation{}
+}
+
+func (r *vniReservation) CopyTo(o datastore.KVObject) error {
+	dst := o.(*vniReservation)
+	*dst = *r
+	return nil
+}
+
+func (r *vniReservation) DataScope() string {
+	return datastore.GlobalScope
+}
+
+// parseVNIs parses a csv list of VNIs pinned by the user
+func parseVNIs(val string) ([]uint32, error) {
+	var vnis []uint32
+	seen := make(map[uint64]bool)
+	for _, s := range strings.Split(val, ",") {
+		vni, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err != nil || vni == 0 || vni > maxVNI {
+			return nil, fmt.Errorf("invalid vni value %q passed", s)
+		}
+		if seen[vni] {
+			return nil, fmt.Errorf("vni %d passed more than once", vni)
+		}
+		seen[vni] = true
+		vnis = append(vnis, uint32(vni))
+	}
+	return vnis, nil
+}
+
+// reserveVNIs reserves the VNIs pinned by the user for the network in the
+// datastore, failing if another network uses any of them
+func (d *driver) reserveVNIs(nid string, vnis []uint32) error {
+	if d.store == nil {
+		return nil
+	}
+
+	for i, vni := range vnis {
+		r := &vniReservation{vni: vni, nid: nid}
+		if err := d.store.PutObjectAtomic(r); err != nil {
+			d.releaseVNIs(vnis[:i])
+			if err == datastore.ErrKeyModified {
+				owner := &vniReservation{vni: vni}
+				if err := d.store.GetObject(datastore.Key(owner.Key()...), owner); err == nil {
+					return types.ForbiddenErrorf("vni %d is in use by network %s", vni, owner.nid)
+				}
+				return types.ForbiddenErrorf("vni %d is in use", vni)
+			}
+			return fmt.Errorf("failed to reserve vni %d: %v", vni, err)
+		}
+
+		// The VNIs of the range the driver allocates from are also
+		// taken out of it
+		if d.vxlanIdm != nil && vni >= vxlanIDStart && vni <= vxlanIDEnd {
+			if err := d.vxlanIdm.GetSpecificID(uint64(vni)); err != nil {
+				d.releaseVNIs(vnis[:i])
+				d.store.DeleteObject(r)
+				return types.ForbiddenErrorf("vni %d is in use: %v", vni, err)
+			}
+		}
+	}
+	return nil
+}
+
+// releaseVNIs releases the reservations of the VNIs pinned by the user
+func (d *driver) releaseVNIs(vnis []uint32) {
+	if d.store == nil {
+		return
+	}
+
+	for _, vni := range vnis {
+		if d.vxlanIdm != nil && vni >= vxlanIDStart && vni <= vxlanIDEnd {
+			d.vxlanIdm.Release(uint64(vni))
+		}
+		if err := d.store.DeleteObject(&vniReservation{vni: vni}); err != nil && err != datastore.ErrKeyNotFound {
+			logrus.Warnf("Failed to release the reservation of vni %d: %v", vni, err)
+		}
+	}
+}
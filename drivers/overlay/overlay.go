@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/datastore"
@@ -43,6 +44,16 @@ type driver struct {
 	vxlanIdm     *idm.Idm
 	once         sync.Once
 	joinOnce     sync.Once
+	wgOnce       sync.Once
+	resyncOnce   sync.Once
+	wg           *wgState
+	geneveDevs   map[uint16]bool
+	bpfDevs      map[uint16]bool
+	staticOnce   sync.Once
+	staticPeers  []*staticPeer
+	staticMtime  time.Time
+	probeOnce    sync.Once
+	probe        *probeState
 	sync.Mutex
 }
 
@@ -141,6 +152,25 @@ func validateSelf(node string) error {
 	return fmt.Errorf("Multi-Host overlay networking requires cluster-advertise(%s) to be configured with a local ip-address that is reachable within the cluster", advIP.String())
 }
 
+// underlayIPv6 returns whether the tunnels of the node run over IPv6
+func (d *driver) underlayIPv6() bool {
+	d.Lock()
+	defer d.Unlock()
+	return isIPv6(net.ParseIP(d.bindAddress))
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
+}
+
+// hostPrefix returns the host prefix of the address
+func hostPrefix(ip net.IP) string {
+	if isIPv6(ip) {
+		return ip.String() + "/128"
+	}
+	return ip.String() + "/32"
+}
+
 func (d *driver) nodeJoin(node string, self bool) {
 	if self && !d.isSerfAlive() {
 		if err := validateSelf(node); err != nil {
@@ -150,6 +180,8 @@ func (d *driver) nodeJoin(node string, self bool) {
 		d.bindAddress = node
 		d.Unlock()
 
+		d.probeOnce.Do(d.startProber)
+
 		// If there is no cluster store there is no need to start serf.
 		if d.store != nil {
 			err := d.serfInit()
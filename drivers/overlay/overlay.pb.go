@@ -48,6 +48,20 @@ type PeerRecord struct {
 	// which this container is running and can be reached by
 	// building a tunnel to that host IP.
 	TunnelEndpointIP string `protobuf:"bytes,3,opt,name=tunnel_endpoint_ip,json=tunnelEndpointIp,proto3" json:"tunnel_endpoint_ip,omitempty"`
+	// Tunnel Public Key is the WireGuard public key of the host
+	// in which this container is running, used to encrypt the
+	// traffic of the encrypted networks to that host.
+	TunnelPublicKey string `protobuf:"bytes,4,opt,name=tunnel_public_key,json=tunnelPublicKey,proto3" json:"tunnel_public_key,omitempty"`
+	// Tunnel Next Public Key is the WireGuard public key the host
+	// accepts the traffic with during a key rotation, before it
+	// starts sending with it.
+	TunnelNextPublicKey string `protobuf:"bytes,5,opt,name=tunnel_next_public_key,json=tunnelNextPublicKey,proto3" json:"tunnel_next_public_key,omitempty"`
+	// Tunnel Key Generation is the generation of the WireGuard key
+	// the host sends the traffic with.
+	TunnelKeyGeneration uint32 `protobuf:"varint,6,opt,name=tunnel_key_generation,json=tunnelKeyGeneration,proto3" json:"tunnel_key_generation,omitempty"`
+	// External Prefixes are the networks routed through the
+	// endpoint, for the external peers acting as gateways.
+	ExternalPrefixes []string `protobuf:"bytes,7,rep,name=external_prefixes,json=externalPrefixes" json:"external_prefixes,omitempty"`
 }
 
 func (m *PeerRecord) Reset()                    { *m = PeerRecord{} }
@@ -61,11 +75,15 @@ func (this *PeerRecord) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 11)
 	s = append(s, "&overlay.PeerRecord{")
 	s = append(s, "EndpointIP: "+fmt.Sprintf("%#v", this.EndpointIP)+",\n")
 	s = append(s, "EndpointMAC: "+fmt.Sprintf("%#v", this.EndpointMAC)+",\n")
 	s = append(s, "TunnelEndpointIP: "+fmt.Sprintf("%#v", this.TunnelEndpointIP)+",\n")
+	s = append(s, "TunnelPublicKey: "+fmt.Sprintf("%#v", this.TunnelPublicKey)+",\n")
+	s = append(s, "TunnelNextPublicKey: "+fmt.Sprintf("%#v", this.TunnelNextPublicKey)+",\n")
+	s = append(s, "TunnelKeyGeneration: "+fmt.Sprintf("%#v", this.TunnelKeyGeneration)+",\n")
+	s = append(s, "ExternalPrefixes: "+fmt.Sprintf("%#v", this.ExternalPrefixes)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -127,6 +145,38 @@ func (m *PeerRecord) MarshalTo(data []byte) (int, error) {
 		i = encodeVarintOverlay(data, i, uint64(len(m.TunnelEndpointIP)))
 		i += copy(data[i:], m.TunnelEndpointIP)
 	}
+	if len(m.TunnelPublicKey) > 0 {
+		data[i] = 0x22
+		i++
+		i = encodeVarintOverlay(data, i, uint64(len(m.TunnelPublicKey)))
+		i += copy(data[i:], m.TunnelPublicKey)
+	}
+	if len(m.TunnelNextPublicKey) > 0 {
+		data[i] = 0x2a
+		i++
+		i = encodeVarintOverlay(data, i, uint64(len(m.TunnelNextPublicKey)))
+		i += copy(data[i:], m.TunnelNextPublicKey)
+	}
+	if m.TunnelKeyGeneration != 0 {
+		data[i] = 0x30
+		i++
+		i = encodeVarintOverlay(data, i, uint64(m.TunnelKeyGeneration))
+	}
+	if len(m.ExternalPrefixes) > 0 {
+		for _, s := range m.ExternalPrefixes {
+			data[i] = 0x3a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				data[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			data[i] = uint8(l)
+			i++
+			i += copy(data[i:], s)
+		}
+	}
 	return i, nil
 }
 
@@ -172,6 +222,23 @@ func (m *PeerRecord) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovOverlay(uint64(l))
 	}
+	l = len(m.TunnelPublicKey)
+	if l > 0 {
+		n += 1 + l + sovOverlay(uint64(l))
+	}
+	l = len(m.TunnelNextPublicKey)
+	if l > 0 {
+		n += 1 + l + sovOverlay(uint64(l))
+	}
+	if m.TunnelKeyGeneration != 0 {
+		n += 1 + sovOverlay(uint64(m.TunnelKeyGeneration))
+	}
+	if len(m.ExternalPrefixes) > 0 {
+		for _, s := range m.ExternalPrefixes {
+			l = len(s)
+			n += 1 + l + sovOverlay(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -196,6 +263,10 @@ func (this *PeerRecord) String() string {
 		`EndpointIP:` + fmt.Sprintf("%v", this.EndpointIP) + `,`,
 		`EndpointMAC:` + fmt.Sprintf("%v", this.EndpointMAC) + `,`,
 		`TunnelEndpointIP:` + fmt.Sprintf("%v", this.TunnelEndpointIP) + `,`,
+		`TunnelPublicKey:` + fmt.Sprintf("%v", this.TunnelPublicKey) + `,`,
+		`TunnelNextPublicKey:` + fmt.Sprintf("%v", this.TunnelNextPublicKey) + `,`,
+		`TunnelKeyGeneration:` + fmt.Sprintf("%v", this.TunnelKeyGeneration) + `,`,
+		`ExternalPrefixes:` + fmt.Sprintf("%v", this.ExternalPrefixes) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -324,6 +395,112 @@ func (m *PeerRecord) Unmarshal(data []byte) error {
 			}
 			m.TunnelEndpointIP = string(data[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TunnelPublicKey", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowOverlay
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthOverlay
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TunnelPublicKey = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TunnelNextPublicKey", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowOverlay
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthOverlay
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TunnelNextPublicKey = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TunnelKeyGeneration", wireType)
+			}
+			m.TunnelKeyGeneration = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowOverlay
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.TunnelKeyGeneration |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExternalPrefixes", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowOverlay
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthOverlay
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ExternalPrefixes = append(m.ExternalPrefixes, string(data[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipOverlay(data[iNdEx:])
@@ -451,18 +628,25 @@ var (
 )
 
 var fileDescriptorOverlay = []byte{
-	// 195 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0xcd, 0x2f, 0x4b, 0x2d,
-	0xca, 0x49, 0xac, 0xd4, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x87, 0x72, 0xa5, 0x44, 0xd2,
-	0xf3, 0xd3, 0xf3, 0xc1, 0x62, 0xfa, 0x20, 0x16, 0x44, 0x5a, 0x69, 0x2b, 0x23, 0x17, 0x57, 0x40,
-	0x6a, 0x6a, 0x51, 0x50, 0x6a, 0x72, 0x7e, 0x51, 0x8a, 0x90, 0x3e, 0x17, 0x77, 0x6a, 0x5e, 0x4a,
-	0x41, 0x7e, 0x66, 0x5e, 0x49, 0x7c, 0x66, 0x81, 0x04, 0xa3, 0x02, 0xa3, 0x06, 0xa7, 0x13, 0xdf,
-	0xa3, 0x7b, 0xf2, 0x5c, 0xae, 0x50, 0x61, 0xcf, 0x80, 0x20, 0x2e, 0x98, 0x12, 0xcf, 0x02, 0x21,
-	0x23, 0x2e, 0x1e, 0xb8, 0x86, 0xdc, 0xc4, 0x64, 0x09, 0x26, 0xb0, 0x0e, 0x7e, 0xa0, 0x0e, 0x6e,
-	0x98, 0x0e, 0x5f, 0x47, 0xe7, 0x20, 0xb8, 0xa9, 0xbe, 0x89, 0xc9, 0x42, 0x4e, 0x5c, 0x42, 0x25,
-	0xa5, 0x79, 0x79, 0xa9, 0x39, 0xf1, 0xc8, 0x76, 0x31, 0x83, 0x75, 0x8a, 0x00, 0x75, 0x0a, 0x84,
-	0x80, 0x65, 0x91, 0x6c, 0x14, 0x28, 0x41, 0x15, 0x29, 0x70, 0x92, 0xb8, 0xf1, 0x50, 0x8e, 0xe1,
-	0xc3, 0x43, 0x39, 0xc6, 0x86, 0x47, 0x72, 0x8c, 0x27, 0x80, 0xf8, 0x02, 0x10, 0x3f, 0x00, 0xe2,
-	0x24, 0x36, 0xb0, 0xc7, 0x8c, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0xbf, 0xd7, 0x7d, 0x7d, 0x08,
-	0x01, 0x00, 0x00,
+	// 320 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x65, 0x91, 0xcf, 0x4a, 0xc3, 0x40,
+	0x10, 0xc6, 0x8d, 0xd1, 0x96, 0x6e, 0xad, 0x49, 0xb7, 0x55, 0x83, 0x87, 0x58, 0x3c, 0x15, 0x04,
+	0x0b, 0xfa, 0x00, 0x62, 0x44, 0xa4, 0x68, 0x25, 0x04, 0xef, 0x21, 0x4d, 0xc7, 0x10, 0xac, 0xbb,
+	0x61, 0xdd, 0x4a, 0x73, 0xf3, 0x65, 0x7c, 0x17, 0x8f, 0x1e, 0x3d, 0x89, 0xed, 0x13, 0xf8, 0x08,
+	0x8e, 0x9b, 0x3f, 0xa6, 0x78, 0x18, 0xd8, 0xfd, 0xbe, 0xf9, 0x7e, 0xc3, 0xec, 0x92, 0x16, 0x7f,
+	0x06, 0x31, 0x0d, 0xd2, 0xe3, 0x44, 0x70, 0xc9, 0x69, 0x3d, 0xbf, 0xee, 0x77, 0x23, 0x1e, 0x71,
+	0xa5, 0x0d, 0x7e, 0x4f, 0x99, 0x7d, 0xf8, 0xaa, 0x13, 0xe2, 0x02, 0x08, 0x0f, 0x42, 0x2e, 0x26,
+	0x74, 0x40, 0x9a, 0xc0, 0x26, 0x09, 0x8f, 0x99, 0xf4, 0xe3, 0xc4, 0xd2, 0x7a, 0x5a, 0xbf, 0xe1,
+	0x6c, 0x2f, 0x3f, 0x0f, 0xc8, 0x65, 0x2e, 0x0f, 0x5d, 0x8f, 0x14, 0x2d, 0xc3, 0x84, 0x9e, 0x90,
+	0xad, 0x32, 0xf0, 0x18, 0x84, 0xd6, 0xba, 0x4a, 0x18, 0x98, 0x68, 0x16, 0x89, 0xd1, 0xf9, 0x85,
+	0x57, 0x52, 0x47, 0x41, 0x48, 0x1d, 0x42, 0xe5, 0x8c, 0x31, 0x98, 0xfa, 0xd5, 0x59, 0xba, 0x4a,
+	0x76, 0x31, 0x69, 0xde, 0x29, 0xb7, 0x32, 0xd1, 0x94, 0xab, 0x4a, 0x42, 0xcf, 0x48, 0x3b, 0x67,
+	0x24, 0xb3, 0xf1, 0x34, 0x0e, 0xfd, 0x07, 0x48, 0xad, 0x0d, 0x85, 0xe8, 0x20, 0xc2, 0xc8, 0x10,
+	0xae, 0xf2, 0xae, 0x21, 0xf5, 0x0c, 0xb9, 0x2a, 0xd0, 0x1b, 0xb2, 0x9b, 0x03, 0x18, 0xcc, 0x65,
+	0x95, 0xb2, 0xa9, 0x28, 0x7b, 0x48, 0xe9, 0x64, 0x94, 0x5b, 0x6c, 0xf8, 0x23, 0x75, 0xe4, 0x7f,
+	0x11, 0x9f, 0x61, 0x27, 0xa7, 0x21, 0xc1, 0x8f, 0x80, 0x81, 0x08, 0x64, 0xcc, 0x99, 0x55, 0x43,
+	0x58, 0xab, 0xc8, 0x60, 0xe7, 0x55, 0x69, 0xd1, 0x23, 0xd2, 0x46, 0x06, 0x08, 0x16, 0xe0, 0x12,
+	0x02, 0xee, 0xe3, 0x39, 0x3c, 0x59, 0xf5, 0x9e, 0xde, 0x6f, 0x78, 0x66, 0x61, 0xb8, 0xb9, 0xee,
+	0x58, 0x1f, 0x0b, 0x7b, 0xed, 0x7b, 0x61, 0x6b, 0x2f, 0x4b, 0x5b, 0x7b, 0xc3, 0x7a, 0xc7, 0xfa,
+	0xc2, 0x1a, 0xd7, 0xd4, 0x47, 0x9e, 0xfe, 0x00, 0x65, 0x2a, 0x73, 0xcd, 0xf8, 0x01, 0x00, 0x00,
 }
@@ -1,10 +1,15 @@
 package overlay
 
 import (
+	"io/ioutil"
 	"net"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
 	"github.com/docker/libkv/store/consul"
 	"github.com/docker/libnetwork/datastore"
 	"github.com/docker/libnetwork/discoverapi"
@@ -15,6 +20,7 @@ import (
 
 func init() {
 	consul.Register()
+	boltdb.Register()
 }
 
 type driverTester struct {
@@ -130,3 +136,704 @@ func TestOverlayType(t *testing.T) {
 			dt.d.Type())
 	}
 }
+
+func TestParsePortRange(t *testing.T) {
+	low, high, err := parsePortRange("49152-49407")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low != 49152 || high != 49407 {
+		t.Fatalf("Unexpected port range %d-%d", low, high)
+	}
+
+	for _, value := range []string{"", "49152", "0-100", "200-100", "1-65536"} {
+		if _, _, err := parsePortRange(value); err == nil {
+			t.Fatalf("Failed to detect invalid port range %q", value)
+		}
+	}
+}
+
+func TestNetworkValuePorts(t *testing.T) {
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/24")
+	gw := &net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: ipnet.Mask}
+
+	n := &network{id: "dummy", subnets: []*subnet{{subnetIP: ipnet, gwIP: gw, vni: 300}}}
+	legacy := n.Value()
+	if legacy[0] != '[' {
+		t.Fatalf("Expected the legacy subnet list for a network without options: %s", legacy)
+	}
+
+	n.vxlanDstPort, n.srcPortLow, n.srcPortHigh, n.encryption = 8472, 49152, 49407, encryptionWireGuard
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil {
+		t.Fatal(err)
+	}
+	if nn.vxlanDstPort != 8472 || nn.srcPortLow != 49152 || nn.srcPortHigh != 49407 {
+		t.Fatalf("Unexpected ports after restore: %d %d-%d", nn.vxlanDstPort, nn.srcPortLow, nn.srcPortHigh)
+	}
+	if nn.encryption != encryptionWireGuard {
+		t.Fatalf("Unexpected encryption after restore: %q", nn.encryption)
+	}
+	if len(nn.subnets) != 1 || nn.subnets[0].vni != 300 {
+		t.Fatalf("Unexpected subnets after restore: %v", nn.subnets)
+	}
+
+	nn = &network{id: "dummy"}
+	if err := nn.SetValue(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if nn.dstPort() != vxlanPort || len(nn.subnets) != 1 {
+		t.Fatalf("Unexpected legacy network restore")
+	}
+}
+
+func TestPinnedVNI(t *testing.T) {
+	if vnis, err := parseVNIs("300, 16777215"); err != nil || len(vnis) != 2 || vnis[1] != maxVNI {
+		t.Fatalf("Unexpected vnis: %v %v", vnis, err)
+	}
+	for _, val := range []string{"0", "16777216", "300,300", "abc"} {
+		if _, err := parseVNIs(val); err == nil {
+			t.Fatalf("Failed to detect invalid vnis %q", val)
+		}
+	}
+
+	tmp, err := ioutil.TempFile("", "libnetwork-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	ds, err := datastore.NewDataStore(datastore.LocalScope, &datastore.ScopeCfg{
+		Client: datastore.ScopeClientCfg{
+			Provider: "boltdb",
+			Address:  tmp.Name(),
+			Config:   &store.Config{Bucket: "libnetwork", ConnectionTimeout: 3 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &driver{store: ds}
+	if err := d.initializeVxlanIdm(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.reserveVNIs("net1", []uint32{300, 5000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.reserveVNIs("net2", []uint32{400, 5000}); err == nil {
+		t.Fatalf("Failed to detect the vni collision")
+	}
+	// The reservations of the failed network were rolled back
+	if err := d.reserveVNIs("net2", []uint32{400}); err != nil {
+		t.Fatal(err)
+	}
+	if vni, err := d.vxlanIdm.GetID(); err != nil || vni != vxlanIDStart {
+		t.Fatalf("Unexpected allocated vni: %d %v", vni, err)
+	}
+	if err := d.reserveVNIs("net3", []uint32{vxlanIDStart}); err == nil {
+		t.Fatalf("Failed to detect the collision with an allocated vni")
+	}
+
+	d.releaseVNIs([]uint32{300, 5000})
+	if err := d.reserveVNIs("net3", []uint32{300, 5000}); err != nil {
+		t.Fatal(err)
+	}
+
+	n := &network{id: "dummy", pinnedVNI: true}
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil || !nn.pinnedVNI {
+		t.Fatalf("Failed to restore the pinned vni network: %v", err)
+	}
+}
+
+func TestEncryptionRules(t *testing.T) {
+	if m := vniMatch(300, false); m != "0>>22&0x3C@12&0xFFFFFF00=76800" {
+		t.Fatalf("Unexpected vni match: %s", m)
+	}
+
+	rules := encryptionRules(300, vxlanPort, nil, false)
+	if r := strings.Join(rules[0], " "); r != "-t mangle OUTPUT -p udp --dport 4789 -m u32 --u32 0>>22&0x3C@12&0xFFFFFF00=76800 -j MARK --set-mark 53444" {
+		t.Fatalf("Unexpected mark rule: %s", r)
+	}
+	if r := strings.Join(rules[1], " "); r != "-t filter INPUT ! -i wg-overlay+ -p udp --dport 4789 -m u32 --u32 0>>22&0x3C@12&0xFFFFFF00=76800 -j DROP" {
+		t.Fatalf("Unexpected drop rule: %s", r)
+	}
+
+	rec := &PeerRecord{EndpointIP: "10.0.0.2/24", TunnelEndpointIP: "192.168.1.2", TunnelPublicKey: "key",
+		TunnelNextPublicKey: "next", TunnelKeyGeneration: 3}
+	buf, err := rec.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var peer PeerRecord
+	if err := peer.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	if peer.TunnelPublicKey != "key" || peer.TunnelEndpointIP != "192.168.1.2" ||
+		peer.TunnelNextPublicKey != "next" || peer.TunnelKeyGeneration != 3 {
+		t.Fatalf("Unexpected peer record after unmarshal: %s", peer.String())
+	}
+}
+
+func TestKeyRotationStep(t *testing.T) {
+	now := time.Now()
+	wg := &wgState{
+		gen:  4,
+		devs: map[uint32]*wgDevice{4: {gen: 4}, 5: {gen: 5}},
+		peers: map[string]*wgPeer{
+			"192.168.1.2": {gen: 4, sendGen: 5, routed: true},
+			"192.168.1.3": {gen: 5, sendGen: 5, routed: true},
+		},
+	}
+
+	// All the peers accept the next generation
+	if !wg.step(now) || wg.gen != 5 {
+		t.Fatalf("Failed to switch to the next generation: %d", wg.gen)
+	}
+
+	// A peer still sends with the previous generation
+	if wg.step(now) {
+		t.Fatalf("Unexpected change of the keys")
+	}
+	if _, ok := wg.devs[4]; !ok {
+		t.Fatalf("Previous generation removed while in use")
+	}
+
+	p := &wgPeer{keys: make(map[uint32]string), seen: make(map[uint32]time.Time)}
+	p.setKey(1, "a")
+	p.setKey(4, "b")
+	if _, ok := p.keys[1]; ok || p.keys[4] != "b" {
+		t.Fatalf("Unexpected peer keys: %v", p.keys)
+	}
+}
+
+func TestParseGeneveOptions(t *testing.T) {
+	opts, err := parseGeneveOptions("0102:80:00112233, ffff:1:0011223344556677")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := formatGeneveOptions(opts); s != "0102:80:00112233,ffff:01:0011223344556677" {
+		t.Fatalf("Unexpected geneve options: %s", s)
+	}
+	if l := geneveOptionsLen(opts); l != 20 {
+		t.Fatalf("Unexpected geneve options length: %d", l)
+	}
+
+	for _, value := range []string{"", "0102:80", "10000:80:00112233", "0102:100:00112233", "0102:80:001122", "0102:80:zz"} {
+		if _, err := parseGeneveOptions(value); err == nil {
+			t.Fatalf("Failed to detect invalid geneve options %q", value)
+		}
+	}
+
+	n := &network{id: "dummy", encap: encapGeneve, geneveOpts: opts}
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil {
+		t.Fatal(err)
+	}
+	if nn.encap != encapGeneve || len(nn.geneveOpts) != 2 || nn.dstPort() != genevePort {
+		t.Fatalf("Unexpected geneve network after restore: %q %v %d", nn.encap, nn.geneveOpts, nn.dstPort())
+	}
+}
+
+func TestPathMTU(t *testing.T) {
+	dev, mtu := parseRouteGet("192.168.1.2 via 10.0.0.1 dev eth0 src 10.0.0.5 uid 0 \n    cache expires 580sec mtu 1400 \n")
+	if dev != "eth0" || mtu != 1400 {
+		t.Fatalf("Unexpected route: dev %s mtu %d", dev, mtu)
+	}
+	if _, mtu := parseRouteGet("192.168.1.2 dev eth0 src 192.168.1.5 uid 0 \n    cache \n"); mtu != 0 {
+		t.Fatalf("Unexpected path mtu %d of a route without one", mtu)
+	}
+
+	n := &network{id: "dummy", encryption: encryptionWireGuard}
+	if mtu := n.vethMTU(); mtu != 1390 {
+		t.Fatalf("Unexpected default MTU of an encrypted network: %d", mtu)
+	}
+	if r := strings.Join(mssRule("ov-000100-dummy", 1400), " "); r != "-i ov-000100-dummy -p tcp --tcp-flags SYN,RST SYN -j TCPMSS --set-mss 1360" {
+		t.Fatalf("Unexpected mss rule: %s", r)
+	}
+}
+
+func TestRoutedNetwork(t *testing.T) {
+	n := &network{id: "dummy", encap: encapNone}
+	if !n.routed() || n.overhead() != 0 {
+		t.Fatalf("Unexpected encap overhead of a routed network: %d", n.overhead())
+	}
+
+	r := peerRoute(net.ParseIP("10.0.0.2"), net.ParseIP("192.168.1.2"))
+	if r.Dst.String() != "10.0.0.2/32" || !r.Gw.Equal(net.ParseIP("192.168.1.2")) {
+		t.Fatalf("Unexpected peer route: %s", r)
+	}
+}
+
+func TestMulticastNetwork(t *testing.T) {
+	n := &network{id: "dummy", multicast: true}
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil || !nn.multicast {
+		t.Fatalf("Failed to restore the multicast network: %v", err)
+	}
+
+	s := &subnet{floodPeers: map[string]string{
+		"02:42:0a:00:00:02": "192.168.1.2",
+		"02:42:0a:00:00:03": "192.168.1.2",
+		"02:42:0a:00:00:04": "192.168.1.3",
+	}}
+	if c := floodVtepPeers(s, "192.168.1.2"); c != 2 {
+		t.Fatalf("Unexpected number of peers on the vtep: %d", c)
+	}
+	if c := floodVtepPeers(s, "192.168.1.4"); c != 0 {
+		t.Fatalf("Unexpected number of peers on the vtep: %d", c)
+	}
+}
+
+type tableInfo map[string][]byte
+
+func (ti tableInfo) TableEventRegister(tableName string) error {
+	return nil
+}
+
+func (ti tableInfo) UpdateTableEntry(tableName, key string, value []byte) error {
+	ti[key] = value
+	return nil
+}
+
+func (ti tableInfo) AddTableEntry(tableName, key string, value []byte) error {
+	ti[key] = value
+	return nil
+}
+
+func (ti tableInfo) DeleteTableEntry(tableName, key string) error {
+	delete(ti, key)
+	return nil
+}
+
+func (ti tableInfo) WalkTable(tableName string, fn func(string, []byte) bool) error {
+	for key, value := range ti {
+		if fn(key, value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestPeerResync(t *testing.T) {
+	d := &driver{
+		networks:    networkTable{},
+		peerDb:      peerNetworkMap{mp: map[string]*peerMap{}},
+		bindAddress: "192.168.1.1",
+	}
+	ti := tableInfo{}
+	n := &network{id: "net1", driver: d, nInfo: ti}
+	d.networks[n.id] = n
+
+	mac1, _ := net.ParseMAC("02:42:0a:00:00:02")
+	mac2, _ := net.ParseMAC("02:42:0a:00:00:03")
+	mask := net.CIDRMask(24, 32)
+
+	// A peer whose delete event was missed
+	d.peerDbAdd(n.id, "ep1", net.ParseIP("10.0.0.2"), mask, mac1, net.ParseIP("192.168.1.2"), false)
+	// A peer whose create event was missed, and a local one
+	for key, rec := range map[string]*PeerRecord{
+		"ep2": {EndpointIP: "10.0.0.3/24", EndpointMAC: mac2.String(), TunnelEndpointIP: "192.168.1.3"},
+		"ep3": {EndpointIP: "10.0.0.4/24", EndpointMAC: "02:42:0a:00:00:04", TunnelEndpointIP: d.bindAddress},
+	} {
+		buf, err := rec.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ti[key] = buf
+	}
+
+	d.peerResync(n)
+
+	if _, _, _, err := d.peerDbSearch(n.id, net.ParseIP("10.0.0.2")); err == nil {
+		t.Fatalf("Stale peer not removed")
+	}
+	if _, _, vtep, err := d.peerDbSearch(n.id, net.ParseIP("10.0.0.3")); err != nil || !vtep.Equal(net.ParseIP("192.168.1.3")) {
+		t.Fatalf("Missed peer not added: %v", err)
+	}
+	if _, _, _, err := d.peerDbSearch(n.id, net.ParseIP("10.0.0.4")); err == nil {
+		t.Fatalf("Local peer added from the table")
+	}
+}
+
+func TestBPFMapEntries(t *testing.T) {
+	mac, _ := net.ParseMAC("02:42:0a:00:00:02")
+	if k := bpfPeerKey(7, mac); len(k) != 12 || k[4] != 0x02 || k[9] != 0x02 {
+		t.Fatalf("Unexpected peer key: %v", k)
+	}
+	v := bpfPeerValue(300, net.ParseIP("192.168.1.2"))
+	if len(v) != 8 || !net.IP(v[4:]).Equal(net.ParseIP("192.168.1.2")) {
+		t.Fatalf("Unexpected peer value: %v", v)
+	}
+	if h := strings.Join(hexBytes([]byte{0x0a, 0xff}), " "); h != "0a ff" {
+		t.Fatalf("Unexpected hex bytes: %s", h)
+	}
+
+	n := &network{id: "dummy", dataPath: dataPathBPF}
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil || nn.dataPath != dataPathBPF {
+		t.Fatalf("Failed to restore the eBPF data path network: %v", err)
+	}
+	if nn.externalDevName() != "vxb-4789" {
+		t.Fatalf("Unexpected external device: %s", nn.externalDevName())
+	}
+}
+
+func TestExternalPeer(t *testing.T) {
+	d := &driver{
+		networks:    networkTable{},
+		peerDb:      peerNetworkMap{mp: map[string]*peerMap{}},
+		bindAddress: "192.168.1.1",
+	}
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/24")
+	ti := tableInfo{}
+	n := &network{id: "net1", driver: d, nInfo: ti, subnets: []*subnet{{subnetIP: ipnet, vni: 300}}}
+	d.networks[n.id] = n
+
+	mac, _ := net.ParseMAC("02:42:0a:00:00:fe")
+	_, prefix, _ := net.ParseCIDR("172.30.0.0/16")
+	peer := &driverapi.ExternalPeer{
+		Address:    &net.IPNet{IP: net.ParseIP("10.0.0.254"), Mask: ipnet.Mask},
+		MacAddress: mac,
+		Vtep:       net.ParseIP("192.168.5.10"),
+		Prefixes:   []*net.IPNet{prefix},
+	}
+	if err := d.AddExternalPeer(n.id, peer); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, vtep, err := d.peerDbSearch(n.id, peer.Address.IP); err != nil || !vtep.Equal(peer.Vtep) {
+		t.Fatalf("External peer not added to the peer db: %v", err)
+	}
+	var rec PeerRecord
+	if err := rec.Unmarshal(ti[externalPeerID(peer.Address.IP)]); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.ExternalPrefixes) != 1 || rec.ExternalPrefixes[0] != "172.30.0.0/16" {
+		t.Fatalf("Unexpected advertised external peer: %s", rec.String())
+	}
+	if len(n.externalRoutes["10.0.0.254"]) != 1 {
+		t.Fatalf("Unexpected external routes: %v", n.externalRoutes)
+	}
+
+	nn := &network{id: "net1"}
+	if err := nn.SetValue(n.Value()); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := nn.externalPeers["10.0.0.254"]; !ok || !p.Vtep.Equal(peer.Vtep) || len(p.Prefixes) != 1 {
+		t.Fatalf("Failed to restore the external peers: %v", nn.externalPeers)
+	}
+
+	peer.Address = &net.IPNet{IP: net.ParseIP("10.1.0.254"), Mask: ipnet.Mask}
+	if err := d.AddExternalPeer(n.id, peer); err == nil {
+		t.Fatalf("Failed to detect an external peer out of the network")
+	}
+
+	if err := d.DeleteExternalPeer(n.id, net.ParseIP("10.0.0.254")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ti[externalPeerID(net.ParseIP("10.0.0.254"))]; ok || len(n.externalRoutes) != 0 {
+		t.Fatalf("External peer not withdrawn")
+	}
+}
+
+func TestParseDSCP(t *testing.T) {
+	if tos, err := parseDSCP("46"); err != nil || tos != 46<<2 {
+		t.Fatalf("Unexpected tos: %d %v", tos, err)
+	}
+	if tos, err := parseDSCP("inherit"); err != nil || tos != tosInherit {
+		t.Fatalf("Unexpected tos: %d %v", tos, err)
+	}
+	for _, value := range []string{"64", "-1", "ef"} {
+		if _, err := parseDSCP(value); err == nil {
+			t.Fatalf("Failed to detect invalid dscp %q", value)
+		}
+	}
+
+	n := &network{id: "dummy", tos: 46 << 2}
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil || nn.tos != 46<<2 {
+		t.Fatalf("Failed to restore the network dscp: %v", err)
+	}
+}
+
+func TestStaticPeers(t *testing.T) {
+	f, err := ioutil.TempFile("", "static-peers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	peers := `[
+		{"VNI": 300, "Address": "10.0.0.2/24", "Vtep": "192.168.1.1"},
+		{"VNI": 300, "Address": "10.0.0.3/24", "Vtep": "192.168.1.2"},
+		{"VNI": 301, "Address": "10.0.0.4/24", "Vtep": "192.168.1.3"}
+	]`
+	if err := ioutil.WriteFile(f.Name(), []byte(peers), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &driver{
+		networks:    networkTable{},
+		peerDb:      peerNetworkMap{mp: map[string]*peerMap{}},
+		bindAddress: "192.168.1.1",
+		config:      map[string]interface{}{netlabel.OverlayStaticPeers: f.Name()},
+	}
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/24")
+	n := &network{id: "net1", driver: d, subnets: []*subnet{{subnetIP: ipnet, vni: 300}}}
+	d.networks[n.id] = n
+
+	if err := d.loadStaticPeers(); err != nil {
+		t.Fatal(err)
+	}
+	d.applyStaticPeers(n)
+
+	if _, _, vtep, err := d.peerDbSearch(n.id, net.ParseIP("10.0.0.3")); err != nil || !vtep.Equal(net.ParseIP("192.168.1.2")) {
+		t.Fatalf("Static peer not added to the peer db: %v", err)
+	}
+	if len(n.staticPeers) != 1 {
+		t.Fatalf("Unexpected static peers of the network: %v", n.staticPeers)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d.staticMtime = time.Time{}
+	if err := d.loadStaticPeers(); err != nil {
+		t.Fatal(err)
+	}
+	d.applyStaticPeers(n)
+
+	if _, _, _, err := d.peerDbSearch(n.id, net.ParseIP("10.0.0.3")); err == nil {
+		t.Fatalf("Static peer not removed from the peer db")
+	}
+
+	if _, err := parseStaticPeers([]byte(`[{"VNI": 300, "Address": "10.0.0.3/24", "Vtep": "bogus"}]`)); err == nil {
+		t.Fatalf("Failed to detect an invalid static peer")
+	}
+}
+
+func TestMigratedPeer(t *testing.T) {
+	d := &driver{
+		networks: networkTable{},
+		peerDb:   peerNetworkMap{mp: map[string]*peerMap{}},
+	}
+	ip := net.ParseIP("10.0.0.3")
+	mask := net.CIDRMask(24, 32)
+	mac, _ := net.ParseMAC("02:42:0a:00:00:03")
+	oldVtep, newVtep := net.ParseIP("192.168.1.2"), net.ParseIP("192.168.1.3")
+
+	if err := d.peerAdd("net1", "ep1", ip, mask, mac, oldVtep, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.peerAdd("net1", "ep1", ip, mask, mac, newVtep, true); err != nil {
+		t.Fatal(err)
+	}
+	// The source node withdraws the endpoint after the destination
+	// node advertised it
+	if err := d.peerDelete("net1", "ep1", ip, mask, mac, oldVtep, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, vtep, err := d.peerDbSearch("net1", ip); err != nil || !vtep.Equal(newVtep) {
+		t.Fatalf("Migrated peer removed by the late withdrawal: %v", err)
+	}
+
+	if err := d.peerDelete("net1", "ep1", ip, mask, mac, newVtep, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := d.peerDbSearch("net1", ip); err == nil {
+		t.Fatalf("Peer not removed from the peer db")
+	}
+}
+
+func TestGARPFrame(t *testing.T) {
+	mac, _ := net.ParseMAC("02:42:0a:00:00:03")
+	b := garpFrame(net.ParseIP("10.0.0.3"), mac)
+	if len(b) != 42 || b[12] != 0x08 || b[13] != 0x06 || b[21] != 1 {
+		t.Fatalf("Unexpected gratuitous ARP frame: %x", b)
+	}
+	if !net.IP(b[28:32]).Equal(net.ParseIP("10.0.0.3")) || !net.IP(b[38:42]).Equal(net.ParseIP("10.0.0.3")) {
+		t.Fatalf("Unexpected gratuitous ARP addresses: %x", b)
+	}
+	if net.HardwareAddr(b[22:28]).String() != mac.String() {
+		t.Fatalf("Unexpected gratuitous ARP sender: %x", b)
+	}
+}
+
+func TestEncryptionPolicy(t *testing.T) {
+	policy, err := parseEncryptionPolicy("10.0.1.0/24, 10.0.0.5-10.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy) != 4 || policy[2].src.String() != "10.0.0.5/32" || policy[3].dst.String() != "10.0.0.5/32" {
+		t.Fatalf("Unexpected encryption policy: %v", policy)
+	}
+	if p := formatEncryptionPolicy(policy); p != "10.0.1.0/24,10.0.0.5/32-10.0.2.0/24" {
+		t.Fatalf("Unexpected formatted encryption policy: %s", p)
+	}
+
+	rules := encryptionRules(300, vxlanPort, policy, false)
+	if len(rules) != 8 {
+		t.Fatalf("Unexpected number of encryption rules: %d", len(rules))
+	}
+	if m := rules[0][10]; m != "0>>22&0x3C@12&0xFFFFFF00=76800&&0>>22&0x3C@26&0xFFFF=0x800&&0>>22&0x3C@42&0xffffff00=0x0a000100" {
+		t.Fatalf("Unexpected source prefix match: %s", m)
+	}
+	if m := rules[6][10]; !strings.HasSuffix(m, "@42&0xffffff00=0x0a000200&&0>>22&0x3C@46&0xffffffff=0x0a000005") {
+		t.Fatalf("Unexpected pair match: %s", m)
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "10.0.0.1-10.0.0.2-10.0.0.3", "fd00::/64"} {
+		if _, err := parseEncryptionPolicy(bad); err == nil {
+			t.Fatalf("Failed to detect invalid encryption policy %q", bad)
+		}
+	}
+}
+
+func TestPeerProber(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	d := &driver{
+		networks: networkTable{},
+		peerDb:   peerNetworkMap{mp: map[string]*peerMap{}},
+		probe: &probeState{
+			conn:     conn,
+			port:     conn.LocalAddr().(*net.UDPAddr).Port,
+			interval: time.Second,
+			peers:    make(map[string]*driverapi.PeerStatus),
+		},
+	}
+	d.networks["net1"] = &network{id: "net1", driver: d}
+	mac, _ := net.ParseMAC("02:42:0a:00:00:03")
+	d.peerDbAdd("net1", "ep1", net.ParseIP("10.0.0.3"), net.CIDRMask(24, 32), mac, net.ParseIP("127.0.0.1"), false)
+	go d.probe.serve()
+
+	d.probeTick(time.Now())
+	var status []*driverapi.PeerStatus
+	for i := 0; i < 50; i++ {
+		if status, err = d.PeerStatus("net1"); err != nil {
+			t.Fatal(err)
+		}
+		if len(status) == 1 && status[0].Reachable {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(status) != 1 || !status[0].Reachable || status[0].LastSeen.IsZero() {
+		t.Fatalf("Peer not reachable through the prober: %v", status)
+	}
+
+	// The peer stops answering
+	conn.Close()
+	d.probeTick(time.Now().Add(probeLossThreshold*time.Second + time.Second))
+	if status, _ = d.PeerStatus("net1"); status[0].Reachable {
+		t.Fatalf("Unreplied peer still reachable")
+	}
+}
+
+func TestInterSubnetPolicy(t *testing.T) {
+	if r := strings.Join(interSubnetDropRule("ov-000100-abcde", "ov-000101-abcde"), " "); r != "-i ov-000100-abcde -o ov-000101-abcde -j DROP" {
+		t.Fatalf("Unexpected inter-subnet rule: %s", r)
+	}
+
+	n := &network{id: "dummy", interSubnet: interSubnetDrop}
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil || nn.interSubnet != interSubnetDrop {
+		t.Fatalf("Failed to restore the inter-subnet policy: %v", err)
+	}
+}
+
+func TestIPv6Underlay(t *testing.T) {
+	d := &driver{bindAddress: "2001:db8::1"}
+	if !d.underlayIPv6() {
+		t.Fatalf("Failed to detect the IPv6 underlay")
+	}
+	if p := hostPrefix(net.ParseIP("2001:db8::2")); p != "2001:db8::2/128" {
+		t.Fatalf("Unexpected host prefix: %s", p)
+	}
+	if p := hostPrefix(net.ParseIP("192.168.1.2")); p != "192.168.1.2/32" {
+		t.Fatalf("Unexpected host prefix: %s", p)
+	}
+
+	if m := vniMatch(300, true); m != "52&0xFFFFFF00=76800" {
+		t.Fatalf("Unexpected vni match over IPv6: %s", m)
+	}
+
+	n := &network{id: "dummy", driver: d}
+	if o := n.overhead(); o != encapOverhead+ipv6Overhead {
+		t.Fatalf("Unexpected encap overhead over IPv6: %d", o)
+	}
+	n.encryption = encryptionWireGuard
+	if o := n.overhead(); o != encapOverhead+wgOverhead+2*ipv6Overhead {
+		t.Fatalf("Unexpected encrypted encap overhead over IPv6: %d", o)
+	}
+}
+
+func TestSandboxState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateDir = dir
+
+	d := &driver{
+		networks: networkTable{},
+		peerDb:   peerNetworkMap{mp: map[string]*peerMap{}},
+	}
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/24")
+	n := &network{id: "net1", driver: d, endpoints: endpointTable{},
+		subnets: []*subnet{{subnetIP: ipnet, vni: 300, brName: "ov-00012c-net1", vxlanName: "vx-00012c-net1"}}}
+	mac, _ := net.ParseMAC("02:42:0a:00:00:02")
+	addr := &net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)}
+	n.endpoints["ep1"] = &endpoint{id: "ep1", addr: addr, mac: mac, ifName: "veth1", srcName: "veth2"}
+	n.endpoints["ep2"] = &endpoint{id: "ep2", addr: addr, mac: mac, ifName: "veth3", srcName: "veth4"}
+	d.peerDbAdd(n.id, "ep3", net.ParseIP("10.0.0.3"), net.CIDRMask(24, 32), mac, net.ParseIP("192.168.1.2"), false)
+
+	if err := writeNetworkState(n.id, n.state("/var/run/docker/netns/1-net1", nil)); err != nil {
+		t.Fatal(err)
+	}
+	st, err := readNetworkState(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Key != "/var/run/docker/netns/1-net1" || len(st.Subnets) != 1 || st.Subnets[0].Vxlan != "vx-00012c-net1" {
+		t.Fatalf("Unexpected sandbox state: %+v", st)
+	}
+	// Only the endpoints with an interface in the sandbox are kept
+	if len(st.Endpoints) != 0 {
+		t.Fatalf("Unexpected endpoints in the sandbox state: %+v", st.Endpoints)
+	}
+	if len(st.Peers) != 1 || st.Peers[0].Vtep != "192.168.1.2" || st.Peers[0].Address != "10.0.0.3/24" {
+		t.Fatalf("Unexpected peers in the sandbox state: %+v", st.Peers)
+	}
+
+	removeNetworkState(n.id)
+	if _, err := readNetworkState(n.id); !os.IsNotExist(err) {
+		t.Fatalf("Sandbox state not removed: %v", err)
+	}
+}
+
+func TestOffloadSettings(t *testing.T) {
+	settings, err := parseOffloads("tx=off,gro=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := strings.Join(offloadArgs("vx-00012c-abcde", settings), " "); a != "-K vx-00012c-abcde tx off gro on" {
+		t.Fatalf("Unexpected offload arguments: %s", a)
+	}
+	for _, v := range []string{"tso=off", "tx=no", "tx=off,tx=on", ""} {
+		if _, err := parseOffloads(v); err == nil {
+			t.Fatalf("Failed to detect invalid offload settings %q", v)
+		}
+	}
+
+	n := &network{id: "dummy", udpCsum: true, offloads: settings}
+	nn := &network{id: "dummy"}
+	if err := nn.SetValue(n.Value()); err != nil || !nn.udpCsum || formatOffloads(nn.offloads) != "tx=off,gro=on" {
+		t.Fatalf("Failed to restore the checksum and offload settings: %v", err)
+	}
+}
@@ -5,9 +5,17 @@ import (
 	"net"
 	"sync"
 	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/osl"
 )
 
-const ovPeerTable = "overlay_peer_table"
+const (
+	ovPeerTable = "overlay_peer_table"
+	// Interval of the reconciliation of the peer db with the peer table
+	peerResyncInterval = 30 * time.Second
+)
 
 type peerKey struct {
 	peerIP  net.IP
@@ -165,6 +173,26 @@ func (d *driver) peerDbAdd(nid, eid string, peerIP net.IP, peerIPMask net.IPMask
 	pMap.Unlock()
 }
 
+// peerDbGet returns the peer db entry of the peer ip and mac
+func (d *driver) peerDbGet(nid string, peerIP net.IP, peerMac net.HardwareAddr) (peerEntry, bool) {
+	d.peerDb.Lock()
+	pMap, ok := d.peerDb.mp[nid]
+	d.peerDb.Unlock()
+	if !ok {
+		return peerEntry{}, false
+	}
+
+	pKey := peerKey{
+		peerIP:  peerIP,
+		peerMac: peerMac,
+	}
+
+	pMap.Lock()
+	defer pMap.Unlock()
+	pEntry, ok := pMap.mp[pKey.String()]
+	return pEntry, ok
+}
+
 func (d *driver) peerDbDelete(nid, eid string, peerIP net.IP, peerIPMask net.IPMask,
 	peerMac net.HardwareAddr, vtep net.IP) {
 	peerDbWg.Wait()
@@ -241,6 +269,10 @@ func (d *driver) peerAdd(nid, eid string, peerIP net.IP, peerIPMask net.IPMask,
 		return err
 	}
 
+	// A known peer on another vtep migrated there
+	old, moved := d.peerDbGet(nid, peerIP, peerMac)
+	moved = moved && !old.isLocal && !old.vtep.Equal(vtep)
+
 	if updateDb {
 		d.peerDbAdd(nid, eid, peerIP, peerIPMask, peerMac, vtep, false)
 	}
@@ -255,6 +287,10 @@ func (d *driver) peerAdd(nid, eid string, peerIP net.IP, peerIPMask net.IPMask,
 		return nil
 	}
 
+	if updateDb {
+		defer n.saveState()
+	}
+
 	IP := &net.IPNet{
 		IP:   peerIP,
 		Mask: peerIPMask,
@@ -273,16 +309,50 @@ func (d *driver) peerAdd(nid, eid string, peerIP net.IP, peerIPMask net.IPMask,
 		return fmt.Errorf("subnet sandbox join failed for %q: %v", s.subnetIP.String(), err)
 	}
 
+	n.Lock()
+	_, known := n.pathMTUs[vtep.String()]
+	n.Unlock()
+	if !known {
+		n.updateMTU()
+	}
+
+	if n.routed() {
+		return addPeerRoute(peerIP, vtep)
+	}
+
+	if n.encap == encapGeneve {
+		return n.geneveAddPeer(s, peerMac, vtep)
+	}
+
+	if n.dataPath == dataPathBPF {
+		return n.bpfAddPeer(s, peerMac, vtep)
+	}
+
 	// Add neighbor entry for the peer IP
 	if err := sbox.AddNeighbor(peerIP, peerMac, sbox.NeighborOptions().LinkName(s.vxlanName)); err != nil {
 		return fmt.Errorf("could not add neigbor entry into the sandbox: %v", err)
 	}
 
-	// Add fdb entry to the bridge for the peer mac
-	if err := sbox.AddNeighbor(vtep, peerMac, sbox.NeighborOptions().LinkName(s.vxlanName),
-		sbox.NeighborOptions().Family(syscall.AF_BRIDGE)); err != nil {
+	// Add fdb entry to the bridge for the peer mac. The entry of a
+	// migrated peer is added next to the one of its old vtep, which is
+	// removed once the new one is in place.
+	fdbOpts := []osl.NeighOption{sbox.NeighborOptions().LinkName(s.vxlanName),
+		sbox.NeighborOptions().Family(syscall.AF_BRIDGE)}
+	if moved {
+		fdbOpts = append(fdbOpts, sbox.NeighborOptions().Append())
+	}
+	if err := sbox.AddNeighbor(vtep, peerMac, fdbOpts...); err != nil {
 		return fmt.Errorf("could not add fdb entry into the sandbox: %v", err)
 	}
+	if moved {
+		if err := sbox.DeleteNeighbor(old.vtep, peerMac); err != nil {
+			logrus.Debugf("overlay: removing the old fdb entry of migrated peer %s failed: %v", peerIP, err)
+		}
+	}
+
+	if n.multicast {
+		return n.addFloodPeer(s, peerMac, vtep)
+	}
 
 	return nil
 }
@@ -295,6 +365,12 @@ func (d *driver) peerDelete(nid, eid string, peerIP net.IP, peerIPMask net.IPMas
 	}
 
 	if updateDb {
+		// The late withdrawal of a peer which migrated to another
+		// vtep or to this node leaves the new entries in place
+		if e, ok := d.peerDbGet(nid, peerIP, peerMac); ok && (e.isLocal || !e.vtep.Equal(vtep)) {
+			logrus.Debugf("overlay: ignoring the withdrawal of migrated peer %s from %s", peerIP, vtep)
+			return nil
+		}
 		d.peerDbDelete(nid, eid, peerIP, peerIPMask, peerMac, vtep)
 	}
 
@@ -308,6 +384,33 @@ func (d *driver) peerDelete(nid, eid string, peerIP net.IP, peerIPMask net.IPMas
 		return nil
 	}
 
+	if updateDb {
+		defer n.saveState()
+	}
+
+	if n.routed() {
+		return deletePeerRoute(peerIP, vtep)
+	}
+
+	if n.encap == encapGeneve || n.dataPath == dataPathBPF {
+		s := n.getSubnetforIP(&net.IPNet{IP: peerIP, Mask: peerIPMask})
+		if s == nil {
+			return fmt.Errorf("couldn't find the subnet %q in network %q\n", peerIP.String(), n.id)
+		}
+		if n.dataPath == dataPathBPF {
+			return n.bpfDeletePeer(s, peerMac)
+		}
+		return n.geneveDeletePeer(s, peerMac)
+	}
+
+	if n.multicast {
+		if s := n.getSubnetforIP(&net.IPNet{IP: peerIP, Mask: peerIPMask}); s != nil {
+			if err := n.deleteFloodPeer(s, peerMac); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Delete fdb entry to the bridge for the peer mac
 	if err := sbox.DeleteNeighbor(vtep, peerMac); err != nil {
 		return fmt.Errorf("could not delete fdb entry into the sandbox: %v", err)
@@ -329,3 +432,90 @@ func (d *driver) pushLocalDb() {
 		return false
 	})
 }
+
+// peerResyncLoop periodically reconciles the peer db of the networks with
+// the peer table
+func (d *driver) peerResyncLoop() {
+	for range time.Tick(peerResyncInterval) {
+		d.Lock()
+		networks := make([]*network, 0, len(d.networks))
+		for _, n := range d.networks {
+			if n.nInfo != nil {
+				networks = append(networks, n)
+			}
+		}
+		d.Unlock()
+
+		for _, n := range networks {
+			d.peerResync(n)
+		}
+	}
+}
+
+// peerResync removes the remote peers of the network which are gone from
+// the peer table and adds the ones missing from the peer db, whose events
+// were lost while the node was partitioned from the cluster
+func (d *driver) peerResync(n *network) {
+	type peer struct {
+		eid  string
+		rec  *PeerRecord
+		addr *net.IPNet
+		mac  net.HardwareAddr
+		vtep net.IP
+	}
+
+	// The peer db is walked before the table for the peers added in
+	// between not to be taken as stale
+	known := make(map[string]peerEntry)
+	d.peerDbNetworkWalk(n.id, func(pKey *peerKey, pEntry *peerEntry) bool {
+		if !pEntry.isLocal && !isStaticPeer(pEntry.eid) {
+			known[pKey.String()] = *pEntry
+		}
+		return false
+	})
+
+	live := make(map[string]bool)
+	var missing []peer
+	err := n.nInfo.WalkTable(ovPeerTable, func(eid string, value []byte) bool {
+		rec, addr, mac, vtep, err := parsePeerRecord(value)
+		if err != nil || vtep.String() == d.bindAddress {
+			return false
+		}
+		key := peerKey{peerIP: addr.IP, peerMac: mac}.String()
+		live[key] = true
+		if e, ok := known[key]; !ok || !e.vtep.Equal(vtep) {
+			missing = append(missing, peer{eid, rec, addr, mac, vtep})
+		}
+		return false
+	})
+	if err != nil {
+		logrus.Debugf("overlay: peer resync of network %s skipped: %v", n.id, err)
+		return
+	}
+
+	for keyStr, e := range known {
+		if live[keyStr] {
+			continue
+		}
+		var pKey peerKey
+		if _, err := fmt.Sscan(keyStr, &pKey); err != nil {
+			continue
+		}
+		logrus.Debugf("overlay: removing stale peer %s of network %s", keyStr, n.id)
+		n.setExternalRoutes(pKey.peerIP, nil)
+		if err := d.peerDelete(n.id, e.eid, pKey.peerIP, e.peerIPMask, pKey.peerMac, e.vtep, true); err != nil {
+			logrus.Warnf("overlay: failed to remove stale peer %s of network %s: %v", keyStr, n.id, err)
+		}
+	}
+
+	for _, p := range missing {
+		logrus.Debugf("overlay: adding missed peer %s %s of network %s", p.addr.IP, p.mac, n.id)
+		if prefixes, err := parsePrefixes(p.rec.ExternalPrefixes); err == nil && len(prefixes) > 0 {
+			n.setExternalRoutes(p.addr.IP, prefixes)
+		}
+		d.wgPeerUpdate(p.vtep, p.rec)
+		if err := d.peerAdd(n.id, p.eid, p.addr.IP, p.addr.Mask, p.mac, p.vtep, true); err != nil {
+			logrus.Warnf("overlay: failed to add missed peer %s of network %s: %v", p.addr.IP, n.id, err)
+		}
+	}
+}
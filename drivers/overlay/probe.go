@@ -0,0 +1,201 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/driverapi"
+	"github.com/docker/libnetwork/netlabel"
+	"github.com/docker/libnetwork/types"
+)
+
+const (
+	// UDP port the nodes answer the probes of their peers on
+	probePort = 4792
+	// Probes carry the magic, their type and the send time of the
+	// request, echoed by the reply
+	probeMagic   = 0x4f56504c
+	probeRequest = 1
+	probeReply   = 2
+	probeLen     = 16
+	// Number of probe intervals without reply after which a vtep is
+	// reported unreachable
+	probeLossThreshold = 3
+)
+
+// probeState is the prober of the node, sending a probe to the vtep of
+// each remote peer every interval and answering the probes of the peers.
+// The probes follow the underlay path of the tunnels, from the bind
+// address to the vteps.
+type probeState struct {
+	conn     *net.UDPConn
+	port     int
+	interval time.Duration
+	peers    map[string]*driverapi.PeerStatus
+	sync.Mutex
+}
+
+func probePacket(typ byte, sent time.Time) []byte {
+	b := make([]byte, probeLen)
+	binary.BigEndian.PutUint32(b[0:4], probeMagic)
+	b[4] = typ
+	binary.BigEndian.PutUint64(b[8:16], uint64(sent.UnixNano()))
+	return b
+}
+
+func parseProbe(b []byte) (byte, time.Time, bool) {
+	if len(b) != probeLen || binary.BigEndian.Uint32(b[0:4]) != probeMagic {
+		return 0, time.Time{}, false
+	}
+	return b[4], time.Unix(0, int64(binary.BigEndian.Uint64(b[8:16]))), true
+}
+
+// startProber starts the prober on the bind address when a probe
+// interval is configured on the driver
+func (d *driver) startProber() {
+	v, ok := d.config[netlabel.OverlayProbeInterval]
+	if !ok {
+		return
+	}
+	interval, err := parseInterval(v)
+	if err != nil || interval <= 0 {
+		logrus.Errorf("overlay: invalid probe interval %v: %v", v, err)
+		return
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(d.bindAddress), Port: probePort})
+	if err != nil {
+		logrus.Errorf("overlay: failed to start the peer prober: %v", err)
+		return
+	}
+
+	d.Lock()
+	d.probe = &probeState{
+		conn:     conn,
+		port:     probePort,
+		interval: interval,
+		peers:    make(map[string]*driverapi.PeerStatus),
+	}
+	d.Unlock()
+
+	go d.probe.serve()
+	go d.probeLoop()
+}
+
+// serve answers the probe requests and accounts the replies
+func (p *probeState) serve() {
+	b := make([]byte, 64)
+	for {
+		n, addr, err := p.conn.ReadFromUDP(b)
+		if err != nil {
+			logrus.Errorf("overlay: peer prober stopped: %v", err)
+			return
+		}
+		typ, sent, ok := parseProbe(b[:n])
+		if !ok {
+			continue
+		}
+		switch typ {
+		case probeRequest:
+			p.conn.WriteToUDP(probePacket(probeReply, sent), addr)
+		case probeReply:
+			p.Lock()
+			if ps, ok := p.peers[addr.IP.String()]; ok {
+				ps.LastSeen = time.Now()
+				ps.Latency = ps.LastSeen.Sub(sent)
+				ps.Reachable = true
+			}
+			p.Unlock()
+		}
+	}
+}
+
+func (d *driver) probeLoop() {
+	for range time.Tick(d.probe.interval) {
+		d.probeTick(time.Now())
+	}
+}
+
+// probeTick sends a probe to the vtep of each remote peer, updating the
+// reachability of the ones which did not reply recently
+func (d *driver) probeTick(now time.Time) {
+	vteps := make(map[string]bool)
+	d.peerDbWalk(func(nid string, pKey *peerKey, pEntry *peerEntry) bool {
+		if !pEntry.isLocal {
+			vteps[pEntry.vtep.String()] = true
+		}
+		return false
+	})
+
+	p := d.probe
+	p.Lock()
+	for vtep := range p.peers {
+		if !vteps[vtep] {
+			delete(p.peers, vtep)
+		}
+	}
+	for vtep := range vteps {
+		ps, ok := p.peers[vtep]
+		if !ok {
+			ps = &driverapi.PeerStatus{Vtep: net.ParseIP(vtep)}
+			p.peers[vtep] = ps
+		}
+		if ps.Reachable && now.Sub(ps.LastSeen) > probeLossThreshold*p.interval {
+			logrus.Warnf("overlay: peer %s stopped replying to the probes", vtep)
+			ps.Reachable = false
+		}
+	}
+	p.Unlock()
+
+	for vtep := range vteps {
+		addr := &net.UDPAddr{IP: net.ParseIP(vtep), Port: p.port}
+		if _, err := p.conn.WriteToUDP(probePacket(probeRequest, now), addr); err != nil {
+			logrus.Debugf("overlay: failed to probe peer %s: %v", vtep, err)
+		}
+	}
+}
+
+// PeerStatus returns the status of the vteps of the remote peers of the
+// network
+func (d *driver) PeerStatus(nid string) ([]*driverapi.PeerStatus, error) {
+	if d.network(nid) == nil {
+		return nil, types.NotFoundErrorf("could not find network with id %s", nid)
+	}
+	d.Lock()
+	p := d.probe
+	d.Unlock()
+	if p == nil {
+		return nil, types.ForbiddenErrorf("the overlay peer prober is not enabled, see %s", netlabel.OverlayProbeInterval)
+	}
+
+	vteps := make(map[string]bool)
+	d.peerDbNetworkWalk(nid, func(pKey *peerKey, pEntry *peerEntry) bool {
+		if !pEntry.isLocal {
+			vteps[pEntry.vtep.String()] = true
+		}
+		return false
+	})
+
+	p.Lock()
+	defer p.Unlock()
+	list := make([]*driverapi.PeerStatus, 0, len(vteps))
+	for vtep := range vteps {
+		ps := &driverapi.PeerStatus{Vtep: net.ParseIP(vtep)}
+		if s, ok := p.peers[vtep]; ok {
+			*ps = *s
+		}
+		list = append(list, ps)
+	}
+	sort.Sort(byVtep(list))
+	return list, nil
+}
+
+type byVtep []*driverapi.PeerStatus
+
+func (b byVtep) Len() int           { return len(b) }
+func (b byVtep) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byVtep) Less(i, j int) bool { return b[i].Vtep.String() < b[j].Vtep.String() }
@@ -0,0 +1,280 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/osl"
+	"github.com/docker/libnetwork/types"
+)
+
+// The sandbox of a network is kept across the restarts of the daemon. Its
+// key, devices, local endpoints and remote peers are saved as they change,
+// and the next run adopts the namespace with the devices and the fdb and
+// neighbor entries in place instead of recreating it, the peers being
+// programmed again over the existing entries. The traffic of the endpoints
+// keeps flowing in between. Only the sandboxes of the kernel vxlan data
+// path are kept, the WireGuard keys of the encrypted networks are
+// renegotiated by the new run.
+
+// Directory of the saved sandbox states, one file per network
+var stateDir = "/var/run/docker/overlay"
+
+type subnetState struct {
+	SubnetIP string
+	Vni      uint32
+	Bridge   string
+	Vxlan    string
+}
+
+type endpointState struct {
+	ID      string
+	Address string
+	Mac     string
+	IfName  string
+	SrcName string
+}
+
+type peerState struct {
+	ID      string
+	Address string
+	Mac     string
+	Vtep    string
+}
+
+// networkState is the saved state of the sandbox of a network
+type networkState struct {
+	Key   string
+	Epoch int
+	// source names of the interfaces by name in the sandbox
+	Ifaces    map[string]string
+	Subnets   []*subnetState
+	Endpoints []*endpointState `json:",omitempty"`
+	Peers     []*peerState     `json:",omitempty"`
+}
+
+func statePath(nid string) string {
+	return filepath.Join(stateDir, nid+".json")
+}
+
+// keepsSandbox returns whether the sandbox of the network is kept across
+// the restarts of the daemon
+func (n *network) keepsSandbox() bool {
+	return !hostMode && !n.routed() && n.encap == "" && n.dataPath == ""
+}
+
+// state returns the state of the sandbox with the key and interfaces. To
+// be called while holding the network lock.
+func (n *network) state(key string, ifaces []osl.Interface) *networkState {
+	st := &networkState{Key: key, Epoch: n.initEpoch, Ifaces: make(map[string]string)}
+	sandboxIfs := make(map[string]bool)
+	for _, i := range ifaces {
+		st.Ifaces[i.DstName()] = i.SrcName()
+		sandboxIfs[i.SrcName()] = true
+	}
+	for _, s := range n.subnets {
+		if s.brName == "" {
+			continue
+		}
+		st.Subnets = append(st.Subnets, &subnetState{
+			SubnetIP: s.subnetIP.String(),
+			Vni:      s.vni,
+			Bridge:   s.brName,
+			Vxlan:    s.vxlanName,
+		})
+	}
+	for _, ep := range n.endpoints {
+		if !sandboxIfs[ep.srcName] {
+			continue
+		}
+		st.Endpoints = append(st.Endpoints, &endpointState{
+			ID:      ep.id,
+			Address: ep.addr.String(),
+			Mac:     ep.mac.String(),
+			IfName:  ep.ifName,
+			SrcName: ep.srcName,
+		})
+	}
+	n.driver.peerDbNetworkWalk(n.id, func(pKey *peerKey, pEntry *peerEntry) bool {
+		if !pEntry.isLocal {
+			st.Peers = append(st.Peers, &peerState{
+				ID:      pEntry.eid,
+				Address: (&net.IPNet{IP: pKey.peerIP, Mask: pEntry.peerIPMask}).String(),
+				Mac:     pKey.peerMac.String(),
+				Vtep:    pEntry.vtep.String(),
+			})
+		}
+		return false
+	})
+	return st
+}
+
+// saveState saves the state of the sandbox of the network
+func (n *network) saveState() {
+	n.Lock()
+	defer n.Unlock()
+	n.writeState()
+}
+
+// writeState saves the state of the sandbox of the network. To be called
+// while holding the network lock.
+func (n *network) writeState() {
+	if n.sbox == nil || !n.keepsSandbox() {
+		return
+	}
+	st := n.state(n.sbox.Key(), n.sbox.Info().Interfaces())
+	if err := writeNetworkState(n.id, st); err != nil {
+		logrus.Warnf("overlay: failed to save the sandbox state of network %s: %v", n.id, err)
+	}
+}
+
+func writeNetworkState(nid string, st *networkState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return err
+	}
+	tmp := statePath(nid) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statePath(nid))
+}
+
+func readNetworkState(nid string) (*networkState, error) {
+	data, err := ioutil.ReadFile(statePath(nid))
+	if err != nil {
+		return nil, err
+	}
+	var st networkState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("invalid sandbox state: %v", err)
+	}
+	return &st, nil
+}
+
+func removeNetworkState(nid string) {
+	if err := os.Remove(statePath(nid)); err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("overlay: failed to remove the sandbox state of network %s: %v", nid, err)
+	}
+}
+
+// restoreSandbox adopts the sandbox of the network a previous run of the
+// daemon saved, if it is still in place and matches the subnets of the
+// network
+func (n *network) restoreSandbox() (osl.Sandbox, bool) {
+	if !n.keepsSandbox() {
+		return nil, false
+	}
+	st, err := readNetworkState(n.id)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Warnf("overlay: failed to read the sandbox state of network %s: %v", n.id, err)
+		}
+		return nil, false
+	}
+	removeNetworkState(n.id)
+
+	subnets := make(map[*subnet]*subnetState)
+	for _, ss := range st.Subnets {
+		s := n.getMatchingSubnet(ipNet(ss.SubnetIP))
+		if s == nil {
+			logrus.Infof("overlay: not restoring the sandbox of network %s, subnet %s is gone", n.id, ss.SubnetIP)
+			return nil, false
+		}
+		if err := n.obtainVxlanID(s); err != nil || n.vxlanID(s) != ss.Vni {
+			logrus.Infof("overlay: not restoring the sandbox of network %s, vni of subnet %s changed", n.id, ss.SubnetIP)
+			return nil, false
+		}
+		subnets[s] = ss
+	}
+
+	sbox, err := osl.OpenSandbox(st.Key, st.Ifaces)
+	if err != nil {
+		logrus.Infof("overlay: not restoring the sandbox of network %s: %v", n.id, err)
+		return nil, false
+	}
+
+	adopted := make(map[string]bool)
+	for _, i := range sbox.Info().Interfaces() {
+		adopted[i.SrcName()] = true
+	}
+
+	var endpoints []*endpoint
+	for _, es := range st.Endpoints {
+		if !adopted[es.SrcName] {
+			continue
+		}
+		addr, err := types.ParseCIDR(es.Address)
+		if err != nil {
+			continue
+		}
+		mac, err := net.ParseMAC(es.Mac)
+		if err != nil {
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{id: es.ID, addr: addr, mac: mac, ifName: es.IfName, srcName: es.SrcName})
+	}
+
+	n.Lock()
+	n.initEpoch = st.Epoch
+	for s, ss := range subnets {
+		s.brName = ss.Bridge
+		s.vxlanName = ss.Vxlan
+		s.once = &sync.Once{}
+		s.once.Do(func() {})
+	}
+	for _, ep := range endpoints {
+		if _, ok := n.endpoints[ep.id]; !ok {
+			n.endpoints[ep.id] = ep
+		}
+		n.joinCnt++
+	}
+	n.Unlock()
+
+	vtep := net.ParseIP(n.driver.bindAddress)
+	for _, ep := range endpoints {
+		n.driver.peerDbAdd(n.id, ep.id, ep.addr.IP, ep.addr.Mask, ep.mac, vtep, true)
+	}
+	for _, ps := range st.Peers {
+		addr, err := types.ParseCIDR(ps.Address)
+		if err != nil {
+			continue
+		}
+		mac, err := net.ParseMAC(ps.Mac)
+		if err != nil {
+			continue
+		}
+		if _, ok := n.driver.peerDbGet(n.id, addr.IP, mac); ok {
+			continue
+		}
+		n.driver.peerDbAdd(n.id, ps.ID, addr.IP, addr.Mask, mac, net.ParseIP(ps.Vtep), false)
+	}
+
+	if n.encryption != "" {
+		for s := range subnets {
+			if err := n.setupEncryption(s); err != nil {
+				logrus.Warnf("overlay: failed to restore the encryption of network %s: %v", n.id, err)
+			}
+		}
+	}
+
+	logrus.Infof("overlay: restored the sandbox of network %s with %d endpoints and %d peers", n.id, len(endpoints), len(st.Peers))
+	return sbox, true
+}
+
+func ipNet(s string) *net.IPNet {
+	_, nw, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil
+	}
+	return nw
+}
@@ -0,0 +1,88 @@
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/iptables"
+	"github.com/docker/libnetwork/osl"
+	"github.com/vishvananda/netlink"
+)
+
+// encapNone is the encapsulation of the routed networks, whose subnet
+// bridges live in the host namespace and reach the peers through host
+// routes to their vteps, the nodes sharing an L3 fabric
+const encapNone = "none"
+
+// routed returns whether the network is routed instead of encapsulated
+func (n *network) routed() bool {
+	return n.encap == encapNone
+}
+
+// peerRoute returns the host route to the peer through its vtep
+func peerRoute(peerIP, vtep net.IP) *netlink.Route {
+	return &netlink.Route{
+		Dst: &net.IPNet{IP: peerIP, Mask: net.CIDRMask(32, 32)},
+		Gw:  vtep,
+	}
+}
+
+func addPeerRoute(peerIP, vtep net.IP) error {
+	defer osl.InitOSContext()()
+
+	route := peerRoute(peerIP, vtep)
+	err := netlink.RouteAdd(route)
+	if err == syscall.EEXIST {
+		// The peer moved to another node
+		netlink.RouteDel(&netlink.Route{Dst: route.Dst})
+		err = netlink.RouteAdd(route)
+	}
+	if err != nil {
+		return fmt.Errorf("could not add the route to peer %s through %s: %v", peerIP, vtep, err)
+	}
+	return nil
+}
+
+func deletePeerRoute(peerIP, vtep net.IP) error {
+	defer osl.InitOSContext()()
+
+	if err := netlink.RouteDel(peerRoute(peerIP, vtep)); err != nil {
+		return fmt.Errorf("could not delete the route to peer %s through %s: %v", peerIP, vtep, err)
+	}
+	return nil
+}
+
+// deletePeerRoutes deletes the host routes to the remote peers of the
+// network
+func (d *driver) deletePeerRoutes(nid string) {
+	d.peerDbNetworkWalk(nid, func(pKey *peerKey, pEntry *peerEntry) bool {
+		if !pEntry.isLocal {
+			if err := deletePeerRoute(pKey.peerIP, pEntry.vtep); err != nil {
+				logrus.Debugf("%v", err)
+			}
+		}
+		return false
+	})
+}
+
+// setupRoutedSubnet makes the bridge of the subnet answer the ARP requests
+// for the peers on the other nodes and accept the traffic they route to it
+func setupRoutedSubnet(cname, brName string, subnet *net.IPNet) error {
+	if err := ioutil.WriteFile("/proc/sys/net/ipv4/conf/"+brName+"/proxy_arp", []byte("1"), 0644); err != nil {
+		return fmt.Errorf("could not enable proxy arp on bridge %s: %v", brName, err)
+	}
+
+	// Accept in the network chain the traffic of the subnet routed from
+	// the other nodes, the chain is flushed on the sandbox destroy
+	rule := []string{"-s", subnet.String(), "-j", "ACCEPT"}
+	if iptables.Exists(iptables.Filter, cname, rule...) {
+		return nil
+	}
+	if err := iptables.RawCombinedOutput(append([]string{"-I", cname}, rule...)...); err != nil {
+		return fmt.Errorf("failed to program routed filter rule for network chain %s, subnet %s: %v", cname, subnet, err)
+	}
+	return nil
+}
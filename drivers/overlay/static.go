@@ -0,0 +1,204 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/netlabel"
+	"github.com/docker/libnetwork/netutils"
+	"github.com/docker/libnetwork/types"
+)
+
+// Interval of the check of the static peers file for changes
+const staticPeersInterval = 10 * time.Second
+
+// staticPeerJSON is an entry of the static peers file, which lists the
+// endpoints of the overlay subnets by their vni for the driver to be used
+// without the cluster store and the networkdb gossip. The same file can be
+// given to all the nodes, the entries of the local vtep being skipped.
+type staticPeerJSON struct {
+	VNI     uint32
+	Address string
+	Mac     string `json:",omitempty"`
+	Vtep    string
+}
+
+type staticPeer struct {
+	vni  uint32
+	addr *net.IPNet
+	mac  net.HardwareAddr
+	vtep net.IP
+}
+
+// staticPeerID returns the peer db endpoint id of the static peer
+func staticPeerID(ip net.IP) string {
+	return "static-" + ip.String()
+}
+
+func isStaticPeer(eid string) bool {
+	return strings.HasPrefix(eid, "static-")
+}
+
+func (sp *staticPeerJSON) peer() (*staticPeer, error) {
+	addr, err := types.ParseCIDR(sp.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid static peer address %q: %v", sp.Address, err)
+	}
+	mac := netutils.GenerateMACFromIP(addr.IP)
+	if sp.Mac != "" {
+		if mac, err = net.ParseMAC(sp.Mac); err != nil {
+			return nil, fmt.Errorf("invalid static peer mac %q: %v", sp.Mac, err)
+		}
+	}
+	vtep := net.ParseIP(sp.Vtep)
+	if vtep == nil || vtep.IsUnspecified() {
+		return nil, fmt.Errorf("invalid static peer vtep %q", sp.Vtep)
+	}
+	if sp.VNI == 0 || sp.VNI > maxVNI {
+		return nil, fmt.Errorf("invalid static peer vni %d", sp.VNI)
+	}
+	return &staticPeer{vni: sp.VNI, addr: addr, mac: mac, vtep: vtep}, nil
+}
+
+// parseStaticPeers parses the content of the static peers file
+func parseStaticPeers(data []byte) ([]*staticPeer, error) {
+	var list []staticPeerJSON
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid static peers file: %v", err)
+	}
+	peers := make([]*staticPeer, 0, len(list))
+	for i := range list {
+		p, err := list[i].peer()
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+// staticPeersFile returns the path of the static peers file configured on
+// the driver, if any
+func (d *driver) staticPeersFile() string {
+	path, _ := d.config[netlabel.OverlayStaticPeers].(string)
+	return path
+}
+
+// loadStaticPeers reads the static peers file if it changed since the last
+// load. A removed file withdraws all the static peers, an invalid one keeps
+// the previous ones.
+func (d *driver) loadStaticPeers() error {
+	path := d.staticPeersFile()
+	if path == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		d.Lock()
+		d.staticPeers = nil
+		d.staticMtime = time.Time{}
+		d.Unlock()
+		return nil
+	}
+
+	d.Lock()
+	unchanged := d.staticPeers != nil && fi.ModTime().Equal(d.staticMtime)
+	d.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	peers, err := parseStaticPeers(data)
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	d.staticPeers = peers
+	d.staticMtime = fi.ModTime()
+	d.Unlock()
+	return nil
+}
+
+// staticPeersLoop applies the changes of the static peers file to the
+// networks
+func (d *driver) staticPeersLoop() {
+	for range time.Tick(staticPeersInterval) {
+		if err := d.loadStaticPeers(); err != nil {
+			logrus.Warnf("overlay: failed to load the static peers: %v", err)
+		}
+
+		d.Lock()
+		networks := make([]*network, 0, len(d.networks))
+		for _, n := range d.networks {
+			networks = append(networks, n)
+		}
+		d.Unlock()
+
+		for _, n := range networks {
+			d.applyStaticPeers(n)
+		}
+	}
+}
+
+// applyStaticPeers programs the static peers of the subnets of the network,
+// removing the ones gone from the file. Nothing is done until the node
+// knows its own vtep.
+func (d *driver) applyStaticPeers(n *network) {
+	d.Lock()
+	peers := d.staticPeers
+	bindAddress := d.bindAddress
+	d.Unlock()
+
+	if bindAddress == "" {
+		return
+	}
+
+	want := make(map[string]*staticPeer)
+	for _, p := range peers {
+		if p.vtep.String() == bindAddress || isIPv6(p.vtep) != isIPv6(net.ParseIP(bindAddress)) {
+			continue
+		}
+		s := n.getSubnetforIP(p.addr)
+		if s == nil || n.vxlanID(s) != p.vni {
+			continue
+		}
+		want[p.addr.IP.String()] = p
+	}
+
+	n.Lock()
+	old := n.staticPeers
+	n.staticPeers = want
+	n.Unlock()
+
+	for key, p := range old {
+		if np, ok := want[key]; ok && np.mac.String() == p.mac.String() && np.vtep.Equal(p.vtep) {
+			continue
+		}
+		if err := d.peerDelete(n.id, staticPeerID(p.addr.IP), p.addr.IP, p.addr.Mask, p.mac, p.vtep, true); err != nil {
+			logrus.Warnf("overlay: failed to remove static peer %s of network %s: %v", key, n.id, err)
+		}
+	}
+	for key, p := range want {
+		if op, ok := old[key]; ok && op.mac.String() == p.mac.String() && op.vtep.Equal(p.vtep) {
+			continue
+		}
+		if err := d.peerAdd(n.id, staticPeerID(p.addr.IP), p.addr.IP, p.addr.Mask, p.mac, p.vtep, true); err != nil {
+			logrus.Warnf("overlay: failed to program static peer %s of network %s: %v", key, n.id, err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/docker/libnetwork/types"
 
 	builtinIpam "github.com/docker/libnetwork/ipams/builtin"
+	dhcpIpam "github.com/docker/libnetwork/ipams/dhcpipam"
 	nullIpam "github.com/docker/libnetwork/ipams/null"
 	remoteIpam "github.com/docker/libnetwork/ipams/remote"
 )
@@ -162,6 +163,7 @@ func (r *DrvRegistry) initIPAMs(lDs, gDs interface{}) error {
 		builtinIpam.Init,
 		remoteIpam.Init,
 		nullIpam.Init,
+		dhcpIpam.Init,
 	} {
 		if err := fn(r, nil, gDs); err != nil {
 			return err
@@ -153,7 +153,7 @@ func TestWalkIPAMs(t *testing.T) {
 	})
 
 	sort.Strings(ipams)
-	assert.Equal(t, ipams, []string{"default", "null"})
+	assert.Equal(t, ipams, []string{"default", "dhcp", "null"})
 }
 
 func TestWalkDrivers(t *testing.T) {
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"sort"
 	"strings"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/driverapi"
 	"github.com/docker/libnetwork/ipamapi"
 	"github.com/docker/libnetwork/netlabel"
 	"github.com/docker/libnetwork/options"
@@ -42,6 +44,15 @@ type Endpoint interface {
 
 	// Delete and detaches this endpoint from the network.
 	Delete(force bool) error
+
+	// SetHealthy marks the endpoint as healthy or unhealthy. An unhealthy
+	// endpoint stays attached but is removed from the load balancers and
+	// the DNS RR answers of its service across the cluster.
+	SetHealthy(healthy bool) error
+
+	// Statistics returns the RX/TX counters of the endpoint interface, as
+	// seen from the sandbox.
+	Statistics() (*types.InterfaceStatistics, error)
 }
 
 // EndpointOption is an option setter function type used to pass various options to Network
@@ -64,13 +75,29 @@ type endpoint struct {
 	joinLeaveDone     chan struct{}
 	prefAddress       net.IP
 	prefAddressV6     net.IP
+	prefSecondary     []net.IP
+	prefSecondaryV6   []net.IP
 	ipamOptions       map[string]string
+	labels            map[string]string
 	aliases           map[string]string
 	myAliases         []string
 	svcID             string
 	svcName           string
 	virtualIP         net.IP
+	virtualIPv6       net.IP
 	ingressPorts      []*PortConfig
+	svcMetadata       map[string]string
+	svcSchedName      string
+	svcPersistTimeout uint32
+	svcPersistMaskLen uint32
+	svcWeight         uint32
+	svcUnhealthy      bool
+	svcDSR            bool
+	svcLBPreference   string
+	svcUDPTimeout     uint32
+	svcMaxConns       uint32
+	svcConnRate       uint32
+	svcGroup          string
 	dbIndex           uint64
 	dbExists          bool
 	sync.Mutex
@@ -96,7 +123,40 @@ func (ep *endpoint) MarshalJSON() ([]byte, error) {
 	epMap["svcName"] = ep.svcName
 	epMap["svcID"] = ep.svcID
 	epMap["virtualIP"] = ep.virtualIP.String()
+	if len(ep.virtualIPv6) != 0 {
+		epMap["virtualIPv6"] = ep.virtualIPv6.String()
+	}
 	epMap["ingressPorts"] = ep.ingressPorts
+	if len(ep.svcMetadata) > 0 {
+		epMap["svcMetadata"] = ep.svcMetadata
+	}
+	if ep.svcSchedName != "" {
+		epMap["svcSchedName"] = ep.svcSchedName
+	}
+	if ep.svcPersistTimeout > 0 {
+		epMap["svcPersistTimeout"] = ep.svcPersistTimeout
+		epMap["svcPersistMaskLen"] = ep.svcPersistMaskLen
+	}
+	if ep.svcWeight > 0 {
+		epMap["svcWeight"] = ep.svcWeight
+	}
+	epMap["svcUnhealthy"] = ep.svcUnhealthy
+	epMap["svcDSR"] = ep.svcDSR
+	if ep.svcLBPreference != "" {
+		epMap["svcLBPreference"] = ep.svcLBPreference
+	}
+	if ep.svcUDPTimeout > 0 {
+		epMap["svcUDPTimeout"] = ep.svcUDPTimeout
+	}
+	if ep.svcMaxConns > 0 {
+		epMap["svcMaxConns"] = ep.svcMaxConns
+	}
+	if ep.svcConnRate > 0 {
+		epMap["svcConnRate"] = ep.svcConnRate
+	}
+	if ep.svcGroup != "" {
+		epMap["svcGroup"] = ep.svcGroup
+	}
 
 	return json.Marshal(epMap)
 }
@@ -194,11 +254,61 @@ func (ep *endpoint) UnmarshalJSON(b []byte) (err error) {
 		ep.virtualIP = net.ParseIP(vip.(string))
 	}
 
+	if vip, ok := epMap["virtualIPv6"]; ok {
+		ep.virtualIPv6 = net.ParseIP(vip.(string))
+	}
+
 	pc, _ := json.Marshal(epMap["ingressPorts"])
 	var ingressPorts []*PortConfig
 	json.Unmarshal(pc, &ingressPorts)
 	ep.ingressPorts = ingressPorts
 
+	if v, ok := epMap["svcMetadata"]; ok {
+		sm, _ := json.Marshal(v)
+		json.Unmarshal(sm, &ep.svcMetadata)
+	}
+
+	if v, ok := epMap["svcSchedName"]; ok {
+		ep.svcSchedName = v.(string)
+	}
+
+	if v, ok := epMap["svcPersistTimeout"]; ok {
+		ep.svcPersistTimeout = uint32(v.(float64))
+		ep.svcPersistMaskLen = uint32(epMap["svcPersistMaskLen"].(float64))
+	}
+
+	if v, ok := epMap["svcWeight"]; ok {
+		ep.svcWeight = uint32(v.(float64))
+	}
+
+	if v, ok := epMap["svcUnhealthy"]; ok {
+		ep.svcUnhealthy = v.(bool)
+	}
+
+	if v, ok := epMap["svcDSR"]; ok {
+		ep.svcDSR = v.(bool)
+	}
+
+	if v, ok := epMap["svcLBPreference"]; ok {
+		ep.svcLBPreference = v.(string)
+	}
+
+	if v, ok := epMap["svcUDPTimeout"]; ok {
+		ep.svcUDPTimeout = uint32(v.(float64))
+	}
+
+	if v, ok := epMap["svcMaxConns"]; ok {
+		ep.svcMaxConns = uint32(v.(float64))
+	}
+
+	if v, ok := epMap["svcConnRate"]; ok {
+		ep.svcConnRate = uint32(v.(float64))
+	}
+
+	if v, ok := epMap["svcGroup"]; ok {
+		ep.svcGroup = v.(string)
+	}
+
 	ma, _ := json.Marshal(epMap["myAliases"])
 	var myAliases []string
 	json.Unmarshal(ma, &myAliases)
@@ -226,10 +336,29 @@ func (ep *endpoint) CopyTo(o datastore.KVObject) error {
 	dstEp.svcName = ep.svcName
 	dstEp.svcID = ep.svcID
 	dstEp.virtualIP = ep.virtualIP
+	dstEp.virtualIPv6 = ep.virtualIPv6
+	dstEp.svcSchedName = ep.svcSchedName
+	dstEp.svcPersistTimeout = ep.svcPersistTimeout
+	dstEp.svcPersistMaskLen = ep.svcPersistMaskLen
+	dstEp.svcWeight = ep.svcWeight
+	dstEp.svcUnhealthy = ep.svcUnhealthy
+	dstEp.svcDSR = ep.svcDSR
+	dstEp.svcLBPreference = ep.svcLBPreference
+	dstEp.svcUDPTimeout = ep.svcUDPTimeout
+	dstEp.svcMaxConns = ep.svcMaxConns
+	dstEp.svcConnRate = ep.svcConnRate
+	dstEp.svcGroup = ep.svcGroup
 
 	dstEp.ingressPorts = make([]*PortConfig, len(ep.ingressPorts))
 	copy(dstEp.ingressPorts, ep.ingressPorts)
 
+	if ep.svcMetadata != nil {
+		dstEp.svcMetadata = make(map[string]string, len(ep.svcMetadata))
+		for k, v := range ep.svcMetadata {
+			dstEp.svcMetadata[k] = v
+		}
+	}
+
 	if ep.iface != nil {
 		dstEp.iface = &endpointInterface{}
 		ep.iface.CopyTo(dstEp.iface)
@@ -284,6 +413,90 @@ func (ep *endpoint) isAnonymous() bool {
 	return ep.anonymous
 }
 
+func (ep *endpoint) isUnhealthy() bool {
+	ep.Lock()
+	defer ep.Unlock()
+	return ep.svcUnhealthy
+}
+
+func (ep *endpoint) SetHealthy(healthy bool) error {
+	ep.Lock()
+	if ep.svcUnhealthy == !healthy {
+		ep.Unlock()
+		return nil
+	}
+	ep.svcUnhealthy = !healthy
+	ep.Unlock()
+
+	n := ep.getNetwork()
+	if err := n.getController().updateToStore(ep); err != nil {
+		return err
+	}
+
+	return ep.updateHealthInCluster()
+}
+
+func (ep *endpoint) Statistics() (*types.InterfaceStatistics, error) {
+	n, err := ep.getNetworkFromStore()
+	if err != nil {
+		return nil, fmt.Errorf("could not find network in store for endpoint statistics: %v", err)
+	}
+
+	d, err := n.driver(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver for endpoint statistics: %v", err)
+	}
+	if es, ok := d.(driverapi.EndpointStatistics); ok {
+		return es.EndpointStatistics(n.ID(), ep.ID())
+	}
+
+	// Otherwise read the counters of the interface in the sandbox
+	sb, ok := ep.getSandbox()
+	if !ok {
+		return nil, types.ForbiddenErrorf("endpoint %s has not joined a sandbox", ep.Name())
+	}
+	sb.Lock()
+	osb := sb.osSbox
+	sb.Unlock()
+
+	ep.Lock()
+	srcName := ep.iface.srcName
+	ep.Unlock()
+
+	if osb != nil {
+		for _, i := range osb.Info().Interfaces() {
+			if i.SrcName() == srcName {
+				return i.Statistics()
+			}
+		}
+	}
+
+	return nil, types.NotFoundErrorf("no interface of endpoint %s in sandbox %s", ep.Name(), sb.ID())
+}
+
+// svcMetadataRecords returns the service metadata in the key=value form
+// of TXT records, sorted by key.
+func (ep *endpoint) svcMetadataRecords() []string {
+	ep.Lock()
+	defer ep.Unlock()
+
+	if len(ep.svcMetadata) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(ep.svcMetadata))
+	for k := range ep.svcMetadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	recs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		recs = append(recs, k+"="+ep.svcMetadata[k])
+	}
+	return recs
+}
+
 func (ep *endpoint) needResolver() bool {
 	ep.Lock()
 	defer ep.Unlock()
@@ -460,6 +673,9 @@ func (ep *endpoint) sbJoin(sb *sandbox, options ...EndpointOption) error {
 	if err = sb.updateDNS(n.enableIPv6); err != nil {
 		return err
 	}
+	if err = sb.updateDNSSearch(n.DNSSearch()); err != nil {
+		return err
+	}
 
 	if err = n.getController().updateToStore(ep); err != nil {
 		return err
@@ -751,30 +967,26 @@ func (ep *endpoint) Delete(force bool) error {
 		}
 	}
 
-	if err = n.getController().deleteFromStore(ep); err != nil {
-		return err
+	if err = n.getEpCnt().decEndpointCntWith(ep); err != nil {
+		if !force {
+			return err
+		}
+		log.Debugf("Error decrementing the endpoint count of network %s on force delete of endpoint %s: %v", n.name, name, err)
+
+		if err = n.getController().deleteFromStore(ep); err != nil {
+			return err
+		}
 	}
 
 	defer func() {
 		if err != nil && !force {
 			ep.dbExists = false
-			if e := n.getController().updateToStore(ep); e != nil {
+			if e := n.getEpCnt().incEndpointCntWith(ep); e != nil {
 				log.Warnf("failed to recreate endpoint in store %s : %v", name, e)
 			}
 		}
 	}()
 
-	if err = n.getEpCnt().DecEndpointCnt(); err != nil && !force {
-		return err
-	}
-	defer func() {
-		if err != nil && !force {
-			if e := n.getEpCnt().IncEndpointCnt(); e != nil {
-				log.Warnf("failed to update network %s : %v", n.name, e)
-			}
-		}
-	}()
-
 	// unwatch for service records
 	if !n.getController().isAgent() {
 		n.getController().unWatchSvcRecord(ep)
@@ -852,6 +1064,14 @@ func EndpointOptionGeneric(generic map[string]interface{}) EndpointOption {
 	}
 }
 
+// CreateOptionLabels function returns an option setter for the labels of
+// the endpoint passed to the ipam drivers requiring them
+func CreateOptionLabels(labels map[string]string) EndpointOption {
+	return func(ep *endpoint) {
+		ep.labels = labels
+	}
+}
+
 // CreateOptionIpam function returns an option setter for the ipam configuration for this endpoint
 func CreateOptionIpam(ipV4, ipV6 net.IP, ipamOptions map[string]string) EndpointOption {
 	return func(ep *endpoint) {
@@ -861,6 +1081,16 @@ func CreateOptionIpam(ipV4, ipV6 net.IP, ipamOptions map[string]string) Endpoint
 	}
 }
 
+// CreateOptionSecondaryAddresses function returns an option setter for the
+// additional addresses to assign to the endpoint interface. A nil address
+// is allocated from the network pools.
+func CreateOptionSecondaryAddresses(ipV4, ipV6 []net.IP) EndpointOption {
+	return func(ep *endpoint) {
+		ep.prefSecondary = ipV4
+		ep.prefSecondaryV6 = ipV6
+	}
+}
+
 // CreateOptionExposedPorts function returns an option setter for the container exposed
 // ports option to be passed to network.CreateEndpoint() method.
 func CreateOptionExposedPorts(exposedPorts []types.TransportPort) EndpointOption {
@@ -921,6 +1151,105 @@ func CreateOptionService(name, id string, vip net.IP, ingressPorts []*PortConfig
 	}
 }
 
+// CreateOptionServiceMetadata function returns an option setter for the
+// key/value metadata of the service, served as DNS TXT records
+func CreateOptionServiceMetadata(metadata map[string]string) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcMetadata = metadata
+	}
+}
+
+// CreateOptionServiceScheduler function returns an option setter for the
+// scheduling algorithm used to balance the load across the service backends.
+// Supported values are SchedRoundRobin, SchedLeastConnection and
+// SchedSourceHashing.
+func CreateOptionServiceScheduler(schedName string) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcSchedName = schedName
+	}
+}
+
+// CreateOptionServicePersistence function returns an option setter for the
+// session affinity of the service. Connections from clients within the same
+// maskLen prefix stick to one backend until timeout seconds elapse without
+// traffic. A zero maskLen pins each client address individually.
+func CreateOptionServicePersistence(timeout uint32, maskLen uint32) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcPersistTimeout = timeout
+		ep.svcPersistMaskLen = maskLen
+	}
+}
+
+// CreateOptionServiceWeight function returns an option setter for the weight
+// of the endpoint among the backends of its service. The share of the VIP
+// traffic received by the endpoint is proportional to its weight.
+func CreateOptionServiceWeight(weight uint32) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcWeight = weight
+	}
+}
+
+// CreateOptionServiceDSR function returns an option setter to enable the
+// direct server return mode for the ingress traffic of the service. The
+// backends reply directly to the clients instead of going back through
+// the ingress node.
+func CreateOptionServiceDSR() EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcDSR = true
+	}
+}
+
+// CreateOptionServiceLBPreference function returns an option setter for the
+// locality of the backends preferred by the service load balancers, either
+// LBPreferNode or LBPreferSubnet. The remaining backends only receive
+// traffic when no preferred backend exists.
+func CreateOptionServiceLBPreference(pref string) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcLBPreference = pref
+	}
+}
+
+// CreateOptionServiceUDPTimeout function returns an option setter for the
+// timeout, in seconds, after which an idle UDP flow to the service is
+// forgotten by the load balancers, so that long lived pseudo sessions
+// stick to the same backend.
+func CreateOptionServiceUDPTimeout(timeout uint32) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcUDPTimeout = timeout
+	}
+}
+
+// CreateOptionServiceConnLimits function returns an option setter for the
+// overload protection of the service. Each backend accepts at most
+// maxConns concurrent connections from a load balancer, and every ingress
+// node accepts at most rate new connections per second to the service.
+// A zero value disables the corresponding limit.
+func CreateOptionServiceConnLimits(maxConns, rate uint32) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcMaxConns = maxConns
+		ep.svcConnRate = rate
+	}
+}
+
+// CreateOptionServiceGroup function returns an option setter for the
+// deployment group, e.g. blue or green, of the endpoint among the backends
+// of its service. The traffic of the service is switched between its
+// groups with SwitchServiceTraffic.
+func CreateOptionServiceGroup(group string) EndpointOption {
+	return func(ep *endpoint) {
+		ep.svcGroup = group
+	}
+}
+
+// CreateOptionServiceVIPv6 function returns an option setter for the IPv6
+// VIP of the service. The service is balanced on both the IPv4 and IPv6
+// VIPs, the latter across the backends having an IPv6 address.
+func CreateOptionServiceVIPv6(vip net.IP) EndpointOption {
+	return func(ep *endpoint) {
+		ep.virtualIPv6 = vip
+	}
+}
+
 //CreateOptionMyAlias function returns an option setter for setting endpoint's self alias
 func CreateOptionMyAlias(alias string) EndpointOption {
 	return func(ep *endpoint) {
@@ -960,16 +1289,35 @@ func (ep *endpoint) assignAddress(ipam ipamapi.Ipam, assignIPv4, assignIPv6 bool
 	log.Debugf("Assigning addresses for endpoint %s's interface on network %s", ep.Name(), n.Name())
 
 	if assignIPv4 {
+		if len(ep.prefSecondaryV6) > 0 && !n.enableIPv6 {
+			return types.BadRequestErrorf("secondary IPv6 addresses requested on network %s which is not IPv6 enabled", n.Name())
+		}
 		if err = ep.assignAddressVersion(4, ipam); err != nil {
 			return err
 		}
+		if err = ep.assignSecondaryAddresses(4, ipam); err != nil {
+			return err
+		}
 	}
 
 	if assignIPv6 {
-		err = ep.assignAddressVersion(6, ipam)
+		if err = ep.assignAddressVersion(6, ipam); err == nil {
+			if err = ep.assignSecondaryAddresses(6, ipam); err != nil {
+				ep.releaseAddressVersion(6, ipam)
+			}
+		}
+		if err != nil {
+			// do not leave the endpoint with its IPv4 addresses only
+			if assignIPv4 {
+				ep.releaseAddressVersion(4, ipam)
+			}
+			return err
+		}
 	}
 
-	return err
+	go n.getController().checkPoolUsage(n)
+
+	return nil
 }
 
 func (ep *endpoint) assignAddressVersion(ipVer int, ipam ipamapi.Ipam) error {
@@ -1001,6 +1349,23 @@ func (ep *endpoint) assignAddressVersion(ipVer int, ipam ipamapi.Ipam) error {
 		return nil
 	}
 
+	// the address bound to the name of the endpoint is reserved with the
+	// network
+	if ip := n.staticAddress(ep.Name(), ipVer); ip != nil {
+		if prefAdd != nil && !prefAdd.Equal(ip) {
+			return types.ForbiddenErrorf("address %s requested for endpoint %s differs from the address %s bound to its name", prefAdd, ep.Name(), ip)
+		}
+		d := n.poolOf(ip, ipVer)
+		if d == nil {
+			return types.InternalErrorf("address %s bound to %s does not belong to any pool of network %s", ip, ep.Name(), n.Name())
+		}
+		ep.Lock()
+		*address = &net.IPNet{IP: ip, Mask: d.Pool.Mask}
+		*poolID = d.PoolID
+		ep.Unlock()
+		return nil
+	}
+
 	// The address to program may be chosen by the user or by the network driver in one specific
 	// case to support backward compatibility with `docker daemon --fixed-cidrv6` use case
 	if prefAdd != nil {
@@ -1009,26 +1374,160 @@ func (ep *endpoint) assignAddressVersion(ipVer int, ipam ipamapi.Ipam) error {
 		progAdd = (*address).IP
 	}
 
-	for _, d := range ipInfo {
-		if progAdd != nil && !d.Pool.Contains(progAdd) {
-			continue
+	addr, pool, err := ep.requestAddress(ipVer, ipam, progAdd)
+	if err != nil {
+		return err
+	}
+
+	ep.Lock()
+	*address = addr
+	*poolID = pool
+	ep.Unlock()
+
+	n.auditAddress(AddressAllocated, AddressRoleEndpoint, pool, addr.IP, ep)
+
+	return nil
+}
+
+// assignSecondaryAddresses assigns the additional addresses of the passed
+// version to the endpoint interface. The addresses already assigned are
+// released on failure.
+func (ep *endpoint) assignSecondaryAddresses(ipVer int, ipam ipamapi.Ipam) error {
+	var (
+		prefs   []net.IP
+		address *[]*net.IPNet
+	)
+
+	switch ipVer {
+	case 4:
+		prefs = ep.prefSecondary
+		address = &ep.iface.secondaryAddrs
+	case 6:
+		prefs = ep.prefSecondaryV6
+		address = &ep.iface.secondaryAddrsv6
+	default:
+		return types.InternalErrorf("incorrect ip version number passed: %d", ipVer)
+	}
+
+	var assigned []*net.IPNet
+	for _, prefAdd := range prefs {
+		addr, pool, err := ep.requestAddress(ipVer, ipam, prefAdd)
+		if err != nil {
+			for _, a := range assigned {
+				ep.releaseSecondaryAddress(ipVer, ipam, a.IP)
+			}
+			return err
 		}
-		addr, _, err := ipam.RequestAddress(d.PoolID, progAdd, ep.ipamOptions)
-		if err == nil {
-			ep.Lock()
-			*address = addr
-			*poolID = d.PoolID
-			ep.Unlock()
-			return nil
+		ep.getNetwork().auditAddress(AddressAllocated, AddressRoleSecondary, pool, addr.IP, ep)
+		assigned = append(assigned, addr)
+	}
+
+	ep.Lock()
+	*address = assigned
+	ep.Unlock()
+
+	return nil
+}
+
+// addIpamLabels adds the names and the labels of the network and of the
+// endpoint to the options of its address requests
+func (ep *endpoint) addIpamLabels() {
+	n := ep.getNetwork()
+	opts := make(map[string]string, len(ep.ipamOptions)+2)
+	for k, v := range ep.ipamOptions {
+		opts[k] = v
+	}
+	opts[ipamapi.NetworkName] = n.Name()
+	opts[ipamapi.EndpointName] = ep.Name()
+	for k, v := range n.Labels() {
+		opts[ipamapi.NetworkLabelPrefix+k] = v
+	}
+	for k, v := range ep.labels {
+		opts[ipamapi.EndpointLabelPrefix+k] = v
+	}
+	ep.ipamOptions = opts
+}
+
+// requestAddress requests the passed address, or any when nil, from the
+// pools of the passed version of the endpoint network
+func (ep *endpoint) requestAddress(ipVer int, ipam ipamapi.Ipam, progAdd net.IP) (*net.IPNet, string, error) {
+	var err error
+	n := ep.getNetwork()
+	infos := n.getIPInfo(ipVer)
+	for len(infos) > 0 {
+		for _, d := range infos {
+			if progAdd != nil && !d.Pool.Contains(progAdd) {
+				continue
+			}
+			addr, _, err := ipam.RequestAddress(d.PoolID, progAdd, ep.ipamOptions)
+			if err == nil {
+				return addr, d.PoolID, nil
+			}
+			if err != ipamapi.ErrNoAvailableIPs || progAdd != nil {
+				return nil, "", err
+			}
 		}
-		if err != ipamapi.ErrNoAvailableIPs || progAdd != nil {
-			return err
+		if progAdd != nil || ipVer != 4 {
+			break
+		}
+		// all the pools are exhausted, the network may grow
+		if infos, err = n.expandPool(ipam); err != nil {
+			return nil, "", err
 		}
 	}
 	if progAdd != nil {
-		return types.BadRequestErrorf("Invalid address %s: It does not belong to any of this network's subnets", prefAdd)
+		return nil, "", types.BadRequestErrorf("Invalid address %s: It does not belong to any of this network's subnets", progAdd)
+	}
+	return nil, "", fmt.Errorf("no available IPv%d addresses on this network's address pools: %s (%s)", ipVer, n.Name(), n.ID())
+}
+
+// releaseSecondaryAddress releases the additional address to the pool
+// of the endpoint network it belongs to
+func (ep *endpoint) releaseSecondaryAddress(ipVer int, ipam ipamapi.Ipam, ip net.IP) {
+	n := ep.getNetwork()
+	for _, d := range n.getIPInfo(ipVer) {
+		if !d.Pool.Contains(ip) {
+			continue
+		}
+		if err := ipam.ReleaseAddress(d.PoolID, ip); err != nil {
+			log.Warnf("Failed to release secondary ip address %s of endpoint %s (%s): %v", ip, ep.Name(), ep.ID(), err)
+			return
+		}
+		n.auditAddress(AddressReleased, AddressRoleSecondary, d.PoolID, ip, ep)
+		return
+	}
+}
+
+// releaseAddressVersion releases the primary and the secondary addresses of
+// the passed version just assigned to the endpoint interface
+func (ep *endpoint) releaseAddressVersion(ipVer int, ipam ipamapi.Ipam) {
+	var (
+		poolID    string
+		address   **net.IPNet
+		secondary *[]*net.IPNet
+	)
+
+	ep.Lock()
+	switch ipVer {
+	case 4:
+		poolID, address, secondary = ep.iface.v4PoolID, &ep.iface.addr, &ep.iface.secondaryAddrs
+	case 6:
+		poolID, address, secondary = ep.iface.v6PoolID, &ep.iface.addrv6, &ep.iface.secondaryAddrsv6
+	}
+	addr, addrs := *address, *secondary
+	*address, *secondary = nil, nil
+	ep.Unlock()
+
+	if addr != nil && !ep.getNetwork().isStaticAddress(ep.Name(), addr.IP) {
+		if err := ipam.ReleaseAddress(poolID, addr.IP); err != nil {
+			log.Warnf("Failed to release ip address %s of endpoint %s (%s): %v", addr.IP, ep.Name(), ep.ID(), err)
+		} else {
+			ep.getNetwork().auditAddress(AddressReleased, AddressRoleEndpoint, poolID, addr.IP, ep)
+		}
+	}
+	for _, a := range addrs {
+		ep.releaseSecondaryAddress(ipVer, ipam, a.IP)
 	}
-	return fmt.Errorf("no available IPv%d addresses on this network's address pools: %s (%s)", ipVer, n.Name(), n.ID())
 }
 
 func (ep *endpoint) releaseAddress() {
@@ -1045,17 +1544,30 @@ func (ep *endpoint) releaseAddress() {
 		return
 	}
 
-	if ep.iface.addr != nil {
+	if ep.iface.addr != nil && !n.isStaticAddress(ep.Name(), ep.iface.addr.IP) {
 		if err := ipam.ReleaseAddress(ep.iface.v4PoolID, ep.iface.addr.IP); err != nil {
 			log.Warnf("Failed to release ip address %s on delete of endpoint %s (%s): %v", ep.iface.addr.IP, ep.Name(), ep.ID(), err)
+		} else {
+			n.auditAddress(AddressReleased, AddressRoleEndpoint, ep.iface.v4PoolID, ep.iface.addr.IP, ep)
 		}
 	}
 
-	if ep.iface.addrv6 != nil && ep.iface.addrv6.IP.IsGlobalUnicast() {
+	if ep.iface.addrv6 != nil && ep.iface.addrv6.IP.IsGlobalUnicast() && !n.isStaticAddress(ep.Name(), ep.iface.addrv6.IP) {
 		if err := ipam.ReleaseAddress(ep.iface.v6PoolID, ep.iface.addrv6.IP); err != nil {
 			log.Warnf("Failed to release ip address %s on delete of endpoint %s (%s): %v", ep.iface.addrv6.IP, ep.Name(), ep.ID(), err)
+		} else {
+			n.auditAddress(AddressReleased, AddressRoleEndpoint, ep.iface.v6PoolID, ep.iface.addrv6.IP, ep)
 		}
 	}
+
+	for _, addr := range ep.iface.secondaryAddrs {
+		ep.releaseSecondaryAddress(4, ipam, addr.IP)
+	}
+	for _, addr := range ep.iface.secondaryAddrsv6 {
+		ep.releaseSecondaryAddress(6, ipam, addr.IP)
+	}
+
+	go n.getController().checkPoolUsage(n)
 }
 
 func (c *controller) cleanupLocalEndpoints() {
@@ -167,3 +167,69 @@ func (ec *endpointCnt) IncEndpointCnt() error {
 func (ec *endpointCnt) DecEndpointCnt() error {
 	return ec.atomicIncDecEpCnt(false)
 }
+
+// incEndpointCntWith increments the count along with the creation of the
+// endpoint in the store, in a single transaction
+func (ec *endpointCnt) incEndpointCntWith(ep *endpoint) error {
+	return ec.atomicIncDecEpCntWith(datastore.TxnOp{Object: ep}, true)
+}
+
+// decEndpointCntWith decrements the count along with the deletion of the
+// endpoint from the store, in a single transaction
+func (ec *endpointCnt) decEndpointCntWith(ep *endpoint) error {
+	return ec.atomicIncDecEpCntWith(datastore.TxnOp{Object: ep, Delete: true}, false)
+}
+
+func (ec *endpointCnt) atomicIncDecEpCntWith(op datastore.TxnOp, inc bool) error {
+	store := ec.n.getController().getStore(ec.DataScope())
+	if store == nil {
+		return fmt.Errorf("store not found for scope %s", ec.DataScope())
+	}
+
+	for {
+		ec.Lock()
+		if inc {
+			ec.Count++
+		} else {
+			ec.Count--
+		}
+		index := ec.dbIndex
+		ec.Unlock()
+
+		err := ec.n.getController().updateTxnToStore(op, datastore.TxnOp{Object: ec})
+		if err == nil {
+			return nil
+		}
+
+		ec.Lock()
+		if inc {
+			ec.Count--
+		} else {
+			ec.Count++
+		}
+		ec.Unlock()
+		if err != datastore.ErrKeyModified {
+			return err
+		}
+
+		if err := store.GetObject(datastore.Key(ec.Key()...), ec); err != nil {
+			return fmt.Errorf("could not update the kvobject to latest when trying to atomic update endpoint count: %v", err)
+		}
+		if ec.Index() != index {
+			continue
+		}
+
+		// the endpoint is stale, which only its deletion retries with
+		// the latest one
+		if !op.Delete {
+			return err
+		}
+		opIndex := op.Object.Index()
+		if err := store.GetObject(datastore.Key(op.Object.Key()...), op.Object); err != nil {
+			return fmt.Errorf("could not update the kvobject to latest when trying to delete: %v", err)
+		}
+		if op.Object.Index() == opIndex {
+			return err
+		}
+	}
+}
@@ -43,17 +43,25 @@ type InterfaceInfo interface {
 
 	// AddressIPv6 returns the IPv6 address assigned to the endpoint.
 	AddressIPv6() *net.IPNet
+
+	// SecondaryAddresses returns the additional IPv4 addresses assigned to the endpoint.
+	SecondaryAddresses() []*net.IPNet
+
+	// SecondaryAddressesIPv6 returns the additional IPv6 addresses assigned to the endpoint.
+	SecondaryAddressesIPv6() []*net.IPNet
 }
 
 type endpointInterface struct {
-	mac       net.HardwareAddr
-	addr      *net.IPNet
-	addrv6    *net.IPNet
-	srcName   string
-	dstPrefix string
-	routes    []*net.IPNet
-	v4PoolID  string
-	v6PoolID  string
+	mac              net.HardwareAddr
+	addr             *net.IPNet
+	addrv6           *net.IPNet
+	secondaryAddrs   []*net.IPNet
+	secondaryAddrsv6 []*net.IPNet
+	srcName          string
+	dstPrefix        string
+	routes           []*net.IPNet
+	v4PoolID         string
+	v6PoolID         string
 }
 
 func (epi *endpointInterface) MarshalJSON() ([]byte, error) {
@@ -67,6 +75,12 @@ func (epi *endpointInterface) MarshalJSON() ([]byte, error) {
 	if epi.addrv6 != nil {
 		epMap["addrv6"] = epi.addrv6.String()
 	}
+	if len(epi.secondaryAddrs) > 0 {
+		epMap["secondaryAddrs"] = ipNetsToStrings(epi.secondaryAddrs)
+	}
+	if len(epi.secondaryAddrsv6) > 0 {
+		epMap["secondaryAddrsv6"] = ipNetsToStrings(epi.secondaryAddrsv6)
+	}
 	epMap["srcName"] = epi.srcName
 	epMap["dstPrefix"] = epi.dstPrefix
 	var routes []string
@@ -102,6 +116,16 @@ func (epi *endpointInterface) UnmarshalJSON(b []byte) error {
 			return types.InternalErrorf("failed to decode endpoint interface ipv6 address after json unmarshal: %v", err)
 		}
 	}
+	if v, ok := epMap["secondaryAddrs"]; ok {
+		if epi.secondaryAddrs, err = stringsToIPNets(v); err != nil {
+			return types.InternalErrorf("failed to decode endpoint interface secondary ipv4 addresses after json unmarshal: %v", err)
+		}
+	}
+	if v, ok := epMap["secondaryAddrsv6"]; ok {
+		if epi.secondaryAddrsv6, err = stringsToIPNets(v); err != nil {
+			return types.InternalErrorf("failed to decode endpoint interface secondary ipv6 addresses after json unmarshal: %v", err)
+		}
+	}
 
 	epi.srcName = epMap["srcName"].(string)
 	epi.dstPrefix = epMap["dstPrefix"].(string)
@@ -123,6 +147,34 @@ func (epi *endpointInterface) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+func ipNetsToStrings(addrs []*net.IPNet) []string {
+	s := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		s = append(s, addr.String())
+	}
+	return s
+}
+
+func stringsToIPNets(v interface{}) ([]*net.IPNet, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected address list %v", v)
+	}
+	addrs := make([]*net.IPNet, 0, len(list))
+	for _, a := range list {
+		s, ok := a.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected address %v", a)
+		}
+		addr, err := types.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
 func (epi *endpointInterface) CopyTo(dstEpi *endpointInterface) error {
 	dstEpi.mac = types.GetMacCopy(epi.mac)
 	dstEpi.addr = types.GetIPNetCopy(epi.addr)
@@ -131,6 +183,8 @@ func (epi *endpointInterface) CopyTo(dstEpi *endpointInterface) error {
 	dstEpi.dstPrefix = epi.dstPrefix
 	dstEpi.v4PoolID = epi.v4PoolID
 	dstEpi.v6PoolID = epi.v6PoolID
+	dstEpi.secondaryAddrs = copyIPNets(epi.secondaryAddrs)
+	dstEpi.secondaryAddrsv6 = copyIPNets(epi.secondaryAddrsv6)
 
 	for _, route := range epi.routes {
 		dstEpi.routes = append(dstEpi.routes, types.GetIPNetCopy(route))
@@ -266,6 +320,25 @@ func (epi *endpointInterface) AddressIPv6() *net.IPNet {
 	return types.GetIPNetCopy(epi.addrv6)
 }
 
+func (epi *endpointInterface) SecondaryAddresses() []*net.IPNet {
+	return copyIPNets(epi.secondaryAddrs)
+}
+
+func (epi *endpointInterface) SecondaryAddressesIPv6() []*net.IPNet {
+	return copyIPNets(epi.secondaryAddrsv6)
+}
+
+func copyIPNets(addrs []*net.IPNet) []*net.IPNet {
+	if addrs == nil {
+		return nil
+	}
+	c := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		c = append(c, types.GetIPNetCopy(addr))
+	}
+	return c
+}
+
 func (epi *endpointInterface) SetNames(srcName string, dstPrefix string) error {
 	epi.srcName = srcName
 	epi.dstPrefix = dstPrefix
@@ -2,6 +2,8 @@ package libnetwork
 
 import (
 	"fmt"
+	"net"
+	"strings"
 )
 
 // ErrNoSuchNetwork is returned when a network query finds no result
@@ -183,3 +185,42 @@ func (mr ManagerRedirectError) Error() string {
 
 // Maskable denotes the type of this error
 func (mr ManagerRedirectError) Maskable() {}
+
+// PoolConflict is a subnet of another network, or a route of the host,
+// a pool overlaps with
+type PoolConflict struct {
+	Subnet *net.IPNet
+	// NetworkID and NetworkName identify the network of the subnet, if any
+	NetworkID   string
+	NetworkName string
+	// Interface is the host interface of the route, if any
+	Interface string
+}
+
+func (pc PoolConflict) String() string {
+	if pc.NetworkID != "" {
+		return fmt.Sprintf("subnet %s of network %s (%s)", pc.Subnet, pc.NetworkName, pc.NetworkID)
+	}
+	if pc.Interface != "" {
+		return fmt.Sprintf("route %s on interface %s", pc.Subnet, pc.Interface)
+	}
+	return fmt.Sprintf("route %s", pc.Subnet)
+}
+
+// PoolConflictError is returned when the pool requested for a network
+// overlaps with the subnets of other networks or the routes of the host
+type PoolConflictError struct {
+	Pool      *net.IPNet
+	Conflicts []PoolConflict
+}
+
+func (pce *PoolConflictError) Error() string {
+	list := make([]string, 0, len(pce.Conflicts))
+	for _, c := range pce.Conflicts {
+		list = append(list, c.String())
+	}
+	return fmt.Sprintf("pool %s overlaps with %s", pce.Pool, strings.Join(list, ", "))
+}
+
+// Forbidden denotes the type of this error
+func (pce *PoolConflictError) Forbidden() {}
@@ -2,9 +2,11 @@ package ipam
 
 import (
 	"fmt"
+	"math/rand"
 	"net"
 	"sort"
 	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/bitseq"
@@ -12,6 +14,7 @@ import (
 	"github.com/docker/libnetwork/discoverapi"
 	"github.com/docker/libnetwork/ipamapi"
 	"github.com/docker/libnetwork/ipamutils"
+	"github.com/docker/libnetwork/netlabel"
 	"github.com/docker/libnetwork/types"
 )
 
@@ -34,6 +37,21 @@ type Allocator struct {
 	// stores        []datastore.Datastore
 	// Allocated addresses in each address space's subnet
 	addresses map[SubnetKey]*bitseq.Handle
+	// Slices of the global pools claimed by the node
+	slices map[SubnetKey][]*localSlice
+	node   string
+	// Ordinals the least recently used allocations resume from, by pool
+	cursors map[string]uint64
+	rnd     *rand.Rand
+	// Prefix delegated to the node the predefined IPv6 pools are carved
+	// from, and the length of the pools
+	delegated    *net.IPNet
+	delegatedLen int
+	// Quota policy of the address requests, the number of addresses of
+	// each tenant and the tenant of each address, by pool and address
+	quota      *ipamapi.QuotaPolicy
+	quotaUsage map[string]int
+	quotaOwner map[string]string
 	sync.Mutex
 }
 
@@ -49,6 +67,12 @@ func NewAllocator(lcDs, glDs datastore.DataStore) (*Allocator, error) {
 
 	// Initialize bitseq map
 	a.addresses = make(map[SubnetKey]*bitseq.Handle)
+	a.slices = make(map[SubnetKey][]*localSlice)
+	a.node = nodeName()
+	a.cursors = make(map[string]uint64)
+	a.quotaUsage = make(map[string]int)
+	a.quotaOwner = make(map[string]string)
+	a.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	// Initialize address spaces
 	a.addrSpaces = make(map[string]*addrSpace)
@@ -205,6 +229,14 @@ retry:
 		return "", nil, nil, types.InternalErrorf("failed to parse pool request for address space %q pool %q subpool %q: %v", addressSpace, pool, subPool, err)
 	}
 
+	po := &poolOptions{strategy: options[ipamapi.AllocationStrategy]}
+	if err := validateStrategy(po.strategy); err != nil {
+		return "", nil, nil, err
+	}
+	if po.excluded, err = parseExclusions(options[ipamapi.ExcludeAddresses], nw); err != nil {
+		return "", nil, nil, err
+	}
+
 	if err := a.refresh(addressSpace); err != nil {
 		return "", nil, nil, err
 	}
@@ -214,7 +246,11 @@ retry:
 		return "", nil, nil, err
 	}
 
-	insert, err := aSpace.updatePoolDBOnAdd(*k, nw, ipr, pdf)
+	if po.nodeSlice, err = parseNodeSlice(options[ipamapi.NodeSlice], nw, aSpace, subPool); err != nil {
+		return "", nil, nil, err
+	}
+
+	insert, err := aSpace.updatePoolDBOnAdd(*k, nw, ipr, pdf, po)
 	if err != nil {
 		if _, ok := err.(types.MaskableError); ok {
 			log.Debugf("Retrying predefined pool search: %v", err)
@@ -348,6 +384,29 @@ func (a *Allocator) insertBitMask(key SubnetKey, pool *net.IPNet) error {
 	return nil
 }
 
+// applyExclusions reserves the excluded ranges in the bitmask of the master
+// pool. The addresses already allocated stay reserved once released.
+func (a *Allocator) applyExclusions(key SubnetKey, pool *net.IPNet, excl []*ExcludedRange) error {
+	if len(excl) == 0 {
+		return nil
+	}
+	bm, err := a.retrieveBitmask(key, pool)
+	if err != nil {
+		return err
+	}
+	for _, r := range excl {
+		for o := r.Start; o <= r.End; o++ {
+			if bm.IsSet(o) {
+				continue
+			}
+			if err := bm.Set(o); err != nil && err != bitseq.ErrBitAllocated {
+				return types.InternalErrorf("failed to exclude %s from pool %s: %v", generateAddress(o, pool), key.String(), err)
+			}
+		}
+	}
+	return nil
+}
+
 func (a *Allocator) retrieveBitmask(k SubnetKey, n *net.IPNet) (*bitseq.Handle, error) {
 	a.Lock()
 	bm, ok := a.addresses[k]
@@ -390,7 +449,14 @@ func (a *Allocator) getPredefinedPool(as string, ipV6 bool) (*net.IPNet, error)
 		return nil, err
 	}
 
-	for _, nw := range a.getPredefineds(as) {
+	predefined := a.getPredefineds(as)
+	if ipV6 && as == localAddressSpace {
+		if l := a.delegatedPools(); l != nil {
+			predefined = l
+		}
+	}
+
+	for _, nw := range predefined {
 		if v != getAddressVersion(nw.IP) {
 			continue
 		}
@@ -412,15 +478,38 @@ func (a *Allocator) getPredefinedPool(as string, ipV6 bool) (*net.IPNet, error)
 // RequestAddress returns an address from the specified pool ID
 func (a *Allocator) RequestAddress(poolID string, prefAddress net.IP, opts map[string]string) (*net.IPNet, map[string]string, error) {
 	log.Debugf("RequestAddress(%s, %v, %v)", poolID, prefAddress, opts)
+	tenant, err := a.chargeQuota(opts, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, data, err := a.requestAddress(poolID, prefAddress, opts)
+	if err != nil {
+		a.refundQuota(tenant, 1)
+		return nil, nil, err
+	}
+	a.recordQuota(tenant, poolID, addr.IP)
+	return addr, data, nil
+}
+
+func (a *Allocator) requestAddress(poolID string, prefAddress net.IP, opts map[string]string) (*net.IPNet, map[string]string, error) {
 	k := SubnetKey{}
 	if err := k.FromString(poolID); err != nil {
 		return nil, nil, types.BadRequestErrorf("invalid pool id: %s", poolID)
 	}
 
+	// the slices of the node serve the pool without refreshing it
+	if p := a.slicedPool(k); p != nil {
+		return a.requestSliceAddress(k, p, prefAddress, opts)
+	}
+
 	if err := a.refresh(k.AddressSpace); err != nil {
 		return nil, nil, err
 	}
 
+	if p := a.slicedPool(k); p != nil {
+		return a.requestSliceAddress(k, p, prefAddress, opts)
+	}
+
 	aSpace, err := a.getAddrSpace(k.AddressSpace)
 	if err != nil {
 		return nil, nil, err
@@ -443,14 +532,29 @@ func (a *Allocator) RequestAddress(poolID string, prefAddress net.IP, opts map[s
 		k = c.ParentKey
 		c, ok = aSpace.subnets[k]
 	}
+	excluded := false
+	if prefAddress != nil {
+		if o, err := hostOrdinal(prefAddress, c.Pool.Mask); err == nil {
+			excluded = c.isExcluded(o)
+		}
+	}
 	aSpace.Unlock()
 
+	// the gateway may be one of the excluded addresses the network
+	// infrastructure owns, the others are never handed out
+	if excluded {
+		if opts[ipamapi.RequestAddressType] == netlabel.Gateway {
+			return &net.IPNet{IP: prefAddress, Mask: p.Pool.Mask}, nil, nil
+		}
+		return nil, nil, types.ForbiddenErrorf("address %s is excluded from pool %s", prefAddress, poolID)
+	}
+
 	bm, err := a.retrieveBitmask(k, c.Pool)
 	if err != nil {
 		return nil, nil, types.InternalErrorf("could not find bitmask in datastore for %s on address %v request from pool %s: %v",
 			k.String(), prefAddress, poolID, err)
 	}
-	ip, err := a.getAddress(p.Pool, bm, prefAddress, p.Range)
+	ip, err := a.getAddress(p.Pool, bm, prefAddress, p.Range, p.Strategy, poolID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -458,18 +562,64 @@ func (a *Allocator) RequestAddress(poolID string, prefAddress net.IP, opts map[s
 	return &net.IPNet{IP: ip, Mask: p.Pool.Mask}, nil, nil
 }
 
+// RequestAddresses allocates an address from the IPv4 pool and one from
+// the IPv6 pool, or none of them
+func (a *Allocator) RequestAddresses(v4PoolID string, v4 net.IP, v6PoolID string, v6 net.IP, opts map[string]string) (*net.IPNet, *net.IPNet, map[string]string, error) {
+	log.Debugf("RequestAddresses(%s, %v, %s, %v, %v)", v4PoolID, v4, v6PoolID, v6, opts)
+	for _, id := range []string{v4PoolID, v6PoolID} {
+		k := SubnetKey{}
+		if err := k.FromString(id); err != nil {
+			return nil, nil, nil, types.BadRequestErrorf("invalid pool id: %s", id)
+		}
+	}
+
+	addr, _, err := a.RequestAddress(v4PoolID, v4, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	addrv6, _, err := a.RequestAddress(v6PoolID, v6, opts)
+	if err != nil {
+		if e := a.ReleaseAddress(v4PoolID, addr.IP); e != nil {
+			log.Warnf("Failed to release address %s from pool %s after the IPv6 allocation failure: %v", addr.IP, v4PoolID, e)
+		}
+		return nil, nil, nil, err
+	}
+
+	return addr, addrv6, nil, nil
+}
+
 // ReleaseAddress releases the address from the specified pool ID
 func (a *Allocator) ReleaseAddress(poolID string, address net.IP) error {
 	log.Debugf("ReleaseAddress(%s, %v)", poolID, address)
+	if err := a.releaseAddress(poolID, address); err != nil {
+		return err
+	}
+	a.releaseQuota(poolID, address)
+	return nil
+}
+
+func (a *Allocator) releaseAddress(poolID string, address net.IP) error {
 	k := SubnetKey{}
 	if err := k.FromString(poolID); err != nil {
 		return types.BadRequestErrorf("invalid pool id: %s", poolID)
 	}
 
+	if address == nil {
+		return types.BadRequestErrorf("invalid address: nil")
+	}
+
+	if p := a.slicedPool(k); p != nil {
+		return a.releaseSliceAddress(k, p, address)
+	}
+
 	if err := a.refresh(k.AddressSpace); err != nil {
 		return err
 	}
 
+	if p := a.slicedPool(k); p != nil {
+		return a.releaseSliceAddress(k, p, address)
+	}
+
 	aSpace, err := a.getAddrSpace(k.AddressSpace)
 	if err != nil {
 		return err
@@ -482,11 +632,6 @@ func (a *Allocator) ReleaseAddress(poolID string, address net.IP) error {
 		return types.NotFoundErrorf("cannot find address pool for poolID:%s", poolID)
 	}
 
-	if address == nil {
-		aSpace.Unlock()
-		return types.BadRequestErrorf("invalid address: nil")
-	}
-
 	if !p.Pool.Contains(address) {
 		aSpace.Unlock()
 		return ipamapi.ErrIPOutOfRange
@@ -506,6 +651,14 @@ func (a *Allocator) ReleaseAddress(poolID string, address net.IP) error {
 		return types.InternalErrorf("failed to release address %s: %v", address.String(), err)
 	}
 
+	// the excluded addresses stay reserved
+	aSpace.Lock()
+	excluded := c.isExcluded(ipToUint64(h))
+	aSpace.Unlock()
+	if excluded {
+		return nil
+	}
+
 	bm, err := a.retrieveBitmask(k, c.Pool)
 	if err != nil {
 		return types.InternalErrorf("could not find bitmask in datastore for %s on address %v release from pool %s: %v",
@@ -515,7 +668,7 @@ func (a *Allocator) ReleaseAddress(poolID string, address net.IP) error {
 	return bm.Unset(ipToUint64(h))
 }
 
-func (a *Allocator) getAddress(nw *net.IPNet, bitmask *bitseq.Handle, prefAddress net.IP, ipr *AddressRange) (net.IP, error) {
+func (a *Allocator) getAddress(nw *net.IPNet, bitmask *bitseq.Handle, prefAddress net.IP, ipr *AddressRange, strategy, poolID string) (net.IP, error) {
 	var (
 		ordinal uint64
 		err     error
@@ -528,7 +681,7 @@ func (a *Allocator) getAddress(nw *net.IPNet, bitmask *bitseq.Handle, prefAddres
 		return nil, ipamapi.ErrNoAvailableIPs
 	}
 	if ipr == nil && prefAddress == nil {
-		ordinal, err = bitmask.SetAny()
+		ordinal, err = a.setAny(bitmask, 0, bitmask.Bits()-1, strategy, poolID)
 	} else if prefAddress != nil {
 		hostPart, e := types.GetHostPartIP(prefAddress, base.Mask)
 		if e != nil {
@@ -537,7 +690,7 @@ func (a *Allocator) getAddress(nw *net.IPNet, bitmask *bitseq.Handle, prefAddres
 		ordinal = ipToUint64(types.GetMinimalIP(hostPart))
 		err = bitmask.Set(ordinal)
 	} else {
-		ordinal, err = bitmask.SetAnyInRange(ipr.Start, ipr.End)
+		ordinal, err = a.setAny(bitmask, ipr.Start, ipr.End, strategy, poolID)
 	}
 
 	switch err {
@@ -553,6 +706,70 @@ func (a *Allocator) getAddress(nw *net.IPNet, bitmask *bitseq.Handle, prefAddres
 	}
 }
 
+// validateStrategy verifies the allocation strategy is a known one
+func validateStrategy(strategy string) error {
+	switch strategy {
+	case "", ipamapi.StrategySequential, ipamapi.StrategyRandom, ipamapi.StrategyLRU:
+		return nil
+	}
+	return types.BadRequestErrorf("invalid allocation strategy %q, must be one of %s, %s or %s",
+		strategy, ipamapi.StrategySequential, ipamapi.StrategyRandom, ipamapi.StrategyLRU)
+}
+
+// setAny sets a free ordinal of the bitmask between start and end, picked
+// following the allocation strategy. The random and least recently used
+// strategies look for it from a random ordinal and from the one following
+// the last allocation of the pool respectively, wrapping around, so that
+// the addresses just released, which may still be in the ARP and
+// conntrack caches of the remote hosts, are not handed out again first.
+func (a *Allocator) setAny(bm *bitseq.Handle, start, end uint64, strategy, poolID string) (uint64, error) {
+	var from uint64
+	switch strategy {
+	case ipamapi.StrategyRandom:
+		a.Lock()
+		from = start + uint64(a.rnd.Int63())%(end-start+1)
+		a.Unlock()
+	case ipamapi.StrategyLRU:
+		a.Lock()
+		from = a.cursors[poolID]
+		a.Unlock()
+		if from < start || from > end {
+			from = start
+		}
+	default:
+		if start == 0 && end == bm.Bits()-1 {
+			return bm.SetAny()
+		}
+		return bm.SetAnyInRange(start, end)
+	}
+
+	o, err := setFirstInRange(bm, from, end)
+	if err == bitseq.ErrNoBitAvailable && from > start {
+		o, err = setFirstInRange(bm, start, from-1)
+	}
+	if err == nil && strategy == ipamapi.StrategyLRU {
+		a.Lock()
+		a.cursors[poolID] = o + 1
+		a.Unlock()
+	}
+	return o, err
+}
+
+// setFirstInRange sets the first unset ordinal between start and end
+func setFirstInRange(bm *bitseq.Handle, start, end uint64) (uint64, error) {
+	if start != end {
+		return bm.SetAnyInRange(start, end)
+	}
+	switch err := bm.Set(start); err {
+	case nil:
+		return start, nil
+	case bitseq.ErrBitAllocated:
+		return 0, bitseq.ErrNoBitAvailable
+	default:
+		return 0, err
+	}
+}
+
 // DumpDatabase dumps the internal info
 func (a *Allocator) DumpDatabase() string {
 	a.Lock()
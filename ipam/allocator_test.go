@@ -17,6 +17,7 @@ import (
 	"github.com/docker/libnetwork/datastore"
 	"github.com/docker/libnetwork/ipamapi"
 	"github.com/docker/libnetwork/ipamutils"
+	"github.com/docker/libnetwork/netlabel"
 	_ "github.com/docker/libnetwork/testutils"
 	"github.com/docker/libnetwork/types"
 )
@@ -566,6 +567,842 @@ func TestGetAddressSubPoolEqualPool(t *testing.T) {
 	}
 }
 
+func TestParseExclusions(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("192.168.1.0/24")
+	excl, err := parseExclusions("192.168.1.1-192.168.1.10, 192.168.1.248/29,192.168.1.100,10.0.0.1,fe80::1", nw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ExcludedRange{{1, 10}, {248, 255}, {100, 100}}
+	if len(excl) != len(expected) {
+		t.Fatalf("Unexpected exclusions: %v", excl)
+	}
+	for i, r := range excl {
+		if *r != expected[i] {
+			t.Fatalf("Unexpected exclusion %d: %v", i, *r)
+		}
+	}
+
+	for _, bad := range []string{"192.168.1.10-192.168.1.1", "192.168.1.250-192.168.2.10", "192.168.0.0/16", "192.168.1.x", "192.168.1.1-fe80::1"} {
+		if _, err := parseExclusions(bad, nw); err == nil {
+			t.Fatalf("Unexpected success parsing %s", bad)
+		}
+	}
+}
+
+func TestExcludedAddresses(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := map[string]string{ipamapi.ExcludeAddresses: "172.28.0.1-172.28.0.10,172.28.0.20"}
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.28.0.0/24", "", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ip, _, err := a.RequestAddress(pid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.IP.String() != "172.28.0.11" {
+		t.Fatalf("Expected the first address after the excluded range, got %s", ip)
+	}
+	if _, _, err := a.RequestAddress(pid, net.ParseIP("172.28.0.20"), nil); err == nil {
+		t.Fatal("Unexpected success requesting an excluded address")
+	}
+
+	// the gateway may be owned by the infrastructure
+	gwOpts := map[string]string{ipamapi.RequestAddressType: netlabel.Gateway}
+	gw, _, err := a.RequestAddress(pid, net.ParseIP("172.28.0.1"), gwOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ReleaseAddress(pid, gw.IP); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.RequestAddress(pid, net.ParseIP("172.28.0.1"), nil); err == nil {
+		t.Fatal("Unexpected success requesting a released excluded address")
+	}
+
+	// the exclusions of a subpool request reserve the addresses of the master pool
+	spid, _, _, err := a.RequestPool(localAddressSpace, "172.28.0.0/24", "172.28.0.0/28", map[string]string{ipamapi.ExcludeAddresses: "172.28.0.12"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip, _, err = a.RequestAddress(spid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.IP.String() != "172.28.0.13" {
+		t.Fatalf("Expected 172.28.0.13, got %s", ip)
+	}
+}
+
+func TestAllocationStrategy(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := a.RequestPool(localAddressSpace, "172.29.0.0/24", "", map[string]string{ipamapi.AllocationStrategy: "first"}, false); err == nil {
+		t.Fatal("Unexpected success for an invalid allocation strategy")
+	}
+
+	opts := map[string]string{ipamapi.AllocationStrategy: ipamapi.StrategyLRU}
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.29.0.0/24", "", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := a.RequestPool(localAddressSpace, "172.29.0.0/24", "", nil, false); err == nil {
+		t.Fatal("Unexpected success requesting the pool with another allocation strategy")
+	}
+
+	ip1, _, err := a.RequestAddress(pid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ReleaseAddress(pid, ip1.IP); err != nil {
+		t.Fatal(err)
+	}
+	ip2, _, err := a.RequestAddress(pid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip2.IP.Equal(ip1.IP) {
+		t.Fatalf("Unexpected reuse of the address %s just released", ip1)
+	}
+	if ip2.IP.String() != "172.29.0.2" {
+		t.Fatalf("Expected the address following the last allocated one, got %s", ip2)
+	}
+
+	// the released addresses are handed out once the pool wrapped around
+	for i := 0; i < 252; i++ {
+		if _, _, err := a.RequestAddress(pid, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ip, _, err := a.RequestAddress(pid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.IP.Equal(ip1.IP) {
+		t.Fatalf("Expected the released address %s after wrapping around, got %s", ip1, ip)
+	}
+	if _, _, err := a.RequestAddress(pid, nil, nil); err != ipamapi.ErrNoAvailableIPs {
+		t.Fatalf("Expected %v, got: %v", ipamapi.ErrNoAvailableIPs, err)
+	}
+
+	opts = map[string]string{ipamapi.AllocationStrategy: ipamapi.StrategyRandom}
+	pid, _, _, err = a.RequestPool(localAddressSpace, "172.30.0.0/28", "", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/28")
+	seen := make(map[string]bool)
+	for i := 0; i < 14; i++ {
+		ip, _, err := a.RequestAddress(pid, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !subnet.Contains(ip.IP) || ip.IP.Equal(subnet.IP) || ip.IP.String() == "172.30.0.15" {
+			t.Fatalf("Unexpected address %s allocated in %s", ip, subnet)
+		}
+		if seen[ip.IP.String()] {
+			t.Fatalf("Address %s allocated twice", ip)
+		}
+		seen[ip.IP.String()] = true
+	}
+	if _, _, err := a.RequestAddress(pid, nil, nil); err != ipamapi.ErrNoAvailableIPs {
+		t.Fatalf("Expected %v, got: %v", ipamapi.ErrNoAvailableIPs, err)
+	}
+}
+
+func TestRequestAddresses(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid4, _, _, err := a.RequestPool(localAddressSpace, "172.31.0.0/30", "", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid6, _, _, err := a.RequestPool(localAddressSpace, "2001:db8:1::/126", "", nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ip4, ip6, _, err := a.RequestAddresses(pid4, nil, pid6, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip4.IP.String() != "172.31.0.1" || ip6.IP.String() != "2001:db8:1::1" {
+		t.Fatalf("Unexpected addresses %s and %s", ip4, ip6)
+	}
+
+	// exhaust the IPv6 pool
+	for i := 0; i < 2; i++ {
+		if _, _, err := a.RequestAddress(pid6, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, _, err := a.RequestAddresses(pid4, nil, pid6, nil, nil); err != ipamapi.ErrNoAvailableIPs {
+		t.Fatalf("Expected %v, got: %v", ipamapi.ErrNoAvailableIPs, err)
+	}
+	// the IPv4 address was not left allocated
+	ip, _, err := a.RequestAddress(pid4, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.IP.String() != "172.31.0.2" {
+		t.Fatalf("Expected the IPv4 address released on failure, got %s", ip)
+	}
+
+	if _, _, _, err := a.RequestAddresses(pid4, nil, "invalid", nil, nil); err == nil {
+		t.Fatal("Unexpected success for an invalid pool id")
+	}
+}
+
+func TestAdjacentSubnets(t *testing.T) {
+	input := map[string][]string{
+		"10.0.0.0/24":    {"10.0.1.0/24", "9.255.255.0/24"},
+		"10.0.1.0/24":    {"10.0.0.0/24", "10.0.2.0/24"},
+		"0.0.0.0/16":     {"0.1.0.0/16"},
+		"255.255.0.0/16": {"255.254.0.0/16"},
+		"fd00::/64":      {"fd00:0:0:1::/64", "fcff:ffff:ffff:ffff::/64"},
+		"0.0.0.0/0":      nil,
+	}
+
+	for pool, expected := range input {
+		_, nw, _ := net.ParseCIDR(pool)
+		list := adjacentSubnets(nw)
+		if len(list) != len(expected) {
+			t.Fatalf("Unexpected subnets adjacent to %s: %v", pool, list)
+		}
+		for i, n := range list {
+			if n.String() != expected[i] {
+				t.Fatalf("Unexpected subnets adjacent to %s: %v", pool, list)
+			}
+		}
+	}
+}
+
+func TestExpandPool(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.25.1.0/24", "", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, _, _, err := a.RequestPool(localAddressSpace, "172.25.1.0/24", "172.25.1.0/25", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := a.ExpandPool(sid, nil); err == nil {
+		t.Fatal("Unexpected success expanding a sub pool")
+	}
+
+	// the supernet buddy is taken, the other neighbour is used
+	if _, _, _, err := a.RequestPool(localAddressSpace, "172.25.0.0/24", "", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	eid, nw, _, err := a.ExpandPool(pid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nw.String() != "172.25.2.0/24" {
+		t.Fatalf("Unexpected expansion pool %s", nw)
+	}
+	if _, _, err := a.RequestAddress(eid, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := a.ExpandPool(pid, nil); err != ipamapi.ErrNoAvailablePool {
+		t.Fatalf("Expected %v, got: %v", ipamapi.ErrNoAvailablePool, err)
+	}
+}
+
+func TestDelegatedPrefix(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, prefix, _ := net.ParseCIDR("2001:db8:0:100::/63")
+	_, v4, _ := net.ParseCIDR("10.0.0.0/8")
+	if err := a.SetDelegatedPrefix(v4, 24); err == nil {
+		t.Fatal("Unexpected success for an IPv4 delegated prefix")
+	}
+	if err := a.SetDelegatedPrefix(prefix, 48); err == nil {
+		t.Fatal("Unexpected success for subnets larger than the delegated prefix")
+	}
+	if err := a.SetDelegatedPrefix(prefix, 64); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"2001:db8:0:100::/64", "2001:db8:0:101::/64"} {
+		_, nw, _, err := a.RequestPool(localAddressSpace, "", "", nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nw.String() != expected {
+			t.Fatalf("Expected the pool %s carved from the delegated prefix, got %s", expected, nw)
+		}
+	}
+	if _, _, _, err := a.RequestPool(localAddressSpace, "", "", nil, true); err == nil {
+		t.Fatal("Unexpected success once the delegated prefix is exhausted")
+	}
+
+	// the IPv4 pools are not affected
+	if _, nw, _, err := a.RequestPool(localAddressSpace, "", "", nil, false); err != nil || nw.IP.To4() == nil {
+		t.Fatalf("Unexpected IPv4 pool %v (%v)", nw, err)
+	}
+}
+
+func TestNodeSlices(t *testing.T) {
+	ipamutils.InitNetworks()
+	tmp, err := ioutil.TempFile("", "libnetwork-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	gds, err := datastore.NewDataStore(datastore.GlobalScope, &datastore.ScopeCfg{
+		Client: datastore.ScopeClientCfg{
+			Provider: "boltdb",
+			Address:  defaultPrefix + tmp.Name(),
+			Config: &store.Config{
+				Bucket:            "libnetwork",
+				ConnectionTimeout: 3 * time.Second,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nodes []*Allocator
+	for _, name := range []string{"node1", "node2"} {
+		lds, err := randomLocalStore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := NewAllocator(lds, gds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.node = name
+		nodes = append(nodes, a)
+	}
+
+	if _, _, _, err := nodes[0].RequestPool(localAddressSpace, "10.50.0.0/24", "", map[string]string{ipamapi.NodeSlice: "26"}, false); err == nil {
+		t.Fatal("Unexpected success carving a local pool in node slices")
+	}
+	if _, _, _, err := nodes[0].RequestPool(globalAddressSpace, "10.50.0.0/24", "", map[string]string{ipamapi.NodeSlice: "24"}, false); err == nil {
+		t.Fatal("Unexpected success with a node slice as big as the pool")
+	}
+
+	opts := map[string]string{ipamapi.NodeSlice: "/26", ipamapi.ExcludeAddresses: "10.50.0.1"}
+	pid, _, _, err := nodes[0].RequestPool(globalAddressSpace, "10.50.0.0/24", "", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slice := func(ip *net.IPNet) int { return int(ip.IP.To4()[3]) / 64 }
+	ip1, _, err := nodes[0].RequestAddress(pid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip2, _, err := nodes[1].RequestAddress(pid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slice(ip1) == slice(ip2) {
+		t.Fatalf("Expected the nodes to allocate from disjoint slices, got %s and %s", ip1, ip2)
+	}
+	if ip1.IP.String() == "10.50.0.1" || ip1.IP.String() == "10.50.0.0" {
+		t.Fatalf("Unexpected reserved address %s", ip1)
+	}
+
+	// a preferred address in the slice of the other node is refused
+	other := generateAddress(uint64(slice(ip2)*64+10), ip2)
+	if _, _, err := nodes[0].RequestAddress(pid, other, nil); err == nil {
+		t.Fatalf("Unexpected success requesting %s of the slice of the other node", other)
+	}
+
+	// the node claims another slice when its slices are full
+	seen := map[int]bool{slice(ip1): true}
+	for i := 0; i < 70; i++ {
+		ip, _, err := nodes[0].RequestAddress(pid, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slice(ip) == slice(ip2) {
+			t.Fatalf("Address %s allocated in the slice of the other node", ip)
+		}
+		seen[slice(ip)] = true
+	}
+	if len(seen) != 2 {
+		t.Fatalf("Expected the node to claim a second slice, got %v", seen)
+	}
+
+	if err := nodes[0].ReleaseAddress(pid, ip1.IP); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].ReleaseAddress(pid, ip2.IP); err == nil {
+		t.Fatal("Unexpected success releasing an address of the slice of the other node")
+	}
+	if _, _, err := nodes[0].RequestAddress(pid, ip1.IP, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nodes[0].ReleasePool(pid); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := nodes[0].listClaims(SubnetKey{AddressSpace: globalAddressSpace, Subnet: "10.50.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 0 {
+		t.Fatalf("Expected the slice claims to be released with the pool, got %d", len(claims))
+	}
+}
+
+func TestNodeSlicesUsage(t *testing.T) {
+	ipamutils.InitNetworks()
+	tmp, err := ioutil.TempFile("", "libnetwork-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	gds, err := datastore.NewDataStore(datastore.GlobalScope, &datastore.ScopeCfg{
+		Client: datastore.ScopeClientCfg{
+			Provider: "boltdb",
+			Address:  defaultPrefix + tmp.Name(),
+			Config: &store.Config{
+				Bucket:            "libnetwork",
+				ConnectionTimeout: 3 * time.Second,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nodes []*Allocator
+	for _, name := range []string{"node1", "node2"} {
+		lds, err := randomLocalStore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := NewAllocator(lds, gds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.node = name
+		nodes = append(nodes, a)
+	}
+
+	pid, _, _, err := nodes[0].RequestPool(globalAddressSpace, "10.60.0.0/24", "", map[string]string{ipamapi.NodeSlice: "26"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := nodes[0].RequestAddress(pid, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := nodes[1].RequestAddress(pid, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the slice of the other node counts as allocated, less its network
+	// or broadcast address
+	k := SubnetKey{AddressSpace: globalAddressSpace, Subnet: "10.60.0.0/24"}
+	otherSlice := func(i int) uint64 {
+		other := nodes[1-i].slices[k][0].index
+		if other == 0 || other == 3 {
+			return 63
+		}
+		return 64
+	}
+	for i, own := range []uint64{3, 1} {
+		expected := own + otherSlice(i)
+		u, err := nodes[i].PoolUsage(pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Size != 254 || u.Allocated != expected {
+			t.Fatalf("Unexpected usage of the pool seen from node %d: %+v", i+1, u)
+		}
+	}
+}
+
+func TestPoolUsage(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := map[string]string{ipamapi.ExcludeAddresses: "172.29.0.0/30,172.29.0.10"}
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.29.0.0/27", "", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := a.PoolUsage(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 32 addresses less the excluded .0-.3 and .10, and the broadcast one
+	if u.Size != 26 || u.Allocated != 0 || u.Free() != 26 {
+		t.Fatalf("Unexpected usage of the empty pool: %+v", u)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, _, err := a.RequestAddress(pid, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u, err = a.PoolUsage(pid); err != nil {
+		t.Fatal(err)
+	}
+	if u.Size != 26 || u.Allocated != 5 {
+		t.Fatalf("Unexpected usage of the pool: %+v", u)
+	}
+
+	// the usage of a sub pool is the one of its master pool
+	spid, _, _, err := a.RequestPool(localAddressSpace, "172.29.0.0/27", "172.29.0.16/28", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.RequestAddress(spid, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if u, err = a.PoolUsage(spid); err != nil {
+		t.Fatal(err)
+	}
+	if u.Size != 26 || u.Allocated != 6 {
+		t.Fatalf("Unexpected usage of the sub pool: %+v", u)
+	}
+
+	if _, err := a.PoolUsage("LocalDefault/10.99.0.0/16"); err == nil {
+		t.Fatal("Unexpected success getting the usage of an unknown pool")
+	}
+}
+
+func TestAllocatedAddresses(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := map[string]string{ipamapi.ExcludeAddresses: "172.26.0.1-172.26.0.4"}
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.26.0.0/24", "", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, _, _, err := a.RequestPool(localAddressSpace, "172.26.0.0/24", "172.26.0.128/25", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list, err := a.AllocatedAddresses(pid); err != nil || len(list) != 0 {
+		t.Fatalf("Expected no allocated address, got %v (%v)", list, err)
+	}
+
+	var expected []string
+	for i := 0; i < 2; i++ {
+		ip, _, err := a.RequestAddress(pid, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, ip.IP.String())
+	}
+	ip, _, err := a.RequestAddress(sid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, ip.IP.String())
+
+	list, err := a.AllocatedAddresses(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, list)
+	}
+	for i, ip := range list {
+		if ip.String() != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, list)
+		}
+	}
+
+	list, err = a.AllocatedAddresses(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].String() != "172.26.0.128" {
+		t.Fatalf("Expected the address of the sub pool only, got %v", list)
+	}
+}
+
+func TestExportImportState(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := map[string]string{
+		ipamapi.ExcludeAddresses:   "172.27.0.1-172.27.0.4",
+		ipamapi.AllocationStrategy: ipamapi.StrategyLRU,
+	}
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.27.0.0/24", "", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, _, _, err := a.RequestPool(localAddressSpace, "172.27.0.0/24", "172.27.0.128/25", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := a.RequestPool(localAddressSpace, "172.27.0.0/24", "172.27.0.128/25", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	// a sub pool creating its master pool
+	oid, _, _, err := a.RequestPool(localAddressSpace, "172.28.0.0/24", "172.28.0.0/25", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected []string
+	for _, id := range []string{pid, pid, sid, oid} {
+		ip, _, err := a.RequestAddress(id, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, ip.IP.String())
+	}
+
+	state, err := a.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Pools) != 4 {
+		t.Fatalf("Expected 4 pools, got %d", len(state.Pools))
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var imported ipamapi.State
+	if err := json.Unmarshal(b, &imported); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ImportState(&imported); err == nil {
+		t.Fatal("Expected failure importing pools overlapping the existing ones")
+	}
+
+	a2, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a2.ImportState(&imported); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{pid, sid, oid} {
+		u1, err := a.PoolUsage(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u2, err := a2.PoolUsage(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *u1 != *u2 {
+			t.Fatalf("Expected usage %v of pool %s, got %v", u1, id, u2)
+		}
+	}
+	for i, id := range []string{pid, pid, sid, oid} {
+		if _, _, err := a2.RequestAddress(id, net.ParseIP(expected[i]), nil); err == nil {
+			t.Fatalf("Expected address %s to be allocated", expected[i])
+		}
+	}
+	if _, _, err := a2.RequestAddress(pid, net.ParseIP("172.27.0.2"), nil); err == nil {
+		t.Fatal("Expected the excluded addresses to be imported")
+	}
+
+	// the imported pools are released as many times as they were requested
+	for _, id := range []string{sid, sid, pid, oid} {
+		if err := a2.ReleasePool(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state, err = a2.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Pools) != 0 {
+		t.Fatalf("Expected no pool left, got %d", len(state.Pools))
+	}
+
+	// a failed import leaves nothing behind
+	imported.Pools[len(imported.Pools)-1].Addresses = []string{"10.0.0.1"}
+	imported.Pools[0].Addresses = []string{"172.27.0.10"}
+	if err := a2.ImportState(&imported); err == nil {
+		t.Fatal("Expected failure importing an address out of its pool")
+	}
+	if state, err = a2.ExportState(); err != nil || len(state.Pools) != 0 {
+		t.Fatalf("Expected no pool left after a failed import, got %v (%v)", state, err)
+	}
+}
+
+func TestQuotaPolicy(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetQuotaPolicy(&ipamapi.QuotaPolicy{}); err == nil {
+		t.Fatal("Expected failure for a policy without label")
+	}
+	policy := &ipamapi.QuotaPolicy{
+		Label:   "tenant",
+		Limits:  map[string]int{"blue": 2},
+		Default: 1,
+	}
+	if err := a.SetQuotaPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.29.0.0/24", "", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blue := map[string]string{ipamapi.NetworkLabelPrefix + "tenant": "blue"}
+	red := map[string]string{
+		ipamapi.NetworkLabelPrefix + "tenant":  "blue",
+		ipamapi.EndpointLabelPrefix + "tenant": "red",
+	}
+	var blueIPs []net.IP
+	for i := 0; i < 2; i++ {
+		ip, _, err := a.RequestAddress(pid, nil, blue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blueIPs = append(blueIPs, ip.IP)
+	}
+	_, _, err = a.RequestAddress(pid, nil, blue)
+	if qe, ok := err.(*ipamapi.QuotaExceededError); !ok || qe.Tenant != "blue" || qe.Limit != 2 {
+		t.Fatalf("Expected a quota exceeded error, got %v", err)
+	}
+	if _, ok := err.(types.ForbiddenError); !ok {
+		t.Fatalf("Expected a forbidden error, got %T", err)
+	}
+
+	// the endpoint label wins over the network one
+	if _, _, err := a.RequestAddress(pid, nil, red); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.RequestAddress(pid, nil, red); err == nil {
+		t.Fatal("Expected failure over the default quota")
+	}
+
+	// the requests without tenant are not limited
+	for i := 0; i < 3; i++ {
+		if _, _, err := a.RequestAddress(pid, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a failed request does not count
+	if _, _, err := a.RequestAddress(pid, blueIPs[0], map[string]string{ipamapi.NetworkLabelPrefix + "tenant": "green"}); err == nil {
+		t.Fatal("Expected failure requesting an allocated address")
+	}
+	if _, _, err := a.RequestAddress(pid, nil, map[string]string{ipamapi.NetworkLabelPrefix + "tenant": "green"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.ReleaseAddress(pid, blueIPs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.RequestAddress(pid, nil, blue); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.SetQuotaPolicy(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.RequestAddress(pid, nil, blue); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestAddressBulk(t *testing.T) {
+	a, err := getAllocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, _, _, err := a.RequestPool(localAddressSpace, "172.30.0.0/24", "", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.RequestAddress(pid, net.ParseIP("172.30.0.3"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := a.RequestAddressBulk(pid, 3, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"172.30.0.1/24", "172.30.0.2/24", "172.30.0.4/24"}
+	for i, addr := range list {
+		if addr.String() != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, list)
+		}
+	}
+
+	if _, _, err := a.RequestAddress(pid, net.ParseIP("172.30.0.7"), nil); err != nil {
+		t.Fatal(err)
+	}
+	list, err = a.RequestAddressBulk(pid, 3, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].IP.String() != "172.30.0.8" || list[2].IP.String() != "172.30.0.10" {
+		t.Fatalf("Unexpected consecutive addresses: %v", list)
+	}
+
+	// none is allocated on failure
+	if _, err := a.RequestAddressBulk(pid, 250, false, nil); err != ipamapi.ErrNoAvailableIPs {
+		t.Fatalf("Expected ErrNoAvailableIPs, got %v", err)
+	}
+	if _, _, err := a.RequestAddress(pid, net.ParseIP("172.30.0.5"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sid, _, _, err := a.RequestPool(localAddressSpace, "172.30.0.0/24", "172.30.0.128/25", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err = a.RequestAddressBulk(sid, 2, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list[0].IP.String() != "172.30.0.128" || list[1].IP.String() != "172.30.0.129" {
+		t.Fatalf("Unexpected addresses of the sub pool: %v", list)
+	}
+
+	if _, err := a.RequestAddressBulk(pid, 0, false, nil); err == nil {
+		t.Fatal("Expected failure for an empty request")
+	}
+}
+
 func TestRequestReleaseAddressFromSubPool(t *testing.T) {
 	a, err := getAllocator()
 	if err != nil {
@@ -900,7 +1737,7 @@ func assertGetAddress(t *testing.T, subnet string) {
 	start := time.Now()
 	run := 0
 	for err != ipamapi.ErrNoAvailableIPs {
-		_, err = a.getAddress(sub, bm, nil, nil)
+		_, err = a.getAddress(sub, bm, nil, nil, "", "")
 		run++
 	}
 	if printTime {
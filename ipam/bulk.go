@@ -0,0 +1,118 @@
+package ipam
+
+import (
+	"net"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/bitseq"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// RequestAddressBulk returns n addresses from the specified pool ID,
+// allocated with a single update of the bitmask in the datastore, and
+// consecutive ones if requested. The addresses are the lowest free ones
+// whatever the allocation strategy of the pool. None is allocated on
+// failure. The pools carved in node slices allocate them one at a time and
+// do not serve consecutive addresses.
+func (a *Allocator) RequestAddressBulk(poolID string, n int, consecutive bool, opts map[string]string) ([]*net.IPNet, error) {
+	log.Debugf("RequestAddressBulk(%s, %d, %t, %v)", poolID, n, consecutive, opts)
+	if n <= 0 {
+		return nil, types.BadRequestErrorf("invalid number of addresses: %d", n)
+	}
+
+	tenant, err := a.chargeQuota(opts, n)
+	if err != nil {
+		return nil, err
+	}
+	list, err := a.requestAddressBulk(poolID, n, consecutive, opts)
+	if err != nil {
+		a.refundQuota(tenant, n)
+		return nil, err
+	}
+	for _, addr := range list {
+		a.recordQuota(tenant, poolID, addr.IP)
+	}
+	return list, nil
+}
+
+func (a *Allocator) requestAddressBulk(poolID string, n int, consecutive bool, opts map[string]string) ([]*net.IPNet, error) {
+	k := SubnetKey{}
+	if err := k.FromString(poolID); err != nil {
+		return nil, types.BadRequestErrorf("invalid pool id: %s", poolID)
+	}
+
+	if err := a.refresh(k.AddressSpace); err != nil {
+		return nil, err
+	}
+
+	if p := a.slicedPool(k); p != nil {
+		if consecutive {
+			return nil, types.NotImplementedErrorf("pool %s carved in node slices does not serve consecutive addresses", poolID)
+		}
+		return a.requestSliceAddresses(k, p, n, opts)
+	}
+
+	aSpace, err := a.getAddrSpace(k.AddressSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	aSpace.Lock()
+	p, ok := aSpace.subnets[k]
+	if !ok {
+		aSpace.Unlock()
+		return nil, types.NotFoundErrorf("cannot find address pool for poolID:%s", poolID)
+	}
+	c := p
+	for c.Range != nil {
+		k = c.ParentKey
+		c = aSpace.subnets[k]
+	}
+	aSpace.Unlock()
+
+	bm, err := a.retrieveBitmask(k, c.Pool)
+	if err != nil {
+		return nil, types.InternalErrorf("could not find bitmask in datastore for %s on bulk request from pool %s: %v",
+			k.String(), poolID, err)
+	}
+
+	start, end := uint64(0), bm.Bits()-1
+	if p.Range != nil {
+		start, end = p.Range.Start, p.Range.End
+	}
+	ordinals, err := bm.SetAnyN(start, end, uint64(n), consecutive)
+	switch err {
+	case nil:
+	case bitseq.ErrNoBitAvailable:
+		return nil, ipamapi.ErrNoAvailableIPs
+	default:
+		return nil, err
+	}
+
+	base := types.GetIPNetCopy(p.Pool)
+	list := make([]*net.IPNet, 0, n)
+	for _, o := range ordinals {
+		list = append(list, &net.IPNet{IP: generateAddress(o, base), Mask: p.Pool.Mask})
+	}
+	return list, nil
+}
+
+// requestSliceAddresses allocates the addresses from the slices of the node
+// one at a time, releasing them on failure
+func (a *Allocator) requestSliceAddresses(k SubnetKey, p *PoolData, n int, opts map[string]string) ([]*net.IPNet, error) {
+	list := make([]*net.IPNet, 0, n)
+	for len(list) < n {
+		addr, _, err := a.requestSliceAddress(k, p, nil, opts)
+		if err != nil {
+			for _, r := range list {
+				if e := a.releaseSliceAddress(k, p, r.IP); e != nil {
+					log.Warnf("Failed to release address %s of pool %s after the bulk request failure: %v", r.IP, k.String(), e)
+				}
+			}
+			return nil, err
+		}
+		list = append(list, addr)
+	}
+	return list, nil
+}
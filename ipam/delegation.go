@@ -0,0 +1,63 @@
+package ipam
+
+import (
+	"math/big"
+	"net"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/types"
+)
+
+// maxDelegatedPools bounds the number of pools carved from a delegated
+// prefix the predefined pool search goes through
+const maxDelegatedPools = 1 << 12
+
+// SetDelegatedPrefix sets the IPv6 prefix delegated to the node the
+// predefined IPv6 pools of the local address space are carved from, as
+// subnets of the passed length. A nil prefix restores the predefined
+// pools. The pools already allocated are left untouched.
+func (a *Allocator) SetDelegatedPrefix(prefix *net.IPNet, subnetLen int) error {
+	log.Debugf("SetDelegatedPrefix(%v, %d)", prefix, subnetLen)
+	if prefix != nil {
+		ones, bits := prefix.Mask.Size()
+		if prefix.IP.To4() != nil || bits != 128 {
+			return types.BadRequestErrorf("delegated prefix %s is not an IPv6 prefix", prefix)
+		}
+		if subnetLen < ones || subnetLen > 126 {
+			return types.BadRequestErrorf("invalid length %d of the subnets carved from the delegated prefix %s", subnetLen, prefix)
+		}
+	}
+
+	a.Lock()
+	a.delegated = prefix
+	a.delegatedLen = subnetLen
+	a.Unlock()
+	return nil
+}
+
+// delegatedPools returns the pools carved from the delegated prefix, if
+// any
+func (a *Allocator) delegatedPools() []*net.IPNet {
+	a.Lock()
+	prefix, subnetLen := a.delegated, a.delegatedLen
+	a.Unlock()
+	if prefix == nil {
+		return nil
+	}
+
+	ones, _ := prefix.Mask.Size()
+	count := uint64(maxDelegatedPools)
+	if subnetLen-ones < 12 {
+		count = 1 << uint(subnetLen-ones)
+	}
+	base := new(big.Int).SetBytes(prefix.IP.To16())
+	step := new(big.Int).Lsh(big.NewInt(1), uint(128-subnetLen))
+	mask := net.CIDRMask(subnetLen, 128)
+
+	list := make([]*net.IPNet, 0, count)
+	for i := uint64(0); i < count; i++ {
+		n := new(big.Int).Add(base, new(big.Int).Mul(step, new(big.Int).SetUint64(i)))
+		list = append(list, &net.IPNet{IP: bigToIP(n, net.IPv6len), Mask: mask})
+	}
+	return list
+}
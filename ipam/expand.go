@@ -0,0 +1,92 @@
+package ipam
+
+import (
+	"math/big"
+	"net"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// ExpandPool requests the pool of the same size adjacent to the passed
+// one, trying first the one forming a supernet with it, then its other
+// neighbour
+func (a *Allocator) ExpandPool(poolID string, options map[string]string) (string, *net.IPNet, map[string]string, error) {
+	log.Debugf("ExpandPool(%s, %v)", poolID, options)
+	k := SubnetKey{}
+	if err := k.FromString(poolID); err != nil {
+		return "", nil, nil, types.BadRequestErrorf("invalid pool id: %s", poolID)
+	}
+	if k.ChildSubnet != "" {
+		return "", nil, nil, types.BadRequestErrorf("cannot expand the sub pool %s", poolID)
+	}
+
+	if err := a.refresh(k.AddressSpace); err != nil {
+		return "", nil, nil, err
+	}
+
+	aSpace, err := a.getAddrSpace(k.AddressSpace)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	aSpace.Lock()
+	p, ok := aSpace.subnets[k]
+	aSpace.Unlock()
+	if !ok {
+		return "", nil, nil, types.NotFoundErrorf("cannot find address pool for poolID:%s", poolID)
+	}
+
+	v6 := getAddressVersion(p.Pool.IP) == v6
+	for _, nw := range adjacentSubnets(p.Pool) {
+		aSpace.Lock()
+		used := aSpace.contains(k.AddressSpace, nw)
+		aSpace.Unlock()
+		if used {
+			continue
+		}
+		id, pool, meta, err := a.RequestPool(k.AddressSpace, nw.String(), "", options, v6)
+		if err == nil {
+			return id, pool, meta, nil
+		}
+		// lost the pool to a concurrent request
+		if _, ok := err.(types.ForbiddenError); !ok {
+			return "", nil, nil, err
+		}
+	}
+
+	return "", nil, nil, ipamapi.ErrNoAvailablePool
+}
+
+// adjacentSubnets returns the subnets of the size of the passed one which
+// are adjacent to it, the one they form a supernet with first
+func adjacentSubnets(nw *net.IPNet) []*net.IPNet {
+	ones, bits := nw.Mask.Size()
+	if ones == 0 {
+		return nil
+	}
+
+	ip := nw.IP.To4()
+	if ip == nil {
+		ip = nw.IP.To16()
+	}
+	base := new(big.Int).SetBytes(ip)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+
+	buddy := new(big.Int).Xor(base, step)
+	other := new(big.Int).Add(base, step)
+	if buddy.Cmp(base) > 0 {
+		other.Sub(base, step)
+	}
+
+	var list []*net.IPNet
+	for _, n := range []*big.Int{buddy, other} {
+		if n.Sign() < 0 || n.Cmp(limit) >= 0 {
+			continue
+		}
+		list = append(list, &net.IPNet{IP: bigToIP(n, len(ip)), Mask: nw.Mask})
+	}
+	return list
+}
@@ -0,0 +1,194 @@
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// ExportState returns the pools of the address spaces, the master pools
+// before the sub pools, along with the addresses allocated from them. The
+// addresses allocated from a sub pool are listed with its master pool,
+// the ones of a pool carved in node slices are the ones of the slices of
+// the node.
+func (a *Allocator) ExportState() (*ipamapi.State, error) {
+	a.Lock()
+	spaces := make([]string, 0, len(a.addrSpaces))
+	for as := range a.addrSpaces {
+		spaces = append(spaces, as)
+	}
+	a.Unlock()
+	sort.Strings(spaces)
+
+	state := &ipamapi.State{}
+	for _, as := range spaces {
+		if err := a.refresh(as); err != nil {
+			return nil, err
+		}
+		aSpace, err := a.getAddrSpace(as)
+		if err != nil {
+			return nil, err
+		}
+
+		var masters, subs []*ipamapi.PoolState
+		aSpace.Lock()
+		// the requests of a sub pool count as requests of its master pool
+		children := make(map[SubnetKey]int)
+		for _, p := range aSpace.subnets {
+			if p.Range != nil {
+				children[p.ParentKey] += p.RefCount
+			}
+		}
+		for k, p := range aSpace.subnets {
+			ps := &ipamapi.PoolState{
+				AddressSpace: k.AddressSpace,
+				Pool:         k.Subnet,
+				SubPool:      k.ChildSubnet,
+				V6:           getAddressVersion(p.Pool.IP) == v6,
+				Options:      map[string]string{},
+				Requests:     p.RefCount - children[k],
+			}
+			if p.Strategy != "" {
+				ps.Options[ipamapi.AllocationStrategy] = p.Strategy
+			}
+			mp := p
+			if p.Range != nil {
+				mp = aSpace.subnets[p.ParentKey]
+				subs = append(subs, ps)
+			} else {
+				if p.NodeSlice != 0 {
+					ps.Options[ipamapi.NodeSlice] = strconv.Itoa(p.NodeSlice)
+				}
+				masters = append(masters, ps)
+			}
+			// a sub pool may create its master pool on import
+			if mp != nil && len(mp.Excluded) > 0 {
+				ps.Options[ipamapi.ExcludeAddresses] = formatExclusions(mp)
+			}
+		}
+		aSpace.Unlock()
+
+		sort.Sort(byPool(masters))
+		sort.Sort(byPool(subs))
+		for _, ps := range masters {
+			k := SubnetKey{AddressSpace: ps.AddressSpace, Subnet: ps.Pool}
+			list, err := a.AllocatedAddresses(k.String())
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range list {
+				ps.Addresses = append(ps.Addresses, ip.String())
+			}
+		}
+		state.Pools = append(state.Pools, masters...)
+		state.Pools = append(state.Pools, subs...)
+	}
+
+	return state, nil
+}
+
+// ImportState requests the pools of the state as many times as they were
+// requested, then the addresses allocated from them, so the pool ids and
+// the addresses of the endpoints stay the same. The pools must not overlap
+// the existing ones. On failure the pools and addresses already imported
+// are released.
+func (a *Allocator) ImportState(state *ipamapi.State) (err error) {
+	log.Debugf("ImportState(%d pools)", len(state.Pools))
+
+	ids := make([]string, len(state.Pools))
+	for i, ps := range state.Pools {
+		_, nw, err := net.ParseCIDR(ps.Pool)
+		if err != nil {
+			return types.BadRequestErrorf("invalid pool %s: %v", ps.Pool, err)
+		}
+		if ps.Requests < 0 {
+			return types.BadRequestErrorf("invalid number of requests %d of pool %s", ps.Requests, ps.Pool)
+		}
+		if err := a.refresh(ps.AddressSpace); err != nil {
+			return err
+		}
+		aSpace, err := a.getAddrSpace(ps.AddressSpace)
+		if err != nil {
+			return err
+		}
+		aSpace.Lock()
+		used := aSpace.contains(ps.AddressSpace, nw)
+		aSpace.Unlock()
+		if used {
+			return types.ForbiddenErrorf("pool %s overlaps with an existing pool of address space %s", ps.Pool, ps.AddressSpace)
+		}
+		k := SubnetKey{AddressSpace: ps.AddressSpace, Subnet: nw.String(), ChildSubnet: ps.SubPool}
+		ids[i] = k.String()
+	}
+
+	var undo []func()
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}()
+
+	for _, ps := range state.Pools {
+		for i := 0; i < ps.Requests; i++ {
+			id, _, _, err := a.RequestPool(ps.AddressSpace, ps.Pool, ps.SubPool, ps.Options, ps.V6)
+			if err != nil {
+				return err
+			}
+			undo = append(undo, func() {
+				if err := a.ReleasePool(id); err != nil {
+					log.Warnf("Failed to release pool %s on import rollback: %v", id, err)
+				}
+			})
+		}
+	}
+
+	for i, ps := range state.Pools {
+		poolID := ids[i]
+		for _, s := range ps.Addresses {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return types.BadRequestErrorf("invalid address %s of pool %s", s, poolID)
+			}
+			if _, _, err := a.RequestAddress(poolID, ip, nil); err != nil {
+				return err
+			}
+			undo = append(undo, func() {
+				if err := a.ReleaseAddress(poolID, ip); err != nil {
+					log.Warnf("Failed to release address %s of pool %s on import rollback: %v", ip, poolID, err)
+				}
+			})
+		}
+	}
+
+	return nil
+}
+
+// formatExclusions returns the excluded ranges of the master pool in the
+// format of the exclusion pool option
+func formatExclusions(p *PoolData) string {
+	list := make([]string, 0, len(p.Excluded))
+	for _, r := range p.Excluded {
+		list = append(list, fmt.Sprintf("%s-%s", generateAddress(r.Start, p.Pool), generateAddress(r.End, p.Pool)))
+	}
+	return strings.Join(list, ",")
+}
+
+type byPool []*ipamapi.PoolState
+
+func (b byPool) Len() int      { return len(b) }
+func (b byPool) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byPool) Less(i, j int) bool {
+	if b[i].Pool != b[j].Pool {
+		return b[i].Pool < b[j].Pool
+	}
+	return b[i].SubPool < b[j].SubPool
+}
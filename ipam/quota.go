@@ -0,0 +1,100 @@
+package ipam
+
+import (
+	"net"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// SetQuotaPolicy sets the policy limiting the number of addresses
+// allocated to each tenant, nil removing it. The addresses are counted by
+// the node, from the time a policy with the same label was first set, the
+// requests without the tenant label aside.
+func (a *Allocator) SetQuotaPolicy(policy *ipamapi.QuotaPolicy) error {
+	log.Debugf("SetQuotaPolicy(%v)", policy)
+	if policy != nil {
+		if policy.Label == "" {
+			return types.BadRequestErrorf("quota policy without a tenant label")
+		}
+		if policy.Default < 0 {
+			return types.BadRequestErrorf("invalid default quota %d", policy.Default)
+		}
+		for t, l := range policy.Limits {
+			if l < 0 {
+				return types.BadRequestErrorf("invalid quota %d of tenant %s", l, t)
+			}
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	if policy == nil || a.quota == nil || a.quota.Label != policy.Label {
+		a.quotaUsage = make(map[string]int)
+		a.quotaOwner = make(map[string]string)
+	}
+	a.quota = policy
+	return nil
+}
+
+// chargeQuota counts the n addresses about to be requested against the
+// quota of the tenant of the request, if any, and returns the tenant
+func (a *Allocator) chargeQuota(opts map[string]string, n int) (string, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.quota == nil {
+		return "", nil
+	}
+	tenant := a.quota.Tenant(opts)
+	if tenant == "" {
+		return "", nil
+	}
+	if l := a.quota.Limit(tenant); l > 0 && a.quotaUsage[tenant]+n > l {
+		return "", &ipamapi.QuotaExceededError{Tenant: tenant, Limit: l}
+	}
+	a.quotaUsage[tenant] += n
+	return tenant, nil
+}
+
+// refundQuota uncounts the n addresses the request failed to allocate
+func (a *Allocator) refundQuota(tenant string, n int) {
+	if tenant == "" {
+		return
+	}
+	a.Lock()
+	a.uncount(tenant, n)
+	a.Unlock()
+}
+
+// recordQuota records the tenant the address was allocated to
+func (a *Allocator) recordQuota(tenant, poolID string, ip net.IP) {
+	if tenant == "" {
+		return
+	}
+	a.Lock()
+	a.quotaOwner[quotaKey(poolID, ip)] = tenant
+	a.Unlock()
+}
+
+// releaseQuota uncounts the released address from the quota of its
+// tenant, if any
+func (a *Allocator) releaseQuota(poolID string, ip net.IP) {
+	k := quotaKey(poolID, ip)
+	a.Lock()
+	if tenant, ok := a.quotaOwner[k]; ok {
+		delete(a.quotaOwner, k)
+		a.uncount(tenant, 1)
+	}
+	a.Unlock()
+}
+
+func (a *Allocator) uncount(tenant string, n int) {
+	if a.quotaUsage[tenant] -= n; a.quotaUsage[tenant] <= 0 {
+		delete(a.quotaUsage, tenant)
+	}
+}
+
+func quotaKey(poolID string, ip net.IP) string {
+	return poolID + "/" + ip.String()
+}
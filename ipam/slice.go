@@ -0,0 +1,452 @@
+package ipam
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/bitseq"
+	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/netlabel"
+	"github.com/docker/libnetwork/types"
+)
+
+const (
+	// datastore key of the node slice claims
+	dsSliceKey = "ipam/" + ipamapi.DefaultIPAM + "/slice"
+	// The most slices a pool is carved in
+	maxSliceBits = 16
+	// The biggest slice
+	maxSliceHostBits = 24
+)
+
+// A global pool requested with a node slice length is carved in slices of
+// that length. A node claims a slice in the global datastore the first
+// time it allocates from the pool, starting from the one its name hashes
+// to, and claims another one when its slices are full. The addresses of
+// its slices are allocated in bitmasks of the local datastore, without
+// the round trips to the global one the pool bitmask requires.
+
+// sliceClaim is the claim of a slice of a global pool by a node
+type sliceClaim struct {
+	PoolID   string
+	Slice    uint64
+	Node     string
+	dbIndex  uint64
+	dbExists bool
+}
+
+// localSlice is a slice of a global pool the node claimed
+type localSlice struct {
+	index uint64
+	// ordinal of the first address of the slice in the pool
+	start uint64
+	bm    *bitseq.Handle
+}
+
+// nodeName returns the name the node claims the slices under
+func nodeName() string {
+	name, err := os.Hostname()
+	if err != nil {
+		log.Warnf("Failed to get the node name of the ipam slice claims: %v", err)
+	}
+	return name
+}
+
+// parseNodeSlice parses the length of the node slices of the pool
+func parseNodeSlice(value string, nw *net.IPNet, aSpace *addrSpace, subPool string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	slice, err := strconv.Atoi(strings.TrimPrefix(value, "/"))
+	if err != nil {
+		return 0, types.BadRequestErrorf("invalid node slice length %q: %v", value, err)
+	}
+	if aSpace.DataScope() != datastore.GlobalScope || aSpace.store() == nil {
+		return 0, types.BadRequestErrorf("node slices require a pool of a global address space")
+	}
+	if subPool != "" {
+		return 0, types.BadRequestErrorf("node slices are not supported on sub pools")
+	}
+	ones, bits := nw.Mask.Size()
+	if slice <= ones || slice-ones > maxSliceBits {
+		return 0, types.BadRequestErrorf("node slice length %d must be between %d and %d for pool %s", slice, ones+1, ones+maxSliceBits, nw)
+	}
+	if bits-slice < 2 || bits-slice > maxSliceHostBits {
+		return 0, types.BadRequestErrorf("node slice length %d must be between %d and %d", slice, bits-maxSliceHostBits, bits-2)
+	}
+	return slice, nil
+}
+
+// slicedPool returns the master pool of the key if it is carved in node
+// slices, as last read from the datastore
+func (a *Allocator) slicedPool(k SubnetKey) *PoolData {
+	if k.ChildSubnet != "" {
+		return nil
+	}
+	a.Lock()
+	aSpace, ok := a.addrSpaces[k.AddressSpace]
+	a.Unlock()
+	if !ok {
+		return nil
+	}
+	aSpace.Lock()
+	defer aSpace.Unlock()
+	p, ok := aSpace.subnets[k]
+	if !ok || p.NodeSlice == 0 {
+		return nil
+	}
+	c := &PoolData{}
+	p.CopyTo(c)
+	return c
+}
+
+// sliceSize returns the number of addresses of the slices of the pool
+func (p *PoolData) sliceSize() uint64 {
+	_, bits := p.Pool.Mask.Size()
+	return uint64(1) << uint(bits-p.NodeSlice)
+}
+
+// numSlices returns the number of slices of the pool
+func (p *PoolData) numSlices() uint64 {
+	ones, _ := p.Pool.Mask.Size()
+	return uint64(1) << uint(p.NodeSlice-ones)
+}
+
+// requestSliceAddress allocates the address in the slices of the node
+func (a *Allocator) requestSliceAddress(k SubnetKey, p *PoolData, prefAddress net.IP, opts map[string]string) (*net.IPNet, map[string]string, error) {
+	if prefAddress != nil && !p.Pool.Contains(prefAddress) {
+		return nil, nil, ipamapi.ErrIPOutOfRange
+	}
+	slices, err := a.nodeSlices(k, p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if prefAddress != nil {
+		o, err := hostOrdinal(prefAddress, p.Pool.Mask)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.isExcluded(o) {
+			if opts[ipamapi.RequestAddressType] == netlabel.Gateway {
+				return &net.IPNet{IP: prefAddress, Mask: p.Pool.Mask}, nil, nil
+			}
+			return nil, nil, types.ForbiddenErrorf("address %s is excluded from pool %s", prefAddress, k.String())
+		}
+		s := findSlice(slices, o/p.sliceSize())
+		if s == nil {
+			if s, err = a.claimSlice(k, p, o/p.sliceSize()); err != nil {
+				return nil, nil, err
+			}
+		}
+		switch err := s.bm.Set(o - s.start); err {
+		case nil:
+			return &net.IPNet{IP: prefAddress, Mask: p.Pool.Mask}, nil, nil
+		case bitseq.ErrBitAllocated:
+			return nil, nil, ipamapi.ErrIPAlreadyAllocated
+		default:
+			return nil, nil, err
+		}
+	}
+
+	for {
+		for _, s := range slices {
+			if s.bm.Unselected() == 0 {
+				continue
+			}
+			o, err := a.setAny(s.bm, 0, s.bm.Bits()-1, p.Strategy, k.String()+"/slice/"+strconv.FormatUint(s.index, 10))
+			if err == nil {
+				return &net.IPNet{IP: generateAddress(s.start+o, p.Pool), Mask: p.Pool.Mask}, nil, nil
+			}
+			if err != bitseq.ErrNoBitAvailable {
+				return nil, nil, err
+			}
+		}
+		s, err := a.claimSlice(k, p, p.numSlices())
+		if err != nil {
+			return nil, nil, err
+		}
+		slices = []*localSlice{s}
+	}
+}
+
+// releaseSliceAddress releases the address of a slice of the node
+func (a *Allocator) releaseSliceAddress(k SubnetKey, p *PoolData, address net.IP) error {
+	if !p.Pool.Contains(address) {
+		return ipamapi.ErrIPOutOfRange
+	}
+	o, err := hostOrdinal(address, p.Pool.Mask)
+	if err != nil {
+		return err
+	}
+	// the excluded addresses stay reserved
+	if p.isExcluded(o) {
+		return nil
+	}
+	slices, err := a.nodeSlices(k, p)
+	if err != nil {
+		return err
+	}
+	s := findSlice(slices, o/p.sliceSize())
+	if s == nil {
+		return types.NotFoundErrorf("address %s is not in a slice of node %s", address, a.node)
+	}
+	return s.bm.Unset(o - s.start)
+}
+
+func findSlice(slices []*localSlice, index uint64) *localSlice {
+	for _, s := range slices {
+		if s.index == index {
+			return s
+		}
+	}
+	return nil
+}
+
+// nodeSlices returns the slices of the pool the node claimed, loading
+// the claims from the global datastore the first time
+func (a *Allocator) nodeSlices(k SubnetKey, p *PoolData) ([]*localSlice, error) {
+	a.Lock()
+	slices, ok := a.slices[k]
+	a.Unlock()
+	if ok {
+		return slices, nil
+	}
+
+	claims, err := a.listClaims(k)
+	if err != nil {
+		return nil, err
+	}
+	slices = []*localSlice{}
+	for _, c := range claims {
+		if c.Node != a.node {
+			continue
+		}
+		s, err := a.newLocalSlice(k, p, c.Slice, false)
+		if err != nil {
+			return nil, err
+		}
+		slices = append(slices, s)
+	}
+	a.Lock()
+	a.slices[k] = slices
+	a.Unlock()
+
+	return slices, nil
+}
+
+// claimSlice claims the slice of the index, or the first free one from
+// the slice the node name hashes to if the index is out of the pool
+func (a *Allocator) claimSlice(k SubnetKey, p *PoolData, index uint64) (*localSlice, error) {
+	claims, err := a.listClaims(k)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[uint64]string, len(claims))
+	for _, c := range claims {
+		owners[c.Slice] = c.Node
+	}
+
+	n := p.numSlices()
+	candidates := []uint64{index}
+	if index >= n {
+		h := fnv.New32a()
+		h.Write([]byte(a.node))
+		first := uint64(h.Sum32()) % n
+		candidates = candidates[:0]
+		for i := uint64(0); i < n; i++ {
+			candidates = append(candidates, (first+i)%n)
+		}
+	}
+
+	store := a.getStore(k.AddressSpace)
+	for _, i := range candidates {
+		if owner, ok := owners[i]; ok {
+			if index < n {
+				return nil, types.ForbiddenErrorf("slice %d of pool %s is claimed by node %s", i, k.String(), owner)
+			}
+			continue
+		}
+		c := &sliceClaim{PoolID: k.String(), Slice: i, Node: a.node}
+		if err := store.PutObjectAtomic(c); err != nil {
+			if err == datastore.ErrKeyModified && index >= n {
+				continue
+			}
+			return nil, types.InternalErrorf("failed to claim slice %d of pool %s: %v", i, k.String(), err)
+		}
+		s, err := a.newLocalSlice(k, p, i, true)
+		if err != nil {
+			return nil, err
+		}
+		a.Lock()
+		a.slices[k] = append(a.slices[k], s)
+		a.Unlock()
+		log.Debugf("Node %s claimed slice %d of pool %s", a.node, i, k.String())
+		return s, nil
+	}
+
+	return nil, ipamapi.ErrNoAvailableIPs
+}
+
+// newLocalSlice returns the bitmask of the slice in the local datastore.
+// The bitmask of a newly claimed slice starts empty.
+func (a *Allocator) newLocalSlice(k SubnetKey, p *PoolData, index uint64, fresh bool) (*localSlice, error) {
+	store := a.getStore(localAddressSpace)
+	id := k.String() + "/slice/" + strconv.FormatUint(index, 10)
+	size := p.sliceSize()
+	if fresh && store != nil {
+		if old, err := bitseq.NewHandle(dsDataKey, store, id, size); err == nil {
+			old.Destroy()
+		}
+	}
+	h, err := bitseq.NewHandle(dsDataKey, store, id, size)
+	if err != nil {
+		return nil, types.InternalErrorf("failed to create the bitmask of slice %d of pool %s: %v", index, k.String(), err)
+	}
+	s := &localSlice{index: index, start: index * size, bm: h}
+
+	// Reserve the network and broadcast addresses of the pool along with
+	// its excluded ranges
+	reserved := []*ExcludedRange{{Start: 0, End: 0}}
+	if getAddressVersion(p.Pool.IP) == v4 {
+		last := p.numSlices()*size - 1
+		reserved = append(reserved, &ExcludedRange{Start: last, End: last})
+	}
+	reserved = append(reserved, p.Excluded...)
+	for _, r := range reserved {
+		for o := r.Start; o <= r.End; o++ {
+			if o < s.start || o >= s.start+size || h.IsSet(o-s.start) {
+				continue
+			}
+			if err := h.Set(o - s.start); err != nil && err != bitseq.ErrBitAllocated {
+				return nil, err
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// listClaims returns the slice claims of the pool
+func (a *Allocator) listClaims(k SubnetKey) ([]*sliceClaim, error) {
+	store := a.getStore(k.AddressSpace)
+	if store == nil {
+		return nil, types.InternalErrorf("node slices of pool %s require a global datastore", k.String())
+	}
+	kvol, err := store.List(datastore.Key(dsSliceKey, k.String()), &sliceClaim{})
+	if err != nil && err != datastore.ErrKeyNotFound {
+		return nil, types.InternalErrorf("failed to get the slice claims of pool %s: %v", k.String(), err)
+	}
+	var claims []*sliceClaim
+	for _, kvo := range kvol {
+		if c := kvo.(*sliceClaim); c.PoolID == k.String() {
+			claims = append(claims, c)
+		}
+	}
+	return claims, nil
+}
+
+// releaseSlices drops the slice claims of the pool released
+func (a *Allocator) releaseSlices(k SubnetKey) {
+	a.Lock()
+	slices := a.slices[k]
+	delete(a.slices, k)
+	a.Unlock()
+	for _, s := range slices {
+		if err := s.bm.Destroy(); err != nil {
+			log.Warnf("Failed to destroy the bitmask of slice %d of pool %s: %v", s.index, k.String(), err)
+		}
+	}
+
+	claims, err := a.listClaims(k)
+	if err != nil {
+		log.Warnf("Failed to release the slices of pool %s: %v", k.String(), err)
+		return
+	}
+	store := a.getStore(k.AddressSpace)
+	for _, c := range claims {
+		if err := store.DeleteObjectAtomic(c); err != nil {
+			log.Warnf("Failed to release slice %d of pool %s: %v", c.Slice, k.String(), err)
+		}
+	}
+}
+
+func (c *sliceClaim) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"PoolID": c.PoolID,
+		"Slice":  c.Slice,
+		"Node":   c.Node,
+	})
+}
+
+func (c *sliceClaim) UnmarshalJSON(b []byte) error {
+	var t struct {
+		PoolID string
+		Slice  uint64
+		Node   string
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+	c.PoolID = t.PoolID
+	c.Slice = t.Slice
+	c.Node = t.Node
+	return nil
+}
+
+func (c *sliceClaim) Key() []string {
+	return []string{dsSliceKey, c.PoolID, strconv.FormatUint(c.Slice, 10)}
+}
+
+func (c *sliceClaim) KeyPrefix() []string {
+	return []string{dsSliceKey, c.PoolID}
+}
+
+func (c *sliceClaim) Value() []byte {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func (c *sliceClaim) SetValue(value []byte) error {
+	return json.Unmarshal(value, c)
+}
+
+func (c *sliceClaim) Index() uint64 {
+	return c.dbIndex
+}
+
+func (c *sliceClaim) SetIndex(index uint64) {
+	c.dbIndex = index
+	c.dbExists = true
+}
+
+func (c *sliceClaim) Exists() bool {
+	return c.dbExists
+}
+
+func (c *sliceClaim) Skip() bool {
+	return false
+}
+
+func (c *sliceClaim) New() datastore.KVObject {
+	return &sliceClaim{}
+}
+
+func (c *sliceClaim) CopyTo(o datastore.KVObject) error {
+	dst := o.(*sliceClaim)
+	*dst = *c
+	return nil
+}
+
+func (c *sliceClaim) DataScope() string {
+	return datastore.GlobalScope
+}
@@ -25,6 +25,20 @@ type PoolData struct {
 	Pool      *net.IPNet
 	Range     *AddressRange `json:",omitempty"`
 	RefCount  int
+	// ranges of a master pool reserved for the addresses owned by other
+	// systems
+	Excluded []*ExcludedRange `json:",omitempty"`
+	// length of the node slices a global master pool is carved in
+	NodeSlice int `json:",omitempty"`
+	// strategy the addresses of the pool are allocated with
+	Strategy string `json:",omitempty"`
+}
+
+// poolOptions are the options of a pool request
+type poolOptions struct {
+	excluded  []*ExcludedRange
+	nodeSlice int
+	strategy  string
 }
 
 // addrSpace contains the pool configurations for the address space
@@ -46,6 +60,17 @@ type AddressRange struct {
 	Start, End uint64
 }
 
+// ExcludedRange specifies first and last ip ordinal of a range
+// of addresses never handed out
+type ExcludedRange struct {
+	Start, End uint64
+}
+
+// contains returns whether the ordinal is in the range
+func (r *ExcludedRange) contains(ordinal uint64) bool {
+	return ordinal >= r.Start && ordinal <= r.End
+}
+
 // String returns the string form of the AddressRange object
 func (r *AddressRange) String() string {
 	return fmt.Sprintf("Sub: %s, range [%d, %d]", r.Sub, r.Start, r.End)
@@ -122,6 +147,15 @@ func (p *PoolData) MarshalJSON() ([]byte, error) {
 	if p.Range != nil {
 		m["Range"] = p.Range
 	}
+	if len(p.Excluded) > 0 {
+		m["Excluded"] = p.Excluded
+	}
+	if p.NodeSlice != 0 {
+		m["NodeSlice"] = p.NodeSlice
+	}
+	if p.Strategy != "" {
+		m["Strategy"] = p.Strategy
+	}
 	return json.Marshal(m)
 }
 
@@ -134,6 +168,9 @@ func (p *PoolData) UnmarshalJSON(data []byte) error {
 			Pool      string
 			Range     *AddressRange `json:",omitempty"`
 			RefCount  int
+			Excluded  []*ExcludedRange `json:",omitempty"`
+			NodeSlice int              `json:",omitempty"`
+			Strategy  string           `json:",omitempty"`
 		}
 	)
 
@@ -144,6 +181,9 @@ func (p *PoolData) UnmarshalJSON(data []byte) error {
 	p.ParentKey = t.ParentKey
 	p.Range = t.Range
 	p.RefCount = t.RefCount
+	p.Excluded = t.Excluded
+	p.NodeSlice = t.NodeSlice
+	p.Strategy = t.Strategy
 	if t.Pool != "" {
 		if p.Pool, err = types.ParseCIDR(t.Pool); err != nil {
 			return err
@@ -220,6 +260,15 @@ func (p *PoolData) CopyTo(dstP *PoolData) error {
 	}
 
 	dstP.RefCount = p.RefCount
+	dstP.NodeSlice = p.NodeSlice
+	dstP.Strategy = p.Strategy
+
+	if p.Excluded != nil {
+		dstP.Excluded = make([]*ExcludedRange, 0, len(p.Excluded))
+		for _, r := range p.Excluded {
+			dstP.Excluded = append(dstP.Excluded, &ExcludedRange{Start: r.Start, End: r.End})
+		}
+	}
 	return nil
 }
 
@@ -257,7 +306,7 @@ func (aSpace *addrSpace) New() datastore.KVObject {
 	}
 }
 
-func (aSpace *addrSpace) updatePoolDBOnAdd(k SubnetKey, nw *net.IPNet, ipr *AddressRange, pdf bool) (func() error, error) {
+func (aSpace *addrSpace) updatePoolDBOnAdd(k SubnetKey, nw *net.IPNet, ipr *AddressRange, pdf bool, po *poolOptions) (func() error, error) {
 	aSpace.Lock()
 	defer aSpace.Unlock()
 
@@ -266,8 +315,20 @@ func (aSpace *addrSpace) updatePoolDBOnAdd(k SubnetKey, nw *net.IPNet, ipr *Addr
 		if pdf {
 			return nil, types.InternalMaskableErrorf("predefined pool %s is already reserved", nw)
 		}
+		if p.NodeSlice != po.nodeSlice {
+			return nil, types.ForbiddenErrorf("pool %s exists with node slice length %d", nw, p.NodeSlice)
+		}
+		if p.Strategy != po.strategy {
+			return nil, types.ForbiddenErrorf("pool %s exists with allocation strategy %q", nw, p.Strategy)
+		}
 		aSpace.incRefCount(p, 1)
-		return func() error { return nil }, nil
+		mk, mp := k, p
+		if p.Range != nil {
+			mk = p.ParentKey
+			mp = aSpace.subnets[mk]
+		}
+		added := mp.addExclusions(po.excluded)
+		return func() error { return aSpace.alloc.applyExclusions(mk, mp.Pool, added) }, nil
 	}
 
 	// If master pool, check for overlap
@@ -276,8 +337,13 @@ func (aSpace *addrSpace) updatePoolDBOnAdd(k SubnetKey, nw *net.IPNet, ipr *Addr
 			return nil, ipamapi.ErrPoolOverlap
 		}
 		// This is a new master pool, add it along with corresponding bitmask
-		aSpace.subnets[k] = &PoolData{Pool: nw, RefCount: 1}
-		return func() error { return aSpace.alloc.insertBitMask(k, nw) }, nil
+		aSpace.subnets[k] = &PoolData{Pool: nw, RefCount: 1, Excluded: po.excluded, NodeSlice: po.nodeSlice, Strategy: po.strategy}
+		return func() error {
+			if err := aSpace.alloc.insertBitMask(k, nw); err != nil {
+				return err
+			}
+			return aSpace.alloc.applyExclusions(k, nw, po.excluded)
+		}, nil
 	}
 
 	// This is a new non-master pool
@@ -286,19 +352,29 @@ func (aSpace *addrSpace) updatePoolDBOnAdd(k SubnetKey, nw *net.IPNet, ipr *Addr
 		Pool:      nw,
 		Range:     ipr,
 		RefCount:  1,
+		Strategy:  po.strategy,
 	}
-	aSpace.subnets[k] = p
 
 	// Look for parent pool
 	pp, ok := aSpace.subnets[p.ParentKey]
+	if ok && pp.NodeSlice != 0 {
+		return nil, types.ForbiddenErrorf("pool %s is carved in node slices", nw)
+	}
+	aSpace.subnets[k] = p
 	if ok {
 		aSpace.incRefCount(pp, 1)
-		return func() error { return nil }, nil
+		added := pp.addExclusions(po.excluded)
+		return func() error { return aSpace.alloc.applyExclusions(p.ParentKey, nw, added) }, nil
 	}
 
 	// Parent pool does not exist, add it along with corresponding bitmask
-	aSpace.subnets[p.ParentKey] = &PoolData{Pool: nw, RefCount: 1}
-	return func() error { return aSpace.alloc.insertBitMask(p.ParentKey, nw) }, nil
+	aSpace.subnets[p.ParentKey] = &PoolData{Pool: nw, RefCount: 1, Excluded: po.excluded}
+	return func() error {
+		if err := aSpace.alloc.insertBitMask(p.ParentKey, nw); err != nil {
+			return err
+		}
+		return aSpace.alloc.applyExclusions(p.ParentKey, nw, po.excluded)
+	}, nil
 }
 
 func (aSpace *addrSpace) updatePoolDBOnRemoval(k SubnetKey) (func() error, error) {
@@ -318,6 +394,9 @@ func (aSpace *addrSpace) updatePoolDBOnRemoval(k SubnetKey) (func() error, error
 			delete(aSpace.subnets, k)
 			if c.Range == nil {
 				return func() error {
+					if c.NodeSlice != 0 {
+						aSpace.alloc.releaseSlices(k)
+					}
 					bm, err := aSpace.alloc.retrieveBitmask(k, c.Pool)
 					if err != nil {
 						return types.InternalErrorf("could not find bitmask in datastore for pool %s removal: %v", k.String(), err)
@@ -333,6 +412,37 @@ func (aSpace *addrSpace) updatePoolDBOnRemoval(k SubnetKey) (func() error, error
 	return func() error { return nil }, nil
 }
 
+// addExclusions adds the excluded ranges to the master pool, returning the
+// ones it did not have
+func (p *PoolData) addExclusions(excl []*ExcludedRange) []*ExcludedRange {
+	var added []*ExcludedRange
+	for _, r := range excl {
+		found := false
+		for _, e := range p.Excluded {
+			if *e == *r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			p.Excluded = append(p.Excluded, r)
+			added = append(added, r)
+		}
+	}
+	return added
+}
+
+// isExcluded returns whether the address ordinal is in an excluded range
+// of the master pool
+func (p *PoolData) isExcluded(ordinal uint64) bool {
+	for _, r := range p.Excluded {
+		if r.contains(ordinal) {
+			return true
+		}
+	}
+	return false
+}
+
 func (aSpace *addrSpace) incRefCount(p *PoolData, delta int) {
 	c := p
 	ok := true
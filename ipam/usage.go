@@ -0,0 +1,231 @@
+package ipam
+
+import (
+	"net"
+	"sort"
+
+	"github.com/docker/libnetwork/bitseq"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// PoolUsage returns the address usage of the pool. The usage of a sub
+// pool is the one of its master pool, the addresses of both being
+// allocated in the same bitmask. The usage of a pool carved in node slices
+// is seen from the node, the slices claimed by the other nodes counting
+// as allocated.
+func (a *Allocator) PoolUsage(poolID string) (*ipamapi.PoolUsage, error) {
+	k := SubnetKey{}
+	if err := k.FromString(poolID); err != nil {
+		return nil, types.BadRequestErrorf("invalid pool id: %s", poolID)
+	}
+
+	if err := a.refresh(k.AddressSpace); err != nil {
+		return nil, err
+	}
+
+	if p := a.slicedPool(k); p != nil {
+		return a.sliceUsage(k, p)
+	}
+
+	aSpace, err := a.getAddrSpace(k.AddressSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	aSpace.Lock()
+	p, ok := aSpace.subnets[k]
+	if !ok {
+		aSpace.Unlock()
+		return nil, types.NotFoundErrorf("cannot find address pool for poolID:%s", poolID)
+	}
+	c := p
+	for c.Range != nil {
+		k = c.ParentKey
+		c = aSpace.subnets[k]
+	}
+	reserved := reservedRanges(c)
+	aSpace.Unlock()
+
+	bm, err := a.retrieveBitmask(k, c.Pool)
+	if err != nil {
+		return nil, types.InternalErrorf("could not find bitmask in datastore for %s on usage request of pool %s: %v",
+			k.String(), poolID, err)
+	}
+
+	size := bm.Bits() - rangesCount(reserved, 0, bm.Bits()-1)
+	return &ipamapi.PoolUsage{Size: size, Allocated: subFloor(size, bm.Unselected())}, nil
+}
+
+// sliceUsage returns the usage of the pool carved in node slices, the
+// free addresses being the ones of the slices of the node and of the
+// slices nobody claimed
+func (a *Allocator) sliceUsage(k SubnetKey, p *PoolData) (*ipamapi.PoolUsage, error) {
+	slices, err := a.nodeSlices(k, p)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := a.listClaims(k)
+	if err != nil {
+		return nil, err
+	}
+	claimed := make(map[uint64]bool, len(claims))
+	for _, c := range claims {
+		claimed[c.Slice] = true
+	}
+
+	reserved := reservedRanges(p)
+	total := p.numSlices() * p.sliceSize()
+	size := total - rangesCount(reserved, 0, total-1)
+
+	var free uint64
+	for _, s := range slices {
+		free += s.bm.Unselected()
+	}
+	for i := uint64(0); i < p.numSlices(); i++ {
+		if claimed[i] {
+			continue
+		}
+		start := i * p.sliceSize()
+		end := start + p.sliceSize() - 1
+		free += p.sliceSize() - rangesCount(reserved, start, end)
+	}
+
+	return &ipamapi.PoolUsage{Size: size, Allocated: subFloor(size, free)}, nil
+}
+
+// AllocatedAddresses returns the addresses allocated from the pool, the
+// network, broadcast and excluded addresses aside. The addresses of a sub
+// pool are the ones of its range allocated from the master pool, the ones
+// of a pool carved in node slices the ones of the slices of the node.
+func (a *Allocator) AllocatedAddresses(poolID string) ([]net.IP, error) {
+	k := SubnetKey{}
+	if err := k.FromString(poolID); err != nil {
+		return nil, types.BadRequestErrorf("invalid pool id: %s", poolID)
+	}
+
+	if err := a.refresh(k.AddressSpace); err != nil {
+		return nil, err
+	}
+
+	if p := a.slicedPool(k); p != nil {
+		slices, err := a.nodeSlices(k, p)
+		if err != nil {
+			return nil, err
+		}
+		reserved := reservedRanges(p)
+		var list []net.IP
+		for _, s := range slices {
+			list = append(list, walkAddresses(s.bm, p.Pool, s.start, reserved, nil)...)
+		}
+		return list, nil
+	}
+
+	aSpace, err := a.getAddrSpace(k.AddressSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	aSpace.Lock()
+	p, ok := aSpace.subnets[k]
+	if !ok {
+		aSpace.Unlock()
+		return nil, types.NotFoundErrorf("cannot find address pool for poolID:%s", poolID)
+	}
+	c := p
+	for c.Range != nil {
+		k = c.ParentKey
+		c = aSpace.subnets[k]
+	}
+	reserved := reservedRanges(c)
+	ipr := p.Range
+	aSpace.Unlock()
+
+	bm, err := a.retrieveBitmask(k, c.Pool)
+	if err != nil {
+		return nil, types.InternalErrorf("could not find bitmask in datastore for %s on allocation list of pool %s: %v",
+			k.String(), poolID, err)
+	}
+
+	return walkAddresses(bm, c.Pool, 0, reserved, ipr), nil
+}
+
+// walkAddresses returns the addresses of the pool of the bits set in the
+// bitmask, its first bit being the passed pool ordinal, which are not
+// reserved and are in the range if any
+func walkAddresses(bm *bitseq.Handle, pool *net.IPNet, start uint64, reserved []*ExcludedRange, ipr *AddressRange) []net.IP {
+	var list []net.IP
+	bm.WalkSet(func(o uint64) bool {
+		o += start
+		if ipr != nil && (o < ipr.Start || o > ipr.End) {
+			return false
+		}
+		if rangesCount(reserved, o, o) != 0 {
+			return false
+		}
+		list = append(list, generateAddress(o, pool))
+		return false
+	})
+	return list
+}
+
+// reservedRanges returns the sorted and merged ranges of the master pool
+// never allocated: its network and broadcast addresses along with its
+// excluded ranges
+func reservedRanges(p *PoolData) []*ExcludedRange {
+	ones, bits := p.Pool.Mask.Size()
+	list := []*ExcludedRange{{Start: 0, End: 0}}
+	if getAddressVersion(p.Pool.IP) == v4 {
+		last := uint64(1)<<uint(bits-ones) - 1
+		list = append(list, &ExcludedRange{Start: last, End: last})
+	}
+	for _, r := range p.Excluded {
+		list = append(list, &ExcludedRange{Start: r.Start, End: r.End})
+	}
+	sort.Sort(byStart(list))
+
+	merged := []*ExcludedRange{list[0]}
+	for _, r := range list[1:] {
+		last := merged[len(merged)-1]
+		if r.Start <= last.End+1 {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+type byStart []*ExcludedRange
+
+func (b byStart) Len() int           { return len(b) }
+func (b byStart) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byStart) Less(i, j int) bool { return b[i].Start < b[j].Start }
+
+// rangesCount returns the number of ordinals of the merged ranges between
+// start and end
+func rangesCount(ranges []*ExcludedRange, start, end uint64) uint64 {
+	var n uint64
+	for _, r := range ranges {
+		s, e := r.Start, r.End
+		if s < start {
+			s = start
+		}
+		if e > end {
+			e = end
+		}
+		if s <= e {
+			n += e - s + 1
+		}
+	}
+	return n
+}
+
+func subFloor(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
@@ -1,8 +1,11 @@
 package ipam
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
 	"net"
+	"strings"
 
 	"github.com/docker/libnetwork/ipamapi"
 	"github.com/docker/libnetwork/types"
@@ -36,6 +39,73 @@ func getAddressRange(pool string, masterNw *net.IPNet) (*AddressRange, error) {
 	return &AddressRange{nw, ipToUint64(types.GetMinimalIP(lIP)), ipToUint64(types.GetMinimalIP(hIP))}, nil
 }
 
+// parseExclusions parses the addresses, first-last ranges and subnets
+// excluded from the pool. The entries out of the pool are skipped, the
+// option being shared by the pools of the network.
+func parseExclusions(value string, nw *net.IPNet) ([]*ExcludedRange, error) {
+	var excl []*ExcludedRange
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var first, last net.IP
+		switch {
+		case strings.Contains(entry, "-"):
+			bounds := strings.SplitN(entry, "-", 2)
+			first = net.ParseIP(strings.TrimSpace(bounds[0]))
+			last = net.ParseIP(strings.TrimSpace(bounds[1]))
+		case strings.Contains(entry, "/"):
+			_, sub, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, types.BadRequestErrorf("invalid excluded subnet %s: %v", entry, err)
+			}
+			first = sub.IP
+			if last, err = types.GetBroadcastIP(sub.IP, sub.Mask); err != nil {
+				return nil, types.BadRequestErrorf("invalid excluded subnet %s: %v", entry, err)
+			}
+		default:
+			first = net.ParseIP(entry)
+			last = first
+		}
+		if first == nil || last == nil || getAddressVersion(first) != getAddressVersion(last) {
+			return nil, types.BadRequestErrorf("invalid excluded addresses %s", entry)
+		}
+		if bytes.Compare(first.To16(), last.To16()) > 0 {
+			return nil, types.BadRequestErrorf("invalid excluded range %s, the first address is greater than the last", entry)
+		}
+		if getAddressVersion(first) != getAddressVersion(nw.IP) {
+			continue
+		}
+		if !nw.Contains(first) || !nw.Contains(last) {
+			if nw.Contains(first) || nw.Contains(last) ||
+				(bytes.Compare(first.To16(), nw.IP.To16()) < 0 && bytes.Compare(last.To16(), nw.IP.To16()) > 0) {
+				return nil, types.BadRequestErrorf("excluded range %s overlaps the bounds of pool %s", entry, nw)
+			}
+			continue
+		}
+		start, err := hostOrdinal(first, nw.Mask)
+		if err != nil {
+			return nil, err
+		}
+		end, err := hostOrdinal(last, nw.Mask)
+		if err != nil {
+			return nil, err
+		}
+		excl = append(excl, &ExcludedRange{Start: start, End: end})
+	}
+	return excl, nil
+}
+
+// hostOrdinal returns the ordinal of the address in its subnet
+func hostOrdinal(ip net.IP, mask net.IPMask) (uint64, error) {
+	h, err := types.GetHostPartIP(ip, mask)
+	if err != nil {
+		return 0, types.InternalErrorf("failed to compute the host part of %s: %v", ip, err)
+	}
+	return ipToUint64(h), nil
+}
+
 // It generates the ip address in the passed subnet specified by
 // the passed host address ordinal
 func generateAddress(ordinal uint64, network *net.IPNet) net.IP {
@@ -79,3 +149,12 @@ func ipToUint64(ip []byte) (value uint64) {
 	}
 	return value
 }
+
+// bigToIP returns the IP address of the passed length the integer
+// represents
+func bigToIP(n *big.Int, length int) net.IP {
+	b := n.Bytes()
+	ip := make(net.IP, length)
+	copy(ip[length-len(b):], b)
+	return ip
+}
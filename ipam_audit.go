@@ -0,0 +1,80 @@
+package libnetwork
+
+import (
+	"net"
+	"time"
+)
+
+// Actions of the AddressAuditRecord
+const (
+	AddressAllocated = "allocated"
+	AddressReleased  = "released"
+)
+
+// Roles of the addresses of the AddressAuditRecord
+const (
+	AddressRoleEndpoint  = "endpoint"
+	AddressRoleSecondary = "secondary"
+	AddressRoleGateway   = "gateway"
+	AddressRoleAuxiliary = "auxiliary"
+	AddressRoleStatic    = "static"
+	AddressRoleVIP       = "vip"
+	AddressRoleOrphan    = "orphan"
+)
+
+// AddressAuditRecord describes an address allocated from or released to
+// the ipam pool of a network, along with who requested it
+type AddressAuditRecord struct {
+	Time        time.Time
+	Action      string
+	Role        string
+	NetworkID   string
+	NetworkName string
+	// Labels are the labels of the network
+	Labels  map[string]string
+	PoolID  string
+	Address net.IP
+	// EndpointID and EndpointName identify the endpoint owning an
+	// endpoint or secondary address
+	EndpointID   string
+	EndpointName string
+}
+
+// AddressAuditHook is invoked for every address allocated or released by
+// the controller, synchronously. It must neither block nor call back into
+// the controller.
+type AddressAuditHook func(*AddressAuditRecord)
+
+func (c *controller) SetAddressAuditHook(hook AddressAuditHook) {
+	c.Lock()
+	c.auditHook = hook
+	c.Unlock()
+}
+
+// auditAddress passes the address just allocated or released to the audit
+// hook, if any. The endpoint is nil for the addresses not owned by one.
+func (n *network) auditAddress(action, role, poolID string, ip net.IP, ep *endpoint) {
+	c := n.getController()
+	c.Lock()
+	hook := c.auditHook
+	c.Unlock()
+	if hook == nil || ip == nil {
+		return
+	}
+
+	r := &AddressAuditRecord{
+		Time:        time.Now(),
+		Action:      action,
+		Role:        role,
+		NetworkID:   n.ID(),
+		NetworkName: n.Name(),
+		Labels:      n.Labels(),
+		PoolID:      poolID,
+		Address:     ip,
+	}
+	if ep != nil {
+		r.EndpointID = ep.ID()
+		r.EndpointName = ep.Name()
+	}
+	hook(r)
+}
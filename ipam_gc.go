@@ -0,0 +1,194 @@
+package libnetwork
+
+import (
+	"net"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+	"github.com/gogo/protobuf/proto"
+)
+
+// OrphanedAddress is an address allocated from an ipam pool of a network
+// which no endpoint, gateway, auxiliary address or service uses, leaked by
+// a crash in the middle of an endpoint creation or deletion.
+type OrphanedAddress struct {
+	NetworkID   string
+	NetworkName string
+	PoolID      string
+	Address     net.IP
+	// Released is set when the pass released the address
+	Released bool
+}
+
+// ReconcileAddresses cross checks the addresses allocated from the pools
+// of the networks with the ones of the endpoints in the stores and in the
+// cluster endpoint table, and returns the orphaned ones. An address is
+// only released by the second pass it is found orphaned in, not to release
+// the addresses of the endpoints being created, which are stored after
+// their allocation. A dry run only reports the orphaned addresses.
+func (c *controller) ReconcileAddresses(dryRun bool) ([]*OrphanedAddress, error) {
+	c.orphansMu.Lock()
+	defer c.orphansMu.Unlock()
+
+	var (
+		list    []*OrphanedAddress
+		orphans = make(map[string]bool)
+	)
+	for _, nw := range c.Networks() {
+		n := nw.(*network)
+		if n.Type() == "host" || n.Type() == "null" {
+			continue
+		}
+		ipam, _, err := c.getIPAMDriver(n.ipamType)
+		if err != nil {
+			return nil, err
+		}
+		al, ok := ipam.(ipamapi.AllocationLister)
+		if !ok {
+			continue
+		}
+
+		inUse, err := n.addressesInUse()
+		if err != nil {
+			return nil, err
+		}
+
+		n.Lock()
+		infos := append(append([]*IpamInfo{}, n.ipamV4Info...), n.ipamV6Info...)
+		n.Unlock()
+
+		for _, i := range infos {
+			allocated, err := al.AllocatedAddresses(i.PoolID)
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range allocated {
+				if inUse[ip.String()] {
+					continue
+				}
+				o := &OrphanedAddress{NetworkID: n.ID(), NetworkName: n.Name(), PoolID: i.PoolID, Address: ip}
+				list = append(list, o)
+				if dryRun {
+					continue
+				}
+				key := n.ID() + "/" + i.PoolID + "/" + ip.String()
+				if !c.orphans[key] {
+					orphans[key] = true
+					continue
+				}
+				if err := ipam.ReleaseAddress(i.PoolID, ip); err != nil {
+					log.Warnf("Failed to release orphaned address %s of network %s: %v", ip, n.Name(), err)
+					continue
+				}
+				log.Infof("Released orphaned address %s of network %s", ip, n.Name())
+				n.auditAddress(AddressReleased, AddressRoleOrphan, i.PoolID, ip, nil)
+				o.Released = true
+			}
+		}
+		if !dryRun {
+			go c.checkPoolUsage(n)
+		}
+	}
+
+	if !dryRun {
+		c.orphans = orphans
+	}
+	return list, nil
+}
+
+// addressesInUse returns the addresses of the network used by its
+// gateways, auxiliary and statically bound addresses, its endpoints on any
+// node and its local services
+func (n *network) addressesInUse() (map[string]bool, error) {
+	inUse := make(map[string]bool)
+	add := func(ip net.IP) {
+		if ip != nil {
+			inUse[ip.String()] = true
+		}
+	}
+
+	n.Lock()
+	for _, i := range append(append([]*IpamInfo{}, n.ipamV4Info...), n.ipamV6Info...) {
+		if i.Gateway != nil {
+			add(i.Gateway.IP)
+		}
+		for _, aux := range i.AuxAddresses {
+			add(aux.IP)
+		}
+	}
+	n.Unlock()
+
+	for _, ipVer := range []int{4, 6} {
+		for _, ip := range n.staticAddresses(ipVer) {
+			add(ip)
+		}
+	}
+
+	epl, err := n.getEndpointsFromStore()
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range epl {
+		ep.Lock()
+		if ep.iface.addr != nil {
+			add(ep.iface.addr.IP)
+		}
+		if ep.iface.addrv6 != nil {
+			add(ep.iface.addrv6.IP)
+		}
+		for _, a := range append(append([]*net.IPNet{}, ep.iface.secondaryAddrs...), ep.iface.secondaryAddrsv6...) {
+			add(a.IP)
+		}
+		add(ep.virtualIP)
+		add(ep.virtualIPv6)
+		ep.Unlock()
+	}
+
+	c := n.getController()
+	if c.agent != nil && n.isClusterEligible() {
+		err := n.WalkTable("endpoint_table", func(key string, value []byte) bool {
+			var epRec EndpointRecord
+			if err := proto.Unmarshal(value, &epRec); err != nil {
+				log.Warnf("Failed to unmarshal endpoint %s of network %s: %v", key, n.Name(), err)
+				return false
+			}
+			add(net.ParseIP(epRec.EndpointIP))
+			add(net.ParseIP(epRec.EndpointIPv6))
+			add(net.ParseIP(epRec.VirtualIP))
+			add(net.ParseIP(epRec.VirtualIPv6))
+			return false
+		})
+		if err != nil {
+			return nil, types.InternalErrorf("failed to walk the endpoint table of network %s: %v", n.Name(), err)
+		}
+	}
+
+	c.Lock()
+	for _, ls := range c.localServices {
+		if ls.nid == n.ID() {
+			add(ls.vip)
+		}
+	}
+	c.Unlock()
+
+	return inUse, nil
+}
+
+// reconcileAddressesLoop periodically releases the orphaned addresses
+// until the stop channel is closed
+func (c *controller) reconcileAddressesLoop(interval time.Duration, stopCh chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := c.ReconcileAddresses(false); err != nil {
+				log.Warnf("Failed to reconcile the ipam allocations: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
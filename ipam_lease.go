@@ -0,0 +1,176 @@
+package libnetwork
+
+import (
+	"net"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/ipamapi"
+)
+
+// leaseTick is the period the leases due for renewal are checked at
+const leaseTick = time.Second
+
+// heldAddress is an address of a network the node holds a lease of
+type heldAddress struct {
+	poolID string
+	ip     net.IP
+}
+
+// renewLeasesLoop renews the leases of the addresses allocated by the
+// leasing ipam drivers until the stop channel is closed
+func (c *controller) renewLeasesLoop(stopCh chan struct{}) {
+	ticker := time.NewTicker(leaseTick)
+	defer ticker.Stop()
+	renewed := make(map[string]time.Time)
+	for {
+		select {
+		case <-ticker.C:
+			c.renewLeases(renewed)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// renewLeases renews the leases of the addresses the node holds on the
+// networks of the leasing ipam drivers whose renewal interval elapsed
+// since the time recorded for the network
+func (c *controller) renewLeases(renewed map[string]time.Time) {
+	leasing := false
+	c.drvRegistry.WalkIPAMs(func(name string, driver ipamapi.Ipam, cap *ipamapi.Capability) bool {
+		leasing = cap.LeaseRenewInterval > 0
+		return leasing
+	})
+	if !leasing {
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, nw := range c.Networks() {
+		n := nw.(*network)
+		if n.Type() == "host" || n.Type() == "null" {
+			continue
+		}
+		// not to load a missing plugin every tick
+		ipam, cap := c.drvRegistry.IPAM(n.ipamType)
+		if ipam == nil || cap.LeaseRenewInterval == 0 {
+			continue
+		}
+		lr, ok := ipam.(ipamapi.LeaseRenewer)
+		if !ok {
+			continue
+		}
+		seen[n.ID()] = true
+		if now.Sub(renewed[n.ID()]) < cap.LeaseRenewInterval {
+			continue
+		}
+		renewed[n.ID()] = now
+
+		held, err := n.heldAddresses()
+		if err != nil {
+			log.Warnf("Failed to list the addresses of network %s to renew: %v", n.Name(), err)
+			continue
+		}
+		for _, h := range held {
+			if err := lr.RenewAddress(h.poolID, h.ip); err != nil {
+				log.Warnf("Failed to renew the lease of address %s of network %s: %v", h.ip, n.Name(), err)
+			}
+		}
+	}
+
+	for nid := range renewed {
+		if !seen[nid] {
+			delete(renewed, nid)
+		}
+	}
+}
+
+// heldAddresses returns the addresses of the network the node holds: its
+// gateways, auxiliary and statically bound addresses, the addresses of its
+// endpoints on the node and the VIPs of its local services
+func (n *network) heldAddresses() ([]heldAddress, error) {
+	var held []heldAddress
+	add := func(ip net.IP) {
+		if ip == nil {
+			return
+		}
+		ipVer := 6
+		if ip.To4() != nil {
+			ipVer = 4
+		}
+		if d := n.poolOf(ip, ipVer); d != nil {
+			held = append(held, heldAddress{poolID: d.PoolID, ip: ip})
+		}
+	}
+
+	var ips []net.IP
+	n.Lock()
+	for _, i := range append(append([]*IpamInfo{}, n.ipamV4Info...), n.ipamV6Info...) {
+		if i.Gateway != nil {
+			ips = append(ips, i.Gateway.IP)
+		}
+		for _, aux := range i.AuxAddresses {
+			if aux != nil {
+				ips = append(ips, aux.IP)
+			}
+		}
+	}
+	n.Unlock()
+	for _, ip := range ips {
+		add(ip)
+	}
+
+	for _, ipVer := range []int{4, 6} {
+		for _, ip := range n.staticAddresses(ipVer) {
+			add(ip)
+		}
+	}
+
+	epl, err := n.getEndpointsFromStore()
+	if err != nil {
+		return nil, err
+	}
+	c := n.getController()
+	local := n.Scope() == datastore.LocalScope
+	for _, ep := range epl {
+		if !local && ep.locator != c.clusterHostID() {
+			continue
+		}
+		var addrs []net.IP
+		ep.Lock()
+		name := ep.name
+		if ep.iface.addr != nil {
+			addrs = append(addrs, ep.iface.addr.IP)
+		}
+		if ep.iface.addrv6 != nil {
+			addrs = append(addrs, ep.iface.addrv6.IP)
+		}
+		for _, a := range append(append([]*net.IPNet{}, ep.iface.secondaryAddrs...), ep.iface.secondaryAddrsv6...) {
+			addrs = append(addrs, a.IP)
+		}
+		ep.Unlock()
+		for _, ip := range addrs {
+			// the statically bound addresses are renewed with the network
+			if !n.isStaticAddress(name, ip) {
+				add(ip)
+			}
+		}
+	}
+
+	c.Lock()
+	var vips []net.IP
+	for _, ls := range c.localServices {
+		if ls.nid == n.ID() {
+			vips = append(vips, ls.vip)
+		}
+	}
+	c.Unlock()
+	for _, vip := range vips {
+		add(vip)
+	}
+
+	return held, nil
+}
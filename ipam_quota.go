@@ -0,0 +1,21 @@
+package libnetwork
+
+import (
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// SetIPAMQuotaPolicy sets the quota policy of the ipam driver. The tenant
+// of an address is the value of the label of the endpoint, or else of its
+// network, with the key of the policy.
+func (c *controller) SetIPAMQuotaPolicy(ipamName string, policy *ipamapi.QuotaPolicy) error {
+	ipam, _, err := c.getIPAMDriver(ipamName)
+	if err != nil {
+		return err
+	}
+	qe, ok := ipam.(ipamapi.QuotaEnforcer)
+	if !ok {
+		return types.NotImplementedErrorf("%s ipam driver does not enforce quotas", ipamName)
+	}
+	return qe.SetQuotaPolicy(policy)
+}
@@ -0,0 +1,36 @@
+package libnetwork
+
+import (
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+func (c *controller) stateExporter(ipamName string) (ipamapi.StateExporter, error) {
+	ipam, _, err := c.getIPAMDriver(ipamName)
+	if err != nil {
+		return nil, err
+	}
+	se, ok := ipam.(ipamapi.StateExporter)
+	if !ok {
+		return nil, types.NotImplementedErrorf("%s ipam driver does not export its state", ipamName)
+	}
+	return se, nil
+}
+
+func (c *controller) ExportIPAMState(ipamName string) (*ipamapi.State, error) {
+	se, err := c.stateExporter(ipamName)
+	if err != nil {
+		return nil, err
+	}
+	return se.ExportState()
+}
+
+// ImportIPAMState imports the state in the ipam driver. The imported pools
+// keep their ids, so the networks using them can be migrated as they are.
+func (c *controller) ImportIPAMState(ipamName string, state *ipamapi.State) error {
+	se, err := c.stateExporter(ipamName)
+	if err != nil {
+		return err
+	}
+	return se.ImportState(state)
+}
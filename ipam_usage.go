@@ -0,0 +1,152 @@
+package libnetwork
+
+import (
+	"net"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/go-events"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// Utilization percentages of the ipam pools notified when none is
+// configured
+var defaultPoolUsageThresholds = []int{80, 90, 100}
+
+// PoolUsage is the address usage of an ipam pool of a network
+type PoolUsage struct {
+	PoolID string
+	Pool   *net.IPNet
+	ipamapi.PoolUsage
+}
+
+// PoolUsageEvent is sent to the subscribers when the usage of an ipam
+// pool of a network rises to or falls below one of the configured
+// thresholds, before the exhaustion of the pool fails the endpoint
+// creations.
+type PoolUsageEvent struct {
+	NetworkID   string
+	NetworkName string
+	PoolID      string
+	Pool        *net.IPNet
+	// Threshold is the utilization percentage crossed, the highest one
+	// reached when Rising, the one fallen below otherwise
+	Threshold int
+	Rising    bool
+	Size      uint64
+	Allocated uint64
+}
+
+func (n *network) PoolUsage() ([]*PoolUsage, error) {
+	ipam, _, err := n.getController().getIPAMDriver(n.ipamType)
+	if err != nil {
+		return nil, err
+	}
+	ur, ok := ipam.(ipamapi.UsageReporter)
+	if !ok {
+		return nil, types.NotImplementedErrorf("%s ipam driver does not report the usage of the pools of network %s", n.ipamType, n.Name())
+	}
+
+	n.Lock()
+	infos := append(append([]*IpamInfo{}, n.ipamV4Info...), n.ipamV6Info...)
+	n.Unlock()
+
+	var list []*PoolUsage
+	for _, i := range infos {
+		u, err := ur.PoolUsage(i.PoolID)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &PoolUsage{PoolID: i.PoolID, Pool: i.Pool, PoolUsage: *u})
+	}
+	return list, nil
+}
+
+func (c *controller) SubscribePoolEvents() (chan events.Event, func()) {
+	return c.watchServiceEvents(events.MatcherFunc(func(ev events.Event) bool {
+		switch ev.(type) {
+		case PoolUsageEvent, DelegatedPrefixEvent:
+			return true
+		}
+		return false
+	}))
+}
+
+// checkPoolUsage notifies the thresholds the usage of the pools of the
+// network crossed since the last check
+func (c *controller) checkPoolUsage(n *network) {
+	if c.cfg == nil {
+		return
+	}
+	thresholds := c.cfg.Daemon.PoolUsageThresholds
+	if thresholds == nil {
+		thresholds = defaultPoolUsageThresholds
+	}
+	if len(thresholds) == 0 {
+		return
+	}
+
+	c.poolLevelsMu.Lock()
+	defer c.poolLevelsMu.Unlock()
+
+	usage, err := n.PoolUsage()
+	if err != nil {
+		if _, ok := err.(types.NotImplementedError); !ok {
+			log.Debugf("Failed to get the pool usage of network %s: %v", n.Name(), err)
+		}
+		return
+	}
+	for _, u := range usage {
+		var pct uint64
+		if u.Size > 0 {
+			pct = u.Allocated * 100 / u.Size
+		}
+		level := 0
+		for _, t := range thresholds {
+			if t > level && pct >= uint64(t) {
+				level = t
+			}
+		}
+
+		key := n.ID() + "/" + u.PoolID
+		last := c.poolLevels[key]
+		if level == last {
+			continue
+		}
+		if level == 0 {
+			delete(c.poolLevels, key)
+		} else {
+			c.poolLevels[key] = level
+		}
+
+		ev := PoolUsageEvent{
+			NetworkID:   n.ID(),
+			NetworkName: n.Name(),
+			PoolID:      u.PoolID,
+			Pool:        u.Pool,
+			Threshold:   level,
+			Rising:      level > last,
+			Size:        u.Size,
+			Allocated:   u.Allocated,
+		}
+		if !ev.Rising {
+			ev.Threshold = last
+		}
+		if ev.Rising {
+			log.Warnf("Address pool %s of network %s is %d%% used (%d of %d addresses)", u.Pool, n.Name(), pct, u.Allocated, u.Size)
+		}
+		c.svcBroadcaster.Write(ev)
+	}
+}
+
+// dropPoolLevels forgets the levels of the pools of the network released
+func (c *controller) dropPoolLevels(nid string) {
+	c.poolLevelsMu.Lock()
+	defer c.poolLevelsMu.Unlock()
+	for key := range c.poolLevels {
+		if strings.HasPrefix(key, nid+"/") {
+			delete(c.poolLevels, key)
+		}
+	}
+}
@@ -2,7 +2,9 @@
 package ipamapi
 
 import (
+	"fmt"
 	"net"
+	"time"
 
 	"github.com/docker/libnetwork/discoverapi"
 	"github.com/docker/libnetwork/types"
@@ -17,10 +19,47 @@ const (
 	DefaultIPAM = "default"
 	// NullIPAM is the name of the built-in null ipam driver
 	NullIPAM = "null"
+	// DHCPIPAM is the name of the built-in ipam driver leasing the
+	// addresses from an external DHCP server
+	DHCPIPAM = "dhcp"
 	// PluginEndpointType represents the Endpoint Type used by Plugin system
 	PluginEndpointType = "IpamDriver"
 	// RequestAddressType represents the Address Type used when requesting an address
 	RequestAddressType = "RequestAddressType"
+	// ExcludeAddresses is the pool option listing the addresses the ipam
+	// driver never hands out, as comma separated addresses, first-last
+	// ranges or subnets
+	ExcludeAddresses = "com.docker.network.ipam.exclude"
+	// NodeSlice is the pool option carving a global pool in slices of the
+	// prefix length, each node allocating the addresses of its slices
+	NodeSlice = "com.docker.network.ipam.node_slice"
+	// AllocationStrategy is the pool option selecting how the free
+	// addresses are picked
+	AllocationStrategy = "com.docker.network.ipam.strategy"
+	// StrategySequential allocates the lowest free address. This is the
+	// default.
+	StrategySequential = "sequential"
+	// StrategyRandom allocates a free address at random
+	StrategyRandom = "random"
+	// StrategyLRU allocates the free address following the last one
+	// allocated, the released addresses being reused once the others were
+	StrategyLRU = "lru"
+	// AutoExpand is the network ipam option setting the maximum number of
+	// subnets added to the network when its pools are exhausted, each one
+	// adjacent to an existing pool
+	AutoExpand = "com.docker.network.ipam.auto_expand"
+	// NetworkName is the address request option carrying the name of the
+	// network to the drivers requiring labels
+	NetworkName = "com.docker.network.ipam.network_name"
+	// EndpointName is the address request option carrying the name of the
+	// endpoint to the drivers requiring labels
+	EndpointName = "com.docker.network.ipam.endpoint_name"
+	// NetworkLabelPrefix prefixes the keys of the address request options
+	// carrying the labels of the network
+	NetworkLabelPrefix = "com.docker.network.ipam.network_label."
+	// EndpointLabelPrefix prefixes the keys of the address request options
+	// carrying the labels of the endpoint
+	EndpointLabelPrefix = "com.docker.network.ipam.endpoint_label."
 )
 
 // Callback provides a Callback interface for registering an IPAM instance into LibNetwork
@@ -86,4 +125,176 @@ type Capability struct {
 	// Whether of daemon start, libnetwork must replay the pool
 	// request and the address request for current local networks
 	RequiresRequestReplay bool
+	// Whether the driver allocates IPv6 pools and addresses
+	SupportsIPv6 bool
+	// Whether the driver honors the AllocationStrategy pool option
+	SupportsAllocationStrategy bool
+	// Whether on address request, libnetwork must pass the names and the
+	// labels of the network and of the endpoint in the options
+	RequiresLabels bool
+	// Interval at which libnetwork must renew the leases of the addresses
+	// the driver allocates, zero if it does not lease them
+	LeaseRenewInterval time.Duration
+}
+
+// PoolUsage is the address usage of a pool
+type PoolUsage struct {
+	// Size is the number of addresses of the pool which can be allocated
+	Size uint64
+	// Allocated is the number of addresses of the pool in use
+	Allocated uint64
+}
+
+// Free returns the number of addresses of the pool still available
+func (u *PoolUsage) Free() uint64 {
+	if u.Allocated > u.Size {
+		return 0
+	}
+	return u.Size - u.Allocated
+}
+
+// DualStackRequester is implemented by the IPAM drivers able to allocate
+// the IPv4 and the IPv6 address of an endpoint at once
+type DualStackRequester interface {
+	// RequestAddresses requests an address from each of the passed IPv4
+	// and IPv6 pools, with the same semantic as RequestAddress. Either
+	// both addresses are allocated or none is: the IPv4 address is
+	// released when the IPv6 one cannot be allocated.
+	RequestAddresses(v4PoolID string, v4 net.IP, v6PoolID string, v6 net.IP, options map[string]string) (*net.IPNet, *net.IPNet, map[string]string, error)
+}
+
+// PoolExpander is implemented by the IPAM drivers able to grow a pool with
+// an adjacent one
+type PoolExpander interface {
+	// ExpandPool requests a pool of the size of the one identified by the
+	// passed id and adjacent to it, forming a supernet with it when
+	// possible. It returns ErrNoAvailablePool when both neighbours are in
+	// use.
+	ExpandPool(poolID string, options map[string]string) (string, *net.IPNet, map[string]string, error)
+}
+
+// PrefixDelegate is implemented by the IPAM drivers able to carve the IPv6
+// pools they choose from a prefix delegated to the node
+type PrefixDelegate interface {
+	// SetDelegatedPrefix sets the prefix the IPv6 pools of the passed
+	// length are carved from, nil restoring the predefined pools
+	SetDelegatedPrefix(prefix *net.IPNet, subnetLen int) error
+}
+
+// AllocationLister is implemented by the IPAM drivers able to list the
+// addresses allocated from their pools
+type AllocationLister interface {
+	// AllocatedAddresses returns the addresses allocated from the pool
+	// identified by the passed id
+	AllocatedAddresses(poolID string) ([]net.IP, error)
+}
+
+// LeaseRenewer is implemented by the IPAM drivers leasing the addresses
+// they allocate, which reclaim the addresses whose lease was not renewed
+// in time, of the nodes gone without releasing them
+type LeaseRenewer interface {
+	// RenewAddress renews the lease of the address allocated from the
+	// pool identified by the passed id
+	RenewAddress(poolID string, address net.IP) error
+}
+
+// UsageReporter is implemented by the IPAM drivers reporting the address
+// usage of their pools
+type UsageReporter interface {
+	// PoolUsage returns the address usage of the pool identified by the
+	// passed id
+	PoolUsage(poolID string) (*PoolUsage, error)
+}
+
+// State is the portable document of the pools of an IPAM driver and of
+// the addresses allocated from them, which an IPAM driver backed by
+// another datastore can import
+type State struct {
+	Pools []*PoolState
+}
+
+// PoolState is a pool of the State along with the addresses allocated
+// from it. Importing it replays its requests.
+type PoolState struct {
+	AddressSpace string
+	Pool         string
+	SubPool      string            `json:",omitempty"`
+	V6           bool              `json:",omitempty"`
+	Options      map[string]string `json:",omitempty"`
+	// Requests is the number of times the pool was requested and not
+	// released
+	Requests  int
+	Addresses []string `json:",omitempty"`
+}
+
+// StateExporter is implemented by the IPAM drivers able to export their
+// pools and allocations to a State and to import them back
+type StateExporter interface {
+	// ExportState returns the pools of the driver and the addresses
+	// allocated from them
+	ExportState() (*State, error)
+	// ImportState requests the pools of the state and the addresses
+	// allocated from them, none of them if one fails
+	ImportState(state *State) error
+}
+
+// BulkRequester is implemented by the IPAM drivers able to allocate several
+// addresses of a pool at once
+type BulkRequester interface {
+	// RequestAddressBulk allocates n addresses from the pool identified by
+	// the passed id, consecutive ones if requested, none on failure
+	RequestAddressBulk(poolID string, n int, consecutive bool, opts map[string]string) ([]*net.IPNet, error)
+}
+
+// QuotaPolicy limits the number of addresses allocated to each tenant. The
+// tenant of an address request is the value of the endpoint label, or else
+// of the network label, with the Label key.
+type QuotaPolicy struct {
+	Label string
+	// Limits are the maximum numbers of addresses of the tenants, by
+	// tenant
+	Limits map[string]int
+	// Default is the maximum number of addresses of the tenants missing
+	// from Limits. Zero means no limit.
+	Default int
+}
+
+// Tenant returns the tenant of the address request with the passed
+// options, empty if the request carries no tenant label
+func (q *QuotaPolicy) Tenant(opts map[string]string) string {
+	if t, ok := opts[EndpointLabelPrefix+q.Label]; ok {
+		return t
+	}
+	return opts[NetworkLabelPrefix+q.Label]
+}
+
+// Limit returns the maximum number of addresses of the tenant, zero for no
+// limit
+func (q *QuotaPolicy) Limit(tenant string) int {
+	if l, ok := q.Limits[tenant]; ok {
+		return l
+	}
+	return q.Default
+}
+
+// QuotaExceededError is returned for the address requests exceeding the
+// quota of their tenant
+type QuotaExceededError struct {
+	Tenant string
+	Limit  int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("tenant %s exceeded its quota of %d addresses", e.Tenant, e.Limit)
+}
+
+// Forbidden denotes the type of this error
+func (e *QuotaExceededError) Forbidden() {}
+
+// QuotaEnforcer is implemented by the IPAM drivers able to enforce a
+// QuotaPolicy on the address requests
+type QuotaEnforcer interface {
+	// SetQuotaPolicy sets the quota policy of the address requests, nil
+	// removing it
+	SetQuotaPolicy(policy *QuotaPolicy) error
 }
@@ -37,7 +37,11 @@ func Init(ic ipamapi.Callback, l, g interface{}) error {
 		return err
 	}
 
-	cps := &ipamapi.Capability{RequiresRequestReplay: true}
+	cps := &ipamapi.Capability{
+		RequiresRequestReplay:      true,
+		SupportsIPv6:               true,
+		SupportsAllocationStrategy: true,
+	}
 
 	return ic.RegisterIpamDriverWithCapabilities(ipamapi.DefaultIPAM, a, cps)
 }
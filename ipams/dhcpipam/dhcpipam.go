@@ -0,0 +1,271 @@
+// Package dhcpipam implements the dhcp ipam driver, which leases the
+// addresses of the endpoints from an external DHCP server reached through
+// the parent interface of the network, and keeps the leases renewed.
+package dhcpipam
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/dhcp"
+	"github.com/docker/libnetwork/discoverapi"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/netlabel"
+	"github.com/docker/libnetwork/types"
+)
+
+const (
+	// ParentInterface is the ipam option of the interface the DHCP
+	// server is reached through
+	ParentInterface = "dhcp_interface"
+
+	defaultAS = "dhcp"
+)
+
+type pool struct {
+	id      string
+	ifName  string
+	subnet  *net.IPNet
+	gateway net.IP
+}
+
+type lease struct {
+	poolID string
+	mac    net.HardwareAddr
+	keeper *dhcp.Keeper
+}
+
+type allocator struct {
+	pools map[string]*pool
+	// leases of the endpoint addresses by address
+	leases map[string]*lease
+	// leases saved by the previous run of the daemon, by address
+	saved map[string]*leaseState
+	sync.Mutex
+}
+
+// Init registers the dhcp ipam driver with libnetwork
+func Init(ic ipamapi.Callback, l, g interface{}) error {
+	a := &allocator{
+		pools:  make(map[string]*pool),
+		leases: make(map[string]*lease),
+		saved:  make(map[string]*leaseState),
+	}
+	if err := a.loadState(); err != nil {
+		log.Warnf("dhcp ipam: failed to load the saved leases: %v", err)
+	}
+
+	cps := &ipamapi.Capability{RequiresMACAddress: true, RequiresRequestReplay: true}
+
+	return ic.RegisterIpamDriverWithCapabilities(ipamapi.DHCPIPAM, a, cps)
+}
+
+func (a *allocator) GetDefaultAddressSpaces() (string, string, error) {
+	return defaultAS, defaultAS, nil
+}
+
+// RequestPool returns the subnet of the DHCP server reached through the
+// parent interface. Without a requested pool, the subnet and the router
+// are learnt from a lease obtained and released right away.
+func (a *allocator) RequestPool(addressSpace, poolStr, subPool string, options map[string]string, v6 bool) (string, *net.IPNet, map[string]string, error) {
+	log.Debugf("RequestPool(%s, %s, %s, %v, %t)", addressSpace, poolStr, subPool, options, v6)
+	if addressSpace != defaultAS {
+		return "", nil, nil, types.BadRequestErrorf("unknown address space: %s", addressSpace)
+	}
+	if subPool != "" {
+		return "", nil, nil, types.BadRequestErrorf("dhcp ipam driver does not handle specific address subpool requests")
+	}
+	if v6 {
+		return "", nil, nil, types.BadRequestErrorf("dhcp ipam driver does not handle IPv6 address pool requests")
+	}
+	ifName := options[ParentInterface]
+	if ifName == "" {
+		return "", nil, nil, types.BadRequestErrorf("dhcp ipam driver requires the %s option", ParentInterface)
+	}
+	if err := checkInterface(ifName); err != nil {
+		return "", nil, nil, err
+	}
+
+	p := &pool{ifName: ifName}
+	if poolStr != "" {
+		_, subnet, err := net.ParseCIDR(poolStr)
+		if err != nil || subnet.IP.To4() == nil {
+			return "", nil, nil, ipamapi.ErrInvalidPool
+		}
+		p.subnet = subnet
+	} else {
+		l, err := probe(ifName)
+		if err != nil {
+			return "", nil, nil, types.InternalErrorf("failed to learn the subnet of the dhcp server on %s: %v", ifName, err)
+		}
+		p.subnet = &net.IPNet{IP: l.IP.IP.Mask(l.IP.Mask), Mask: l.IP.Mask}
+		p.gateway = l.Gateway
+	}
+	p.id = poolID(ifName, p.subnet)
+
+	a.Lock()
+	defer a.Unlock()
+	if _, ok := a.pools[p.id]; ok {
+		return "", nil, nil, ipamapi.ErrPoolOverlap
+	}
+	a.pools[p.id] = p
+
+	var meta map[string]string
+	if p.gateway != nil && p.subnet.Contains(p.gateway) {
+		meta = map[string]string{
+			netlabel.Gateway: (&net.IPNet{IP: p.gateway, Mask: p.subnet.Mask}).String(),
+		}
+	}
+	return p.id, p.subnet, meta, nil
+}
+
+func poolID(ifName string, subnet *net.IPNet) string {
+	return fmt.Sprintf("%s/%s/%s", defaultAS, ifName, subnet)
+}
+
+// ReleasePool releases the pool, the leases of its addresses being
+// released by then
+func (a *allocator) ReleasePool(poolID string) error {
+	log.Debugf("ReleasePool(%s)", poolID)
+	a.Lock()
+	defer a.Unlock()
+	if _, ok := a.pools[poolID]; !ok {
+		return ipamapi.ErrPoolNotFound
+	}
+	delete(a.pools, poolID)
+	return nil
+}
+
+func (a *allocator) getPool(poolID string) (*pool, error) {
+	a.Lock()
+	defer a.Unlock()
+	p, ok := a.pools[poolID]
+	if !ok {
+		return nil, ipamapi.ErrPoolNotFound
+	}
+	return p, nil
+}
+
+// RequestAddress leases an address for the mac address of the endpoint.
+// A requested address is asked to the server as a rebooting client does,
+// the lease saved for it by the previous run of the daemon being resumed
+// if it did not expire. The gateway is the router the server advertises,
+// unless requested.
+func (a *allocator) RequestAddress(poolID string, ip net.IP, opts map[string]string) (*net.IPNet, map[string]string, error) {
+	log.Debugf("RequestAddress(%s, %v, %v)", poolID, ip, opts)
+	p, err := a.getPool(poolID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ip != nil && !p.subnet.Contains(ip) {
+		return nil, nil, ipamapi.ErrIPOutOfRange
+	}
+
+	if opts[ipamapi.RequestAddressType] == netlabel.Gateway {
+		if ip == nil {
+			ip = p.gateway
+		}
+		if ip == nil {
+			return nil, nil, types.NoServiceErrorf("the dhcp server on %s advertises no router", p.ifName)
+		}
+		return &net.IPNet{IP: ip, Mask: p.subnet.Mask}, nil, nil
+	}
+
+	mac, err := net.ParseMAC(opts[netlabel.MacAddress])
+	if err != nil {
+		return nil, nil, types.BadRequestErrorf("dhcp ipam driver requires the mac address of the endpoint: %v", err)
+	}
+
+	var l *dhcp.Lease
+	if ip != nil {
+		l = a.resumeLease(poolID, ip, mac)
+		if l == nil {
+			if l, err = request(p.ifName, mac, ip); err != nil {
+				return nil, nil, types.InternalErrorf("failed to lease %s from the dhcp server on %s: %v", ip, p.ifName, err)
+			}
+		}
+	} else if l, err = acquire(p.ifName, mac); err != nil {
+		return nil, nil, types.InternalErrorf("failed to lease an address from the dhcp server on %s: %v", p.ifName, err)
+	}
+	if !p.subnet.Contains(l.IP.IP) {
+		release(p.ifName, mac, l)
+		return nil, nil, types.InternalErrorf("dhcp server on %s leased %s out of the pool %s", p.ifName, l.IP.IP, p.subnet)
+	}
+
+	key := l.IP.IP.String()
+	a.Lock()
+	if _, ok := a.leases[key]; ok {
+		a.Unlock()
+		release(p.ifName, mac, l)
+		return nil, nil, ipamapi.ErrIPAlreadyAllocated
+	}
+	a.leases[key] = &lease{
+		poolID: poolID,
+		mac:    mac,
+		keeper: dhcp.Keep(l, func(old *dhcp.Lease) (*dhcp.Lease, error) {
+			nl, err := renew(p.ifName, mac, old)
+			if err == nil {
+				a.saveState()
+			}
+			return nl, err
+		}),
+	}
+	a.Unlock()
+	a.saveState()
+	log.Debugf("dhcp ipam: leased %s to %s from the dhcp server %s", l.IP, mac, l.Server)
+
+	return &net.IPNet{IP: l.IP.IP, Mask: p.subnet.Mask}, nil, nil
+}
+
+// ReleaseAddress gives the lease of the address back to the server
+func (a *allocator) ReleaseAddress(poolID string, ip net.IP) error {
+	log.Debugf("ReleaseAddress(%s, %v)", poolID, ip)
+	p, err := a.getPool(poolID)
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	l, ok := a.leases[ip.String()]
+	if ok && l.poolID == poolID {
+		delete(a.leases, ip.String())
+	}
+	a.Unlock()
+	if !ok || l.poolID != poolID {
+		// the gateway is not leased
+		return nil
+	}
+
+	l.keeper.Stop()
+	if err := release(p.ifName, l.mac, l.keeper.Lease()); err != nil {
+		log.Warnf("dhcp ipam: failed to release the lease of %s: %v", ip, err)
+	}
+	a.saveState()
+	return nil
+}
+
+func (a *allocator) DiscoverNew(dType discoverapi.DiscoveryType, data interface{}) error {
+	return nil
+}
+
+func (a *allocator) DiscoverDelete(dType discoverapi.DiscoveryType, data interface{}) error {
+	return nil
+}
+
+// resumeLease returns the unexpired lease of the address the previous run
+// of the daemon saved for the mac address
+func (a *allocator) resumeLease(poolID string, ip net.IP, mac net.HardwareAddr) *dhcp.Lease {
+	a.Lock()
+	defer a.Unlock()
+	st, ok := a.saved[ip.String()]
+	if !ok {
+		return nil
+	}
+	delete(a.saved, ip.String())
+	if st.PoolID != poolID || !strings.EqualFold(st.Mac, mac.String()) {
+		return nil
+	}
+	return st.lease()
+}
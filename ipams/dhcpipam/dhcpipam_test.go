@@ -0,0 +1,125 @@
+package dhcpipam
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/libnetwork/dhcp"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/netlabel"
+	_ "github.com/docker/libnetwork/testutils"
+	"github.com/docker/libnetwork/types"
+)
+
+func newAllocator() *allocator {
+	return &allocator{
+		pools:  make(map[string]*pool),
+		leases: make(map[string]*lease),
+		saved:  make(map[string]*leaseState),
+	}
+}
+
+func TestPoolRequest(t *testing.T) {
+	a := newAllocator()
+
+	if _, _, _, err := a.RequestPool("default", "", "", map[string]string{ParentInterface: "eth0"}, false); err == nil {
+		t.Fatal("Unexpected success for an unknown address space")
+	}
+	if _, _, _, err := a.RequestPool(defaultAS, "", "", map[string]string{ParentInterface: "eth0"}, true); err == nil {
+		t.Fatal("Unexpected success for an IPv6 pool")
+	}
+	if _, _, _, err := a.RequestPool(defaultAS, "", "192.168.0.0/25", map[string]string{ParentInterface: "eth0"}, false); err == nil {
+		t.Fatal("Unexpected success for a subpool")
+	}
+	_, _, _, err := a.RequestPool(defaultAS, "", "", nil, false)
+	if _, ok := err.(types.BadRequestError); !ok {
+		t.Fatalf("Expected a bad request error without the %s option, got: %v", ParentInterface, err)
+	}
+}
+
+func TestGatewayRequest(t *testing.T) {
+	a := newAllocator()
+	_, subnet, _ := net.ParseCIDR("192.168.10.0/24")
+	p := &pool{ifName: "eth0", subnet: subnet, gateway: net.ParseIP("192.168.10.1")}
+	p.id = poolID(p.ifName, subnet)
+	a.pools[p.id] = p
+
+	opts := map[string]string{ipamapi.RequestAddressType: netlabel.Gateway}
+	gw, _, err := a.RequestAddress(p.id, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.String() != "192.168.10.1/24" {
+		t.Fatalf("Unexpected gateway %s", gw)
+	}
+	if _, _, err := a.RequestAddress(p.id, net.ParseIP("10.0.0.1"), opts); err != ipamapi.ErrIPOutOfRange {
+		t.Fatalf("Expected %v, got: %v", ipamapi.ErrIPOutOfRange, err)
+	}
+	if _, _, err := a.RequestAddress(p.id, nil, nil); err == nil {
+		t.Fatal("Unexpected success without the mac address of the endpoint")
+	}
+	// the gateway is not leased
+	if err := a.ReleaseAddress(p.id, gw.IP); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ReleasePool(p.id); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ReleasePool(p.id); err != ipamapi.ErrPoolNotFound {
+		t.Fatalf("Expected %v, got: %v", ipamapi.ErrPoolNotFound, err)
+	}
+}
+
+func TestLeaseState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dhcpipam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { stateDir = d }(stateDir)
+	stateDir = dir
+
+	ip, _ := types.ParseCIDR("192.168.10.20/24")
+	old, _ := types.ParseCIDR("192.168.10.21/24")
+	now := time.Now()
+	list := []*leaseState{
+		{PoolID: "dhcp/eth0/192.168.10.0/24", Mac: "02:42:c0:a8:0a:14",
+			Lease: &dhcp.Lease{IP: ip, Duration: time.Hour, Renew: 30 * time.Minute, Obtained: now}},
+		{PoolID: "dhcp/eth0/192.168.10.0/24", Mac: "02:42:c0:a8:0a:15",
+			Lease: &dhcp.Lease{IP: old, Duration: time.Hour, Obtained: now.Add(-2 * time.Hour)}},
+	}
+	if err := writeState(list); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newAllocator()
+	if err := a.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.saved) != 1 {
+		t.Fatalf("Expected the unexpired lease only, got %d", len(a.saved))
+	}
+
+	mac, _ := net.ParseMAC("02:42:c0:a8:0a:14")
+	other, _ := net.ParseMAC("02:42:c0:a8:0a:16")
+	if l := a.resumeLease("dhcp/eth0/192.168.10.0/24", ip.IP, other); l != nil {
+		t.Fatal("Unexpected lease resumed for another mac address")
+	}
+	if err := writeState(list); err != nil {
+		t.Fatal(err)
+	}
+	a = newAllocator()
+	if err := a.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	l := a.resumeLease("dhcp/eth0/192.168.10.0/24", ip.IP, mac)
+	if l == nil || !l.IP.IP.Equal(ip.IP) {
+		t.Fatalf("Expected the lease of %s to be resumed, got %v", ip, l)
+	}
+	if l := a.resumeLease("dhcp/eth0/192.168.10.0/24", ip.IP, mac); l != nil {
+		t.Fatal("Unexpected lease resumed twice")
+	}
+}
@@ -0,0 +1,107 @@
+package dhcpipam
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+
+	"github.com/docker/libnetwork/dhcp"
+	"github.com/docker/libnetwork/netutils"
+	"github.com/docker/libnetwork/types"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	clientPrefix = "dhi" // macvlan prefix for the dhcp client link
+	clientLen    = 7
+)
+
+// checkInterface verifies the interface the dhcp server is reached
+// through exists
+func checkInterface(ifName string) error {
+	if _, err := netlink.LinkByName(ifName); err != nil {
+		return types.BadRequestErrorf("dhcp interface %s does not exist: %v", ifName, err)
+	}
+	return nil
+}
+
+// clientLink runs the function on a macvlan link of the interface created
+// for the time of the dhcp exchange, the endpoint interfaces being in the
+// container sandboxes or not created yet. The interface itself is used if
+// it cannot have macvlan links.
+func clientLink(ifName string, f func(ifName string) error) error {
+	parent, err := netlink.LinkByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to find the dhcp interface %s: %v", ifName, err)
+	}
+	name, err := netutils.GenerateIfaceName(clientPrefix, clientLen)
+	if err != nil {
+		return fmt.Errorf("error generating an interface name: %v", err)
+	}
+	link := &netlink.Macvlan{
+		LinkAttrs: netlink.LinkAttrs{Name: name, ParentIndex: parent.Attrs().Index},
+		Mode:      netlink.MACVLAN_MODE_BRIDGE,
+	}
+	if err := netlink.LinkAdd(link); err != nil {
+		return f(ifName)
+	}
+	defer netlink.LinkDel(link)
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to enable the dhcp client link %s: %v", name, err)
+	}
+
+	return f(name)
+}
+
+// probe obtains and releases a lease with a random mac address, to learn
+// the subnet and router of the dhcp server reached through the interface
+func probe(ifName string) (*dhcp.Lease, error) {
+	mac := make(net.HardwareAddr, 6)
+	if _, err := rand.Read(mac); err != nil {
+		return nil, err
+	}
+	// locally administered unicast address
+	mac[0] = mac[0]&0xfe | 0x02
+	l, err := acquire(ifName, mac)
+	if err != nil {
+		return nil, err
+	}
+	release(ifName, mac, l)
+	return l, nil
+}
+
+func acquire(ifName string, mac net.HardwareAddr) (*dhcp.Lease, error) {
+	var l *dhcp.Lease
+	err := clientLink(ifName, func(name string) error {
+		var err error
+		l, err = dhcp.Acquire(name, mac)
+		return err
+	})
+	return l, err
+}
+
+func request(ifName string, mac net.HardwareAddr, ip net.IP) (*dhcp.Lease, error) {
+	var l *dhcp.Lease
+	err := clientLink(ifName, func(name string) error {
+		var err error
+		l, err = dhcp.Request(name, mac, ip)
+		return err
+	})
+	return l, err
+}
+
+func renew(ifName string, mac net.HardwareAddr, old *dhcp.Lease) (*dhcp.Lease, error) {
+	var l *dhcp.Lease
+	err := clientLink(ifName, func(name string) error {
+		var err error
+		l, err = dhcp.Renew(name, mac, old)
+		return err
+	})
+	return l, err
+}
+
+func release(ifName string, mac net.HardwareAddr, l *dhcp.Lease) error {
+	return clientLink(ifName, func(name string) error {
+		return dhcp.Release(name, mac, l)
+	})
+}
@@ -0,0 +1,34 @@
+// +build !linux
+
+package dhcpipam
+
+import (
+	"net"
+
+	"github.com/docker/libnetwork/dhcp"
+	"github.com/docker/libnetwork/types"
+)
+
+func checkInterface(ifName string) error {
+	return types.NotImplementedErrorf("dhcp ipam driver is not supported on this platform")
+}
+
+func probe(ifName string) (*dhcp.Lease, error) {
+	return nil, types.NotImplementedErrorf("dhcp ipam driver is not supported on this platform")
+}
+
+func acquire(ifName string, mac net.HardwareAddr) (*dhcp.Lease, error) {
+	return nil, types.NotImplementedErrorf("dhcp ipam driver is not supported on this platform")
+}
+
+func request(ifName string, mac net.HardwareAddr, ip net.IP) (*dhcp.Lease, error) {
+	return nil, types.NotImplementedErrorf("dhcp ipam driver is not supported on this platform")
+}
+
+func renew(ifName string, mac net.HardwareAddr, old *dhcp.Lease) (*dhcp.Lease, error) {
+	return nil, types.NotImplementedErrorf("dhcp ipam driver is not supported on this platform")
+}
+
+func release(ifName string, mac net.HardwareAddr, l *dhcp.Lease) error {
+	return types.NotImplementedErrorf("dhcp ipam driver is not supported on this platform")
+}
@@ -0,0 +1,99 @@
+package dhcpipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/dhcp"
+)
+
+// The leases are saved as they are obtained, renewed and released. On
+// restart the daemon replays the address requests of its endpoints, and
+// the unexpired lease saved for a requested address and mac address is
+// kept renewed as is, the server being asked again for the others.
+
+// Directory of the saved leases
+var stateDir = "/var/lib/docker/network/files/dhcp"
+
+// leaseState is the saved lease of an address
+type leaseState struct {
+	PoolID string
+	Mac    string
+	Lease  *dhcp.Lease
+}
+
+func statePath() string {
+	return filepath.Join(stateDir, "leases.json")
+}
+
+// lease returns the saved lease, nil if it expired
+func (st *leaseState) lease() *dhcp.Lease {
+	if st.Lease == nil || st.Lease.IP == nil || st.Lease.Expired(time.Now()) {
+		return nil
+	}
+	return st.Lease
+}
+
+// loadState loads the unexpired leases the previous run of the daemon
+// saved
+func (a *allocator) loadState() error {
+	data, err := ioutil.ReadFile(statePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var list []*leaseState
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid dhcp lease state: %v", err)
+	}
+	a.Lock()
+	defer a.Unlock()
+	for _, st := range list {
+		if l := st.lease(); l != nil {
+			a.saved[l.IP.IP.String()] = st
+		}
+	}
+	return nil
+}
+
+// saveState saves the current leases, along with the unexpired saved ones
+// not requested again yet
+func (a *allocator) saveState() {
+	var list []*leaseState
+	a.Lock()
+	for _, l := range a.leases {
+		list = append(list, &leaseState{PoolID: l.poolID, Mac: l.mac.String(), Lease: l.keeper.Lease()})
+	}
+	for _, st := range a.saved {
+		if st.lease() != nil {
+			list = append(list, st)
+		}
+	}
+	a.Unlock()
+
+	if err := writeState(list); err != nil {
+		log.Warnf("dhcp ipam: failed to save the leases: %v", err)
+	}
+}
+
+func writeState(list []*leaseState) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return err
+	}
+	tmp := statePath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statePath())
+}
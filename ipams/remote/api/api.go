@@ -2,7 +2,11 @@
 // messages between libnetwork and the remote ipam plugin
 package api
 
-import "github.com/docker/libnetwork/ipamapi"
+import (
+	"time"
+
+	"github.com/docker/libnetwork/ipamapi"
+)
 
 // Response is the basic response structure used in all responses
 type Response struct {
@@ -24,13 +28,23 @@ type GetCapabilityResponse struct {
 	Response
 	RequiresMACAddress    bool
 	RequiresRequestReplay bool
+	// SupportsIPv6 defaults to true for the plugins predating it
+	SupportsIPv6               *bool `json:",omitempty"`
+	SupportsAllocationStrategy bool
+	RequiresLabels             bool
+	// LeaseRenewInterval is in seconds
+	LeaseRenewInterval int
 }
 
 // ToCapability converts the capability response into the internal ipam driver capaility structure
 func (capRes GetCapabilityResponse) ToCapability() *ipamapi.Capability {
 	return &ipamapi.Capability{
-		RequiresMACAddress:    capRes.RequiresMACAddress,
-		RequiresRequestReplay: capRes.RequiresRequestReplay,
+		RequiresMACAddress:         capRes.RequiresMACAddress,
+		RequiresRequestReplay:      capRes.RequiresRequestReplay,
+		SupportsIPv6:               capRes.SupportsIPv6 == nil || *capRes.SupportsIPv6,
+		SupportsAllocationStrategy: capRes.SupportsAllocationStrategy,
+		RequiresLabels:             capRes.RequiresLabels,
+		LeaseRenewInterval:         time.Duration(capRes.LeaseRenewInterval) * time.Second,
 	}
 }
 
@@ -92,3 +106,14 @@ type ReleaseAddressRequest struct {
 type ReleaseAddressResponse struct {
 	Response
 }
+
+// RenewAddressRequest represents the expected data in a ``renew address`` request message
+type RenewAddressRequest struct {
+	PoolID  string
+	Address string
+}
+
+// RenewAddressResponse represents the response message to a ``renew address`` request
+type RenewAddressResponse struct {
+	Response
+}
@@ -39,7 +39,8 @@ func Init(cb ipamapi.Callback, l, g interface{}) error {
 		} else {
 			log.Infof("remote ipam driver %s does not support capabilities", name)
 			log.Debug(err)
-			if err := cb.RegisterIpamDriver(name, a); err != nil {
+			// the plugins predating the capabilities serve IPv6 pools
+			if err := cb.RegisterIpamDriverWithCapabilities(name, a, &ipamapi.Capability{SupportsIPv6: true}); err != nil {
 				log.Errorf("error registering remote ipam driver %s due to %v", name, err)
 			}
 		}
@@ -126,6 +127,13 @@ func (a *allocator) ReleaseAddress(poolID string, address net.IP) error {
 	return a.call("ReleaseAddress", req, res)
 }
 
+// RenewAddress renews the lease of the address allocated from the pool
+func (a *allocator) RenewAddress(poolID string, address net.IP) error {
+	req := &api.RenewAddressRequest{PoolID: poolID, Address: address.String()}
+	res := &api.RenewAddressResponse{}
+	return a.call("RenewAddress", req, res)
+}
+
 // DiscoverNew is a notification for a new discovery event, such as a new global datastore
 func (a *allocator) DiscoverNew(dType discoverapi.DiscoveryType, data interface{}) error {
 	return nil
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/pkg/plugins"
 	"github.com/docker/libnetwork/ipamapi"
@@ -86,11 +87,70 @@ func TestGetCapabilities(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !caps.RequiresMACAddress || caps.RequiresRequestReplay {
+	if !caps.RequiresMACAddress || caps.RequiresRequestReplay || !caps.SupportsIPv6 || caps.RequiresLabels {
 		t.Fatalf("Unexpected capability: %v", caps)
 	}
 }
 
+func TestGetPolicyCapabilities(t *testing.T) {
+	var plugin = "test-ipam-driver-policy-capabilities"
+
+	mux := http.NewServeMux()
+	defer setupPlugin(t, plugin, mux)()
+
+	handle(t, mux, "GetCapabilities", func(msg map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"SupportsIPv6":               false,
+			"SupportsAllocationStrategy": true,
+			"RequiresLabels":             true,
+			"LeaseRenewInterval":         30,
+		}
+	})
+
+	p, err := plugins.Get(plugin, ipamapi.PluginEndpointType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newAllocator(plugin, p.Client)
+
+	caps, err := d.(*allocator).getCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if caps.SupportsIPv6 || !caps.SupportsAllocationStrategy || !caps.RequiresLabels || caps.LeaseRenewInterval != 30*time.Second {
+		t.Fatalf("Unexpected capability: %v", caps)
+	}
+}
+
+func TestRenewAddress(t *testing.T) {
+	var plugin = "test-ipam-driver-renew-address"
+
+	mux := http.NewServeMux()
+	defer setupPlugin(t, plugin, mux)()
+
+	var renewed string
+	handle(t, mux, "RenewAddress", func(msg map[string]interface{}) interface{} {
+		renewed = msg["PoolID"].(string) + "/" + msg["Address"].(string)
+		return map[string]interface{}{}
+	})
+
+	p, err := plugins.Get(plugin, ipamapi.PluginEndpointType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newAllocator(plugin, p.Client)
+
+	if err := d.(ipamapi.LeaseRenewer).RenewAddress("pool1", net.ParseIP("172.20.0.5")); err != nil {
+		t.Fatal(err)
+	}
+	if renewed != "pool1/172.20.0.5" {
+		t.Fatalf("Unexpected renewal request: %s", renewed)
+	}
+}
+
 func TestGetCapabilitiesFromLegacyDriver(t *testing.T) {
 	var plugin = "test-ipam-legacy-driver"
 
@@ -2,10 +2,15 @@
 package ipamutils
 
 import (
+	"fmt"
 	"net"
 	"sync"
 )
 
+// maxSplitNetworks bounds the number of networks a base network of the
+// default address pools is split in
+const maxSplitNetworks = 1 << 16
+
 var (
 	// PredefinedBroadNetworks contains a list of 31 IPv4 private networks with host size 16 and 12
 	// (172.17-31.x.x/16, 192.168.x.x/20) which do not overlap with the networks in `PredefinedGranularNetworks`
@@ -15,16 +20,108 @@ var (
 	PredefinedGranularNetworks []*net.IPNet
 
 	initNetworksOnce sync.Once
+	// the built-in lists, the configured default address pools replace
+	defaultBroadNetworks    []*net.IPNet
+	defaultGranularNetworks []*net.IPNet
+	predefinedMu            sync.Mutex
 )
 
+// NetworkToSplit is a base network of the default address pools, split in
+// networks of the Size prefix length
+type NetworkToSplit struct {
+	Base string
+	Size int
+}
+
 // InitNetworks initializes the pre-defined networks used by the  built-in IP allocator
 func InitNetworks() {
 	initNetworksOnce.Do(func() {
-		PredefinedBroadNetworks = initBroadPredefinedNetworks()
-		PredefinedGranularNetworks = initGranularPredefinedNetworks()
+		defaultBroadNetworks = initBroadPredefinedNetworks()
+		defaultGranularNetworks = initGranularPredefinedNetworks()
+		predefinedMu.Lock()
+		if PredefinedBroadNetworks == nil {
+			PredefinedBroadNetworks = defaultBroadNetworks
+		}
+		if PredefinedGranularNetworks == nil {
+			PredefinedGranularNetworks = defaultGranularNetworks
+		}
+		predefinedMu.Unlock()
 	})
 }
 
+// ConfigLocalScopeDefaultNetworks replaces the pre-defined networks of the
+// local scope with the ones the pools are split in, nil restoring the
+// built-in ones. It must be invoked before the built-in IP allocator is
+// initialized.
+func ConfigLocalScopeDefaultNetworks(pools []*NetworkToSplit) error {
+	return configDefaultNetworks(pools, &PredefinedBroadNetworks, &defaultBroadNetworks)
+}
+
+// ConfigGlobalScopeDefaultNetworks replaces the pre-defined networks of
+// the global scope with the ones the pools are split in, nil restoring
+// the built-in ones. It must be invoked before the built-in IP allocator is
+// initialized.
+func ConfigGlobalScopeDefaultNetworks(pools []*NetworkToSplit) error {
+	return configDefaultNetworks(pools, &PredefinedGranularNetworks, &defaultGranularNetworks)
+}
+
+func configDefaultNetworks(pools []*NetworkToSplit, predefined, builtin *[]*net.IPNet) error {
+	var list []*net.IPNet
+	if pools == nil {
+		InitNetworks()
+		list = *builtin
+	}
+	for _, p := range pools {
+		nws, err := splitNetwork(p)
+		if err != nil {
+			return err
+		}
+		for _, nw := range nws {
+			for _, o := range list {
+				if o.Contains(nw.IP) || nw.Contains(o.IP) {
+					return fmt.Errorf("default address pool %s overlaps with %s", p.Base, o)
+				}
+			}
+		}
+		list = append(list, nws...)
+	}
+
+	predefinedMu.Lock()
+	*predefined = list
+	predefinedMu.Unlock()
+	return nil
+}
+
+// splitNetwork returns the networks of the Size prefix length the base
+// network is split in
+func splitNetwork(p *NetworkToSplit) ([]*net.IPNet, error) {
+	_, base, err := net.ParseCIDR(p.Base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default address pool %q: %v", p.Base, err)
+	}
+	ip := base.IP.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("default address pool %s is not an IPv4 network", p.Base)
+	}
+	ones, _ := base.Mask.Size()
+	if p.Size < ones || p.Size > 30 {
+		return nil, fmt.Errorf("invalid size %d of the networks of the default address pool %s", p.Size, p.Base)
+	}
+	if p.Size-ones > 16 {
+		return nil, fmt.Errorf("default address pool %s splits in more than %d networks of size %d", p.Base, maxSplitNetworks, p.Size)
+	}
+
+	count := 1 << uint(p.Size-ones)
+	mask := net.CIDRMask(p.Size, 32)
+	start := uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+	list := make([]*net.IPNet, 0, count)
+	for i := 0; i < count; i++ {
+		v := start + uint32(i)<<uint(32-p.Size)
+		list = append(list, &net.IPNet{IP: net.IP{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}, Mask: mask})
+	}
+	return list, nil
+}
+
 func initBroadPredefinedNetworks() []*net.IPNet {
 	pl := make([]*net.IPNet, 0, 31)
 	mask := []byte{255, 255, 0, 0}
@@ -24,3 +24,39 @@ func TestGranularPredefined(t *testing.T) {
 	}
 
 }
+
+func TestConfigDefaultNetworks(t *testing.T) {
+	defer ConfigLocalScopeDefaultNetworks(nil)
+
+	pools := []*NetworkToSplit{{Base: "10.200.0.0/16", Size: 24}, {Base: "192.168.100.0/23", Size: 26}}
+	if err := ConfigLocalScopeDefaultNetworks(pools); err != nil {
+		t.Fatal(err)
+	}
+	if len(PredefinedBroadNetworks) != 256+8 {
+		t.Fatalf("Expected %d networks, got %d", 256+8, len(PredefinedBroadNetworks))
+	}
+	for i, exp := range map[int]string{0: "10.200.0.0/24", 255: "10.200.255.0/24", 256: "192.168.100.0/26", 263: "192.168.101.192/26"} {
+		if PredefinedBroadNetworks[i].String() != exp {
+			t.Fatalf("Expected network %d to be %s, got %s", i, exp, PredefinedBroadNetworks[i])
+		}
+	}
+
+	for _, p := range [][]*NetworkToSplit{
+		{{Base: "10.200.0.0/16", Size: 12}},
+		{{Base: "10.200.0.0/16", Size: 31}},
+		{{Base: "10.0.0.0/8", Size: 28}},
+		{{Base: "fd00::/48", Size: 64}},
+		{{Base: "10.200.0.0/16", Size: 24}, {Base: "10.200.128.0/20", Size: 24}},
+	} {
+		if err := ConfigLocalScopeDefaultNetworks(p); err == nil {
+			t.Fatalf("Expected failure configuring %v", p[0])
+		}
+	}
+
+	if err := ConfigLocalScopeDefaultNetworks(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(PredefinedBroadNetworks) != 31 {
+		t.Fatalf("Expected the built-in networks, got %d", len(PredefinedBroadNetworks))
+	}
+}
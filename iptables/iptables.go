@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -16,7 +17,7 @@ import (
 // Action signifies the iptable action.
 type Action string
 
-// Table refers to Nat, Filter or Mangle.
+// Table refers to Nat, Filter, Mangle or RawTable.
 type Table string
 
 const (
@@ -32,8 +33,28 @@ const (
 	Filter Table = "filter"
 	// Mangle table is used for mangling the packet.
 	Mangle Table = "mangle"
+	// RawTable table is used for the rules applied before connection tracking.
+	RawTable Table = "raw"
 )
 
+// Backend is the kernel packet filtering framework the rules are
+// programmed into.
+type Backend string
+
+const (
+	// BackendAuto uses the framework of the iptables command of the
+	// system, or nftables if only the nftables variant is installed.
+	BackendAuto Backend = ""
+	// BackendLegacy programs the rules into the legacy x_tables.
+	BackendLegacy Backend = "legacy"
+	// BackendNFTables programs the rules into nftables, through the
+	// nftables variant of the iptables command.
+	BackendNFTables Backend = "nftables"
+)
+
+// backendEnv passes the selected backend to the reexec'ed processes
+const backendEnv = "LIBNETWORK_IPTABLES_BACKEND"
+
 var (
 	iptablesPath  string
 	supportsXlock = false
@@ -44,6 +65,7 @@ var (
 	ErrIptablesNotFound = errors.New("Iptables not found")
 	probeOnce           sync.Once
 	firewalldOnce       sync.Once
+	backend             = Backend(os.Getenv(backendEnv))
 )
 
 // ChainInfo defines the iptables chain.
@@ -78,11 +100,65 @@ func initFirewalld() {
 	}
 }
 
+// SetBackend selects the packet filtering framework the rules are
+// programmed into. It must be called before any rule is programmed.
+func SetBackend(b Backend) error {
+	switch b {
+	case BackendAuto, BackendLegacy, BackendNFTables:
+	default:
+		return fmt.Errorf("invalid iptables backend %q", b)
+	}
+
+	backend = b
+	iptablesPath = ""
+	return os.Setenv(backendEnv, string(b))
+}
+
+// lookupIptables returns the path of the iptables command programming
+// the rules into the backend.
+func lookupIptables(b Backend) (string, error) {
+	var names []string
+	switch b {
+	case BackendLegacy:
+		names = []string{"iptables-legacy", "iptables"}
+	case BackendNFTables:
+		names = []string{"iptables-nft"}
+	default:
+		names = []string{"iptables", "iptables-nft"}
+	}
+
+	for _, name := range names {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		// The plain iptables command may be the variant of
+		// either framework.
+		if name == "iptables" && b == BackendLegacy {
+			out, _ := exec.Command(path, "--version").Output()
+			if backendFromVersion(string(out)) != BackendLegacy {
+				continue
+			}
+		}
+		return path, nil
+	}
+	return "", ErrIptablesNotFound
+}
+
+// backendFromVersion returns the framework of an iptables command from
+// its version output, e.g. "iptables v1.8.4 (nf_tables)".
+func backendFromVersion(version string) Backend {
+	if strings.Contains(version, "nf_tables") {
+		return BackendNFTables
+	}
+	return BackendLegacy
+}
+
 func initCheck() error {
 	if iptablesPath == "" {
 		probeOnce.Do(probe)
 		firewalldOnce.Do(initFirewalld)
-		path, err := exec.LookPath("iptables")
+		path, err := lookupIptables(backend)
 		if err != nil {
 			return ErrIptablesNotFound
 		}
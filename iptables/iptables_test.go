@@ -2,6 +2,7 @@ package iptables
 
 import (
 	"net"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -289,6 +290,36 @@ func TestGetVersion(t *testing.T) {
 	}
 }
 
+func TestBackendFromVersion(t *testing.T) {
+	input := []struct {
+		version string
+		backend Backend
+	}{
+		{"iptables v1.4.21", BackendLegacy},
+		{"iptables v1.8.4 (legacy)", BackendLegacy},
+		{"iptables v1.8.4 (nf_tables)", BackendNFTables},
+	}
+	for _, inp := range input {
+		if b := backendFromVersion(inp.version); b != inp.backend {
+			t.Fatalf("Incorrect backend for %q: %q", inp.version, b)
+		}
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	defer SetBackend(BackendAuto)
+
+	if err := SetBackend(Backend("ebtables")); err == nil {
+		t.Fatalf("Expected an error for an invalid backend")
+	}
+	if err := SetBackend(BackendNFTables); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(backendEnv) != string(BackendNFTables) {
+		t.Fatalf("Backend not passed to the reexec'ed processes")
+	}
+}
+
 func TestSupportsCOption(t *testing.T) {
 	input := []struct {
 		mj int
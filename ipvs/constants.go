@@ -87,8 +87,29 @@ const (
 	ipvsDestAttrStats
 )
 
+// Attributes used to describe the statistics of a service or of a
+// destination. Used inside nested attributes ipvsSvcAttrStats and
+// ipvsDestAttrStats.
+const (
+	ipvsStatsUnspec int = iota
+	ipvsStatsConns
+	ipvsStatsInPkts
+	ipvsStatsOutPkts
+	ipvsStatsInBytes
+	ipvsStatsOutBytes
+	ipvsStatsCPS
+	ipvsStatsInPPS
+	ipvsStatsOutPPS
+	ipvsStatsInBPS
+	ipvsStatsOutBPS
+)
+
 // Destination forwarding methods
 const (
+	// SvcFlagPersistent marks the service as persistent so that the
+	// connections from a client are sent to the same real server.
+	SvcFlagPersistent = 0x0001
+
 	// ConnectionFlagFwdmask indicates the mask in the connection
 	// flags which is used by forwarding method bits.
 	ConnectionFlagFwdMask = 0x0007
@@ -5,6 +5,7 @@ package ipvs
 import (
 	"net"
 	"syscall"
+	"time"
 
 	"github.com/vishvananda/netlink/nl"
 	"github.com/vishvananda/netns"
@@ -25,6 +26,9 @@ type Service struct {
 	Netmask       uint32
 	AddressFamily uint16
 	PEName        string
+
+	// Statistics of the service, only filled by GetService.
+	Stats Stats
 }
 
 // Destination defines an IPVS destination (real server) in its
@@ -37,6 +41,35 @@ type Destination struct {
 	AddressFamily   uint16
 	UpperThreshold  uint32
 	LowerThreshold  uint32
+
+	// Connection counters and statistics of the real server, only
+	// filled by GetDestinations.
+	ActiveConnections   int
+	InactiveConnections int
+	Stats               Stats
+}
+
+// Stats defines the traffic statistics of an IPVS service or
+// destination.
+type Stats struct {
+	Connections uint32
+	PacketsIn   uint32
+	PacketsOut  uint32
+	BytesIn     uint64
+	BytesOut    uint64
+	CPS         uint32
+	PPSIn       uint32
+	PPSOut      uint32
+	BPSIn       uint32
+	BPSOut      uint32
+}
+
+// Config defines the IPVS timeouts of a network namespace. A zero
+// timeout is left unchanged by SetConfig.
+type Config struct {
+	TimeoutTCP    time.Duration
+	TimeoutTCPFin time.Duration
+	TimeoutUDP    time.Duration
 }
 
 // Handle provides a namespace specific ipvs handle to program ipvs
@@ -111,3 +144,48 @@ func (i *Handle) UpdateDestination(s *Service, d *Destination) error {
 func (i *Handle) DelDestination(s *Service, d *Destination) error {
 	return i.doCmd(s, d, ipvsCmdDelDest)
 }
+
+// GetService returns the ipvs service matching the passed one in the
+// passed handle, along with its statistics.
+func (i *Handle) GetService(s *Service) (*Service, error) {
+	msgs, err := i.doGetCmd(s, ipvsCmdGetService, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, syscall.ENOENT
+	}
+
+	return parseService(msgs[0])
+}
+
+// GetDestinations returns the real servers of the passed ipvs service
+// in the passed handle, along with their statistics.
+func (i *Handle) GetDestinations(s *Service) ([]*Destination, error) {
+	msgs, err := i.doGetCmd(s, ipvsCmdGetDest, syscall.NLM_F_DUMP)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*Destination
+	for _, m := range msgs {
+		d, err := parseDestination(m, s.AddressFamily)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// SetConfig sets the IPVS timeouts of the namespace of the passed
+// handle.
+func (i *Handle) SetConfig(c *Config) error {
+	return i.doSetConfigCmd(c)
+}
+
+// GetConfig returns the IPVS timeouts of the namespace of the passed
+// handle.
+func (i *Handle) GetConfig() (*Config, error) {
+	return i.doGetConfigCmd()
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/docker/libnetwork/testutils"
 	"github.com/stretchr/testify/assert"
@@ -184,6 +185,26 @@ func TestService(t *testing.T) {
 
 }
 
+func TestConfig(t *testing.T) {
+	if testutils.RunningOnCircleCI() {
+		t.Skipf("Skipping as not supported on CIRCLE CI kernel")
+	}
+
+	defer testutils.SetupTestOSContext(t)()
+
+	i, err := New("")
+	require.NoError(t, err)
+	defer i.Close()
+
+	err = i.SetConfig(&Config{TimeoutUDP: 600 * time.Second})
+	require.NoError(t, err)
+
+	c, err := i.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 600*time.Second, c.TimeoutUDP)
+	assert.NotEqual(t, time.Duration(0), c.TimeoutTCP)
+}
+
 func createDummyInterface(t *testing.T) {
 	if testutils.RunningOnCircleCI() {
 		t.Skipf("Skipping as not supported on CIRCLE CI kernel")
@@ -287,6 +308,18 @@ func TestDestination(t *testing.T) {
 			assert.NoError(t, err)
 			checkDestination(t, true, protocol, serviceAddress, realAddress, fwdMethodStrings[j])
 
+			dests, err := i.GetDestinations(&s)
+			assert.NoError(t, err)
+			assert.Len(t, dests, 3)
+			for _, d := range dests {
+				assert.Equal(t, uint16(5000), d.Port)
+				assert.Equal(t, fwdMethod, d.ConnectionFlags&ConnectionFlagFwdMask)
+			}
+
+			svc, err := i.GetService(&s)
+			assert.NoError(t, err)
+			assert.Equal(t, s.FWMark, svc.FWMark)
+
 			for m, updateFwdMethod := range fwdMethods {
 				if updateFwdMethod == fwdMethod {
 					continue
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/Sirupsen/logrus"
@@ -131,6 +132,199 @@ func (i *Handle) doCmd(s *Service, d *Destination, cmd uint8) error {
 	return nil
 }
 
+func (i *Handle) doGetCmd(s *Service, cmd uint8, flags int) ([][]byte, error) {
+	req := nl.NewNetlinkRequest(ipvsFamily, flags)
+	req.AddData(&genlMsgHdr{cmd: cmd, version: 1})
+	req.AddData(fillService(s))
+
+	return execute(i.sock, req, 0)
+}
+
+func (i *Handle) doSetConfigCmd(c *Config) error {
+	req := newIPVSRequest(ipvsCmdSetConfig)
+	req.AddData(nl.NewRtAttr(ipvsCmdAttrTimeoutTCP, nl.Uint32Attr(uint32(c.TimeoutTCP/time.Second))))
+	req.AddData(nl.NewRtAttr(ipvsCmdAttrTimeoutTCPFin, nl.Uint32Attr(uint32(c.TimeoutTCPFin/time.Second))))
+	req.AddData(nl.NewRtAttr(ipvsCmdAttrTimeoutUDP, nl.Uint32Attr(uint32(c.TimeoutUDP/time.Second))))
+
+	_, err := execute(i.sock, req, 0)
+	return err
+}
+
+func (i *Handle) doGetConfigCmd() (*Config, error) {
+	req := nl.NewNetlinkRequest(ipvsFamily, 0)
+	req.AddData(&genlMsgHdr{cmd: ipvsCmdGetConfig, version: 1})
+
+	msgs, err := execute(i.sock, req, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("no ipvs config in the netlink response")
+	}
+
+	hdr := deserializeGenlMsg(msgs[0])
+	attrs, err := nl.ParseRouteAttr(msgs[0][hdr.Len():])
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	for _, attr := range attrs {
+		timeout := time.Duration(native.Uint32(attr.Value)) * time.Second
+		switch attrType(attr) {
+		case ipvsCmdAttrTimeoutTCP:
+			c.TimeoutTCP = timeout
+		case ipvsCmdAttrTimeoutTCPFin:
+			c.TimeoutTCPFin = timeout
+		case ipvsCmdAttrTimeoutUDP:
+			c.TimeoutUDP = timeout
+		}
+	}
+	return c, nil
+}
+
+// attrType strips the flags the kernel may set in the type of a nested
+// attribute.
+func attrType(attr syscall.NetlinkRouteAttr) int {
+	return int(attr.Attr.Type) & 0x3FFF
+}
+
+// parseNested returns the attributes nested in the passed top level
+// attribute of a generic netlink message.
+func parseNested(msg []byte, cmdAttr int) ([]syscall.NetlinkRouteAttr, error) {
+	hdr := deserializeGenlMsg(msg)
+	attrs, err := nl.ParseRouteAttr(msg[hdr.Len():])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attr := range attrs {
+		if attrType(attr) == cmdAttr {
+			return nl.ParseRouteAttr(attr.Value)
+		}
+	}
+	return nil, fmt.Errorf("no attribute %d in the netlink message", cmdAttr)
+}
+
+func parseIP(b []byte, family uint16) net.IP {
+	if family == nl.FAMILY_V4 && len(b) >= net.IPv4len {
+		return net.IP(append([]byte{}, b[:net.IPv4len]...))
+	}
+	return net.IP(append([]byte{}, b...))
+}
+
+func parseStats(b []byte) (Stats, error) {
+	var s Stats
+
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return s, err
+	}
+
+	for _, attr := range attrs {
+		switch attrType(attr) {
+		case ipvsStatsConns:
+			s.Connections = native.Uint32(attr.Value)
+		case ipvsStatsInPkts:
+			s.PacketsIn = native.Uint32(attr.Value)
+		case ipvsStatsOutPkts:
+			s.PacketsOut = native.Uint32(attr.Value)
+		case ipvsStatsInBytes:
+			s.BytesIn = native.Uint64(attr.Value)
+		case ipvsStatsOutBytes:
+			s.BytesOut = native.Uint64(attr.Value)
+		case ipvsStatsCPS:
+			s.CPS = native.Uint32(attr.Value)
+		case ipvsStatsInPPS:
+			s.PPSIn = native.Uint32(attr.Value)
+		case ipvsStatsOutPPS:
+			s.PPSOut = native.Uint32(attr.Value)
+		case ipvsStatsInBPS:
+			s.BPSIn = native.Uint32(attr.Value)
+		case ipvsStatsOutBPS:
+			s.BPSOut = native.Uint32(attr.Value)
+		}
+	}
+	return s, nil
+}
+
+func parseService(msg []byte) (*Service, error) {
+	attrs, err := parseNested(msg, ipvsCmdAttrService)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{}
+	var addr []byte
+	for _, attr := range attrs {
+		switch attrType(attr) {
+		case ipvsSvcAttrAddressFamily:
+			s.AddressFamily = native.Uint16(attr.Value)
+		case ipvsSvcAttrProtocol:
+			s.Protocol = native.Uint16(attr.Value)
+		case ipvsSvcAttrAddress:
+			addr = attr.Value
+		case ipvsSvcAttrPort:
+			s.Port = binary.BigEndian.Uint16(attr.Value)
+		case ipvsSvcAttrFWMark:
+			s.FWMark = native.Uint32(attr.Value)
+		case ipvsSvcAttrSchedName:
+			s.SchedName = nl.BytesToString(attr.Value)
+		case ipvsSvcAttrFlags:
+			s.Flags = native.Uint32(attr.Value)
+		case ipvsSvcAttrTimeout:
+			s.Timeout = native.Uint32(attr.Value)
+		case ipvsSvcAttrNetmask:
+			s.Netmask = native.Uint32(attr.Value)
+		case ipvsSvcAttrPEName:
+			s.PEName = nl.BytesToString(attr.Value)
+		case ipvsSvcAttrStats:
+			if s.Stats, err = parseStats(attr.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if addr != nil {
+		s.Address = parseIP(addr, s.AddressFamily)
+	}
+	return s, nil
+}
+
+func parseDestination(msg []byte, family uint16) (*Destination, error) {
+	attrs, err := parseNested(msg, ipvsCmdAttrDest)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Destination{AddressFamily: family}
+	for _, attr := range attrs {
+		switch attrType(attr) {
+		case ipvsDestAttrAddress:
+			d.Address = parseIP(attr.Value, family)
+		case ipvsDestAttrPort:
+			d.Port = binary.BigEndian.Uint16(attr.Value)
+		case ipvsDestAttrForwardingMethod:
+			d.ConnectionFlags = native.Uint32(attr.Value)
+		case ipvsDestAttrWeight:
+			d.Weight = int(native.Uint32(attr.Value))
+		case ipvsDestAttrUpperThreshold:
+			d.UpperThreshold = native.Uint32(attr.Value)
+		case ipvsDestAttrLowerThreshold:
+			d.LowerThreshold = native.Uint32(attr.Value)
+		case ipvsDestAttrActiveConnections:
+			d.ActiveConnections = int(native.Uint32(attr.Value))
+		case ipvsDestAttrInactiveConnections:
+			d.InactiveConnections = int(native.Uint32(attr.Value))
+		case ipvsDestAttrStats:
+			if d.Stats, err = parseStats(attr.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
 func getIPVSFamily() (int, error) {
 	sock, err := nl.GetNetlinkSocketAt(netns.None(), netns.None(), syscall.NETLINK_GENERIC)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"reflect"
 	"testing"
 
 	"github.com/docker/libnetwork/datastore"
@@ -114,6 +115,7 @@ func TestNetworkMarshalling(t *testing.T) {
 			"color":        "blue",
 			"superimposed": "",
 		},
+		dnsSearch: []string{"corp.example.com", "example.com"},
 	}
 
 	b, err := json.Marshal(n)
@@ -133,7 +135,8 @@ func TestNetworkMarshalling(t *testing.T) {
 		!compareIpamInfoList(n.ipamV4Info, nn.ipamV4Info) || !compareIpamConfList(n.ipamV6Config, nn.ipamV6Config) ||
 		!compareIpamInfoList(n.ipamV6Info, nn.ipamV6Info) ||
 		!compareStringMaps(n.ipamOptions, nn.ipamOptions) ||
-		!compareStringMaps(n.labels, nn.labels) {
+		!compareStringMaps(n.labels, nn.labels) ||
+		!reflect.DeepEqual(n.dnsSearch, nn.dnsSearch) {
 		t.Fatalf("JSON marsh/unmarsh failed."+
 			"\nOriginal:\n%#v\nDecoded:\n%#v"+
 			"\nOriginal ipamV4Conf: %#v\n\nDecoded ipamV4Conf: %#v"+
@@ -183,7 +186,11 @@ func TestEndpointMarshalling(t *testing.T) {
 				IP:   net.IP{10, 0, 1, 23},
 				Mask: net.IPMask{255, 255, 255, 0},
 			},
-			addrv6:    nw6,
+			addrv6: nw6,
+			secondaryAddrs: []*net.IPNet{{
+				IP:   net.IP{10, 0, 1, 24},
+				Mask: net.IPMask{255, 255, 255, 0},
+			}},
 			srcName:   "veth12ab1314",
 			dstPrefix: "eth",
 			v4PoolID:  "poolpool",
@@ -215,7 +222,20 @@ func compareEndpointInterface(a, b *endpointInterface) bool {
 		return false
 	}
 	return a.srcName == b.srcName && a.dstPrefix == b.dstPrefix && a.v4PoolID == b.v4PoolID && a.v6PoolID == b.v6PoolID &&
-		types.CompareIPNet(a.addr, b.addr) && types.CompareIPNet(a.addrv6, b.addrv6)
+		types.CompareIPNet(a.addr, b.addr) && types.CompareIPNet(a.addrv6, b.addrv6) &&
+		compareIPNetList(a.secondaryAddrs, b.secondaryAddrs) && compareIPNetList(a.secondaryAddrsv6, b.secondaryAddrsv6)
+}
+
+func compareIPNetList(a, b []*net.IPNet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !types.CompareIPNet(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func compareIpamConfList(listA, listB []*IpamConf) bool {
@@ -481,6 +501,235 @@ func TestIpamReleaseOnNetDriverFailures(t *testing.T) {
 	}
 }
 
+func TestReconcileAddresses(t *testing.T) {
+	if !testutils.IsRunningInContainer() {
+		defer testutils.SetupTestOSContext(t)()
+	}
+
+	cfgOptions, err := OptionBoltdbWithRandomDBFile()
+	c, err := New(cfgOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	ipamOpt := NetworkOptionIpam(ipamapi.DefaultIPAM, "", []*IpamConf{{PreferredPool: "10.35.0.0/16", Gateway: "10.35.255.254"}}, nil, nil)
+	nw, err := c.NewNetwork("bridge", "reconcilenet", "", ipamOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Delete()
+
+	ep, err := nw.CreateEndpoint("ep1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep.Delete(false)
+
+	// leak an address as a crash before the endpoint is stored would
+	n := nw.(*network)
+	ipam, _, err := c.(*controller).getIPAMDriver(n.ipamType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaked, _, err := ipam.RequestAddress(n.ipamV4Info[0].PoolID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := c.ReconcileAddresses(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].Address.Equal(leaked.IP) || list[0].Released {
+		t.Fatalf("Expected %s to be reported orphaned only, got %v", leaked.IP, list)
+	}
+
+	// the first pass marks the address, the second one releases it
+	for i, released := range []bool{false, true} {
+		list, err := c.ReconcileAddresses(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].Released != released {
+			t.Fatalf("Unexpected orphaned addresses on pass %d: %v", i, list)
+		}
+	}
+
+	if list, err := c.ReconcileAddresses(true); err != nil || len(list) != 0 {
+		t.Fatalf("Expected no orphaned address left, got %v (%v)", list, err)
+	}
+	if !types.CompareIPNet(ep.Info().Iface().Address(), &net.IPNet{IP: net.ParseIP("10.35.0.1").To4(), Mask: leaked.Mask}) {
+		t.Fatalf("Unexpected endpoint address %v", ep.Info().Iface().Address())
+	}
+}
+
+func TestStaticBindings(t *testing.T) {
+	if !testutils.IsRunningInContainer() {
+		defer testutils.SetupTestOSContext(t)()
+	}
+
+	cfgOptions, err := OptionBoltdbWithRandomDBFile()
+	c, err := New(cfgOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	ipamOpt := NetworkOptionIpam(ipamapi.DefaultIPAM, "", []*IpamConf{{PreferredPool: "10.38.0.0/24", Gateway: "10.38.0.254"}}, nil, nil)
+	bad := NetworkOptionStaticBindings(map[string]string{"dns": "10.39.0.1"})
+	if _, err := c.NewNetwork("bridge", "staticnet", "", ipamOpt, bad); err == nil {
+		t.Fatal("Expected failure binding an address out of the network pools")
+	}
+
+	bindings := NetworkOptionStaticBindings(map[string]string{"dns": "10.38.0.1", "proxy": "10.38.0.2"})
+	nw, err := c.NewNetwork("bridge", "staticnet", "", ipamOpt, bindings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Delete()
+
+	other, err := nw.CreateEndpoint("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Delete(false)
+	if ip := other.Info().Iface().Address().IP; !ip.Equal(net.ParseIP("10.38.0.3")) {
+		t.Fatalf("Expected the bound addresses to be skipped, got %s", ip)
+	}
+
+	if _, err := nw.CreateEndpoint("proxy", CreateOptionIpam(net.ParseIP("10.38.0.9"), nil, nil)); err == nil {
+		t.Fatal("Expected failure requesting another address than the bound one")
+	}
+
+	// the address is kept reserved across the endpoints of the name
+	for i := 0; i < 2; i++ {
+		ep, err := nw.CreateEndpoint("dns")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip := ep.Info().Iface().Address().IP; !ip.Equal(net.ParseIP("10.38.0.1")) {
+			t.Fatalf("Expected the bound address, got %s", ip)
+		}
+		if err := ep.Delete(false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := nw.CreateEndpoint("intruder", CreateOptionIpam(net.ParseIP("10.38.0.1"), nil, nil)); err == nil {
+			t.Fatal("Expected the bound address to stay reserved")
+		}
+	}
+
+	if list, err := c.ReconcileAddresses(true); err != nil || len(list) != 0 {
+		t.Fatalf("Expected the bound addresses not to be orphaned, got %v (%v)", list, err)
+	}
+}
+
+func TestAddressAuditHook(t *testing.T) {
+	if !testutils.IsRunningInContainer() {
+		defer testutils.SetupTestOSContext(t)()
+	}
+
+	cfgOptions, err := OptionBoltdbWithRandomDBFile()
+	c, err := New(cfgOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	var records []*AddressAuditRecord
+	c.SetAddressAuditHook(func(r *AddressAuditRecord) {
+		records = append(records, r)
+	})
+
+	ipamOpt := NetworkOptionIpam(ipamapi.DefaultIPAM, "", []*IpamConf{{PreferredPool: "10.37.0.0/16", Gateway: "10.37.255.254"}}, nil, nil)
+	nw, err := c.NewNetwork("bridge", "auditnet", "", ipamOpt, NetworkOptionLabels(map[string]string{"team": "blue"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep, err := nw.CreateEndpoint("ep1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ep.Delete(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := nw.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		action, role, address, endpoint string
+	}{
+		{AddressAllocated, AddressRoleGateway, "10.37.255.254", ""},
+		{AddressAllocated, AddressRoleEndpoint, "10.37.0.1", "ep1"},
+		{AddressReleased, AddressRoleEndpoint, "10.37.0.1", "ep1"},
+		{AddressReleased, AddressRoleGateway, "10.37.255.254", ""},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d audit records, got %d", len(expected), len(records))
+	}
+	for i, e := range expected {
+		r := records[i]
+		if r.Action != e.action || r.Role != e.role || r.Address.String() != e.address || r.EndpointName != e.endpoint {
+			t.Fatalf("Unexpected audit record %d: %+v", i, r)
+		}
+		if r.NetworkName != "auditnet" || r.Labels["team"] != "blue" {
+			t.Fatalf("Unexpected requester of audit record %d: %+v", i, r)
+		}
+	}
+}
+
+func TestAutoExpandPool(t *testing.T) {
+	if !testutils.IsRunningInContainer() {
+		defer testutils.SetupTestOSContext(t)()
+	}
+
+	cfgOptions, err := OptionBoltdbWithRandomDBFile()
+	c, err := New(cfgOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	ipamOpt := NetworkOptionIpam(ipamapi.DefaultIPAM, "", []*IpamConf{{PreferredPool: "10.36.0.0/30"}}, nil, map[string]string{ipamapi.AutoExpand: "x"})
+	if _, err := c.NewNetwork("bridge", "expandnet", "", ipamOpt); err == nil {
+		t.Fatal("Unexpected success for an invalid auto expansion option")
+	}
+
+	ipamOpt = NetworkOptionIpam(ipamapi.DefaultIPAM, "", []*IpamConf{{PreferredPool: "10.36.0.0/30"}}, nil, map[string]string{ipamapi.AutoExpand: "1"})
+	nw, err := c.NewNetwork("bridge", "expandnet", "", ipamOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Delete()
+
+	// the gateway and the first endpoint exhaust the pool
+	ep1, err := nw.CreateEndpoint("ep1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep1.Delete(false)
+
+	ep2, err := nw.CreateEndpoint("ep2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ep2.Delete(false)
+
+	_, expanded, _ := net.ParseCIDR("10.36.0.4/30")
+	if !expanded.Contains(ep2.Info().Iface().Address().IP) {
+		t.Fatalf("Expected an address of the adjacent subnet %s, got %v", expanded, ep2.Info().Iface().Address())
+	}
+	v4Info, _ := nw.Info().IpamInfo()
+	if len(v4Info) != 2 || v4Info[1].Pool.String() != expanded.String() {
+		t.Fatalf("Expected the network to be expanded with %s, got %v", expanded, v4Info)
+	}
+
+	if _, err := nw.CreateEndpoint("ep3"); err == nil {
+		t.Fatal("Unexpected expansion beyond the configured number of subnets")
+	}
+}
+
 var badDriverName = "bad network driver"
 
 type badDriver struct {
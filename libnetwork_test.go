@@ -1228,6 +1228,10 @@ func (f *fakeSandbox) ResolveService(name string) ([]*net.SRV, []net.IP, error)
 	return nil, nil, nil
 }
 
+func (f *fakeSandbox) ResolveTXT(name string) []string {
+	return nil
+}
+
 func (f *fakeSandbox) Endpoints() []libnetwork.Endpoint {
 	return nil
 }
@@ -42,11 +42,90 @@ const (
 	// OverlayVxlanIDList constant represents a list of VXLAN Ids as csv
 	OverlayVxlanIDList = DriverPrefix + ".overlay.vxlanid_list"
 
+	// OverlayVNI constant represents a csv list of VNIs, one per subnet,
+	// pinned by the user to interoperate with external VTEPs
+	OverlayVNI = DriverPrefix + ".overlay.vni"
+
+	// OverlayVxlanPort constant represents the UDP destination port of
+	// the VXLAN traffic of an overlay network
+	OverlayVxlanPort = DriverPrefix + ".overlay.vxlan_port"
+
+	// OverlaySourcePortRange constant represents the low-high range of
+	// the UDP source ports of the VXLAN traffic of an overlay network
+	OverlaySourcePortRange = DriverPrefix + ".overlay.source_port_range"
+
+	// OverlayEncryption constant represents the encryption of the data
+	// plane of an overlay network, "wireguard" being the supported one
+	OverlayEncryption = DriverPrefix + ".overlay.encryption"
+
+	// OverlayEncryptionPolicy constant represents a csv list of the
+	// prefixes, or dash separated pairs of prefixes, whose traffic is
+	// the only one encrypted
+	OverlayEncryptionPolicy = DriverPrefix + ".overlay.encryption_policy"
+
+	// OverlayEncapsulation constant represents the encapsulation of the
+	// traffic of an overlay network, "vxlan" (default), "geneve" or "none"
+	// for routing it through host routes when the nodes share an L3 fabric
+	OverlayEncapsulation = DriverPrefix + ".overlay.encapsulation"
+
+	// OverlayGeneveOptions constant represents a list of class:type:data
+	// TLVs as csv carried in the geneve header of an overlay network
+	OverlayGeneveOptions = DriverPrefix + ".overlay.geneve_options"
+
+	// OverlayMulticast constant represents whether the broadcast and
+	// multicast traffic of a vxlan overlay network is replicated to the
+	// nodes of its peers, the geneve networks always replicating it
+	OverlayMulticast = DriverPrefix + ".overlay.multicast"
+
+	// OverlayDataPath constant represents the data path forwarding the
+	// traffic of an overlay network, "kernel" (default) or the
+	// experimental "bpf"
+	OverlayDataPath = DriverPrefix + ".overlay.datapath"
+
+	// OverlayBPFObject constant represents the path of the eBPF object
+	// of the experimental overlay data path
+	OverlayBPFObject = DriverPrefix + ".overlay.bpf_object"
+
+	// OverlayInterSubnet constant represents the policy of the traffic
+	// between the subnets of an overlay network, "route" or "drop"
+	OverlayInterSubnet = DriverPrefix + ".overlay.inter_subnet"
+
+	// OverlayDSCP constant represents the DSCP set on the outer header
+	// of the encapsulated traffic of an overlay network, from 0 to 63, or
+	// "inherit" to copy the one of the inner header
+	OverlayDSCP = DriverPrefix + ".overlay.dscp"
+
+	// OverlayUDPChecksum constant represents whether the outer UDP header
+	// of the vxlan traffic of an overlay network carries a checksum, the
+	// IPv6 underlay always checksumming it
+	OverlayUDPChecksum = DriverPrefix + ".overlay.udp_checksum"
+
+	// OverlayOffloads constant represents a csv of feature=on|off settings
+	// of the tx, rx checksum, gso and gro offloads of the vxlan devices of
+	// an overlay network
+	OverlayOffloads = DriverPrefix + ".overlay.offloads"
+
+	// OverlayStaticPeers constant represents the path of a json file of
+	// static overlay peers, for the driver to be used without gossip
+	OverlayStaticPeers = DriverPrefix + ".overlay.static_peers"
+
+	// OverlayProbeInterval constant represents the interval the overlay
+	// driver probes the tunnel endpoints of the remote peers at, the
+	// prober being disabled when not set
+	OverlayProbeInterval = DriverPrefix + ".overlay.probe_interval"
+
+	// OverlayKeyRotationInterval constant represents the interval the
+	// overlay driver rotates the keys of the encrypted networks at
+	OverlayKeyRotationInterval = DriverPrefix + ".overlay.key_rotation_interval"
+
 	// Gateway represents the gateway for the network
 	Gateway = Prefix + ".gateway"
 
 	// Internal constant represents that the network is internal which disables default gateway service
 	Internal = Prefix + ".internal"
+
+	// DNSSearch constant represents the DNS search domains of a network as csv
+	DNSSearch = Prefix + ".dns_search"
 )
 
 var (
@@ -0,0 +1,130 @@
+package netutils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode parameters as defined in RFC 3492
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	acePrefix       = "xn--"
+)
+
+// NormalizeDNSName returns the canonical form of a DNS name used for
+// service discovery lookups: the name is lower cased and every label
+// holding non ASCII characters is converted to its IDNA (punycode) form,
+// so that a name matches regardless of the normalization done by clients.
+func NormalizeDNSName(name string) string {
+	name = strings.ToLower(name)
+
+	labels := strings.Split(name, ".")
+	for i, l := range labels {
+		if !isASCII(l) {
+			labels[i] = acePrefix + punyEncode(l)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func punyAdapt(delta, numPoints int32, firstTime bool) int32 {
+	if firstTime {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := int32(0)
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int32) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punyEncode implements the encoding procedure of RFC 3492 section 6.3
+func punyEncode(s string) string {
+	var (
+		out   []byte
+		runes = []rune(s)
+		n     = int32(punyInitialN)
+		delta = int32(0)
+		bias  = int32(punyInitialBias)
+	)
+
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	b := int32(len(out))
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+
+	for h < int32(len(runes)) {
+		m := int32(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := int32(punyBase); ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+
+	return string(out)
+}
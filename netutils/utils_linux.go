@@ -34,6 +34,38 @@ func CheckRouteOverlaps(toCheck *net.IPNet) error {
 	return nil
 }
 
+// RouteOverlap is a route of the host a network overlaps with
+type RouteOverlap struct {
+	Dst       *net.IPNet
+	Interface string
+}
+
+// FindRouteOverlaps returns the routes of the host, of the IP version of
+// the passed network, it overlaps with
+func FindRouteOverlaps(toCheck *net.IPNet) ([]RouteOverlap, error) {
+	family := netlink.FAMILY_V4
+	if toCheck.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	routes, err := networkGetRoutesFct(nil, family)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []RouteOverlap
+	for _, r := range routes {
+		if r.Dst == nil || !NetworkOverlaps(toCheck, r.Dst) {
+			continue
+		}
+		o := RouteOverlap{Dst: r.Dst}
+		if link, err := netlink.LinkByIndex(r.LinkIndex); err == nil {
+			o.Interface = link.Attrs().Name
+		}
+		list = append(list, o)
+	}
+	return list, nil
+}
+
 // GenerateIfaceName returns an interface name using the passed in
 // prefix and the length of random bytes. The api ensures that the
 // there are is no interface which exists with that name.
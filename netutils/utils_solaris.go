@@ -30,3 +30,15 @@ func ElectInterfaceAddresses(name string) (*net.IPNet, []*net.IPNet, error) {
 func FindAvailableNetwork(list []*net.IPNet) (*net.IPNet, error) {
 	return list[0], nil
 }
+
+// RouteOverlap is a route of the host a network overlaps with
+type RouteOverlap struct {
+	Dst       *net.IPNet
+	Interface string
+}
+
+// FindRouteOverlaps returns the routes of the host the passed network
+// overlaps with
+func FindRouteOverlaps(toCheck *net.IPNet) ([]RouteOverlap, error) {
+	return nil, nil
+}
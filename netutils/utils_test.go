@@ -304,3 +304,57 @@ func createInterface(t *testing.T, name, nw string) {
 		t.Fatal(err)
 	}
 }
+
+func TestNormalizeDNSName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Web.MyNet":         "web.mynet",
+		"bücher.example":    "xn--bcher-kva.example",
+		"BÜCHER":            "xn--bcher-kva",
+		"München.Service":   "xn--mnchen-3ya.service",
+		"ドメイン名例":            "xn--eckwd4c7cu47r2wf",
+		"xn--bcher-kva.net": "xn--bcher-kva.net",
+	} {
+		if n := NormalizeDNSName(name); n != expected {
+			t.Fatalf("Expected %s for %s, got %s", expected, name, n)
+		}
+	}
+}
+
+func TestFindRouteOverlaps(t *testing.T) {
+	orig := networkGetRoutesFct
+	defer func() {
+		networkGetRoutesFct = orig
+	}()
+	networkGetRoutesFct = func(link netlink.Link, family int) ([]netlink.Route, error) {
+		routesData := []string{"10.0.2.0/24", "10.0.0.0/8", "192.168.142.0/24"}
+		if family == netlink.FAMILY_V6 {
+			routesData = []string{"fd00:1::/64"}
+		}
+
+		routes := []netlink.Route{{}}
+		for _, addr := range routesData {
+			_, netX, _ := net.ParseCIDR(addr)
+			routes = append(routes, netlink.Route{Dst: netX})
+		}
+		return routes, nil
+	}
+
+	_, netX, _ := net.ParseCIDR("172.16.0.0/24")
+	if list, err := FindRouteOverlaps(netX); err != nil || len(list) != 0 {
+		t.Fatalf("Unexpected overlaps of %s: %v (%v)", netX, list, err)
+	}
+
+	_, netX, _ = net.ParseCIDR("10.0.2.128/25")
+	list, err := FindRouteOverlaps(netX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Dst.String() != "10.0.2.0/24" || list[1].Dst.String() != "10.0.0.0/8" {
+		t.Fatalf("Unexpected overlaps of %s: %v", netX, list)
+	}
+
+	_, netX, _ = net.ParseCIDR("fd00:1::/48")
+	if list, err := FindRouteOverlaps(netX); err != nil || len(list) != 1 {
+		t.Fatalf("Unexpected overlaps of %s: %v (%v)", netX, list, err)
+	}
+}
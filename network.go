@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -53,6 +54,26 @@ type Network interface {
 
 	// Return certain operational data belonging to this network
 	Info() NetworkInfo
+
+	// AddSubnet adds an IPv4 subnet to the network, from which the
+	// endpoints get their address once the existing subnets are exhausted.
+	AddSubnet(conf *IpamConf) error
+
+	// AddExternalPeer registers into the network a static peer which is
+	// not a libnetwork node, such as a host behind a hardware VTEP.
+	AddExternalPeer(peer *driverapi.ExternalPeer) error
+
+	// DeleteExternalPeer unregisters the external peer of the passed
+	// address from the network.
+	DeleteExternalPeer(address net.IP) error
+
+	// PeerStatus returns the reachability of the tunnel endpoints of
+	// the remote peers of the network, as probed by the driver.
+	PeerStatus() ([]*driverapi.PeerStatus, error)
+
+	// PoolUsage returns the address usage of the ipam pools of the
+	// network, as reported by its ipam driver.
+	PoolUsage() ([]*PoolUsage, error)
 }
 
 // NetworkInfo returns some configuration and operational information about the network
@@ -65,6 +86,7 @@ type NetworkInfo interface {
 	Internal() bool
 	Labels() map[string]string
 	Dynamic() bool
+	DNSSearch() []string
 }
 
 // EndpointWalker is a client provided function which will be used to walk the Endpoints.
@@ -76,6 +98,7 @@ type svcInfo struct {
 	svcIPv6Map map[string][]net.IP
 	ipMap      map[string]string
 	service    map[string][]servicePorts
+	txtMap     map[string][]string
 }
 
 // backing container or host's info
@@ -189,6 +212,9 @@ type network struct {
 	ingress      bool
 	driverTables []string
 	dynamic      bool
+	dnsSearch    []string
+	// addresses reserved for the endpoints of the names
+	staticBindings map[string]string
 	sync.Mutex
 }
 
@@ -331,6 +357,11 @@ func (n *network) CopyTo(o datastore.KVObject) error {
 	dstN.inDelete = n.inDelete
 	dstN.ingress = n.ingress
 
+	if n.dnsSearch != nil {
+		dstN.dnsSearch = make([]string, len(n.dnsSearch))
+		copy(dstN.dnsSearch, n.dnsSearch)
+	}
+
 	// copy labels
 	if dstN.labels == nil {
 		dstN.labels = make(map[string]string, len(n.labels))
@@ -346,6 +377,13 @@ func (n *network) CopyTo(o datastore.KVObject) error {
 		}
 	}
 
+	if n.staticBindings != nil {
+		dstN.staticBindings = make(map[string]string, len(n.staticBindings))
+		for k, v := range n.staticBindings {
+			dstN.staticBindings[k] = v
+		}
+	}
+
 	for _, v4conf := range n.ipamV4Config {
 		dstV4Conf := &IpamConf{}
 		v4conf.CopyTo(dstV4Conf)
@@ -437,6 +475,12 @@ func (n *network) MarshalJSON() ([]byte, error) {
 	netMap["internal"] = n.internal
 	netMap["inDelete"] = n.inDelete
 	netMap["ingress"] = n.ingress
+	if len(n.dnsSearch) > 0 {
+		netMap["dnsSearch"] = n.dnsSearch
+	}
+	if len(n.staticBindings) > 0 {
+		netMap["staticBindings"] = n.staticBindings
+	}
 	return json.Marshal(netMap)
 }
 
@@ -469,6 +513,15 @@ func (n *network) UnmarshalJSON(b []byte) (err error) {
 		}
 	}
 
+	if v, ok := netMap["staticBindings"]; ok {
+		if bindings, ok := v.(map[string]interface{}); ok {
+			n.staticBindings = make(map[string]string, len(bindings))
+			for k, v := range bindings {
+				n.staticBindings[k] = v.(string)
+			}
+		}
+	}
+
 	if v, ok := netMap["generic"]; ok {
 		n.generic = v.(map[string]interface{})
 		// Restore opts in their map[string]string form
@@ -530,6 +583,11 @@ func (n *network) UnmarshalJSON(b []byte) (err error) {
 	if v, ok := netMap["ingress"]; ok {
 		n.ingress = v.(bool)
 	}
+	if v, ok := netMap["dnsSearch"]; ok {
+		for _, d := range v.([]interface{}) {
+			n.dnsSearch = append(n.dnsSearch, d.(string))
+		}
+	}
 	// Reconcile old networks with the recently added `--ipv6` flag
 	if !n.enableIPv6 {
 		n.enableIPv6 = len(n.ipamV6Info) > 0
@@ -640,6 +698,29 @@ func NetworkOptionDynamic() NetworkOption {
 	}
 }
 
+// NetworkOptionDNSSearch function returns an option setter for the DNS search
+// domains which are appended to the resolv.conf of every container joining the network
+func NetworkOptionDNSSearch(search []string) NetworkOption {
+	return func(n *network) {
+		n.dnsSearch = nil
+		for _, d := range search {
+			if d = strings.TrimSuffix(strings.TrimSpace(d), "."); d != "" {
+				n.dnsSearch = append(n.dnsSearch, d)
+			}
+		}
+	}
+}
+
+// NetworkOptionStaticBindings function returns an option setter for the
+// addresses statically bound to endpoint names. Each name is bound to an
+// IPv4 and or an IPv6 address, comma separated, which are reserved with
+// the network and handed out to the endpoint of the name only.
+func NetworkOptionStaticBindings(bindings map[string]string) NetworkOption {
+	return func(n *network) {
+		n.staticBindings = bindings
+	}
+}
+
 // NetworkOptionDeferIPv6Alloc instructs the network to defer the IPV6 address allocation until after the endpoint has been created
 // It is being provided to support the specific docker daemon flags where user can deterministically assign an IPv6 address
 // to a container as combination of fixed-cidr-v6 + mac-address
@@ -754,18 +835,18 @@ func (n *network) delete(force bool) error {
 		log.Warnf("Failed to update store after ipam release for network %s (%s): %v", n.Name(), n.ID(), err)
 	}
 
-	// deleteFromStore performs an atomic delete operation and the
+	// deleteTxnFromStore performs an atomic delete operation and the
 	// network.epCnt will help prevent any possible
 	// race between endpoint join and network delete
-	if err = c.deleteFromStore(n.getEpCnt()); err != nil {
+	if err = c.deleteTxnFromStore(n.getEpCnt(), n); err != nil {
 		if !force {
-			return fmt.Errorf("error deleting network endpoint count from store: %v", err)
+			return fmt.Errorf("error deleting network and its endpoint count from store: %v", err)
 		}
 		log.Debugf("Error deleting endpoint count from store for stale network %s (%s) for deletion: %v", n.Name(), n.ID(), err)
-	}
 
-	if err = c.deleteFromStore(n); err != nil {
-		return fmt.Errorf("error deleting network from store: %v", err)
+		if err = c.deleteFromStore(n); err != nil {
+			return fmt.Errorf("error deleting network from store: %v", err)
+		}
 	}
 
 	n.cancelDriverWatches()
@@ -858,6 +939,11 @@ func (n *network) CreateEndpoint(name string, options ...EndpointOption) (Endpoi
 		ep.ipamOptions[netlabel.MacAddress] = ep.iface.mac.String()
 	}
 
+	// the quota enforcers find the tenant in the labels
+	if _, ok := ipam.(ipamapi.QuotaEnforcer); ok || cap.RequiresLabels {
+		ep.addIpamLabels()
+	}
+
 	if err = ep.assignAddress(ipam, true, n.enableIPv6 && !n.postIPv6); err != nil {
 		return nil, err
 	}
@@ -882,12 +968,14 @@ func (n *network) CreateEndpoint(name string, options ...EndpointOption) (Endpoi
 		return nil, err
 	}
 
-	if err = n.getController().updateToStore(ep); err != nil {
+	// Increment endpoint count along with the endpoint addition, to
+	// indicate its completion
+	if err = n.getEpCnt().incEndpointCntWith(ep); err != nil {
 		return nil, err
 	}
 	defer func() {
 		if err != nil {
-			if e := n.getController().deleteFromStore(ep); e != nil {
+			if e := n.getEpCnt().decEndpointCntWith(ep); e != nil {
 				log.Warnf("error rolling back endpoint %s from store: %v", name, e)
 			}
 		}
@@ -901,11 +989,6 @@ func (n *network) CreateEndpoint(name string, options ...EndpointOption) (Endpoi
 		}
 	}()
 
-	// Increment endpoint count to indicate completion of endpoint addition
-	if err = n.getEpCnt().IncEndpointCnt(); err != nil {
-		return nil, err
-	}
-
 	return ep, nil
 }
 
@@ -1003,6 +1086,41 @@ func (n *network) updateSvcRecord(ep *endpoint, localEps []*endpoint, isAdd bool
 				n.deleteSvcRecords(alias, iface.Address().IP, ipv6, false)
 			}
 		}
+
+		// The secondary addresses only resolve back to the endpoint
+		// name, which resolves to the primary address.
+		ptrName := epName
+		if ep.isAnonymous() {
+			ptrName = ""
+			if len(myAliases) > 0 {
+				ptrName = myAliases[0]
+			}
+		}
+		if ptrName != "" {
+			n.updateSecondaryIPRecords(ptrName, append(iface.SecondaryAddresses(), iface.SecondaryAddressesIPv6()...), isAdd)
+		}
+	}
+}
+
+func (n *network) updateSecondaryIPRecords(name string, addrs []*net.IPNet, isAdd bool) {
+	if len(addrs) == 0 {
+		return
+	}
+
+	c := n.getController()
+	c.Lock()
+	defer c.Unlock()
+	sr, ok := c.svcRecords[n.ID()]
+	if !ok {
+		return
+	}
+
+	for _, addr := range addrs {
+		if isAdd {
+			addIPToName(sr.ipMap, name, addr.IP)
+		} else {
+			delete(sr.ipMap, netutils.ReverseIP(addr.IP.String()))
+		}
 	}
 }
 
@@ -1014,6 +1132,7 @@ func addIPToName(ipMap map[string]string, name string, ip net.IP) {
 }
 
 func addNameToIP(svcMap map[string][]net.IP, name string, epIP net.IP) {
+	name = netutils.NormalizeDNSName(name)
 	ipList := svcMap[name]
 	for _, ip := range ipList {
 		if ip.Equal(epIP) {
@@ -1024,6 +1143,7 @@ func addNameToIP(svcMap map[string][]net.IP, name string, epIP net.IP) {
 }
 
 func delNameToIP(svcMap map[string][]net.IP, name string, epIP net.IP) {
+	name = netutils.NormalizeDNSName(name)
 	ipList := svcMap[name]
 	for i, ip := range ipList {
 		if ip.Equal(epIP) {
@@ -1063,6 +1183,8 @@ func (n *network) addSvcRecords(name string, epIP net.IP, epIPv6 net.IP, ipMapUp
 	if epIPv6 != nil {
 		addNameToIP(sr.svcIPv6Map, name, epIPv6)
 	}
+
+	n.notifySvcRecord(true, name, epIP, epIPv6)
 }
 
 func (n *network) deleteSvcRecords(name string, epIP net.IP, epIPv6 net.IP, ipMapUpdate bool) {
@@ -1087,6 +1209,40 @@ func (n *network) deleteSvcRecords(name string, epIP net.IP, epIPv6 net.IP, ipMa
 	if epIPv6 != nil {
 		delNameToIP(sr.svcIPv6Map, name, epIPv6)
 	}
+
+	n.notifySvcRecord(false, name, epIP, epIPv6)
+}
+
+func (n *network) addSvcTXTRecords(name string, txt []string) {
+	c := n.getController()
+	c.Lock()
+	defer c.Unlock()
+	sr, ok := c.svcRecords[n.ID()]
+	if !ok {
+		sr = svcInfo{
+			svcMap:     make(map[string][]net.IP),
+			svcIPv6Map: make(map[string][]net.IP),
+			ipMap:      make(map[string]string),
+		}
+	}
+	if sr.txtMap == nil {
+		sr.txtMap = make(map[string][]string)
+	}
+	c.svcRecords[n.ID()] = sr
+
+	sr.txtMap[netutils.NormalizeDNSName(name)] = txt
+}
+
+func (n *network) deleteSvcTXTRecords(name string) {
+	c := n.getController()
+	c.Lock()
+	defer c.Unlock()
+	sr, ok := c.svcRecords[n.ID()]
+	if !ok || sr.txtMap == nil {
+		return
+	}
+
+	delete(sr.txtMap, netutils.NormalizeDNSName(name))
 }
 
 func (n *network) getSvcRecords(ep *endpoint) []etchosts.Record {
@@ -1097,7 +1253,7 @@ func (n *network) getSvcRecords(ep *endpoint) []etchosts.Record {
 	sr, _ := n.ctrlr.svcRecords[n.id]
 
 	for h, ip := range sr.svcMap {
-		if ep != nil && strings.Split(h, ".")[0] == ep.Name() {
+		if ep != nil && strings.EqualFold(strings.Split(h, ".")[0], ep.Name()) {
 			continue
 		}
 
@@ -1122,11 +1278,18 @@ func (n *network) ipamAllocate() error {
 		return nil
 	}
 
-	ipam, _, err := n.getController().getIPAMDriver(n.ipamType)
+	ipam, cap, err := n.getController().getIPAMDriver(n.ipamType)
 	if err != nil {
 		return err
 	}
 
+	if n.enableIPv6 && !cap.SupportsIPv6 {
+		return types.BadRequestErrorf("%s ipam driver does not support IPv6 on network %s", n.ipamType, n.Name())
+	}
+	if _, ok := n.ipamOptions[ipamapi.AllocationStrategy]; ok && !cap.SupportsAllocationStrategy {
+		return types.BadRequestErrorf("%s ipam driver does not support allocation strategies on network %s", n.ipamType, n.Name())
+	}
+
 	if n.addrSpace == "" {
 		if n.addrSpace, err = n.deriveAddressSpace(); err != nil {
 			return err
@@ -1144,19 +1307,33 @@ func (n *network) ipamAllocate() error {
 		}
 	}()
 
-	if !n.enableIPv6 {
-		return nil
-	}
-
-	err = n.ipamAllocateVersion(6, ipam)
-	if err != nil {
-		return err
+	if n.enableIPv6 {
+		if err = n.ipamAllocateVersion(6, ipam); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				n.ipamReleaseVersion(6, ipam)
+			}
+		}()
 	}
 
-	return nil
+	err = n.reserveStaticAddresses(ipam)
+	return err
 }
 
 func (n *network) requestPoolHelper(ipam ipamapi.Ipam, addressSpace, preferredPool, subPool string, options map[string]string, v6 bool) (string, *net.IPNet, map[string]string, error) {
+	// The pool chosen by the user is validated before being reserved
+	if _, nw, err := net.ParseCIDR(preferredPool); err == nil && n.Scope() != datastore.GlobalScope {
+		conflicts, err := n.poolConflicts(addressSpace, nw)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if len(conflicts) > 0 {
+			return "", nil, nil, &PoolConflictError{Pool: nw, Conflicts: conflicts}
+		}
+	}
+
 	for {
 		poolID, pool, meta, err := ipam.RequestPool(addressSpace, preferredPool, subPool, options, v6)
 		if err != nil {
@@ -1171,7 +1348,10 @@ func (n *network) requestPoolHelper(ipam ipamapi.Ipam, addressSpace, preferredPo
 
 		// Check for overlap and if none found, we have found the right pool.
 		if _, err := netutils.FindAvailableNetwork([]*net.IPNet{pool}); err == nil {
-			return poolID, pool, meta, nil
+			conflicts, err := n.poolConflicts(addressSpace, pool)
+			if err == nil && len(conflicts) == 0 {
+				return poolID, pool, meta, nil
+			}
 		}
 
 		// Pool obtained in this iteration is
@@ -1197,6 +1377,55 @@ func (n *network) requestPoolHelper(ipam ipamapi.Ipam, addressSpace, preferredPo
 	}
 }
 
+// poolConflicts returns the subnets of the other networks and, for the
+// networks routed by the host, the routes of the host the pool overlaps
+// with. The networks of the same ipam driver and address space may share
+// the very same pool, the ipam driver arbitrating it.
+func (n *network) poolConflicts(addressSpace string, pool *net.IPNet) ([]PoolConflict, error) {
+	var conflicts []PoolConflict
+
+	networks, err := n.getController().getNetworksFromStore()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range networks {
+		if o.ID() == n.ID() || o.Type() == "host" || o.Type() == "null" {
+			continue
+		}
+		for _, i := range append(o.getIPInfo(4), o.getIPInfo(6)...) {
+			if i.Pool == nil || !netutils.NetworkOverlaps(pool, i.Pool) {
+				continue
+			}
+			if o.ipamType == n.ipamType && o.addrSpace == addressSpace && i.Pool.String() == pool.String() {
+				continue
+			}
+			conflicts = append(conflicts, PoolConflict{Subnet: i.Pool, NetworkID: o.ID(), NetworkName: o.Name()})
+		}
+	}
+
+	if n.Type() != "bridge" {
+		return conflicts, nil
+	}
+	routes, err := netutils.FindRouteOverlaps(pool)
+	if err != nil {
+		return nil, err
+	}
+	// the bridge driver names the bridge of the network after its id
+	// unless told otherwise
+	bridgeName := n.DriverOptions()["com.docker.network.bridge.name"]
+	if bridgeName == "" && len(n.ID()) >= 12 {
+		bridgeName = "br-" + n.ID()[:12]
+	}
+	for _, r := range routes {
+		// the route of a pre-existing bridge the network adopts
+		if r.Interface != "" && r.Interface == bridgeName {
+			continue
+		}
+		conflicts = append(conflicts, PoolConflict{Subnet: r.Dst, Interface: r.Interface})
+	}
+	return conflicts, nil
+}
+
 func (n *network) ipamAllocateVersion(ipVer int, ipam ipamapi.Ipam) error {
 	var (
 		cfgList  *[]*IpamConf
@@ -1216,7 +1445,7 @@ func (n *network) ipamAllocateVersion(ipVer int, ipam ipamapi.Ipam) error {
 	}
 
 	if len(*cfgList) == 0 {
-		if ipVer == 6 {
+		if ipVer == 6 && !n.carvesDelegatedPrefix() {
 			return nil
 		}
 		*cfgList = []*IpamConf{{}}
@@ -1246,45 +1475,266 @@ func (n *network) ipamAllocateVersion(ipVer int, ipam ipamapi.Ipam) error {
 			}
 		}()
 
-		if gws, ok := d.Meta[netlabel.Gateway]; ok {
-			if d.Gateway, err = types.ParseCIDR(gws); err != nil {
-				return types.BadRequestErrorf("failed to parse gateway address (%v) returned by ipam driver: %v", gws, err)
-			}
+		if err = n.ipamAllocateAddresses(ipam, cfg, d); err != nil {
+			return err
 		}
+	}
 
-		// If user requested a specific gateway, libnetwork will allocate it
-		// irrespective of whether ipam driver returned a gateway already.
-		// If none of the above is true, libnetwork will allocate one.
-		if cfg.Gateway != "" || d.Gateway == nil {
-			var gatewayOpts = map[string]string{
-				ipamapi.RequestAddressType: netlabel.Gateway,
+	return nil
+}
+
+// ipamAllocateAddresses reserves the gateway and the auxiliary addresses
+// of the passed configuration in the pool already requested for it.
+func (n *network) ipamAllocateAddresses(ipam ipamapi.Ipam, cfg *IpamConf, d *IpamInfo) error {
+	var err error
+
+	if gws, ok := d.Meta[netlabel.Gateway]; ok {
+		if d.Gateway, err = types.ParseCIDR(gws); err != nil {
+			return types.BadRequestErrorf("failed to parse gateway address (%v) returned by ipam driver: %v", gws, err)
+		}
+	}
+
+	// If user requested a specific gateway, libnetwork will allocate it
+	// irrespective of whether ipam driver returned a gateway already.
+	// If none of the above is true, libnetwork will allocate one.
+	if cfg.Gateway != "" || d.Gateway == nil {
+		var gatewayOpts = map[string]string{
+			ipamapi.RequestAddressType: netlabel.Gateway,
+		}
+		if d.Gateway, _, err = ipam.RequestAddress(d.PoolID, net.ParseIP(cfg.Gateway), gatewayOpts); err != nil {
+			return types.InternalErrorf("failed to allocate gateway (%v): %v", cfg.Gateway, err)
+		}
+		n.auditAddress(AddressAllocated, AddressRoleGateway, d.PoolID, d.Gateway.IP, nil)
+	}
+
+	// Auxiliary addresses must be part of the master address pool
+	// If they fall into the container addressable pool, libnetwork will reserve them
+	if cfg.AuxAddresses != nil {
+		var ip net.IP
+		d.IPAMData.AuxAddresses = make(map[string]*net.IPNet, len(cfg.AuxAddresses))
+		for k, v := range cfg.AuxAddresses {
+			if ip = net.ParseIP(v); ip == nil {
+				return types.BadRequestErrorf("non parsable secondary ip address (%s:%s) passed for network %s", k, v, n.Name())
 			}
-			if d.Gateway, _, err = ipam.RequestAddress(d.PoolID, net.ParseIP(cfg.Gateway), gatewayOpts); err != nil {
-				return types.InternalErrorf("failed to allocate gateway (%v): %v", cfg.Gateway, err)
+			if !d.Pool.Contains(ip) {
+				return types.ForbiddenErrorf("auxilairy address: (%s:%s) must belong to the master pool: %s", k, v, d.Pool)
+			}
+			// Attempt reservation in the container addressable pool, silent the error if address does not belong to that pool
+			if d.IPAMData.AuxAddresses[k], _, err = ipam.RequestAddress(d.PoolID, ip, nil); err != nil && err != ipamapi.ErrIPOutOfRange {
+				return types.InternalErrorf("failed to allocate secondary ip address (%s:%s): %v", k, v, err)
+			}
+			if err == nil {
+				n.auditAddress(AddressAllocated, AddressRoleAuxiliary, d.PoolID, ip, nil)
 			}
 		}
+	}
 
-		// Auxiliary addresses must be part of the master address pool
-		// If they fall into the container addressable pool, libnetwork will reserve them
-		if cfg.AuxAddresses != nil {
-			var ip net.IP
-			d.IPAMData.AuxAddresses = make(map[string]*net.IPNet, len(cfg.AuxAddresses))
-			for k, v := range cfg.AuxAddresses {
-				if ip = net.ParseIP(v); ip == nil {
-					return types.BadRequestErrorf("non parsable secondary ip address (%s:%s) passed for network %s", k, v, n.Name())
-				}
-				if !d.Pool.Contains(ip) {
-					return types.ForbiddenErrorf("auxilairy address: (%s:%s) must belong to the master pool: %s", k, v, d.Pool)
-				}
-				// Attempt reservation in the container addressable pool, silent the error if address does not belong to that pool
-				if d.IPAMData.AuxAddresses[k], _, err = ipam.RequestAddress(d.PoolID, ip, nil); err != nil && err != ipamapi.ErrIPOutOfRange {
-					return types.InternalErrorf("failed to allocate secondary ip address (%s:%s): %v", k, v, err)
+	return nil
+}
+
+func (n *network) AddExternalPeer(peer *driverapi.ExternalPeer) error {
+	if peer == nil || peer.Address == nil || peer.MacAddress == nil || peer.Vtep == nil {
+		return types.BadRequestErrorf("external peer of network %s needs an address, a mac address and a vtep", n.Name())
+	}
+	epr, err := n.externalPeerRegistrar()
+	if err != nil {
+		return err
+	}
+	return epr.AddExternalPeer(n.ID(), peer)
+}
+
+func (n *network) DeleteExternalPeer(address net.IP) error {
+	epr, err := n.externalPeerRegistrar()
+	if err != nil {
+		return err
+	}
+	return epr.DeleteExternalPeer(n.ID(), address)
+}
+
+func (n *network) externalPeerRegistrar() (driverapi.ExternalPeerRegistrar, error) {
+	d, err := n.driver(true)
+	if err != nil {
+		return nil, err
+	}
+	epr, ok := d.(driverapi.ExternalPeerRegistrar)
+	if !ok {
+		return nil, types.NotImplementedErrorf("%s driver does not support external peers in network %s", n.Type(), n.Name())
+	}
+	return epr, nil
+}
+
+func (n *network) PeerStatus() ([]*driverapi.PeerStatus, error) {
+	d, err := n.driver(true)
+	if err != nil {
+		return nil, err
+	}
+	pd, ok := d.(driverapi.PeerDiagnostics)
+	if !ok {
+		return nil, types.NotImplementedErrorf("%s driver does not probe the peers of network %s", n.Type(), n.Name())
+	}
+	return pd.PeerStatus(n.ID())
+}
+
+func (n *network) AddSubnet(conf *IpamConf) error {
+	if conf == nil {
+		conf = &IpamConf{}
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	d, err := n.driver(true)
+	if err != nil {
+		return err
+	}
+	se, ok := d.(driverapi.SubnetExtender)
+	if !ok {
+		return types.NotImplementedErrorf("%s driver does not support adding subnets to network %s", n.Type(), n.Name())
+	}
+
+	c := n.getController()
+	ipam, _, err := c.getIPAMDriver(n.ipamType)
+	if err != nil {
+		return err
+	}
+
+	info := &IpamInfo{}
+	info.PoolID, info.Pool, info.Meta, err = n.requestPoolHelper(ipam, n.addrSpace, conf.PreferredPool, conf.SubPool, n.ipamOptions, false)
+	if err != nil {
+		return err
+	}
+
+	return n.addSubnet(ipam, se, conf, info)
+}
+
+// addSubnet reserves the gateway and the auxiliary addresses of the
+// configuration in the pool requested for it and adds the pool to the
+// network. The pool is released on failure.
+func (n *network) addSubnet(ipam ipamapi.Ipam, se driverapi.SubnetExtender, conf *IpamConf, info *IpamInfo) (err error) {
+	defer func() {
+		if err != nil {
+			n.ipamReleaseInfo(ipam, info)
+		}
+	}()
+
+	if err = n.ipamAllocateAddresses(ipam, conf, info); err != nil {
+		return err
+	}
+
+	if err = se.AddSubnet(n.ID(), info.IPAMData); err != nil {
+		return err
+	}
+
+	c := n.getController()
+	n.Lock()
+	n.ipamV4Config = append(n.ipamV4Config, conf)
+	n.ipamV4Info = append(n.ipamV4Info, info)
+	n.Unlock()
+
+	if err = c.updateToStore(n); err != nil {
+		n.Lock()
+		n.ipamV4Config = n.ipamV4Config[:len(n.ipamV4Config)-1]
+		n.ipamV4Info = n.ipamV4Info[:len(n.ipamV4Info)-1]
+		n.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// autoExpandLimit returns the number of subnets the network may be
+// expanded with when its IPv4 pools are exhausted
+func (n *network) autoExpandLimit() (int, error) {
+	v, ok := n.ipamOptions[ipamapi.AutoExpand]
+	if !ok {
+		return 0, nil
+	}
+	max, err := strconv.Atoi(v)
+	if err != nil || max < 0 {
+		return 0, types.BadRequestErrorf("invalid %s ipam option %q: must be a number of subnets", ipamapi.AutoExpand, v)
+	}
+	return max, nil
+}
+
+// expandPool adds to the network the subnet adjacent to one of its IPv4
+// pools, all of them being exhausted, unless it already got the number
+// of subnets its auto expansion ipam option allows. It returns the pools
+// added, which are the ones another endpoint creation added in the
+// meantime if any.
+func (n *network) expandPool(ipam ipamapi.Ipam) ([]*IpamInfo, error) {
+	max, err := n.autoExpandLimit()
+	if err != nil || max == 0 {
+		return nil, err
+	}
+	pe, ok := ipam.(ipamapi.PoolExpander)
+	if !ok {
+		return nil, nil
+	}
+	d, err := n.driver(true)
+	if err != nil {
+		return nil, err
+	}
+	se, ok := d.(driverapi.SubnetExtender)
+	if !ok {
+		return nil, nil
+	}
+
+	c := n.getController()
+	c.expandMu.Lock()
+	defer c.expandMu.Unlock()
+
+	cur, err := c.getNetworkFromStore(n.ID())
+	if err != nil {
+		return nil, err
+	}
+	seen := len(n.getIPInfo(4))
+	infos := cur.getIPInfo(4)
+	if len(infos) > seen {
+		return infos[seen:], nil
+	}
+
+	expanded := 0
+	for _, i := range infos {
+		if _, ok := i.Meta[ipamapi.AutoExpand]; ok {
+			expanded++
+		}
+	}
+	if expanded >= max {
+		return nil, nil
+	}
+
+	for i := len(infos) - 1; i >= 0; i-- {
+		info := &IpamInfo{}
+		info.PoolID, info.Pool, info.Meta, err = pe.ExpandPool(infos[i].PoolID, cur.ipamOptions)
+		if err != nil {
+			if _, ok := err.(types.BadRequestError); ok || err == ipamapi.ErrNoAvailablePool {
+				continue
+			}
+			return nil, err
+		}
+
+		if cur.Scope() != datastore.GlobalScope {
+			if _, err := netutils.FindAvailableNetwork([]*net.IPNet{info.Pool}); err != nil {
+				log.Debugf("Subnet %s adjacent to network %s overlaps in the host", info.Pool, n.Name())
+				if err := ipam.ReleasePool(info.PoolID); err != nil {
+					log.Warnf("Failed to release overlapping pool %s of network %s: %v", info.Pool, n.Name(), err)
 				}
+				continue
 			}
 		}
+
+		if info.Meta == nil {
+			info.Meta = make(map[string]string)
+		}
+		info.Meta[ipamapi.AutoExpand] = "true"
+		if err := cur.addSubnet(ipam, se, &IpamConf{PreferredPool: info.Pool.String()}, info); err != nil {
+			return nil, err
+		}
+		log.Infof("Expanded network %s with subnet %s, its address pools being exhausted", n.Name(), info.Pool)
+		return []*IpamInfo{info}, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
 func (n *network) ipamRelease() {
@@ -1297,8 +1747,10 @@ func (n *network) ipamRelease() {
 		log.Warnf("Failed to retrieve ipam driver to release address pool(s) on delete of network %s (%s): %v", n.Name(), n.ID(), err)
 		return
 	}
+	n.releaseStaticAddresses(ipam)
 	n.ipamReleaseVersion(4, ipam)
 	n.ipamReleaseVersion(6, ipam)
+	n.getController().dropPoolLevels(n.ID())
 }
 
 func (n *network) ipamReleaseVersion(ipVer int, ipam ipamapi.Ipam) {
@@ -1321,26 +1773,106 @@ func (n *network) ipamReleaseVersion(ipVer int, ipam ipamapi.Ipam) {
 	log.Debugf("releasing IPv%d pools from network %s (%s)", ipVer, n.Name(), n.ID())
 
 	for _, d := range *infoList {
-		if d.Gateway != nil {
-			if err := ipam.ReleaseAddress(d.PoolID, d.Gateway.IP); err != nil {
-				log.Warnf("Failed to release gateway ip address %s on delete of network %s (%s): %v", d.Gateway.IP, n.Name(), n.ID(), err)
-			}
+		n.ipamReleaseInfo(ipam, d)
+	}
+
+	*infoList = nil
+}
+
+func (n *network) ipamReleaseInfo(ipam ipamapi.Ipam, d *IpamInfo) {
+	if d.Gateway != nil {
+		if err := ipam.ReleaseAddress(d.PoolID, d.Gateway.IP); err != nil {
+			log.Warnf("Failed to release gateway ip address %s on delete of network %s (%s): %v", d.Gateway.IP, n.Name(), n.ID(), err)
+		} else {
+			n.auditAddress(AddressReleased, AddressRoleGateway, d.PoolID, d.Gateway.IP, nil)
 		}
-		if d.IPAMData.AuxAddresses != nil {
-			for k, nw := range d.IPAMData.AuxAddresses {
-				if d.Pool.Contains(nw.IP) {
-					if err := ipam.ReleaseAddress(d.PoolID, nw.IP); err != nil && err != ipamapi.ErrIPOutOfRange {
-						log.Warnf("Failed to release secondary ip address %s (%v) on delete of network %s (%s): %v", k, nw.IP, n.Name(), n.ID(), err)
-					}
+	}
+	if d.IPAMData.AuxAddresses != nil {
+		for k, nw := range d.IPAMData.AuxAddresses {
+			if d.Pool.Contains(nw.IP) {
+				err := ipam.ReleaseAddress(d.PoolID, nw.IP)
+				if err != nil && err != ipamapi.ErrIPOutOfRange {
+					log.Warnf("Failed to release secondary ip address %s (%v) on delete of network %s (%s): %v", k, nw.IP, n.Name(), n.ID(), err)
+				}
+				if err == nil {
+					n.auditAddress(AddressReleased, AddressRoleAuxiliary, d.PoolID, nw.IP, nil)
 				}
 			}
 		}
-		if err := ipam.ReleasePool(d.PoolID); err != nil {
-			log.Warnf("Failed to release address pool %s on delete of network %s (%s): %v", d.PoolID, n.Name(), n.ID(), err)
+	}
+	if err := ipam.ReleasePool(d.PoolID); err != nil {
+		log.Warnf("Failed to release address pool %s on delete of network %s (%s): %v", d.PoolID, n.Name(), n.ID(), err)
+	}
+}
+
+func (c *controller) RequestVIP(nid string) (net.IP, error) {
+	nw, err := c.NetworkByID(nid)
+	if err != nil {
+		return nil, err
+	}
+	return nw.(*network).requestVIP()
+}
+
+func (c *controller) ReleaseVIP(nid string, vip net.IP) error {
+	nw, err := c.NetworkByID(nid)
+	if err != nil {
+		return err
+	}
+	return nw.(*network).releaseVIP(vip)
+}
+
+func (n *network) requestVIP() (net.IP, error) {
+	ipam, _, err := n.getController().getIPAMDriver(n.ipamType)
+	if err != nil {
+		return nil, err
+	}
+
+	info := n.getIPInfo(4)
+	if len(info) == 0 {
+		return nil, types.ForbiddenErrorf("network %s has no IPv4 pool to allocate a VIP from", n.Name())
+	}
+	d := info[0]
+	poolID := d.PoolID
+
+	var vipPool string
+	if c := n.getController(); c.cfg != nil {
+		vipPool = c.cfg.Daemon.VIPPool
+	}
+	if ip, _, err := net.ParseCIDR(vipPool); err == nil && d.Pool.Contains(ip) {
+		// The sub pool shares the allocations of the network pool,
+		// it is only needed for the duration of the request.
+		subPoolID, _, _, err := ipam.RequestPool(n.addrSpace, d.Pool.String(), vipPool, nil, false)
+		if err != nil {
+			return nil, err
 		}
+		defer ipam.ReleasePool(subPoolID)
+		poolID = subPoolID
 	}
 
-	*infoList = nil
+	addr, _, err := ipam.RequestAddress(poolID, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate a VIP on network %s: %v", n.Name(), err)
+	}
+	n.auditAddress(AddressAllocated, AddressRoleVIP, d.PoolID, addr.IP, nil)
+	return addr.IP, nil
+}
+
+func (n *network) releaseVIP(vip net.IP) error {
+	ipam, _, err := n.getController().getIPAMDriver(n.ipamType)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range n.getIPInfo(4) {
+		if d.Pool.Contains(vip) {
+			if err := ipam.ReleaseAddress(d.PoolID, vip); err != nil {
+				return err
+			}
+			n.auditAddress(AddressReleased, AddressRoleVIP, d.PoolID, vip, nil)
+			return nil
+		}
+	}
+	return types.BadRequestErrorf("VIP %s does not belong to network %s", vip, n.Name())
 }
 
 func (n *network) getIPInfo(ipVer int) []*IpamInfo {
@@ -1490,6 +2022,16 @@ func (n *network) Labels() map[string]string {
 	return lbls
 }
 
+func (n *network) DNSSearch() []string {
+	n.Lock()
+	defer n.Unlock()
+
+	search := make([]string, len(n.dnsSearch))
+	copy(search, n.dnsSearch)
+
+	return search
+}
+
 func (n *network) TableEventRegister(tableName string) error {
 	n.Lock()
 	defer n.Unlock()
@@ -1497,3 +2039,48 @@ func (n *network) TableEventRegister(tableName string) error {
 	n.driverTables = append(n.driverTables, tableName)
 	return nil
 }
+
+func (n *network) UpdateTableEntry(tableName, key string, value []byte) error {
+	for _, e := range n.Endpoints() {
+		ep := e.(*endpoint)
+		ep.Lock()
+		if ep.joinInfo != nil {
+			for _, te := range ep.joinInfo.driverTableEntries {
+				if te.tableName == tableName && te.key == key {
+					te.value = value
+				}
+			}
+		}
+		ep.Unlock()
+	}
+
+	c := n.getController()
+	if c.agent == nil || !n.isClusterEligible() {
+		return nil
+	}
+	return c.agent.networkDB.UpdateEntry(tableName, n.ID(), key, value)
+}
+
+func (n *network) AddTableEntry(tableName, key string, value []byte) error {
+	c := n.getController()
+	if c.agent == nil || !n.isClusterEligible() {
+		return nil
+	}
+	return c.agent.networkDB.CreateEntry(tableName, n.ID(), key, value)
+}
+
+func (n *network) DeleteTableEntry(tableName, key string) error {
+	c := n.getController()
+	if c.agent == nil || !n.isClusterEligible() {
+		return nil
+	}
+	return c.agent.networkDB.DeleteEntry(tableName, n.ID(), key)
+}
+
+func (n *network) WalkTable(tableName string, fn func(key string, value []byte) bool) error {
+	c := n.getController()
+	if c.agent == nil || !n.isClusterEligible() {
+		return fmt.Errorf("network %s is not attached to the cluster", n.ID())
+	}
+	return c.agent.networkDB.WalkNetworkTable(tableName, n.ID(), fn)
+}
@@ -331,6 +331,31 @@ func (nDB *NetworkDB) WalkTable(tname string, fn func(string, string, []byte) bo
 	return nil
 }
 
+// WalkNetworkTable walks the entries of a network in a table, skipping
+// the ones being deleted, and invokes the passed function for each
+// passing the key and value. The walk stops if the passed function
+// returns a true.
+func (nDB *NetworkDB) WalkNetworkTable(tname, nid string, fn func(string, []byte) bool) error {
+	nDB.RLock()
+	values := make(map[string][]byte)
+	prefix := fmt.Sprintf("/%s/%s/", tname, nid)
+	nDB.indexes[byTable].WalkPrefix(prefix, func(path string, v interface{}) bool {
+		if e := v.(*entry); !e.deleting {
+			values[path[len(prefix):]] = e.value
+		}
+		return false
+	})
+	nDB.RUnlock()
+
+	for key, value := range values {
+		if fn(key, value) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
 // JoinNetwork joins this node to a given network and propogates this
 // event across the cluster. This triggers this node joining the
 // sub-cluster of this network and participates in the network-scoped
@@ -24,6 +24,7 @@ type nwIface struct {
 	mac         net.HardwareAddr
 	address     *net.IPNet
 	addressIPv6 *net.IPNet
+	secondary   []*net.IPNet
 	routes      []*net.IPNet
 	bridge      bool
 	ns          *networkNamespace
@@ -86,6 +87,18 @@ func (i *nwIface) AddressIPv6() *net.IPNet {
 	return types.GetIPNetCopy(i.addressIPv6)
 }
 
+func (i *nwIface) SecondaryAddresses() []*net.IPNet {
+	i.Lock()
+	defer i.Unlock()
+
+	addrs := make([]*net.IPNet, len(i.secondary))
+	for index, addr := range i.secondary {
+		addrs[index] = types.GetIPNetCopy(addr)
+	}
+
+	return addrs
+}
+
 func (i *nwIface) Routes() []*net.IPNet {
 	i.Lock()
 	defer i.Unlock()
@@ -315,6 +328,7 @@ func configureInterface(iface netlink.Link, i *nwIface) error {
 		{setInterfaceMAC, fmt.Sprintf("error setting interface %q MAC to %q", ifaceName, i.MacAddress())},
 		{setInterfaceIP, fmt.Sprintf("error setting interface %q IP to %v", ifaceName, i.Address())},
 		{setInterfaceIPv6, fmt.Sprintf("error setting interface %q IPv6 to %v", ifaceName, i.AddressIPv6())},
+		{setInterfaceSecondaryIPs, fmt.Sprintf("error setting interface %q secondary IPs to %v", ifaceName, i.SecondaryAddresses())},
 		{setInterfaceMaster, fmt.Sprintf("error setting interface %q master to %q", ifaceName, i.DstMaster())},
 	}
 
@@ -359,6 +373,19 @@ func setInterfaceIPv6(iface netlink.Link, i *nwIface) error {
 	return netlink.AddrAdd(iface, ipAddr)
 }
 
+func setInterfaceSecondaryIPs(iface netlink.Link, i *nwIface) error {
+	for _, addr := range i.SecondaryAddresses() {
+		ipAddr := &netlink.Addr{IPNet: addr, Label: ""}
+		if addr.IP.To4() == nil {
+			ipAddr.Flags = syscall.IFA_F_NODAD
+		}
+		if err := netlink.AddrAdd(iface, ipAddr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func setInterfaceName(iface netlink.Link, i *nwIface) error {
 	return netlink.LinkSetName(iface, i.DstName())
 }
@@ -2,10 +2,12 @@ package osl
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -150,6 +152,63 @@ func NewSandbox(key string, osCreate bool) (Sandbox, error) {
 	return &networkNamespace{path: key, isDefault: !osCreate}, nil
 }
 
+// OpenSandbox returns the sandbox of the network namespace a previous run
+// mounted at the key, adopting its interfaces. The interfaces are known
+// by the source name passed for their name in the namespace, or by the
+// latter. The neighbor entries are not adopted, adding them again
+// replaces the existing ones.
+func OpenSandbox(key string, srcNames map[string]string) (Sandbox, error) {
+	nsh, err := netns.GetFromPath(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the network namespace at %s: %v", key, err)
+	}
+	nsh.Close()
+
+	n := &networkNamespace{path: key}
+	err = n.InvokeFunc(func() {
+		var links []netlink.Link
+		if links, err = netlink.LinkList(); err != nil {
+			return
+		}
+		names := make(map[int]string, len(links))
+		for _, l := range links {
+			names[l.Attrs().Index] = l.Attrs().Name
+		}
+		for _, l := range links {
+			dstName := l.Attrs().Name
+			if dstName == "lo" {
+				continue
+			}
+			i := &nwIface{srcName: dstName, dstName: dstName, bridge: l.Type() == "bridge", ns: n}
+			if src, ok := srcNames[dstName]; ok {
+				i.srcName = src
+			}
+			if l.Attrs().MasterIndex != 0 {
+				i.dstMaster = names[l.Attrs().MasterIndex]
+				i.master = i.dstMaster
+				if src, ok := srcNames[i.dstMaster]; ok {
+					i.master = src
+				}
+			}
+			n.iFaces = append(n.iFaces, i)
+
+			// The new interfaces are named past the adopted ones
+			idx := len(dstName)
+			for idx > 0 && dstName[idx-1] >= '0' && dstName[idx-1] <= '9' {
+				idx--
+			}
+			if v, err := strconv.Atoi(dstName[idx:]); err == nil && v >= n.nextIfIndex {
+				n.nextIfIndex = v + 1
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to adopt the interfaces of the network namespace at %s: %v", key, err)
+	}
+
+	return n, nil
+}
+
 func (n *networkNamespace) InterfaceOptions() IfaceOptionSetter {
 	return n
 }
@@ -243,6 +302,43 @@ func createNamespaceFile(path string) (err error) {
 	return err
 }
 
+func (n *networkNamespace) AddLoopbackAliasIP(ip *net.IPNet) error {
+	return nsInvoke(n.nsPath(), func(nsFD int) error { return nil }, func(callerFD int) error {
+		// Do not answer nor announce the loopback addresses on
+		// the other interfaces.
+		for _, s := range []struct{ path, value string }{
+			{"/proc/sys/net/ipv4/conf/all/arp_ignore", "1"},
+			{"/proc/sys/net/ipv4/conf/all/arp_announce", "2"},
+		} {
+			if err := ioutil.WriteFile(s.path, []byte(s.value+"\n"), 0644); err != nil {
+				return fmt.Errorf("failed to set %s: %v", s.path, err)
+			}
+		}
+
+		iface, err := netlink.LinkByName("lo")
+		if err != nil {
+			return err
+		}
+
+		err = netlink.AddrAdd(iface, &netlink.Addr{IPNet: ip})
+		if err != nil && err != syscall.EEXIST {
+			return err
+		}
+		return nil
+	})
+}
+
+func (n *networkNamespace) RemoveLoopbackAliasIP(ip *net.IPNet) error {
+	return nsInvoke(n.nsPath(), func(nsFD int) error { return nil }, func(callerFD int) error {
+		iface, err := netlink.LinkByName("lo")
+		if err != nil {
+			return err
+		}
+
+		return netlink.AddrDel(iface, &netlink.Addr{IPNet: ip})
+	})
+}
+
 func loopbackUp() error {
 	iface, err := netlink.LinkByName("lo")
 	if err != nil {
@@ -17,6 +17,7 @@ type neigh struct {
 	linkName string
 	linkDst  string
 	family   int
+	append   bool
 }
 
 func (n *networkNamespace) findNeighbor(dstIP net.IP, dstMac net.HardwareAddr) *neigh {
@@ -127,7 +128,11 @@ func (n *networkNamespace) AddNeighbor(dstIP net.IP, dstMac net.HardwareAddr, op
 			nlnh.LinkIndex = iface.Attrs().Index
 		}
 
-		if err := netlink.NeighSet(nlnh); err != nil {
+		add := netlink.NeighSet
+		if nh.append {
+			add = netlink.NeighAppend
+		}
+		if err := add(nlnh); err != nil {
 			return fmt.Errorf("could not add neighbor entry: %v", err)
 		}
 
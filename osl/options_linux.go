@@ -22,6 +22,12 @@ func (n *networkNamespace) Family(family int) NeighOption {
 	}
 }
 
+func (n *networkNamespace) Append() NeighOption {
+	return func(nh *neigh) {
+		nh.append = true
+	}
+}
+
 func (i *nwIface) processInterfaceOptions(options ...IfaceOption) {
 	for _, opt := range options {
 		if opt != nil {
@@ -60,6 +66,12 @@ func (n *networkNamespace) AddressIPv6(addr *net.IPNet) IfaceOption {
 	}
 }
 
+func (n *networkNamespace) SecondaryAddresses(addrs []*net.IPNet) IfaceOption {
+	return func(i *nwIface) {
+		i.secondary = addrs
+	}
+}
+
 func (n *networkNamespace) Routes(routes []*net.IPNet) IfaceOption {
 	return func(i *nwIface) {
 		i.routes = routes
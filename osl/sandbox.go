@@ -38,6 +38,15 @@ type Sandbox interface {
 	// Remove a static route from the sandbox.
 	RemoveStaticRoute(*types.StaticRoute) error
 
+	// AddLoopbackAliasIP adds the passed address to the loopback
+	// interface of the sandbox. The sandbox does not answer ARP
+	// requests for addresses owned by its loopback interface.
+	AddLoopbackAliasIP(ip *net.IPNet) error
+
+	// RemoveLoopbackAliasIP removes the passed address from the
+	// loopback interface of the sandbox.
+	RemoveLoopbackAliasIP(ip *net.IPNet) error
+
 	// AddNeighbor adds a neighbor entry into the sandbox.
 	AddNeighbor(dstIP net.IP, dstMac net.HardwareAddr, option ...NeighOption) error
 
@@ -69,6 +78,11 @@ type NeighborOptionSetter interface {
 	// Family returns an option setter to set the address family for the neighbor
 	// entry. eg. AF_BRIDGE
 	Family(int) NeighOption
+
+	// Append returns an option setter to add the neighbor entry next to
+	// the existing ones for the same address instead of replacing them.
+	// eg. the all-zeros fdb entries of the vxlan devices
+	Append() NeighOption
 }
 
 // IfaceOptionSetter interface defines the option setter methods for interface options.
@@ -85,6 +99,10 @@ type IfaceOptionSetter interface {
 	// Address returns an option setter to set IPv6 address.
 	AddressIPv6(*net.IPNet) IfaceOption
 
+	// SecondaryAddresses returns an option setter to set additional
+	// IPv4 and IPv6 addresses.
+	SecondaryAddresses([]*net.IPNet) IfaceOption
+
 	// Master returns an option setter to set the master interface if any for this
 	// interface. The master interface name should refer to the srcname of a
 	// previously added interface of type bridge.
@@ -138,6 +156,9 @@ type Interface interface {
 	// IPv6 address for the interface.
 	AddressIPv6() *net.IPNet
 
+	// Additional IPv4 and IPv6 addresses for the interface.
+	SecondaryAddresses() []*net.IPNet
+
 	// IP routes for the interface.
 	Routes() []*net.IPNet
 
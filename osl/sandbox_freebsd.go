@@ -19,6 +19,12 @@ func NewSandbox(key string, osCreate bool) (Sandbox, error) {
 	return nil, nil
 }
 
+// OpenSandbox returns the sandbox of the network namespace a previous run
+// mounted at the key
+func OpenSandbox(key string, srcNames map[string]string) (Sandbox, error) {
+	return nil, nil
+}
+
 // GetSandboxForExternalKey returns sandbox object for the supplied path
 func GetSandboxForExternalKey(path string, key string) (Sandbox, error) {
 	return nil, nil
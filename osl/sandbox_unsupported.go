@@ -15,6 +15,12 @@ func NewSandbox(key string, osCreate bool) (Sandbox, error) {
 	return nil, ErrNotImplemented
 }
 
+// OpenSandbox returns the sandbox of the network namespace a previous run
+// mounted at the key
+func OpenSandbox(key string, srcNames map[string]string) (Sandbox, error) {
+	return nil, ErrNotImplemented
+}
+
 // GenerateKey generates a sandbox key based on the passed
 // container id.
 func GenerateKey(containerID string) string {
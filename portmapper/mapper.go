@@ -9,6 +9,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/iptables"
 	"github.com/docker/libnetwork/portallocator"
+	"github.com/docker/libnetwork/types"
 )
 
 type mapping struct {
@@ -111,6 +112,21 @@ func (pm *PortMapper) MapRange(container net.Addr, hostIP net.IP, hostPortStart,
 		} else {
 			m.userlandProxy = newDummyProxy(proto, hostIP, allocatedHostPort)
 		}
+	case *types.SCTPAddr:
+		proto = "sctp"
+		if allocatedHostPort, err = pm.Allocator.RequestPortInRange(hostIP, proto, hostPortStart, hostPortEnd); err != nil {
+			return nil, err
+		}
+
+		m = &mapping{
+			proto:     proto,
+			host:      &types.SCTPAddr{IP: hostIP, Port: allocatedHostPort},
+			container: container,
+		}
+
+		// There is no userland proxy for SCTP, the traffic is
+		// only forwarded by the iptables rules.
+		m.userlandProxy = newDummyProxy(proto, hostIP, allocatedHostPort)
 	default:
 		return nil, ErrUnknownBackendAddressType
 	}
@@ -182,6 +198,8 @@ func (pm *PortMapper) Unmap(host net.Addr) error {
 		return pm.Allocator.ReleasePort(a.IP, "tcp", a.Port)
 	case *net.UDPAddr:
 		return pm.Allocator.ReleasePort(a.IP, "udp", a.Port)
+	case *types.SCTPAddr:
+		return pm.Allocator.ReleasePort(a.IP, "sctp", a.Port)
 	}
 	return nil
 }
@@ -206,6 +224,8 @@ func getKey(a net.Addr) string {
 		return fmt.Sprintf("%s:%d/%s", t.IP.String(), t.Port, "tcp")
 	case *net.UDPAddr:
 		return fmt.Sprintf("%s:%d/%s", t.IP.String(), t.Port, "udp")
+	case *types.SCTPAddr:
+		return fmt.Sprintf("%s:%d/%s", t.IP.String(), t.Port, "sctp")
 	}
 	return ""
 }
@@ -216,6 +236,8 @@ func getIPAndPort(a net.Addr) (net.IP, int) {
 		return t.IP, t.Port
 	case *net.UDPAddr:
 		return t.IP, t.Port
+	case *types.SCTPAddr:
+		return t.IP, t.Port
 	}
 	return nil, 0
 }
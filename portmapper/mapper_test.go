@@ -7,6 +7,7 @@ import (
 
 	"github.com/docker/libnetwork/iptables"
 	_ "github.com/docker/libnetwork/testutils"
+	"github.com/docker/libnetwork/types"
 )
 
 func init() {
@@ -97,6 +98,21 @@ func TestGetTCPKey(t *testing.T) {
 	}
 }
 
+func TestGetSCTPKey(t *testing.T) {
+	addr := &types.SCTPAddr{IP: net.ParseIP("192.168.1.5"), Port: 2905}
+
+	key := getKey(addr)
+
+	if expected := "192.168.1.5:2905/sctp"; key != expected {
+		t.Fatalf("expected key %s got %s", expected, key)
+	}
+
+	ip, port := getIPAndPort(addr)
+	if ip.String() != "192.168.1.5" || port != 2905 {
+		t.Fatalf("unexpected ip and port %s:%d", ip, port)
+	}
+}
+
 func TestGetUDPIPAndPort(t *testing.T) {
 	addr := &net.UDPAddr{IP: net.ParseIP("192.168.1.5"), Port: 53}
 
@@ -16,6 +16,7 @@ import (
 
 	"github.com/docker/docker/pkg/proxy"
 	"github.com/docker/docker/pkg/reexec"
+	"github.com/docker/libnetwork/types"
 )
 
 const userlandProxyCommandName = "docker-proxy"
@@ -177,6 +178,9 @@ func newDummyProxy(proto string, hostIP net.IP, hostPort int) userlandProxy {
 	case "udp":
 		addr := &net.UDPAddr{IP: hostIP, Port: hostPort}
 		return &dummyProxy{addr: addr}
+	case "sctp":
+		addr := &types.SCTPAddr{IP: hostIP, Port: hostPort}
+		return &dummyProxy{addr: addr}
 	}
 	return nil
 }
@@ -195,6 +199,9 @@ func (p *dummyProxy) Start() error {
 			return err
 		}
 		p.listener = l
+	case *types.SCTPAddr:
+		// SCTP sockets are not supported by the standard
+		// library, the port is only reserved in the allocator.
 	default:
 		return fmt.Errorf("Unknown addr type: %T", p.addr)
 	}
@@ -0,0 +1,133 @@
+package libnetwork
+
+import (
+	"net"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/dhcp"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+const (
+	// length of the subnets carved from the delegated prefix when none
+	// is configured
+	defaultDelegatedSubnetLen = 64
+	// interval of the attempts to obtain the delegation of a prefix
+	prefixRetryInterval = 30 * time.Second
+)
+
+// DelegatedPrefixEvent is sent to the pool event subscribers when the
+// upstream router delegates another IPv6 prefix to the node. The subnets
+// of the new networks are carved from the new prefix, the networks with a
+// subnet of the old one need to be recreated for their endpoints to be
+// renumbered.
+type DelegatedPrefixEvent struct {
+	Old *net.IPNet
+	New *net.IPNet
+	// Networks lists the ids of the networks with a subnet of the old
+	// prefix
+	Networks []string
+}
+
+// startPrefixDelegation obtains the delegation of an IPv6 prefix from the
+// upstream router reached through the interface and keeps it renewed,
+// until the stop channel is closed
+func (c *controller) startPrefixDelegation(ifName string, stopCh chan struct{}) {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		log.Errorf("Failed to find the prefix delegation interface %s: %v", ifName, err)
+		return
+	}
+	mac := iface.HardwareAddr
+
+	var l *dhcp.PrefixLease
+	for {
+		if l, err = acquirePrefix(ifName, mac); err == nil {
+			break
+		}
+		log.Warnf("Failed to obtain the delegation of an IPv6 prefix through %s: %v", ifName, err)
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(prefixRetryInterval):
+		}
+	}
+	if err := c.setDelegatedPrefix(l.Prefix); err != nil {
+		log.Errorf("Failed to carve the IPv6 pools from the delegated prefix %s: %v", l.Prefix, err)
+		return
+	}
+
+	renew := func(old *dhcp.PrefixLease) (*dhcp.PrefixLease, error) {
+		nl, err := renewPrefix(ifName, mac, old)
+		if err != nil && old.Expired(time.Now()) {
+			return acquirePrefix(ifName, mac)
+		}
+		return nl, err
+	}
+	k := dhcp.KeepPrefix(l, renew, c.delegatedPrefixChanged)
+	<-stopCh
+	k.Stop()
+}
+
+// setDelegatedPrefix sets the prefix the default ipam carves the IPv6
+// pools of the local networks from
+func (c *controller) setDelegatedPrefix(prefix *net.IPNet) error {
+	ipam, _, err := c.getIPAMDriver(ipamapi.DefaultIPAM)
+	if err != nil {
+		return err
+	}
+	pd, ok := ipam.(ipamapi.PrefixDelegate)
+	if !ok {
+		return types.NotImplementedErrorf("%s ipam driver does not support delegated prefixes", ipamapi.DefaultIPAM)
+	}
+	subnetLen := c.cfg.Daemon.PrefixDelegationLen
+	if subnetLen == 0 {
+		subnetLen = defaultDelegatedSubnetLen
+	}
+	if err := pd.SetDelegatedPrefix(prefix, subnetLen); err != nil {
+		return err
+	}
+
+	c.Lock()
+	c.delegatedPrefix = prefix
+	c.Unlock()
+	log.Infof("Carving the IPv6 subnets of the local networks from the delegated prefix %s", prefix)
+	return nil
+}
+
+func (c *controller) getDelegatedPrefix() *net.IPNet {
+	c.Lock()
+	defer c.Unlock()
+	return c.delegatedPrefix
+}
+
+// delegatedPrefixChanged carves the subnets of the new networks from the
+// new prefix and notifies the networks with a subnet of the old one
+func (c *controller) delegatedPrefixChanged(old, new *dhcp.PrefixLease) {
+	if err := c.setDelegatedPrefix(new.Prefix); err != nil {
+		log.Errorf("Failed to carve the IPv6 pools from the delegated prefix %s: %v", new.Prefix, err)
+	}
+
+	ev := DelegatedPrefixEvent{Old: old.Prefix, New: new.Prefix}
+	for _, nw := range c.Networks() {
+		n := nw.(*network)
+		for _, i := range n.getIPInfo(6) {
+			if old.Prefix.Contains(i.Pool.IP) {
+				log.Warnf("Network %s subnet %s is not part of the delegated prefix %s anymore", n.Name(), i.Pool, new.Prefix)
+				ev.Networks = append(ev.Networks, n.ID())
+				break
+			}
+		}
+	}
+	c.svcBroadcaster.Write(ev)
+}
+
+// carvesDelegatedPrefix returns whether the network gets an IPv6 subnet
+// of the delegated prefix when none is configured
+func (n *network) carvesDelegatedPrefix() bool {
+	return n.ipamType == ipamapi.DefaultIPAM && n.DataScope() != datastore.GlobalScope &&
+		n.getController().getDelegatedPrefix() != nil
+}
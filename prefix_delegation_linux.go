@@ -0,0 +1,15 @@
+package libnetwork
+
+import (
+	"net"
+
+	"github.com/docker/libnetwork/dhcp"
+)
+
+func acquirePrefix(ifName string, mac net.HardwareAddr) (*dhcp.PrefixLease, error) {
+	return dhcp.AcquirePrefix(ifName, mac, 0)
+}
+
+func renewPrefix(ifName string, mac net.HardwareAddr, l *dhcp.PrefixLease) (*dhcp.PrefixLease, error) {
+	return dhcp.RenewPrefix(ifName, mac, l)
+}
@@ -0,0 +1,18 @@
+// +build !linux
+
+package libnetwork
+
+import (
+	"net"
+
+	"github.com/docker/libnetwork/dhcp"
+	"github.com/docker/libnetwork/types"
+)
+
+func acquirePrefix(ifName string, mac net.HardwareAddr) (*dhcp.PrefixLease, error) {
+	return nil, types.NotImplementedErrorf("prefix delegation is not supported on this platform")
+}
+
+func renewPrefix(ifName string, mac net.HardwareAddr, l *dhcp.PrefixLease) (*dhcp.PrefixLease, error) {
+	return nil, types.NotImplementedErrorf("prefix delegation is not supported on this platform")
+}
@@ -0,0 +1,158 @@
+// Package proxyproto implements a TCP proxy which prepends a PROXY
+// protocol header to the forwarded connections so that the backends
+// can recover the address of the original client.
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Versions of the PROXY protocol header
+const (
+	// Version1 is the human readable header format
+	Version1 = 1
+	// Version2 is the binary header format
+	Version2 = 2
+)
+
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// Header returns the PROXY protocol header of the passed version for a
+// TCP connection from src to dst.
+func Header(version int, src, dst *net.TCPAddr) ([]byte, error) {
+	switch version {
+	case Version1:
+		return headerV1(src, dst), nil
+	case Version2:
+		return headerV2(src, dst), nil
+	}
+	return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+}
+
+func headerV1(src, dst *net.TCPAddr) []byte {
+	proto := "TCP4"
+	if src.IP.To4() == nil || dst.IP.To4() == nil {
+		proto = "TCP6"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, src.IP, dst.IP, src.Port, dst.Port))
+}
+
+func headerV2(src, dst *net.TCPAddr) []byte {
+	var (
+		fam            byte = 0x11 // TCP over IPv4
+		srcIP, dstIP        = src.IP.To4(), dst.IP.To4()
+		buf                 bytes.Buffer
+	)
+	if srcIP == nil || dstIP == nil {
+		fam = 0x21 // TCP over IPv6
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+
+	buf.Write(v2Signature)
+	// Version 2, PROXY command
+	buf.WriteByte(0x21)
+	buf.WriteByte(fam)
+	binary.Write(&buf, binary.BigEndian, uint16(2*len(srcIP)+4))
+	buf.Write(srcIP)
+	buf.Write(dstIP)
+	binary.Write(&buf, binary.BigEndian, uint16(src.Port))
+	binary.Write(&buf, binary.BigEndian, uint16(dst.Port))
+	return buf.Bytes()
+}
+
+// TCPProxy forwards the connections accepted on a listener to the
+// backend returned by the dial function, prepending a PROXY protocol
+// header which carries the client and the listener addresses.
+type TCPProxy struct {
+	listener net.Listener
+	dial     func() (net.Conn, error)
+	version  int
+}
+
+// NewTCPProxy creates a new TCPProxy.
+func NewTCPProxy(listener net.Listener, dial func() (net.Conn, error), version int) (*TCPProxy, error) {
+	if version != Version1 && version != Version2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+
+	return &TCPProxy{
+		listener: listener,
+		dial:     dial,
+		version:  version,
+	}, nil
+}
+
+func (proxy *TCPProxy) clientLoop(client *net.TCPConn, quit chan bool) {
+	conn, err := proxy.dial()
+	if err != nil {
+		logrus.Errorf("Can't forward traffic from %v: %v", client.RemoteAddr(), err)
+		client.Close()
+		return
+	}
+	backend := conn.(*net.TCPConn)
+
+	hdr, _ := Header(proxy.version, client.RemoteAddr().(*net.TCPAddr), client.LocalAddr().(*net.TCPAddr))
+	if _, err := backend.Write(hdr); err != nil {
+		logrus.Errorf("Failed to send PROXY header to %v: %v", backend.RemoteAddr(), err)
+		client.Close()
+		backend.Close()
+		return
+	}
+
+	event := make(chan int64)
+	var broker = func(to, from *net.TCPConn) {
+		written, err := io.Copy(to, from)
+		if err != nil {
+			// If the socket we are writing to is shutdown with
+			// SHUT_WR, forward it to the other end of the pipe:
+			if err, ok := err.(*net.OpError); ok && err.Err == syscall.EPIPE {
+				from.CloseWrite()
+			}
+		}
+		to.CloseRead()
+		event <- written
+	}
+
+	go broker(client, backend)
+	go broker(backend, client)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-event:
+		case <-quit:
+			// Interrupt the two brokers and "join" them.
+			client.Close()
+			backend.Close()
+			for ; i < 2; i++ {
+				<-event
+			}
+			return
+		}
+	}
+	client.Close()
+	backend.Close()
+}
+
+// Run starts forwarding the traffic.
+func (proxy *TCPProxy) Run() {
+	quit := make(chan bool)
+	defer close(quit)
+	for {
+		client, err := proxy.listener.Accept()
+		if err != nil {
+			logrus.Debugf("Stopping PROXY protocol proxy on %v: %v", proxy.listener.Addr(), err)
+			return
+		}
+		go proxy.clientLoop(client.(*net.TCPConn), quit)
+	}
+}
+
+// Close stops forwarding the traffic.
+func (proxy *TCPProxy) Close() error { return proxy.listener.Close() }
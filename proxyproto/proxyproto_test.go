@@ -0,0 +1,116 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestHeaderV1(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.255.0.2"), Port: 8080}
+
+	hdr, err := Header(Version1, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "PROXY TCP4 192.168.1.10 10.255.0.2 56324 8080\r\n"; string(hdr) != expected {
+		t.Fatalf("Expected %q, got %q", expected, hdr)
+	}
+
+	src.IP = net.ParseIP("2001:db8::1")
+	dst.IP = net.ParseIP("2001:db8::2")
+	hdr, err = Header(Version1, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "PROXY TCP6 2001:db8::1 2001:db8::2 56324 8080\r\n"; string(hdr) != expected {
+		t.Fatalf("Expected %q, got %q", expected, hdr)
+	}
+}
+
+func TestHeaderV2(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.255.0.2"), Port: 8080}
+
+	hdr, err := Header(Version2, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := append([]byte{}, v2Signature...)
+	expected = append(expected, 0x21, 0x11, 0x00, 0x0C,
+		192, 168, 1, 10, 10, 255, 0, 2, 0xDC, 0x04, 0x1F, 0x90)
+	if !bytes.Equal(hdr, expected) {
+		t.Fatalf("Expected %x, got %x", expected, hdr)
+	}
+
+	src.IP = net.ParseIP("2001:db8::1")
+	hdr, err = Header(Version2, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hdr) != 16+36 || hdr[13] != 0x21 {
+		t.Fatalf("Unexpected IPv6 header %x", hdr)
+	}
+
+	if _, err := Header(3, src, dst); err == nil {
+		t.Fatal("Expected an error for an unsupported version")
+	}
+}
+
+func TestTCPProxy(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	frontend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := NewTCPProxy(frontend, func() (net.Conn, error) {
+		return net.Dial("tcp", backend.Addr().String())
+	}, Version1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go proxy.Run()
+	defer proxy.Close()
+
+	client, err := net.Dial("tcp", frontend.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Write([]byte("hello"))
+	client.(*net.TCPConn).CloseWrite()
+
+	conn, err := backend.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := Header(Version1, client.LocalAddr().(*net.TCPAddr), frontend.Addr().(*net.TCPAddr))
+	if line != string(expected) {
+		t.Fatalf("Expected header %q, got %q", expected, line)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("Expected the client data to be forwarded, got %q", data)
+	}
+	client.Close()
+}
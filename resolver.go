@@ -35,6 +35,31 @@ type Resolver interface {
 	FlushExtServers()
 	// ResolverOptions returns resolv.conf options that should be set
 	ResolverOptions() []string
+	// FlushCache drops the cached answers of the external nameservers
+	FlushCache()
+}
+
+// DNSAction is the verdict a DNSPolicy returns for a query
+type DNSAction int
+
+const (
+	// DNSActionPass lets the query be resolved as usual
+	DNSActionPass DNSAction = iota
+	// DNSActionBlock answers the query with NXDOMAIN
+	DNSActionBlock
+	// DNSActionRewrite resolves the name returned by the policy in place
+	// of the queried one
+	DNSActionRewrite
+)
+
+// DNSPolicy is implemented by integrators which want to block or rewrite
+// the queries served by the embedded DNS server, e.g. for egress filtering
+// or sinkholing.
+type DNSPolicy interface {
+	// Evaluate is invoked for every query issued by a container connected
+	// to the network with the passed id. For DNSActionRewrite the returned
+	// name is resolved instead of the queried one.
+	Evaluate(nid string, name string, qtype uint16) (DNSAction, string)
 }
 
 const (
@@ -75,6 +100,7 @@ type resolver struct {
 	tStamp     time.Time
 	queryLock  sync.Mutex
 	client     map[uint16]clientConn
+	cache      *dnsCache
 }
 
 func init() {
@@ -83,11 +109,16 @@ func init() {
 
 // NewResolver creates a new instance of the Resolver
 func NewResolver(sb *sandbox) Resolver {
-	return &resolver{
+	r := &resolver{
 		sb:     sb,
 		err:    fmt.Errorf("setup not done yet"),
 		client: make(map[uint16]clientConn),
 	}
+	if sb.controller != nil && sb.controller.cfg != nil && sb.controller.cfg.Daemon.DNSCacheSize > 0 {
+		cfg := sb.controller.cfg.Daemon
+		r.cache = newDNSCache(cfg.DNSCacheSize, cfg.DNSCacheMaxTTL)
+	}
+	return r
 }
 
 func (r *resolver) SetupFunc() func() {
@@ -154,6 +185,10 @@ func (r *resolver) FlushExtServers() {
 	}
 }
 
+func (r *resolver) FlushCache() {
+	r.cache.flush()
+}
+
 func (r *resolver) Stop() {
 	r.FlushExtServers()
 
@@ -303,6 +338,24 @@ func (r *resolver) handleSRVQuery(svc string, query *dns.Msg) (*dns.Msg, error)
 
 }
 
+func (r *resolver) handleTXTQuery(name string, query *dns.Msg) (*dns.Msg, error) {
+	txt := r.sb.ResolveTXT(name)
+	if len(txt) == 0 {
+		return nil, nil
+	}
+
+	log.Debugf("Lookup for TXT %s: %v", name, txt)
+
+	resp := createRespMsg(query)
+	for _, t := range txt {
+		rr := new(dns.TXT)
+		rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: respTTL}
+		rr.Txt = []string{t}
+		resp.Answer = append(resp.Answer, rr)
+	}
+	return resp, nil
+}
+
 func truncateResp(resp *dns.Msg, maxSize int, isTCP bool) {
 	if !isTCP {
 		resp.Truncated = true
@@ -332,6 +385,23 @@ func (r *resolver) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
 		return
 	}
 	name := query.Question[0].Name
+	origName := name
+
+	action, rwName := r.sb.evalDNSPolicy(name, query.Question[0].Qtype)
+	switch action {
+	case DNSActionBlock:
+		log.Debugf("Query %s[%d] blocked by DNS policy", name, query.Question[0].Qtype)
+		resp = createRespMsg(query)
+		resp.Rcode = dns.RcodeNameError
+		if err = w.WriteMsg(resp); err != nil {
+			log.Errorf("error writing resolver resp, %s", err)
+		}
+		return
+	case DNSActionRewrite:
+		log.Debugf("Query %s[%d] rewritten to %s by DNS policy", name, query.Question[0].Qtype, rwName)
+		name = dns.Fqdn(rwName)
+		query.Question[0].Name = name
+	}
 
 	switch query.Question[0].Qtype {
 	case dns.TypeA:
@@ -342,6 +412,8 @@ func (r *resolver) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
 		resp, err = r.handlePTRQuery(name, query)
 	case dns.TypeSRV:
 		resp, err = r.handleSRVQuery(name, query)
+	case dns.TypeTXT:
+		resp, err = r.handleTXTQuery(name, query)
 	}
 
 	if err != nil {
@@ -363,6 +435,12 @@ func (r *resolver) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
 		}
 	}
 
+	if resp == nil {
+		if resp = r.cache.get(query); resp != nil {
+			log.Debugf("Query %s[%d] answered from cache", name, query.Question[0].Qtype)
+		}
+	}
+
 	if resp != nil {
 		if resp.Len() > maxSize {
 			truncateResp(resp, maxSize, proto == "tcp")
@@ -450,6 +528,7 @@ func (r *resolver) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
 				continue
 			}
 
+			r.cache.put(resp)
 			resp.Compress = true
 			break
 		}
@@ -461,11 +540,29 @@ func (r *resolver) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
 	if writer == nil {
 		return
 	}
+	if name != origName {
+		restoreQueryName(resp, name, origName)
+	}
 	if err = writer.WriteMsg(resp); err != nil {
 		log.Errorf("error writing resolver resp, %s", err)
 	}
 }
 
+// restoreQueryName reverts a policy rewrite of the query name in the
+// response so that the client sees the name it asked for.
+func restoreQueryName(resp *dns.Msg, name, origName string) {
+	for i := range resp.Question {
+		if resp.Question[i].Name == name {
+			resp.Question[i].Name = origName
+		}
+	}
+	for _, rr := range resp.Answer {
+		if rr.Header().Name == name {
+			rr.Header().Name = origName
+		}
+	}
+}
+
 func (r *resolver) forwardQueryStart(w dns.ResponseWriter, msg *dns.Msg, queryID uint16) bool {
 	proto := w.LocalAddr().Network()
 	dnsID := uint16(rand.Intn(maxDNSID))
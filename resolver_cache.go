@@ -0,0 +1,158 @@
+package libnetwork
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type dnsCacheKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+type dnsCacheEntry struct {
+	msg    *dns.Msg
+	stored time.Time
+	expiry time.Time
+}
+
+// dnsCache holds the positive answers received from the external
+// nameservers so that repeated queries are not forwarded again
+// until their TTL expires.
+type dnsCache struct {
+	size    int
+	maxTTL  uint32
+	entries map[dnsCacheKey]*dnsCacheEntry
+	sync.Mutex
+}
+
+func newDNSCache(size int, maxTTL uint32) *dnsCache {
+	return &dnsCache{
+		size:    size,
+		maxTTL:  maxTTL,
+		entries: make(map[dnsCacheKey]*dnsCacheEntry),
+	}
+}
+
+func cacheKey(q dns.Question) dnsCacheKey {
+	return dnsCacheKey{name: dns.Fqdn(q.Name), qtype: q.Qtype, qclass: q.Qclass}
+}
+
+// get returns a copy of the cached answer for the query with the TTLs
+// adjusted to the remaining lifetime, or nil on a miss.
+func (c *dnsCache) get(query *dns.Msg) *dns.Msg {
+	if c == nil || len(query.Question) == 0 {
+		return nil
+	}
+
+	key := cacheKey(query.Question[0])
+	now := time.Now()
+
+	c.Lock()
+	e, ok := c.entries[key]
+	if ok && !now.Before(e.expiry) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.Unlock()
+	if !ok {
+		return nil
+	}
+
+	resp := e.msg.Copy()
+	resp.Id = query.Id
+	elapsed := uint32(now.Sub(e.stored) / time.Second)
+	for _, rrs := range [][]dns.RR{resp.Answer, resp.Ns, resp.Extra} {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			if rr.Header().Ttl > elapsed {
+				rr.Header().Ttl -= elapsed
+			} else {
+				rr.Header().Ttl = 0
+			}
+		}
+	}
+	return resp
+}
+
+// put stores a successful, non truncated answer for the lowest TTL among
+// its records, capped to the configured maximum.
+func (c *dnsCache) put(resp *dns.Msg) {
+	if c == nil || resp.Rcode != dns.RcodeSuccess || resp.Truncated ||
+		len(resp.Question) == 0 || len(resp.Answer) == 0 {
+		return
+	}
+
+	ttl := resp.Answer[0].Header().Ttl
+	for _, rr := range resp.Answer[1:] {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	if c.maxTTL > 0 && ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	if ttl == 0 {
+		return
+	}
+
+	msg := resp.Copy()
+	if c.maxTTL > 0 {
+		for _, rr := range msg.Answer {
+			if rr.Header().Ttl > c.maxTTL {
+				rr.Header().Ttl = c.maxTTL
+			}
+		}
+	}
+
+	now := time.Now()
+	key := cacheKey(resp.Question[0])
+
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		c.evict(now)
+	}
+	c.entries[key] = &dnsCacheEntry{
+		msg:    msg,
+		stored: now,
+		expiry: now.Add(time.Duration(ttl) * time.Second),
+	}
+}
+
+// evict drops the expired entries, or the one closest to expiry if
+// none has expired yet. Must be called with the lock held.
+func (c *dnsCache) evict(now time.Time) {
+	var (
+		oldest    dnsCacheKey
+		oldestExp time.Time
+	)
+	for k, e := range c.entries {
+		if !now.Before(e.expiry) {
+			delete(c.entries, k)
+			continue
+		}
+		if oldestExp.IsZero() || e.expiry.Before(oldestExp) {
+			oldest, oldestExp = k, e.expiry
+		}
+	}
+	if len(c.entries) >= c.size && !oldestExp.IsZero() {
+		delete(c.entries, oldest)
+	}
+}
+
+func (c *dnsCache) flush() {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	c.entries = make(map[dnsCacheKey]*dnsCacheEntry)
+	c.Unlock()
+}
@@ -0,0 +1,67 @@
+package libnetwork
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func newCacheTestResp(name string, ttl uint32) *dns.Msg {
+	query := new(dns.Msg)
+	query.SetQuestion(name, dns.TypeA)
+
+	resp := new(dns.Msg)
+	resp.SetReply(query)
+	rr := new(dns.A)
+	rr.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}
+	rr.A = net.ParseIP("192.168.1.1")
+	resp.Answer = append(resp.Answer, rr)
+	return resp
+}
+
+func TestDNSCache(t *testing.T) {
+	c := newDNSCache(2, 60)
+
+	query := new(dns.Msg)
+	query.SetQuestion("docker.com.", dns.TypeA)
+	if resp := c.get(query); resp != nil {
+		t.Fatalf("Expected a miss on empty cache, got %v", resp)
+	}
+
+	c.put(newCacheTestResp("docker.com.", 600))
+	resp := c.get(query)
+	if resp == nil {
+		t.Fatal("Expected a hit after put")
+	}
+	if resp.Id != query.Id {
+		t.Fatalf("Expected the cached answer to carry the query id %d, got %d", query.Id, resp.Id)
+	}
+	if ttl := resp.Answer[0].Header().Ttl; ttl > 60 {
+		t.Fatalf("Expected the TTL to be capped to 60, got %d", ttl)
+	}
+
+	c.put(newCacheTestResp("golang.org.", 30))
+	c.put(newCacheTestResp("example.com.", 300))
+	if len(c.entries) != 2 {
+		t.Fatalf("Expected the cache to be bound to 2 entries, got %d", len(c.entries))
+	}
+	query.SetQuestion("golang.org.", dns.TypeA)
+	if c.get(query) != nil {
+		t.Fatal("Expected the entry closest to expiry to be evicted")
+	}
+
+	nx := newCacheTestResp("nx.example.com.", 300)
+	nx.Rcode = dns.RcodeNameError
+	c.put(nx)
+	query.SetQuestion("nx.example.com.", dns.TypeA)
+	if c.get(query) != nil {
+		t.Fatal("Expected negative answers not to be cached")
+	}
+
+	c.flush()
+	query.SetQuestion("example.com.", dns.TypeA)
+	if c.get(query) != nil {
+		t.Fatal("Expected a miss after flush")
+	}
+}
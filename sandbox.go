@@ -12,7 +12,9 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/libnetwork/etchosts"
 	"github.com/docker/libnetwork/netlabel"
+	"github.com/docker/libnetwork/netutils"
 	"github.com/docker/libnetwork/osl"
+	"github.com/docker/libnetwork/proxyproto"
 	"github.com/docker/libnetwork/types"
 )
 
@@ -48,6 +50,9 @@ type Sandbox interface {
 	// ResolveService returns all the backend details about the containers or hosts
 	// backing a service. Its purpose is to satisfy an SRV query
 	ResolveService(name string) ([]*net.SRV, []net.IP, error)
+	// ResolveTXT returns the metadata published by a service in the
+	// key=value form. Its purpose is to satisfy a TXT query
+	ResolveTXT(name string) []string
 	// Endpoints returns all the endpoints connected to the sandbox
 	Endpoints() []Endpoint
 }
@@ -85,6 +90,13 @@ type sandbox struct {
 	isStub        bool
 	inDelete      bool
 	ingress       bool
+	lbDrains      map[string]*time.Timer
+	// PROXY protocol proxies of the ingress sandbox, keyed by
+	// node port.
+	ingressProxies map[uint32]*proxyproto.TCPProxy
+	// Timeout of the idle UDP flows programmed by the load
+	// balancers, in seconds.
+	lbUDPTimeout uint32
 	sync.Mutex
 }
 
@@ -484,6 +496,33 @@ func (sb *sandbox) ResolveService(name string) ([]*net.SRV, []net.IP, error) {
 	return srv, ip, nil
 }
 
+func (sb *sandbox) ResolveTXT(name string) []string {
+	log.Debugf("TXT name To resolve: %v", name)
+	name = netutils.NormalizeDNSName(strings.TrimSuffix(name, "."))
+
+	for _, ep := range sb.getConnectedEndpoints() {
+		n := ep.getNetwork()
+
+		sr, ok := n.getController().svcRecords[n.ID()]
+		if !ok {
+			continue
+		}
+
+		// Resolution works for both service_name and
+		// service_name.network_name
+		n.Lock()
+		txt, ok := sr.txtMap[name]
+		if suffix := "." + netutils.NormalizeDNSName(n.name); !ok && strings.HasSuffix(name, suffix) {
+			txt, ok = sr.txtMap[strings.TrimSuffix(name, suffix)]
+		}
+		n.Unlock()
+		if ok {
+			return txt
+		}
+	}
+	return nil
+}
+
 func (sb *sandbox) ResolveName(name string, ipType int) ([]net.IP, bool) {
 	// Embedded server owns the docker network domain. Resolution should work
 	// for both container_name and container_name.network_name
@@ -514,6 +553,21 @@ func (sb *sandbox) ResolveName(name string, ipType int) ([]net.IP, bool) {
 	}
 
 	epList := sb.getConnectedEndpoints()
+
+	// A name qualified with one of the search domains of a connected
+	// network is looked up in that network only.
+	normName := netutils.NormalizeDNSName(name)
+	for _, ep := range epList {
+		n := ep.getNetwork()
+		for _, d := range n.DNSSearch() {
+			suffix := "." + netutils.NormalizeDNSName(d)
+			if strings.HasSuffix(normName, suffix) {
+				reqName = append(reqName, strings.TrimSuffix(normName, suffix))
+				networkName = append(networkName, n.Name())
+			}
+		}
+	}
+
 	for i := 0; i < len(reqName); i++ {
 
 		// First check for local container alias
@@ -544,7 +598,7 @@ func (sb *sandbox) resolveName(req string, networkName string, epList []*endpoin
 		name := req
 		n := ep.getNetwork()
 
-		if networkName != "" && networkName != n.Name() {
+		if networkName != "" && netutils.NormalizeDNSName(networkName) != netutils.NormalizeDNSName(n.Name()) {
 			continue
 		}
 
@@ -555,7 +609,7 @@ func (sb *sandbox) resolveName(req string, networkName string, epList []*endpoin
 
 			var ok bool
 			ep.Lock()
-			name, ok = ep.aliases[req]
+			name, ok = lookupAlias(ep.aliases, req)
 			ep.Unlock()
 			if !ok {
 				continue
@@ -564,12 +618,13 @@ func (sb *sandbox) resolveName(req string, networkName string, epList []*endpoin
 			// If it is a regular lookup and if the requested name is an alias
 			// don't perform a svc lookup for this endpoint.
 			ep.Lock()
-			if _, ok := ep.aliases[req]; ok {
+			if _, ok := lookupAlias(ep.aliases, req); ok {
 				ep.Unlock()
 				continue
 			}
 			ep.Unlock()
 		}
+		name = netutils.NormalizeDNSName(name)
 
 		sr, ok := n.getController().svcRecords[n.ID()]
 		if !ok {
@@ -598,6 +653,42 @@ func (sb *sandbox) resolveName(req string, networkName string, epList []*endpoin
 	return nil, ipv6Miss
 }
 
+// evalDNSPolicy consults the controller DNS policy for each of the networks
+// the sandbox is connected to. The first verdict other than pass is returned.
+func (sb *sandbox) evalDNSPolicy(name string, qtype uint16) (DNSAction, string) {
+	policy := sb.controller.getDNSPolicy()
+	if policy == nil {
+		return DNSActionPass, ""
+	}
+
+	for _, ep := range sb.getConnectedEndpoints() {
+		action, rwName := policy.Evaluate(ep.getNetwork().ID(), name, qtype)
+		if action == DNSActionRewrite && rwName == "" {
+			continue
+		}
+		if action != DNSActionPass {
+			return action, rwName
+		}
+	}
+	return DNSActionPass, ""
+}
+
+// lookupAlias returns the name the passed alias maps to. Aliases are
+// matched regardless of case and IDNA encoding.
+func lookupAlias(aliases map[string]string, req string) (string, bool) {
+	if name, ok := aliases[req]; ok {
+		return name, true
+	}
+
+	req = netutils.NormalizeDNSName(req)
+	for a, name := range aliases {
+		if netutils.NormalizeDNSName(a) == req {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 func (sb *sandbox) SetKey(basePath string) error {
 	start := time.Now()
 	defer func() {
@@ -722,6 +813,9 @@ func (sb *sandbox) populateNetworkResources(ep *endpoint) error {
 		if i.addrv6 != nil && i.addrv6.IP.To16() != nil {
 			ifaceOptions = append(ifaceOptions, sb.osSbox.InterfaceOptions().AddressIPv6(i.addrv6))
 		}
+		if secondary := append(i.SecondaryAddresses(), i.SecondaryAddressesIPv6()...); len(secondary) > 0 {
+			ifaceOptions = append(ifaceOptions, sb.osSbox.InterfaceOptions().SecondaryAddresses(secondary))
+		}
 		if i.mac != nil {
 			ifaceOptions = append(ifaceOptions, sb.osSbox.InterfaceOptions().MacAddress(i.mac))
 		}
@@ -775,6 +869,7 @@ func (sb *sandbox) clearNetworkResources(origEp *endpoint) error {
 	inDelete := sb.inDelete
 	sb.Unlock()
 	if osSbox != nil {
+		sb.releaseLoadbalancers(ep)
 		releaseOSSboxResources(osSbox, ep)
 	}
 
@@ -265,6 +265,63 @@ func (sb *sandbox) updateDNS(ipv6Enabled bool) error {
 	return os.Rename(tmpHashFile.Name(), hashFile)
 }
 
+// updateDNSSearch appends the passed network search domains to the
+// container's resolv.conf, unless the user has modified the file.
+func (sb *sandbox) updateDNSSearch(search []string) error {
+	var currHash string
+
+	// This is for the host mode networking
+	if len(search) == 0 || sb.config.originResolvConfPath != "" {
+		return nil
+	}
+
+	currRC, err := resolvconf.GetSpecific(sb.config.resolvConfPath)
+	if err != nil {
+		return err
+	}
+
+	h, err := ioutil.ReadFile(sb.config.resolvConfHashFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		currHash = string(h)
+	}
+
+	if currHash != "" && currHash != currRC.Hash {
+		log.Infof("Skipping update of resolv.conf search domains because file was touched by user")
+		return nil
+	}
+
+	dnsSearchList := resolvconf.GetSearchDomains(currRC.Content)
+	added := false
+	for _, d := range search {
+		found := false
+		for _, s := range dnsSearchList {
+			if s == d {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dnsSearchList = append(dnsSearchList, d)
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+
+	newRC, err := resolvconf.Build(sb.config.resolvConfPath,
+		resolvconf.GetNameservers(currRC.Content, types.IP), dnsSearchList, resolvconf.GetOptions(currRC.Content))
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(sb.config.resolvConfHashFile, []byte(newRC.Hash), filePerm)
+}
+
 // Embedded DNS server has to be enabled for this sandbox. Rebuild the container's
 // resolv.conf by doing the follwing
 // - Save the external name servers in resolv.conf in the sandbox
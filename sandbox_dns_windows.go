@@ -30,3 +30,7 @@ func (sb *sandbox) deleteHostsEntries(recs []etchosts.Record) {
 func (sb *sandbox) updateDNS(ipv6Enabled bool) error {
 	return nil
 }
+
+func (sb *sandbox) updateDNSSearch(search []string) error {
+	return nil
+}
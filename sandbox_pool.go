@@ -0,0 +1,121 @@
+package libnetwork
+
+import (
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/libnetwork/osl"
+)
+
+// pooledSandbox is an OS sandbox created ahead of time along with the
+// sandbox id its key is derived from.
+type pooledSandbox struct {
+	id     string
+	osSbox osl.Sandbox
+}
+
+// sandboxPool keeps a number of OS sandboxes, with their load balancer
+// initialized, ready to be taken over by the new sandboxes. It is
+// refilled in the background.
+type sandboxPool struct {
+	size    int
+	entries []pooledSandbox
+	filling bool
+	closed  bool
+	sync.Mutex
+}
+
+func newSandboxPool(size int) *sandboxPool {
+	p := &sandboxPool{size: size}
+	p.refill()
+	return p
+}
+
+// get takes a sandbox out of the pool. It returns false if the pool is
+// disabled or empty.
+func (p *sandboxPool) get() (pooledSandbox, bool) {
+	if p == nil {
+		return pooledSandbox{}, false
+	}
+
+	p.Lock()
+	if len(p.entries) == 0 {
+		p.Unlock()
+		p.refill()
+		return pooledSandbox{}, false
+	}
+	ps := p.entries[len(p.entries)-1]
+	p.entries = p.entries[:len(p.entries)-1]
+	p.Unlock()
+
+	p.refill()
+	return ps, true
+}
+
+// refill starts filling the pool up to its size, unless it is already
+// being filled.
+func (p *sandboxPool) refill() {
+	p.Lock()
+	if p.filling || p.closed {
+		p.Unlock()
+		return
+	}
+	p.filling = true
+	p.Unlock()
+
+	go p.fill()
+}
+
+func (p *sandboxPool) fill() {
+	for {
+		p.Lock()
+		if p.closed || len(p.entries) >= p.size {
+			p.filling = false
+			p.Unlock()
+			return
+		}
+		p.Unlock()
+
+		id := stringid.GenerateRandomID()
+		osSbox, err := osl.NewSandbox(osl.GenerateKey(id), true)
+		if err != nil || osSbox == nil {
+			if err != nil {
+				logrus.Warnf("Failed to create a pooled sandbox: %v", err)
+			}
+			p.Lock()
+			p.filling = false
+			p.Unlock()
+			return
+		}
+		warmLBSandbox(osSbox.Key())
+
+		p.Lock()
+		if p.closed {
+			p.Unlock()
+			osSbox.Destroy()
+			continue
+		}
+		p.entries = append(p.entries, pooledSandbox{id: id, osSbox: osSbox})
+		p.Unlock()
+	}
+}
+
+// close destroys the sandboxes left in the pool.
+func (p *sandboxPool) close() {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	p.closed = true
+	entries := p.entries
+	p.entries = nil
+	p.Unlock()
+
+	for _, ps := range entries {
+		if err := ps.osSbox.Destroy(); err != nil {
+			logrus.Warnf("Failed to destroy pooled sandbox %s: %v", ps.osSbox.Key(), err)
+		}
+	}
+}
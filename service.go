@@ -12,6 +12,41 @@ var (
 	fwMarkCtrMu sync.Mutex
 )
 
+// Scheduling algorithms a service can request to balance the load
+// across its backends.
+const (
+	// SchedRoundRobin distributes the connections equally across
+	// the backends. This is the default.
+	SchedRoundRobin = "rr"
+	// SchedLeastConnection assigns the connections to the backend
+	// with the least number of active connections.
+	SchedLeastConnection = "lc"
+	// SchedSourceHashing assigns the connections to a backend based
+	// on the hash of the source address.
+	SchedSourceHashing = "sh"
+)
+
+// validSchedName returns the scheduler to use for the passed name,
+// falling back to round robin for an empty or unsupported one.
+func validSchedName(schedName string) (string, bool) {
+	switch schedName {
+	case SchedRoundRobin, SchedLeastConnection, SchedSourceHashing:
+		return schedName, true
+	case "":
+		return SchedRoundRobin, true
+	}
+	return SchedRoundRobin, false
+}
+
+// Locality of the backends a service load balancer can prefer.
+const (
+	// LBPreferNode prefers the backends running on the local node.
+	LBPreferNode = "node"
+	// LBPreferSubnet prefers the backends running on nodes in the
+	// same subnet as the local node.
+	LBPreferSubnet = "subnet"
+)
+
 type service struct {
 	name string // Service Name
 	id   string // Service ID
@@ -23,17 +58,204 @@ type service struct {
 	// List of ingress ports exposed by the service
 	ingressPorts []*PortConfig
 
+	// Scheduling algorithm used by the service load balancers
+	schedName string
+
+	// Session affinity of the service load balancers. Persistence
+	// is disabled if the timeout is zero.
+	persistTimeout uint32
+	persistMaskLen uint32
+
+	// Forward the ingress traffic with direct routing
+	dsr bool
+
+	// Locality of the backends preferred by the load balancers
+	lbPreference string
+
+	// Timeout in seconds of the idle UDP flows, zero keeps the
+	// default
+	udpTimeout uint32
+
+	// Maximum number of concurrent connections of each backend and
+	// maximum rate of new ingress connections, zero disables them
+	maxConns uint32
+	connRate uint32
+
+	// Share of the traffic sent to each deployment group, nil sends
+	// the traffic to all the backends
+	traffic *trafficSplit
+
 	sync.Mutex
 }
 
 type loadBalancer struct {
 	vip    net.IP
+	vip6   net.IP
 	fwMark uint32
 
-	// Map of backend IPs backing this loadbalancer on this
+	// Map of backends backing this loadbalancer on this
 	// network. It is keyed with endpoint ID.
-	backEnds map[string]net.IP
+	backEnds map[string]lbBackend
+
+	// Number of connections the nodes have active to each backend
+	// as gossiped by them. It is keyed with endpoint ID then with
+	// node address.
+	loads map[string]map[string]uint32
 
 	// Back pointer to service to which the loadbalancer belongs.
 	service *service
 }
+
+type lbBackend struct {
+	ip     net.IP
+	ip6    net.IP
+	weight int
+
+	// Whether the backend matches the service locality preference
+	preferred bool
+
+	// Deployment group of the backend
+	group string
+}
+
+// trafficSplit is the share of the traffic of a service sent to each of
+// its two deployment groups.
+type trafficSplit struct {
+	active         string
+	standby        string
+	standbyPercent uint32
+}
+
+// percent returns the percentage of the traffic sent to the group.
+func (t *trafficSplit) percent(group string) int {
+	switch group {
+	case t.active:
+		return 100 - int(t.standbyPercent)
+	case t.standby:
+		return int(t.standbyPercent)
+	}
+	return 0
+}
+
+// hasPreferred returns whether any backend of the load balancer matches
+// the service locality preference.
+func (lb *loadBalancer) hasPreferred() bool {
+	for _, be := range lb.backEnds {
+		if be.preferred {
+			return true
+		}
+	}
+	return false
+}
+
+// load returns the number of connections active to the backend across
+// the nodes.
+func (lb *loadBalancer) load(eid string) uint32 {
+	var load uint32
+	for _, conns := range lb.loads[eid] {
+		load += conns
+	}
+	return load
+}
+
+// effectiveBackend returns the backend as programmed in IPVS: the backends
+// not matching the locality preference get no new connections as long as
+// a preferred backend exists, the others are weighted inversely to their
+// load relative to the most loaded backend.
+func (lb *loadBalancer) effectiveBackend(eid string, be lbBackend, hasPreferred bool) lbBackend {
+	if hasPreferred && !be.preferred {
+		be.weight = 0
+		return be
+	}
+
+	var maxLoad uint32
+	for id := range lb.backEnds {
+		if load := lb.load(id); load > maxLoad {
+			maxLoad = load
+		}
+	}
+	if maxLoad > 0 {
+		be.weight = be.weight * int(maxLoad+1) / int(lb.load(eid)+1)
+	}
+	if s := lb.service; s != nil && s.traffic != nil {
+		be.weight = lb.splitWeight(be, s.traffic)
+	}
+	return be
+}
+
+// splitWeight scales the weight of the backend so that its deployment
+// group receives its share of the traffic, whatever the number of
+// backends in each group. Backends outside of the split groups get no
+// new connections.
+func (lb *loadBalancer) splitWeight(be lbBackend, t *trafficSplit) int {
+	pct := t.percent(be.group)
+	if pct == 0 || be.weight == 0 {
+		return 0
+	}
+
+	var total int
+	for _, b := range lb.backEnds {
+		if b.group == be.group {
+			total += b.weight
+		}
+	}
+	if total == 0 {
+		total = be.weight
+	}
+
+	if w := be.weight * pct * 100 / total; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// otherBackends returns all the backends but the one identified by eid,
+// as programmed in IPVS. Must be called with the service lock held.
+func (lb *loadBalancer) otherBackends(eid string) []lbBackend {
+	hasPreferred := lb.hasPreferred()
+
+	var backEnds []lbBackend
+	for id, be := range lb.backEnds {
+		if id != eid {
+			backEnds = append(backEnds, lb.effectiveBackend(id, be, hasPreferred))
+		}
+	}
+	return backEnds
+}
+
+// ServiceStats carries the load balancing statistics of a service on
+// this node, one entry per network the service is attached to.
+type ServiceStats struct {
+	ServiceID     string
+	Name          string
+	LoadBalancers []*LBStats
+}
+
+// LBStats carries the statistics of the load balancer of a service on
+// a network. The counters are summed over the sandboxes balancing the
+// traffic to the VIP.
+type LBStats struct {
+	NetworkID string
+	VIP       net.IP
+	VIPv6     net.IP
+	// Connections is the number of connections to the VIP.
+	Connections uint64
+	PacketsIn   uint64
+	PacketsOut  uint64
+	BytesIn     uint64
+	BytesOut    uint64
+	Backends    []*BackendStats
+}
+
+// BackendStats carries the statistics of a backend of a service load
+// balancer.
+type BackendStats struct {
+	IP                  net.IP
+	ActiveConnections   uint64
+	InactiveConnections uint64
+	Connections         uint64
+	PacketsIn           uint64
+	PacketsOut          uint64
+	BytesIn             uint64
+	BytesOut            uint64
+}
@@ -11,11 +11,14 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/pkg/reexec"
 	"github.com/docker/libnetwork/iptables"
 	"github.com/docker/libnetwork/ipvs"
+	"github.com/docker/libnetwork/proxyproto"
+	"github.com/docker/libnetwork/types"
 	"github.com/gogo/protobuf/proto"
 	"github.com/vishvananda/netlink/nl"
 	"github.com/vishvananda/netns"
@@ -25,16 +28,60 @@ func init() {
 	reexec.Register("fwmarker", fwMarker)
 }
 
-func newService(name string, id string, ingressPorts []*PortConfig) *service {
+func newService(name string, id string, ingressPorts []*PortConfig, schedName string, persistTimeout, persistMaskLen uint32, dsr bool, lbPref string, udpTimeout, maxConns, connRate uint32) *service {
+	if persistMaskLen == 0 || persistMaskLen > 32 {
+		persistMaskLen = 32
+	}
+
 	return &service{
-		name:          name,
-		id:            id,
-		ingressPorts:  ingressPorts,
-		loadBalancers: make(map[string]*loadBalancer),
+		name:           name,
+		id:             id,
+		ingressPorts:   ingressPorts,
+		schedName:      schedName,
+		persistTimeout: persistTimeout,
+		persistMaskLen: persistMaskLen,
+		dsr:            dsr,
+		lbPreference:   lbPref,
+		udpTimeout:     udpTimeout,
+		maxConns:       maxConns,
+		connRate:       connRate,
+		loadBalancers:  make(map[string]*loadBalancer),
+	}
+}
+
+// ipvsService returns the IPVS service programmed for the load balancer
+// identified by the passed firewall mark in the passed address family.
+func (s *service) ipvsService(fwMark uint32, family uint16) *ipvs.Service {
+	svc := &ipvs.Service{
+		AddressFamily: family,
+		FWMark:        fwMark,
+		SchedName:     s.schedName,
+	}
+
+	if s.persistTimeout > 0 {
+		// The kernel expects the mask in network byte order.
+		svc.Flags |= ipvs.SvcFlagPersistent
+		svc.Timeout = s.persistTimeout
+		if family == nl.FAMILY_V6 {
+			svc.Netmask = 128
+		} else {
+			svc.Netmask = nl.NativeEndian().Uint32(net.CIDRMask(int(s.persistMaskLen), 32))
+		}
+	}
+
+	return svc
+}
+
+// connFlags returns the forwarding method of the service backends in
+// the passed sandbox. Only the ingress sandbox uses direct routing.
+func (s *service) connFlags(sb *sandbox) uint32 {
+	if sb.ingress && s.dsr {
+		return ipvs.ConnectionFlagDirectRoute
 	}
+	return ipvs.ConnectionFlagMasq
 }
 
-func (c *controller) addServiceBinding(name, sid, nid, eid string, vip net.IP, ingressPorts []*PortConfig, ip net.IP) error {
+func (c *controller) addServiceBinding(name, sid, nid, eid string, vip, vip6 net.IP, ingressPorts []*PortConfig, schedName string, persistTimeout, persistMaskLen uint32, dsr bool, lbPref string, udpTimeout, maxConns, connRate uint32, metadata []string, ip, ip6, nodeAddr net.IP, weight uint32, group string) error {
 	var (
 		s          *service
 		addService bool
@@ -50,7 +97,12 @@ func (c *controller) addServiceBinding(name, sid, nid, eid string, vip net.IP, i
 	if !ok {
 		// Create a new service if we are seeing this service
 		// for the first time.
-		s = newService(name, sid, ingressPorts)
+		sched, ok := validSchedName(schedName)
+		if !ok {
+			logrus.Warnf("Unsupported scheduler %q for service %s, using %q", schedName, name, sched)
+		}
+		s = newService(name, sid, ingressPorts, sched, persistTimeout, persistMaskLen, dsr, lbPref, udpTimeout, maxConns, connRate)
+		s.traffic = c.trafficSplits[sid]
 		c.serviceBindings[sid] = s
 	}
 	c.Unlock()
@@ -63,8 +115,9 @@ func (c *controller) addServiceBinding(name, sid, nid, eid string, vip net.IP, i
 		// time.
 		lb = &loadBalancer{
 			vip:      vip,
+			vip6:     vip6,
 			fwMark:   fwMarkCtr,
-			backEnds: make(map[string]net.IP),
+			backEnds: make(map[string]lbBackend),
 			service:  s,
 		}
 
@@ -79,31 +132,68 @@ func (c *controller) addServiceBinding(name, sid, nid, eid string, vip net.IP, i
 		addService = true
 
 		// Add service name to vip in DNS, if vip is valid. Otherwise resort to DNS RR
-		svcIP := vip
+		svcIP, svcIP6 := vip, vip6
 		if len(svcIP) == 0 {
 			svcIP = ip
 		}
+		if len(svcIP6) == 0 {
+			svcIP6 = ip6
+		}
 
-		n.(*network).addSvcRecords(name, svcIP, nil, false)
+		n.(*network).addSvcRecords(name, svcIP, svcIP6, false)
 	}
 
-	lb.backEnds[eid] = ip
+	// A zero weight is not gossiped and stands for the default
+	// weight.
+	be := lbBackend{
+		ip:        ip,
+		ip6:       ip6,
+		weight:    int(weight),
+		preferred: c.isPreferredBackend(s.lbPreference, nodeAddr),
+		group:     group,
+	}
+	if be.weight == 0 {
+		be.weight = 1
+	}
+	bindingEv := ServiceBindingAdd
+	if _, ok := lb.backEnds[eid]; ok {
+		bindingEv = ServiceBindingUpdate
+	}
+	hadPreferred := lb.hasPreferred()
+	lb.backEnds[eid] = be
+	reprogram := lb.preferenceChanged(hadPreferred, eid)
+	if s.traffic != nil {
+		// The share of the other backends of the group changed
+		reprogram = lb.otherBackends(eid)
+	}
+	n.(*network).notifyServiceBinding(bindingEv, s, lb, eid, be)
+	be = lb.effectiveBackend(eid, be, lb.hasPreferred())
+	n.(*network).notifyService(s, lb, false)
 	s.Unlock()
 
+	// Publish the service metadata as TXT records. The most recent
+	// backend wins if the metadata differs across the backends.
+	if len(metadata) > 0 {
+		n.(*network).addSvcTXTRecords(name, metadata)
+	}
+
 	// Add endpoint IP to special "tasks.svc_name" so that the
 	// applications have access to DNS RR.
-	n.(*network).addSvcRecords("tasks."+name, ip, nil, false)
+	n.(*network).addSvcRecords("tasks."+name, ip, ip6, false)
 
 	// Add loadbalancer service and backend in all sandboxes in
 	// the network only if vip is valid.
 	if len(vip) != 0 {
-		n.(*network).addLBBackend(ip, vip, lb.fwMark, ingressPorts, addService)
+		n.(*network).addLBBackend(be, vip, lb.vip6, lb.fwMark, s, ingressPorts, addService)
+		for _, be := range reprogram {
+			n.(*network).addLBBackend(be, vip, lb.vip6, lb.fwMark, s, ingressPorts, false)
+		}
 	}
 
 	return nil
 }
 
-func (c *controller) rmServiceBinding(name, sid, nid, eid string, vip net.IP, ingressPorts []*PortConfig, ip net.IP) error {
+func (c *controller) rmServiceBinding(name, sid, nid, eid string, vip, vip6 net.IP, ingressPorts []*PortConfig, ip, ip6 net.IP) error {
 	var rmService bool
 
 	n, err := c.NetworkByID(nid)
@@ -126,9 +216,24 @@ func (c *controller) rmServiceBinding(name, sid, nid, eid string, vip net.IP, in
 		return nil
 	}
 
+	// The backend may have already been removed if the endpoint
+	// was marked unhealthy.
+	be, ok := lb.backEnds[eid]
+	if !ok {
+		s.Unlock()
+		return nil
+	}
+	n.(*network).notifyServiceBinding(ServiceBindingRemove, s, lb, eid, be)
+
 	// Delete the special "tasks.svc_name" backend record.
-	n.(*network).deleteSvcRecords("tasks."+name, ip, nil, false)
+	n.(*network).deleteSvcRecords("tasks."+name, ip, ip6, false)
+	hadPreferred := lb.hasPreferred()
 	delete(lb.backEnds, eid)
+	delete(lb.loads, eid)
+	reprogram := lb.preferenceChanged(hadPreferred, eid)
+	if s.traffic != nil {
+		reprogram = lb.otherBackends(eid)
+	}
 
 	if len(lb.backEnds) == 0 {
 		// All the backends for this service have been
@@ -138,14 +243,19 @@ func (c *controller) rmServiceBinding(name, sid, nid, eid string, vip net.IP, in
 
 		// Make sure to remove the right IP since if vip is
 		// not valid we would have added a DNS RR record.
-		svcIP := vip
+		svcIP, svcIP6 := vip, vip6
 		if len(svcIP) == 0 {
 			svcIP = ip
 		}
+		if len(svcIP6) == 0 {
+			svcIP6 = ip6
+		}
 
-		n.(*network).deleteSvcRecords(name, svcIP, nil, false)
+		n.(*network).deleteSvcRecords(name, svcIP, svcIP6, false)
+		n.(*network).deleteSvcTXTRecords(name)
 		delete(s.loadBalancers, nid)
 	}
+	n.(*network).notifyService(s, lb, rmService)
 
 	if len(s.loadBalancers) == 0 {
 		// All loadbalancers for the service removed. Time to
@@ -157,12 +267,357 @@ func (c *controller) rmServiceBinding(name, sid, nid, eid string, vip net.IP, in
 	// Remove loadbalancer service(if needed) and backend in all
 	// sandboxes in the network only if the vip is valid.
 	if len(vip) != 0 {
-		n.(*network).rmLBBackend(ip, vip, lb.fwMark, ingressPorts, rmService)
+		n.(*network).rmLBBackend(ip, ip6, vip, lb.vip6, lb.fwMark, s, ingressPorts, rmService)
+		for _, be := range reprogram {
+			n.(*network).addLBBackend(be, vip, lb.vip6, lb.fwMark, s, ingressPorts, false)
+		}
 	}
 
 	return nil
 }
 
+// preferenceChanged returns the backends, other than the one identified
+// by eid, whose weight must be reprogrammed because a preferred backend
+// appeared or the last one went away. Must be called with the service
+// lock held.
+func (lb *loadBalancer) preferenceChanged(hadPreferred bool, eid string) []lbBackend {
+	hasPreferred := lb.hasPreferred()
+	if hasPreferred == hadPreferred {
+		return nil
+	}
+
+	var backEnds []lbBackend
+	for id, be := range lb.backEnds {
+		if id != eid && !be.preferred {
+			backEnds = append(backEnds, lb.effectiveBackend(id, be, hasPreferred))
+		}
+	}
+	return backEnds
+}
+
+func (c *controller) ServiceStats(sid string) (*ServiceStats, error) {
+	c.Lock()
+	s, ok := c.serviceBindings[sid]
+	c.Unlock()
+	if !ok {
+		return nil, types.NotFoundErrorf("service %s not found", sid)
+	}
+
+	type lbInfo struct {
+		nid    string
+		vip    net.IP
+		vip6   net.IP
+		fwMark uint32
+	}
+
+	s.Lock()
+	stats := &ServiceStats{ServiceID: s.id, Name: s.name}
+	var lbs []lbInfo
+	for nid, lb := range s.loadBalancers {
+		if len(lb.vip) != 0 {
+			lbs = append(lbs, lbInfo{nid, lb.vip, lb.vip6, lb.fwMark})
+		}
+	}
+	s.Unlock()
+
+	for _, lb := range lbs {
+		n, err := c.NetworkByID(lb.nid)
+		if err != nil {
+			continue
+		}
+
+		lbStats := &LBStats{NetworkID: lb.nid, VIP: lb.vip, VIPv6: lb.vip6}
+		backEnds := make(map[string]*BackendStats)
+		for _, sb := range n.(*network).lbSandboxes() {
+			sb.lbStats(lb.fwMark, nl.FAMILY_V4, lbStats, backEnds)
+			if len(lb.vip6) != 0 {
+				sb.lbStats(lb.fwMark, nl.FAMILY_V6, lbStats, backEnds)
+			}
+		}
+		for _, be := range backEnds {
+			lbStats.Backends = append(lbStats.Backends, be)
+		}
+		stats.LoadBalancers = append(stats.LoadBalancers, lbStats)
+	}
+
+	return stats, nil
+}
+
+// setBackendLoad records the number of connections a node has active
+// to a service backend and reprograms the backends whose weight changes.
+func (c *controller) setBackendLoad(nid, sid, eid, node string, conns uint32, isDelete bool) {
+	n, err := c.NetworkByID(nid)
+	if err != nil {
+		return
+	}
+
+	c.Lock()
+	s, ok := c.serviceBindings[sid]
+	c.Unlock()
+	if !ok {
+		return
+	}
+
+	s.Lock()
+	lb, ok := s.loadBalancers[nid]
+	if !ok {
+		s.Unlock()
+		return
+	}
+	if _, ok := lb.backEnds[eid]; !ok {
+		s.Unlock()
+		return
+	}
+
+	hasPreferred := lb.hasPreferred()
+	weights := make(map[string]int, len(lb.backEnds))
+	for id, be := range lb.backEnds {
+		weights[id] = lb.effectiveBackend(id, be, hasPreferred).weight
+	}
+
+	if isDelete {
+		delete(lb.loads[eid], node)
+	} else {
+		if lb.loads == nil {
+			lb.loads = make(map[string]map[string]uint32)
+		}
+		if lb.loads[eid] == nil {
+			lb.loads[eid] = make(map[string]uint32)
+		}
+		lb.loads[eid][node] = conns
+	}
+
+	var reprogram []lbBackend
+	for id, be := range lb.backEnds {
+		if be = lb.effectiveBackend(id, be, hasPreferred); be.weight != weights[id] {
+			reprogram = append(reprogram, be)
+		}
+	}
+	vip, vip6, fwMark := lb.vip, lb.vip6, lb.fwMark
+	s.Unlock()
+
+	if len(vip) == 0 {
+		return
+	}
+	for _, be := range reprogram {
+		n.(*network).addLBBackend(be, vip, vip6, fwMark, s, s.ingressPorts, false)
+	}
+}
+
+// setServiceTraffic applies the traffic split of a service, nil sending
+// the traffic to all the backends again, and reprograms its load
+// balancers.
+func (c *controller) setServiceTraffic(sid string, t *trafficSplit) {
+	c.Lock()
+	if t == nil {
+		delete(c.trafficSplits, sid)
+	} else {
+		c.trafficSplits[sid] = t
+	}
+	s, ok := c.serviceBindings[sid]
+	c.Unlock()
+	if !ok {
+		return
+	}
+
+	type lbInfo struct {
+		nid      string
+		vip      net.IP
+		vip6     net.IP
+		fwMark   uint32
+		backEnds []lbBackend
+	}
+
+	s.Lock()
+	s.traffic = t
+	var lbs []lbInfo
+	for nid, lb := range s.loadBalancers {
+		if len(lb.vip) != 0 {
+			lbs = append(lbs, lbInfo{nid, lb.vip, lb.vip6, lb.fwMark, lb.otherBackends("")})
+		}
+	}
+	s.Unlock()
+
+	for _, lb := range lbs {
+		n, err := c.NetworkByID(lb.nid)
+		if err != nil {
+			continue
+		}
+		for _, be := range lb.backEnds {
+			n.(*network).addLBBackend(be, lb.vip, lb.vip6, lb.fwMark, s, s.ingressPorts, false)
+		}
+	}
+}
+
+// publishLoadHints gossips, every interval, the number of connections
+// the load balancers of this node have active to each service backend.
+func (c *controller) publishLoadHints(interval time.Duration, stopCh chan struct{}) {
+	// Last gossiped load keyed with "<network id>/<table key>"
+	published := make(map[string]uint32)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			c.gossipLoadHints(published)
+		}
+	}
+}
+
+func (c *controller) gossipLoadHints(published map[string]uint32) {
+	c.Lock()
+	agent := c.agent
+	services := make([]*service, 0, len(c.serviceBindings))
+	for _, s := range c.serviceBindings {
+		services = append(services, s)
+	}
+	c.Unlock()
+
+	if agent == nil {
+		return
+	}
+
+	current := make(map[string]bool)
+	for _, s := range services {
+		stats, err := c.ServiceStats(s.id)
+		if err != nil {
+			continue
+		}
+
+		for _, lbStats := range stats.LoadBalancers {
+			conns := make(map[string]uint32)
+			for _, be := range lbStats.Backends {
+				conns[be.IP.String()] = uint32(be.ActiveConnections)
+			}
+
+			backEnds := make(map[string]net.IP)
+			s.Lock()
+			if lb, ok := s.loadBalancers[lbStats.NetworkID]; ok {
+				for eid, be := range lb.backEnds {
+					backEnds[eid] = be.ip
+				}
+			}
+			s.Unlock()
+
+			for eid, ip := range backEnds {
+				key := eid + "/" + agent.bindAddr
+				id := lbStats.NetworkID + "/" + key
+				current[id] = true
+
+				hint := &LoadHint{
+					ServiceID:         s.id,
+					EndpointIP:        ip.String(),
+					ActiveConnections: conns[ip.String()],
+				}
+				if last, ok := published[id]; ok && last == hint.ActiveConnections {
+					continue
+				}
+
+				buf, err := proto.Marshal(hint)
+				if err != nil {
+					logrus.Errorf("Failed to marshal load hint for backend %s: %v", ip, err)
+					continue
+				}
+
+				if _, ok := published[id]; ok {
+					err = agent.networkDB.UpdateEntry(loadTable, lbStats.NetworkID, key, buf)
+				} else if err = agent.networkDB.CreateEntry(loadTable, lbStats.NetworkID, key, buf); err != nil {
+					err = agent.networkDB.UpdateEntry(loadTable, lbStats.NetworkID, key, buf)
+				}
+				if err != nil {
+					logrus.Debugf("Failed to gossip load hint for backend %s: %v", ip, err)
+					continue
+				}
+				published[id] = hint.ActiveConnections
+			}
+		}
+	}
+
+	for id := range published {
+		if current[id] {
+			continue
+		}
+
+		parts := strings.SplitN(id, "/", 2)
+		if err := agent.networkDB.DeleteEntry(loadTable, parts[0], parts[1]); err != nil {
+			logrus.Debugf("Failed to withdraw load hint %s: %v", parts[1], err)
+		}
+		delete(published, id)
+	}
+}
+
+// lbSandboxes returns the sandboxes connected to the network, each of
+// which programs the load balancers of the network.
+func (n *network) lbSandboxes() []*sandbox {
+	var sandboxes []*sandbox
+
+	seen := make(map[string]bool)
+	n.WalkEndpoints(func(e Endpoint) bool {
+		if sb, ok := e.(*endpoint).getSandbox(); ok && !seen[sb.ID()] {
+			seen[sb.ID()] = true
+			sandboxes = append(sandboxes, sb)
+		}
+		return false
+	})
+
+	return sandboxes
+}
+
+// lbStats adds the IPVS statistics of the load balancer identified by
+// the passed firewall mark and address family in the sandbox to the
+// passed counters.
+func (sb *sandbox) lbStats(fwMark uint32, family uint16, lbStats *LBStats, backEnds map[string]*BackendStats) {
+	if sb.osSbox == nil {
+		return
+	}
+
+	i, err := ipvs.New(sb.Key())
+	if err != nil {
+		logrus.Errorf("Failed to create a ipvs handle for sbox %s: %v", sb.Key(), err)
+		return
+	}
+	defer i.Close()
+
+	s := &ipvs.Service{
+		AddressFamily: family,
+		FWMark:        fwMark,
+	}
+
+	svc, err := i.GetService(s)
+	if err != nil {
+		logrus.Debugf("Failed to get service fwmark %d in sbox %s: %v", fwMark, sb.Key(), err)
+		return
+	}
+	lbStats.Connections += uint64(svc.Stats.Connections)
+	lbStats.PacketsIn += uint64(svc.Stats.PacketsIn)
+	lbStats.PacketsOut += uint64(svc.Stats.PacketsOut)
+	lbStats.BytesIn += svc.Stats.BytesIn
+	lbStats.BytesOut += svc.Stats.BytesOut
+
+	dests, err := i.GetDestinations(s)
+	if err != nil {
+		logrus.Debugf("Failed to get real servers of fwmark %d in sbox %s: %v", fwMark, sb.Key(), err)
+		return
+	}
+	for _, d := range dests {
+		be, ok := backEnds[d.Address.String()]
+		if !ok {
+			be = &BackendStats{IP: d.Address}
+			backEnds[d.Address.String()] = be
+		}
+		be.ActiveConnections += uint64(d.ActiveConnections)
+		be.InactiveConnections += uint64(d.InactiveConnections)
+		be.Connections += uint64(d.Stats.Connections)
+		be.PacketsIn += uint64(d.Stats.PacketsIn)
+		be.PacketsOut += uint64(d.Stats.PacketsOut)
+		be.BytesIn += d.Stats.BytesIn
+		be.BytesOut += d.Stats.BytesOut
+	}
+}
+
 // Get all loadbalancers on this network that is currently discovered
 // on this node.
 func (n *network) connectedLoadbalancers() []*loadBalancer {
@@ -189,6 +644,17 @@ func (sb *sandbox) populateLoadbalancers(ep *endpoint) {
 	n := ep.getNetwork()
 	eIP := ep.Iface().Address()
 
+	sb.updateHostPorts(ep, false)
+
+	if vip := ep.dsrVIP(); vip != nil && sb.osSbox != nil {
+		// The ingress sandbox forwards the packets to the vip of
+		// a direct server return service unmodified, the backend
+		// must accept them on its loopback.
+		if err := sb.osSbox.AddLoopbackAliasIP(vip); err != nil {
+			logrus.Errorf("Failed to add vip %s to the loopback of sbox %s: %v", vip, sb.Key(), err)
+		}
+	}
+
 	if sb.ingress {
 		// For the ingress sandbox if this is not gateway
 		// endpoint do nothing.
@@ -214,18 +680,85 @@ func (sb *sandbox) populateLoadbalancers(ep *endpoint) {
 		}
 
 		addService := true
-		for _, ip := range lb.backEnds {
-			sb.addLBBackend(ip, lb.vip, lb.fwMark, lb.service.ingressPorts,
-				eIP, gwIP, addService)
+		hasPreferred := lb.hasPreferred()
+		for eid, be := range lb.backEnds {
+			be = lb.effectiveBackend(eid, be, hasPreferred)
+			sb.addLBBackend(be, lb.vip, lb.vip6, lb.fwMark, lb.service,
+				lb.service.ingressPorts, eIP, gwIP, addService)
 			addService = false
 		}
 	}
 }
 
+// Remove the vip of a direct server return service from the sandbox
+// loopback when the backend endpoint leaves it.
+func (sb *sandbox) releaseLoadbalancers(ep *endpoint) {
+	sb.updateHostPorts(ep, true)
+
+	if vip := ep.dsrVIP(); vip != nil && sb.osSbox != nil {
+		if err := sb.osSbox.RemoveLoopbackAliasIP(vip); err != nil {
+			logrus.Warnf("Failed to remove vip %s from the loopback of sbox %s: %v", vip, sb.Key(), err)
+		}
+	}
+}
+
+// updateHostPorts publishes, or withdraws, the host mode ingress ports
+// of the sandbox service endpoint on this node. The node ports are
+// translated to the sandbox address in the gateway network, which is
+// reachable from the host, so both endpoints need to be joined.
+func (sb *sandbox) updateHostPorts(ep *endpoint, isDelete bool) {
+	if sb.ingress {
+		return
+	}
+
+	var ingressEp, gwEp *endpoint
+	switch {
+	case ep.endpointInGWNetwork():
+		gwEp = ep
+		for _, e := range sb.getConnectedEndpoints() {
+			if e.getNetwork().ingress {
+				ingressEp = e
+			}
+		}
+	case ep.getNetwork().ingress:
+		ingressEp = ep
+		gwEp = sb.getEndpointInGWNetwork()
+	}
+	if ingressEp == nil || gwEp == nil || gwEp.Iface().Address() == nil {
+		return
+	}
+
+	var hostPorts []*PortConfig
+	for _, iPort := range ingressEp.ingressPorts {
+		if iPort.PublishMode == PublishModeHost {
+			hostPorts = append(hostPorts, iPort)
+		}
+	}
+	if len(hostPorts) == 0 {
+		return
+	}
+
+	if err := programHostPorts(gwEp.Iface().Address().IP, hostPorts, isDelete); err != nil {
+		logrus.Errorf("Failed to program host mode ports of endpoint %s: %v", ingressEp.Name(), err)
+	}
+}
+
+// dsrVIP returns the vip to be owned by the endpoint sandbox if the
+// endpoint is a backend of a direct server return ingress service.
+func (ep *endpoint) dsrVIP() *net.IPNet {
+	ep.Lock()
+	defer ep.Unlock()
+
+	if !ep.svcDSR || ep.svcID == "" || len(ep.virtualIP) == 0 || !ep.network.ingress {
+		return nil
+	}
+	return &net.IPNet{IP: ep.virtualIP, Mask: net.CIDRMask(32, 32)}
+}
+
 // Add loadbalancer backend to all sandboxes which has a connection to
 // this network. If needed add the service as well, as specified by
 // the addService bool.
-func (n *network) addLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*PortConfig, addService bool) {
+func (n *network) addLBBackend(be lbBackend, vip, vip6 net.IP, fwMark uint32, svc *service, ingressPorts []*PortConfig, addService bool) {
 	n.WalkEndpoints(func(e Endpoint) bool {
 		ep := e.(*endpoint)
 		if sb, ok := ep.getSandbox(); ok {
@@ -234,7 +767,7 @@ func (n *network) addLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*Po
 				gwIP = ep.Iface().Address().IP
 			}
 
-			sb.addLBBackend(ip, vip, fwMark, ingressPorts, ep.Iface().Address(), gwIP, addService)
+			sb.addLBBackend(be, vip, vip6, fwMark, svc, ingressPorts, ep.Iface().Address(), gwIP, addService)
 		}
 
 		return false
@@ -244,7 +777,7 @@ func (n *network) addLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*Po
 // Remove loadbalancer backend from all sandboxes which has a
 // connection to this network. If needed remove the service entry as
 // well, as specified by the rmService bool.
-func (n *network) rmLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*PortConfig, rmService bool) {
+func (n *network) rmLBBackend(ip, ip6, vip, vip6 net.IP, fwMark uint32, svc *service, ingressPorts []*PortConfig, rmService bool) {
 	n.WalkEndpoints(func(e Endpoint) bool {
 		ep := e.(*endpoint)
 		if sb, ok := ep.getSandbox(); ok {
@@ -253,7 +786,7 @@ func (n *network) rmLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*Por
 				gwIP = ep.Iface().Address().IP
 			}
 
-			sb.rmLBBackend(ip, vip, fwMark, ingressPorts, ep.Iface().Address(), gwIP, rmService)
+			sb.rmLBBackend(ip, ip6, vip, vip6, fwMark, svc, ingressPorts, ep.Iface().Address(), gwIP, rmService)
 		}
 
 		return false
@@ -261,11 +794,13 @@ func (n *network) rmLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*Por
 }
 
 // Add loadbalancer backend into one connected sandbox.
-func (sb *sandbox) addLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*PortConfig, eIP *net.IPNet, gwIP net.IP, addService bool) {
+func (sb *sandbox) addLBBackend(be lbBackend, vip, vip6 net.IP, fwMark uint32, svc *service, ingressPorts []*PortConfig, eIP *net.IPNet, gwIP net.IP, addService bool) {
 	if sb.osSbox == nil {
 		return
 	}
 
+	sb.stopLBDrain(fwMark, be.ip)
+
 	i, err := ipvs.New(sb.Key())
 	if err != nil {
 		logrus.Errorf("Failed to create a ipvs handle for sbox %s: %v", sb.Key(), err)
@@ -273,11 +808,7 @@ func (sb *sandbox) addLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*P
 	}
 	defer i.Close()
 
-	s := &ipvs.Service{
-		AddressFamily: nl.FAMILY_V4,
-		FWMark:        fwMark,
-		SchedName:     ipvs.RoundRobin,
-	}
+	s := svc.ipvsService(fwMark, nl.FAMILY_V4)
 
 	if addService {
 		var iPorts []*PortConfig
@@ -289,34 +820,207 @@ func (sb *sandbox) addLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*P
 			}
 		}
 
-		logrus.Debugf("Creating service for vip %s fwMark %d ingressPorts %#v", vip, fwMark, iPorts)
-		if err := invokeFWMarker(sb.Key(), vip, fwMark, iPorts, eIP, false); err != nil {
+		logrus.Debugf("Creating service for vip %s fwMark %d scheduler %s ingressPorts %#v", vip, fwMark, s.SchedName, iPorts)
+		if err := invokeFWMarker(sb.Key(), vip, vip6, fwMark, iPorts, eIP, svc.dsr, svc.connRate, false); err != nil {
 			logrus.Errorf("Failed to add firewall mark rule in sbox %s: %v", sb.Key(), err)
 			return
 		}
 
+		sb.startIngressProxies(vip, iPorts)
+
 		if err := i.NewService(s); err != nil {
 			logrus.Errorf("Failed to create a new service for vip %s fwmark %d: %v", vip, fwMark, err)
 			return
 		}
+
+		sb.setUDPTimeout(i, svc.udpTimeout)
 	}
 
 	d := &ipvs.Destination{
-		AddressFamily: nl.FAMILY_V4,
-		Address:       ip,
-		Weight:        1,
+		AddressFamily:   nl.FAMILY_V4,
+		Address:         be.ip,
+		Weight:          be.weight,
+		ConnectionFlags: svc.connFlags(sb),
+		UpperThreshold:  svc.maxConns,
 	}
 
 	// Remove the sched name before using the service to add
 	// destination.
 	s.SchedName = ""
-	if err := i.NewDestination(s, d); err != nil && err != syscall.EEXIST {
-		logrus.Errorf("Failed to create real server %s for vip %s fwmark %d: %v", ip, vip, fwMark, err)
+	err = i.NewDestination(s, d)
+	if err == syscall.EEXIST {
+		// The backend may still be draining with a zero weight.
+		err = i.UpdateDestination(s, d)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to create real server %s for vip %s fwmark %d: %v", be.ip, vip, fwMark, err)
+	}
+
+	if len(vip6) == 0 {
+		return
+	}
+
+	// The IPv6 vip is balanced by a service of its own sharing the
+	// firewall mark, IPVS keeps the services of each family apart.
+	s6 := svc.ipvsService(fwMark, nl.FAMILY_V6)
+	if addService {
+		if err := i.NewService(s6); err != nil {
+			logrus.Errorf("Failed to create a new service for vip %s fwmark %d: %v", vip6, fwMark, err)
+			return
+		}
+	}
+
+	if be.ip6 == nil {
+		return
+	}
+
+	d6 := &ipvs.Destination{
+		AddressFamily:   nl.FAMILY_V6,
+		Address:         be.ip6,
+		Weight:          be.weight,
+		ConnectionFlags: svc.connFlags(sb),
+		UpperThreshold:  svc.maxConns,
+	}
+
+	s6.SchedName = ""
+	err = i.NewDestination(s6, d6)
+	if err == syscall.EEXIST {
+		err = i.UpdateDestination(s6, d6)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to create real server %s for vip %s fwmark %d: %v", be.ip6, vip6, fwMark, err)
+	}
+}
+
+// warmLBSandbox initializes the IPVS state of the sandbox with the passed
+// key so that programming its first load balancer does not pay for it.
+func warmLBSandbox(key string) {
+	i, err := ipvs.New(key)
+	if err != nil {
+		logrus.Warnf("Failed to create a ipvs handle for sbox %s: %v", key, err)
+		return
+	}
+	i.Close()
+}
+
+// setUDPTimeout raises the timeout of the idle UDP flows in the sandbox
+// to the one requested by a service. The IPVS and conntrack timeouts
+// are shared by the namespace, hence the longest requested one wins.
+func (sb *sandbox) setUDPTimeout(i *ipvs.Handle, timeout uint32) {
+	sb.Lock()
+	if timeout <= sb.lbUDPTimeout {
+		sb.Unlock()
+		return
+	}
+	sb.lbUDPTimeout = timeout
+	sb.Unlock()
+
+	if err := i.SetConfig(&ipvs.Config{TimeoutUDP: time.Duration(timeout) * time.Second}); err != nil {
+		logrus.Errorf("Failed to set the ipvs udp timeout in sbox %s: %v", sb.Key(), err)
+	}
+
+	var err error
+	if e := sb.osSbox.InvokeFunc(func() {
+		err = ioutil.WriteFile("/proc/sys/net/netfilter/nf_conntrack_udp_timeout_stream", []byte(strconv.Itoa(int(timeout))), 0644)
+	}); e != nil {
+		err = e
+	}
+	if err != nil {
+		logrus.Errorf("Failed to set the conntrack udp timeout in sbox %s: %v", sb.Key(), err)
+	}
+}
+
+func lbDrainKey(fwMark uint32, ip net.IP) string {
+	return fmt.Sprintf("%d/%s", fwMark, ip)
+}
+
+// stopLBDrain cancels the pending removal of a draining backend.
+func (sb *sandbox) stopLBDrain(fwMark uint32, ip net.IP) {
+	key := lbDrainKey(fwMark, ip)
+
+	sb.Lock()
+	defer sb.Unlock()
+	if t, ok := sb.lbDrains[key]; ok {
+		t.Stop()
+		delete(sb.lbDrains, key)
 	}
 }
 
-// Remove loadbalancer backend from one connected sandbox.
-func (sb *sandbox) rmLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*PortConfig, eIP *net.IPNet, gwIP net.IP, rmService bool) {
+// Remove loadbalancer backend from one connected sandbox. If a drain
+// period is configured the backend stops receiving new connections
+// right away but is deleted only once the period elapses.
+func (sb *sandbox) rmLBBackend(ip, ip6, vip, vip6 net.IP, fwMark uint32, svc *service, ingressPorts []*PortConfig, eIP *net.IPNet, gwIP net.IP, rmService bool) {
+	if sb.osSbox == nil {
+		return
+	}
+
+	var drain time.Duration
+	if c := sb.controller; c != nil && c.cfg != nil {
+		drain = c.cfg.Daemon.LBDrainPeriod
+	}
+
+	if drain > 0 {
+		i, err := ipvs.New(sb.Key())
+		if err != nil {
+			logrus.Errorf("Failed to create a ipvs handle for sbox %s: %v", sb.Key(), err)
+			return
+		}
+
+		err = i.UpdateDestination(&ipvs.Service{
+			AddressFamily: nl.FAMILY_V4,
+			FWMark:        fwMark,
+		}, &ipvs.Destination{
+			AddressFamily:   nl.FAMILY_V4,
+			Address:         ip,
+			Weight:          0,
+			ConnectionFlags: svc.connFlags(sb),
+		})
+		if err == nil && len(vip6) != 0 && ip6 != nil {
+			err = i.UpdateDestination(&ipvs.Service{
+				AddressFamily: nl.FAMILY_V6,
+				FWMark:        fwMark,
+			}, &ipvs.Destination{
+				AddressFamily:   nl.FAMILY_V6,
+				Address:         ip6,
+				Weight:          0,
+				ConnectionFlags: svc.connFlags(sb),
+			})
+		}
+		i.Close()
+
+		if err == nil {
+			key := lbDrainKey(fwMark, ip)
+			sb.Lock()
+			if sb.lbDrains == nil {
+				sb.lbDrains = make(map[string]*time.Timer)
+			}
+			if t, ok := sb.lbDrains[key]; ok {
+				t.Stop()
+			}
+			var t *time.Timer
+			t = time.AfterFunc(drain, func() {
+				sb.Lock()
+				if sb.lbDrains[key] != t {
+					sb.Unlock()
+					return
+				}
+				delete(sb.lbDrains, key)
+				sb.Unlock()
+
+				sb.delLBBackend(ip, ip6, vip, vip6, fwMark, svc, ingressPorts, eIP, gwIP, rmService)
+			})
+			sb.lbDrains[key] = t
+			sb.Unlock()
+			return
+		}
+
+		logrus.Warnf("Failed to drain real server %s for vip %s fwmark %d, removing it: %v", ip, vip, fwMark, err)
+	}
+
+	sb.delLBBackend(ip, ip6, vip, vip6, fwMark, svc, ingressPorts, eIP, gwIP, rmService)
+}
+
+func (sb *sandbox) delLBBackend(ip, ip6, vip, vip6 net.IP, fwMark uint32, svc *service, ingressPorts []*PortConfig, eIP *net.IPNet, gwIP net.IP, rmService bool) {
 	if sb.osSbox == nil {
 		return
 	}
@@ -344,6 +1048,23 @@ func (sb *sandbox) rmLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*Po
 		return
 	}
 
+	var s6 *ipvs.Service
+	if len(vip6) != 0 {
+		s6 = &ipvs.Service{
+			AddressFamily: nl.FAMILY_V6,
+			FWMark:        fwMark,
+		}
+		if ip6 != nil {
+			if err := i.DelDestination(s6, &ipvs.Destination{
+				AddressFamily: nl.FAMILY_V6,
+				Address:       ip6,
+				Weight:        1,
+			}); err != nil {
+				logrus.Errorf("Failed to delete real server %s for vip %s fwmark %d: %v", ip6, vip6, fwMark, err)
+			}
+		}
+	}
+
 	if rmService {
 		s.SchedName = ipvs.RoundRobin
 		if err := i.DelService(s); err != nil {
@@ -351,22 +1072,151 @@ func (sb *sandbox) rmLBBackend(ip, vip net.IP, fwMark uint32, ingressPorts []*Po
 			return
 		}
 
+		if s6 != nil {
+			s6.SchedName = ipvs.RoundRobin
+			if err := i.DelService(s6); err != nil {
+				logrus.Errorf("Failed to delete service for vip %s fwmark %d: %v", vip6, fwMark, err)
+			}
+		}
+
 		var iPorts []*PortConfig
 		if sb.ingress {
 			iPorts = ingressPorts
+			sb.stopIngressProxies(iPorts)
 			if err := programIngress(gwIP, iPorts, true); err != nil {
 				logrus.Errorf("Failed to delete ingress: %v", err)
 				return
 			}
 		}
 
-		if err := invokeFWMarker(sb.Key(), vip, fwMark, iPorts, eIP, true); err != nil {
+		if err := invokeFWMarker(sb.Key(), vip, vip6, fwMark, iPorts, eIP, svc.dsr, svc.connRate, true); err != nil {
 			logrus.Errorf("Failed to add firewall mark rule in sbox %s: %v", sb.Key(), err)
 			return
 		}
 	}
 }
 
+// usesProxyProtocol returns whether the connections to the ingress port
+// are forwarded by a PROXY protocol proxy instead of the load balancer.
+func usesProxyProtocol(iPort *PortConfig) bool {
+	return iPort.ProxyProtocol != 0 && iPort.Protocol == ProtocolTCP &&
+		iPort.PublishMode == PublishModeIngress && iPort.PortCount <= 1
+}
+
+// portSpan is a range of count node ports translated to the range of
+// target ports of the same size starting at port.
+type portSpan struct {
+	nodePort, port, count uint32
+}
+
+// ingressPortSpans returns the spans the rules of a published port are
+// programmed for. A range mapping each node port to the same target port
+// is programmed as a whole, otherwise each port needs its own rules since
+// the NAT targets do not shift a port range.
+func ingressPortSpans(iPort *PortConfig) []portSpan {
+	count := iPort.PortCount
+	if count <= 1 {
+		return []portSpan{{iPort.NodePort, iPort.Port, 1}}
+	}
+	if iPort.NodePort == iPort.Port {
+		return []portSpan{{iPort.NodePort, iPort.Port, count}}
+	}
+
+	spans := make([]portSpan, 0, count)
+	for i := uint32(0); i < count; i++ {
+		spans = append(spans, portSpan{iPort.NodePort + i, iPort.Port + i, 1})
+	}
+	return spans
+}
+
+// portMatch returns the iptables match of the range of count ports
+// starting at port.
+func portMatch(port, count uint32) string {
+	if count <= 1 {
+		return strconv.Itoa(int(port))
+	}
+	return fmt.Sprintf("%d:%d", port, port+count-1)
+}
+
+// portTarget returns the iptables NAT target of the range of count ports
+// starting at port.
+func portTarget(port, count uint32) string {
+	if count <= 1 {
+		return strconv.Itoa(int(port))
+	}
+	return fmt.Sprintf("%d-%d", port, port+count-1)
+}
+
+// Start a PROXY protocol proxy in the ingress sandbox for each ingress
+// port requesting it. The proxy listens on the node port and forwards
+// the connections to the vip, so they are still load balanced.
+func (sb *sandbox) startIngressProxies(vip net.IP, ingressPorts []*PortConfig) {
+	for _, iPort := range ingressPorts {
+		if !usesProxyProtocol(iPort) {
+			if iPort.ProxyProtocol != 0 && iPort.PublishMode == PublishModeIngress {
+				logrus.Warnf("PROXY protocol is not supported for %s port %d", PortConfig_Protocol_name[int32(iPort.Protocol)], iPort.NodePort)
+			}
+			continue
+		}
+
+		var (
+			l   net.Listener
+			err error
+		)
+		if e := sb.osSbox.InvokeFunc(func() {
+			l, err = net.ListenTCP("tcp", &net.TCPAddr{Port: int(iPort.NodePort)})
+		}); e != nil {
+			err = e
+		}
+		if err != nil {
+			logrus.Errorf("Failed to listen on ingress port %d in sbox %s: %v", iPort.NodePort, sb.Key(), err)
+			continue
+		}
+
+		backendAddr := net.JoinHostPort(vip.String(), strconv.Itoa(int(iPort.Port)))
+		dial := func() (net.Conn, error) {
+			var (
+				conn net.Conn
+				err  error
+			)
+			if e := sb.osSbox.InvokeFunc(func() {
+				conn, err = net.Dial("tcp", backendAddr)
+			}); e != nil {
+				return nil, e
+			}
+			return conn, err
+		}
+
+		p, err := proxyproto.NewTCPProxy(l, dial, int(iPort.ProxyProtocol))
+		if err != nil {
+			l.Close()
+			logrus.Errorf("Failed to create PROXY protocol proxy for ingress port %d: %v", iPort.NodePort, err)
+			continue
+		}
+
+		sb.Lock()
+		if sb.ingressProxies == nil {
+			sb.ingressProxies = make(map[uint32]*proxyproto.TCPProxy)
+		}
+		sb.ingressProxies[iPort.NodePort] = p
+		sb.Unlock()
+
+		go p.Run()
+	}
+}
+
+func (sb *sandbox) stopIngressProxies(ingressPorts []*PortConfig) {
+	sb.Lock()
+	defer sb.Unlock()
+
+	for _, iPort := range ingressPorts {
+		if p, ok := sb.ingressProxies[iPort.NodePort]; ok && usesProxyProtocol(iPort) {
+			p.Close()
+			delete(sb.ingressProxies, iPort.NodePort)
+		}
+	}
+}
+
 func programIngress(gwIP net.IP, ingressPorts []*PortConfig, isDelete bool) error {
 	addDelOpt := "-A"
 	if isDelete {
@@ -374,10 +1224,77 @@ func programIngress(gwIP net.IP, ingressPorts []*PortConfig, isDelete bool) erro
 	}
 
 	for _, iPort := range ingressPorts {
-		rule := strings.Fields(fmt.Sprintf("-t nat %s PREROUTING -p %s --dport %d -j DNAT --to-destination %s:%d",
-			addDelOpt, strings.ToLower(PortConfig_Protocol_name[int32(iPort.Protocol)]), iPort.NodePort, gwIP, iPort.NodePort))
-		if err := iptables.RawCombinedOutput(rule...); err != nil {
-			return fmt.Errorf("setting up rule failed, %v: %v", rule, err)
+		// Host mode ports are published by the nodes running
+		// the service backends.
+		if iPort.PublishMode == PublishModeHost {
+			continue
+		}
+
+		srcMatches, err := sourceMatches(iPort)
+		if err != nil {
+			return err
+		}
+
+		// The connections from the sources not allowed are not
+		// translated, hence they never reach the ingress sandbox.
+		for _, srcMatch := range srcMatches {
+			rule := strings.Fields(fmt.Sprintf("-t nat %s PREROUTING %s -p %s --dport %s -j DNAT --to-destination %s:%s",
+				addDelOpt, srcMatch, strings.ToLower(PortConfig_Protocol_name[int32(iPort.Protocol)]),
+				portMatch(iPort.NodePort, iPort.PortCount), gwIP, portTarget(iPort.NodePort, iPort.PortCount)))
+			if err := iptables.RawCombinedOutput(rule...); err != nil {
+				return fmt.Errorf("setting up rule failed, %v: %v", rule, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sourceMatches returns the iptables matches of the clients allowed to
+// connect to the port, a single empty match if all the clients are.
+func sourceMatches(iPort *PortConfig) ([]string, error) {
+	if len(iPort.AllowedSources) == 0 {
+		return []string{""}, nil
+	}
+
+	matches := make([]string, 0, len(iPort.AllowedSources))
+	for _, src := range iPort.AllowedSources {
+		_, ipNet, err := net.ParseCIDR(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed source %q for port %d: %v", src, iPort.NodePort, err)
+		}
+		matches = append(matches, "-s "+ipNet.String())
+	}
+	return matches, nil
+}
+
+func programHostPorts(ip net.IP, hostPorts []*PortConfig, isDelete bool) error {
+	addDelOpt := "-A"
+	if isDelete {
+		addDelOpt = "-D"
+	}
+
+	for _, iPort := range hostPorts {
+		srcMatches, err := sourceMatches(iPort)
+		if err != nil {
+			return err
+		}
+
+		proto := strings.ToLower(PortConfig_Protocol_name[int32(iPort.Protocol)])
+		for _, span := range ingressPortSpans(iPort) {
+			var rules [][]string
+			for _, srcMatch := range srcMatches {
+				rules = append(rules,
+					strings.Fields(fmt.Sprintf("-t nat %s PREROUTING %s -p %s --dport %s -j DNAT --to-destination %s:%s",
+						addDelOpt, srcMatch, proto, portMatch(span.nodePort, span.count), ip, portTarget(span.port, span.count))),
+					strings.Fields(fmt.Sprintf("-t filter %s FORWARD %s -p %s -d %s --dport %s -j ACCEPT",
+						addDelOpt, srcMatch, proto, ip, portMatch(span.port, span.count))))
+			}
+			for _, rule := range rules {
+				if err := iptables.RawCombinedOutput(rule...); err != nil {
+					return fmt.Errorf("setting up rule failed, %v: %v", rule, err)
+				}
+			}
 		}
 	}
 
@@ -385,8 +1302,9 @@ func programIngress(gwIP net.IP, ingressPorts []*PortConfig, isDelete bool) erro
 }
 
 // Invoke fwmarker reexec routine to mark vip destined packets with
-// the passed firewall mark.
-func invokeFWMarker(path string, vip net.IP, fwMark uint32, ingressPorts []*PortConfig, eIP *net.IPNet, isDelete bool) error {
+// the passed firewall mark. With dsr the ingress packets are sent to
+// the vip, which the backends own, instead of the sandbox itself.
+func invokeFWMarker(path string, vip, vip6 net.IP, fwMark uint32, ingressPorts []*PortConfig, eIP *net.IPNet, dsr bool, connRate uint32, isDelete bool) error {
 	var ingressPortsFile string
 	if len(ingressPorts) != 0 {
 		f, err := ioutil.TempFile("", "port_configs")
@@ -418,9 +1336,14 @@ func invokeFWMarker(path string, vip net.IP, fwMark uint32, ingressPorts []*Port
 		addDelOpt = "-D"
 	}
 
+	var vip6Str string
+	if len(vip6) != 0 {
+		vip6Str = vip6.String()
+	}
+
 	cmd := &exec.Cmd{
 		Path:   reexec.Self(),
-		Args:   append([]string{"fwmarker"}, path, vip.String(), fmt.Sprintf("%d", fwMark), addDelOpt, ingressPortsFile, eIP.IP.String()),
+		Args:   append([]string{"fwmarker"}, path, vip.String(), fmt.Sprintf("%d", fwMark), addDelOpt, ingressPortsFile, eIP.IP.String(), strconv.FormatBool(dsr), vip6Str, strconv.FormatUint(uint64(connRate), 10)),
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
 	}
@@ -467,16 +1390,35 @@ func fwMarker() {
 		os.Exit(2)
 	}
 	addDelOpt := os.Args[4]
+	dsr := len(os.Args) > 7 && os.Args[7] == "true"
 
 	rules := [][]string{}
 	for _, iPort := range ingressPorts {
-		rule := strings.Fields(fmt.Sprintf("-t nat %s PREROUTING -p %s --dport %d -j REDIRECT --to-port %d",
-			addDelOpt, strings.ToLower(PortConfig_Protocol_name[int32(iPort.Protocol)]), iPort.NodePort, iPort.Port))
-		rules = append(rules, rule)
+		// The PROXY protocol proxy listens on the node port
+		// itself and connects to the vip.
+		if usesProxyProtocol(iPort) || iPort.PublishMode == PublishModeHost {
+			continue
+		}
+
+		proto := strings.ToLower(PortConfig_Protocol_name[int32(iPort.Protocol)])
+		for _, span := range ingressPortSpans(iPort) {
+			var rule []string
+			if dsr {
+				// Direct routing does not translate the packets,
+				// hence they need to be addressed to the vip the
+				// backends are listening on.
+				rule = strings.Fields(fmt.Sprintf("-t nat %s PREROUTING -p %s --dport %s -j DNAT --to-destination %s:%s",
+					addDelOpt, proto, portMatch(span.nodePort, span.count), vip, portTarget(span.port, span.count)))
+			} else {
+				rule = strings.Fields(fmt.Sprintf("-t nat %s PREROUTING -p %s --dport %s -j REDIRECT --to-port %s",
+					addDelOpt, proto, portMatch(span.nodePort, span.count), portTarget(span.port, span.count)))
+			}
+			rules = append(rules, rule)
 
-		rule = strings.Fields(fmt.Sprintf("-t mangle %s PREROUTING -p %s --dport %d -j MARK --set-mark %d",
-			addDelOpt, strings.ToLower(PortConfig_Protocol_name[int32(iPort.Protocol)]), iPort.NodePort, fwMark))
-		rules = append(rules, rule)
+			rule = strings.Fields(fmt.Sprintf("-t mangle %s PREROUTING -p %s --dport %s -j MARK --set-mark %d",
+				addDelOpt, proto, portMatch(span.nodePort, span.count), fwMark))
+			rules = append(rules, rule)
+		}
 	}
 
 	ns, err := netns.GetFromPath(os.Args[1])
@@ -492,7 +1434,9 @@ func fwMarker() {
 	}
 
 	if len(ingressPorts) != 0 && addDelOpt == "-A" {
-		ruleParams := strings.Fields(fmt.Sprintf("-m ipvs --ipvs -j SNAT --to-source %s", os.Args[6]))
+		// Only masqueraded connections are source translated, the
+		// replies of the direct routed ones bypass the sandbox.
+		ruleParams := strings.Fields(fmt.Sprintf("-m ipvs --ipvs --vmethod masq -j SNAT --to-source %s", os.Args[6]))
 		if !iptables.Exists("nat", "POSTROUTING", ruleParams...) {
 			rule := append(strings.Fields("-t nat -A POSTROUTING"), ruleParams...)
 			rules = append(rules, rule)
@@ -508,10 +1452,29 @@ func fwMarker() {
 	rule := strings.Fields(fmt.Sprintf("-t mangle %s OUTPUT -d %s/32 -j MARK --set-mark %d", addDelOpt, vip, fwMark))
 	rules = append(rules, rule)
 
+	// The new ingress connections exceeding the rate of the service
+	// are dropped before reaching the load balancer, which protects
+	// the backends from SYN floods.
+	if len(os.Args) > 9 && os.Args[9] != "" && os.Args[9] != "0" {
+		rate := os.Args[9]
+		rules = append(rules,
+			strings.Fields(fmt.Sprintf("-t filter %s INPUT -m mark --mark %d -m conntrack --ctstate NEW -m limit --limit %s/second --limit-burst %s -j ACCEPT",
+				addDelOpt, fwMark, rate, rate)),
+			strings.Fields(fmt.Sprintf("-t filter %s INPUT -m mark --mark %d -m conntrack --ctstate NEW -j DROP", addDelOpt, fwMark)))
+	}
+
 	for _, rule := range rules {
 		if err := iptables.RawCombinedOutputNative(rule...); err != nil {
 			logrus.Errorf("setting up rule failed, %v: %v", rule, err)
 			os.Exit(5)
 		}
 	}
+
+	if len(os.Args) > 8 && os.Args[8] != "" {
+		args := strings.Fields(fmt.Sprintf("-t mangle %s OUTPUT -d %s/128 -j MARK --set-mark %d", addDelOpt, os.Args[8], fwMark))
+		if out, err := exec.Command("ip6tables", args...).CombinedOutput(); err != nil {
+			logrus.Errorf("setting up ipv6 rule failed, %v: %v (%s)", args, err, out)
+			os.Exit(5)
+		}
+	}
 }
@@ -0,0 +1,171 @@
+package libnetwork
+
+import (
+	"net"
+	"sync"
+
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/libnetwork/types"
+)
+
+// localBackend is the address of an endpoint attached to a local service
+type localBackend struct {
+	ip  net.IP
+	ip6 net.IP
+}
+
+// localService is a service created through the controller API instead
+// of being learnt from the cluster agent. Its backends are attached and
+// detached by the embedder.
+type localService struct {
+	id   string
+	name string
+	nid  string
+	vip  net.IP
+	// Whether the vip was allocated from the network pool
+	vipAllocated bool
+	backends     map[string]localBackend
+	sync.Mutex
+}
+
+func (c *controller) CreateService(name, nid string, vip net.IP) (string, net.IP, error) {
+	if name == "" {
+		return "", nil, types.BadRequestErrorf("service name cannot be empty")
+	}
+
+	if _, err := c.NetworkByID(nid); err != nil {
+		return "", nil, err
+	}
+
+	c.Lock()
+	for _, ls := range c.localServices {
+		if ls.name == name && ls.nid == nid {
+			c.Unlock()
+			return "", nil, types.ForbiddenErrorf("service %s already exists on network %s", name, nid)
+		}
+	}
+	c.Unlock()
+
+	ls := &localService{
+		id:       stringid.GenerateRandomID(),
+		name:     name,
+		nid:      nid,
+		vip:      vip,
+		backends: make(map[string]localBackend),
+	}
+	if len(ls.vip) == 0 {
+		var err error
+		if ls.vip, err = c.RequestVIP(nid); err != nil {
+			return "", nil, err
+		}
+		ls.vipAllocated = true
+	}
+
+	c.Lock()
+	c.localServices[ls.id] = ls
+	c.Unlock()
+
+	return ls.id, ls.vip, nil
+}
+
+func (c *controller) AttachServiceBackend(sid, eid string) error {
+	ls, err := c.localService(sid)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.NetworkByID(ls.nid)
+	if err != nil {
+		return err
+	}
+
+	e, err := n.EndpointByID(eid)
+	if err != nil {
+		return err
+	}
+	ep := e.(*endpoint)
+	if ep.Iface() == nil || ep.Iface().Address() == nil {
+		return types.ForbiddenErrorf("endpoint %s has no address", ep.Name())
+	}
+
+	ls.Lock()
+	defer ls.Unlock()
+
+	if _, ok := ls.backends[eid]; ok {
+		return nil
+	}
+
+	be := localBackend{ip: ep.Iface().Address().IP, ip6: ep.ipv6Addr()}
+	if err := c.addServiceBinding(ls.name, ls.id, ls.nid, eid, ls.vip, nil, nil, "", 0, 0, false, "", 0, 0, 0, nil, be.ip, be.ip6, nil, 0, ""); err != nil {
+		return err
+	}
+	ls.backends[eid] = be
+
+	return nil
+}
+
+func (c *controller) DetachServiceBackend(sid, eid string) error {
+	ls, err := c.localService(sid)
+	if err != nil {
+		return err
+	}
+
+	ls.Lock()
+	defer ls.Unlock()
+
+	return c.detachServiceBackend(ls, eid)
+}
+
+func (c *controller) DeleteService(sid string) error {
+	ls, err := c.localService(sid)
+	if err != nil {
+		return err
+	}
+
+	ls.Lock()
+	defer ls.Unlock()
+
+	for eid := range ls.backends {
+		if err := c.detachServiceBackend(ls, eid); err != nil {
+			return err
+		}
+	}
+
+	if ls.vipAllocated {
+		if err := c.ReleaseVIP(ls.nid, ls.vip); err != nil {
+			return err
+		}
+	}
+
+	c.Lock()
+	delete(c.localServices, sid)
+	c.Unlock()
+
+	return nil
+}
+
+// detachServiceBackend must be called with the local service lock held
+func (c *controller) detachServiceBackend(ls *localService, eid string) error {
+	be, ok := ls.backends[eid]
+	if !ok {
+		return types.NotFoundErrorf("endpoint %s is not a backend of service %s", eid, ls.name)
+	}
+
+	if err := c.rmServiceBinding(ls.name, ls.id, ls.nid, eid, ls.vip, nil, nil, be.ip, be.ip6); err != nil {
+		return err
+	}
+	delete(ls.backends, eid)
+
+	return nil
+}
+
+func (c *controller) localService(sid string) (*localService, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	ls, ok := c.localServices[sid]
+	if !ok {
+		return nil, types.NotFoundErrorf("service %s not found", sid)
+	}
+	return ls, nil
+}
@@ -5,15 +5,35 @@ package libnetwork
 import (
 	"fmt"
 	"net"
+	"time"
 )
 
-func (c *controller) addServiceBinding(name, sid, nid, eid string, vip net.IP, ingressPorts []*PortConfig, ip net.IP) error {
+func (c *controller) addServiceBinding(name, sid, nid, eid string, vip, vip6 net.IP, ingressPorts []*PortConfig, schedName string, persistTimeout, persistMaskLen uint32, dsr bool, lbPref string, udpTimeout, maxConns, connRate uint32, metadata []string, ip, ip6, nodeAddr net.IP, weight uint32, group string) error {
 	return fmt.Errorf("not supported")
 }
 
-func (c *controller) rmServiceBinding(name, sid, nid, eid string, vip net.IP, ingressPorts []*PortConfig, ip net.IP) error {
+func (c *controller) rmServiceBinding(name, sid, nid, eid string, vip, vip6 net.IP, ingressPorts []*PortConfig, ip, ip6 net.IP) error {
 	return fmt.Errorf("not supported")
 }
 
+func (c *controller) ServiceStats(sid string) (*ServiceStats, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (c *controller) setBackendLoad(nid, sid, eid, node string, conns uint32, isDelete bool) {
+}
+
+func (c *controller) setServiceTraffic(sid string, t *trafficSplit) {
+}
+
+func (c *controller) publishLoadHints(interval time.Duration, stopCh chan struct{}) {
+}
+
+func warmLBSandbox(key string) {
+}
+
 func (sb *sandbox) populateLoadbalancers(ep *endpoint) {
 }
+
+func (sb *sandbox) releaseLoadbalancers(ep *endpoint) {
+}
@@ -0,0 +1,163 @@
+package libnetwork
+
+import (
+	"net"
+
+	"github.com/docker/go-events"
+)
+
+type svcRecordEvent struct {
+	NetworkID   string
+	NetworkName string
+	Name        string
+	IP          net.IP
+	IPv6        net.IP
+}
+
+// SvcRecordAddEvent is sent to the watchers when a name record is
+// added to the service discovery of a network
+type SvcRecordAddEvent svcRecordEvent
+
+// SvcRecordDeleteEvent is sent to the watchers when a name record is
+// removed from the service discovery of a network
+type SvcRecordDeleteEvent svcRecordEvent
+
+type serviceEvent struct {
+	NetworkID   string
+	NetworkName string
+	ServiceID   string
+	Name        string
+	VIP         net.IP
+	Backends    []net.IP
+}
+
+// ServiceUpdateEvent is sent to the watchers when a backend is added to
+// or removed from a service load balancer. Backends carries the full
+// set of backends after the change.
+type ServiceUpdateEvent serviceEvent
+
+// ServiceDeleteEvent is sent to the watchers when the last backend of a
+// service load balancer on a network is removed
+type ServiceDeleteEvent serviceEvent
+
+// ServiceBindingEventType is the kind of change of a service binding
+type ServiceBindingEventType int
+
+const (
+	// ServiceBindingAdd is sent when a backend is bound to a service
+	ServiceBindingAdd ServiceBindingEventType = iota
+	// ServiceBindingRemove is sent when a backend is unbound from a service
+	ServiceBindingRemove
+	// ServiceBindingUpdate is sent when an already bound backend is
+	// bound again, e.g. with a different weight
+	ServiceBindingUpdate
+)
+
+// ServiceBindingEvent is sent to the subscribers when a backend of a
+// service load balancer on a network is added, removed or updated. It
+// carries the service VIPs and ingress ports so that load balancers
+// external to the cluster can mirror the service configuration.
+type ServiceBindingEvent struct {
+	Type         ServiceBindingEventType
+	NetworkID    string
+	NetworkName  string
+	ServiceID    string
+	ServiceName  string
+	EndpointID   string
+	VIP          net.IP
+	VIPv6        net.IP
+	IP           net.IP
+	IPv6         net.IP
+	Weight       int
+	IngressPorts []*PortConfig
+}
+
+func (c *controller) WatchServiceRecords(nid string) (chan events.Event, func()) {
+	return c.watchServiceEvents(events.MatcherFunc(func(ev events.Event) bool {
+		switch ev := ev.(type) {
+		case SvcRecordAddEvent:
+			return nid == "" || ev.NetworkID == nid
+		case SvcRecordDeleteEvent:
+			return nid == "" || ev.NetworkID == nid
+		case ServiceUpdateEvent:
+			return nid == "" || ev.NetworkID == nid
+		case ServiceDeleteEvent:
+			return nid == "" || ev.NetworkID == nid
+		}
+		return false
+	}))
+}
+
+func (c *controller) SubscribeServiceEvents() (chan events.Event, func()) {
+	return c.watchServiceEvents(events.MatcherFunc(func(ev events.Event) bool {
+		_, ok := ev.(ServiceBindingEvent)
+		return ok
+	}))
+}
+
+func (c *controller) watchServiceEvents(matcher events.Matcher) (chan events.Event, func()) {
+	ch := events.NewChannel(0)
+	sink := events.Sink(events.NewQueue(ch))
+	sink = events.NewFilter(sink, matcher)
+
+	c.svcBroadcaster.Add(sink)
+	return ch.C, func() {
+		c.svcBroadcaster.Remove(sink)
+		ch.Close()
+		sink.Close()
+	}
+}
+
+func (n *network) notifySvcRecord(add bool, name string, epIP net.IP, epIPv6 net.IP) {
+	ev := svcRecordEvent{
+		NetworkID:   n.ID(),
+		NetworkName: n.Name(),
+		Name:        name,
+		IP:          epIP,
+		IPv6:        epIPv6,
+	}
+
+	if add {
+		n.getController().svcBroadcaster.Write(SvcRecordAddEvent(ev))
+	} else {
+		n.getController().svcBroadcaster.Write(SvcRecordDeleteEvent(ev))
+	}
+}
+
+// notifyService must be called with the service lock held
+func (n *network) notifyService(s *service, lb *loadBalancer, deleted bool) {
+	ev := serviceEvent{
+		NetworkID:   n.ID(),
+		NetworkName: n.Name(),
+		ServiceID:   s.id,
+		Name:        s.name,
+		VIP:         lb.vip,
+	}
+	for _, be := range lb.backEnds {
+		ev.Backends = append(ev.Backends, be.ip)
+	}
+
+	if deleted {
+		n.getController().svcBroadcaster.Write(ServiceDeleteEvent(ev))
+	} else {
+		n.getController().svcBroadcaster.Write(ServiceUpdateEvent(ev))
+	}
+}
+
+// notifyServiceBinding must be called with the service lock held
+func (n *network) notifyServiceBinding(typ ServiceBindingEventType, s *service, lb *loadBalancer, eid string, be lbBackend) {
+	n.getController().svcBroadcaster.Write(ServiceBindingEvent{
+		Type:         typ,
+		NetworkID:    n.ID(),
+		NetworkName:  n.Name(),
+		ServiceID:    s.id,
+		ServiceName:  s.name,
+		EndpointID:   eid,
+		VIP:          lb.vip,
+		VIPv6:        lb.vip6,
+		IP:           be.ip,
+		IPv6:         be.ip6,
+		Weight:       be.weight,
+		IngressPorts: s.ingressPorts,
+	})
+}
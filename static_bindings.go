@@ -0,0 +1,162 @@
+package libnetwork
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/libnetwork/ipamapi"
+	"github.com/docker/libnetwork/types"
+)
+
+// parseStaticBinding returns the IPv4 and the IPv6 address of the binding
+func parseStaticBinding(name, value string) (net.IP, net.IP, error) {
+	var v4, v6 net.IP
+	for _, s := range strings.Split(value, ",") {
+		ip := net.ParseIP(strings.TrimSpace(s))
+		switch {
+		case ip == nil:
+			return nil, nil, types.BadRequestErrorf("invalid address %q bound to %s", s, name)
+		case ip.To4() != nil && v4 == nil:
+			v4 = ip.To4()
+		case ip.To4() == nil && v6 == nil:
+			v6 = ip
+		default:
+			return nil, nil, types.BadRequestErrorf("more than one address of the same family bound to %s", name)
+		}
+	}
+	return v4, v6, nil
+}
+
+// staticAddress returns the address of the version bound to the name, if
+// any
+func (n *network) staticAddress(name string, ipVer int) net.IP {
+	n.Lock()
+	value, ok := n.staticBindings[name]
+	n.Unlock()
+	if !ok {
+		return nil
+	}
+	v4, v6, err := parseStaticBinding(name, value)
+	if err != nil {
+		return nil
+	}
+	if ipVer == 4 {
+		return v4
+	}
+	return v6
+}
+
+// staticAddresses returns the addresses of the version bound to the names,
+// by name
+func (n *network) staticAddresses(ipVer int) map[string]net.IP {
+	n.Lock()
+	names := make([]string, 0, len(n.staticBindings))
+	for name := range n.staticBindings {
+		names = append(names, name)
+	}
+	n.Unlock()
+
+	m := make(map[string]net.IP, len(names))
+	for _, name := range names {
+		if ip := n.staticAddress(name, ipVer); ip != nil {
+			m[name] = ip
+		}
+	}
+	return m
+}
+
+// isStaticAddress returns whether the address is the one bound to the name
+func (n *network) isStaticAddress(name string, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	ipVer := 6
+	if ip.To4() != nil {
+		ipVer = 4
+	}
+	s := n.staticAddress(name, ipVer)
+	return s != nil && s.Equal(ip)
+}
+
+// poolOf returns the pool of the network the address of the version
+// belongs to
+func (n *network) poolOf(ip net.IP, ipVer int) *IpamInfo {
+	for _, d := range n.getIPInfo(ipVer) {
+		if d.Pool.Contains(ip) {
+			return d
+		}
+	}
+	return nil
+}
+
+// reserveStaticAddresses reserves the addresses bound to names in the
+// pools of the network. The addresses already reserved are released on
+// failure.
+func (n *network) reserveStaticAddresses(ipam ipamapi.Ipam) (err error) {
+	n.Lock()
+	names := make([]string, 0, len(n.staticBindings))
+	for name, value := range n.staticBindings {
+		if _, _, err := parseStaticBinding(name, value); err != nil {
+			n.Unlock()
+			return err
+		}
+		names = append(names, name)
+	}
+	n.Unlock()
+	sort.Strings(names)
+
+	type reservation struct {
+		poolID string
+		ip     net.IP
+	}
+	var reserved []reservation
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, r := range reserved {
+			if err := ipam.ReleaseAddress(r.poolID, r.ip); err != nil {
+				log.Warnf("Failed to release static address %s of network %s: %v", r.ip, n.Name(), err)
+			}
+		}
+	}()
+
+	for _, name := range names {
+		for _, ipVer := range []int{4, 6} {
+			ip := n.staticAddress(name, ipVer)
+			if ip == nil {
+				continue
+			}
+			d := n.poolOf(ip, ipVer)
+			if d == nil {
+				return types.BadRequestErrorf("address %s bound to %s does not belong to any pool of network %s", ip, name, n.Name())
+			}
+			if _, _, err := ipam.RequestAddress(d.PoolID, ip, nil); err != nil {
+				return types.ForbiddenErrorf("failed to reserve address %s bound to %s on network %s: %v", ip, name, n.Name(), err)
+			}
+			n.auditAddress(AddressAllocated, AddressRoleStatic, d.PoolID, ip, nil)
+			reserved = append(reserved, reservation{poolID: d.PoolID, ip: ip})
+		}
+	}
+
+	return nil
+}
+
+// releaseStaticAddresses releases the addresses bound to names
+func (n *network) releaseStaticAddresses(ipam ipamapi.Ipam) {
+	for _, ipVer := range []int{4, 6} {
+		for name, ip := range n.staticAddresses(ipVer) {
+			d := n.poolOf(ip, ipVer)
+			if d == nil {
+				continue
+			}
+			if err := ipam.ReleaseAddress(d.PoolID, ip); err != nil {
+				log.Warnf("Failed to release address %s bound to %s on delete of network %s (%s): %v", ip, name, n.Name(), n.ID(), err)
+				continue
+			}
+			n.auditAddress(AddressReleased, AddressRoleStatic, d.PoolID, ip, nil)
+		}
+	}
+}
@@ -9,12 +9,14 @@ import (
 	"github.com/docker/libkv/store/etcd"
 	"github.com/docker/libkv/store/zookeeper"
 	"github.com/docker/libnetwork/datastore"
+	"github.com/docker/libnetwork/datastore/etcdv3"
 )
 
 func registerKVStores() {
 	consul.Register()
 	zookeeper.Register()
 	etcd.Register()
+	etcdv3.Register()
 	boltdb.Register()
 }
 
@@ -254,6 +256,59 @@ retry:
 	return nil
 }
 
+// updateTxnToStore atomically performs the writes of the objects of the
+// operations, which must belong to the same scope
+func (c *controller) updateTxnToStore(ops ...datastore.TxnOp) error {
+	scope := ops[0].Object.DataScope()
+	for _, op := range ops[1:] {
+		if op.Object.DataScope() != scope {
+			return fmt.Errorf("transaction across the %s and %s scopes", scope, op.Object.DataScope())
+		}
+	}
+	cs := c.getStore(scope)
+	if cs == nil {
+		return fmt.Errorf("datastore for scope %q is not initialized ", scope)
+	}
+
+	if err := cs.Transaction(ops...); err != nil {
+		if err == datastore.ErrKeyModified {
+			return err
+		}
+		return fmt.Errorf("failed to update store in transaction: %v", err)
+	}
+
+	return nil
+}
+
+// deleteTxnFromStore atomically deletes the objects, which must belong to
+// the same scope
+func (c *controller) deleteTxnFromStore(kvObjects ...datastore.KVObject) error {
+	cs := c.getStore(kvObjects[0].DataScope())
+	if cs == nil {
+		return fmt.Errorf("datastore for scope %q is not initialized ", kvObjects[0].DataScope())
+	}
+
+	var ops []datastore.TxnOp
+	for _, o := range kvObjects {
+		ops = append(ops, datastore.TxnOp{Object: o, Delete: true})
+	}
+
+retry:
+	if err := c.updateTxnToStore(ops...); err != nil {
+		if err == datastore.ErrKeyModified {
+			for _, o := range kvObjects {
+				if err := cs.GetObject(datastore.Key(o.Key()...), o); err != nil {
+					return fmt.Errorf("could not update the kvobject to latest when trying to delete: %v", err)
+				}
+			}
+			goto retry
+		}
+		return err
+	}
+
+	return nil
+}
+
 type netWatch struct {
 	localEps  map[string]*endpoint
 	remoteEps map[string]*endpoint